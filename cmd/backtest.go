@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"diabetbot/internal/config"
+	"diabetbot/internal/database"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/services"
+)
+
+const backtestUsage = `Usage: diabetbot backtest <telegram_id> [days]
+
+Replays the user's stored history for the last days (default 90): builds a
+glucose forecast at every reading using only data known at that moment and
+compares it with the readings one and two hours later.`
+
+const defaultBacktestDays = 90
+
+// runBacktest выполняет подкоманду backtest
+func runBacktest(cfg *config.Config, args []string) error {
+	switch {
+	case len(args) == 0:
+		return fmt.Errorf("missing telegram_id\n\n%s", backtestUsage)
+	case len(args) > 2:
+		return fmt.Errorf("too many arguments\n\n%s", backtestUsage)
+	}
+	telegramID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid telegram_id %q", args[0])
+	}
+	days := defaultBacktestDays
+	if len(args) > 1 {
+		days, err = strconv.Atoi(args[1])
+		if err != nil || days <= 0 {
+			return fmt.Errorf("invalid number of days %q", args[1])
+		}
+	}
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	svc := services.New(repository.NewGorm(db.DB))
+	user, err := svc.Users.GetByTelegramID(telegramID)
+	if err != nil {
+		return fmt.Errorf("user %d: %w", telegramID, err)
+	}
+	to := time.Now()
+	result, err := svc.Forecast.Backtest(user.ID, to.AddDate(0, 0, -days), to)
+	if err != nil {
+		return err
+	}
+	printBacktest(result)
+	return nil
+}
+
+func printBacktest(result *services.BacktestResult) {
+	fmt.Printf("Forecasts from %s to %s: %d\n\n", result.From.Format("2006-01-02"), result.To.Format("2006-01-02"), result.Forecasts)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HORIZON\tCHECKED\tMAE\tNO-CHANGE MAE\tIN BAND")
+	for _, h := range result.Horizons {
+		fmt.Fprintf(w, "%d min\t%d\t%.2f\t%.2f\t%.0f%%\n", h.Minutes, h.Count, h.MAE, h.NaiveMAE, h.Coverage*100)
+	}
+	w.Flush()
+	fmt.Println("\nMAE in mmol/L; no-change MAE assumes glucose stays at the last reading.")
+}
@@ -19,6 +19,22 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Подкоманда управления миграциями
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		return
+	}
+
+	// Проверка точности прогноза глюкозы на сохраненной истории
+	if len(os.Args) > 1 && os.Args[1] == "backtest" {
+		if err := runBacktest(cfg, os.Args[2:]); err != nil {
+			log.Fatal("Backtest failed: ", err)
+		}
+		return
+	}
+
 	// Initialize and start application
 	application := app.New(cfg)
 	
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"diabetbot/internal/config"
+	"diabetbot/internal/database"
+)
+
+const migrateUsage = `Usage: diabetbot migrate <command>
+
+Commands:
+  up          apply all pending migrations
+  down [N]    roll back the last N migrations (default 1)
+  status      show applied and pending migrations`
+
+// runMigrate выполняет подкоманду migrate
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n\n%s", migrateUsage)
+	}
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db.DB)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		count, err := migrator.Down(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migrations\n", count)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(statuses)
+	default:
+		return fmt.Errorf("unknown migrate command %q\n\n%s", args[0], migrateUsage)
+	}
+
+	return nil
+}
+
+func printMigrationStatus(statuses []database.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied() {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	w.Flush()
+}
@@ -13,6 +13,7 @@ import (
 	"diabetbot/internal/config"
 	"diabetbot/internal/database"
 	"diabetbot/internal/handlers"
+	"diabetbot/internal/repository"
 	"diabetbot/internal/services"
 	"diabetbot/internal/telegram"
 
@@ -23,8 +24,10 @@ import (
 type App struct {
 	config   *config.Config
 	db       *database.Database
+	services *services.Services
 	bot      *telegram.Bot
 	server   *http.Server
+	stopJobs context.CancelFunc
 }
 
 func New(cfg *config.Config) *App {
@@ -42,15 +45,21 @@ func (a *App) Run() error {
 	a.db = db
 
 	// Миграция базы данных
-	if err := db.AutoMigrate(); err != nil {
+	if err := db.Migrate(); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	a.services = services.New(repository.NewGorm(db.DB))
+
 	// Создаем AI сервисы с приоритетом YandexGPT
 	var aiService services.AIService
 	
 	yandexGPTService := services.NewYandexGPTService(&a.config.YandexGPT)
 	gigaChatService := services.NewGigaChatService(&a.config.GigaChat)
+	// Найденные в дневнике закономерности, вес, давление и кетоны добавляются к запросам к ИИ
+	patientContext := services.PatientContexts{a.services.Insights, a.services.Vitals}
+	yandexGPTService.SetPatientContext(patientContext)
+	gigaChatService.SetPatientContext(patientContext)
 	
 	// Используем YandexGPT как основной, GigaChat как fallback
 	if a.config.YandexGPT.APIKey != "" && a.config.YandexGPT.APIKey != "your_yandex_api_key_here" {
@@ -65,8 +74,10 @@ func (a *App) Run() error {
 	}
 	
 	// Оборачиваем AI сервис в ограничитель запросов
-	limitedAIService := services.NewLimitedAIService(aiService, db.DB)
+	limitedAIService := services.NewLimitedAIService(aiService, a.services.AIUsage)
 	log.Printf("AI request limit enabled: %d requests per user per day", services.DailyAIRequestLimit)
+	// Еженедельная сводка обращается к ИИ напрямую и не расходует дневной лимит
+	a.services.Digests.SetAI(aiService)
 	
 	// Инициализация веб-сервера (всегда запускается)
 	if err := a.setupServer(); err != nil {
@@ -75,11 +86,14 @@ func (a *App) Run() error {
 
 	// Инициализация бота (только если есть токен)
 	if a.config.Telegram.BotToken != "" {
-		bot, err := telegram.NewBot(&a.config.Telegram, db.DB, limitedAIService)
+		bot, err := telegram.NewBot(&a.config.Telegram, a.services, limitedAIService)
 		if err != nil {
 			log.Printf("Failed to initialize bot (continuing without bot): %v", err)
 		} else {
 			a.bot = bot
+			// Тревоги родственникам по критическим значениям глюкозы отправляет бот
+			a.services.Alerts.SetNotifier(bot)
+			a.services.Digests.SetNotifier(bot)
 			// Запуск бота в горутине
 			go func() {
 				log.Println("Starting Telegram bot...")
@@ -92,6 +106,11 @@ func (a *App) Run() error {
 		log.Println("No Telegram bot token provided, running web server only")
 	}
 
+	// Фоновые задачи запускаются после бота: эскалация тревог рассылается через него
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	a.stopJobs = stopJobs
+	a.startJobs(jobsCtx)
+
 	// Запуск веб-сервера в горутине
 	go func() {
 		log.Printf("Starting web server on %s:%s", a.config.Server.Host, a.config.Server.Port)
@@ -115,7 +134,8 @@ func (a *App) setupServer() error {
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Telegram-Init-Data")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Telegram-Init-Data, X-Request-ID, "+handlers.NightscoutSecretHeader)
+		c.Header("Access-Control-Expose-Headers", handlers.RequestIDHeader)
 		
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -125,28 +145,18 @@ func (a *App) setupServer() error {
 		c.Next()
 	})
 
+	// ID запроса и единый формат ошибок
+	router.Use(handlers.ErrorHandler())
+
 	// Инициализация обработчиков API
-	apiHandler := handlers.NewAPIHandler(a.db.DB)
+	apiHandler := handlers.NewAPIHandler(a.services, a.config.Telegram.BotToken)
 	
 	// API роуты
-	api := router.Group("/api/v1")
-	{
-		api.GET("/user/:telegram_id", apiHandler.GetUser)
-		api.PUT("/user/:telegram_id", apiHandler.UpdateUser)
-		api.PUT("/user/:telegram_id/diabetes-info", apiHandler.UpdateDiabetesInfo)
-		api.DELETE("/user/:telegram_id/data", apiHandler.DeleteUserData)
-		
-		api.GET("/glucose/:user_id", apiHandler.GetGlucoseRecords)
-		api.POST("/glucose", apiHandler.CreateGlucoseRecord)
-		api.PUT("/glucose/:id", apiHandler.UpdateGlucoseRecord)
-		api.DELETE("/glucose/:id", apiHandler.DeleteGlucoseRecord)
-		api.GET("/glucose/:user_id/stats", apiHandler.GetGlucoseStats)
-		
-		api.GET("/food/:user_id", apiHandler.GetFoodRecords)
-		api.POST("/food", apiHandler.CreateFoodRecord)
-		api.PUT("/food/:id", apiHandler.UpdateFoodRecord)
-		api.DELETE("/food/:id", apiHandler.DeleteFoodRecord)
-	}
+	apiHandler.RegisterRoutes(router.Group("/api/v1"))
+	// Совместимый с Nightscout API для xDrip+, AAPS и приложений мониторинга
+	handlers.NewNightscoutHandler(a.services).RegisterRoutes(router.Group("/api/v1"))
+	// Сводка дневника по публичной ссылке для врача без Telegram
+	handlers.NewPublicShareHandler(a.services).RegisterRoutes(router)
 
 	// Статические файлы для веб-приложения
 	router.Static("/webapp", "./web/dist")
@@ -157,7 +167,7 @@ func (a *App) setupServer() error {
 		if len(c.Request.URL.Path) > 7 && c.Request.URL.Path[:7] == "/webapp" {
 			c.File("./web/dist/index.html")
 		} else {
-			c.JSON(404, gin.H{"error": "Not found"})
+			_ = c.Error(services.ErrNotFound)
 		}
 	})
 
@@ -175,13 +185,21 @@ func (a *App) setupServer() error {
 			return
 		}
 		
-		a.bot.HandleWebhook(update)
+		if err := a.bot.HandleWebhook(update); err != nil {
+			// Не 2xx ответ заставит Telegram повторить доставку позже
+			c.JSON(503, gin.H{"error": "Bot is busy"})
+			return
+		}
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok", "timestamp": time.Now().Unix()})
+		response := gin.H{"status": "ok", "timestamp": time.Now().Unix()}
+		if a.bot != nil {
+			response["bot"] = a.bot.Stats()
+		}
+		c.JSON(200, response)
 	})
 
 	a.server = &http.Server{
@@ -207,6 +225,15 @@ func (a *App) waitForShutdown() error {
 		return err
 	}
 
+	// Новые обновления больше не поступают, дожидаемся обработки принятых
+	if a.bot != nil {
+		if err := a.bot.Shutdown(ctx); err != nil {
+			log.Printf("Bot forced to shutdown with %d pending updates: %v", a.bot.Stats().Updates.Pending, err)
+		}
+	}
+
+	a.stopJobs()
+
 	if err := a.db.Close(); err != nil {
 		log.Printf("Error closing database: %v", err)
 		return err
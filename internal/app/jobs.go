@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// cgmRetentionInterval — как часто удалять устаревшие показания CGM
+const cgmRetentionInterval = 24 * time.Hour
+
+// alertEscalationInterval — как часто проверять тревоги без повторного измерения
+const alertEscalationInterval = time.Minute
+
+// digestInterval — как часто проверять, кому пора отправить еженедельную сводку
+const digestInterval = 10 * time.Minute
+
+// startJobs запускает фоновые задачи; они останавливаются при отмене ctx
+func (a *App) startJobs(ctx context.Context) {
+	if days := a.config.Database.CGMRetentionDays; days > 0 {
+		log.Printf("CGM retention enabled: readings older than %d days are deleted", days)
+		go runEvery(ctx, cgmRetentionInterval, func() { a.purgeCGM(days) })
+	}
+	if a.bot != nil {
+		go runEvery(ctx, alertEscalationInterval, a.escalateAlerts)
+		go runEvery(ctx, digestInterval, a.sendDigests)
+	}
+}
+
+// purgeCGM удаляет показания сенсора старше days дней
+func (a *App) purgeCGM(days int) {
+	deleted, err := a.services.Glucose.PurgeCGM(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("CGM retention error: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("CGM retention: deleted %d readings", deleted)
+	}
+}
+
+// escalateAlerts напоминает родственникам о тревогах без повторного измерения
+func (a *App) escalateAlerts() {
+	escalated, err := a.services.Alerts.Escalate()
+	if err != nil {
+		log.Printf("Alert escalation error: %v", err)
+	}
+	if escalated > 0 {
+		log.Printf("Alert escalation: %d alerts escalated", escalated)
+	}
+}
+
+// sendDigests отправляет еженедельные сводки подписчикам, у которых наступил воскресный вечер
+func (a *App) sendDigests() {
+	sent, err := a.services.Digests.SendDue()
+	if err != nil {
+		log.Printf("Weekly digest error: %v", err)
+	}
+	if sent > 0 {
+		log.Printf("Weekly digest: %d digests sent", sent)
+	}
+}
+
+// runEvery выполняет job сразу и затем с интервалом interval, пока ctx не отменен
+func runEvery(ctx context.Context, interval time.Duration, job func()) {
+	job()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job()
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
@@ -16,11 +17,15 @@ type TelegramConfig struct {
 	BotToken   string
 	WebhookURL string
 	WebAppURL  string
+	APIEndpoint string // Шаблон адреса Bot API, пустой — api.telegram.org
+	Workers    int // Количество воркеров обработки обновлений
+	QueueSize  int // Максимум обновлений, ожидающих обработки
 }
 
 type GigaChatConfig struct {
 	APIKey  string
 	BaseURL string
+	AuthURL string // пусто — BaseURL + /api/v1/oauth
 }
 
 type YandexGPTConfig struct {
@@ -29,12 +34,15 @@ type YandexGPTConfig struct {
 }
 
 type DatabaseConfig struct {
+	Driver   string // postgres или sqlite
+	Path     string // путь к файлу базы для sqlite
 	Host     string
 	Port     string
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string
+	CGMRetentionDays int // Срок хранения показаний CGM в днях, 0 — бессрочно
 }
 
 type ServerConfig struct {
@@ -49,22 +57,30 @@ func Load() *Config {
 			BotToken:   getEnv("TELEGRAM_BOT_TOKEN", ""),
 			WebhookURL: getEnv("TELEGRAM_WEBHOOK_URL", ""),
 			WebAppURL:  getEnv("WEBAPP_URL", ""),
+			APIEndpoint: getEnv("TELEGRAM_API_ENDPOINT", ""),
+			Workers:    getEnvInt("TELEGRAM_WORKERS", 8),
+			QueueSize:  getEnvInt("TELEGRAM_QUEUE_SIZE", 256),
 		},
 		GigaChat: GigaChatConfig{
 			APIKey:  getEnv("GIGACHAT_API_KEY", ""),
 			BaseURL: getEnv("GIGACHAT_BASE_URL", "https://gigachat.devices.sberbank.ru"),
+			// Локальный прокси для обхода ограничений Docker
+			AuthURL: getEnv("GIGACHAT_AUTH_URL", "http://172.17.0.1:8888/oauth"),
 		},
 		YandexGPT: YandexGPTConfig{
 			APIKey:   getEnv("YANDEXGPT_API_KEY", ""),
 			FolderID: getEnv("YANDEXGPT_FOLDER_ID", ""),
 		},
 		Database: DatabaseConfig{
+			Driver:   getEnv("DB_DRIVER", "postgres"),
+			Path:     getEnv("DB_PATH", "diabetbot.db"),
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnv("DB_PORT", "5432"),
 			User:     getEnv("DB_USER", "diabetbot"),
 			Password: getEnv("DB_PASSWORD", ""),
 			DBName:   getEnv("DB_NAME", "diabetbot"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			CGMRetentionDays: getEnvInt("CGM_RETENTION_DAYS", 0),
 		},
 		Server: ServerConfig{
 			Port: getEnv("SERVER_PORT", "8080"),
@@ -79,4 +95,13 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
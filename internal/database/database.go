@@ -3,26 +3,32 @@ package database
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"diabetbot/internal/config"
-	"diabetbot/internal/models"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// Поддерживаемые драйверы базы данных
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 type Database struct {
 	DB *gorm.DB
 }
 
 func New(cfg *config.DatabaseConfig) (*Database, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.SSLMode)
+	dialector, err := Dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	db, err := Open(dialector, logger.Info)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -30,19 +36,43 @@ func New(cfg *config.DatabaseConfig) (*Database, error) {
 	return &Database{DB: db}, nil
 }
 
-func (d *Database) AutoMigrate() error {
-	err := d.DB.AutoMigrate(
-		&models.User{},
-		&models.GlucoseRecord{},
-		&models.FoodRecord{},
-		&models.AIRecommendation{},
-		&models.AIUsage{},
-	)
+// Dialector возвращает драйвер GORM по настройкам подключения
+func Dialector(cfg *config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "", DriverPostgres:
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+			cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.SSLMode)
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		return SQLiteDialector(cfg.Path), nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+}
+
+// Open открывает подключение с общими для всех драйверов настройками.
+// Время создания и удаления записей пишется в UTC.
+func Open(dialector gorm.Dialector, logLevel logger.LogLevel) (*gorm.DB, error) {
+	return gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
+}
+
+// Migrate применяет неприменённые версионированные миграции
+func (d *Database) Migrate() error {
+	migrator, err := NewMigrator(d.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	count, err := migrator.Up()
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	log.Println("Database migration completed successfully")
+	log.Printf("Database migration completed successfully, applied %d migrations", count)
 	return nil
 }
 
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Миграции лежат в отдельном каталоге для каждого диалекта с общей нумерацией версий
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID — ключ advisory lock в PostgreSQL, общий для всех реплик
+const migrationLockID = 7268954012
+
+// Migration — версионированная миграция из пары файлов NNNN_name.up.sql и NNNN_name.down.sql
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus описывает состояние одной миграции
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Applied сообщает, применена ли миграция
+func (s MigrationStatus) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+// schemaMigration — строка таблицы schema_migrations
+type schemaMigration struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator создает мигратор со встроенными в бинарник миграциями для диалекта db
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	fsys, err := MigrationsFS(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return NewMigratorFS(db, fsys)
+}
+
+// MigrationsFS возвращает встроенные миграции для диалекта (postgres или sqlite)
+func MigrationsFS(dialect string) (fs.FS, error) {
+	dir := path.Join("migrations", dialect)
+	if _, err := fs.Stat(migrationFiles, dir); err != nil {
+		return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+	}
+	return fs.Sub(migrationFiles, dir)
+}
+
+// NewMigratorFS создает мигратор с миграциями из произвольной файловой системы
+func NewMigratorFS(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// LoadMigrations читает миграции из корня fsys и сортирует их по версии
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		version, name, direction, err := parseMigrationName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// parseMigrationName разбирает имя вида 0001_initial_schema.up.sql
+func parseMigrationName(filename string) (int64, string, string, error) {
+	base := strings.TrimSuffix(filename, ".sql")
+	direction := path.Ext(base)
+	if direction != ".up" && direction != ".down" {
+		return 0, "", "", fmt.Errorf("migration %s must end with .up.sql or .down.sql", filename)
+	}
+	base = strings.TrimSuffix(base, direction)
+
+	versionText, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("migration %s must be named NNNN_name", filename)
+	}
+	version, err := strconv.ParseInt(versionText, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s has invalid version %q", filename, versionText)
+	}
+
+	return version, name, direction[1:], nil
+}
+
+// Up применяет все неприменённые миграции и возвращает их количество
+func (m *Migrator) Up() (int, error) {
+	count := 0
+	err := m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down откатывает steps последних применённых миграций и возвращает их количество
+func (m *Migrator) Down(steps int) (int, error) {
+	count := 0
+	err := m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(migration.Down) == "" {
+				return fmt.Errorf("migration %04d_%s has no down script", migration.Version, migration.Name)
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Where("version = ?", migration.Version).Delete(&schemaMigration{}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("Rolled back migration %04d_%s", migration.Version, migration.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status возвращает все известные миграции с отметкой о применении
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if record, ok := applied[migration.Version]; ok {
+				appliedAt := record.AppliedAt
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// applied создает таблицу schema_migrations при необходимости и читает применённые версии
+func (m *Migrator) applied(conn *gorm.DB) (map[int64]schemaMigration, error) {
+	err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamp NOT NULL
+	)`).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var records []schemaMigration
+	if err := conn.Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int64]schemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// withLock выполняет fn на одном соединении под блокировкой миграций.
+// В PostgreSQL используется advisory lock, поэтому реплики, запущенные
+// одновременно, применяют миграции по очереди. SQLite блокирует запись сам.
+func (m *Migrator) withLock(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if conn.Dialector.Name() != "postgres" {
+			return fn(conn)
+		}
+
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID).Error; err != nil {
+				log.Printf("Failed to release migration lock: %v", err)
+			}
+		}()
+
+		return fn(conn)
+	})
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMigrationDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_notes.up.sql":   {Data: []byte("CREATE TABLE notes (id integer PRIMARY KEY, text varchar(100));")},
+		"0001_create_notes.down.sql": {Data: []byte("DROP TABLE notes;")},
+		"0002_add_author.up.sql":     {Data: []byte("ALTER TABLE notes ADD COLUMN author varchar(100);")},
+		"0002_add_author.down.sql":   {Data: []byte("ALTER TABLE notes DROP COLUMN author;")},
+		"README.md":                  {Data: []byte("not a migration")},
+	}
+}
+
+func loadEmbeddedMigrations(t *testing.T, dialect string) []Migration {
+	fsys, err := MigrationsFS(dialect)
+	require.NoError(t, err)
+	migrations, err := LoadMigrations(fsys)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	return migrations
+}
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		t.Run(dialect, func(t *testing.T) {
+			migrations := loadEmbeddedMigrations(t, dialect)
+
+			initial := migrations[0]
+			assert.Equal(t, int64(1), initial.Version)
+			assert.Equal(t, "initial_schema", initial.Name)
+			for _, table := range []string{"users", "glucose_records", "food_records", "insulin_records", "ai_recommendations", "ai_usages"} {
+				assert.Contains(t, initial.Up, `"`+table+`"`)
+				assert.Contains(t, initial.Down, `"`+table+`"`)
+			}
+
+			for i := 1; i < len(migrations); i++ {
+				assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+			}
+		})
+	}
+
+	_, err := MigrationsFS("mysql")
+	assert.Error(t, err)
+}
+
+// У каждого диалекта должен быть один и тот же набор версий
+func TestLoadMigrations_DialectsInSync(t *testing.T) {
+	postgres := loadEmbeddedMigrations(t, "postgres")
+	sqlite := loadEmbeddedMigrations(t, "sqlite")
+
+	require.Equal(t, len(postgres), len(sqlite))
+	for i := range postgres {
+		assert.Equal(t, postgres[i].Version, sqlite[i].Version)
+		assert.Equal(t, postgres[i].Name, sqlite[i].Name)
+	}
+}
+
+// Встроенные миграции SQLite применяются и откатываются на чистой базе
+func TestMigrator_EmbeddedSQLite(t *testing.T) {
+	db := setupMigrationDB(t)
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+
+	count, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, len(migrator.migrations), count)
+	for _, table := range []string{"users", "glucose_records", "food_records", "insulin_records", "ai_recommendations", "ai_usages", "import_jobs"} {
+		assert.True(t, db.Migrator().HasTable(table), table)
+	}
+
+	count, err = migrator.Down(len(migrator.migrations))
+	require.NoError(t, err)
+	assert.Equal(t, len(migrator.migrations), count)
+	assert.False(t, db.Migrator().HasTable("users"))
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"BadDirection":     {"0001_test.sideways.sql": {Data: []byte("SELECT 1;")}},
+		"NoName":           {"0001.up.sql": {Data: []byte("SELECT 1;")}},
+		"BadVersion":       {"abc_test.up.sql": {Data: []byte("SELECT 1;")}},
+		"DuplicateVersion": {"0001_one.up.sql": {Data: []byte("SELECT 1;")}, "0001_two.up.sql": {Data: []byte("SELECT 1;")}},
+		"MissingUp":        {"0001_test.down.sql": {Data: []byte("SELECT 1;")}},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadMigrations(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	db := setupMigrationDB(t)
+	migrator, err := NewMigratorFS(db, testMigrations())
+	require.NoError(t, err)
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.False(t, statuses[0].Applied())
+	assert.False(t, statuses[1].Applied())
+
+	count, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.True(t, db.Migrator().HasColumn("notes", "author"))
+
+	// Повторный запуск ничего не делает
+	count, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	statuses, err = migrator.Status()
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied())
+	assert.True(t, statuses[1].Applied())
+
+	count, err = migrator.Down(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.True(t, db.Migrator().HasTable("notes"))
+	assert.False(t, db.Migrator().HasColumn("notes", "author"))
+
+	statuses, err = migrator.Status()
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied())
+	assert.False(t, statuses[1].Applied())
+
+	count, err = migrator.Down(5)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.False(t, db.Migrator().HasTable("notes"))
+}
+
+func TestMigrator_FailedMigrationIsNotRecorded(t *testing.T) {
+	db := setupMigrationDB(t)
+	fsys := testMigrations()
+	fsys["0003_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE tags (id integer PRIMARY KEY); ALTER TABLE missing ADD COLUMN x int;")}
+
+	migrator, err := NewMigratorFS(db, fsys)
+	require.NoError(t, err)
+
+	count, err := migrator.Up()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "0003_broken")
+	assert.Equal(t, 2, count)
+
+	// Изменения сломанной миграции откатываются вместе с транзакцией
+	assert.False(t, db.Migrator().HasTable("tags"))
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.True(t, statuses[1].Applied())
+	assert.False(t, statuses[2].Applied())
+}
+
+// migrateTo применяет встроенные миграции SQLite до версии version включительно
+func migrateTo(t *testing.T, db *gorm.DB, version string) {
+	fsys, err := MigrationsFS(DriverSQLite)
+	require.NoError(t, err)
+	names, err := fs.Glob(fsys, "*.sql")
+	require.NoError(t, err)
+	upTo := fstest.MapFS{}
+	for _, name := range names {
+		if name[:len(version)] <= version {
+			data, err := fs.ReadFile(fsys, name)
+			require.NoError(t, err)
+			upTo[name] = &fstest.MapFile{Data: data}
+		}
+	}
+	migrator, err := NewMigratorFS(db, upTo)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+}
+
+// 0014 убирает дубли сводок за неделю и не дает создать новые
+func TestMigrator_DigestsUniqueWeek(t *testing.T) {
+	db := setupMigrationDB(t)
+	migrateTo(t, db, "0013")
+
+	require.NoError(t, db.Exec(`INSERT INTO "users" ("id", "telegram_id") VALUES (1, 1)`).Error)
+	insert := `INSERT INTO "digests" ("id", "user_id", "period_from") VALUES (?, 1, '2024-05-06 00:00:00')`
+	for _, id := range []int{1, 2} {
+		require.NoError(t, db.Exec(insert, id).Error)
+	}
+
+	migrateTo(t, db, "0014")
+	var live []int
+	require.NoError(t, db.Raw(`SELECT "id" FROM "digests" WHERE "deleted_at" IS NULL`).Scan(&live).Error)
+	assert.Equal(t, []int{2}, live)
+	assert.Error(t, db.Exec(insert, 3).Error)
+
+	// Мягко удаленная сводка не мешает собрать новую за ту же неделю
+	require.NoError(t, db.Exec(`UPDATE "digests" SET "deleted_at" = CURRENT_TIMESTAMP WHERE "id" = 2`).Error)
+	assert.NoError(t, db.Exec(insert, 3).Error)
+}
+
+// 0015 закрывает лишние незакрытые тревоги и не дает поднять вторую
+func TestMigrator_AlertsSingleActive(t *testing.T) {
+	db := setupMigrationDB(t)
+	migrateTo(t, db, "0014")
+
+	require.NoError(t, db.Exec(`INSERT INTO "users" ("id", "telegram_id") VALUES (1, 1)`).Error)
+	insert := `INSERT INTO "alerts" ("id", "user_id", "kind", "status") VALUES (?, 1, 'low', ?)`
+	for id, status := range map[int]string{1: "open", 2: "acknowledged", 3: "resolved"} {
+		require.NoError(t, db.Exec(insert, id, status).Error)
+	}
+
+	migrateTo(t, db, "0015")
+	var statuses []string
+	require.NoError(t, db.Raw(`SELECT "status" FROM "alerts" ORDER BY "id"`).Scan(&statuses).Error)
+	assert.Equal(t, []string{"resolved", "acknowledged", "resolved"}, statuses)
+	assert.Error(t, db.Exec(insert, 4, "muted").Error)
+	assert.NoError(t, db.Exec(insert, 4, "resolved").Error)
+}
@@ -0,0 +1,99 @@
+-- Схема, которую раньше создавал GORM AutoMigrate.
+-- IF NOT EXISTS позволяет принять под управление уже существующую базу.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "telegram_id" bigint NOT NULL,
+    "username" varchar(255),
+    "first_name" varchar(255),
+    "last_name" varchar(255),
+    "language_code" varchar(10) DEFAULT 'ru',
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "diabetes_type" bigint,
+    "target_glucose" decimal,
+    "notifications" boolean DEFAULT true,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "chk_users_diabetes_type" CHECK (diabetes_type IN (1,2))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_telegram_id" ON "users" ("telegram_id");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "glucose_records" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "value" decimal NOT NULL,
+    "measured_at" timestamptz NOT NULL,
+    "measurement_context" varchar(32),
+    "notes" varchar(500),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_glucose_records" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+-- Колонка появилась позже таблицы, в старых базах ее может не быть
+ALTER TABLE "glucose_records" ADD COLUMN IF NOT EXISTS "measurement_context" varchar(32);
+CREATE INDEX IF NOT EXISTS "idx_glucose_records_deleted_at" ON "glucose_records" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "food_records" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "food_name" varchar(255) NOT NULL,
+    "food_type" varchar(100),
+    "carbs" decimal,
+    "calories" bigint,
+    "quantity" varchar(100),
+    "consumed_at" timestamptz NOT NULL,
+    "notes" varchar(500),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_food_records" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_food_records_deleted_at" ON "food_records" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "insulin_records" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "units" decimal NOT NULL,
+    "insulin_type" varchar(20),
+    "insulin_name" varchar(100),
+    "injected_at" timestamptz NOT NULL,
+    "notes" varchar(500),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_insulin_records_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_insulin_records_deleted_at" ON "insulin_records" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_insulin_records_user_id" ON "insulin_records" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "ai_recommendations" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "type" varchar(50) NOT NULL,
+    "content" text NOT NULL,
+    "context" json,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_ai_recommendations_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "ai_usages" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "date" date NOT NULL,
+    "request_count" bigint DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_ai_usages_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_date" ON "ai_usages" ("user_id","date");
+CREATE INDEX IF NOT EXISTS "idx_ai_usages_deleted_at" ON "ai_usages" ("deleted_at");
@@ -0,0 +1 @@
+ALTER TABLE "ai_usages" ALTER COLUMN "date" TYPE date USING "date"::date;
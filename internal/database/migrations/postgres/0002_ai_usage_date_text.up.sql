@@ -0,0 +1,2 @@
+-- День хранится строкой YYYY-MM-DD (UTC), чтобы сравнение не зависело от часового пояса сессии и СУБД
+ALTER TABLE "ai_usages" ALTER COLUMN "date" TYPE varchar(10) USING to_char("date", 'YYYY-MM-DD');
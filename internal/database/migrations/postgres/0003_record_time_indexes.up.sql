@@ -0,0 +1,3 @@
+-- Списки записей фильтруются по пользователю и времени и листаются курсором (время, id)
+CREATE INDEX IF NOT EXISTS "idx_glucose_records_user_measured_at" ON "glucose_records" ("user_id","measured_at","id");
+CREATE INDEX IF NOT EXISTS "idx_food_records_user_consumed_at" ON "food_records" ("user_id","consumed_at","id");
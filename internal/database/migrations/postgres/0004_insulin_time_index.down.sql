@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "idx_insulin_records_user_injected_at";
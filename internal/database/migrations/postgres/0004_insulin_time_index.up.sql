@@ -0,0 +1,2 @@
+-- Выгрузка дневника листает инсулин курсором (время, id), как списки глюкозы и еды
+CREATE INDEX IF NOT EXISTS "idx_insulin_records_user_injected_at" ON "insulin_records" ("user_id","injected_at","id");
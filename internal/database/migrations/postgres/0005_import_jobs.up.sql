@@ -0,0 +1,25 @@
+-- Загрузки файлов с измерениями глюкозы (LibreView, Dexcom Clarity, CSV глюкометров)
+CREATE TABLE IF NOT EXISTS "import_jobs" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "source" varchar(20) NOT NULL,
+    "format" varchar(20) NOT NULL,
+    "unit" varchar(10),
+    "file_name" varchar(255),
+    "file_id" varchar(255),
+    "status" varchar(20) NOT NULL,
+    "total" bigint,
+    "imported" bigint,
+    "duplicates" bigint,
+    "skipped" bigint,
+    "first_at" timestamptz,
+    "last_at" timestamptz,
+    "completed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_import_jobs_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_import_jobs_deleted_at" ON "import_jobs" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_import_jobs_user_id" ON "import_jobs" ("user_id");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_users_nightscout_secret_hash";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "nightscout_secret_hash";
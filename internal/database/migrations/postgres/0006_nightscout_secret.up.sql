@@ -0,0 +1,3 @@
+-- SHA1 API secret для клиентов Nightscout (xDrip+, AAPS); сам секрет не хранится
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "nightscout_secret_hash" varchar(40);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_nightscout_secret_hash" ON "users" ("nightscout_secret_hash");
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_glucose_records_source_measured_at";
+ALTER TABLE "glucose_records" DROP COLUMN IF EXISTS "device_id";
+ALTER TABLE "glucose_records" DROP COLUMN IF EXISTS "source";
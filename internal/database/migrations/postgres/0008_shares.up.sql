@@ -0,0 +1,20 @@
+-- Доступ родственников и врачей к дневнику: приглашения и выданные права
+CREATE TABLE IF NOT EXISTS "shares" (
+    "id" bigserial,
+    "owner_id" bigint NOT NULL,
+    "grantee_id" bigint,
+    "role" varchar(10) NOT NULL,
+    "token_hash" varchar(64),
+    "expires_at" timestamptz,
+    "accepted_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_shares_owner" FOREIGN KEY ("owner_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_shares_grantee" FOREIGN KEY ("grantee_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_shares_deleted_at" ON "shares" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_shares_owner_id" ON "shares" ("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_shares_grantee_id" ON "shares" ("grantee_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_shares_token_hash" ON "shares" ("token_hash");
@@ -0,0 +1,43 @@
+-- Тревоги родственникам по критическим значениям глюкозы
+CREATE TABLE IF NOT EXISTS "alert_rules" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "enabled" boolean NOT NULL DEFAULT true,
+    "low_threshold" decimal NOT NULL,
+    "high_threshold" decimal NOT NULL,
+    "recheck_minutes" bigint NOT NULL,
+    "quiet_start" varchar(5),
+    "quiet_end" varchar(5),
+    "timezone" varchar(64),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_alert_rules_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_alert_rules_user_id" ON "alert_rules" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_alert_rules_deleted_at" ON "alert_rules" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "alerts" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "record_id" bigint,
+    "kind" varchar(10) NOT NULL,
+    "value" decimal,
+    "measured_at" timestamptz,
+    "status" varchar(20) NOT NULL,
+    "recheck_by" timestamptz,
+    "acknowledged_at" timestamptz,
+    "acknowledged_by" bigint,
+    "escalated_at" timestamptz,
+    "resolved_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_alerts_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_alerts_user_id" ON "alerts" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_alerts_status" ON "alerts" ("status");
+CREATE INDEX IF NOT EXISTS "idx_alerts_recheck_by" ON "alerts" ("recheck_by");
+CREATE INDEX IF NOT EXISTS "idx_alerts_deleted_at" ON "alerts" ("deleted_at");
@@ -0,0 +1,31 @@
+-- Публичные ссылки на сводку дневника для врача без Telegram и журнал их открытий
+CREATE TABLE IF NOT EXISTS "public_links" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token_hash" varchar(64),
+    "label" varchar(100),
+    "period_from" timestamptz,
+    "period_to" timestamptz,
+    "expires_at" timestamptz,
+    "access_count" bigint NOT NULL DEFAULT 0,
+    "last_accessed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_public_links_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_public_links_user_id" ON "public_links" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_public_links_deleted_at" ON "public_links" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_public_links_token_hash" ON "public_links" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "public_link_accesses" (
+    "id" bigserial,
+    "link_id" bigint NOT NULL,
+    "ip" varchar(45),
+    "user_agent" varchar(255),
+    "accessed_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_public_link_accesses_link" FOREIGN KEY ("link_id") REFERENCES "public_links"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_public_link_accesses_link_id" ON "public_link_accesses" ("link_id");
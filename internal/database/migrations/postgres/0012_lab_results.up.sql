@@ -0,0 +1,19 @@
+-- Результаты лабораторных анализов: HbA1c, холестерин, креатинин и другие
+CREATE TABLE IF NOT EXISTS "lab_results" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "kind" varchar(20) NOT NULL,
+    "name" varchar(100),
+    "value" decimal NOT NULL,
+    "unit" varchar(20) NOT NULL,
+    "taken_at" timestamptz NOT NULL,
+    "notes" varchar(500),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_lab_results_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_lab_results_user_taken_at" ON "lab_results" ("user_id","taken_at","id");
+CREATE INDEX IF NOT EXISTS "idx_lab_results_user_id" ON "lab_results" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_lab_results_deleted_at" ON "lab_results" ("deleted_at");
@@ -0,0 +1,19 @@
+-- Показатели здоровья помимо глюкозы: вес, артериальное давление, кетоны
+CREATE TABLE IF NOT EXISTS "vitals" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "kind" varchar(20) NOT NULL,
+    "value" decimal NOT NULL,
+    "diastolic" decimal,
+    "unit" varchar(10) NOT NULL,
+    "measured_at" timestamptz NOT NULL,
+    "notes" varchar(500),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_vitals_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_vitals_user_measured_at" ON "vitals" ("user_id","measured_at","id");
+CREATE INDEX IF NOT EXISTS "idx_vitals_user_id" ON "vitals" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_vitals_deleted_at" ON "vitals" ("deleted_at");
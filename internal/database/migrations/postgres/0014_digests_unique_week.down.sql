@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "idx_digests_user_period";
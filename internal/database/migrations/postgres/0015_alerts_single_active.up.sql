@@ -0,0 +1,11 @@
+-- Одна незакрытая тревога на пользователя: реплики, одновременно получившие
+-- критические измерения, не поднимут каждая свою тревогу и не разбудят родственников дважды.
+-- Прежние дубли закрываются, остается последняя тревога
+UPDATE "alerts" SET "status" = 'resolved', "resolved_at" = CURRENT_TIMESTAMP
+WHERE "deleted_at" IS NULL AND "status" IN ('open', 'acknowledged', 'escalated', 'muted') AND "id" NOT IN (
+    SELECT MAX("id") FROM "alerts"
+    WHERE "deleted_at" IS NULL AND "status" IN ('open', 'acknowledged', 'escalated', 'muted')
+    GROUP BY "user_id"
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_alerts_user_active" ON "alerts" ("user_id")
+WHERE "status" IN ('open', 'acknowledged', 'escalated', 'muted') AND "deleted_at" IS NULL;
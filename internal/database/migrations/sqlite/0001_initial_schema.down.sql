@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "ai_usages";
+DROP TABLE IF EXISTS "ai_recommendations";
+DROP TABLE IF EXISTS "insulin_records";
+DROP TABLE IF EXISTS "food_records";
+DROP TABLE IF EXISTS "glucose_records";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,90 @@
+-- Та же схема, что и postgres/0001_initial_schema.up.sql, в синтаксисе SQLite
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "telegram_id" integer NOT NULL,
+    "username" varchar(255),
+    "first_name" varchar(255),
+    "last_name" varchar(255),
+    "language_code" varchar(10) DEFAULT 'ru',
+    "is_active" boolean DEFAULT true,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "diabetes_type" integer,
+    "target_glucose" real,
+    "notifications" boolean DEFAULT true,
+    CONSTRAINT "chk_users_diabetes_type" CHECK (diabetes_type IN (1,2))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_telegram_id" ON "users" ("telegram_id");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "glucose_records" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "value" real NOT NULL,
+    "measured_at" datetime NOT NULL,
+    "measurement_context" varchar(32),
+    "notes" varchar(500),
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_users_glucose_records" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_glucose_records_deleted_at" ON "glucose_records" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "food_records" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "food_name" varchar(255) NOT NULL,
+    "food_type" varchar(100),
+    "carbs" real,
+    "calories" integer,
+    "quantity" varchar(100),
+    "consumed_at" datetime NOT NULL,
+    "notes" varchar(500),
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_users_food_records" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_food_records_deleted_at" ON "food_records" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "insulin_records" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "units" real NOT NULL,
+    "insulin_type" varchar(20),
+    "insulin_name" varchar(100),
+    "injected_at" datetime NOT NULL,
+    "notes" varchar(500),
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_insulin_records_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_insulin_records_deleted_at" ON "insulin_records" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_insulin_records_user_id" ON "insulin_records" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "ai_recommendations" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "type" varchar(50) NOT NULL,
+    "content" text NOT NULL,
+    "context" json,
+    "created_at" datetime,
+    CONSTRAINT "fk_ai_recommendations_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "ai_usages" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer NOT NULL,
+    "date" date NOT NULL,
+    "request_count" integer DEFAULT 0,
+    CONSTRAINT "fk_ai_usages_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_date" ON "ai_usages" ("user_id","date");
+CREATE INDEX IF NOT EXISTS "idx_ai_usages_deleted_at" ON "ai_usages" ("deleted_at");
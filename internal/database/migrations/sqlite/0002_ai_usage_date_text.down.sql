@@ -0,0 +1,16 @@
+CREATE TABLE "ai_usages_old" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer NOT NULL,
+    "date" date NOT NULL,
+    "request_count" integer DEFAULT 0,
+    CONSTRAINT "fk_ai_usages_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+INSERT INTO "ai_usages_old" ("id", "created_at", "updated_at", "deleted_at", "user_id", "date", "request_count")
+    SELECT "id", "created_at", "updated_at", "deleted_at", "user_id", "date", "request_count" FROM "ai_usages";
+DROP TABLE "ai_usages";
+ALTER TABLE "ai_usages_old" RENAME TO "ai_usages";
+CREATE UNIQUE INDEX "idx_user_date" ON "ai_usages" ("user_id","date");
+CREATE INDEX "idx_ai_usages_deleted_at" ON "ai_usages" ("deleted_at");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_food_records_user_consumed_at";
+DROP INDEX IF EXISTS "idx_glucose_records_user_measured_at";
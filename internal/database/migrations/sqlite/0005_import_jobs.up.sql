@@ -0,0 +1,24 @@
+-- Загрузки файлов с измерениями глюкозы (LibreView, Dexcom Clarity, CSV глюкометров)
+CREATE TABLE IF NOT EXISTS "import_jobs" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "source" varchar(20) NOT NULL,
+    "format" varchar(20) NOT NULL,
+    "unit" varchar(10),
+    "file_name" varchar(255),
+    "file_id" varchar(255),
+    "status" varchar(20) NOT NULL,
+    "total" integer,
+    "imported" integer,
+    "duplicates" integer,
+    "skipped" integer,
+    "first_at" datetime,
+    "last_at" datetime,
+    "completed_at" datetime,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_import_jobs_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_import_jobs_deleted_at" ON "import_jobs" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_import_jobs_user_id" ON "import_jobs" ("user_id");
@@ -0,0 +1,5 @@
+-- Источник измерения: глюкометр или CGM. Прежние записи считаются измерениями глюкометра.
+ALTER TABLE "glucose_records" ADD COLUMN "source" varchar(20) NOT NULL DEFAULT 'fingerstick';
+ALTER TABLE "glucose_records" ADD COLUMN "device_id" varchar(100);
+-- Очистка старых показаний CGM выбирает их по источнику и времени у всех пользователей
+CREATE INDEX IF NOT EXISTS "idx_glucose_records_source_measured_at" ON "glucose_records" ("source","measured_at");
@@ -0,0 +1,19 @@
+-- Доступ родственников и врачей к дневнику: приглашения и выданные права
+CREATE TABLE IF NOT EXISTS "shares" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "owner_id" integer NOT NULL,
+    "grantee_id" integer,
+    "role" varchar(10) NOT NULL,
+    "token_hash" varchar(64),
+    "expires_at" datetime,
+    "accepted_at" datetime,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_shares_owner" FOREIGN KEY ("owner_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_shares_grantee" FOREIGN KEY ("grantee_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_shares_deleted_at" ON "shares" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_shares_owner_id" ON "shares" ("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_shares_grantee_id" ON "shares" ("grantee_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_shares_token_hash" ON "shares" ("token_hash");
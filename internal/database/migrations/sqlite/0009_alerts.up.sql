@@ -0,0 +1,41 @@
+-- Тревоги родственникам по критическим значениям глюкозы
+CREATE TABLE IF NOT EXISTS "alert_rules" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "enabled" boolean NOT NULL DEFAULT true,
+    "low_threshold" real NOT NULL,
+    "high_threshold" real NOT NULL,
+    "recheck_minutes" integer NOT NULL,
+    "quiet_start" varchar(5),
+    "quiet_end" varchar(5),
+    "timezone" varchar(64),
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_alert_rules_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_alert_rules_user_id" ON "alert_rules" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_alert_rules_deleted_at" ON "alert_rules" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "alerts" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "record_id" integer,
+    "kind" varchar(10) NOT NULL,
+    "value" real,
+    "measured_at" datetime,
+    "status" varchar(20) NOT NULL,
+    "recheck_by" datetime,
+    "acknowledged_at" datetime,
+    "acknowledged_by" integer,
+    "escalated_at" datetime,
+    "resolved_at" datetime,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_alerts_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_alerts_user_id" ON "alerts" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_alerts_status" ON "alerts" ("status");
+CREATE INDEX IF NOT EXISTS "idx_alerts_recheck_by" ON "alerts" ("recheck_by");
+CREATE INDEX IF NOT EXISTS "idx_alerts_deleted_at" ON "alerts" ("deleted_at");
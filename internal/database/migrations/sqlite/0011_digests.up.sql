@@ -0,0 +1,30 @@
+-- Еженедельные сводки с комментарием ИИ и подписки на них
+CREATE TABLE IF NOT EXISTS "digest_subscriptions" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "enabled" boolean NOT NULL DEFAULT false,
+    "timezone" varchar(64),
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_digest_subscriptions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_digest_subscriptions_user_id" ON "digest_subscriptions" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_digest_subscriptions_deleted_at" ON "digest_subscriptions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "digests" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "period_from" datetime,
+    "period_to" datetime,
+    "summary" text,
+    "commentary" text,
+    "generated_at" datetime,
+    "sent_at" datetime,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_digests_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_digests_user_id" ON "digests" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_digests_deleted_at" ON "digests" ("deleted_at");
@@ -0,0 +1,18 @@
+-- Результаты лабораторных анализов: HbA1c, холестерин, креатинин и другие
+CREATE TABLE IF NOT EXISTS "lab_results" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "kind" varchar(20) NOT NULL,
+    "name" varchar(100),
+    "value" decimal NOT NULL,
+    "unit" varchar(20) NOT NULL,
+    "taken_at" datetime NOT NULL,
+    "notes" varchar(500),
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_lab_results_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_lab_results_user_taken_at" ON "lab_results" ("user_id","taken_at","id");
+CREATE INDEX IF NOT EXISTS "idx_lab_results_user_id" ON "lab_results" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_lab_results_deleted_at" ON "lab_results" ("deleted_at");
@@ -0,0 +1,7 @@
+-- Одна неудаленная сводка на неделю: реплики не создадут каждая свою копию.
+-- Прежние дубли мягко удаляются, остается последняя сводка за неделю
+UPDATE "digests" SET "deleted_at" = CURRENT_TIMESTAMP
+WHERE "deleted_at" IS NULL AND "id" NOT IN (
+    SELECT MAX("id") FROM "digests" WHERE "deleted_at" IS NULL GROUP BY "user_id", "period_from"
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_digests_user_period" ON "digests" ("user_id","period_from") WHERE "deleted_at" IS NULL;
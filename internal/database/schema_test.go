@@ -0,0 +1,267 @@
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Модели в том виде, в каком их создавал AutoMigrate до перехода на миграции.
+// 0001_initial_schema должна воспроизводить именно эту схему, поэтому модели
+// заморожены здесь, а не берутся из internal/models.
+
+type baselineUser struct {
+	ID            uint   `gorm:"primarykey"`
+	TelegramID    int64  `gorm:"uniqueIndex;not null"`
+	Username      string `gorm:"size:255"`
+	FirstName     string `gorm:"size:255"`
+	LastName      string `gorm:"size:255"`
+	LanguageCode  string `gorm:"size:10;default:'ru'"`
+	IsActive      bool   `gorm:"default:true"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+	DiabetesType  *int           `gorm:"check:diabetes_type IN (1,2)"`
+	TargetGlucose *float64
+	Notifications *bool `gorm:"default:true"`
+
+	GlucoseRecords []baselineGlucoseRecord `gorm:"foreignKey:UserID"`
+	FoodRecords    []baselineFoodRecord    `gorm:"foreignKey:UserID"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineGlucoseRecord struct {
+	ID                 uint      `gorm:"primarykey"`
+	UserID             uint      `gorm:"not null"`
+	Value              float64   `gorm:"not null"`
+	MeasuredAt         time.Time `gorm:"not null"`
+	MeasurementContext string    `gorm:"size:32"`
+	Notes              string    `gorm:"size:500"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	DeletedAt          gorm.DeletedAt `gorm:"index"`
+
+	User baselineUser `gorm:"foreignKey:UserID"`
+}
+
+func (baselineGlucoseRecord) TableName() string { return "glucose_records" }
+
+type baselineFoodRecord struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     uint   `gorm:"not null"`
+	FoodName   string `gorm:"size:255;not null"`
+	FoodType   string `gorm:"size:100"`
+	Carbs      *float64
+	Calories   *int
+	Quantity   string    `gorm:"size:100"`
+	ConsumedAt time.Time `gorm:"not null"`
+	Notes      string    `gorm:"size:500"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+
+	User baselineUser `gorm:"foreignKey:UserID"`
+}
+
+func (baselineFoodRecord) TableName() string { return "food_records" }
+
+type baselineInsulinRecord struct {
+	ID          uint      `gorm:"primarykey"`
+	UserID      uint      `gorm:"not null;index"`
+	Units       float64   `gorm:"not null"`
+	InsulinType string    `gorm:"size:20"`
+	InsulinName string    `gorm:"size:100"`
+	InjectedAt  time.Time `gorm:"not null"`
+	Notes       string    `gorm:"size:500"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+
+	User baselineUser `gorm:"foreignKey:UserID"`
+}
+
+func (baselineInsulinRecord) TableName() string { return "insulin_records" }
+
+type baselineAIRecommendation struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null"`
+	Type      string `gorm:"size:50;not null"`
+	Content   string `gorm:"type:text;not null"`
+	Context   string `gorm:"type:json"`
+	CreatedAt time.Time
+
+	User baselineUser `gorm:"foreignKey:UserID"`
+}
+
+func (baselineAIRecommendation) TableName() string { return "ai_recommendations" }
+
+type baselineAIUsage struct {
+	gorm.Model
+	UserID       uint         `gorm:"not null;index:idx_user_date,unique:true"`
+	Date         time.Time    `gorm:"type:date;not null;index:idx_user_date,unique:true"`
+	RequestCount int          `gorm:"default:0"`
+	User         baselineUser `gorm:"foreignKey:UserID"`
+}
+
+func (baselineAIUsage) TableName() string { return "ai_usages" }
+
+var baselineModels = []interface{}{
+	&baselineUser{}, &baselineGlucoseRecord{}, &baselineFoodRecord{},
+	&baselineInsulinRecord{}, &baselineAIRecommendation{}, &baselineAIUsage{},
+}
+
+// 0001_initial_schema дает ту же схему, что AutoMigrate прежних моделей:
+// колонки, их типы, обязательность и значения по умолчанию, индексы и внешние ключи.
+// PostgreSQL проверяется при TEST_DB_DRIVER=postgres и TEST_DB_DSN.
+func TestInitialMigration_MatchesAutoMigrate(t *testing.T) {
+	dialects := []string{DriverSQLite}
+	if os.Getenv("TEST_DB_DRIVER") == DriverPostgres {
+		dialects = append(dialects, DriverPostgres)
+	}
+
+	for _, dialect := range dialects {
+		t.Run(dialect, func(t *testing.T) {
+			auto := openSchemaDB(t, dialect)
+			require.NoError(t, auto.AutoMigrate(baselineModels...))
+
+			migrated := openSchemaDB(t, dialect)
+			migrator, err := NewMigratorFS(migrated, initialMigrationFS(t, dialect))
+			require.NoError(t, err)
+			_, err = migrator.Up()
+			require.NoError(t, err)
+
+			for _, model := range baselineModels {
+				table := auto.Model(model).Statement
+				require.NoError(t, table.Parse(model))
+				name := table.Schema.Table
+				t.Run(name, func(t *testing.T) {
+					assert.Equal(t, describeColumns(t, auto, name), describeColumns(t, migrated, name), "columns")
+					assert.Equal(t, describeIndexes(t, auto, name), describeIndexes(t, migrated, name), "indexes")
+					for _, constraint := range []string{"fk_users_glucose_records", "fk_users_food_records", "fk_insulin_records_user",
+						"fk_ai_recommendations_user", "fk_ai_usages_user", "chk_users_diabetes_type"} {
+						assert.Equal(t, auto.Migrator().HasConstraint(name, constraint), migrated.Migrator().HasConstraint(name, constraint), constraint)
+					}
+				})
+			}
+		})
+	}
+}
+
+// initialMigrationFS оставляет из встроенных миграций только 0001
+func initialMigrationFS(t *testing.T, dialect string) fstest.MapFS {
+	fsys, err := MigrationsFS(dialect)
+	require.NoError(t, err)
+	initial := fstest.MapFS{}
+	for _, name := range []string{"0001_initial_schema.up.sql", "0001_initial_schema.down.sql"} {
+		data, err := fs.ReadFile(fsys, name)
+		require.NoError(t, err)
+		initial[name] = &fstest.MapFile{Data: data}
+	}
+	return initial
+}
+
+func openSchemaDB(t *testing.T, dialect string) *gorm.DB {
+	if dialect == DriverSQLite {
+		return setupMigrationDB(t)
+	}
+
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is required for TEST_DB_DRIVER=postgres")
+	}
+	admin, err := Open(postgres.Open(dsn), logger.Silent)
+	require.NoError(t, err)
+	schema := fmt.Sprintf("schema_%d_%d", os.Getpid(), time.Now().UnixNano())
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schema).Error)
+
+	db, err := Open(postgres.Open(dsn+" search_path="+schema), logger.Silent)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// describeColumns описывает колонки таблицы строками «имя тип null default pk», по порядку.
+// В SQLite объявленный тип значит только его класс (affinity), поэтому сравнивается класс:
+// varchar из миграции и text из AutoMigrate хранятся одинаково.
+func describeColumns(t *testing.T, db *gorm.DB, table string) []string {
+	columns, err := db.Migrator().ColumnTypes(table)
+	require.NoError(t, err)
+	var described []string
+	for _, column := range columns {
+		nullable, _ := column.Nullable()
+		def, _ := column.DefaultValue()
+		pk, _ := column.PrimaryKey()
+		typ := strings.ToLower(column.DatabaseTypeName())
+		if db.Dialector.Name() == DriverSQLite {
+			typ, def = sqliteAffinity(typ), strings.Trim(def, `'"`)
+		}
+		described = append(described, fmt.Sprintf("%s %s null=%t default=%s pk=%t", column.Name(), typ, nullable, def, pk))
+	}
+	return described
+}
+
+// sqliteAffinity возвращает класс типа по правилам SQLite (раздел 3.1 «Datatypes In SQLite»)
+func sqliteAffinity(typ string) string {
+	switch {
+	case strings.Contains(typ, "int"):
+		return "integer"
+	case strings.Contains(typ, "char"), strings.Contains(typ, "clob"), strings.Contains(typ, "text"):
+		return "text"
+	case typ == "", strings.Contains(typ, "blob"):
+		return "blob"
+	case strings.Contains(typ, "real"), strings.Contains(typ, "floa"), strings.Contains(typ, "doub"):
+		return "real"
+	}
+	return "numeric"
+}
+
+// describeIndexes описывает индексы таблицы строками «имя (колонки) unique».
+// Драйвер SQLite не умеет GetIndexes, для него индексы читаются через PRAGMA.
+func describeIndexes(t *testing.T, db *gorm.DB, table string) []string {
+	var described []string
+	if db.Dialector.Name() == DriverSQLite {
+		var indexes []struct {
+			Name   string
+			Unique bool
+			Origin string
+		}
+		require.NoError(t, db.Raw("SELECT name, \"unique\", origin FROM pragma_index_list(?)", table).Scan(&indexes).Error)
+		for _, index := range indexes {
+			if index.Origin == "pk" {
+				continue
+			}
+			var columns []string
+			require.NoError(t, db.Raw("SELECT name FROM pragma_index_info(?) ORDER BY seqno", index.Name).Scan(&columns).Error)
+			described = append(described, fmt.Sprintf("%s (%s) unique=%t", index.Name, strings.Join(columns, ","), index.Unique))
+		}
+	} else {
+		indexes, err := db.Migrator().GetIndexes(table)
+		require.NoError(t, err)
+		for _, index := range indexes {
+			unique, _ := index.Unique()
+			described = append(described, fmt.Sprintf("%s (%s) unique=%t", index.Name(), strings.Join(index.Columns(), ","), unique))
+		}
+	}
+	sort.Strings(described)
+	return described
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteDriverName — драйвер SQLite, приводящий параметры-время к UTC
+const sqliteDriverName = "sqlite3_utc"
+
+func init() {
+	sql.Register(sqliteDriverName, &utcSQLiteDriver{})
+}
+
+// SQLiteDialector возвращает драйвер GORM для файла SQLite
+func SQLiteDialector(path string) gorm.Dialector {
+	return &sqlite.Dialector{DriverName: sqliteDriverName, DSN: SQLiteDSN(path)}
+}
+
+// SQLiteDSN добавляет к пути файла параметры подключения SQLite:
+// внешние ключи, ожидание блокировки, WAL и чтение времени в локальном поясе
+func SQLiteDSN(path string) string {
+	return path + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_loc=auto"
+}
+
+// SQLite хранит время строкой со смещением и сравнивает строки лексикографически,
+// поэтому значения в разных поясах сравниваются неверно. Драйвер переводит
+// все параметры-время в UTC, как это фактически делает PostgreSQL для timestamptz.
+type utcSQLiteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *utcSQLiteDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &utcSQLiteConn{conn: conn}, nil
+}
+
+// utcSQLiteConn передает вызовы соединению go-sqlite3. ExecContext нужен
+// напрямую: только он выполняет несколько выражений за раз, как в миграциях.
+type utcSQLiteConn struct {
+	conn driver.Conn
+}
+
+func (c *utcSQLiteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(query)
+}
+
+func (c *utcSQLiteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+func (c *utcSQLiteConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *utcSQLiteConn) Begin() (driver.Tx, error) {
+	//lint:ignore SA1019 метод обязателен для driver.Conn
+	return c.conn.Begin()
+}
+
+func (c *utcSQLiteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *utcSQLiteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *utcSQLiteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *utcSQLiteConn) Ping(ctx context.Context) error {
+	return c.conn.(driver.Pinger).Ping(ctx)
+}
+
+// CheckNamedValue приводит time.Time (в том числе за driver.Valuer) к UTC
+func (c *utcSQLiteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value := nv.Value
+	if valuer, ok := value.(driver.Valuer); ok {
+		var err error
+		if value, err = valuer.Value(); err != nil {
+			return err
+		}
+	}
+
+	if t, ok := value.(time.Time); ok {
+		nv.Value = t.UTC()
+		return nil
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type timedRecord struct {
+	ID        uint
+	At        time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+// Время с разными смещениями сравнивается по моменту, а не как строки
+func TestSQLiteDialector_ComparesTimesInUTC(t *testing.T) {
+	db, err := Open(SQLiteDialector(":memory:"), logger.Silent)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	defer sqlDB.Close()
+
+	require.NoError(t, db.AutoMigrate(&timedRecord{}))
+
+	east := time.FixedZone("UTC+10", 10*60*60)
+	west := time.FixedZone("UTC-8", -8*60*60)
+	moment := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	// 21:00 по UTC+10 — это на час раньше moment, хотя строка «больше»
+	require.NoError(t, db.Create(&timedRecord{At: moment.Add(-time.Hour).In(east)}).Error)
+	require.NoError(t, db.Create(&timedRecord{At: moment.Add(time.Hour).In(west)}).Error)
+
+	var later []timedRecord
+	require.NoError(t, db.Where("at >= ?", moment.In(east)).Find(&later).Error)
+	require.Len(t, later, 1)
+	assert.True(t, later[0].At.Equal(moment.Add(time.Hour)))
+
+	// gorm.DeletedAt передается через driver.Valuer
+	require.NoError(t, db.Delete(&timedRecord{}, later[0].ID).Error)
+	var deleted int64
+	require.NoError(t, db.Unscoped().Model(&timedRecord{}).
+		Where("deleted_at >= ?", gorm.DeletedAt{Time: time.Now().Add(-time.Minute).In(west), Valid: true}).
+		Count(&deleted).Error)
+	assert.Equal(t, int64(1), deleted)
+}
@@ -0,0 +1,233 @@
+package fhir
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"diabetbot/internal/importer"
+	"diabetbot/internal/models"
+)
+
+// ErrNotBundle — документ не является JSON ресурсом Bundle
+var ErrNotBundle = errors.New("fhir: document is not a Bundle")
+
+// Допустимые значения глюкозы, ммоль/л, как при импорте CSV
+const (
+	minGlucose = 1.0
+	maxGlucose = 40.0
+)
+
+// BundleWriter пишет Bundle типа collection потоком: ресурсы добавляются по одному,
+// поэтому память не зависит от размера дневника. Bundle должен содержать хотя бы один
+// ресурс: FHIR запрещает пустые массивы.
+type BundleWriter struct {
+	w       *bufio.Writer
+	entries int
+}
+
+// NewBundleWriter начинает Bundle с отметкой времени timestamp
+func NewBundleWriter(w io.Writer, timestamp time.Time) (*BundleWriter, error) {
+	header, err := json.Marshal(struct {
+		ResourceType string `json:"resourceType"`
+		Type         string `json:"type"`
+		Timestamp    string `json:"timestamp"`
+	}{"Bundle", "collection", timestamp.UTC().Format(time.RFC3339)})
+	if err != nil {
+		return nil, err
+	}
+
+	bw := bufio.NewWriter(w)
+	// Дописываем массив entry в объект заголовка
+	bw.Write(header[:len(header)-1])
+	if _, err := bw.WriteString(`,"entry":[`); err != nil {
+		return nil, err
+	}
+	return &BundleWriter{w: bw}, nil
+}
+
+// Add добавляет ресурс с ID id; fullUrl — URN(id)
+func (b *BundleWriter) Add(id string, resource interface{}) error {
+	data, err := json.Marshal(struct {
+		FullURL  string      `json:"fullUrl"`
+		Resource interface{} `json:"resource"`
+	}{URN(id), resource})
+	if err != nil {
+		return err
+	}
+	if b.entries > 0 {
+		b.w.WriteByte(',')
+	}
+	b.entries++
+	_, err = b.w.Write(data)
+	return err
+}
+
+// Close завершает Bundle
+func (b *BundleWriter) Close() error {
+	b.w.WriteString("]}\n")
+	return b.w.Flush()
+}
+
+// Records — записи дневника из Bundle
+type Records struct {
+	Glucose []models.GlucoseRecord // UserID не заполнен
+	Food    []models.FoodRecord
+	Total   int // Observation в Bundle
+	Skipped int // Observation с неизвестным кодом, отмененные, без времени или со значением вне диапазона
+}
+
+// Decode читает Bundle и извлекает из Observation измерения глюкозы (LOINC 15074-8,
+// 2339-0, 14743-9, 41653-7, 14749-6, 2345-7) и углеводы (9059-7). Глюкоза в мг/дл
+// переводится в ммоль/л. Остальные ресурсы, в том числе Patient, пропускаются:
+// записи сохраняются в дневник того, кто загрузил файл.
+func Decode(r io.Reader) (*Records, error) {
+	var bundle struct {
+		ResourceType string `json:"resourceType"`
+		Entry        []struct {
+			Resource json.RawMessage `json:"resource"`
+		} `json:"entry"`
+	}
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: %v", ErrNotBundle, err)
+		}
+		// Ошибки чтения, например превышение размера тела запроса, отдаются как есть
+		return nil, err
+	}
+	if bundle.ResourceType != "Bundle" {
+		return nil, ErrNotBundle
+	}
+
+	records := &Records{}
+	for _, entry := range bundle.Entry {
+		var obs Observation
+		if len(entry.Resource) == 0 || json.Unmarshal(entry.Resource, &obs) != nil || obs.ResourceType != "Observation" {
+			continue
+		}
+		records.Total++
+		if !records.add(&obs) {
+			records.Skipped++
+		}
+	}
+	return records, nil
+}
+
+// add переводит Observation в запись дневника; false — Observation пропущен
+func (r *Records) add(obs *Observation) bool {
+	if obs.Status == StatusEnteredInError || obs.Status == StatusCancelled {
+		return false
+	}
+	at, ok := effectiveTime(obs)
+	if !ok {
+		return false
+	}
+
+	code := loincCode(obs.Code)
+	if code == LOINCCarbIntake {
+		food := models.FoodRecord{FoodName: "Углеводы", ConsumedAt: at}
+		if note := noteText(obs.Note); note != "" {
+			food.FoodName = truncate(note, 255)
+		}
+		if q := obs.ValueQuantity; q != nil && q.Value != nil {
+			if *q.Value < 0 || (q.Code != "" && q.Code != UnitGram) {
+				return false
+			}
+			carbs := math.Round(*q.Value*10) / 10
+			food.Carbs = &carbs
+		}
+		r.Food = append(r.Food, food)
+		return true
+	}
+
+	unit, ok := glucoseCodes[code]
+	if !ok || obs.ValueQuantity == nil || obs.ValueQuantity.Value == nil {
+		return false
+	}
+	if q := obs.ValueQuantity; q.Code != "" {
+		unit = q.Code
+	} else if q.Unit != "" {
+		unit = q.Unit
+	}
+	value := *obs.ValueQuantity.Value
+	switch {
+	case strings.EqualFold(unit, UnitMmolL):
+	case strings.EqualFold(unit, UnitMgDL):
+		value = math.Round(value/importer.MgDLPerMmol*10) / 10
+	default:
+		return false
+	}
+	if value < minGlucose || value > maxGlucose {
+		return false
+	}
+
+	record := models.GlucoseRecord{
+		Value:      value,
+		MeasuredAt: at,
+		Source:     models.GlucoseSourceFingerstick,
+		Notes:      truncate(noteText(obs.Note), 500),
+	}
+	if obs.Method != nil {
+		for _, coding := range obs.Method.Coding {
+			if coding.System == SystemGlucoseSource && models.IsGlucoseSource(coding.Code) {
+				record.Source = coding.Code
+			}
+		}
+	}
+	if obs.Device != nil {
+		record.DeviceID = truncate(obs.Device.Display, 100)
+	}
+	r.Glucose = append(r.Glucose, record)
+	return true
+}
+
+func loincCode(concept CodeableConcept) string {
+	for _, coding := range concept.Coding {
+		if coding.System == SystemLOINC {
+			return coding.Code
+		}
+	}
+	return ""
+}
+
+// effectiveTime берет время измерения из effective[x], а без него — время выпуска результата.
+// Дата без времени не подходит: по ней нельзя отличить измерения одного дня.
+func effectiveTime(obs *Observation) (time.Time, bool) {
+	value := obs.EffectiveDateTime
+	if value == "" {
+		value = obs.EffectiveInstant
+	}
+	if value == "" && obs.EffectivePeriod != nil {
+		value = obs.EffectivePeriod.Start
+	}
+	if value == "" {
+		value = obs.Issued
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	return at, err == nil
+}
+
+func noteText(notes []Annotation) string {
+	texts := make([]string, 0, len(notes))
+	for _, note := range notes {
+		if text := strings.TrimSpace(note.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "; ")
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
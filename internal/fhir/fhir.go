@@ -0,0 +1,231 @@
+// Package fhir переводит дневник в ресурсы FHIR R4 и обратно: глюкоза и углеводы —
+// Observation с кодами LOINC, пользователь — Patient. Описаны только элементы,
+// которые нужны для обмена с медицинскими информационными системами.
+package fhir
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strconv"
+	"time"
+
+	"diabetbot/internal/models"
+)
+
+// Системы кодирования
+const (
+	SystemLOINC               = "http://loinc.org"
+	SystemUCUM                = "http://unitsofmeasure.org"
+	SystemObservationCategory = "http://terminology.hl7.org/CodeSystem/observation-category"
+	SystemDataAbsentReason    = "http://terminology.hl7.org/CodeSystem/data-absent-reason"
+	SystemLanguage            = "urn:ietf:bcp:47"
+	// SystemTelegramID — идентификатор пациента в боте
+	SystemTelegramID = "urn:diabetbot:telegram-id"
+	// SystemGlucoseSource — источник измерения: models.GlucoseSource*
+	SystemGlucoseSource = "urn:diabetbot:glucose-source"
+)
+
+// Коды LOINC
+const (
+	LOINCGlucoseMolesBlood     = "15074-8" // Glucose [Moles/volume] in Blood
+	LOINCGlucoseMassBlood      = "2339-0"  // Glucose [Mass/volume] in Blood
+	LOINCGlucoseMolesCapillary = "14743-9" // Glucose [Moles/volume] in Capillary blood by Glucometer
+	LOINCGlucoseMassCapillary  = "41653-7" // Glucose [Mass/volume] in Capillary blood by Glucometer
+	LOINCGlucoseMolesSerum     = "14749-6" // Glucose [Moles/volume] in Serum or Plasma
+	LOINCGlucoseMassSerum      = "2345-7"  // Glucose [Mass/volume] in Serum or Plasma
+	LOINCCarbIntake            = "9059-7"  // Carbohydrate intake Estimated
+)
+
+// Единицы UCUM
+const (
+	UnitMmolL = "mmol/L"
+	UnitMgDL  = "mg/dL"
+	UnitGram  = "g"
+)
+
+// Статусы Observation
+const (
+	StatusFinal          = "final"
+	StatusEnteredInError = "entered-in-error"
+	StatusCancelled      = "cancelled"
+)
+
+// glucoseCodes — коды глюкозы, которые принимает импорт, и их единицы по умолчанию
+var glucoseCodes = map[string]string{
+	LOINCGlucoseMolesBlood:     UnitMmolL,
+	LOINCGlucoseMassBlood:      UnitMgDL,
+	LOINCGlucoseMolesCapillary: UnitMmolL,
+	LOINCGlucoseMassCapillary:  UnitMgDL,
+	LOINCGlucoseMolesSerum:     UnitMmolL,
+	LOINCGlucoseMassSerum:      UnitMgDL,
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+type Quantity struct {
+	Value  *float64 `json:"value,omitempty"`
+	Unit   string   `json:"unit,omitempty"`
+	System string   `json:"system,omitempty"`
+	Code   string   `json:"code,omitempty"`
+}
+
+type Reference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type Identifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+type HumanName struct {
+	Use    string   `json:"use,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+type Period struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type Annotation struct {
+	Text string `json:"text"`
+}
+
+type PatientCommunication struct {
+	Language CodeableConcept `json:"language"`
+}
+
+type Patient struct {
+	ResourceType  string                 `json:"resourceType"`
+	ID            string                 `json:"id,omitempty"`
+	Identifier    []Identifier           `json:"identifier,omitempty"`
+	Active        *bool                  `json:"active,omitempty"`
+	Name          []HumanName            `json:"name,omitempty"`
+	Communication []PatientCommunication `json:"communication,omitempty"`
+}
+
+type Observation struct {
+	ResourceType      string            `json:"resourceType"`
+	ID                string            `json:"id,omitempty"`
+	Status            string            `json:"status"`
+	Category          []CodeableConcept `json:"category,omitempty"`
+	Code              CodeableConcept   `json:"code"`
+	Subject           *Reference        `json:"subject,omitempty"`
+	EffectiveDateTime string            `json:"effectiveDateTime,omitempty"`
+	EffectivePeriod   *Period           `json:"effectivePeriod,omitempty"`
+	EffectiveInstant  string            `json:"effectiveInstant,omitempty"`
+	Issued            string            `json:"issued,omitempty"`
+	ValueQuantity     *Quantity         `json:"valueQuantity,omitempty"`
+	DataAbsentReason  *CodeableConcept  `json:"dataAbsentReason,omitempty"`
+	Note              []Annotation      `json:"note,omitempty"`
+	Method            *CodeableConcept  `json:"method,omitempty"`
+	Device            *Reference        `json:"device,omitempty"`
+}
+
+// NewPatient описывает пользователя ресурсом Patient
+func NewPatient(u *models.User) Patient {
+	patient := Patient{
+		ResourceType: "Patient",
+		ID:           "patient-" + strconv.FormatUint(uint64(u.ID), 10),
+		Identifier:   []Identifier{{System: SystemTelegramID, Value: strconv.FormatInt(u.TelegramID, 10)}},
+		Active:       &u.IsActive,
+	}
+	if u.FirstName != "" || u.LastName != "" {
+		name := HumanName{Use: "usual", Family: u.LastName}
+		if u.FirstName != "" {
+			name.Given = []string{u.FirstName}
+		}
+		patient.Name = []HumanName{name}
+	}
+	if u.LanguageCode != "" {
+		patient.Communication = []PatientCommunication{{
+			Language: CodeableConcept{Coding: []Coding{{System: SystemLanguage, Code: u.LanguageCode}}},
+		}}
+	}
+	return patient
+}
+
+// GlucoseObservation описывает измерение глюкозы в ммоль/л кодом LOINC 15074-8
+func GlucoseObservation(r *models.GlucoseRecord, subject string) Observation {
+	value := r.Value
+	obs := Observation{
+		ResourceType: "Observation",
+		ID:           "glucose-" + strconv.FormatUint(uint64(r.ID), 10),
+		Status:       StatusFinal,
+		Category:     []CodeableConcept{category("laboratory", "Laboratory")},
+		Code: CodeableConcept{
+			Coding: []Coding{{System: SystemLOINC, Code: LOINCGlucoseMolesBlood, Display: "Glucose [Moles/volume] in Blood"}},
+			Text:   "Glucose",
+		},
+		Subject:           &Reference{Reference: subject},
+		EffectiveDateTime: formatDateTime(r.MeasuredAt),
+		ValueQuantity:     &Quantity{Value: &value, Unit: UnitMmolL, System: SystemUCUM, Code: UnitMmolL},
+	}
+	if r.Source != "" {
+		obs.Method = &CodeableConcept{Coding: []Coding{{System: SystemGlucoseSource, Code: r.Source}}}
+	}
+	if r.DeviceID != "" {
+		obs.Device = &Reference{Display: r.DeviceID}
+	}
+	if r.Notes != "" {
+		obs.Note = []Annotation{{Text: r.Notes}}
+	}
+	return obs
+}
+
+// CarbsObservation описывает прием пищи оценкой углеводов (LOINC 9059-7).
+// Название блюда попадает в note; без углеводов значение заменяет dataAbsentReason.
+func CarbsObservation(r *models.FoodRecord, subject string) Observation {
+	obs := Observation{
+		ResourceType: "Observation",
+		ID:           "food-" + strconv.FormatUint(uint64(r.ID), 10),
+		Status:       StatusFinal,
+		Category:     []CodeableConcept{category("survey", "Survey")},
+		Code: CodeableConcept{
+			Coding: []Coding{{System: SystemLOINC, Code: LOINCCarbIntake, Display: "Carbohydrate intake Estimated"}},
+			Text:   "Carbohydrate intake",
+		},
+		Subject:           &Reference{Reference: subject},
+		EffectiveDateTime: formatDateTime(r.ConsumedAt),
+	}
+	if r.FoodName != "" {
+		obs.Note = []Annotation{{Text: r.FoodName}}
+	}
+	if r.Carbs != nil {
+		carbs := *r.Carbs
+		obs.ValueQuantity = &Quantity{Value: &carbs, Unit: UnitGram, System: SystemUCUM, Code: UnitGram}
+	} else {
+		obs.DataAbsentReason = &CodeableConcept{Coding: []Coding{{System: SystemDataAbsentReason, Code: "unknown", Display: "Unknown"}}}
+	}
+	return obs
+}
+
+func category(code, display string) CodeableConcept {
+	return CodeableConcept{Coding: []Coding{{System: SystemObservationCategory, Code: code, Display: display}}}
+}
+
+// formatDateTime форматирует время как dateTime FHIR: секунды и смещение обязательны
+func formatDateTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// URN возвращает urn:uuid ресурса для fullUrl и ссылок внутри Bundle. UUID строится
+// из типа и ID ресурса (версия 5), поэтому повторная выгрузка дает те же ссылки.
+func URN(resourceID string) string {
+	sum := sha1.Sum([]byte("diabetbot:" + resourceID))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
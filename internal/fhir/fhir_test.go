@@ -0,0 +1,200 @@
+package fhir
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadSchema загружает testdata/fhir.schema.json. Определения JSON Schema
+// оборачиваются в компоненты OpenAPI, чтобы проверять документы валидатором kin-openapi.
+func loadSchema(t *testing.T) openapi3.Schemas {
+	data, err := os.ReadFile("testdata/fhir.schema.json")
+	require.NoError(t, err)
+	var schema struct {
+		Definitions json.RawMessage `json:"definitions"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+
+	definitions := strings.ReplaceAll(string(schema.Definitions), "#/definitions/", "#/components/schemas/")
+	spec := `{"openapi": "3.0.3", "info": {"title": "FHIR R4", "version": "4.0.1"}, "paths": {}, "components": {"schemas": ` + definitions + `}}`
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(spec))
+	require.NoError(t, err)
+	return doc.Components.Schemas
+}
+
+func validateBundle(t *testing.T, schemas openapi3.Schemas, data []byte) error {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	return schemas["Bundle"].Value.VisitJSON(doc)
+}
+
+func testRecords() (*models.User, []models.GlucoseRecord, []models.FoodRecord) {
+	user := &models.User{ID: 7, TelegramID: 123456789, FirstName: "Анна", LastName: "Иванова", LanguageCode: "ru", IsActive: true}
+	msk := time.FixedZone("MSK", 3*3600)
+	carbs := 45.5
+	glucose := []models.GlucoseRecord{
+		{ID: 1, UserID: 7, Value: 6.2, MeasuredAt: time.Date(2024, 5, 1, 8, 0, 0, 0, msk), Source: models.GlucoseSourceFingerstick, Notes: "натощак"},
+		{ID: 2, UserID: 7, Value: 9.4, MeasuredAt: time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC), Source: models.GlucoseSourceCGM, DeviceID: "Libre 3"},
+	}
+	food := []models.FoodRecord{
+		{ID: 3, UserID: 7, FoodName: "Гречка с курицей", Carbs: &carbs, ConsumedAt: time.Date(2024, 5, 1, 13, 0, 0, 0, msk)},
+		{ID: 4, UserID: 7, FoodName: "Яблоко", ConsumedAt: time.Date(2024, 5, 1, 16, 30, 0, 0, msk)},
+	}
+	return user, glucose, food
+}
+
+func writeBundle(t *testing.T, user *models.User, glucose []models.GlucoseRecord, food []models.FoodRecord) []byte {
+	var buf bytes.Buffer
+	bw, err := NewBundleWriter(&buf, time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	patient := NewPatient(user)
+	subject := URN(patient.ID)
+	require.NoError(t, bw.Add(patient.ID, patient))
+	for i := range glucose {
+		obs := GlucoseObservation(&glucose[i], subject)
+		require.NoError(t, bw.Add(obs.ID, obs))
+	}
+	for i := range food {
+		obs := CarbsObservation(&food[i], subject)
+		require.NoError(t, bw.Add(obs.ID, obs))
+	}
+	require.NoError(t, bw.Close())
+	return buf.Bytes()
+}
+
+func TestBundleWriter_MatchesSchema(t *testing.T) {
+	schemas := loadSchema(t)
+	user, glucose, food := testRecords()
+	data := writeBundle(t, user, glucose, food)
+
+	require.NoError(t, validateBundle(t, schemas, data))
+
+	var bundle struct {
+		Type  string `json:"type"`
+		Entry []struct {
+			FullURL  string                 `json:"fullUrl"`
+			Resource map[string]interface{} `json:"resource"`
+		} `json:"entry"`
+	}
+	require.NoError(t, json.Unmarshal(data, &bundle))
+	assert.Equal(t, "collection", bundle.Type)
+	require.Len(t, bundle.Entry, 5)
+	assert.Equal(t, "Patient", bundle.Entry[0].Resource["resourceType"])
+	assert.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, bundle.Entry[0].FullURL)
+
+	glucoseObs := bundle.Entry[1].Resource
+	assert.Equal(t, "2024-05-01T08:00:00+03:00", glucoseObs["effectiveDateTime"])
+	assert.Equal(t, map[string]interface{}{"reference": bundle.Entry[0].FullURL}, glucoseObs["subject"])
+	assert.Contains(t, string(data), `"code":"15074-8"`)
+	assert.Contains(t, string(data), `"code":"9059-7"`)
+	assert.Contains(t, bundle.Entry[4].Resource, "dataAbsentReason")
+
+	// Повторная выгрузка дает те же fullUrl
+	assert.Equal(t, data, writeBundle(t, user, glucose, food))
+}
+
+// Проверка схемы должна ловить нарушения, иначе тест выше ничего не доказывает
+func TestSchema_RejectsInvalidResources(t *testing.T) {
+	schemas := loadSchema(t)
+	for name, doc := range map[string]string{
+		"UnknownElement": `{"resourceType": "Bundle", "type": "collection", "entry": [{"resource": {"resourceType": "Observation", "code": {}, "value": 5}}]}`,
+		"NoTimezone":     `{"resourceType": "Bundle", "entry": [{"resource": {"resourceType": "Observation", "code": {}, "effectiveDateTime": "2024-05-01T08:00:00"}}]}`,
+		"BadStatus":      `{"resourceType": "Bundle", "entry": [{"resource": {"resourceType": "Observation", "code": {}, "status": "done"}}]}`,
+		"NoCode":         `{"resourceType": "Bundle", "entry": [{"resource": {"resourceType": "Observation", "status": "final"}}]}`,
+		"BadBundleType":  `{"resourceType": "Bundle", "type": "archive"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, validateBundle(t, schemas, []byte(doc)))
+		})
+	}
+}
+
+func TestDecode_RoundTrip(t *testing.T) {
+	user, glucose, food := testRecords()
+	records, err := Decode(bytes.NewReader(writeBundle(t, user, glucose, food)))
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, records.Total)
+	assert.Zero(t, records.Skipped)
+	require.Len(t, records.Glucose, 2)
+	assert.Equal(t, 6.2, records.Glucose[0].Value)
+	assert.True(t, glucose[0].MeasuredAt.Equal(records.Glucose[0].MeasuredAt))
+	assert.Equal(t, "натощак", records.Glucose[0].Notes)
+	assert.Equal(t, models.GlucoseSourceFingerstick, records.Glucose[0].Source)
+	assert.Equal(t, models.GlucoseSourceCGM, records.Glucose[1].Source)
+	assert.Equal(t, "Libre 3", records.Glucose[1].DeviceID)
+
+	require.Len(t, records.Food, 2)
+	assert.Equal(t, "Гречка с курицей", records.Food[0].FoodName)
+	assert.Equal(t, 45.5, *records.Food[0].Carbs)
+	assert.Nil(t, records.Food[1].Carbs)
+}
+
+func TestDecode_ForeignBundle(t *testing.T) {
+	// Так результаты выгружают лабораторные системы: мг/дл, searchset, время в issued
+	doc := `{
+		"resourceType": "Bundle",
+		"type": "searchset",
+		"entry": [
+			{"resource": {"resourceType": "Patient", "id": "p1"}},
+			{"resource": {"resourceType": "Observation", "status": "final",
+				"code": {"coding": [{"system": "http://loinc.org", "code": "2339-0"}]},
+				"effectiveDateTime": "2024-03-01T09:30:00+05:00",
+				"valueQuantity": {"value": 126, "unit": "mg/dL", "system": "http://unitsofmeasure.org", "code": "mg/dL"}}},
+			{"resource": {"resourceType": "Observation", "status": "final",
+				"code": {"coding": [{"system": "http://loinc.org", "code": "41653-7"}]},
+				"issued": "2024-03-01T12:00:00Z",
+				"valueQuantity": {"value": 99}}},
+			{"resource": {"resourceType": "Observation", "status": "final",
+				"code": {"coding": [{"system": "http://loinc.org", "code": "14749-6"}]},
+				"effectivePeriod": {"start": "2024-03-02T08:00:00Z"},
+				"valueQuantity": {"value": 5.4, "code": "mmol/L"}}},
+			{"resource": {"resourceType": "Observation", "status": "entered-in-error",
+				"code": {"coding": [{"system": "http://loinc.org", "code": "15074-8"}]},
+				"effectiveDateTime": "2024-03-01T10:00:00Z", "valueQuantity": {"value": 6.0}}},
+			{"resource": {"resourceType": "Observation", "status": "final",
+				"code": {"coding": [{"system": "http://loinc.org", "code": "15074-8"}]},
+				"effectiveDateTime": "2024-03-01", "valueQuantity": {"value": 6.0}}},
+			{"resource": {"resourceType": "Observation", "status": "final",
+				"code": {"coding": [{"system": "http://loinc.org", "code": "15074-8"}]},
+				"effectiveDateTime": "2024-03-01T11:00:00Z", "valueQuantity": {"value": 6.0, "code": "g/L"}}},
+			{"resource": {"resourceType": "Observation", "status": "final",
+				"code": {"coding": [{"system": "http://loinc.org", "code": "8867-4"}]},
+				"effectiveDateTime": "2024-03-01T11:00:00Z", "valueQuantity": {"value": 72}}},
+			{"resource": {"resourceType": "Observation", "status": "final",
+				"code": {"coding": [{"system": "http://loinc.org", "code": "9059-7"}]},
+				"effectiveDateTime": "2024-03-01T13:00:00Z", "valueQuantity": {"value": 60, "unit": "g"}}}
+		]
+	}`
+
+	records, err := Decode(strings.NewReader(doc))
+	require.NoError(t, err)
+	assert.Equal(t, 8, records.Total)
+	assert.Equal(t, 4, records.Skipped)
+	require.Len(t, records.Glucose, 3)
+	assert.Equal(t, 7.0, records.Glucose[0].Value)
+	assert.Equal(t, 5.5, records.Glucose[1].Value)
+	assert.Equal(t, 5.4, records.Glucose[2].Value)
+	assert.Equal(t, models.GlucoseSourceFingerstick, records.Glucose[0].Source)
+	require.Len(t, records.Food, 1)
+	assert.Equal(t, "Углеводы", records.Food[0].FoodName)
+	assert.Equal(t, 60.0, *records.Food[0].Carbs)
+}
+
+func TestDecode_NotBundle(t *testing.T) {
+	for _, doc := range []string{`{"resourceType": "Patient"}`, `[1, 2]`, `date,glucose`} {
+		_, err := Decode(strings.NewReader(doc))
+		assert.ErrorIs(t, err, ErrNotBundle, doc)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAlertRules возвращает настройки тревог родственникам; без сохраненных — значения по умолчанию
+func (h *APIHandler) GetAlertRules(c *gin.Context) {
+	owner, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	rule, err := h.alertService.Rules(owner.ID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateAlertRules заменяет настройки тревог. Пустые quiet_start и quiet_end
+// выключают тихие часы, пустой timezone — часовой пояс сервера. Менять пороги
+// может только сам владелец: родственник с правом записи не должен выключать тревоги.
+func (h *APIHandler) UpdateAlertRules(c *gin.Context) {
+	owner, err := h.selfFromQuery(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	var req struct {
+		Enabled        *bool   `json:"enabled" binding:"required"`
+		LowThreshold   float64 `json:"low_threshold" binding:"required"`
+		HighThreshold  float64 `json:"high_threshold" binding:"required"`
+		RecheckMinutes int     `json:"recheck_minutes" binding:"required"`
+		QuietStart     string  `json:"quiet_start"`
+		QuietEnd       string  `json:"quiet_end"`
+		Timezone       string  `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+
+	rule, err := h.alertService.UpdateRules(owner.ID, models.AlertRule{
+		Enabled:        *req.Enabled,
+		LowThreshold:   req.LowThreshold,
+		HighThreshold:  req.HighThreshold,
+		RecheckMinutes: req.RecheckMinutes,
+		QuietStart:     req.QuietStart,
+		QuietEnd:       req.QuietEnd,
+		Timezone:       req.Timezone,
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// GetAlerts возвращает последние тревоги дневника, сначала новые
+func (h *APIHandler) GetAlerts(c *gin.Context) {
+	owner, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	limit := services.DefaultPageLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > services.MaxPageLimit {
+			fail(c, services.ErrInvalidLimit)
+			return
+		}
+	}
+
+	alerts, err := h.alertService.List(owner.ID, limit)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// AcknowledgeAlert отмечает, что родственник видит тревогу: эскалации не будет
+func (h *APIHandler) AcknowledgeAlert(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	alertID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	alert, err := h.alertService.Acknowledge(user.ID, alertID)
+	if err != nil {
+		fail(c, recordError(err, "alert"))
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
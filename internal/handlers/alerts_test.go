@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIHandler_Alerts(t *testing.T) {
+	router, handler, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	owner := testutils.CreateTestUser(db, 656501)
+	parent := testutils.CreateTestUser(db, 656502)
+	testutils.CreateTestUser(db, 656503)
+	helper := testutils.CreateTestUser(db, 656504)
+	ownerData := testInitData(owner.TelegramID, time.Now())
+	parentData := testInitData(parent.TelegramID, time.Now())
+	strangerData := testInitData(656503, time.Now())
+	helperData := testInitData(helper.TelegramID, time.Now())
+
+	invite, err := handler.sharingService.CreateInvite(owner.ID, models.ShareRoleRead)
+	require.NoError(t, err)
+	_, err = handler.sharingService.AcceptInvite(parent.ID, invite.Token)
+	require.NoError(t, err)
+	invite, err = handler.sharingService.CreateInvite(owner.ID, models.ShareRoleWrite)
+	require.NoError(t, err)
+	_, err = handler.sharingService.AcceptInvite(helper.ID, invite.Token)
+	require.NoError(t, err)
+
+	send := func(method, target, body, initData string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	rulesPath := fmt.Sprintf("/api/v1/alerts/rules?owner_id=%d", owner.TelegramID)
+
+	t.Run("Rules", func(t *testing.T) {
+		w := send("GET", "/api/v1/alerts/rules", "", ownerData)
+		require.Equal(t, http.StatusOK, w.Code)
+		var rule models.AlertRule
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+		assert.True(t, rule.Enabled)
+		assert.Equal(t, 3.0, rule.LowThreshold)
+
+		body := `{"enabled": true, "low_threshold": 3.5, "high_threshold": 22, "recheck_minutes": 10}`
+		// Родственник с доступом на чтение видит настройки, но не меняет их
+		assert.Equal(t, http.StatusOK, send("GET", rulesPath, "", parentData).Code)
+		assert.Equal(t, http.StatusForbidden, send("PUT", rulesPath, body, parentData).Code)
+		assert.Equal(t, http.StatusForbidden, send("GET", rulesPath, "", strangerData).Code)
+		// Право записи в дневник не дает выключить или ослабить тревоги владельца
+		assert.Equal(t, http.StatusOK, send("GET", rulesPath, "", helperData).Code)
+		assert.Equal(t, http.StatusForbidden, send("PUT", rulesPath, body, helperData).Code)
+		assert.Equal(t, http.StatusOK, send("PUT", rulesPath, body, ownerData).Code)
+
+		w = send("PUT", "/api/v1/alerts/rules", body, ownerData)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+		assert.Equal(t, 3.5, rule.LowThreshold)
+		assert.Equal(t, 10, rule.RecheckMinutes)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		w := send("PUT", "/api/v1/alerts/rules", `{"enabled": true, "low_threshold": 3, "high_threshold": 20, "recheck_minutes": 15, "quiet_start": "23:00"}`, ownerData)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "quiet_end", decodeError(t, w).Details[0].Field)
+
+		w = send("PUT", "/api/v1/alerts/rules", `{"enabled": true, "low_threshold": 3, "high_threshold": 20, "recheck_minutes": 15, "quiet_start": "7pm", "quiet_end": "07:00"}`, ownerData)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, FieldError{Field: "quiet_start", Rule: "time_of_day", Message: "Ожидается время в формате ЧЧ:ММ"}, decodeError(t, w).Details[0])
+
+		w = send("PUT", "/api/v1/alerts/rules", `{"low_threshold": 3, "high_threshold": 20, "recheck_minutes": 15}`, ownerData)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "enabled", decodeError(t, w).Details[0].Field)
+
+		assert.Equal(t, http.StatusBadRequest, send("GET", "/api/v1/alerts?limit=0", "", ownerData).Code)
+	})
+
+	t.Run("Acknowledge", func(t *testing.T) {
+		_, err := handler.glucoseService.CreateRecord(owner.ID, 2.7, "")
+		require.NoError(t, err)
+
+		w := send("GET", fmt.Sprintf("/api/v1/alerts?owner_id=%d", owner.TelegramID), "", parentData)
+		require.Equal(t, http.StatusOK, w.Code)
+		var alerts []models.Alert
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &alerts))
+		require.Len(t, alerts, 1)
+		assert.Equal(t, models.AlertKindLow, alerts[0].Kind)
+		assert.Equal(t, models.AlertStatusOpen, alerts[0].Status)
+
+		target := fmt.Sprintf("/api/v1/alerts/%d/ack", alerts[0].ID)
+		assert.Equal(t, http.StatusNotFound, send("POST", target, "", strangerData).Code)
+		w = send("POST", target, "", parentData)
+		require.Equal(t, http.StatusOK, w.Code)
+		var alert models.Alert
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &alert))
+		assert.Equal(t, models.AlertStatusAcknowledged, alert.Status)
+		assert.NotNil(t, alert.AcknowledgedAt)
+	})
+}
@@ -1,60 +1,215 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"diabetbot/internal/importer"
 	"diabetbot/internal/models"
 	"diabetbot/internal/services"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
+// APIHandler обрабатывает REST API. Ошибки передаются через fail и
+// оформляются middleware ErrorHandler, который должен быть подключен к роутеру.
 type APIHandler struct {
 	userService    *services.UserService
 	glucoseService *services.GlucoseService
 	foodService    *services.FoodService
+	insulinService *services.InsulinService
+	exportService  *services.ExportService
+	reportService  *services.ReportService
+	importService  *services.ImportService
+	sharingService *services.SharingService
+	alertService   *services.AlertService
+	linkService    *services.PublicLinkService
+	digestService  *services.DigestService
+	insightService *services.InsightService
+	labService     *services.LabService
+	vitalService   *services.VitalService
+	botToken       string // проверяет подпись initData Telegram WebApp
 }
 
-func NewAPIHandler(db *gorm.DB) *APIHandler {
+func NewAPIHandler(svc *services.Services, botToken string) *APIHandler {
 	return &APIHandler{
-		userService:    services.NewUserService(db),
-		glucoseService: services.NewGlucoseService(db),
-		foodService:    services.NewFoodService(db),
+		userService:    svc.Users,
+		glucoseService: svc.Glucose,
+		foodService:    svc.Food,
+		insulinService: svc.Insulin,
+		exportService:  svc.Export,
+		reportService:  svc.Report,
+		importService:  svc.Import,
+		sharingService: svc.Sharing,
+		alertService:   svc.Alerts,
+		linkService:    svc.Links,
+		digestService:  svc.Digests,
+		insightService: svc.Insights,
+		labService:     svc.Labs,
+		vitalService:   svc.Vitals,
+		botToken:       botToken,
 	}
 }
 
+// RegisterRoutes подключает эндпоинты API к группе /api/v1.
+// Каждый маршрут должен быть описан в openapi.json — это проверяют тесты.
+func (h *APIHandler) RegisterRoutes(api *gin.RouterGroup) {
+	api.GET("/openapi.json", ServeOpenAPI)
+	api.GET("/docs", ServeDocs)
+
+	api.GET("/user/:telegram_id", TelegramAuth(h.botToken), h.GetUser)
+	api.PUT("/user/:telegram_id", TelegramAuth(h.botToken), h.UpdateUser)
+	api.PUT("/user/:telegram_id/diabetes-info", TelegramAuth(h.botToken), h.UpdateDiabetesInfo)
+	api.DELETE("/user/:telegram_id/data", TelegramAuth(h.botToken), h.DeleteUserData)
+
+	api.GET("/glucose/:user_id", TelegramAuth(h.botToken), h.GetGlucoseRecords)
+	api.POST("/glucose", TelegramAuth(h.botToken), h.CreateGlucoseRecord)
+	api.PUT("/glucose/:id", TelegramAuth(h.botToken), h.UpdateGlucoseRecord)
+	api.DELETE("/glucose/:id", TelegramAuth(h.botToken), h.DeleteGlucoseRecord)
+	api.GET("/glucose/:user_id/stats", TelegramAuth(h.botToken), h.GetGlucoseStats)
+	api.POST("/glucose/batch", TelegramAuth(h.botToken), h.CreateGlucoseBatch)
+	api.GET("/glucose/series", TelegramAuth(h.botToken), h.GetGlucoseSeries)
+
+	api.GET("/food/:user_id", TelegramAuth(h.botToken), h.GetFoodRecords)
+	api.POST("/food", TelegramAuth(h.botToken), h.CreateFoodRecord)
+	api.PUT("/food/:id", TelegramAuth(h.botToken), h.UpdateFoodRecord)
+	api.DELETE("/food/:id", TelegramAuth(h.botToken), h.DeleteFoodRecord)
+
+	api.GET("/export", TelegramAuth(h.botToken), h.Export)
+	api.GET("/report", TelegramAuth(h.botToken), h.Report)
+	api.POST("/import", TelegramAuth(h.botToken), h.Import)
+	api.POST("/import/fhir", TelegramAuth(h.botToken), h.ImportFHIR)
+	api.GET("/import/:id", TelegramAuth(h.botToken), h.GetImportJob)
+
+	api.GET("/accounts", TelegramAuth(h.botToken), h.GetAccounts)
+	api.GET("/shares", TelegramAuth(h.botToken), h.GetShares)
+	api.POST("/shares", TelegramAuth(h.botToken), h.CreateShare)
+	api.DELETE("/shares/:id", TelegramAuth(h.botToken), h.DeleteShare)
+
+	api.GET("/alerts", TelegramAuth(h.botToken), h.GetAlerts)
+	api.GET("/alerts/rules", TelegramAuth(h.botToken), h.GetAlertRules)
+	api.PUT("/alerts/rules", TelegramAuth(h.botToken), h.UpdateAlertRules)
+	api.POST("/alerts/:id/ack", TelegramAuth(h.botToken), h.AcknowledgeAlert)
+
+	api.GET("/public-links", TelegramAuth(h.botToken), h.GetPublicLinks)
+	api.POST("/public-links", TelegramAuth(h.botToken), h.CreatePublicLink)
+	api.DELETE("/public-links/:id", TelegramAuth(h.botToken), h.DeletePublicLink)
+	api.GET("/public-links/:id/accesses", TelegramAuth(h.botToken), h.GetPublicLinkAccesses)
+
+	api.GET("/digests", TelegramAuth(h.botToken), h.GetDigests)
+	api.GET("/digests/settings", TelegramAuth(h.botToken), h.GetDigestSettings)
+	api.PUT("/digests/settings", TelegramAuth(h.botToken), h.UpdateDigestSettings)
+
+	api.GET("/insights/:user_id", TelegramAuth(h.botToken), h.GetInsights)
+
+	api.GET("/labs", TelegramAuth(h.botToken), h.GetLabResults)
+	api.POST("/labs", TelegramAuth(h.botToken), h.CreateLabResult)
+	api.GET("/labs/:id", TelegramAuth(h.botToken), h.GetLabResult)
+	api.PUT("/labs/:id", TelegramAuth(h.botToken), h.UpdateLabResult)
+	api.DELETE("/labs/:id", TelegramAuth(h.botToken), h.DeleteLabResult)
+
+	api.GET("/vitals", TelegramAuth(h.botToken), h.GetVitals)
+	api.POST("/vitals", TelegramAuth(h.botToken), h.CreateVital)
+	api.GET("/vitals/:id", TelegramAuth(h.botToken), h.GetVital)
+	api.PUT("/vitals/:id", TelegramAuth(h.botToken), h.UpdateVital)
+	api.DELETE("/vitals/:id", TelegramAuth(h.botToken), h.DeleteVital)
+}
+
+// telegramIDParam разбирает telegram_id из параметра пути
+func telegramIDParam(c *gin.Context, name string) (int64, error) {
+	telegramID, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		return 0, invalidParam(name)
+	}
+	return telegramID, nil
+}
+
+// recordIDParam разбирает ID записи из параметра пути
+func recordIDParam(c *gin.Context) (uint, error) {
+	recordID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, invalidParam("id")
+	}
+	return uint(recordID), nil
+}
+
+// userByTelegramID отличает отсутствующего пользователя (404) от ошибки базы (500)
+func (h *APIHandler) userByTelegramID(telegramID int64) (*models.User, error) {
+	user, err := h.userService.GetByTelegramID(telegramID)
+	if errors.Is(err, services.ErrNotFound) {
+		return nil, notFound("user")
+	}
+	return user, err
+}
+
+// selfFromPath находит пользователя по telegram_id из параметра пути name, если это
+// пользователь из initData. Профиль меняет только владелец: получателям доступа — 403.
+func (h *APIHandler) selfFromPath(c *gin.Context, name string) (*models.User, error) {
+	telegramID, err := telegramIDParam(c, name)
+	if err != nil {
+		return nil, err
+	}
+	if telegramID != authTelegramID(c) {
+		return nil, services.ErrForbidden
+	}
+	return h.userByTelegramID(telegramID)
+}
+
+// recordError уточняет, какая запись не найдена
+func recordError(err error, resource string) error {
+	if errors.Is(err, services.ErrNotFound) {
+		return notFound(resource)
+	}
+	return err
+}
+
 // User endpoints
+
+// GetUser возвращает профиль владельца дневника. Свой профиль при первом входе
+// создается из данных Telegram WebApp, чужой доступен получателю доступа.
 func (h *APIHandler) GetUser(c *gin.Context) {
-	telegramIDStr := c.Param("telegram_id")
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	telegramID, err := telegramIDParam(c, "telegram_id")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid telegram_id"})
+		fail(c, err)
+		return
+	}
+	if telegramID != authTelegramID(c) {
+		owner, err := h.diaryOwner(c, telegramID, models.ShareRoleRead)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, owner)
 		return
 	}
 
 	user, err := h.userService.GetByTelegramID(telegramID)
-	if err != nil {
+	if errors.Is(err, services.ErrNotFound) {
 		// Если пользователь не найден, создаем его из данных Telegram WebApp
-		if err == gorm.ErrRecordNotFound {
-			// Получаем данные из заголовков для создания пользователя
-			username := c.GetHeader("X-Telegram-Username")
-			firstName := c.GetHeader("X-Telegram-First-Name") 
-			lastName := c.GetHeader("X-Telegram-Last-Name")
-			languageCode := c.GetHeader("X-Telegram-Language-Code")
-			
-			// Создаем нового пользователя
-			user, err = h.userService.GetOrCreateUser(telegramID, username, firstName, lastName, languageCode)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-				return
-			}
-			
-			c.JSON(http.StatusCreated, user)
+		username := c.GetHeader("X-Telegram-Username")
+		firstName := c.GetHeader("X-Telegram-First-Name")
+		lastName := c.GetHeader("X-Telegram-Last-Name")
+		languageCode := c.GetHeader("X-Telegram-Language-Code")
+
+		user, err = h.userService.GetOrCreateUser(telegramID, username, firstName, lastName, languageCode)
+		if err != nil {
+			fail(c, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+
+		c.JSON(http.StatusCreated, user)
+		return
+	}
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
@@ -62,16 +217,9 @@ func (h *APIHandler) GetUser(c *gin.Context) {
 }
 
 func (h *APIHandler) UpdateDiabetesInfo(c *gin.Context) {
-	telegramIDStr := c.Param("telegram_id")
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid telegram_id"})
-		return
-	}
-
-	user, err := h.userService.GetByTelegramID(telegramID)
+	user, err := h.selfFromPath(c, "telegram_id")
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		fail(c, err)
 		return
 	}
 
@@ -81,12 +229,12 @@ func (h *APIHandler) UpdateDiabetesInfo(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
 	if err := h.userService.UpdateDiabetesInfo(user.ID, req.DiabetesType, req.TargetGlucose); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user info"})
+		fail(c, err)
 		return
 	}
 
@@ -94,26 +242,19 @@ func (h *APIHandler) UpdateDiabetesInfo(c *gin.Context) {
 }
 
 func (h *APIHandler) UpdateUser(c *gin.Context) {
-	telegramIDStr := c.Param("telegram_id")
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid telegram_id"})
-		return
-	}
-
-	user, err := h.userService.GetByTelegramID(telegramID)
+	user, err := h.selfFromPath(c, "telegram_id")
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		fail(c, err)
 		return
 	}
 
 	var req struct {
-		TargetGlucose *float64 `json:"target_glucose"`
+		TargetGlucose *float64 `json:"target_glucose" binding:"omitempty,min=3,max=15"`
 		Notifications *bool    `json:"notifications"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
@@ -126,44 +267,48 @@ func (h *APIHandler) UpdateUser(c *gin.Context) {
 		updates["notifications"] = req.Notifications
 	}
 
-	if err := h.userService.UpdateUser(user.ID, updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-		return
+	if len(updates) > 0 {
+		if err := h.userService.UpdateUser(user.ID, updates); err != nil {
+			fail(c, err)
+			return
+		}
 	}
 
 	// Получаем обновленного пользователя
-	updatedUser, err := h.userService.GetByTelegramID(telegramID)
+	updatedUser, err := h.userByTelegramID(user.TelegramID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated user"})
+		fail(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, updatedUser)
 }
 
+// DeleteUserData удаляет все записи дневника. Доступно только владельцу, не получателям доступа.
 func (h *APIHandler) DeleteUserData(c *gin.Context) {
-	telegramIDStr := c.Param("telegram_id")
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid telegram_id"})
-		return
-	}
-
-	user, err := h.userService.GetByTelegramID(telegramID)
+	user, err := h.selfFromPath(c, "telegram_id")
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		fail(c, err)
 		return
 	}
 
-	// Удаляем все данные пользователя (glucose records, food records)
-	if err := h.glucoseService.DeleteAllUserRecords(user.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete glucose records"})
-		return
+	// Удаляем все записи дневника и сводки, которые их пересказывают, и отзываем
+	// публичные ссылки. Профиль, настройки и доступы родственникам остаются:
+	// дневник можно вести заново, не приглашая их повторно.
+	deletes := []func(uint) error{
+		h.glucoseService.DeleteAllUserRecords,
+		h.foodService.DeleteAllUserRecords,
+		h.insulinService.DeleteAllUserRecords,
+		h.labService.DeleteAllUserResults,
+		h.vitalService.DeleteAllUserVitals,
+		h.digestService.DeleteAll,
+		h.linkService.RevokeAll,
 	}
-
-	if err := h.foodService.DeleteAllUserRecords(user.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete food records"})
-		return
+	for _, del := range deletes {
+		if err := del(user.ID); err != nil {
+			fail(c, err)
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User data deleted successfully"})
@@ -171,57 +316,54 @@ func (h *APIHandler) DeleteUserData(c *gin.Context) {
 
 // Glucose endpoints
 func (h *APIHandler) GetGlucoseRecords(c *gin.Context) {
-	telegramID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	user, err := h.ownerFromPath(c, "user_id", models.ShareRoleRead)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		fail(c, err)
 		return
 	}
 
-	// Получаем пользователя по telegram_id
-	user, err := h.userService.GetByTelegramID(telegramID)
+	opts, err := parseListOptions(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		fail(c, err)
 		return
 	}
 
-	days := 30 // по умолчанию 30 дней
-	if daysStr := c.Query("days"); daysStr != "" {
-		if d, err := strconv.Atoi(daysStr); err == nil && d > 0 && d <= 365 {
-			days = d
-		}
+	measurementContext := c.Query("context")
+	if measurementContext != "" && !models.IsGlucoseContext(measurementContext) {
+		fail(c, &services.ValidationError{Field: "context", Rule: "oneof", Param: "fasting before_meal after_meal bedtime"})
+		return
 	}
 
-	records, err := h.glucoseService.GetUserRecords(user.ID, days)
+	page, err := h.glucoseService.ListRecords(user.ID, opts, measurementContext)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get glucose records"})
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, records)
+	c.JSON(http.StatusOK, page)
 }
 
+// CreateGlucoseRecord добавляет запись в свой дневник или в открытый на запись дневник owner_id
 func (h *APIHandler) CreateGlucoseRecord(c *gin.Context) {
-	var req struct {
-		UserID int64   `json:"user_id" binding:"required"`
-		Value  float64 `json:"value" binding:"required,min=1,max=30"`
-		Notes  string  `json:"notes"`
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	var req struct {
+		Value float64 `json:"value" binding:"required,min=1,max=30"`
+		Notes string  `json:"notes" binding:"max=500"`
 	}
 
-	// Получаем пользователя по telegram_id
-	user, err := h.userService.GetByTelegramID(req.UserID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
 		return
 	}
 
 	record, err := h.glucoseService.CreateRecord(user.ID, req.Value, req.Notes)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create glucose record"})
+		fail(c, err)
 		return
 	}
 
@@ -229,25 +371,35 @@ func (h *APIHandler) CreateGlucoseRecord(c *gin.Context) {
 }
 
 func (h *APIHandler) UpdateGlucoseRecord(c *gin.Context) {
-	recordID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+		fail(c, err)
+		return
+	}
+
+	recordID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
 	var req struct {
-		UserID uint    `json:"user_id" binding:"required"`
-		Value  float64 `json:"value" binding:"required,min=1,max=30"`
-		Notes  string  `json:"notes"`
+		Value float64 `json:"value" binding:"required,min=1,max=30"`
+		Notes string  `json:"notes" binding:"max=500"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
-	if err := h.glucoseService.UpdateRecord(req.UserID, uint(recordID), req.Value, req.Notes); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update glucose record"})
+	if _, err := h.glucoseService.GetRecord(user.ID, recordID); err != nil {
+		fail(c, recordError(err, "glucose_record"))
+		return
+	}
+
+	if err := h.glucoseService.UpdateRecord(user.ID, recordID, req.Value, req.Notes); err != nil {
+		fail(c, err)
 		return
 	}
 
@@ -255,21 +407,25 @@ func (h *APIHandler) UpdateGlucoseRecord(c *gin.Context) {
 }
 
 func (h *APIHandler) DeleteGlucoseRecord(c *gin.Context) {
-	recordID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+		fail(c, err)
 		return
 	}
 
-	userIDStr := c.Query("user_id")
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	recordID, err := recordIDParam(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid user_id"})
+		fail(c, err)
+		return
+	}
+
+	if _, err := h.glucoseService.GetRecord(user.ID, recordID); err != nil {
+		fail(c, recordError(err, "glucose_record"))
 		return
 	}
 
-	if err := h.glucoseService.DeleteRecord(uint(userID), uint(recordID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete glucose record"})
+	if err := h.glucoseService.DeleteRecord(user.ID, recordID); err != nil {
+		fail(c, err)
 		return
 	}
 
@@ -277,94 +433,158 @@ func (h *APIHandler) DeleteGlucoseRecord(c *gin.Context) {
 }
 
 func (h *APIHandler) GetGlucoseStats(c *gin.Context) {
-	telegramID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	user, err := h.ownerFromPath(c, "user_id", models.ShareRoleRead)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		fail(c, err)
 		return
 	}
 
-	// Получаем пользователя по telegram_id
-	user, err := h.userService.GetByTelegramID(telegramID)
+	days, err := parseDays(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		fail(c, err)
 		return
 	}
 
-	days := 30
-	if daysStr := c.Query("days"); daysStr != "" {
-		if d, err := strconv.Atoi(daysStr); err == nil && d > 0 && d <= 365 {
-			days = d
-		}
-	}
-
 	stats, err := h.glucoseService.GetUserStats(user.ID, days)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get glucose stats"})
+		fail(c, err)
+		return
+	}
+	hba1c, err := h.labService.Latest(user.ID)
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	// Последний HbA1c и GMI до него — независимо от days: анализ сдают раз в несколько месяцев
+	c.JSON(http.StatusOK, struct {
+		*services.GlucoseStats
+		HbA1c *services.HbA1cComparison `json:"hba1c,omitempty"`
+	}{stats, hba1c})
 }
 
-// Food endpoints
-func (h *APIHandler) GetFoodRecords(c *gin.Context) {
-	telegramID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+// defaultSeriesPeriod — период ряда глюкозы, если не задан from
+const defaultSeriesPeriod = 24 * time.Hour
+
+// CreateGlucoseBatch сохраняет пачку показаний сенсора или глюкометра. Повторы уже
+// сохраненных показаний пропускаются, поэтому клиент может повторить загрузку целиком.
+func (h *APIHandler) CreateGlucoseBatch(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		fail(c, err)
 		return
 	}
 
-	// Получаем пользователя по telegram_id
-	user, err := h.userService.GetByTelegramID(telegramID)
+	var req struct {
+		Source   string `json:"source" binding:"omitempty,oneof=fingerstick cgm"`
+		DeviceID string `json:"device_id" binding:"max=100"`
+		Readings []struct {
+			Value      float64   `json:"value" binding:"required"`
+			MeasuredAt time.Time `json:"measured_at" binding:"required"`
+		} `json:"readings" binding:"required,min=1,max=5000,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+	if req.Source == "" {
+		req.Source = models.GlucoseSourceCGM
+	}
+
+	readings := make([]services.GlucoseReading, len(req.Readings))
+	for i, reading := range req.Readings {
+		readings[i] = services.GlucoseReading{Value: reading.Value, MeasuredAt: reading.MeasuredAt}
+	}
+	result, err := h.glucoseService.AddReadings(user.ID, req.Source, req.DeviceID, readings)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// GetGlucoseSeries возвращает средние значения глюкозы по интервалам bucket (5m, 1h, 1d)
+// за период from–to, по умолчанию за последние сутки. Сутки отсчитываются в часовом
+// поясе timezone (IANA), source ограничивает ряд одним источником.
+func (h *APIHandler) GetGlucoseSeries(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleRead)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		fail(c, err)
 		return
 	}
 
-	days := 30
-	if daysStr := c.Query("days"); daysStr != "" {
-		if d, err := strconv.Atoi(daysStr); err == nil && d > 0 && d <= 365 {
-			days = d
+	opts := services.SeriesOptions{
+		Bucket:   c.DefaultQuery("bucket", services.SeriesBucket1h),
+		Source:   c.Query("source"),
+		Location: time.Local,
+	}
+	if opts.From, opts.To, err = parseTimeRange(c); err != nil {
+		fail(c, err)
+		return
+	}
+	if opts.To.IsZero() {
+		opts.To = time.Now()
+	}
+	if opts.From.IsZero() {
+		opts.From = opts.To.Add(-defaultSeriesPeriod)
+	}
+	if timezone := c.Query("timezone"); timezone != "" {
+		if opts.Location, err = time.LoadLocation(timezone); err != nil {
+			fail(c, invalidParam("timezone"))
+			return
 		}
 	}
 
-	var records []models.FoodRecord
-	var serviceErr error
+	series, err := h.glucoseService.Series(user.ID, opts)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, series)
+}
+
+// Food endpoints
+func (h *APIHandler) GetFoodRecords(c *gin.Context) {
+	user, err := h.ownerFromPath(c, "user_id", models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
 
-	if foodType := c.Query("type"); foodType != "" {
-		records, serviceErr = h.foodService.GetRecordsByType(user.ID, foodType, days)
-	} else {
-		records, serviceErr = h.foodService.GetUserRecords(user.ID, days)
+	opts, err := parseListOptions(c)
+	if err != nil {
+		fail(c, err)
+		return
 	}
 
-	if serviceErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get food records"})
+	page, err := h.foodService.ListRecords(user.ID, opts, c.Query("type"))
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, records)
+	c.JSON(http.StatusOK, page)
 }
 
+// CreateFoodRecord добавляет запись в свой дневник или в открытый на запись дневник owner_id
 func (h *APIHandler) CreateFoodRecord(c *gin.Context) {
-	var req struct {
-		UserID   int64    `json:"user_id" binding:"required"`
-		FoodName string   `json:"food_name" binding:"required"`
-		FoodType string   `json:"food_type" binding:"required"`
-		Carbs    *float64 `json:"carbs"`
-		Calories *int     `json:"calories"`
-		Quantity string   `json:"quantity"`
-		Notes    string   `json:"notes"`
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	var req struct {
+		FoodName string   `json:"food_name" binding:"required,max=255"`
+		FoodType string   `json:"food_type" binding:"required,max=100"`
+		Carbs    *float64 `json:"carbs" binding:"omitempty,min=0"`
+		Calories *int     `json:"calories" binding:"omitempty,min=0"`
+		Quantity string   `json:"quantity" binding:"max=100"`
+		Notes    string   `json:"notes" binding:"max=500"`
 	}
 
-	// Получаем пользователя по telegram_id
-	user, err := h.userService.GetByTelegramID(req.UserID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
 		return
 	}
 
@@ -373,7 +593,7 @@ func (h *APIHandler) CreateFoodRecord(c *gin.Context) {
 		req.Carbs, req.Calories, req.Quantity, req.Notes,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create food record"})
+		fail(c, err)
 		return
 	}
 
@@ -381,24 +601,29 @@ func (h *APIHandler) CreateFoodRecord(c *gin.Context) {
 }
 
 func (h *APIHandler) UpdateFoodRecord(c *gin.Context) {
-	recordID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+		fail(c, err)
+		return
+	}
+
+	recordID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
 	var req struct {
-		UserID   uint     `json:"user_id" binding:"required"`
-		FoodName string   `json:"food_name"`
-		FoodType string   `json:"food_type"`
-		Carbs    *float64 `json:"carbs"`
-		Calories *int     `json:"calories"`
-		Quantity string   `json:"quantity"`
-		Notes    string   `json:"notes"`
+		FoodName string   `json:"food_name" binding:"max=255"`
+		FoodType string   `json:"food_type" binding:"max=100"`
+		Carbs    *float64 `json:"carbs" binding:"omitempty,min=0"`
+		Calories *int     `json:"calories" binding:"omitempty,min=0"`
+		Quantity string   `json:"quantity" binding:"max=100"`
+		Notes    string   `json:"notes" binding:"max=500"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
@@ -422,32 +647,306 @@ func (h *APIHandler) UpdateFoodRecord(c *gin.Context) {
 		updates["notes"] = req.Notes
 	}
 
-	if err := h.foodService.UpdateRecord(req.UserID, uint(recordID), updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update food record"})
+	if _, err := h.foodService.GetRecord(user.ID, recordID); err != nil {
+		fail(c, recordError(err, "food_record"))
 		return
 	}
 
+	if len(updates) > 0 {
+		if err := h.foodService.UpdateRecord(user.ID, recordID, updates); err != nil {
+			fail(c, err)
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Food record updated successfully"})
 }
 
 func (h *APIHandler) DeleteFoodRecord(c *gin.Context) {
-	recordID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+		fail(c, err)
 		return
 	}
 
-	userIDStr := c.Query("user_id")
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	recordID, err := recordIDParam(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid user_id"})
+		fail(c, err)
+		return
+	}
+
+	if _, err := h.foodService.GetRecord(user.ID, recordID); err != nil {
+		fail(c, recordError(err, "food_record"))
 		return
 	}
 
-	if err := h.foodService.DeleteRecord(uint(userID), uint(recordID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete food record"})
+	if err := h.foodService.DeleteRecord(user.ID, recordID); err != nil {
+		fail(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Food record deleted successfully"})
-}
\ No newline at end of file
+}
+
+// Export выгружает дневник пользователя, подтвержденного подписью Telegram, или
+// открытый ему дневник owner_id. Записи пишутся в ответ по мере чтения из базы.
+func (h *APIHandler) Export(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	opts := services.ExportOptions{Format: services.ExportFormat(c.DefaultQuery("format", string(services.ExportCSV)))}
+	if opts.From, opts.To, err = parseTimeRange(c); err != nil {
+		fail(c, err)
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		fail(c, err)
+		return
+	}
+
+	filename := services.ExportFileName(opts.Format, time.Now())
+	c.Header("Content-Type", opts.Format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	if err := h.exportService.Export(c.Writer, user.ID, opts); err != nil {
+		if !c.Writer.Written() {
+			// Ошибка отдается JSON, а не файлом: заголовки выгрузки убираются
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			fail(c, err)
+			return
+		}
+		// Часть файла уже отправлена, сообщить об ошибке в ответе нельзя
+		log.Printf("Export error [%s] for user %d: %v", c.GetString(requestIDKey), user.ID, err)
+	}
+}
+
+// Report отдает PDF-отчет для врача за период from–to. Без from берутся последние
+// days дней (по умолчанию defaultDays), без to — период до текущего момента.
+func (h *APIHandler) Report(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	if _, ok := c.GetQuery("days"); ok && !from.IsZero() {
+		fail(c, &services.ValidationError{Field: "days", Rule: "exclusive", Param: "from"})
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		days, err := parseDays(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		from = to.AddDate(0, 0, -days)
+	}
+
+	report, err := h.reportService.Build(user, services.ReportOptions{From: from, To: to})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := services.WriteReportPDF(&buf, report); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, services.ReportFileName(time.Now())))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// importFormBytes — запас на поля формы сверх размера файла
+const importFormBytes = 1 << 20
+
+// Import загружает измерения глюкозы из CSV (multipart поле file). С dry_run=true
+// записи не сохраняются: ответ показывает, сколько измерений новых и сколько дубликатов.
+func (h *APIHandler) Import(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, importer.MaxFileSize+importFormBytes)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			fail(c, &services.ValidationError{Field: "file", Rule: "file_size", Param: fmt.Sprintf("%dMB", importer.MaxFileSize>>20)})
+			return
+		}
+		fail(c, &services.ValidationError{Field: "file", Rule: "required"})
+		return
+	}
+
+	opts, err := parseImportOptions(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	opts.FileName = header.Filename
+
+	file, err := header.Open()
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	defer file.Close()
+
+	job, err := h.importService.Run(user.ID, file, opts)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if opts.DryRun {
+		status = http.StatusOK
+	}
+	c.JSON(status, job)
+}
+
+// ImportFHIR загружает глюкозу и углеводы из Bundle FHIR R4: файлом в multipart поле file
+// или телом запроса application/fhir+json.
+func (h *APIHandler) ImportFHIR(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, importer.MaxFileSize+importFormBytes)
+	opts := services.ImportOptions{Source: models.ImportSourceAPI}
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			if !isTooLarge(err) {
+				err = &services.ValidationError{Field: "file", Rule: "required"}
+			}
+			fail(c, importFileError(err))
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		defer file.Close()
+		body, opts.FileName = file, header.Filename
+	}
+
+	job, err := h.importService.ImportFHIR(user.ID, body, opts)
+	if err != nil {
+		fail(c, importFileError(err))
+		return
+	}
+	c.JSON(http.StatusCreated, job)
+}
+
+// importFileError переводит превышение размера загрузки в ошибку поля file
+func importFileError(err error) error {
+	if isTooLarge(err) {
+		return &services.ValidationError{Field: "file", Rule: "file_size", Param: fmt.Sprintf("%dMB", importer.MaxFileSize>>20)}
+	}
+	return err
+}
+
+func isTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// GetImportJob возвращает итог импорта
+func (h *APIHandler) GetImportJob(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	jobID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	job, err := h.importService.GetJob(user.ID, jobID)
+	if err != nil {
+		fail(c, recordError(err, "import_job"))
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// parseImportOptions разбирает поля формы импорта: format, dry_run, timezone (IANA),
+// unit и колонки произвольного CSV (time_column, value_column, date_column, notes_column)
+func parseImportOptions(c *gin.Context) (services.ImportOptions, error) {
+	opts := services.ImportOptions{Source: models.ImportSourceAPI, Location: time.Local}
+
+	if format := c.PostForm("format"); format != "" {
+		formats := importer.Formats()
+		if !slices.Contains(formats, format) {
+			return opts, &services.ValidationError{Field: "format", Rule: "oneof", Param: strings.Join(formats, " ")}
+		}
+		opts.Format = format
+	}
+
+	if dryRun := c.PostForm("dry_run"); dryRun != "" {
+		value, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return opts, &services.ValidationError{Field: "dry_run", Rule: "type"}
+		}
+		opts.DryRun = value
+	}
+
+	if timezone := c.PostForm("timezone"); timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return opts, invalidParam("timezone")
+		}
+		opts.Location = loc
+	}
+
+	mapping := importer.Mapping{
+		DateColumn:  c.PostForm("date_column"),
+		TimeColumn:  c.PostForm("time_column"),
+		ValueColumn: c.PostForm("value_column"),
+		NotesColumn: c.PostForm("notes_column"),
+	}
+	switch unit := importer.Unit(c.PostForm("unit")); unit {
+	case "", importer.UnitMmol, importer.UnitMgDL:
+		mapping.Unit = unit
+	default:
+		return opts, &services.ValidationError{Field: "unit", Rule: "oneof", Param: string(importer.UnitMmol) + " " + string(importer.UnitMgDL)}
+	}
+
+	// Колонки и единицы задают разбор произвольного CSV и указываются вместе
+	if mapping != (importer.Mapping{}) {
+		if mapping.TimeColumn == "" {
+			return opts, &services.ValidationError{Field: "time_column", Rule: "required"}
+		}
+		if mapping.ValueColumn == "" {
+			return opts, &services.ValidationError{Field: "value_column", Rule: "required"}
+		}
+		if opts.Format != "" && opts.Format != importer.FormatGeneric {
+			return opts, &services.ValidationError{Field: "time_column", Rule: "exclusive", Param: "format"}
+		}
+		opts.Mapping = &mapping
+	}
+	return opts, nil
+}
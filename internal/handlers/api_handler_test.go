@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/services"
 	"diabetbot/internal/testutils"
 
 	"github.com/gin-gonic/gin"
@@ -21,27 +25,12 @@ func setupTestRouter() (*gin.Engine, *APIHandler, *gorm.DB) {
 	gin.SetMode(gin.TestMode)
 	
 	db := testutils.SetupTestDB(&testing.T{})
-	handler := NewAPIHandler(db)
+	handler := NewAPIHandler(services.New(repository.NewGorm(db)), testutils.FakeBotToken)
 	
 	router := gin.New()
+	router.Use(ErrorHandler())
 	
-	// API routes
-	api := router.Group("/api/v1")
-	{
-		api.GET("/user/:telegram_id", handler.GetUser)
-		api.PUT("/user/:telegram_id/diabetes-info", handler.UpdateDiabetesInfo)
-		
-		api.GET("/glucose/:user_id", handler.GetGlucoseRecords)
-		api.POST("/glucose", handler.CreateGlucoseRecord)
-		api.PUT("/glucose/:id", handler.UpdateGlucoseRecord)
-		api.DELETE("/glucose/:id", handler.DeleteGlucoseRecord)
-		api.GET("/glucose/:user_id/stats", handler.GetGlucoseStats)
-		
-		api.GET("/food/:user_id", handler.GetFoodRecords)
-		api.POST("/food", handler.CreateFoodRecord)
-		api.PUT("/food/:id", handler.UpdateFoodRecord)
-		api.DELETE("/food/:id", handler.DeleteFoodRecord)
-	}
+	handler.RegisterRoutes(router.Group("/api/v1"))
 	
 	return router, handler, db
 }
@@ -54,7 +43,7 @@ func TestAPIHandler_GetUser(t *testing.T) {
 		// Создаем тестового пользователя
 		user := testutils.CreateTestUser(db, 123456789)
 		
-		req := httptest.NewRequest("GET", "/api/v1/user/123456789", nil)
+		req := getWithInitData("/api/v1/user/123456789", testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -69,23 +58,24 @@ func TestAPIHandler_GetUser(t *testing.T) {
 		assert.Equal(t, user.TelegramID, response.TelegramID)
 	})
 
-	t.Run("UserNotFound", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/api/v1/user/999999999", nil)
+	t.Run("CreatedOnFirstVisit", func(t *testing.T) {
+		req := getWithInitData("/api/v1/user/999999999", testInitData(999999999, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
 		
-		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, http.StatusCreated, w.Code)
 		
-		var response map[string]string
+		var response models.User
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 		
-		assert.Equal(t, "User not found", response["error"])
+		assert.Equal(t, int64(999999999), response.TelegramID)
+		assert.NotZero(t, response.ID)
 	})
 
 	t.Run("InvalidTelegramID", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/api/v1/user/invalid", nil)
+		req := getWithInitData("/api/v1/user/invalid", testInitData(123456789, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -109,6 +99,7 @@ func TestAPIHandler_UpdateDiabetesInfo(t *testing.T) {
 		body, _ := json.Marshal(updateData)
 		req := httptest.NewRequest("PUT", "/api/v1/user/123456789/diabetes-info", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(123456789, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -133,6 +124,7 @@ func TestAPIHandler_UpdateDiabetesInfo(t *testing.T) {
 		body, _ := json.Marshal(updateData)
 		req := httptest.NewRequest("PUT", "/api/v1/user/123456789/diabetes-info", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(123456789, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -149,12 +141,30 @@ func TestAPIHandler_UpdateDiabetesInfo(t *testing.T) {
 		body, _ := json.Marshal(updateData)
 		req := httptest.NewRequest("PUT", "/api/v1/user/999999999/diabetes-info", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(999999999, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
 		
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
+
+	t.Run("OtherUser", func(t *testing.T) {
+		updateData := map[string]interface{}{
+			"diabetes_type":   1,
+			"target_glucose": 6.0,
+		}
+		
+		body, _ := json.Marshal(updateData)
+		req := httptest.NewRequest("PUT", "/api/v1/user/123456789/diabetes-info", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(999999999, time.Now()))
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
 }
 
 func TestAPIHandler_CreateGlucoseRecord(t *testing.T) {
@@ -165,7 +175,6 @@ func TestAPIHandler_CreateGlucoseRecord(t *testing.T) {
 		user := testutils.CreateTestUser(db, 123456789)
 		
 		recordData := map[string]interface{}{
-			"user_id": user.ID,
 			"value":   6.5,
 			"notes":   "После завтрака",
 		}
@@ -173,6 +182,7 @@ func TestAPIHandler_CreateGlucoseRecord(t *testing.T) {
 		body, _ := json.Marshal(recordData)
 		req := httptest.NewRequest("POST", "/api/v1/glucose", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -193,13 +203,13 @@ func TestAPIHandler_CreateGlucoseRecord(t *testing.T) {
 		user := testutils.CreateTestUser(db, 123456789)
 		
 		recordData := map[string]interface{}{
-			"user_id": user.ID,
 			"value":   50.0, // слишком высокое значение
 		}
 		
 		body, _ := json.Marshal(recordData)
 		req := httptest.NewRequest("POST", "/api/v1/glucose", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -207,7 +217,7 @@ func TestAPIHandler_CreateGlucoseRecord(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 
-	t.Run("MissingUserID", func(t *testing.T) {
+	t.Run("WithoutInitData", func(t *testing.T) {
 		recordData := map[string]interface{}{
 			"value": 6.5,
 		}
@@ -219,7 +229,7 @@ func TestAPIHandler_CreateGlucoseRecord(t *testing.T) {
 		
 		router.ServeHTTP(w, req)
 		
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
 }
 
@@ -235,19 +245,19 @@ func TestAPIHandler_GetGlucoseRecords(t *testing.T) {
 		testutils.CreateTestGlucoseRecord(db, user.ID, 6.5)
 		testutils.CreateTestGlucoseRecord(db, user.ID, 7.0)
 		
-		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/glucose/%d", user.ID), nil)
+		req := getWithInitData(fmt.Sprintf("/api/v1/glucose/%d", user.TelegramID), testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
 		
 		assert.Equal(t, http.StatusOK, w.Code)
 		
-		var response []models.GlucoseRecord
+		var response services.Page[models.GlucoseRecord]
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 		
-		assert.Len(t, response, 3)
-		for _, record := range response {
+		assert.Len(t, response.Items, 3)
+		for _, record := range response.Items {
 			assert.Equal(t, user.ID, record.UserID)
 		}
 	})
@@ -256,7 +266,7 @@ func TestAPIHandler_GetGlucoseRecords(t *testing.T) {
 		user := testutils.CreateTestUser(db, 987654321)
 		testutils.CreateTestGlucoseRecord(db, user.ID, 6.0)
 		
-		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/glucose/%d?days=7", user.ID), nil)
+		req := getWithInitData(fmt.Sprintf("/api/v1/glucose/%d?days=7", user.TelegramID), testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -265,7 +275,7 @@ func TestAPIHandler_GetGlucoseRecords(t *testing.T) {
 	})
 
 	t.Run("InvalidUserID", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/api/v1/glucose/invalid", nil)
+		req := getWithInitData("/api/v1/glucose/invalid", testInitData(123456789, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -287,7 +297,7 @@ func TestAPIHandler_GetGlucoseStats(t *testing.T) {
 		testutils.CreateTestGlucoseRecord(db, user.ID, 7.0)
 		testutils.CreateTestGlucoseRecord(db, user.ID, 8.0)
 		
-		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/glucose/%d/stats", user.ID), nil)
+		req := getWithInitData(fmt.Sprintf("/api/v1/glucose/%d/stats", user.TelegramID), testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -313,7 +323,6 @@ func TestAPIHandler_CreateFoodRecord(t *testing.T) {
 		user := testutils.CreateTestUser(db, 123456789)
 		
 		recordData := map[string]interface{}{
-			"user_id":   user.ID,
 			"food_name": "Овсянка с ягодами",
 			"food_type": "завтрак",
 			"carbs":     45.5,
@@ -325,6 +334,7 @@ func TestAPIHandler_CreateFoodRecord(t *testing.T) {
 		body, _ := json.Marshal(recordData)
 		req := httptest.NewRequest("POST", "/api/v1/food", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -338,9 +348,9 @@ func TestAPIHandler_CreateFoodRecord(t *testing.T) {
 		assert.Equal(t, user.ID, response.UserID)
 		assert.Equal(t, "Овсянка с ягодами", response.FoodName)
 		assert.Equal(t, "завтрак", response.FoodType)
-		assert.NotNil(t, response.Carbs)
+		require.NotNil(t, response.Carbs)
 		assert.Equal(t, 45.5, *response.Carbs)
-		assert.NotNil(t, response.Calories)
+		require.NotNil(t, response.Calories)
 		assert.Equal(t, 280, *response.Calories)
 	})
 
@@ -348,7 +358,6 @@ func TestAPIHandler_CreateFoodRecord(t *testing.T) {
 		user := testutils.CreateTestUser(db, 123456790)
 		
 		recordData := map[string]interface{}{
-			"user_id":   user.ID,
 			"food_name": "Яблоко",
 			"food_type": "перекус",
 		}
@@ -356,6 +365,7 @@ func TestAPIHandler_CreateFoodRecord(t *testing.T) {
 		body, _ := json.Marshal(recordData)
 		req := httptest.NewRequest("POST", "/api/v1/food", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -371,13 +381,13 @@ func TestAPIHandler_CreateFoodRecord(t *testing.T) {
 		user := testutils.CreateTestUser(db, 123456791)
 		
 		recordData := map[string]interface{}{
-			"user_id":   user.ID,
 			"food_type": "завтрак",
 		}
 		
 		body, _ := json.Marshal(recordData)
 		req := httptest.NewRequest("POST", "/api/v1/food", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -397,18 +407,18 @@ func TestAPIHandler_GetFoodRecords(t *testing.T) {
 		testutils.CreateTestFoodRecord(db, user.ID, "Обед", "обед")
 		testutils.CreateTestFoodRecord(db, user.ID, "Ужин", "ужин")
 		
-		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/food/%d", user.ID), nil)
+		req := getWithInitData(fmt.Sprintf("/api/v1/food/%d", user.TelegramID), testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
 		
 		assert.Equal(t, http.StatusOK, w.Code)
 		
-		var response []models.FoodRecord
+		var response services.Page[models.FoodRecord]
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 		
-		assert.Len(t, response, 3)
+		assert.Len(t, response.Items, 3)
 	})
 
 	t.Run("FilterByFoodType", func(t *testing.T) {
@@ -418,19 +428,19 @@ func TestAPIHandler_GetFoodRecords(t *testing.T) {
 		testutils.CreateTestFoodRecord(db, user.ID, "Завтрак 2", "завтрак")
 		testutils.CreateTestFoodRecord(db, user.ID, "Обед", "обед")
 		
-		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/food/%d?type=завтрак", user.ID), nil)
+		req := getWithInitData(fmt.Sprintf("/api/v1/food/%d?type=завтрак", user.TelegramID), testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
 		
 		assert.Equal(t, http.StatusOK, w.Code)
 		
-		var response []models.FoodRecord
+		var response services.Page[models.FoodRecord]
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 		
-		assert.Len(t, response, 2)
-		for _, record := range response {
+		assert.Len(t, response.Items, 2)
+		for _, record := range response.Items {
 			assert.Equal(t, "завтрак", record.FoodType)
 		}
 	})
@@ -445,7 +455,6 @@ func TestAPIHandler_UpdateGlucoseRecord(t *testing.T) {
 		record := testutils.CreateTestGlucoseRecord(db, user.ID, 6.0)
 		
 		updateData := map[string]interface{}{
-			"user_id": user.ID,
 			"value":   7.2,
 			"notes":   "Исправленное значение",
 		}
@@ -453,6 +462,7 @@ func TestAPIHandler_UpdateGlucoseRecord(t *testing.T) {
 		body, _ := json.Marshal(updateData)
 		req := httptest.NewRequest("PUT", fmt.Sprintf("/api/v1/glucose/%d", record.ID), bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -469,7 +479,8 @@ func TestAPIHandler_DeleteGlucoseRecord(t *testing.T) {
 		user := testutils.CreateTestUser(db, 123456789)
 		record := testutils.CreateTestGlucoseRecord(db, user.ID, 6.0)
 		
-		req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/glucose/%d?user_id=%d", record.ID, user.ID), nil)
+		req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/glucose/%d", record.ID), nil)
+		req.Header.Set(InitDataHeader, testInitData(user.TelegramID, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
@@ -477,15 +488,168 @@ func TestAPIHandler_DeleteGlucoseRecord(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
-	t.Run("MissingUserID", func(t *testing.T) {
-		user := testutils.CreateTestUser(db, 123456789)
+	t.Run("OtherUsersRecord", func(t *testing.T) {
+		user := testutils.CreateTestUser(db, 123456780)
 		record := testutils.CreateTestGlucoseRecord(db, user.ID, 6.0)
+		testutils.CreateTestUser(db, 987654321)
 		
 		req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/glucose/%d", record.ID), nil)
+		req.Header.Set(InitDataHeader, testInitData(987654321, time.Now()))
 		w := httptest.NewRecorder()
 		
 		router.ServeHTTP(w, req)
 		
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
-}
\ No newline at end of file
+}
+func TestAPIHandler_ListPagination(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	user := testutils.CreateTestUser(db, 555000111)
+	base := time.Now().Add(-10 * time.Hour).UTC().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		context := ""
+		if i%2 == 0 {
+			context = models.GlucoseContextFasting
+		}
+		require.NoError(t, db.Create(&models.GlucoseRecord{
+			UserID: user.ID, Value: float64(5 + i), MeasuredAt: base.Add(time.Duration(i) * time.Hour), MeasurementContext: context,
+		}).Error)
+	}
+
+	get := func(query string) (*httptest.ResponseRecorder, services.Page[models.GlucoseRecord]) {
+		req := getWithInitData("/api/v1/glucose/555000111?"+query, testInitData(user.TelegramID, time.Now()))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var page services.Page[models.GlucoseRecord]
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		}
+		return w, page
+	}
+
+	t.Run("FollowsCursor", func(t *testing.T) {
+		var values []float64
+		query := "limit=2&sort=asc"
+		for {
+			w, page := get(query)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			for _, r := range page.Items {
+				values = append(values, r.Value)
+			}
+			if page.NextCursor == nil {
+				break
+			}
+			query = "limit=2&sort=asc&cursor=" + url.QueryEscape(*page.NextCursor)
+		}
+		assert.Equal(t, []float64{5, 6, 7, 8, 9}, values)
+	})
+
+	t.Run("DateRangeAndContext", func(t *testing.T) {
+		from := url.QueryEscape(base.Add(time.Hour).Format(time.RFC3339))
+		to := url.QueryEscape(base.Add(4 * time.Hour).Format(time.RFC3339))
+
+		w, page := get("from=" + from + "&to=" + to)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Len(t, page.Items, 3)
+		assert.Equal(t, 8.0, page.Items[0].Value)
+
+		w, page = get("from=" + from + "&context=fasting")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Len(t, page.Items, 2)
+		assert.Equal(t, 9.0, page.Items[0].Value)
+		assert.Equal(t, 7.0, page.Items[1].Value)
+	})
+
+	t.Run("RejectsInvalidParams", func(t *testing.T) {
+		for _, query := range []string{
+			"days=0",
+			"days=abc",
+			"days=366",
+			"limit=0",
+			"limit=100000",
+			"sort=up",
+			"from=yesterday",
+			"to=2024-01-01",
+			"cursor=garbage",
+			"context=lunch",
+			"days=7&from=2024-01-01T00:00:00Z",
+			"from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z",
+		} {
+			w, _ := get(query)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("FoodEnvelope", func(t *testing.T) {
+		testutils.CreateTestFoodRecord(db, user.ID, "Каша", "завтрак")
+
+		req := getWithInitData("/api/v1/food/555000111?type=завтрак&limit=1", testInitData(user.TelegramID, time.Now()))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var body map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Contains(t, body, "items")
+		assert.JSONEq(t, "null", string(body["next_cursor"]))
+	})
+}
+
+func TestAPIHandler_DeleteUserData(t *testing.T) {
+	router, handler, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	owner := testutils.CreateTestUser(db, 777000111)
+	testutils.CreateTestUser(db, 777000222)
+	testutils.CreateTestGlucoseRecord(db, owner.ID, 6.2)
+	testutils.CreateTestFoodRecord(db, owner.ID, "Каша", "завтрак")
+	_, err := handler.labService.Create(owner.ID, services.LabInput{Kind: models.LabKindHbA1c, Value: 6.8})
+	require.NoError(t, err)
+	_, err = handler.vitalService.Create(owner.ID, services.VitalInput{Kind: models.VitalKindWeight, Value: 82})
+	require.NoError(t, err)
+	_, err = handler.insulinService.CreateRecord(owner.ID, 6, models.InsulinTypeBolus, "", time.Now(), "")
+	require.NoError(t, err)
+	_, err = handler.digestService.Generate(owner.ID)
+	require.NoError(t, err)
+	_, err = handler.linkService.Create(owner.ID, services.PublicLinkOptions{TTL: time.Hour})
+	require.NoError(t, err)
+	parent := testutils.CreateTestUser(db, 777000333)
+	invite, err := handler.sharingService.CreateInvite(owner.ID, models.ShareRoleRead)
+	require.NoError(t, err)
+	_, err = handler.sharingService.AcceptInvite(parent.ID, invite.Token)
+	require.NoError(t, err)
+
+	remove := func(initData string) int {
+		req := httptest.NewRequest("DELETE", "/api/v1/user/777000111/data", nil)
+		if initData != "" {
+			req.Header.Set(InitDataHeader, initData)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	count := func(model interface{}) int64 {
+		var n int64
+		require.NoError(t, db.Model(model).Where("user_id = ?", owner.ID).Count(&n).Error)
+		return n
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, remove(""))
+	assert.Equal(t, http.StatusForbidden, remove(testInitData(777000222, time.Now())))
+	assert.Equal(t, int64(1), count(&models.LabResult{}))
+	assert.Equal(t, int64(1), count(&models.Vital{}))
+
+	assert.Equal(t, http.StatusOK, remove(testInitData(owner.TelegramID, time.Now())))
+	for _, model := range []interface{}{&models.GlucoseRecord{}, &models.FoodRecord{}, &models.InsulinRecord{},
+		&models.LabResult{}, &models.Vital{}, &models.Digest{}, &models.PublicLink{}} {
+		assert.Zero(t, count(model), "%T", model)
+	}
+	// Доступ родственнику и профиль остаются
+	shares, err := handler.sharingService.Accounts(parent)
+	require.NoError(t, err)
+	assert.Len(t, shares, 2)
+	_, err = handler.userService.GetByTelegramID(owner.TelegramID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InitDataHeader — заголовок с initData Telegram WebApp
+const InitDataHeader = "X-Telegram-Init-Data"
+
+// initDataMaxAge — сколько действительна подпись initData
+const initDataMaxAge = 24 * time.Hour
+
+const telegramIDKey = "telegram_id"
+
+var (
+	errInitDataMissing = errors.New("init data is missing")
+	errInitDataInvalid = errors.New("init data signature is invalid")
+	errInitDataExpired = errors.New("init data is expired")
+)
+
+// unauthorized — запрос без действительной подписи Telegram
+func unauthorized(err error) *APIError {
+	return &APIError{Status: http.StatusUnauthorized, Code: CodeUnauthorized, err: err}
+}
+
+// TelegramAuth проверяет подпись initData Telegram WebApp и сохраняет
+// telegram_id пользователя в контексте запроса (см. authTelegramID).
+func TelegramAuth(botToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		telegramID, err := validateInitData(c.GetHeader(InitDataHeader), botToken, time.Now())
+		if err != nil {
+			fail(c, unauthorized(err))
+			return
+		}
+		c.Set(telegramIDKey, telegramID)
+		c.Next()
+	}
+}
+
+// authTelegramID возвращает telegram_id, проверенный TelegramAuth
+func authTelegramID(c *gin.Context) int64 {
+	return c.GetInt64(telegramIDKey)
+}
+
+// validateInitData проверяет initData по алгоритму Telegram:
+// hash = HMAC-SHA256(data_check_string, HMAC-SHA256(bot_token, "WebAppData")),
+// где data_check_string — остальные поля key=value, отсортированные по ключу и разделенные \n.
+func validateInitData(initData, botToken string, now time.Time) (int64, error) {
+	if initData == "" || botToken == "" {
+		return 0, errInitDataMissing
+	}
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return 0, errInitDataInvalid
+	}
+
+	hash := values.Get("hash")
+	if hash == "" {
+		return 0, errInitDataInvalid
+	}
+	expected := signInitData(values, botToken)
+	if !hmac.Equal([]byte(hash), []byte(expected)) {
+		return 0, errInitDataInvalid
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil || now.Sub(time.Unix(authDate, 0)) > initDataMaxAge {
+		return 0, errInitDataExpired
+	}
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(values.Get("user")), &user); err != nil || user.ID == 0 {
+		return 0, errInitDataInvalid
+	}
+	return user.ID, nil
+}
+
+// signInitData вычисляет hash для полей initData, кроме самого hash
+func signInitData(values url.Values, botToken string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + values.Get(key)
+	}
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testInitData подписывает initData так же, как Telegram, для пользователя telegramID
+func testInitData(telegramID int64, authDate time.Time) string {
+	values := url.Values{}
+	values.Set("query_id", "AAHdF6IQAAAAAN0XohDhrOrc")
+	values.Set("user", fmt.Sprintf(`{"id":%d,"first_name":"Test","language_code":"ru"}`, telegramID))
+	values.Set("auth_date", strconv.FormatInt(authDate.Unix(), 10))
+	values.Set("hash", signInitData(values, testutils.FakeBotToken))
+	return values.Encode()
+}
+
+func TestValidateInitData(t *testing.T) {
+	now := time.Now()
+
+	id, err := validateInitData(testInitData(42, now), testutils.FakeBotToken, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), id)
+
+	_, err = validateInitData("", testutils.FakeBotToken, now)
+	assert.ErrorIs(t, err, errInitDataMissing)
+
+	_, err = validateInitData(testInitData(42, now), "654321:OTHER-TOKEN", now)
+	assert.ErrorIs(t, err, errInitDataInvalid)
+
+	tampered := strings.Replace(testInitData(42, now), "%22id%22%3A42", "%22id%22%3A43", 1)
+	_, err = validateInitData(tampered, testutils.FakeBotToken, now)
+	assert.ErrorIs(t, err, errInitDataInvalid)
+
+	_, err = validateInitData(testInitData(42, now.Add(-25*time.Hour)), testutils.FakeBotToken, now)
+	assert.ErrorIs(t, err, errInitDataExpired)
+}
+
+func TestAPIHandler_Export(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	user := testutils.CreateTestUser(db, 313131)
+	testutils.CreateTestGlucoseRecord(db, user.ID, 6.4)
+	testutils.CreateTestFoodRecord(db, user.ID, "Омлет", "завтрак")
+	other := testutils.CreateTestUser(db, 414141)
+	testutils.CreateTestGlucoseRecord(db, other.ID, 12.5)
+
+	export := func(query, initData string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/export"+query, nil)
+		if initData != "" {
+			req.Header.Set(InitDataHeader, initData)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		w := export("", testInitData(313131, time.Now()))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), `attachment; filename="diabetbot-`)
+
+		rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(w.Body.String(), "\ufeff"))).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+		body := w.Body.String()
+		assert.Contains(t, body, "6.4")
+		assert.Contains(t, body, "Омлет")
+		assert.NotContains(t, body, "12.5")
+	})
+
+	t.Run("JSONWithRange", func(t *testing.T) {
+		from := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+		w := export("?format=json&from="+from, testInitData(313131, time.Now()))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `"entries":[]`)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		w := export("", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, CodeUnauthorized, decodeError(t, w).Code)
+
+		w = export("", testInitData(313131, time.Now().Add(-48*time.Hour)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		w := export("?format=xml", testInitData(313131, time.Now()))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+		apiErr := decodeError(t, w)
+		require.Len(t, apiErr.Details, 1)
+		assert.Equal(t, "format", apiErr.Details[0].Field)
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		w := export("", testInitData(999, time.Now()))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	// Ошибка до начала записи файла приходит обычным JSON без заголовков выгрузки
+	t.Run("StorageError", func(t *testing.T) {
+		require.NoError(t, db.Exec("DROP TABLE food_records").Error)
+		w := export("", testInitData(313131, time.Now()))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, CodeInternal, decodeError(t, w).Code)
+	})
+}
+
+func TestAPIHandler_Report(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	user := testutils.CreateTestUser(db, 515151)
+	testutils.CreateTestGlucoseRecord(db, user.ID, 3.4)
+	testutils.CreateTestGlucoseRecord(db, user.ID, 7.9)
+
+	report := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/report"+query, nil)
+		req.Header.Set(InitDataHeader, testInitData(515151, time.Now()))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := report("")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `attachment; filename="diabetbot-report-`)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "%PDF-"))
+
+	from := url.QueryEscape(time.Now().AddDate(0, 0, -7).Format(time.RFC3339))
+	assert.Equal(t, http.StatusOK, report("?from="+from).Code)
+
+	w = report("?from=" + from + "&days=7")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "days", decodeError(t, w).Details[0].Field)
+
+	to := url.QueryEscape(time.Now().AddDate(0, 0, -10).Format(time.RFC3339))
+	assert.Equal(t, http.StatusBadRequest, report("?from="+from+"&to="+to).Code)
+}
+
+const importTestCSV = "Дата;Время;Глюкоза\n" +
+	"14.02.2024;07:30;5,6\n" +
+	"14.02.2024;13:00;8,9\n"
+
+// importRequest собирает multipart запрос импорта с файлом meter.csv
+func importRequest(t *testing.T, csvData string, fields map[string]string, initData string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, form.WriteField(name, value))
+	}
+	if csvData != "" {
+		file, err := form.CreateFormFile("file", "meter.csv")
+		require.NoError(t, err)
+		_, err = file.Write([]byte(csvData))
+		require.NoError(t, err)
+	}
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest("POST", "/api/v1/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set(InitDataHeader, initData)
+	return req
+}
+
+func TestAPIHandler_Import(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	user := testutils.CreateTestUser(db, 616161)
+	initData := testInitData(616161, time.Now())
+	send := func(req *http.Request) (*httptest.ResponseRecorder, models.ImportJob) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var job models.ImportJob
+		if w.Code < 300 {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job), w.Body.String())
+		}
+		return w, job
+	}
+	countRecords := func() int64 {
+		var count int64
+		db.Model(&models.GlucoseRecord{}).Where("user_id = ?", user.ID).Count(&count)
+		return count
+	}
+
+	w, job := send(importRequest(t, importTestCSV, map[string]string{"dry_run": "true", "timezone": "Europe/Moscow"}, initData))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.ImportStatusPreview, job.Status)
+	assert.Equal(t, models.ImportSourceAPI, job.Source)
+	assert.Equal(t, "meter.csv", job.FileName)
+	assert.Equal(t, 2, job.Total)
+	require.NotNil(t, job.FirstAt)
+	assert.Equal(t, time.Date(2024, 2, 14, 4, 30, 0, 0, time.UTC), job.FirstAt.UTC())
+	assert.Zero(t, countRecords())
+
+	w, job = send(importRequest(t, importTestCSV, map[string]string{
+		"time_column": "Время", "date_column": "Дата", "value_column": "Глюкоза", "unit": "mmol/L",
+	}, initData))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, 2, job.Imported)
+	assert.Equal(t, int64(2), countRecords())
+
+	w, found := send(getWithInitData(fmt.Sprintf("/api/v1/import/%d", job.ID), initData))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, job.ID, found.ID)
+
+	// Чужой импорт не виден
+	testutils.CreateTestUser(db, 616162)
+	w, _ = send(getWithInitData(fmt.Sprintf("/api/v1/import/%d", job.ID), testInitData(616162, time.Now())))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, tt := range []struct {
+		name   string
+		csv    string
+		fields map[string]string
+		field  string
+	}{
+		{"без файла", "", nil, "file"},
+		{"нераспознанный файл", "a,b\n1,2\n", nil, "file"},
+		{"неизвестный формат", importTestCSV, map[string]string{"format": "xml"}, "format"},
+		{"неизвестный часовой пояс", importTestCSV, map[string]string{"timezone": "Mars/Olympus"}, "timezone"},
+		{"колонка глюкозы без времени", importTestCSV, map[string]string{"value_column": "Глюкоза"}, "time_column"},
+		{"dry_run не bool", importTestCSV, map[string]string{"dry_run": "maybe"}, "dry_run"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := send(importRequest(t, tt.csv, tt.fields, initData))
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			assert.Equal(t, tt.field, decodeError(t, w).Details[0].Field)
+		})
+	}
+
+	w, _ = send(importRequest(t, importTestCSV, nil, ""))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func fhirImportRequest(body, contentType, initData string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/v1/import/fhir", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(InitDataHeader, initData)
+	return req
+}
+
+func TestAPIHandler_FHIR(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	user := testutils.CreateTestUser(db, 626262)
+	testutils.CreateTestGlucoseRecord(db, user.ID, 6.4)
+	testutils.CreateTestFoodRecord(db, user.ID, "Омлет", "завтрак")
+	target := testutils.CreateTestUser(db, 636363)
+	targetInitData := testInitData(636363, time.Now())
+	send := func(req *http.Request) (*httptest.ResponseRecorder, models.ImportJob) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var job models.ImportJob
+		if w.Code < 300 {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job), w.Body.String())
+		}
+		return w, job
+	}
+
+	w, _ := send(getWithInitData("/api/v1/export?format=fhir", testInitData(626262, time.Now())))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/fhir+json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `.fhir.json"`)
+	bundle := w.Body.String()
+	assert.Contains(t, bundle, `"resourceType":"Bundle"`)
+
+	// Выгрузку одного пользователя можно загрузить другому
+	w, job := send(fhirImportRequest(bundle, "application/fhir+json", targetInitData))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "fhir", job.Format)
+	assert.Equal(t, 2, job.Total)
+	assert.Equal(t, 2, job.Imported)
+	var glucose models.GlucoseRecord
+	require.NoError(t, db.Where("user_id = ?", target.ID).First(&glucose).Error)
+	assert.Equal(t, 6.4, glucose.Value)
+	var food models.FoodRecord
+	require.NoError(t, db.Where("user_id = ?", target.ID).First(&food).Error)
+	assert.Equal(t, "Омлет", food.FoodName)
+
+	// Повторная загрузка файлом не создает записей
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "diary.fhir.json")
+	require.NoError(t, err)
+	_, err = file.Write([]byte(bundle))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+	w, job = send(fhirImportRequest(body.String(), form.FormDataContentType(), targetInitData))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "diary.fhir.json", job.FileName)
+	assert.Zero(t, job.Imported)
+	assert.Equal(t, 2, job.Duplicates)
+
+	for _, tt := range []struct {
+		name        string
+		body        string
+		contentType string
+	}{
+		{"не Bundle", `{"resourceType": "Patient"}`, "application/fhir+json"},
+		{"не JSON", "date,glucose\n", "application/json"},
+		{"без измерений", `{"resourceType": "Bundle", "entry": []}`, "application/json"},
+		{"multipart без файла", "--x--\r\n", "multipart/form-data; boundary=x"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := send(fhirImportRequest(tt.body, tt.contentType, targetInitData))
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			assert.Equal(t, "file", decodeError(t, w).Details[0].Field)
+		})
+	}
+
+	w, _ = send(fhirImportRequest(bundle, "application/fhir+json", ""))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func getWithInitData(target, initData string) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set(InitDataHeader, initData)
+	return req
+}
+
+func TestAPIHandler_GlucoseBatch(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	user := testutils.CreateTestUser(db, 626262)
+	initData := testInitData(626262, time.Now())
+	post := func(body string, initData string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/glucose/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if initData != "" {
+			req.Header.Set(InitDataHeader, initData)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	base := time.Now().Add(-time.Hour).UTC().Truncate(time.Minute)
+	body := fmt.Sprintf(`{"device_id": "Dexcom G7", "readings": [
+		{"value": 6.1, "measured_at": %q},
+		{"value": 6.4, "measured_at": %q},
+		{"value": 55, "measured_at": %q}
+	]}`, base.Format(time.RFC3339), base.Add(5*time.Minute).Format(time.RFC3339), base.Add(10*time.Minute).Format(time.RFC3339))
+
+	w := post(body, initData)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"received": 3, "inserted": 2, "duplicates": 0, "skipped": 1}`, w.Body.String())
+
+	var records []models.GlucoseRecord
+	require.NoError(t, db.Where("user_id = ?", user.ID).Find(&records).Error)
+	require.Len(t, records, 2)
+	for _, record := range records {
+		assert.Equal(t, models.GlucoseSourceCGM, record.Source)
+		assert.Equal(t, "Dexcom G7", record.DeviceID)
+	}
+
+	w = post(body, initData)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"received": 3, "inserted": 0, "duplicates": 2, "skipped": 1}`, w.Body.String())
+
+	for _, tt := range []struct {
+		name, body, field string
+	}{
+		{"NoReadings", `{"readings": []}`, "readings"},
+		{"UnknownSource", `{"source": "pump", "readings": [{"value": 5, "measured_at": "2024-01-01T00:00:00Z"}]}`, "source"},
+		{"MissingTime", `{"readings": [{"value": 5}]}`, "measured_at"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.body, initData)
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			assert.Equal(t, tt.field, decodeError(t, w).Details[0].Field)
+		})
+	}
+
+	w = post(body, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAPIHandler_GlucoseSeries(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	user := testutils.CreateTestUser(db, 636363)
+	initData := testInitData(636363, time.Now())
+	moscow := time.FixedZone("MSK", 3*3600)
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, moscow)
+	for i, value := range []float64{5.0, 6.0, 10.0} {
+		record := models.GlucoseRecord{UserID: user.ID, Value: value, MeasuredAt: day.Add(time.Duration(i) * 20 * time.Minute), Source: models.GlucoseSourceCGM}
+		require.NoError(t, db.Create(&record).Error)
+	}
+	fingerstick := models.GlucoseRecord{UserID: user.ID, Value: 7.5, MeasuredAt: day.Add(23 * time.Hour), Source: models.GlucoseSourceFingerstick}
+	require.NoError(t, db.Create(&fingerstick).Error)
+
+	series := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, getWithInitData("/api/v1/glucose/series"+query, initData))
+		return w
+	}
+	period := "?from=" + url.QueryEscape(day.Format(time.RFC3339)) + "&to=" + url.QueryEscape(day.Add(24*time.Hour).Format(time.RFC3339))
+
+	w := series(period + "&bucket=1d&timezone=Europe/Moscow")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Bucket string        `json:"bucket"`
+		Points []seriesPoint `json:"points"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "1d", response.Bucket)
+	require.Len(t, response.Points, 1)
+	assert.Equal(t, int64(4), response.Points[0].Count)
+	assert.Equal(t, 7.1, response.Points[0].Average)
+	assert.Equal(t, "2024-06-01T00:00:00+03:00", response.Points[0].Start)
+
+	w = series(period + "&source=cgm")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "1h", response.Bucket)
+	require.Len(t, response.Points, 1)
+	assert.Equal(t, int64(3), response.Points[0].Count)
+	assert.Equal(t, 10.0, response.Points[0].Max)
+
+	for query, field := range map[string]string{
+		"?bucket=15m":            "bucket",
+		"?source=pump":           "source",
+		"?timezone=Mars/Olympus": "timezone",
+		"?from=2024-01-01T00:00:00Z&to=2024-03-01T00:00:00Z&bucket=5m": "bucket",
+	} {
+		w := series(query)
+		require.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Equal(t, field, decodeError(t, w).Details[0].Field, query)
+	}
+}
+
+// seriesPoint — точка ряда в том виде, в каком ее видит клиент
+type seriesPoint struct {
+	Start   string  `json:"start"`
+	Count   int64   `json:"count"`
+	Average float64 `json:"average"`
+	Max     float64 `json:"max"`
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDigests возвращает сохраненные еженедельные сводки, сначала новые
+func (h *APIHandler) GetDigests(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	limit := services.DefaultPageLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > services.MaxPageLimit {
+			fail(c, services.ErrInvalidLimit)
+			return
+		}
+	}
+
+	digests, err := h.digestService.List(user.ID, limit)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, digests)
+}
+
+// GetDigestSettings возвращает подписку на еженедельную сводку; без сохраненной — выключена
+func (h *APIHandler) GetDigestSettings(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	sub, err := h.digestService.Settings(user.ID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// UpdateDigestSettings включает или выключает еженедельную сводку. Пустой timezone —
+// часовой пояс сервера.
+func (h *APIHandler) UpdateDigestSettings(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	var req struct {
+		Enabled  *bool  `json:"enabled" binding:"required"`
+		Timezone string `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+
+	sub, err := h.digestService.UpdateSettings(user.ID, *req.Enabled, req.Timezone)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
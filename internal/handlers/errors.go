@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrorCode — машинно-читаемый код ошибки API
+type ErrorCode string
+
+const (
+	CodeBadRequest   ErrorCode = "bad_request"
+	CodeValidation   ErrorCode = "validation_error"
+	CodeUnauthorized ErrorCode = "unauthorized"
+	CodeNotFound     ErrorCode = "not_found"
+	CodeForbidden    ErrorCode = "forbidden"
+	CodeInternal     ErrorCode = "internal_error"
+)
+
+// RequestIDHeader передает ID запроса клиенту и принимается от прокси
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "request_id"
+
+// FieldError описывает ошибку в конкретном поле запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// APIError — тело ошибки API: {"error": {"code", "message", "details", "request_id"}}
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+
+	resource string // что не найдено, для сообщения not_found
+	err      error  // исходная ошибка, пишется в лог и не отдается клиенту
+}
+
+func (e *APIError) Error() string {
+	if e.err != nil {
+		return string(e.Code) + ": " + e.err.Error()
+	}
+	return string(e.Code)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+type errorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// notFound сообщает, что ресурс (user, glucose_record, food_record) не найден
+func notFound(resource string) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, resource: resource}
+}
+
+// invalidParam — некорректный параметр пути или запроса
+func invalidParam(field string) error {
+	return &services.ValidationError{Field: field, Rule: "invalid"}
+}
+
+// fail передает ошибку в ErrorHandler и прерывает обработку запроса
+func fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// ErrorHandler назначает запросу ID и превращает ошибки, переданные через
+// c.Error, в единый ответ. Ошибки сервисов соответствуют статусам:
+// ErrValidation — 400, ErrForbidden — 403, ErrNotFound — 404, остальные — 500.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		c.Set(requestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		apiErr := toAPIError(err, languageOf(c))
+		apiErr.RequestID = requestID
+		if apiErr.Status >= http.StatusInternalServerError {
+			log.Printf("API error [%s] %s %s: %v", requestID, c.Request.Method, c.Request.URL.Path, err)
+		}
+		c.JSON(apiErr.Status, errorResponse{Error: apiErr})
+	}
+}
+
+func toAPIError(err error, lang string) *APIError {
+	var apiErr *APIError
+	var validationErrs validator.ValidationErrors
+	var fieldErr *services.ValidationError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &apiErr):
+		result := *apiErr
+		if result.Message == "" {
+			result.Message = message(lang, string(result.Code), result.resource)
+		}
+		return &result
+	case errors.As(err, &validationErrs):
+		details := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			details = append(details, fieldError(lang, fe.Field(), fe.Tag(), fe.Param()))
+		}
+		return validationError(lang, details, err)
+	case errors.As(err, &fieldErr):
+		return validationError(lang, []FieldError{fieldError(lang, fieldErr.Field, fieldErr.Rule, fieldErr.Param)}, err)
+	case errors.As(err, &typeErr):
+		return validationError(lang, []FieldError{fieldError(lang, typeErr.Field, "type", "")}, err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: message(lang, "invalid_json", ""), err: err}
+	case errors.Is(err, services.ErrValidation):
+		return validationError(lang, nil, err)
+	case errors.Is(err, services.ErrNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: message(lang, string(CodeNotFound), ""), err: err}
+	case errors.Is(err, services.ErrForbidden):
+		return &APIError{Status: http.StatusForbidden, Code: CodeForbidden, Message: message(lang, string(CodeForbidden), ""), err: err}
+	default:
+		return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message(lang, string(CodeInternal), ""), err: err}
+	}
+}
+
+func validationError(lang string, details []FieldError, err error) *APIError {
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    CodeValidation,
+		Message: message(lang, string(CodeValidation), ""),
+		Details: details,
+		err:     err,
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
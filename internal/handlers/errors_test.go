@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) APIError {
+	var body struct {
+		Error APIError `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return body.Error
+}
+
+func TestErrorHandler_Validation(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	testutils.CreateTestUser(db, 700000002)
+	initData := testInitData(700000002, time.Now())
+
+	t.Run("FieldDetails", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"value": 45})
+		req := httptest.NewRequest("POST", "/api/v1/glucose", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		apiErr := decodeError(t, w)
+		assert.Equal(t, CodeValidation, apiErr.Code)
+		assert.Equal(t, "Please check the submitted data", apiErr.Message)
+		require.Len(t, apiErr.Details, 1)
+		assert.Equal(t, FieldError{Field: "value", Rule: "max", Message: "Must be at most 30"}, apiErr.Details[0])
+		assert.NotEmpty(t, apiErr.RequestID)
+	})
+
+	t.Run("RussianByDefault", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"notes": "натощак"})
+		req := httptest.NewRequest("POST", "/api/v1/glucose", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		apiErr := decodeError(t, w)
+		require.Len(t, apiErr.Details, 1)
+		assert.Equal(t, "value", apiErr.Details[0].Field)
+		assert.Equal(t, "required", apiErr.Details[0].Rule)
+		assert.Equal(t, "Обязательное поле", apiErr.Details[0].Message)
+	})
+
+	t.Run("QueryParameter", func(t *testing.T) {
+		testutils.CreateTestUser(db, 700000001)
+		req := getWithInitData("/api/v1/glucose/700000001?days=1000", testInitData(700000001, time.Now()))
+		req.Header.Set("X-Telegram-Language-Code", "en")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		apiErr := decodeError(t, w)
+		require.Len(t, apiErr.Details, 1)
+		assert.Equal(t, FieldError{Field: "days", Rule: "between", Message: "Must be between 1 and 365"}, apiErr.Details[0])
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/glucose", bytes.NewBufferString("{"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, CodeBadRequest, decodeError(t, w).Code)
+	})
+
+	t.Run("WrongType", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/glucose", bytes.NewBufferString(`{"value": "high"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		apiErr := decodeError(t, w)
+		require.Len(t, apiErr.Details, 1)
+		assert.Equal(t, "value", apiErr.Details[0].Field)
+		assert.Equal(t, "type", apiErr.Details[0].Rule)
+	})
+}
+
+func TestErrorHandler_NotFound(t *testing.T) {
+	router, _, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	req := getWithInitData("/api/v1/glucose/999999999", testInitData(999999999, time.Now()))
+	req.Header.Set(RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "req-42", w.Header().Get(RequestIDHeader))
+	apiErr := decodeError(t, w)
+	assert.Equal(t, CodeNotFound, apiErr.Code)
+	assert.Equal(t, "Пользователь не найден", apiErr.Message)
+	assert.Equal(t, "req-42", apiErr.RequestID)
+}
+
+func TestErrorHandler_ServiceErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+
+	errs := map[string]error{
+		"/forbidden": services.ErrForbidden,
+		"/missing":   services.ErrNotFound,
+		"/internal":  errors.New("database is locked"),
+	}
+	for path, err := range errs {
+		err := err
+		router.GET(path, func(c *gin.Context) { fail(c, err) })
+	}
+
+	cases := []struct {
+		path   string
+		status int
+		code   ErrorCode
+	}{
+		{"/forbidden", http.StatusForbidden, CodeForbidden},
+		{"/missing", http.StatusNotFound, CodeNotFound},
+		{"/internal", http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+
+		assert.Equal(t, tc.status, w.Code, tc.path)
+		apiErr := decodeError(t, w)
+		assert.Equal(t, tc.code, apiErr.Code, tc.path)
+		assert.NotContains(t, w.Body.String(), "database is locked")
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInsights возвращает закономерности в истории глюкозы за последние days дней
+// вместе с измерениями, на которых они основаны
+func (h *APIHandler) GetInsights(c *gin.Context) {
+	user, err := h.ownerFromPath(c, "user_id", models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	days, err := parseDays(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	now := time.Now()
+	report, err := h.insightService.Analyze(user.ID, services.InsightOptions{From: now.AddDate(0, 0, -days), To: now})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
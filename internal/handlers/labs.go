@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// labResultRequest — тело создания и изменения результата анализа
+type labResultRequest struct {
+	Kind    string     `json:"kind" binding:"required"`
+	Name    string     `json:"name"`
+	Value   *float64   `json:"value" binding:"required"`
+	Unit    string     `json:"unit"`
+	TakenAt *time.Time `json:"taken_at"`
+	Notes   string     `json:"notes"`
+}
+
+func (r labResultRequest) input() services.LabInput {
+	input := services.LabInput{Kind: r.Kind, Name: r.Name, Value: *r.Value, Unit: r.Unit, Notes: r.Notes}
+	if r.TakenAt != nil {
+		input.TakenAt = *r.TakenAt
+	}
+	return input
+}
+
+// GetLabResults возвращает страницу результатов анализов, сначала новые.
+// Анализы сдают раз в несколько месяцев, поэтому без from и days период не ограничен.
+func (h *APIHandler) GetLabResults(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	if _, ok := c.GetQuery("days"); !ok && c.Query("from") == "" {
+		opts.From = time.Time{}
+	}
+
+	page, err := h.labService.List(user.ID, opts, c.Query("kind"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// CreateLabResult сохраняет результат анализа; без unit — основная единица вида, без taken_at — сейчас
+func (h *APIHandler) CreateLabResult(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	var req labResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+
+	result, err := h.labService.Create(user.ID, req.input())
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+func (h *APIHandler) GetLabResult(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	resultID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	result, err := h.labService.Get(user.ID, resultID)
+	if err != nil {
+		fail(c, recordError(err, "lab_result"))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdateLabResult целиком перезаписывает результат анализа
+func (h *APIHandler) UpdateLabResult(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	resultID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	var req labResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+
+	result, err := h.labService.Update(user.ID, resultID, req.input())
+	if err != nil {
+		fail(c, recordError(err, "lab_result"))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *APIHandler) DeleteLabResult(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	resultID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if err := h.labService.Delete(user.ID, resultID); err != nil {
+		fail(c, recordError(err, "lab_result"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Lab result deleted successfully"})
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDays = 30  // Период по умолчанию, если не заданы from и days
+	maxDays     = 365 // Максимальный период для параметра days
+)
+
+// parseDays разбирает параметр days; отсутствие параметра дает defaultDays
+func parseDays(c *gin.Context) (int, error) {
+	daysStr := c.Query("days")
+	if daysStr == "" {
+		return defaultDays, nil
+	}
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days < 1 || days > maxDays {
+		return 0, &services.ValidationError{Field: "days", Rule: "between", Param: fmt.Sprintf("1 %d", maxDays)}
+	}
+	return days, nil
+}
+
+// parseTimeRange разбирает необязательные границы периода from и to в RFC3339
+func parseTimeRange(c *gin.Context) (from, to time.Time, err error) {
+	for _, param := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, to, &services.ValidationError{Field: param.name, Rule: "rfc3339"}
+		}
+		*param.target = t
+	}
+	return from, to, nil
+}
+
+// parseListOptions разбирает параметры списков: from и to (RFC3339), days,
+// limit, cursor и sort (desc или asc). Без from и days список ограничен defaultDays;
+// с cursor период берется из него, поэтому from от days не сдвигается между страницами.
+func parseListOptions(c *gin.Context) (services.ListOptions, error) {
+	var opts services.ListOptions
+
+	var err error
+	if opts.From, opts.To, err = parseTimeRange(c); err != nil {
+		return opts, err
+	}
+
+	if _, ok := c.GetQuery("days"); ok && !opts.From.IsZero() {
+		return opts, &services.ValidationError{Field: "days", Rule: "exclusive", Param: "from"}
+	}
+	if opts.From.IsZero() {
+		days, err := parseDays(c)
+		if err != nil {
+			return opts, err
+		}
+		opts.From = time.Now().AddDate(0, 0, -days)
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > services.MaxPageLimit {
+			return opts, services.ErrInvalidLimit
+		}
+		opts.Limit = limit
+	}
+
+	switch c.DefaultQuery("sort", "desc") {
+	case "desc":
+	case "asc":
+		opts.Ascending = true
+	default:
+		return opts, &services.ValidationError{Field: "sort", Rule: "oneof", Param: "asc desc"}
+	}
+
+	opts.Cursor = c.Query("cursor")
+	return opts, nil
+}
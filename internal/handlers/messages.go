@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const defaultLanguage = "ru"
+
+// messages — тексты ошибок API. Ключи: код ошибки, код.ресурс и rule.правило;
+// %s в правилах заменяются параметрами правила.
+var messages = map[string]map[string]string{
+	"ru": {
+		"bad_request":              "Некорректный запрос",
+		"invalid_json":             "Тело запроса должно быть корректным JSON",
+		"validation_error":         "Проверьте введенные данные",
+		"not_found":                "Не найдено",
+		"not_found.user":           "Пользователь не найден",
+		"not_found.glucose_record": "Запись глюкозы не найдена",
+		"not_found.food_record":    "Запись о питании не найдена",
+		"not_found.import_job":     "Импорт не найден",
+		"not_found.share":          "Доступ не найден",
+		"not_found.alert":          "Тревога не найдена",
+		"not_found.public_link":    "Ссылка не найдена",
+		"not_found.lab_result":     "Результат анализа не найден",
+		"not_found.vital":          "Измерение не найдено",
+		"unauthorized":             "Откройте приложение из Telegram, чтобы подтвердить вход",
+		"unauthorized.nightscout":  "Неверный API secret Nightscout: получите новый командой /nightscout в боте",
+		"forbidden":                "Нет доступа",
+		"internal_error":           "Внутренняя ошибка сервера, попробуйте позже",
+		"rule.required":            "Обязательное поле",
+		"rule.min":                 "Значение должно быть не меньше %s",
+		"rule.max":                 "Значение должно быть не больше %s",
+		"rule.between":             "Значение должно быть от %s до %s",
+		"rule.oneof":               "Допустимые значения: %s",
+		"rule.invalid":             "Некорректное значение",
+		"rule.type":                "Неверный тип значения",
+		"rule.rfc3339":             "Ожидается дата и время в формате RFC3339",
+		"rule.after":               "Должно быть позже, чем %s",
+		"rule.exclusive":           "Нельзя указывать вместе с %s",
+		"rule.file_format":         "Формат файла не распознан: поддерживаются выгрузки LibreView, Dexcom Clarity и CSV с датой и глюкозой",
+		"rule.no_readings":         "В файле нет измерений глюкозы",
+		"rule.file_size":           "Файл должен быть не больше %s",
+		"rule.max_points":          "Слишком много точек: увеличьте шаг или сократите период (не больше %s)",
+		"rule.fhir_bundle":         "Ожидается ресурс FHIR Bundle в формате JSON",
+		"rule.max_shares":          "Не больше %s доступов и приглашений: отзовите ненужные",
+		"rule.time_of_day":         "Ожидается время в формате ЧЧ:ММ",
+		"rule.max_links":           "Не больше %s действующих ссылок: отзовите ненужные",
+		"rule.not_future":          "Дата не может быть в будущем",
+		"rule.ltfield":             "Должно быть меньше, чем %s",
+	},
+	"en": {
+		"bad_request":              "Bad request",
+		"invalid_json":             "Request body must be valid JSON",
+		"validation_error":         "Please check the submitted data",
+		"not_found":                "Not found",
+		"not_found.user":           "User not found",
+		"not_found.glucose_record": "Glucose record not found",
+		"not_found.food_record":    "Food record not found",
+		"not_found.import_job":     "Import not found",
+		"not_found.share":          "Share not found",
+		"not_found.alert":          "Alert not found",
+		"not_found.public_link":    "Link not found",
+		"not_found.lab_result":     "Lab result not found",
+		"not_found.vital":          "Measurement not found",
+		"unauthorized":             "Open the app from Telegram to sign in",
+		"unauthorized.nightscout":  "Invalid Nightscout API secret: get a new one with the /nightscout bot command",
+		"forbidden":                "Access denied",
+		"internal_error":           "Internal server error, please try again later",
+		"rule.required":            "This field is required",
+		"rule.min":                 "Must be at least %s",
+		"rule.max":                 "Must be at most %s",
+		"rule.between":             "Must be between %s and %s",
+		"rule.oneof":               "Allowed values: %s",
+		"rule.invalid":             "Invalid value",
+		"rule.type":                "Wrong value type",
+		"rule.rfc3339":             "Expected an RFC3339 date and time",
+		"rule.after":               "Must be later than %s",
+		"rule.exclusive":           "Cannot be combined with %s",
+		"rule.file_format":         "Unrecognized file: LibreView, Dexcom Clarity and CSV files with date and glucose columns are supported",
+		"rule.no_readings":         "No glucose readings found in the file",
+		"rule.file_size":           "File must be at most %s",
+		"rule.max_points":          "Too many points: use a larger bucket or a shorter period (at most %s)",
+		"rule.fhir_bundle":         "Expected a FHIR Bundle resource in JSON",
+		"rule.max_shares":          "At most %s shares and invites: revoke the ones you no longer need",
+		"rule.time_of_day":         "Expected a time of day as HH:MM",
+		"rule.max_links":           "At most %s active links: revoke the ones you no longer need",
+		"rule.not_future":          "Date cannot be in the future",
+		"rule.ltfield":             "Must be less than %s",
+	},
+}
+
+func init() {
+	// В деталях ошибок поля называются так же, как в JSON
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// languageOf выбирает язык ответа по языку Telegram или Accept-Language
+func languageOf(c *gin.Context) string {
+	for _, header := range []string{c.GetHeader("X-Telegram-Language-Code"), c.GetHeader("Accept-Language")} {
+		if len(header) >= 2 {
+			if _, ok := messages[strings.ToLower(header[:2])]; ok {
+				return strings.ToLower(header[:2])
+			}
+		}
+	}
+	return defaultLanguage
+}
+
+// message возвращает текст для кода ошибки, уточненный ресурсом, если он известен
+func message(lang, code, resource string) string {
+	catalog := messages[lang]
+	if text, ok := catalog[code+"."+resource]; ok && resource != "" {
+		return text
+	}
+	if text, ok := catalog[code]; ok {
+		return text
+	}
+	return messages[defaultLanguage][code]
+}
+
+func fieldError(lang, field, rule, param string) FieldError {
+	text, ok := messages[lang]["rule."+rule]
+	if !ok {
+		text = messages[lang]["rule.invalid"]
+	}
+
+	var args []interface{}
+	switch strings.Count(text, "%s") {
+	case 0:
+	case 1:
+		if rule == "oneof" {
+			param = strings.Join(strings.Fields(param), ", ")
+		}
+		args = append(args, param)
+	default:
+		for _, p := range strings.Fields(param) {
+			args = append(args, p)
+		}
+	}
+
+	return FieldError{Field: field, Rule: rule, Message: fmt.Sprintf(text, args...)}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NightscoutSecretHeader — заголовок с SHA1 API secret, который отправляют xDrip+ и AAPS
+const NightscoutSecretHeader = "api-secret"
+
+// nightscoutVersion — версия Nightscout, с которой совместимы ответы; клиенты сверяют ее в /status
+const nightscoutVersion = "15.0.2"
+
+// nightscoutBodyBytes — предел тела загрузки: xDrip+ досылает пропущенные показания пачками
+const nightscoutBodyBytes = 4 << 20
+
+const nightscoutUserKey = "nightscout_user_id"
+
+// NightscoutHandler реализует часть Nightscout REST API v1: entries, treatments и status.
+// Маршруты повторяют Nightscout, а не остальной API, поэтому не описаны в openapi.json.
+type NightscoutHandler struct {
+	nightscoutService *services.NightscoutService
+}
+
+func NewNightscoutHandler(svc *services.Services) *NightscoutHandler {
+	return &NightscoutHandler{nightscoutService: svc.Nightscout}
+}
+
+// RegisterRoutes подключает эндпоинты Nightscout к группе /api/v1
+func (h *NightscoutHandler) RegisterRoutes(api *gin.RouterGroup) {
+	api.GET("/status", h.Status)
+	api.GET("/status.json", h.Status)
+
+	auth := h.Auth()
+	api.GET("/verifyauth", auth, h.VerifyAuth)
+	for _, path := range []string{"/entries", "/entries.json", "/entries/sgv", "/entries/sgv.json"} {
+		api.GET(path, auth, h.GetEntries)
+	}
+	api.GET("/entries/current", auth, h.GetCurrentEntry)
+	api.GET("/entries/current.json", auth, h.GetCurrentEntry)
+	api.POST("/entries", auth, h.CreateEntries)
+	api.POST("/entries.json", auth, h.CreateEntries)
+
+	api.GET("/treatments", auth, h.GetTreatments)
+	api.GET("/treatments.json", auth, h.GetTreatments)
+	api.POST("/treatments", auth, h.CreateTreatments)
+	api.POST("/treatments.json", auth, h.CreateTreatments)
+}
+
+// Auth проверяет API secret из заголовка api-secret или параметра token
+// и сохраняет ID пользователя в контексте запроса
+func (h *NightscoutHandler) Auth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader(NightscoutSecretHeader)
+		if secret == "" {
+			secret = c.Query("token")
+		}
+		user, err := h.nightscoutService.Authenticate(secret)
+		if err != nil {
+			if errors.Is(err, services.ErrForbidden) {
+				err = &APIError{Status: http.StatusUnauthorized, Code: CodeUnauthorized, resource: "nightscout", err: err}
+			}
+			fail(c, err)
+			return
+		}
+		c.Set(nightscoutUserKey, user.ID)
+		c.Next()
+	}
+}
+
+func nightscoutUserID(c *gin.Context) uint {
+	return c.GetUint(nightscoutUserKey)
+}
+
+// Status сообщает клиентам, что сервер совместим с Nightscout
+func (h *NightscoutHandler) Status(c *gin.Context) {
+	now := time.Now()
+	c.JSON(http.StatusOK, gin.H{
+		"status":            "ok",
+		"name":              "diabetbot",
+		"version":           nightscoutVersion,
+		"serverTime":        now.UTC().Format(time.RFC3339),
+		"serverTimeEpoch":   now.UnixMilli(),
+		"apiEnabled":        true,
+		"careportalEnabled": true,
+		"settings": gin.H{
+			"units":  "mmol",
+			"enable": []string{"careportal", "iob", "cob"},
+		},
+	})
+}
+
+// VerifyAuth отвечает на проверку API secret в настройках xDrip+ и AAPS
+func (h *NightscoutHandler) VerifyAuth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"message": gin.H{
+			"canRead":  true,
+			"canWrite": true,
+			"message":  "OK",
+		},
+	})
+}
+
+func (h *NightscoutHandler) GetEntries(c *gin.Context) {
+	query, err := nightscoutQuery(c, "date", "dateString")
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	h.writeEntries(c, query)
+}
+
+// GetCurrentEntry возвращает последнее измерение
+func (h *NightscoutHandler) GetCurrentEntry(c *gin.Context) {
+	h.writeEntries(c, services.NightscoutQuery{Count: 1})
+}
+
+func (h *NightscoutHandler) writeEntries(c *gin.Context, query services.NightscoutQuery) {
+	entries, err := h.nightscoutService.Entries(nightscoutUserID(c), query)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func (h *NightscoutHandler) CreateEntries(c *gin.Context) {
+	var entries []services.NightscoutEntry
+	if err := bindNightscoutBody(c, &entries); err != nil {
+		fail(c, err)
+		return
+	}
+
+	saved, err := h.nightscoutService.AddEntries(nightscoutUserID(c), entries)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+func (h *NightscoutHandler) GetTreatments(c *gin.Context) {
+	query, err := nightscoutQuery(c, "mills", "created_at")
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	treatments, err := h.nightscoutService.Treatments(nightscoutUserID(c), query)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, treatments)
+}
+
+func (h *NightscoutHandler) CreateTreatments(c *gin.Context) {
+	var treatments []services.NightscoutTreatment
+	if err := bindNightscoutBody(c, &treatments); err != nil {
+		fail(c, err)
+		return
+	}
+
+	saved, err := h.nightscoutService.AddTreatments(nightscoutUserID(c), treatments)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+// bindNightscoutBody разбирает массив документов; одиночный объект считается массивом из одного
+func bindNightscoutBody[T any](c *gin.Context, docs *[]T) error {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, nightscoutBodyBytes))
+	if err != nil {
+		return invalidParam("body")
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '{' {
+		var doc T
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return err
+		}
+		*docs = []T{doc}
+		return nil
+	}
+	return json.Unmarshal(body, docs)
+}
+
+// nightscoutQuery разбирает count и условия find[поле][$gte|$gt|$lte|$lt]. Время задается
+// в миллисекундах в поле msField или в ISO 8601 в поле isoField.
+func nightscoutQuery(c *gin.Context, msField, isoField string) (services.NightscoutQuery, error) {
+	var query services.NightscoutQuery
+	if value := c.Query("count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 1 {
+			return query, invalidParam("count")
+		}
+		query.Count = count
+	}
+
+	for _, field := range []string{msField, isoField} {
+		for _, op := range []string{"$gte", "$gt", "$lte", "$lt"} {
+			name := "find[" + field + "][" + op + "]"
+			value := c.Query(name)
+			if value == "" {
+				continue
+			}
+			at, err := parseNightscoutParam(value, field == msField)
+			if err != nil {
+				return query, invalidParam(name)
+			}
+			// Границы выборки: From включается, To нет
+			switch op {
+			case "$gte":
+				query.From = at
+			case "$gt":
+				query.From = at.Add(time.Millisecond)
+			case "$lte":
+				query.To = at.Add(time.Millisecond)
+			case "$lt":
+				query.To = at
+			}
+		}
+	}
+	return query, nil
+}
+
+func parseNightscoutParam(value string, millis bool) (time.Time, error) {
+	if millis {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(ms), nil
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupNightscoutRouter подключает Nightscout API рядом с основным, как в приложении
+func setupNightscoutRouter(t *testing.T) (*gin.Engine, *services.Services, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+	db := testutils.SetupTestDB(t)
+	t.Cleanup(func() { testutils.CleanupTestDB(db) })
+	svc := services.New(repository.NewGorm(db))
+
+	router := gin.New()
+	router.Use(ErrorHandler())
+	NewAPIHandler(svc, testutils.FakeBotToken).RegisterRoutes(router.Group("/api/v1"))
+	NewNightscoutHandler(svc).RegisterRoutes(router.Group("/api/v1"))
+	return router, svc, db
+}
+
+func nightscoutRequest(router *gin.Engine, method, target, secret, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if secret != "" {
+		req.Header.Set(NightscoutSecretHeader, secret)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNightscoutHandler_Status(t *testing.T) {
+	router, _, _ := setupNightscoutRouter(t)
+
+	w := nightscoutRequest(router, "GET", "/api/v1/status.json", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var status struct {
+		Status   string `json:"status"`
+		Version  string `json:"version"`
+		Settings struct {
+			Units string `json:"units"`
+		} `json:"settings"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "ok", status.Status)
+	assert.NotEmpty(t, status.Version)
+	assert.Equal(t, "mmol", status.Settings.Units)
+}
+
+func TestNightscoutHandler_Auth(t *testing.T) {
+	router, svc, db := setupNightscoutRouter(t)
+	user := testutils.CreateTestUser(db, 515151)
+	secret, err := svc.Nightscout.EnableAPI(user.ID)
+	require.NoError(t, err)
+
+	w := nightscoutRequest(router, "GET", "/api/v1/entries.json", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "/nightscout")
+
+	w = nightscoutRequest(router, "GET", "/api/v1/entries.json", services.NightscoutSecretHash("wrong"), "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = nightscoutRequest(router, "GET", "/api/v1/verifyauth", strings.ToUpper(services.NightscoutSecretHash(secret)), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"canWrite":true`)
+
+	w = nightscoutRequest(router, "GET", "/api/v1/entries/current.json?token="+secret, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
+
+func TestNightscoutHandler_Entries(t *testing.T) {
+	router, svc, db := setupNightscoutRouter(t)
+	user := testutils.CreateTestUser(db, 525252)
+	other := testutils.CreateTestUser(db, 535353)
+	secret, err := svc.Nightscout.EnableAPI(user.ID)
+	require.NoError(t, err)
+	hash := services.NightscoutSecretHash(secret)
+	testutils.CreateTestGlucoseRecord(db, other.ID, 12.5)
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	// xDrip+ загружает массив, одиночный объект тоже принимается
+	upload := fmt.Sprintf(`[
+		{"device": "xDrip-LibreOOP", "date": %d, "dateString": "ignored", "sgv": 108, "direction": "Flat", "type": "sgv", "noise": 1},
+		{"device": "xDrip-LibreOOP", "date": %d, "sgv": 117, "direction": "FortyFiveUp", "type": "sgv"}
+	]`, base.UnixMilli(), base.Add(5*time.Minute).UnixMilli())
+	w := nightscoutRequest(router, "POST", "/api/v1/entries", hash, upload)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var saved []services.NightscoutEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+	assert.Len(t, saved, 2)
+
+	w = nightscoutRequest(router, "POST", "/api/v1/entries.json", hash,
+		fmt.Sprintf(`{"type": "mbg", "mbg": 126, "date": %d}`, base.Add(20*time.Minute).UnixMilli()))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Повтор загрузки не создает записей
+	w = nightscoutRequest(router, "POST", "/api/v1/entries", hash, upload)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	var count int64
+	db.Model(&models.GlucoseRecord{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	w = nightscoutRequest(router, "GET", "/api/v1/entries/sgv.json?count=2", hash, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var entries []services.NightscoutEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, 126.0, entries[0].SGV)
+	assert.Equal(t, "FortyFiveUp", entries[1].Direction)
+
+	target := fmt.Sprintf("/api/v1/entries.json?find[date][$gte]=%d&find[date][$lt]=%d", base.UnixMilli(), base.Add(10*time.Minute).UnixMilli())
+	w = nightscoutRequest(router, "GET", target, hash, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 2)
+
+	w = nightscoutRequest(router, "GET", "/api/v1/entries.json?count=abc", hash, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = nightscoutRequest(router, "GET", "/api/v1/entries.json?find[dateString][$gte]=вчера", hash, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = nightscoutRequest(router, "POST", "/api/v1/entries", hash, `[{"sgv": "высокий"}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNightscoutHandler_Treatments(t *testing.T) {
+	router, svc, db := setupNightscoutRouter(t)
+	user := testutils.CreateTestUser(db, 545454)
+	secret, err := svc.Nightscout.EnableAPI(user.ID)
+	require.NoError(t, err)
+	hash := services.NightscoutSecretHash(secret)
+
+	at := time.Now().Add(-3 * time.Hour).UTC().Truncate(time.Second)
+	upload := fmt.Sprintf(`[
+		{"eventType": "Meal Bolus", "created_at": %q, "insulin": 5, "carbs": 60, "notes": "Паста", "enteredBy": "AAPS"},
+		{"eventType": "BG Check", "created_at": %q, "glucose": 144, "glucoseType": "Finger", "units": "mg/dl"},
+		{"eventType": "Temp Basal", "created_at": %q, "duration": 30, "percent": -50}
+	]`, at.Format(time.RFC3339), at.Add(-5*time.Minute).Format("2006-01-02T15:04:05.000Z"), at.Format(time.RFC3339))
+	w := nightscoutRequest(router, "POST", "/api/v1/treatments", hash, upload)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var saved []services.NightscoutTreatment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+	assert.Len(t, saved, 3)
+
+	var food models.FoodRecord
+	require.NoError(t, db.Where("user_id = ?", user.ID).First(&food).Error)
+	assert.Equal(t, "Паста", food.FoodName)
+	require.NotNil(t, food.Carbs)
+	assert.Equal(t, 60.0, *food.Carbs)
+	var injection models.InsulinRecord
+	require.NoError(t, db.Where("user_id = ?", user.ID).First(&injection).Error)
+	assert.Equal(t, 5.0, injection.Units)
+	var reading models.GlucoseRecord
+	require.NoError(t, db.Where("user_id = ?", user.ID).First(&reading).Error)
+	assert.Equal(t, 8.0, reading.Value)
+
+	target := "/api/v1/treatments.json?find[created_at][$gte]=" + at.Add(-time.Minute).Format(time.RFC3339)
+	w = nightscoutRequest(router, "GET", target, hash, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var treatments []services.NightscoutTreatment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &treatments))
+	require.Len(t, treatments, 2)
+	for _, treatment := range treatments {
+		assert.Equal(t, at.UnixMilli(), treatment.Mills)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPISpec — спецификация OpenAPI 3 для /api/v1.
+// Ответы обработчиков сверяются с ней в openapi_test.go.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// docsPage показывает спецификацию через Redoc
+const docsPage = `<!DOCTYPE html>
+<html lang="ru">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>DiabetBot API</title>
+</head>
+<body>
+  <redoc spec-url="openapi.json"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// ServeOpenAPI отдает спецификацию API
+func ServeOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", OpenAPISpec)
+}
+
+// ServeDocs отдает страницу документации API
+func ServeDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// Страница документации и CSV выгрузка проверяются как строки
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.PlainBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/csv", openapi3filter.PlainBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/pdf", openapi3filter.FileBodyDecoder)
+}
+
+func loadOpenAPISpec(t *testing.T) *openapi3.T {
+	doc, err := openapi3.NewLoader().LoadFromData(OpenAPISpec)
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+	return doc
+}
+
+func TestOpenAPISpec_CoversRoutes(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewAPIHandler(services.New(repository.NewGorm(nil)), "").RegisterRoutes(router.Group("/api/v1"))
+
+	// Имена параметров в gin и в спецификации могут различаться, сравниваем только форму пути
+	ginParam := regexp.MustCompile(`:[^/]+`)
+	specParam := regexp.MustCompile(`\{[^/]+\}`)
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		path := ginParam.ReplaceAllString(strings.TrimPrefix(route.Path, "/api/v1"), "{}")
+		registered[route.Method+" "+path] = true
+	}
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+specParam.ReplaceAllString(path, "{}")] = true
+		}
+	}
+
+	assert.Equal(t, registered, documented)
+}
+
+// contractClient выполняет запросы к обработчикам и сверяет ответы со спецификацией
+type contractClient struct {
+	router *gin.Engine
+	routes routers.Router
+}
+
+func (cc *contractClient) do(t *testing.T, method, target string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return cc.doAs(t, 0, method, target, body)
+}
+
+// doAs отправляет запрос с initData пользователя telegramID; 0 — без initData
+func (cc *contractClient) doAs(t *testing.T, telegramID int64, method, target string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(reqBody))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if telegramID != 0 {
+		req.Header.Set(InitDataHeader, testInitData(telegramID, time.Now()))
+	}
+	return cc.send(t, req)
+}
+
+func (cc *contractClient) send(t *testing.T, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	cc.router.ServeHTTP(w, req)
+
+	route, pathParams, err := cc.routes.FindRoute(req)
+	require.NoError(t, err, "%s %s не описан в спецификации", req.Method, req.URL)
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: w.Code,
+		Header: w.Header(),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			MultiError:            true,
+		},
+	}
+	input.SetBodyBytes(w.Body.Bytes())
+	err = openapi3filter.ValidateResponse(context.Background(), input)
+	assert.NoError(t, err, "%s %s -> %d: %s", req.Method, req.URL, w.Code, w.Body.String())
+	return w
+}
+
+func TestOpenAPIContract(t *testing.T) {
+	// Выгрузка FHIR отдается как application/fhir+json, по сути это JSON
+	openapi3filter.RegisterBodyDecoder("application/fhir+json", openapi3filter.JSONBodyDecoder)
+	doc := loadOpenAPISpec(t)
+	routes, err := legacy.NewRouter(doc)
+	require.NoError(t, err)
+
+	router, handler, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+	cc := &contractClient{router: router, routes: routes}
+
+	const telegramID = 424242
+	user := testutils.CreateTestUser(db, telegramID)
+	userPath := fmt.Sprintf("/api/v1/user/%d", telegramID)
+	// Чтение дневника доступно владельцу и тем, кому он открыт через /share
+	get := func(target string) *httptest.ResponseRecorder {
+		return cc.send(t, getWithInitData(target, testInitData(telegramID, time.Now())))
+	}
+	// Изменять можно свой дневник или открытый на запись
+	write := func(method, target string, body interface{}) *httptest.ResponseRecorder {
+		return cc.doAs(t, telegramID, method, target, body)
+	}
+
+	t.Run("Meta", func(t *testing.T) {
+		w := cc.do(t, "GET", "/api/v1/openapi.json", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, string(OpenAPISpec), w.Body.String())
+
+		w = cc.do(t, "GET", "/api/v1/docs", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "openapi.json")
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get(userPath).Code)
+		assert.Equal(t, http.StatusCreated, cc.send(t, getWithInitData("/api/v1/user/515151", testInitData(515151, time.Now()))).Code)
+		assert.Equal(t, http.StatusBadRequest, get("/api/v1/user/abc").Code)
+		assert.Equal(t, http.StatusForbidden, get("/api/v1/user/515151").Code)
+		assert.Equal(t, http.StatusUnauthorized, cc.do(t, "GET", userPath, nil).Code)
+
+		assert.Equal(t, http.StatusOK, write("PUT", userPath, map[string]interface{}{"target_glucose": 6.5, "notifications": false}).Code)
+		assert.Equal(t, http.StatusBadRequest, write("PUT", userPath, map[string]interface{}{"target_glucose": 40}).Code)
+		assert.Equal(t, http.StatusForbidden, write("PUT", "/api/v1/user/515151", map[string]interface{}{"notifications": false}).Code)
+		assert.Equal(t, http.StatusUnauthorized, cc.do(t, "PUT", userPath, map[string]interface{}{"notifications": false}).Code)
+
+		diabetesInfo := map[string]interface{}{"diabetes_type": 1, "target_glucose": 7}
+		assert.Equal(t, http.StatusOK, write("PUT", userPath+"/diabetes-info", diabetesInfo).Code)
+		assert.Equal(t, http.StatusNotFound, cc.doAs(t, 1, "PUT", "/api/v1/user/1/diabetes-info", diabetesInfo).Code)
+		assert.Equal(t, http.StatusForbidden, write("PUT", "/api/v1/user/1/diabetes-info", diabetesInfo).Code)
+	})
+
+	t.Run("Glucose", func(t *testing.T) {
+		w := write("POST", "/api/v1/glucose", map[string]interface{}{"value": 6.2, "notes": "после обеда"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var record models.GlucoseRecord
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &record))
+		write("POST", "/api/v1/glucose", map[string]interface{}{"value": 7.4})
+
+		assert.Equal(t, http.StatusBadRequest, write("POST", "/api/v1/glucose", map[string]interface{}{"value": 99}).Code)
+		assert.Equal(t, http.StatusForbidden, write("POST", "/api/v1/glucose?owner_id=1", map[string]interface{}{"value": 5}).Code)
+		assert.Equal(t, http.StatusNotFound, cc.doAs(t, 1, "POST", "/api/v1/glucose", map[string]interface{}{"value": 5}).Code)
+		assert.Equal(t, http.StatusUnauthorized, cc.do(t, "POST", "/api/v1/glucose", map[string]interface{}{"value": 5}).Code)
+
+		w = get(fmt.Sprintf("/api/v1/glucose/%d?limit=1", telegramID))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"next_cursor":"`)
+		assert.Equal(t, http.StatusOK, get(fmt.Sprintf("/api/v1/glucose/%d?context=fasting", telegramID)).Code)
+		assert.Equal(t, http.StatusBadRequest, get(fmt.Sprintf("/api/v1/glucose/%d?sort=up", telegramID)).Code)
+
+		assert.Equal(t, http.StatusOK, get(fmt.Sprintf("/api/v1/glucose/%d/stats?days=7", telegramID)).Code)
+
+		recordPath := fmt.Sprintf("/api/v1/glucose/%d", record.ID)
+		assert.Equal(t, http.StatusOK, write("PUT", recordPath, map[string]interface{}{"value": 6.0}).Code)
+		assert.Equal(t, http.StatusNotFound, write("PUT", "/api/v1/glucose/999999", map[string]interface{}{"value": 6.0}).Code)
+		// Запись чужого дневника не найдется даже по известному ID
+		assert.Equal(t, http.StatusNotFound, cc.doAs(t, 515151, "DELETE", recordPath, nil).Code)
+		assert.Equal(t, http.StatusOK, write("DELETE", recordPath, nil).Code)
+		assert.Equal(t, http.StatusNotFound, write("DELETE", recordPath, nil).Code)
+	})
+
+	t.Run("Food", func(t *testing.T) {
+		w := write("POST", "/api/v1/food", map[string]interface{}{
+			"food_name": "Гречка", "food_type": "обед", "carbs": 45.5, "calories": 320,
+		})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var record models.FoodRecord
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &record))
+		assert.Equal(t, http.StatusCreated, write("POST", "/api/v1/food", map[string]interface{}{
+			"food_name": "Яблоко", "food_type": "перекус",
+		}).Code)
+		assert.Equal(t, http.StatusBadRequest, write("POST", "/api/v1/food", map[string]interface{}{"notes": "без названия"}).Code)
+
+		assert.Equal(t, http.StatusOK, get(fmt.Sprintf("/api/v1/food/%d?sort=asc", telegramID)).Code)
+		assert.Equal(t, http.StatusBadRequest, get(fmt.Sprintf("/api/v1/food/%d?cursor=broken", telegramID)).Code)
+		assert.Equal(t, http.StatusForbidden, get("/api/v1/food/1").Code)
+
+		recordPath := fmt.Sprintf("/api/v1/food/%d", record.ID)
+		assert.Equal(t, http.StatusOK, write("PUT", recordPath, map[string]interface{}{"carbs": 40}).Code)
+		assert.Equal(t, http.StatusBadRequest, write("DELETE", recordPath+"?owner_id=abc", nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, cc.do(t, "DELETE", recordPath, nil).Code)
+		assert.Equal(t, http.StatusOK, write("DELETE", recordPath, nil).Code)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, write("POST", "/api/v1/glucose", map[string]interface{}{"value": 5.8}).Code)
+
+		export := func(query, initData string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/api/v1/export"+query, nil)
+			req.Header.Set(InitDataHeader, initData)
+			return cc.send(t, req)
+		}
+		initData := testInitData(telegramID, time.Now())
+		assert.Equal(t, http.StatusOK, export("", initData).Code)
+		w := export("?format=json", initData)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"type":"glucose"`)
+		assert.Equal(t, http.StatusBadRequest, export("?format=xml", initData).Code)
+		assert.Equal(t, http.StatusUnauthorized, export("", "").Code)
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		report := func(query, initData string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/api/v1/report"+query, nil)
+			req.Header.Set(InitDataHeader, initData)
+			return cc.send(t, req)
+		}
+		initData := testInitData(telegramID, time.Now())
+		assert.Equal(t, http.StatusOK, report("?days=14", initData).Code)
+		assert.Equal(t, http.StatusBadRequest, report("?days=400", initData).Code)
+		assert.Equal(t, http.StatusUnauthorized, report("", "").Code)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		w := cc.send(t, importRequest(t, importTestCSV, map[string]string{"dry_run": "true"}, initData))
+		require.Equal(t, http.StatusOK, w.Code)
+		w = cc.send(t, importRequest(t, importTestCSV, nil, initData))
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var job models.ImportJob
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/import/%d", job.ID), nil)
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusOK, cc.send(t, req).Code)
+		req = httptest.NewRequest("GET", "/api/v1/import/999999", nil)
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusNotFound, cc.send(t, req).Code)
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, importRequest(t, "a,b\n1,2\n", nil, initData)).Code)
+	})
+
+	t.Run("FHIR", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		w := cc.send(t, getWithInitData("/api/v1/export?format=fhir", initData))
+		require.Equal(t, http.StatusOK, w.Code)
+		bundle := w.Body.String()
+		w = cc.send(t, fhirImportRequest(bundle, "application/fhir+json", initData))
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, fhirImportRequest(`{"resourceType": "Patient"}`, "application/json", initData)).Code)
+		assert.Equal(t, http.StatusUnauthorized, cc.send(t, fhirImportRequest(bundle, "application/json", "")).Code)
+	})
+
+	t.Run("CGM", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		batch := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/api/v1/glucose/batch", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(InitDataHeader, initData)
+			return cc.send(t, req)
+		}
+		at := time.Now().Add(-30 * time.Minute).UTC().Format(time.RFC3339)
+		assert.Equal(t, http.StatusCreated, batch(`{"device_id": "Libre 3", "readings": [{"value": 6.3, "measured_at": "`+at+`"}]}`).Code)
+		assert.Equal(t, http.StatusBadRequest, batch(`{"readings": []}`).Code)
+
+		series := func(query string) *httptest.ResponseRecorder {
+			return cc.send(t, getWithInitData("/api/v1/glucose/series"+query, initData))
+		}
+		w := series("?bucket=5m&source=cgm")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"count":1`)
+		assert.Equal(t, http.StatusBadRequest, series("?bucket=1w").Code)
+		assert.Equal(t, http.StatusUnauthorized, cc.send(t, httptest.NewRequest("GET", "/api/v1/glucose/series", nil)).Code)
+	})
+
+	t.Run("Sharing", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		req := httptest.NewRequest("POST", "/api/v1/shares", strings.NewReader(`{"role": "read"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		w := cc.send(t, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var invite services.ShareInvite
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invite))
+
+		doctor := testutils.CreateTestUser(db, 525252)
+		_, err := services.New(repository.NewGorm(db)).Sharing.AcceptInvite(doctor.ID, invite.Token)
+		require.NoError(t, err)
+		doctorData := testInitData(doctor.TelegramID, time.Now())
+		cc.send(t, getWithInitData("/api/v1/shares", initData))
+		w = cc.send(t, getWithInitData("/api/v1/accounts", doctorData))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"role":"read"`)
+		assert.Equal(t, http.StatusOK, cc.send(t, getWithInitData(fmt.Sprintf("/api/v1/glucose/%d/stats", telegramID), doctorData)).Code)
+
+		req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/shares/%d", invite.ID), nil)
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusOK, cc.send(t, req).Code)
+		assert.Equal(t, http.StatusForbidden, cc.send(t, getWithInitData(fmt.Sprintf("/api/v1/export?owner_id=%d", telegramID), doctorData)).Code)
+	})
+
+	t.Run("Alerts", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		assert.Equal(t, http.StatusOK, cc.send(t, getWithInitData("/api/v1/alerts/rules", initData)).Code)
+
+		body := `{"enabled": true, "low_threshold": 3.5, "high_threshold": 18, "recheck_minutes": 20, "quiet_start": "23:00", "quiet_end": "07:00", "timezone": "Europe/Moscow"}`
+		req := httptest.NewRequest("PUT", "/api/v1/alerts/rules", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusOK, cc.send(t, req).Code)
+
+		req = httptest.NewRequest("PUT", "/api/v1/alerts/rules", strings.NewReader(`{"enabled": true, "low_threshold": 1, "high_threshold": 18, "recheck_minutes": 20}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, req).Code)
+
+		assert.Equal(t, http.StatusOK, cc.send(t, getWithInitData("/api/v1/alerts?limit=10", initData)).Code)
+		req = httptest.NewRequest("POST", "/api/v1/alerts/999/ack", nil)
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusNotFound, cc.send(t, req).Code)
+	})
+
+	t.Run("PublicLinks", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		req := httptest.NewRequest("POST", "/api/v1/public-links", strings.NewReader(`{"label": "Врач", "days": 14}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		w := cc.send(t, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var link services.PublicLinkCreated
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+
+		assert.Equal(t, http.StatusOK, cc.send(t, getWithInitData("/api/v1/public-links", initData)).Code)
+		assert.Equal(t, http.StatusOK, cc.send(t, getWithInitData(fmt.Sprintf("/api/v1/public-links/%d/accesses?limit=10", link.ID), initData)).Code)
+		assert.Equal(t, http.StatusNotFound, cc.send(t, getWithInitData("/api/v1/public-links/999/accesses", initData)).Code)
+
+		req = httptest.NewRequest("POST", "/api/v1/public-links", strings.NewReader(`{"expires_in_days": 60}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, req).Code)
+
+		req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/public-links/%d", link.ID), nil)
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusOK, cc.send(t, req).Code)
+	})
+
+	t.Run("Digests", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		assert.Equal(t, http.StatusOK, cc.send(t, getWithInitData("/api/v1/digests/settings", initData)).Code)
+
+		req := httptest.NewRequest("PUT", "/api/v1/digests/settings", strings.NewReader(`{"enabled": true, "timezone": "Europe/Moscow"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusOK, cc.send(t, req).Code)
+
+		req = httptest.NewRequest("PUT", "/api/v1/digests/settings", strings.NewReader(`{"enabled": true, "timezone": "Mars/Olympus"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, req).Code)
+
+		_, err := handler.digestService.Generate(user.ID)
+		require.NoError(t, err)
+		w := cc.send(t, getWithInitData("/api/v1/digests?limit=5", initData))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "summary")
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, getWithInitData("/api/v1/digests?limit=0", initData)).Code)
+	})
+
+	t.Run("Insights", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		// Ночь — по часовому поясу из настроек сводки, сохраненных выше
+		moscow, err := time.LoadLocation("Europe/Moscow")
+		require.NoError(t, err)
+		for d := 1; d <= 2; d++ {
+			night := time.Now().In(moscow).AddDate(0, 0, -d)
+			night = time.Date(night.Year(), night.Month(), night.Day(), 3, 0, 0, 0, moscow)
+			_, err := handler.glucoseService.CreateRecordAt(user.ID, 3.1, night, "", "")
+			require.NoError(t, err)
+		}
+		w := cc.send(t, getWithInitData(fmt.Sprintf("/api/v1/insights/%d?days=7", telegramID), initData))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"kind":"night_lows"`)
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, getWithInitData(fmt.Sprintf("/api/v1/insights/%d?days=0", telegramID), initData)).Code)
+		assert.Equal(t, http.StatusForbidden, cc.send(t, getWithInitData("/api/v1/insights/1", initData)).Code)
+	})
+
+	t.Run("Labs", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		send := func(method, target, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, target, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(InitDataHeader, initData)
+			return cc.send(t, req)
+		}
+
+		w := send("POST", "/api/v1/labs", `{"kind": "hba1c", "value": 6.8, "taken_at": "2024-05-10T09:00:00Z"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var result models.LabResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, "%", result.Unit)
+		assert.Equal(t, http.StatusCreated, send("POST", "/api/v1/labs", `{"kind": "custom", "name": "Калий", "value": 4.2, "unit": "ммоль/л"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/api/v1/labs", `{"kind": "hba1c", "value": 68}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/api/v1/labs", `{"kind": "hba1c"}`).Code)
+
+		w = cc.send(t, getWithInitData("/api/v1/labs?kind=hba1c", initData))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"kind":"hba1c"`)
+		assert.NotContains(t, w.Body.String(), "Калий")
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, getWithInitData("/api/v1/labs?kind=glucose", initData)).Code)
+
+		resultPath := fmt.Sprintf("/api/v1/labs/%d", result.ID)
+		assert.Equal(t, http.StatusOK, cc.send(t, getWithInitData(resultPath, initData)).Code)
+		assert.Equal(t, http.StatusNotFound, cc.send(t, getWithInitData("/api/v1/labs/999999", initData)).Code)
+		w = send("PUT", resultPath, `{"kind": "hba1c", "value": 53, "unit": "mmol/mol", "taken_at": "2024-05-10T09:00:00Z"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"unit":"mmol/mol"`)
+
+		w = get(fmt.Sprintf("/api/v1/glucose/%d/stats", telegramID))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"hba1c":{"result":`)
+
+		assert.Equal(t, http.StatusOK, send("DELETE", resultPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, send("DELETE", resultPath, "").Code)
+	})
+
+	t.Run("Vitals", func(t *testing.T) {
+		initData := testInitData(telegramID, time.Now())
+		send := func(method, target, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, target, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(InitDataHeader, initData)
+			return cc.send(t, req)
+		}
+
+		w := send("POST", "/api/v1/vitals", `{"kind": "blood_pressure", "value": 135, "diastolic": 85}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var vital models.Vital
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &vital))
+		assert.Equal(t, "mmHg", vital.Unit)
+		assert.Equal(t, http.StatusCreated, send("POST", "/api/v1/vitals", `{"kind": "ketones", "value": 0.4}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/api/v1/vitals", `{"kind": "blood_pressure", "value": 135}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/api/v1/vitals", `{"kind": "weight", "value": 82, "unit": "stone"}`).Code)
+
+		w = cc.send(t, getWithInitData("/api/v1/vitals?kind=blood_pressure", initData))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"diastolic":85`)
+		assert.NotContains(t, w.Body.String(), `"kind":"ketones"`)
+		assert.Equal(t, http.StatusBadRequest, cc.send(t, getWithInitData("/api/v1/vitals?kind=pulse", initData)).Code)
+
+		vitalPath := fmt.Sprintf("/api/v1/vitals/%d", vital.ID)
+		assert.Equal(t, http.StatusOK, cc.send(t, getWithInitData(vitalPath, initData)).Code)
+		assert.Equal(t, http.StatusNotFound, cc.send(t, getWithInitData("/api/v1/vitals/999999", initData)).Code)
+		w = send("PUT", vitalPath, `{"kind": "weight", "value": 180, "unit": "lb"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"unit":"lb"`)
+		assert.NotContains(t, w.Body.String(), "diastolic")
+
+		assert.Equal(t, http.StatusOK, send("DELETE", vitalPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, send("DELETE", vitalPath, "").Code)
+	})
+
+	t.Run("DeleteUserData", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, cc.do(t, "DELETE", userPath+"/data", nil).Code)
+		assert.Equal(t, http.StatusForbidden, write("DELETE", "/api/v1/user/515151/data", nil).Code)
+		assert.Equal(t, http.StatusNotFound, cc.doAs(t, 1, "DELETE", "/api/v1/user/1/data", nil).Code)
+		assert.Equal(t, http.StatusOK, write("DELETE", userPath+"/data", nil).Code)
+	})
+}
+
+// Ответ, не описанный в спецификации, должен ронять тест
+func TestOpenAPIContract_DetectsDrift(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+	routes, err := legacy.NewRouter(doc)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/api/v1/glucose/1/stats", nil)
+	route, pathParams, err := routes.FindRoute(req)
+	require.NoError(t, err)
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{Request: req, PathParams: pathParams, Route: route},
+		Status:                 http.StatusOK,
+		Header:                 http.Header{"Content-Type": {"application/json"}},
+		Body:                   io.NopCloser(strings.NewReader(`{"average": "high", "count": 1}`)),
+	}
+	assert.Error(t, openapi3filter.ValidateResponse(context.Background(), input))
+}
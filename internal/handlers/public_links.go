@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPublicLinks возвращает ссылки пользователя на сводку дневника, включая истекшие
+func (h *APIHandler) GetPublicLinks(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	links, err := h.linkService.List(user.ID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, links)
+}
+
+// CreatePublicLink создает ссылку /share/<token> на сводку дневника для врача без Telegram.
+// Период — from–to или последние days дней (по умолчанию 14), срок действия — expires_in_days.
+// Токен возвращается один раз.
+func (h *APIHandler) CreatePublicLink(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	var req struct {
+		Label         string     `json:"label" binding:"max=100"`
+		Days          int        `json:"days" binding:"omitempty,min=1,max=90"`
+		From          *time.Time `json:"from"`
+		To            *time.Time `json:"to"`
+		ExpiresInDays int        `json:"expires_in_days" binding:"omitempty,min=1,max=30"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+	if req.Days != 0 && req.From != nil {
+		fail(c, &services.ValidationError{Field: "days", Rule: "exclusive", Param: "from"})
+		return
+	}
+
+	opts := services.PublicLinkOptions{Label: req.Label, TTL: time.Duration(req.ExpiresInDays) * 24 * time.Hour}
+	if req.To != nil {
+		opts.To = *req.To
+	}
+	if req.From != nil {
+		opts.From = *req.From
+	} else if req.Days != 0 {
+		if opts.To.IsZero() {
+			opts.To = time.Now()
+		}
+		opts.From = opts.To.AddDate(0, 0, -req.Days)
+	}
+
+	link, err := h.linkService.Create(user.ID, opts)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, link)
+}
+
+// DeletePublicLink отзывает ссылку: страница сводки по ней больше не открывается
+func (h *APIHandler) DeletePublicLink(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	linkID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if _, err := h.linkService.Revoke(user.ID, linkID); err != nil {
+		fail(c, recordError(err, "public_link"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Public link revoked successfully"})
+}
+
+// GetPublicLinkAccesses возвращает журнал открытий ссылки, сначала новые
+func (h *APIHandler) GetPublicLinkAccesses(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	linkID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	limit := services.DefaultPageLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > services.MaxPageLimit {
+			fail(c, services.ErrInvalidLimit)
+			return
+		}
+	}
+
+	accesses, err := h.linkService.Accesses(user.ID, linkID, limit)
+	if err != nil {
+		fail(c, recordError(err, "public_link"))
+		return
+	}
+	c.JSON(http.StatusOK, accesses)
+}
+
+// PublicShareHandler отдает страницу сводки дневника по публичной ссылке. Страница
+// открывается без Telegram, поэтому ошибки показываются страницей, а не JSON API.
+type PublicShareHandler struct {
+	linkService *services.PublicLinkService
+}
+
+func NewPublicShareHandler(svc *services.Services) *PublicShareHandler {
+	return &PublicShareHandler{linkService: svc.Links}
+}
+
+// RegisterRoutes подключает страницу /share/:token
+func (h *PublicShareHandler) RegisterRoutes(router gin.IRoutes) {
+	router.GET(services.PublicLinkPathPrefix+":token", h.Show)
+}
+
+// Show проверяет токен, записывает открытие и отдает сводку в HTML
+func (h *PublicShareHandler) Show(c *gin.Context) {
+	// Токен в адресе: страница не кешируется, не индексируется и не уходит в Referer
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	summary, err := h.linkService.Open(c.Param("token"), c.ClientIP(), c.Request.UserAgent())
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		sharePage(c, http.StatusNotFound, "Ссылка недействительна", "Ссылка отозвана или набрана с ошибкой. Попросите пациента прислать новую.")
+		return
+	case errors.Is(err, services.ErrPublicLinkExpired):
+		sharePage(c, http.StatusGone, "Срок действия ссылки истек", "Попросите пациента прислать новую ссылку.")
+		return
+	case err != nil:
+		log.Printf("Public link error [%s]: %v", c.GetString(requestIDKey), err)
+		sharePage(c, http.StatusInternalServerError, "Не удалось открыть сводку", "Попробуйте обновить страницу позже.")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := services.WritePublicSummaryHTML(&buf, summary); err != nil {
+		log.Printf("Public link render error [%s]: %v", c.GetString(requestIDKey), err)
+		sharePage(c, http.StatusInternalServerError, "Не удалось открыть сводку", "Попробуйте обновить страницу позже.")
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+var sharePageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>{{.Title}}</title>
+</head>
+<body style="font-family:-apple-system,'Segoe UI',Roboto,Arial,sans-serif;max-width:600px;margin:40px auto;padding:0 16px">
+<h1 style="font-size:22px">{{.Title}}</h1>
+<p>{{.Text}}</p>
+</body>
+</html>
+`))
+
+// sharePage отдает страницу с сообщением об ошибке публичной ссылки
+func sharePage(c *gin.Context, status int, title, text string) {
+	var buf bytes.Buffer
+	if err := sharePageTemplate.Execute(&buf, struct{ Title, Text string }{title, text}); err != nil {
+		c.Status(status)
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicLinks(t *testing.T) {
+	router, handler, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+	(&PublicShareHandler{linkService: handler.linkService}).RegisterRoutes(router)
+
+	owner := testutils.CreateTestUser(db, 747401)
+	testutils.CreateTestUser(db, 747402)
+	testutils.CreateTestGlucoseRecord(db, owner.ID, 6.8)
+	ownerData := testInitData(owner.TelegramID, time.Now())
+	strangerData := testInitData(747402, time.Now())
+
+	send := func(method, target, body, initData string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	open := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", "Doctor Browser")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/v1/public-links", `{"label": "Эндокринолог", "days": 7, "expires_in_days": 3}`, ownerData)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var link services.PublicLinkCreated
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, "/share/"+link.Token, link.Path)
+	assert.WithinDuration(t, time.Now().Add(3*24*time.Hour), link.ExpiresAt, time.Minute)
+	assert.NotContains(t, w.Body.String(), "token_hash")
+
+	t.Run("OpenPage", func(t *testing.T) {
+		w := open(link.Path)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+		assert.Contains(t, w.Body.String(), "Дневник гликемии")
+		assert.Contains(t, w.Body.String(), "<svg")
+
+		w = send("GET", fmt.Sprintf("/api/v1/public-links/%d/accesses", link.ID), "", ownerData)
+		require.Equal(t, http.StatusOK, w.Code)
+		var accesses []models.PublicLinkAccess
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accesses))
+		require.Len(t, accesses, 1)
+		assert.Equal(t, "Doctor Browser", accesses[0].UserAgent)
+
+		w = send("GET", "/api/v1/public-links", "", ownerData)
+		require.Equal(t, http.StatusOK, w.Code)
+		var links []models.PublicLink
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &links))
+		require.Len(t, links, 1)
+		assert.Equal(t, 1, links[0].AccessCount)
+		assert.Equal(t, "Эндокринолог", links[0].Label)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		w := send("POST", "/api/v1/public-links", `{"days": 200}`, ownerData)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = send("POST", "/api/v1/public-links", `{"days": 7, "from": "2024-05-01T00:00:00Z"}`, ownerData)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "exclusive", decodeError(t, w).Details[0].Rule)
+		assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/v1/public-links", "", "").Code)
+	})
+
+	t.Run("StrangerCannotManage", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, send("GET", fmt.Sprintf("/api/v1/public-links/%d/accesses", link.ID), "", strangerData).Code)
+		assert.Equal(t, http.StatusNotFound, send("DELETE", fmt.Sprintf("/api/v1/public-links/%d", link.ID), "", strangerData).Code)
+		assert.Equal(t, http.StatusOK, open(link.Path).Code)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		created, err := handler.linkService.Create(owner.ID, services.PublicLinkOptions{})
+		require.NoError(t, err)
+		require.NoError(t, db.Model(&models.PublicLink{}).Where("id = ?", created.ID).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		w := open(created.Path)
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Contains(t, w.Body.String(), "истек")
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		w := send("DELETE", fmt.Sprintf("/api/v1/public-links/%d", link.ID), "", ownerData)
+		require.Equal(t, http.StatusOK, w.Code)
+		w = open(link.Path)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "недействительна")
+		assert.Equal(t, http.StatusNotFound, open("/share/unknown").Code)
+	})
+}
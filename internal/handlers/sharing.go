@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ownerIDParam — параметр запроса с telegram_id владельца открытого дневника
+const ownerIDParam = "owner_id"
+
+// diaryOwner возвращает владельца дневника telegramID, если пользователю из initData
+// разрешен доступ role: это его дневник или дневник, открытый ему через /share.
+// Чужой дневник без доступа и несуществующий пользователь одинаково дают 403.
+func (h *APIHandler) diaryOwner(c *gin.Context, telegramID int64, role string) (*models.User, error) {
+	callerID := authTelegramID(c)
+	if telegramID == callerID {
+		return h.userByTelegramID(callerID)
+	}
+	caller, err := h.userByTelegramID(callerID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := h.userService.GetByTelegramID(telegramID)
+	if errors.Is(err, services.ErrNotFound) {
+		return nil, services.ErrForbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := h.sharingService.Authorize(caller.ID, owner.ID, role); err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// ownerFromPath — diaryOwner для telegram_id из параметра пути name
+func (h *APIHandler) ownerFromPath(c *gin.Context, name, role string) (*models.User, error) {
+	telegramID, err := telegramIDParam(c, name)
+	if err != nil {
+		return nil, err
+	}
+	return h.diaryOwner(c, telegramID, role)
+}
+
+// ownerFromQuery — diaryOwner для необязательного owner_id; без него — сам пользователь
+func (h *APIHandler) ownerFromQuery(c *gin.Context, role string) (*models.User, error) {
+	value, ok := c.GetQuery(ownerIDParam)
+	if !ok {
+		return h.userByTelegramID(authTelegramID(c))
+	}
+	telegramID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, invalidParam(ownerIDParam)
+	}
+	return h.diaryOwner(c, telegramID, role)
+}
+
+// selfFromQuery — как ownerFromQuery, но чужой owner_id запрещен даже получателю доступа
+func (h *APIHandler) selfFromQuery(c *gin.Context) (*models.User, error) {
+	callerID := authTelegramID(c)
+	if value, ok := c.GetQuery(ownerIDParam); ok {
+		telegramID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, invalidParam(ownerIDParam)
+		}
+		if telegramID != callerID {
+			return nil, services.ErrForbidden
+		}
+	}
+	return h.userByTelegramID(callerID)
+}
+
+// GetAccounts возвращает дневники, которые может открыть пользователь: свой и открытые ему
+func (h *APIHandler) GetAccounts(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	accounts, err := h.sharingService.Accounts(user)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+// GetShares возвращает доступы к дневнику пользователя и действующие приглашения
+func (h *APIHandler) GetShares(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	grants, err := h.sharingService.ListGrants(user.ID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// CreateShare создает приглашение к дневнику. Токен возвращается один раз;
+// приглашенный открывает ссылку t.me/<бот>?start=share_<token>.
+func (h *APIHandler) CreateShare(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required,oneof=read write"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+
+	invite, err := h.sharingService.CreateInvite(user.ID, req.Role)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, invite)
+}
+
+// DeleteShare отзывает доступ или приглашение; получатель так отказывается от доступа
+func (h *APIHandler) DeleteShare(c *gin.Context) {
+	user, err := h.userByTelegramID(authTelegramID(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	shareID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if _, err := h.sharingService.Revoke(user.ID, shareID); err != nil {
+		fail(c, recordError(err, "share"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Share revoked successfully"})
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIHandler_Sharing(t *testing.T) {
+	router, handler, db := setupTestRouter()
+	defer testutils.CleanupTestDB(db)
+
+	owner := testutils.CreateTestUser(db, 646401)
+	parent := testutils.CreateTestUser(db, 646402)
+	doctor := testutils.CreateTestUser(db, 646403)
+	testutils.CreateTestUser(db, 646404)
+	testutils.CreateTestGlucoseRecord(db, owner.ID, 6.8)
+	ownerData := testInitData(owner.TelegramID, time.Now())
+	parentData := testInitData(parent.TelegramID, time.Now())
+	doctorData := testInitData(doctor.TelegramID, time.Now())
+	strangerData := testInitData(646404, time.Now())
+
+	send := func(method, target, body, initData string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(InitDataHeader, initData)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	invite := func(role string) services.ShareInvite {
+		w := send("POST", "/api/v1/shares", fmt.Sprintf(`{"role": %q}`, role), ownerData)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var invite services.ShareInvite
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invite))
+		return invite
+	}
+
+	// Приглашение принимается в боте по /start share_<token>
+	writeInvite := invite(models.ShareRoleWrite)
+	_, err := handler.sharingService.AcceptInvite(parent.ID, writeInvite.Token)
+	require.NoError(t, err)
+	readInvite := invite(models.ShareRoleRead)
+	doctorShare, err := handler.sharingService.AcceptInvite(doctor.ID, readInvite.Token)
+	require.NoError(t, err)
+
+	recordsPath := fmt.Sprintf("/api/v1/glucose/%d", owner.TelegramID)
+	t.Run("GranteeReads", func(t *testing.T) {
+		for _, initData := range []string{parentData, doctorData} {
+			w := send("GET", recordsPath, "", initData)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var page services.Page[models.GlucoseRecord]
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			require.Len(t, page.Items, 1)
+			assert.Equal(t, 6.8, page.Items[0].Value)
+
+			assert.Equal(t, http.StatusOK, send("GET", fmt.Sprintf("/api/v1/user/%d", owner.TelegramID), "", initData).Code)
+			assert.Equal(t, http.StatusOK, send("GET", fmt.Sprintf("/api/v1/export?owner_id=%d", owner.TelegramID), "", initData).Code)
+		}
+	})
+
+	t.Run("StrangerForbidden", func(t *testing.T) {
+		w := send("GET", recordsPath, "", strangerData)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, CodeForbidden, decodeError(t, w).Code)
+		// Несуществующий пользователь неотличим от закрытого дневника
+		assert.Equal(t, http.StatusForbidden, send("GET", "/api/v1/glucose/646499", "", strangerData).Code)
+		assert.Equal(t, http.StatusForbidden, send("GET", fmt.Sprintf("/api/v1/food/%d", owner.TelegramID), "", strangerData).Code)
+		assert.Equal(t, http.StatusForbidden, send("GET", fmt.Sprintf("/api/v1/export?owner_id=%d", owner.TelegramID), "", strangerData).Code)
+		assert.Equal(t, http.StatusBadRequest, send("GET", "/api/v1/export?owner_id=abc", "", strangerData).Code)
+	})
+
+	t.Run("RoleLimitsWrites", func(t *testing.T) {
+		at := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		body := `{"readings": [{"value": 7.1, "measured_at": "` + at + `"}]}`
+		target := fmt.Sprintf("/api/v1/glucose/batch?owner_id=%d", owner.TelegramID)
+		assert.Equal(t, http.StatusForbidden, send("POST", target, body, doctorData).Code)
+		assert.Equal(t, http.StatusCreated, send("POST", target, body, parentData).Code)
+
+		var count int64
+		require.NoError(t, db.Model(&models.GlucoseRecord{}).Where("user_id = ?", owner.ID).Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+
+		// Отдельные записи дневника — по тем же правилам
+		target = fmt.Sprintf("/api/v1/glucose?owner_id=%d", owner.TelegramID)
+		assert.Equal(t, http.StatusForbidden, send("POST", target, `{"value": 6.4}`, doctorData).Code)
+		assert.Equal(t, http.StatusForbidden, send("POST", target, `{"value": 6.4}`, strangerData).Code)
+		w := send("POST", target, `{"value": 6.4}`, parentData)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var record models.GlucoseRecord
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &record))
+		assert.Equal(t, owner.ID, record.UserID)
+
+		recordPath := fmt.Sprintf("/api/v1/glucose/%d?owner_id=%d", record.ID, owner.TelegramID)
+		assert.Equal(t, http.StatusForbidden, send("PUT", recordPath, `{"value": 6.6}`, doctorData).Code)
+		assert.Equal(t, http.StatusOK, send("PUT", recordPath, `{"value": 6.6}`, parentData).Code)
+		assert.Equal(t, http.StatusForbidden, send("DELETE", recordPath, "", doctorData).Code)
+		// Без owner_id запись ищется в своем дневнике получателя
+		assert.Equal(t, http.StatusNotFound, send("DELETE", fmt.Sprintf("/api/v1/glucose/%d", record.ID), "", parentData).Code)
+		assert.Equal(t, http.StatusOK, send("DELETE", recordPath, "", parentData).Code)
+
+		foodTarget := fmt.Sprintf("/api/v1/food?owner_id=%d", owner.TelegramID)
+		food := `{"food_name": "Каша", "food_type": "завтрак"}`
+		assert.Equal(t, http.StatusForbidden, send("POST", foodTarget, food, doctorData).Code)
+		assert.Equal(t, http.StatusCreated, send("POST", foodTarget, food, parentData).Code)
+
+		// Профиль и удаление всех данных — только владельцу, даже с доступом на запись
+		userPath := fmt.Sprintf("/api/v1/user/%d", owner.TelegramID)
+		assert.Equal(t, http.StatusForbidden, send("PUT", userPath, `{"notifications": false}`, parentData).Code)
+		assert.Equal(t, http.StatusForbidden, send("PUT", userPath+"/diabetes-info", `{"diabetes_type": 2, "target_glucose": 7}`, parentData).Code)
+		assert.Equal(t, http.StatusForbidden, send("DELETE", userPath+"/data", "", parentData).Code)
+		require.NoError(t, db.Model(&models.GlucoseRecord{}).Where("user_id = ?", owner.ID).Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Accounts", func(t *testing.T) {
+		w := send("GET", "/api/v1/accounts", "", doctorData)
+		require.Equal(t, http.StatusOK, w.Code)
+		var accounts []services.SharedAccount
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accounts))
+		require.Len(t, accounts, 2)
+		assert.Equal(t, services.ShareRoleOwner, accounts[0].Role)
+		assert.Equal(t, owner.TelegramID, accounts[1].TelegramID)
+		assert.Equal(t, models.ShareRoleRead, accounts[1].Role)
+
+		w = send("GET", "/api/v1/shares", "", ownerData)
+		require.Equal(t, http.StatusOK, w.Code)
+		var grants []services.ShareGrant
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &grants))
+		assert.Len(t, grants, 2)
+		assert.NotContains(t, w.Body.String(), "token")
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		target := fmt.Sprintf("/api/v1/shares/%d", doctorShare.ID)
+		assert.Equal(t, http.StatusNotFound, send("DELETE", target, "", strangerData).Code)
+		assert.Equal(t, http.StatusOK, send("DELETE", target, "", ownerData).Code)
+		assert.Equal(t, http.StatusForbidden, send("GET", recordsPath, "", doctorData).Code)
+		assert.Equal(t, http.StatusNotFound, send("DELETE", target, "", ownerData).Code)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		w := send("POST", "/api/v1/shares", `{"role": "admin"}`, ownerData)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "role", decodeError(t, w).Details[0].Field)
+		assert.Equal(t, http.StatusUnauthorized, send("POST", "/api/v1/shares", `{"role": "read"}`, "").Code)
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// vitalRequest — тело создания и изменения измерения веса, давления или кетонов
+type vitalRequest struct {
+	Kind       string     `json:"kind" binding:"required"`
+	Value      *float64   `json:"value" binding:"required"`
+	Diastolic  *float64   `json:"diastolic"`
+	Unit       string     `json:"unit"`
+	MeasuredAt *time.Time `json:"measured_at"`
+	Notes      string     `json:"notes"`
+}
+
+func (r vitalRequest) input() services.VitalInput {
+	input := services.VitalInput{Kind: r.Kind, Value: *r.Value, Diastolic: r.Diastolic, Unit: r.Unit, Notes: r.Notes}
+	if r.MeasuredAt != nil {
+		input.MeasuredAt = *r.MeasuredAt
+	}
+	return input
+}
+
+// GetVitals возвращает страницу измерений за период, сначала новые
+func (h *APIHandler) GetVitals(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	page, err := h.vitalService.List(user.ID, opts, c.Query("kind"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// CreateVital сохраняет измерение; без unit — основная единица вида, без measured_at — сейчас
+func (h *APIHandler) CreateVital(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	var req vitalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+
+	vital, err := h.vitalService.Create(user.ID, req.input())
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, vital)
+}
+
+func (h *APIHandler) GetVital(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleRead)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	vitalID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	vital, err := h.vitalService.Get(user.ID, vitalID)
+	if err != nil {
+		fail(c, recordError(err, "vital"))
+		return
+	}
+	c.JSON(http.StatusOK, vital)
+}
+
+// UpdateVital целиком перезаписывает измерение
+func (h *APIHandler) UpdateVital(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	vitalID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	var req vitalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, err)
+		return
+	}
+
+	vital, err := h.vitalService.Update(user.ID, vitalID, req.input())
+	if err != nil {
+		fail(c, recordError(err, "vital"))
+		return
+	}
+	c.JSON(http.StatusOK, vital)
+}
+
+func (h *APIHandler) DeleteVital(c *gin.Context) {
+	user, err := h.ownerFromQuery(c, models.ShareRoleWrite)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	vitalID, err := recordIDParam(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if err := h.vitalService.Delete(user.ID, vitalID); err != nil {
+		fail(c, recordError(err, "vital"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Vital deleted successfully"})
+}
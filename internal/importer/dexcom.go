@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"strings"
+	"time"
+)
+
+// Значения вне диапазона сенсора Dexcom записывает словами
+const (
+	dexcomLowMgDL  = 40
+	dexcomHighMgDL = 400
+)
+
+// dexcomParser разбирает выгрузку Dexcom Clarity. Кроме показаний сенсора (EGV)
+// в ней есть калибровки, инсулин, углеводы и служебные строки — они пропускаются.
+type dexcomParser struct{}
+
+func (dexcomParser) Format() string { return FormatDexcom }
+
+func (dexcomParser) Detect(rows [][]string) bool {
+	return findHeader(rows, contains("timestamp (yyyy-mm-ddthh:mm:ss)"), contains("event type"), contains("glucose value")) >= 0
+}
+
+func (dexcomParser) Parse(rows [][]string, opts Options) (*Result, error) {
+	headerRow := findHeader(rows, contains("timestamp"), contains("event type"), contains("glucose value"))
+	if headerRow < 0 {
+		return nil, ErrUnknownFormat
+	}
+	header := rows[headerRow]
+	timeCol := columnIndex(header, contains("timestamp"))
+	typeCol := columnIndex(header, contains("event type"))
+	valueCol := columnIndex(header, contains("glucose value"))
+
+	unit := UnitMgDL
+	if u, ok := unitFromText(header[valueCol]); ok {
+		unit = u
+	}
+
+	result := &Result{Format: FormatDexcom, Unit: unit}
+	for _, row := range rows[headerRow+1:] {
+		if typeCol >= len(row) || !strings.EqualFold(row[typeCol], "EGV") {
+			continue
+		}
+
+		at, okTime := time.Time{}, false
+		if timeCol < len(row) {
+			at, okTime = parseTime(row[timeCol], "2006-01-02T15:04:05", opts.Location)
+		}
+		value, notes, okValue := dexcomValue(row, valueCol, unit)
+		if !okTime || !okValue {
+			result.Skipped++
+			continue
+		}
+		result.Readings = append(result.Readings, Reading{At: at.In(time.UTC), Value: value, Notes: notes, CGM: true})
+	}
+	return result, nil
+}
+
+// dexcomValue разбирает значение EGV: «Low» и «High» заменяются границами диапазона сенсора
+func dexcomValue(row []string, col int, unit Unit) (float64, string, bool) {
+	if col >= len(row) {
+		return 0, "", false
+	}
+	switch strings.ToLower(row[col]) {
+	case "low":
+		value, _ := toMmol(dexcomLowMgDL, UnitMgDL)
+		return value, "Dexcom: ниже диапазона сенсора", true
+	case "high":
+		value, _ := toMmol(dexcomHighMgDL, UnitMgDL)
+		return value, "Dexcom: выше диапазона сенсора", true
+	}
+	value, ok := parseNumber(row[col])
+	if !ok {
+		return 0, "", false
+	}
+	value, ok = toMmol(value, unit)
+	return value, "", ok
+}
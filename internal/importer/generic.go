@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"strings"
+	"time"
+)
+
+// Mapping — колонки произвольного CSV. Колонки задаются названиями из заголовка
+// без учета регистра. Если дата и время в одной колонке, DateColumn не нужен.
+type Mapping struct {
+	DateColumn  string
+	TimeColumn  string
+	ValueColumn string
+	NotesColumn string
+	TimeLayout  string // формат time.Parse, пустой — определить по значениям
+	Unit        Unit   // пустой — определить по заголовку или значениям
+}
+
+// Названия колонок, по которым угадываются колонки глюкометров
+var (
+	genericDateTime = contains("timestamp", "date time", "datetime", "дата и время", "дата/время")
+	genericDate     = contains("date", "дата")
+	genericTime     = contains("time", "время")
+	genericValue    = contains("glucose", "глюкоз", "сахар", "bg", "reading", "value", "значение", "mmol", "ммоль", "mg/dl")
+	genericNotes    = contains("note", "comment", "заметк", "коммент", "примечан")
+)
+
+// genericParser разбирает CSV глюкометров и дневников с заданными или угаданными колонками
+type genericParser struct{}
+
+func (genericParser) Format() string { return FormatGeneric }
+
+func (genericParser) Detect(rows [][]string) bool {
+	return findHeader(rows, contains("date", "time", "дата", "время"), genericValue) >= 0
+}
+
+func (genericParser) Parse(rows [][]string, opts Options) (*Result, error) {
+	headerRow, cols := findGenericColumns(rows, opts.Mapping)
+	if headerRow < 0 {
+		return nil, ErrUnknownFormat
+	}
+	data := rows[headerRow+1:]
+
+	stamp := func(row []string) string {
+		value := cell(row, cols.time)
+		if cols.date >= 0 {
+			value = cell(row, cols.date) + " " + value
+		}
+		return value
+	}
+
+	layout := ""
+	if opts.Mapping != nil {
+		layout = opts.Mapping.TimeLayout
+	}
+	if layout == "" {
+		var stamps []string
+		for _, row := range data {
+			stamps = append(stamps, stamp(row))
+		}
+		layout = detectLayout(stamps, timeLayouts)
+	}
+
+	unit, ok := Unit(""), false
+	if opts.Mapping != nil && opts.Mapping.Unit != "" {
+		unit, ok = opts.Mapping.Unit, true
+	}
+	if !ok {
+		unit, ok = unitFromText(rows[headerRow][cols.value])
+	}
+	if !ok {
+		var values []float64
+		for _, row := range data {
+			if v, ok := parseNumber(cell(row, cols.value)); ok {
+				values = append(values, v)
+			}
+		}
+		unit = guessUnit(values)
+	}
+
+	result := &Result{Format: FormatGeneric, Unit: unit}
+	for _, row := range data {
+		if isEmptyRow(row) {
+			continue
+		}
+		at, okTime := parseTime(stamp(row), layout, opts.Location)
+		value, okValue := parseNumber(cell(row, cols.value))
+		if okValue {
+			value, okValue = toMmol(value, unit)
+		}
+		if !okTime || !okValue {
+			result.Skipped++
+			continue
+		}
+		reading := Reading{At: at.In(time.UTC), Value: value}
+		if cols.notes >= 0 {
+			reading.Notes = cell(row, cols.notes)
+		}
+		result.Readings = append(result.Readings, reading)
+	}
+	return result, nil
+}
+
+type genericColumns struct {
+	date, time, value, notes int
+}
+
+// findGenericColumns находит строку заголовка и номера колонок: по mapping,
+// если он задан, иначе по типичным названиям
+func findGenericColumns(rows [][]string, mapping *Mapping) (int, genericColumns) {
+	if mapping != nil {
+		named := func(name string) func(string) bool {
+			name = strings.ToLower(strings.TrimSpace(name))
+			return func(column string) bool { return name != "" && strings.TrimSpace(column) == name }
+		}
+		match := []func(string) bool{named(mapping.TimeColumn), named(mapping.ValueColumn)}
+		if mapping.DateColumn != "" {
+			match = append(match, named(mapping.DateColumn))
+		}
+		headerRow := findHeader(rows, match...)
+		if headerRow < 0 {
+			return -1, genericColumns{}
+		}
+		header := rows[headerRow]
+		cols := genericColumns{
+			date:  -1,
+			time:  columnIndex(header, named(mapping.TimeColumn)),
+			value: columnIndex(header, named(mapping.ValueColumn)),
+			notes: columnIndex(header, named(mapping.NotesColumn)),
+		}
+		if mapping.DateColumn != "" {
+			cols.date = columnIndex(header, named(mapping.DateColumn))
+		}
+		return headerRow, cols
+	}
+
+	headerRow := findHeader(rows, contains("date", "time", "дата", "время"), genericValue)
+	if headerRow < 0 {
+		return -1, genericColumns{}
+	}
+	header := rows[headerRow]
+	cols := genericColumns{date: -1, time: columnIndex(header, genericDateTime), notes: columnIndex(header, genericNotes)}
+	if cols.time < 0 {
+		// Дата и время в разных колонках или только одна колонка с датой и временем
+		date := columnIndex(header, genericDate)
+		clock := columnIndex(header, func(name string) bool { return genericTime(name) && !genericDate(name) })
+		switch {
+		case date >= 0 && clock >= 0 && date != clock:
+			cols.date, cols.time = date, clock
+		case date >= 0:
+			cols.time = date
+		default:
+			cols.time = clock
+		}
+	}
+	cols.value = columnIndex(header, func(name string) bool {
+		return genericValue(name) && !genericDate(name) && !genericTime(name)
+	})
+	if cols.time < 0 || cols.value < 0 {
+		return -1, genericColumns{}
+	}
+	return headerRow, cols
+}
+
+func cell(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return row[col]
+}
+
+func isEmptyRow(row []string) bool {
+	for _, v := range row {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
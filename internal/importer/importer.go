@@ -0,0 +1,288 @@
+// Package importer разбирает выгрузки глюкометров и CGM-приложений (LibreView,
+// Dexcom Clarity, произвольный CSV) в измерения глюкозы в ммоль/л.
+// Формат определяется по заголовку таблицы, новый формат добавляется реализацией Parser.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxFileSize — максимальный размер файла выгрузки
+const MaxFileSize = 10 << 20
+
+// MgDLPerMmol — коэффициент пересчета мг/дл в ммоль/л
+const MgDLPerMmol = 18.0182
+
+// Допустимые значения глюкозы, ммоль/л; остальное считается ошибкой файла
+const (
+	minGlucose = 1.0
+	maxGlucose = 40.0
+)
+
+// headerSearchRows — в скольких первых строках искать заголовок таблицы
+const headerSearchRows = 20
+
+// Unit — единицы глюкозы в файле
+type Unit string
+
+const (
+	UnitMmol Unit = "mmol/L"
+	UnitMgDL Unit = "mg/dL"
+)
+
+// Форматы выгрузок
+const (
+	FormatLibreView = "libreview"
+	FormatDexcom    = "dexcom"
+	FormatGeneric   = "generic"
+)
+
+var (
+	// ErrUnknownFormat — файл не похож ни на один поддерживаемый формат
+	ErrUnknownFormat = errors.New("importer: unknown file format")
+	// ErrNoReadings — в файле нет ни одного измерения
+	ErrNoReadings = errors.New("importer: no glucose readings found")
+	// ErrFileTooLarge — файл больше MaxFileSize
+	ErrFileTooLarge = errors.New("importer: file is too large")
+)
+
+// Reading — измерение глюкозы из файла
+type Reading struct {
+	At    time.Time
+	Value float64 // ммоль/л
+	Notes string
+	CGM   bool // показание сенсора, а не глюкометра
+}
+
+// Result — разобранный файл
+type Result struct {
+	Format   string
+	Unit     Unit
+	Readings []Reading // по возрастанию времени
+	Skipped  int       // строки данных, которые не удалось разобрать
+}
+
+// Options — параметры разбора
+type Options struct {
+	Format   string         // пустой — определить по заголовку
+	Mapping  *Mapping       // колонки для FormatGeneric, nil — определить по заголовку
+	Location *time.Location // часовой пояс времени в файле, nil — time.Local
+}
+
+// Parser разбирает выгрузку одного приложения
+type Parser interface {
+	// Format возвращает название формата
+	Format() string
+	// Detect сообщает, подходит ли формат к таблице, по ее первым строкам
+	Detect(rows [][]string) bool
+	// Parse разбирает таблицу целиком
+	Parse(rows [][]string, opts Options) (*Result, error)
+}
+
+// parsers — известные форматы в порядке проверки; generic подходит почти к любому CSV
+// и поэтому проверяется последним
+var parsers = []Parser{libreViewParser{}, dexcomParser{}, genericParser{}}
+
+// Formats возвращает названия поддерживаемых форматов
+func Formats() []string {
+	formats := make([]string, len(parsers))
+	for i, p := range parsers {
+		formats[i] = p.Format()
+	}
+	return formats
+}
+
+// Parse читает CSV и разбирает его подходящим парсером
+func Parse(r io.Reader, opts Options) (*Result, error) {
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+
+	rows, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	parser, err := selectParser(rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	result, err := parser.Parse(rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Readings) == 0 {
+		return nil, ErrNoReadings
+	}
+	sort.SliceStable(result.Readings, func(i, j int) bool {
+		return result.Readings[i].At.Before(result.Readings[j].At)
+	})
+	return result, nil
+}
+
+func selectParser(rows [][]string, opts Options) (Parser, error) {
+	if opts.Format != "" {
+		for _, p := range parsers {
+			if p.Format() == opts.Format {
+				return p, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, opts.Format)
+	}
+	if opts.Mapping != nil {
+		return genericParser{}, nil
+	}
+
+	head := rows
+	if len(head) > headerSearchRows {
+		head = head[:headerSearchRows]
+	}
+	for _, p := range parsers {
+		if p.Detect(head) {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownFormat
+}
+
+// readCSV читает файл целиком: BOM убирается, разделитель (запятая, точка с запятой
+// или табуляция) определяется по первой строке
+func readCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+	return rows, nil
+}
+
+func detectDelimiter(data []byte) rune {
+	// Первая строка LibreView — описание файла, поэтому смотрим несколько строк
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	counts := map[rune]int{}
+	for i := 0; i < 3 && scanner.Scan(); i++ {
+		for _, delimiter := range []rune{',', ';', '\t'} {
+			counts[delimiter] += strings.Count(scanner.Text(), string(delimiter))
+		}
+	}
+	best := ','
+	for _, delimiter := range []rune{';', '\t'} {
+		if counts[delimiter] > counts[best] {
+			best = delimiter
+		}
+	}
+	return best
+}
+
+// findHeader возвращает номер строки, в которой есть колонки со всеми ключами match
+func findHeader(rows [][]string, match ...func(string) bool) int {
+	for i, row := range rows {
+		if i >= headerSearchRows {
+			break
+		}
+		found := 0
+		for _, m := range match {
+			if columnIndex(row, m) >= 0 {
+				found++
+			}
+		}
+		if found == len(match) {
+			return i
+		}
+	}
+	return -1
+}
+
+// columnIndex возвращает номер первой колонки заголовка, подходящей под match, или -1
+func columnIndex(header []string, match func(string) bool) int {
+	for i, name := range header {
+		if match(strings.ToLower(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// contains возвращает условие «название колонки содержит одну из подстрок»
+func contains(substrings ...string) func(string) bool {
+	return func(name string) bool {
+		for _, s := range substrings {
+			if strings.Contains(name, s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// unitFromText ищет единицы в названии колонки или значении
+func unitFromText(text string) (Unit, bool) {
+	text = strings.ToLower(text)
+	switch {
+	case strings.Contains(text, "mg/dl"), strings.Contains(text, "мг/дл"):
+		return UnitMgDL, true
+	case strings.Contains(text, "mmol"), strings.Contains(text, "ммоль"):
+		return UnitMmol, true
+	}
+	return "", false
+}
+
+// guessUnit определяет единицы по значениям: в ммоль/л медиана почти не бывает больше 35
+func guessUnit(values []float64) Unit {
+	if len(values) == 0 {
+		return UnitMmol
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	if sorted[len(sorted)/2] > 35 {
+		return UnitMgDL
+	}
+	return UnitMmol
+}
+
+// parseNumber разбирает число с точкой или запятой
+func parseNumber(s string) (float64, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+// toMmol переводит значение в ммоль/л с точностью до 0.1 и проверяет диапазон
+func toMmol(value float64, unit Unit) (float64, bool) {
+	if unit == UnitMgDL {
+		value /= MgDLPerMmol
+	}
+	value = math.Round(value*10) / 10
+	return value, value >= minGlucose && value <= maxGlucose
+}
@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const libreViewCSV = "\ufeffGlucose Data,Generated on,02-16-2024 10:00 UTC,Generated by,Test User\n" +
+	"Device,Serial Number,Device Timestamp,Record Type,Historic Glucose mg/dL,Scan Glucose mg/dL,Non-numeric Rapid-Acting Insulin,Rapid-Acting Insulin (units),Strip Glucose mg/dL,Notes\n" +
+	"FreeStyle LibreLink,ABC,02-14-2024 08:15 PM,0,126,,,,,\n" +
+	"FreeStyle LibreLink,ABC,02-14-2024 08:30 PM,1,,144,,,,\n" +
+	"FreeStyle LibreLink,ABC,02-14-2024 08:40 PM,4,,,,4,,\n" +
+	"FreeStyle LibreLink,ABC,02-13-2024 07:00 AM,2,,,,,90,\n" +
+	"FreeStyle LibreLink,ABC,02-14-2024 09:00 PM,0,abc,,,,,\n"
+
+const dexcomCSV = "Index,Timestamp (YYYY-MM-DDThh:mm:ss),Event Type,Event Subtype,Patient Info,Device Info,Source Device ID,Glucose Value (mg/dL),Insulin Value (u),Carb Value (grams),Duration (hh:mm:ss),Glucose Rate of Change (mg/dL/min),Transmitter Time (Long Integer),Transmitter ID\n" +
+	"1,,FirstName,,Иван,,,,,,,,,\n" +
+	"2,,Device,,,G6,,,,,,,,\n" +
+	"3,2024-02-14T08:00:00,EGV,,,,iOS G6,108,,,,,1,8X\n" +
+	"4,2024-02-14T08:05:00,EGV,,,,iOS G6,Low,,,,,2,8X\n" +
+	"5,2024-02-14T08:07:00,Insulin,Fast-Acting,,,iOS G6,,4,,,,3,8X\n" +
+	"6,2024-02-14T08:10:00,EGV,,,,iOS G6,High,,,,,4,8X\n"
+
+func TestParse_LibreView(t *testing.T) {
+	result, err := Parse(strings.NewReader(libreViewCSV), Options{Location: time.UTC})
+	require.NoError(t, err)
+
+	assert.Equal(t, FormatLibreView, result.Format)
+	assert.Equal(t, UnitMgDL, result.Unit)
+	assert.Equal(t, 1, result.Skipped)
+	require.Len(t, result.Readings, 3)
+
+	assert.Equal(t, time.Date(2024, 2, 13, 7, 0, 0, 0, time.UTC), result.Readings[0].At)
+	assert.Equal(t, 5.0, result.Readings[0].Value)
+	assert.Equal(t, "LibreView: глюкометр", result.Readings[0].Notes)
+	assert.Equal(t, time.Date(2024, 2, 14, 20, 15, 0, 0, time.UTC), result.Readings[1].At)
+	assert.Equal(t, 7.0, result.Readings[1].Value)
+	assert.Empty(t, result.Readings[1].Notes)
+	assert.Equal(t, 8.0, result.Readings[2].Value)
+}
+
+func TestParse_LibreViewDayFirstMmol(t *testing.T) {
+	csv := "Данные глюкозы,Создано,16-02-2024 10:00 UTC\n" +
+		"Device;Serial Number;Device Timestamp;Record Type;Historic Glucose mmol/L;Scan Glucose mmol/L\n"
+	csv = strings.Replace(csv, ",", ";", -1)
+	csv += "Libre;A;03-02-2024 08:15;0;6,4;\n" +
+		"Libre;A;13-02-2024 08:15;0;7,1;\n"
+
+	loc := time.FixedZone("MSK", 3*60*60)
+	result, err := Parse(strings.NewReader(csv), Options{Location: loc})
+	require.NoError(t, err)
+
+	assert.Equal(t, UnitMmol, result.Unit)
+	require.Len(t, result.Readings, 2)
+	// День стоит первым: 13-02 не разобрать как месяц-день
+	assert.Equal(t, time.Date(2024, 2, 3, 5, 15, 0, 0, time.UTC), result.Readings[0].At)
+	assert.Equal(t, 6.4, result.Readings[0].Value)
+}
+
+func TestParse_Dexcom(t *testing.T) {
+	result, err := Parse(strings.NewReader(dexcomCSV), Options{Location: time.UTC})
+	require.NoError(t, err)
+
+	assert.Equal(t, FormatDexcom, result.Format)
+	assert.Equal(t, UnitMgDL, result.Unit)
+	assert.Zero(t, result.Skipped)
+	require.Len(t, result.Readings, 3)
+	assert.Equal(t, 6.0, result.Readings[0].Value)
+	assert.Equal(t, 2.2, result.Readings[1].Value)
+	assert.Equal(t, "Dexcom: ниже диапазона сенсора", result.Readings[1].Notes)
+	assert.Equal(t, 22.2, result.Readings[2].Value)
+}
+
+func TestParse_Generic(t *testing.T) {
+	tests := []struct {
+		name  string
+		csv   string
+		opts  Options
+		unit  Unit
+		first Reading
+		count int
+	}{
+		{
+			name: "дата и время в разных колонках, ммоль по значениям",
+			csv: "Дата;Время;Глюкоза;Комментарий\n" +
+				"14.02.2024;07:30;5,6;натощак\n" +
+				"14.02.2024;13:00;8,9;\n",
+			unit:  UnitMmol,
+			first: Reading{At: time.Date(2024, 2, 14, 7, 30, 0, 0, time.UTC), Value: 5.6, Notes: "натощак"},
+			count: 2,
+		},
+		{
+			name: "мг/дл по значениям",
+			csv: "Date Time,Reading\n" +
+				"2024-02-14 07:30,101\n" +
+				"2024-02-14 13:00,160\n",
+			unit:  UnitMgDL,
+			first: Reading{At: time.Date(2024, 2, 14, 7, 30, 0, 0, time.UTC), Value: 5.6},
+			count: 2,
+		},
+		{
+			name: "колонки заданы явно",
+			csv: "when,bg,unit\n" +
+				"2024/02/14 07:30,5.6,x\n",
+			opts:  Options{Mapping: &Mapping{TimeColumn: "When", ValueColumn: "BG", Unit: UnitMmol}},
+			unit:  UnitMmol,
+			first: Reading{At: time.Date(2024, 2, 14, 7, 30, 0, 0, time.UTC), Value: 5.6},
+			count: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Location = time.UTC
+			result, err := Parse(strings.NewReader(tt.csv), tt.opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, FormatGeneric, result.Format)
+			assert.Equal(t, tt.unit, result.Unit)
+			require.Len(t, result.Readings, tt.count)
+			assert.Equal(t, tt.first, result.Readings[0])
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := Parse(strings.NewReader("a,b\n1,2\n"), Options{})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Parse(strings.NewReader(dexcomCSV), Options{Format: "unknown"})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Parse(strings.NewReader("Date,Glucose\nвчера,много\n"), Options{})
+	assert.ErrorIs(t, err, ErrNoReadings)
+
+	_, err = Parse(strings.NewReader(strings.Repeat("x", MaxFileSize+1)), Options{})
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+}
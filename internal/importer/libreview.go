@@ -0,0 +1,88 @@
+package importer
+
+import "time"
+
+// Типы записей LibreView: исторические (раз в 15 минут), сканирование, глюкометр
+const (
+	libreRecordHistoric = "0"
+	libreRecordScan     = "1"
+	libreRecordStrip    = "2"
+)
+
+// libreViewParser разбирает выгрузку LibreView / LibreLink. Первая строка файла —
+// описание выгрузки, заголовок таблицы — во второй.
+type libreViewParser struct{}
+
+func (libreViewParser) Format() string { return FormatLibreView }
+
+func (libreViewParser) Detect(rows [][]string) bool {
+	return findHeader(rows, contains("device timestamp"), contains("record type"), contains("historic glucose")) >= 0
+}
+
+func (libreViewParser) Parse(rows [][]string, opts Options) (*Result, error) {
+	headerRow := findHeader(rows, contains("device timestamp"), contains("record type"))
+	if headerRow < 0 {
+		return nil, ErrUnknownFormat
+	}
+	header := rows[headerRow]
+	timeCol := columnIndex(header, contains("device timestamp"))
+	typeCol := columnIndex(header, contains("record type"))
+	valueCols := map[string]int{
+		libreRecordHistoric: columnIndex(header, contains("historic glucose")),
+		libreRecordScan:     columnIndex(header, contains("scan glucose")),
+		libreRecordStrip:    columnIndex(header, contains("strip glucose")),
+	}
+	notes := map[string]string{
+		libreRecordScan:  "LibreView: сканирование",
+		libreRecordStrip: "LibreView: глюкометр",
+	}
+
+	unit := UnitMmol
+	if col := valueCols[libreRecordHistoric]; col >= 0 {
+		if u, ok := unitFromText(header[col]); ok {
+			unit = u
+		}
+	}
+
+	data := rows[headerRow+1:]
+	var times []string
+	for _, row := range data {
+		if timeCol < len(row) {
+			times = append(times, row[timeCol])
+		}
+	}
+	layout := detectLayout(times, timeLayouts)
+
+	result := &Result{Format: FormatLibreView, Unit: unit}
+	for _, row := range data {
+		if typeCol >= len(row) {
+			continue
+		}
+		col, ok := valueCols[row[typeCol]]
+		if !ok || col < 0 {
+			// Инсулин, углеводы и заметки не импортируются
+			continue
+		}
+
+		at, okTime := parseTime(row[timeCol], layout, opts.Location)
+		var value float64
+		okValue := col < len(row)
+		if okValue {
+			value, okValue = parseNumber(row[col])
+		}
+		if okValue {
+			value, okValue = toMmol(value, unit)
+		}
+		if !okTime || !okValue {
+			result.Skipped++
+			continue
+		}
+		result.Readings = append(result.Readings, Reading{
+			At:    at.In(time.UTC),
+			Value: value,
+			Notes: notes[row[typeCol]],
+			CGM:   row[typeCol] != libreRecordStrip,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package importer
+
+import (
+	"strings"
+	"time"
+)
+
+// timeLayouts — форматы времени, которые встречаются в выгрузках. При равном числе
+// разобранных строк выбирается первый, поэтому «месяц-день» английских выгрузок
+// стоит раньше «день-месяц».
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"01-02-2006 15:04",
+	"01-02-2006 3:04 PM",
+	"02-01-2006 15:04",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"01/02/2006 15:04",
+	"01/02/2006 3:04 PM",
+	"01/02/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02/01/2006 15:04:05",
+}
+
+// detectLayout выбирает формат времени, подходящий к наибольшему числу значений.
+// По одной строке «03-04-2024» не понять, март это или апрель, а по всему файлу —
+// можно: день больше 12 встретится почти наверняка.
+func detectLayout(values []string, layouts []string) string {
+	best, bestCount := "", 0
+	for _, layout := range layouts {
+		count := 0
+		for _, v := range values {
+			if _, err := time.Parse(layout, normalizeTime(v)); err == nil {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = layout, count
+		}
+	}
+	return best
+}
+
+// parseTime разбирает время в формате layout; время без смещения считается в loc
+func parseTime(value, layout string, loc *time.Location) (time.Time, bool) {
+	if layout == "" {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(layout, normalizeTime(value), loc)
+	return t, err == nil
+}
+
+// normalizeTime приводит «am/pm» к верхнему регистру и схлопывает пробелы
+func normalizeTime(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	value = strings.Replace(value, " am", " AM", 1)
+	return strings.Replace(value, " pm", " PM", 1)
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"time"
 	"gorm.io/gorm"
 )
 
@@ -9,7 +8,7 @@ import (
 type AIUsage struct {
 	gorm.Model
 	UserID      uint      `gorm:"not null;index:idx_user_date,unique:true"`
-	Date        time.Time `gorm:"type:date;not null;index:idx_user_date,unique:true"`
+	Date        string    `gorm:"size:10;not null;index:idx_user_date,unique:true"` // YYYY-MM-DD по UTC
 	RequestCount int      `gorm:"default:0"`
 	User        User      `gorm:"foreignKey:UserID"`
 }
\ No newline at end of file
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Вид тревоги по критическому значению глюкозы
+const (
+	AlertKindLow  = "low"  // тяжелая гипогликемия
+	AlertKindHigh = "high" // очень высокий сахар
+)
+
+// Статус тревоги
+const (
+	AlertStatusOpen         = "open"         // ждем повторного измерения
+	AlertStatusAcknowledged = "acknowledged" // родственник подтвердил, что видит тревогу
+	AlertStatusEscalated    = "escalated"    // повторного измерения не было в срок
+	AlertStatusResolved     = "resolved"     // повторное измерение в норме
+	AlertStatusMuted        = "muted"        // высокий сахар в тихие часы, никому не отправлялась
+)
+
+// AlertRule — настройки тревог владельца дневника для родственников.
+// Пока правило не сохранено, действуют значения по умолчанию.
+type AlertRule struct {
+	ID             uint           `json:"-" gorm:"primarykey"`
+	UserID         uint           `json:"-" gorm:"not null;uniqueIndex"`
+	Enabled        bool           `json:"enabled"`
+	LowThreshold   float64        `json:"low_threshold"`             // ммоль/л, ниже — тревога
+	HighThreshold  float64        `json:"high_threshold"`            // ммоль/л, выше — тревога
+	RecheckMinutes int            `json:"recheck_minutes"`           // через сколько минут без повторного измерения эскалация
+	QuietStart     string         `json:"quiet_start" gorm:"size:5"` // ЧЧ:ММ, пусто — без тихих часов
+	QuietEnd       string         `json:"quiet_end" gorm:"size:5"`   // ЧЧ:ММ, может быть раньше начала: 23:00–07:00
+	Timezone       string         `json:"timezone" gorm:"size:64"`   // IANA для тихих часов, пусто — часовой пояс сервера
+	CreatedAt      time.Time      `json:"-"`
+	UpdatedAt      time.Time      `json:"-"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Alert — тревога по критическому измерению и ее путь до повторного измерения
+type Alert struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	UserID         uint           `json:"user_id" gorm:"not null;index"`
+	RecordID       uint           `json:"record_id"`                            // измерение, вызвавшее тревогу
+	Kind           string         `json:"kind" gorm:"size:10;not null"`         // low, high
+	Value          float64        `json:"value"`                                // последнее критическое значение, ммоль/л
+	MeasuredAt     time.Time      `json:"measured_at"`                          // время последнего критического значения
+	Status         string         `json:"status" gorm:"size:20;not null;index"` // open, acknowledged, escalated, resolved, muted
+	RecheckBy      time.Time      `json:"recheck_by" gorm:"index"`              // срок повторного измерения
+	AcknowledgedAt *time.Time     `json:"acknowledged_at"`
+	AcknowledgedBy *uint          `json:"-"`
+	EscalatedAt    *time.Time     `json:"escalated_at"`
+	ResolvedAt     *time.Time     `json:"resolved_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DigestSubscription — подписка на еженедельную сводку. Пока не сохранена, сводка не присылается.
+type DigestSubscription struct {
+	ID        uint           `json:"-" gorm:"primarykey"`
+	UserID    uint           `json:"-" gorm:"not null;uniqueIndex"`
+	Enabled   bool           `json:"enabled"`
+	Timezone  string         `json:"timezone" gorm:"size:64"` // IANA для воскресного вечера, пусто — часовой пояс сервера
+	CreatedAt time.Time      `json:"-"`
+	UpdatedAt time.Time      `json:"-"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Digest — еженедельная сводка за [PeriodFrom, PeriodTo): посчитанные показатели и комментарий ИИ.
+// Повторная сводка за ту же неделю перезаписывает прежнюю.
+type Digest struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	UserID      uint           `json:"-" gorm:"not null;index"`
+	PeriodFrom  time.Time      `json:"from"`
+	PeriodTo    time.Time      `json:"to"`
+	Summary     string         `json:"summary" gorm:"type:text"`    // показатели недели текстом
+	Commentary  string         `json:"commentary" gorm:"type:text"` // комментарий ИИ, пусто — ИИ недоступен
+	GeneratedAt time.Time      `json:"generated_at"`
+	SentAt      *time.Time     `json:"sent_at"` // когда сводка отправлена в Telegram
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"-"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Статус импорта
+const (
+	ImportStatusPreview   = "preview"   // файл разобран, пользователь еще не подтвердил импорт
+	ImportStatusCompleted = "completed" // записи сохранены
+	ImportStatusCancelled = "cancelled" // пользователь отказался от импорта
+)
+
+// Источник файла импорта
+const (
+	ImportSourceTelegram = "telegram"
+	ImportSourceAPI      = "api"
+)
+
+// ImportJob — загрузка файла с измерениями глюкозы: итог разбора и импорта
+type ImportJob struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	UserID      uint           `json:"user_id" gorm:"not null;index"`
+	Source      string         `json:"source" gorm:"size:20;not null"` // telegram, api
+	Format      string         `json:"format" gorm:"size:20;not null"` // libreview, dexcom, generic
+	Unit        string         `json:"unit" gorm:"size:10"`            // единицы в файле: mmol/L, mg/dL
+	FileName    string         `json:"file_name" gorm:"size:255"`
+	FileID      string         `json:"-" gorm:"size:255"`              // file_id Telegram для повторной загрузки
+	Status      string         `json:"status" gorm:"size:20;not null"` // preview, completed, cancelled
+	Total       int            `json:"total"`                          // измерений в файле
+	Imported    int            `json:"imported"`                       // сохранено новых записей
+	Duplicates  int            `json:"duplicates"`                     // уже были в дневнике или повторялись в файле
+	Skipped     int            `json:"skipped"`                        // строки, которые не удалось разобрать
+	FirstAt     *time.Time     `json:"first_at"`
+	LastAt      *time.Time     `json:"last_at"`
+	CompletedAt *time.Time     `json:"completed_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
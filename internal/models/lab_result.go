@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Вид лабораторного анализа
+const (
+	LabKindHbA1c       = "hba1c"       // гликированный гемоглобин
+	LabKindCholesterol = "cholesterol" // общий холестерин
+	LabKindCreatinine  = "creatinine"  // креатинин крови
+	LabKindCustom      = "custom"      // любой другой анализ, название в Name
+)
+
+// Единицы измерения анализов
+const (
+	LabUnitPercent   = "%"
+	LabUnitMmolMol   = "mmol/mol" // HbA1c по IFCC
+	LabUnitMmolL     = "mmol/L"
+	LabUnitMgDL      = "mg/dL"
+	LabUnitMicromolL = "µmol/L"
+)
+
+// IsLabKind проверяет, что строка — известный вид анализа
+func IsLabKind(kind string) bool {
+	switch kind {
+	case LabKindHbA1c, LabKindCholesterol, LabKindCreatinine, LabKindCustom:
+		return true
+	}
+	return false
+}
+
+// LabResult — результат лабораторного анализа, который пользователь сдает раз в несколько месяцев
+type LabResult struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	UserID    uint           `json:"user_id" gorm:"not null;index"`
+	Kind      string         `json:"kind" gorm:"size:20;not null"` // hba1c, cholesterol, creatinine, custom
+	Name      string         `json:"name" gorm:"size:100"`         // название для custom
+	Value     float64        `json:"value" gorm:"not null"`
+	Unit      string         `json:"unit" gorm:"size:20;not null"`
+	TakenAt   time.Time      `json:"taken_at" gorm:"not null"` // дата сдачи анализа
+	Notes     string         `json:"notes" gorm:"size:500"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PublicLink — ссылка /share/<токен> на сводку дневника за период [PeriodFrom, PeriodTo) для врача
+// без Telegram. Хранится только хеш токена; отзыв — мягкое удаление.
+type PublicLink struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	UserID         uint           `json:"-" gorm:"not null;index"`
+	TokenHash      string         `json:"-" gorm:"size:64;uniqueIndex"` // SHA-256 токена
+	Label          string         `json:"label" gorm:"size:100"`        // для кого ссылка, видит только владелец
+	PeriodFrom     time.Time      `json:"from"`
+	PeriodTo       time.Time      `json:"to"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	AccessCount    int            `json:"access_count" gorm:"not null;default:0"`
+	LastAccessedAt *time.Time     `json:"last_accessed_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"-"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// PublicLinkAccess — открытие публичной ссылки
+type PublicLinkAccess struct {
+	ID         uint      `json:"-" gorm:"primarykey"`
+	LinkID     uint      `json:"-" gorm:"not null;index"`
+	IP         string    `json:"ip" gorm:"size:45"`
+	UserAgent  string    `json:"user_agent" gorm:"size:255"`
+	AccessedAt time.Time `json:"accessed_at" gorm:"not null"`
+}
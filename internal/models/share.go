@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Роль получателя доступа к дневнику
+const (
+	ShareRoleRead  = "read"  // только просмотр: врач, родственник
+	ShareRoleWrite = "write" // просмотр и запись: родитель ребенка с диабетом
+)
+
+// IsShareRole проверяет, что строка — известная роль доступа
+func IsShareRole(role string) bool {
+	return role == ShareRoleRead || role == ShareRoleWrite
+}
+
+// Share — доступ к дневнику владельца. Пока приглашение не принято, GranteeID пуст
+// и действует токен из ссылки; отзыв — мягкое удаление.
+type Share struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	OwnerID    uint           `json:"owner_id" gorm:"not null;index"`
+	GranteeID  *uint          `json:"grantee_id" gorm:"index"`
+	Role       string         `json:"role" gorm:"size:10;not null"` // read, write
+	TokenHash  string         `json:"-" gorm:"size:64;uniqueIndex"` // SHA-256 токена приглашения
+	ExpiresAt  time.Time      `json:"expires_at"`                   // срок приглашения, после принятия не действует
+	AcceptedAt *time.Time     `json:"accepted_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Owner   User  `json:"-" gorm:"foreignKey:OwnerID"`
+	Grantee *User `json:"-" gorm:"foreignKey:GranteeID"`
+}
@@ -23,16 +23,50 @@ type User struct {
 	TargetGlucose  *float64       `json:"target_glucose"` // mmol/L
 	Notifications  *bool          `json:"notifications" gorm:"default:true"`
 	
+	// SHA1 секрета для Nightscout API, nil — доступ выключен
+	NightscoutSecretHash *string `json:"-" gorm:"size:40;uniqueIndex"`
+	
 	// Relations
 	GlucoseRecords []GlucoseRecord `json:"glucose_records" gorm:"foreignKey:UserID"`
 	FoodRecords    []FoodRecord    `json:"food_records" gorm:"foreignKey:UserID"`
 }
 
+// Контекст измерения глюкозы
+const (
+	GlucoseContextFasting    = "fasting"     // натощак
+	GlucoseContextBeforeMeal = "before_meal" // до еды
+	GlucoseContextAfterMeal  = "after_meal"  // после еды
+	GlucoseContextBedtime    = "bedtime"     // перед сном
+)
+
+// Источник измерения глюкозы
+const (
+	GlucoseSourceFingerstick = "fingerstick" // глюкометр или ручной ввод
+	GlucoseSourceCGM         = "cgm"         // сенсор непрерывного мониторинга, показание каждые 1–15 минут
+)
+
+// IsGlucoseSource проверяет, что строка — известный источник измерения
+func IsGlucoseSource(source string) bool {
+	return source == GlucoseSourceFingerstick || source == GlucoseSourceCGM
+}
+
+// IsGlucoseContext проверяет, что строка — известный контекст измерения
+func IsGlucoseContext(context string) bool {
+	switch context {
+	case GlucoseContextFasting, GlucoseContextBeforeMeal, GlucoseContextAfterMeal, GlucoseContextBedtime:
+		return true
+	}
+	return false
+}
+
 type GlucoseRecord struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
 	UserID    uint           `json:"user_id" gorm:"not null"`
 	Value     float64        `json:"value" gorm:"not null"` // mmol/L
 	MeasuredAt time.Time     `json:"measured_at" gorm:"not null"`
+	MeasurementContext string `json:"measurement_context" gorm:"size:32"` // fasting, before_meal, after_meal, bedtime
+	Source    string         `json:"source" gorm:"size:20;not null;default:'fingerstick'"` // fingerstick, cgm
+	DeviceID  string         `json:"device_id" gorm:"size:100"` // сенсор или приложение, загрузившее показание
 	Notes     string         `json:"notes" gorm:"size:500"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -58,6 +92,27 @@ type FoodRecord struct {
 	User User `json:"user" gorm:"foreignKey:UserID"`
 }
 
+// Тип инсулина
+const (
+	InsulinTypeBolus = "bolus" // короткий, на еду и коррекцию
+	InsulinTypeBasal = "basal" // продленный
+)
+
+type InsulinRecord struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	UserID      uint           `json:"user_id" gorm:"not null;index"`
+	Units       float64        `json:"units" gorm:"not null"`
+	InsulinType string         `json:"insulin_type" gorm:"size:20"`  // bolus, basal
+	InsulinName string         `json:"insulin_name" gorm:"size:100"` // название препарата
+	InjectedAt  time.Time      `json:"injected_at" gorm:"not null"`
+	Notes       string         `json:"notes" gorm:"size:500"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
 type AIRecommendation struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
 	UserID    uint           `json:"user_id" gorm:"not null"`
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Вид показателя здоровья
+const (
+	VitalKindWeight        = "weight"         // масса тела
+	VitalKindBloodPressure = "blood_pressure" // артериальное давление: Value — систолическое, Diastolic — диастолическое
+	VitalKindKetones       = "ketones"        // кетоны (β-гидроксибутират) в крови
+)
+
+// Единицы измерения показателей
+const (
+	VitalUnitKg    = "kg"
+	VitalUnitLb    = "lb"
+	VitalUnitMmHg  = "mmHg"
+	VitalUnitMmolL = "mmol/L"
+)
+
+// IsVitalKind проверяет, что строка — известный вид показателя
+func IsVitalKind(kind string) bool {
+	switch kind {
+	case VitalKindWeight, VitalKindBloodPressure, VitalKindKetones:
+		return true
+	}
+	return false
+}
+
+// Vital — измерение показателя здоровья помимо глюкозы: веса, давления или кетонов
+type Vital struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	UserID     uint           `json:"user_id" gorm:"not null;index"`
+	Kind       string         `json:"kind" gorm:"size:20;not null"` // weight, blood_pressure, ketones
+	Value      float64        `json:"value" gorm:"not null"`
+	Diastolic  *float64       `json:"diastolic,omitempty"` // только у давления
+	Unit       string         `json:"unit" gorm:"size:10;not null"`
+	MeasuredAt time.Time      `json:"measured_at" gorm:"not null"`
+	Notes      string         `json:"notes" gorm:"size:500"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func FuzzParse(f *testing.F) {
+	seeds := []string{
+		"5.6", "сахар 7,8", "вчера в 22:30 сахар 7,8 после ужина", "6.2 натощак", "гречка 150г и котлета",
+		"уколол 6 ед новорапида", "лантус 20", "140 мг/дл", "полчаса назад 5.5", "3 хе", "-5.0", "25:99",
+		"в 8 утра 6.5", "как дела?", "сахар 7.8, уколол 4 ед", "съел 2 яблока, сахар 9", "4ед.", ":", "-",
+		"99999999999999999999999", "1e308", "в 24 часа", "99 часов назад", "12,", ",5", "5..6", "ммоль/",
+		"мг/", "сахар", "обед", "на", "через 2 часа после", "\xff\xfe", "7.8 ммоль", "ЁЖ 5",
+		"вчера в 25:99 сахар 6", "в 24:00 6", "в 7:60 5.4", "99:99", "в 25 часов сахар 6", "в 30 утра 6.5", "12:5", "-1:30",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		result := Parse(text, testNow)
+
+		if conf := result.Confidence(); conf < 0 || conf > 1 || math.IsNaN(conf) {
+			t.Fatalf("confidence %v out of range for %q", conf, text)
+		}
+		if result.Time.After(testNow.Add(49*time.Hour)) || result.Time.Before(testNow.Add(-72*time.Hour)) {
+			t.Fatalf("time %v too far from now for %q", result.Time, text)
+		}
+		if !result.HasTime && !result.Time.Equal(testNow) {
+			t.Fatalf("time changed without explicit time for %q", text)
+		}
+
+		for _, intent := range result.Intents {
+			if intent.Confidence < 0 || intent.Confidence > 1 {
+				t.Fatalf("intent confidence %v out of range for %q", intent.Confidence, text)
+			}
+			// Запись с несуществующим временем переспрашивается
+			if result.InvalidTime != "" && intent.Kind != KindQuestion && intent.Confidence >= ClarifyThreshold {
+				t.Fatalf("invalid time %q accepted with confidence %v for %q", result.InvalidTime, intent.Confidence, text)
+			}
+
+			switch intent.Kind {
+			case KindGlucose:
+				value := intent.Glucose.Value
+				if math.IsNaN(value) {
+					t.Fatalf("NaN glucose for %q", text)
+				}
+				// Значение вне диапазона допустимо только с низкой уверенностью
+				if (value < MinGlucose || value > MaxGlucose) && intent.Confidence >= ClarifyThreshold {
+					t.Fatalf("glucose %v accepted with confidence %v for %q", value, intent.Confidence, text)
+				}
+			case KindInsulin:
+				units := intent.Insulin.Units
+				if (units <= 0 || units > MaxInsulin || math.IsNaN(units)) && intent.Confidence >= ClarifyThreshold {
+					t.Fatalf("insulin %v accepted with confidence %v for %q", units, intent.Confidence, text)
+				}
+			case KindFood:
+				if intent.Food.Description != strings.TrimSpace(intent.Food.Description) {
+					t.Fatalf("food description %q is not trimmed for %q", intent.Food.Description, text)
+				}
+				if utf8.ValidString(text) && !utf8.ValidString(intent.Food.Description) {
+					t.Fatalf("food description %q is not valid UTF-8 for %q", intent.Food.Description, text)
+				}
+				if intent.Food.Description == "" && intent.Confidence >= ClarifyThreshold {
+					t.Fatalf("empty food description accepted for %q", text)
+				}
+			case KindLab:
+				if math.IsNaN(intent.Lab.Value) || math.IsInf(intent.Lab.Value, 0) {
+					t.Fatalf("lab value %v for %q", intent.Lab.Value, text)
+				}
+			case KindVital:
+				if math.IsNaN(intent.Vital.Value) || math.IsInf(intent.Vital.Value, 0) {
+					t.Fatalf("vital value %v for %q", intent.Vital.Value, text)
+				}
+			case KindQuestion, KindUnknown:
+			default:
+				t.Fatalf("unexpected intent kind %q for %q", intent.Kind, text)
+			}
+		}
+	})
+}
@@ -0,0 +1,193 @@
+package parser
+
+import "strings"
+
+// wordSet — набор словоформ для точного сравнения
+type wordSet map[string]bool
+
+func newWordSet(words ...string) wordSet {
+	set := make(wordSet, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// hasStem проверяет, начинается ли слово с одной из основ.
+// Основы не короче 4 букв, чтобы не совпадать с частями других слов.
+func hasStem(word string, stems []string) bool {
+	for _, stem := range stems {
+		if strings.HasPrefix(word, stem) {
+			return true
+		}
+	}
+	return false
+}
+
+var dayOffsets = map[string]int{
+	"сегодня":     0,
+	"вчера":       -1,
+	"позавчера":   -2,
+	"завтра":      1,
+	"послезавтра": 2,
+}
+
+// Часть суток без часа: «утром 5.5» записывается на типичный для нее час
+var daypartHours = map[string]int{
+	"утром":   8,
+	"днем":    13,
+	"вечером": 19,
+	"ночью":   3,
+}
+
+// Части суток после часа: «в 8 утра», «в 10 вечера»
+var dayparts = newWordSet("утра", "утром", "дня", "днем", "вечера", "вечером", "ночи", "ночью")
+
+// Части суток, которые сами превращают число в час: «8 утра», но не «8 утром»
+var hourDayparts = newWordSet("утра", "дня", "вечера", "ночи")
+
+var (
+	hourWords   = newWordSet("ч", "час", "часа", "часов")
+	minuteWords = newWordSet("мин", "минут", "минуты", "минуту")
+	clockPreps  = newWordSet("в", "во", "около", "примерно")
+)
+
+var (
+	glucoseKeywords = newWordSet("сахар", "сахара", "сахаром", "сахарок", "глюкоза", "глюкозы", "глюкозу",
+		"гликемия", "гк", "ск", "sugar", "glucose", "glu", "bg")
+	mmolUnits = newWordSet("ммоль", "ммол", "mmol")
+	mgdlUnits = newWordSet("мг", "mg")
+)
+
+// Слова между ключевым словом и значением: «сахар был 7.8»
+var glucoseFillers = newWordSet("был", "была", "было", "стал", "уровень", "примерно", "около", "где", "то", "составил", "показал")
+
+// Названия анализов; HbA1c распознается отдельно, потому что разбивается на слова и число
+var labStems = map[string][]string{
+	LabHbA1c:       {"гликир", "гликозил", "гликогемоглоб"},
+	LabCholesterol: {"холестер", "cholesterol"},
+	LabCreatinine:  {"креатин", "creatinin"},
+}
+
+// Слова между названием анализа и значением: «гликированный гемоглобин был 6.8»
+var labFillers = newWordSet("гемоглобин", "гемоглобина", "общий", "в", "крови", "был", "была", "составил")
+
+var micromolUnits = newWordSet("мкмоль", "мкмол", "umol", "µmol", "μmol")
+
+// Названия показателей: вес, давление и кетоны
+var (
+	weightWords   = newWordSet("вес", "весе", "весил", "весила", "вешу", "взвесился", "взвесилась", "weight")
+	pressureWords = newWordSet("ад", "давл", "bp")
+	pressureStems = []string{"давлен", "pressure"}
+	ketoneStems   = []string{"кетон", "keton"}
+)
+
+// Слова между названием показателя и значением: «кетоны в крови 0.8», «вес сегодня был 82»
+var vitalFillers = newWordSet("тела", "в", "крови", "был", "была", "было", "составил", "составило", "уровень")
+
+var (
+	kgUnits   = newWordSet("кг", "kg", "кило", "килограмм", "килограмма", "килограммов")
+	lbUnits   = newWordSet("lb", "lbs", "фунт", "фунта", "фунтов")
+	mmHgWords = newWordSet("мм", "рт", "ст", "mm", "hg", "mmhg")
+)
+
+var (
+	insulinUnits    = newWordSet("ед", "единиц", "единицы", "единица", "едениц", "u", "ui", "iu", "ие", "ме")
+	insulinKeywords = []string{"инсулин", "укол", "вкол", "подкол", "ввел", "ввела", "колол", "болюс", "базал", "подбол", "пролонг", "продлен", "коротк"}
+	basalStems      = []string{"лантус", "тресиб", "левемир", "туджео", "тожео", "базаглар", "протафан", "базал", "пролонг", "продлен"}
+	bolusStems      = []string{"хумалог", "новорапид", "фиасп", "апидр", "актрапид", "лизпро", "аспарт", "болюс", "подбол", "коротк"}
+)
+
+// Названия препаратов в именительном падеже для записи
+var insulinNames = map[string]string{
+	"лантус":    "Лантус",
+	"тресиб":    "Тресиба",
+	"левемир":   "Левемир",
+	"туджео":    "Туджео",
+	"тожео":     "Туджео",
+	"базаглар":  "Базаглар",
+	"протафан":  "Протафан",
+	"хумалог":   "Хумалог",
+	"новорапид": "НовоРапид",
+	"фиасп":     "Фиасп",
+	"апидр":     "Апидра",
+	"актрапид":  "Актрапид",
+	"лизпро":    "Лизпро",
+	"аспарт":    "Аспарт",
+}
+
+// Контекст измерения: слово после «до»/«перед»/«после»
+var mealNouns = newWordSet("еды", "едой", "приема", "приемом", "завтрака", "завтраком", "обеда", "обедом",
+	"ужина", "ужином", "перекуса", "перекусом")
+
+var (
+	mealTypes = map[string]string{
+		"завтрак": "завтрак",
+		"обед":    "обед",
+		"ужин":    "ужин",
+		"перекус": "перекус",
+		"полдник": "перекус",
+	}
+	// Глаголы, указывающие на прием пищи
+	foodVerbs = newWordSet("съел", "съела", "съели", "съем", "поел", "поела", "поели", "ел", "ела", "ем", "ели",
+		"выпил", "выпила", "пил", "пила", "скушал", "скушала", "покушал", "покушала", "кушал", "кушала",
+		"перекусил", "перекусила", "позавтракал", "позавтракала", "пообедал", "пообедала", "поужинал", "поужинала")
+	// Глаголы перед дозой инсулина: «сделал 4 ед», «поставила лантус 20»
+	insulinVerbs = newWordSet("сделал", "сделала", "сделали", "поставил", "поставила", "поставили")
+	// Глаголы, которые сами задают прием пищи
+	mealVerbs = map[string]string{
+		"позавтракал":  "завтрак",
+		"позавтракала": "завтрак",
+		"пообедал":     "обед",
+		"пообедала":    "обед",
+		"поужинал":     "ужин",
+		"поужинала":    "ужин",
+		"перекусил":    "перекус",
+		"перекусила":   "перекус",
+	}
+)
+
+// Короткие названия продуктов сравниваются точно, длинные — по основе
+var (
+	foodWords = newWordSet("рис", "риса", "рисом", "сок", "сока", "соком", "чай", "чая", "чаем", "мед", "меда", "медом",
+		"сыр", "сыра", "сыром", "суп", "супа", "супом", "щи", "щей", "кофе", "торт", "торта", "тортом", "пюре",
+		"какао", "морс", "плов", "плова", "мясо", "мяса", "мясом", "яйцо", "яйца", "яиц", "хлеб", "хлеба", "хлебом",
+		"лаваш", "каша", "кашу", "каши", "кашей", "квас", "пиво", "вино", "паста", "пасту", "пасты")
+	foodStems = []string{
+		"овсян", "гречк", "гречн", "греча", "гречу", "макарон", "спагет", "булк", "булоч", "батон", "котлет",
+		"куриц", "курин", "курочк", "индейк", "говяд", "свинин", "баранин", "рыба", "рыбу", "рыбы", "рыбой",
+		"лосос", "семг", "тунец", "тунц", "овощ", "салат", "фрукт", "яблок", "банан", "апельсин", "груш",
+		"виноград", "молок", "молоч", "кефир", "йогурт", "творог", "творож", "сырник", "яичниц", "омлет",
+		"борщ", "картош", "картоф", "пельмен", "вареник", "блин", "оладь", "оладуш", "пицц", "бургер",
+		"шоколад", "конфет", "печень", "пирог", "пирож", "компот", "колбас", "сосис", "сардел", "ветчин",
+		"бутерброд", "сэндвич", "варень", "сметан", "масло", "масла", "орех", "ягод", "клубник", "малин",
+		"черник", "арбуз", "дыня", "дыни", "персик", "мандарин", "морков", "капуст", "огурц", "огурец",
+		"помидор", "томат", "фасол", "горох", "чечевиц", "кукуруз", "мороженое", "мороженн", "зефир",
+		"пастил", "мармелад", "вафл", "круассан", "лапш", "кускус", "булгур", "киноа", "хлопь", "мюсли",
+		"гранол", "смузи", "лимонад", "кола", "колу", "шаурм", "ролл", "суши", "тефтел", "гуляш", "рагу",
+	}
+)
+
+var (
+	quantityUnits = newWordSet("г", "гр", "грамм", "грамма", "граммов", "кг", "мл", "л", "шт", "штук", "штуки",
+		"штука", "кусок", "куска", "кусков", "кусочек", "кусочка", "ломтик", "ломтика", "ломтиков", "стакан",
+		"стакана", "стаканов", "чашка", "чашки", "чашку", "тарелка", "тарелки", "тарелку", "ложка", "ложки",
+		"ложку", "ложек", "порция", "порции", "порцию", "порций", "g", "ml")
+	gramUnits  = newWordSet("г", "гр", "грамм", "грамма", "граммов", "g")
+	carbWords  = newWordSet("углеводов", "углевода", "углеводы", "угл", "у")
+	breadUnits = newWordSet("хе", "xe")
+)
+
+// Союзы и знаки, разделяющие продукты в описании
+var itemSeparators = newWordSet("и", ",", "+", ";", "/")
+
+// Слова, которые не несут смысла сами по себе
+var fillerWords = newWordSet("и", "а", "но", "ну", "вот", "сейчас", "еще", "уже", "потом", "тоже", "у", "меня",
+	"мой", "моя", "на", "с", "в", "за", "было", "был", "была", "около", "примерно", "где", "то", "только", "что")
+
+var questionWords = newWordSet("как", "что", "почему", "зачем", "сколько", "когда", "где", "какой", "какая",
+	"какие", "какое", "каким", "можно", "нужно", "стоит", "чем", "кто", "ли", "подскажи", "подскажите",
+	"посоветуй", "посоветуйте", "расскажи", "объясни")
+
+// Хлебная единица — 12 г углеводов
+const gramsPerBreadUnit = 12
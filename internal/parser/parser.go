@@ -0,0 +1,1185 @@
+// Package parser разбирает свободный текст сообщений в записи дневника:
+// глюкозу, прием пищи, дозы инсулина, результаты анализов, вес, давление, кетоны
+// и время, к которому они относятся.
+package parser
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kind — тип распознанного намерения
+type Kind string
+
+const (
+	KindGlucose  Kind = "glucose"
+	KindFood     Kind = "food"
+	KindInsulin  Kind = "insulin"
+	KindLab      Kind = "lab"
+	KindVital    Kind = "vital"
+	KindQuestion Kind = "question"
+	KindUnknown  Kind = "unknown" // в сообщении только числа, которые не удалось понять
+)
+
+// Контекст измерения глюкозы, совпадает со значениями models.GlucoseContext*
+const (
+	ContextFasting    = "fasting"
+	ContextBeforeMeal = "before_meal"
+	ContextAfterMeal  = "after_meal"
+	ContextBedtime    = "bedtime"
+)
+
+// Тип инсулина
+const (
+	InsulinBolus = "bolus"
+	InsulinBasal = "basal"
+)
+
+// Вид анализа и единицы, совпадают со значениями models.LabKind* и models.LabUnit*
+const (
+	LabHbA1c       = "hba1c"
+	LabCholesterol = "cholesterol"
+	LabCreatinine  = "creatinine"
+
+	LabUnitPercent   = "%"
+	LabUnitMmolMol   = "mmol/mol"
+	LabUnitMmolL     = "mmol/L"
+	LabUnitMgDL      = "mg/dL"
+	LabUnitMicromolL = "µmol/L"
+)
+
+// Вид показателя и единицы, совпадают со значениями models.VitalKind* и models.VitalUnit*
+const (
+	VitalWeight        = "weight"
+	VitalBloodPressure = "blood_pressure"
+	VitalKetones       = "ketones"
+
+	VitalUnitKg    = "kg"
+	VitalUnitLb    = "lb"
+	VitalUnitMmHg  = "mmHg"
+	VitalUnitMmolL = "mmol/L"
+)
+
+const (
+	// ClarifyThreshold — ниже этой уверенности бот переспрашивает пользователя
+	ClarifyThreshold = 0.6
+
+	MinGlucose = 1.0  // ммоль/л
+	MaxGlucose = 30.0 // ммоль/л
+	MaxInsulin = 100.0
+
+	mgdlPerMmol = 18.0
+)
+
+// Glucose — значение глюкозы
+type Glucose struct {
+	Value   float64 // ммоль/л
+	MgDL    bool    // значение было указано в мг/дл и пересчитано
+	Context string  // ContextFasting и т.д., пусто если не указан
+}
+
+// FoodItem — продукт из описания приема пищи
+type FoodItem struct {
+	Name     string
+	Quantity string // «150 г», «2 шт»
+}
+
+// Food — прием пищи
+type Food struct {
+	Description string // описание без служебных слов, как его написал пользователь
+	MealType    string // завтрак, обед, ужин, перекус; пусто если не указан
+	Items       []FoodItem
+	Carbs       *float64 // граммы углеводов, если указаны явно или в ХЕ
+}
+
+// Insulin — доза инсулина
+type Insulin struct {
+	Units float64
+	Type  string // InsulinBolus, InsulinBasal или пусто
+	Name  string // название препарата, если указано
+}
+
+// Lab — результат лабораторного анализа
+type Lab struct {
+	Kind  string // LabHbA1c и т.д.
+	Value float64
+	Unit  string // указанная или угаданная по значению единица
+}
+
+// Vital — измерение веса, давления или кетонов
+type Vital struct {
+	Kind      string  // VitalWeight и т.д.
+	Value     float64 // для давления — систолическое
+	Diastolic float64 // только для давления
+	Unit      string  // указанная единица или основная для вида
+}
+
+// Intent — одно распознанное намерение из сообщения
+type Intent struct {
+	Kind       Kind
+	Confidence float64 // от 0 до 1
+	Glucose    *Glucose
+	Food       *Food
+	Insulin    *Insulin
+	Lab        *Lab
+	Vital      *Vital
+
+	pos int // позиция в сообщении, по ней намерения упорядочиваются
+}
+
+// Result — результат разбора сообщения
+type Result struct {
+	Text    string
+	Intents []Intent
+	Time    time.Time // время записи; совпадает с now, если не указано
+	HasTime bool      // время указано в сообщении явно
+	// InvalidTime — несуществующее время из сообщения («25:99»); такое сообщение переспрашивается
+	InvalidTime string
+}
+
+// Confidence возвращает наименьшую уверенность среди намерений
+func (r Result) Confidence() float64 {
+	if len(r.Intents) == 0 {
+		return 0
+	}
+	confidence := 1.0
+	for _, intent := range r.Intents {
+		confidence = math.Min(confidence, intent.Confidence)
+	}
+	return confidence
+}
+
+// NeedsClarification сообщает, что сообщение понято неуверенно и его стоит уточнить.
+// Вопросы к ИИ не переспрашиваются.
+func (r Result) NeedsClarification() bool {
+	for _, intent := range r.Intents {
+		if intent.Kind != KindQuestion && intent.Confidence < ClarifyThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// First возвращает первое намерение указанного типа
+func (r Result) First(kind Kind) (Intent, bool) {
+	for _, intent := range r.Intents {
+		if intent.Kind == kind {
+			return intent, true
+		}
+	}
+	return Intent{}, false
+}
+
+// Parse разбирает сообщение. now задает текущее время для «вчера», «час назад» и т.п.
+func Parse(text string, now time.Time) Result {
+	p := &parser{text: text, tokens: tokenize(text), now: now, timeConfidence: 1}
+
+	p.parseTime()
+	p.parseContext()
+	p.parseLab()
+	p.parseVital()
+	p.parseInsulin()
+	p.parseGlucose()
+	p.parseFood()
+	p.parseQuestion()
+
+	for i := range p.intents {
+		p.intents[i].Confidence = math.Min(p.intents[i].Confidence, p.timeConfidence)
+	}
+	sort.SliceStable(p.intents, func(i, j int) bool {
+		return p.intents[i].pos < p.intents[j].pos
+	})
+
+	return Result{
+		Text:        strings.TrimSpace(text),
+		Intents:     p.intents,
+		Time:        p.time,
+		HasTime:     p.hasTime,
+		InvalidTime: p.invalidTime,
+	}
+}
+
+type parser struct {
+	text   string
+	tokens []token
+	now    time.Time
+
+	time           time.Time
+	hasTime        bool
+	timeConfidence float64
+	invalidTime    string
+
+	context      string
+	contextFound bool
+
+	intents []Intent
+}
+
+func (p *parser) word(i int) string {
+	if i < 0 || i >= len(p.tokens) || p.tokens[i].kind != tokenWord {
+		return ""
+	}
+	return p.tokens[i].text
+}
+
+func (p *parser) isNumber(i int) bool {
+	return i >= 0 && i < len(p.tokens) && p.tokens[i].kind == tokenNumber
+}
+
+func (p *parser) mark(r role, indexes ...int) {
+	for _, i := range indexes {
+		if i >= 0 && i < len(p.tokens) {
+			p.tokens[i].role = r
+		}
+	}
+}
+
+// parseTime распознает «вчера», «в 22:30», «в 8 утра», «утром», «2 часа назад», «полчаса назад»
+func (p *parser) parseTime() {
+	dayOffset, hasDay := 0, false
+	hour, minute, hasClock := 0, 0, false
+	daypartHour := -1
+	var ago time.Duration
+
+	for i := range p.tokens {
+		tok := &p.tokens[i]
+		if tok.used() {
+			continue
+		}
+
+		switch {
+		case tok.kind == tokenWord && tok.text == "назад":
+			if ago == 0 {
+				ago = p.parseAgo(i)
+			}
+		case tok.kind == tokenWord:
+			if offset, ok := dayOffsets[tok.text]; ok && !hasDay {
+				dayOffset, hasDay = offset, true
+				p.mark(roleTime, i)
+			} else if h, ok := daypartHours[tok.text]; ok && daypartHour == -1 && !p.isQuestion() {
+				// «утром 5.5». В вопросе «почему утром высокий сахар» это не время записи
+				daypartHour = h
+				p.mark(roleTime, i)
+			}
+		case p.isInvalidClock(i):
+			// «25:99» токенизатор оставляет числами
+			p.markInvalidTime(i, i+2)
+		case tok.kind == tokenNumber && tok.value > 23 && tok.value == math.Trunc(tok.value) && p.isHourNumber(i):
+			// «в 25 часов», «в 30 утра»
+			p.markInvalidTime(i, i+1)
+		case tok.kind == tokenClock && !hasClock:
+			hour, minute, hasClock = tok.hour, tok.minute, true
+			p.mark(roleTime, i)
+			if clockPreps[p.word(i-1)] {
+				p.mark(roleTime, i-1)
+			}
+			if dayparts[p.word(i+1)] {
+				hour = applyDaypart(hour, p.word(i+1))
+				p.mark(roleTime, i+1)
+			}
+		case tok.kind == tokenNumber && !hasClock && isWholeHour(tok.value):
+			if !p.isHourNumber(i) {
+				continue
+			}
+			next := p.word(i + 1)
+			hour, minute, hasClock = int(tok.value), 0, true
+			p.mark(roleTime, i, i+1)
+			if clockPreps[p.word(i-1)] {
+				p.mark(roleTime, i-1)
+			}
+			if dayparts[p.word(i+2)] {
+				hour = applyDaypart(hour, p.word(i+2))
+				p.mark(roleTime, i+2)
+			} else if hourDayparts[next] {
+				hour = applyDaypart(hour, next)
+			}
+		}
+	}
+
+	if !hasClock && daypartHour >= 0 {
+		hour, hasClock = daypartHour, true
+	}
+
+	p.time = p.now
+	switch {
+	case ago > 0:
+		p.time, p.hasTime = p.now.Add(-ago), true
+	case hasClock:
+		day := p.now.AddDate(0, 0, dayOffset)
+		p.time = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, p.now.Location())
+		p.hasTime = true
+		// «в 22:30» утром означает вчерашний вечер
+		if !hasDay && p.time.After(p.now.Add(5*time.Minute)) {
+			p.time = p.time.AddDate(0, 0, -1)
+		}
+	case hasDay:
+		p.time, p.hasTime = p.now.AddDate(0, 0, dayOffset), dayOffset != 0
+	}
+
+	// Записи в будущем почти наверняка ошибка, как и несуществующее время
+	if p.time.After(p.now.Add(5*time.Minute)) || p.invalidTime != "" {
+		p.timeConfidence = 0.4
+	}
+}
+
+// isHourNumber сообщает, что число в позиции i — час: «в 8 утра», «8 вечера», «в 9 часов».
+// «Сахар 8 утром» — это значение, а не время.
+func (p *parser) isHourNumber(i int) bool {
+	next := p.word(i + 1)
+	return hourDayparts[next] || hourWords[next] && clockPreps[p.word(i-1)] && p.word(i+2) != "назад"
+}
+
+// isInvalidClock сообщает, что с позиции i записано время, которого не бывает: «25:99», «7:60»
+func (p *parser) isInvalidClock(i int) bool {
+	if i+2 >= len(p.tokens) {
+		return false
+	}
+	hour, colon, minute := p.tokens[i], p.tokens[i+1], p.tokens[i+2]
+	return hour.kind == tokenNumber && colon.text == ":" && minute.kind == tokenNumber &&
+		hour.end == colon.start && colon.end == minute.start &&
+		len(hour.text) <= 2 && len(minute.text) == 2 &&
+		skipDigits(hour.text, 0) == len(hour.text) && skipDigits(minute.text, 0) == len(minute.text)
+}
+
+// markInvalidTime запоминает несуществующее время из токенов first..last, чтобы переспросить
+func (p *parser) markInvalidTime(first, last int) {
+	if p.invalidTime == "" {
+		p.invalidTime = strings.TrimSpace(p.text[p.tokens[first].start:p.tokens[last].end])
+	}
+	for j := first; j <= last; j++ {
+		p.mark(roleTime, j)
+	}
+	if clockPreps[p.word(first-1)] {
+		p.mark(roleTime, first-1)
+	}
+}
+
+// parseAgo разбирает выражение, заканчивающееся словом «назад» в позиции i
+func (p *parser) parseAgo(i int) time.Duration {
+	unit := p.word(i - 1)
+	var base time.Duration
+	switch {
+	case unit == "полчаса":
+		p.mark(roleTime, i-1, i)
+		return 30 * time.Minute
+	case hourWords[unit]:
+		base = time.Hour
+	case minuteWords[unit]:
+		base = time.Minute
+	default:
+		return 0
+	}
+
+	amount, first := 1.0, i-1
+	switch {
+	case p.isNumber(i-2) && !p.tokens[i-2].used() && p.tokens[i-2].value > 0 && p.tokens[i-2].value <= 48:
+		amount, first = p.tokens[i-2].value, i-2
+	case p.word(i-2) == "полтора" || p.word(i-2) == "полторы":
+		amount, first = 1.5, i-2
+	}
+
+	ago := time.Duration(amount * float64(base))
+	if ago > 48*time.Hour {
+		return 0
+	}
+	for j := first; j <= i; j++ {
+		p.mark(roleTime, j)
+	}
+	return ago
+}
+
+func isWholeHour(value float64) bool {
+	return value >= 0 && value <= 23 && value == math.Trunc(value)
+}
+
+func applyDaypart(hour int, daypart string) int {
+	switch daypart {
+	case "дня", "днем":
+		if hour >= 1 && hour <= 6 {
+			return hour + 12
+		}
+	case "вечера", "вечером":
+		if hour < 12 {
+			return hour + 12
+		}
+	case "ночи", "ночью":
+		if hour == 12 {
+			return 0
+		}
+		if hour >= 6 && hour < 12 {
+			return hour + 12
+		}
+	case "утра", "утром":
+		if hour == 12 {
+			return 0
+		}
+	}
+	return hour
+}
+
+// parseContext распознает контекст измерения: «натощак», «после ужина», «перед сном»
+func (p *parser) parseContext() {
+	for i := range p.tokens {
+		if p.tokens[i].used() {
+			continue
+		}
+
+		context, last := "", i
+		switch w := p.word(i); {
+		case w == "натощак":
+			context = ContextFasting
+		case w == "перед" && (p.word(i+1) == "сном" || p.word(i+1) == "сна"):
+			context, last = ContextBedtime, i+1
+		case w == "на" && p.word(i+1) == "ночь":
+			context, last = ContextBedtime, i+1
+		case (w == "до" || w == "перед") && mealNouns[p.word(i+1)]:
+			context, last = ContextBeforeMeal, i+1
+		case w == "после" && mealNouns[p.word(i+1)]:
+			context, last = ContextAfterMeal, i+1
+		}
+		if context == "" {
+			continue
+		}
+
+		if p.word(last+1) == "пищи" {
+			last++
+		}
+		for j := i; j <= last; j++ {
+			p.mark(roleContext, j)
+		}
+		// «через 2 часа после еды»
+		if context == ContextAfterMeal {
+			p.markDelayBefore(i)
+		}
+		if !p.contextFound {
+			p.context, p.contextFound = context, true
+		}
+	}
+}
+
+// markDelayBefore помечает «через 2 часа» / «2 часа» / «через час» перед позицией i
+func (p *parser) markDelayBefore(i int) {
+	unit := p.word(i - 1)
+	if !hourWords[unit] && !minuteWords[unit] && unit != "полчаса" {
+		return
+	}
+	first := i - 1
+	if p.isNumber(i - 2) {
+		first = i - 2
+	}
+	if p.word(first-1) == "через" {
+		first--
+	}
+	for j := first; j < i; j++ {
+		p.mark(roleContext, j)
+	}
+}
+
+// parseLab распознает результаты анализов: «HbA1c 6.8%», «гликированный гемоглобин 7,1»,
+// «холестерин 5.2», «креатинин 80 мкмоль/л». Значение ищется сразу после названия.
+func (p *parser) parseLab() {
+	for k := range p.tokens {
+		if p.tokens[k].used() {
+			continue
+		}
+		kind, last := p.labNameAt(k)
+		if kind == "" {
+			continue
+		}
+
+		for j := last + 1; j < len(p.tokens) && j <= last+3; j++ {
+			tok := &p.tokens[j]
+			if tok.used() || labFillers[tok.text] || (tok.kind == tokenPunct && tok.text != "%") {
+				continue
+			}
+			if !p.isNumber(j) {
+				break
+			}
+
+			lab := &Lab{Kind: kind, Value: tok.value}
+			confidence, end := 0.85, j
+			if unit, unitEnd, ok := p.labUnitAt(j + 1); ok {
+				lab.Unit, end, confidence = unit, unitEnd, 0.95
+			} else {
+				lab.Unit = guessLabUnit(kind, lab.Value)
+			}
+			if lab.Value <= 0 {
+				confidence = 0.3
+			}
+			p.markRange(roleLab, k, end)
+			p.intents = append(p.intents, Intent{Kind: KindLab, Confidence: confidence, Lab: lab, pos: k})
+			break
+		}
+	}
+}
+
+// labNameAt распознает название анализа с позиции i и возвращает его вид и последний токен названия
+func (p *parser) labNameAt(i int) (string, int) {
+	w := p.word(i)
+	switch {
+	case w == "hb" && p.isA1c(i+1):
+		return LabHbA1c, i + 3
+	case (w == "hba" || w == "a" || w == "а") && p.isA1c(i):
+		return LabHbA1c, i + 2
+	}
+	for kind, stems := range labStems {
+		if w != "" && hasStem(w, stems) {
+			return kind, i
+		}
+	}
+	return "", 0
+}
+
+// isA1c проверяет, что с позиции i записано слитно «…a1c»: HbA1c разбивается на «hba», «1» и «c»
+func (p *parser) isA1c(i int) bool {
+	if i+2 >= len(p.tokens) || !strings.HasSuffix(p.tokens[i].text, "a") && !strings.HasSuffix(p.tokens[i].text, "а") {
+		return false
+	}
+	digit, suffix := p.tokens[i+1], p.tokens[i+2]
+	return digit.text == "1" && (suffix.text == "c" || suffix.text == "с") &&
+		digit.start == p.tokens[i].end && suffix.start == digit.end
+}
+
+// labUnitAt распознает единицу анализа с позиции i: «%», «ммоль/моль», «ммоль/л», «мг/дл», «мкмоль/л»
+func (p *parser) labUnitAt(i int) (string, int, bool) {
+	if i >= len(p.tokens) {
+		return "", 0, false
+	}
+	if p.tokens[i].text == "%" {
+		return LabUnitPercent, i, true
+	}
+	if mmolUnits[p.word(i)] && i+2 < len(p.tokens) && p.tokens[i+1].text == "/" {
+		if w := p.word(i + 2); w == "моль" || w == "mol" {
+			return LabUnitMmolMol, i + 2, true
+		}
+	}
+	if last, ok := p.mmolUnitAt(i); ok {
+		return LabUnitMmolL, last, true
+	}
+	if last, ok := p.mgdlUnitAt(i); ok {
+		return LabUnitMgDL, last, true
+	}
+	if micromolUnits[p.word(i)] {
+		if i+2 < len(p.tokens) && p.tokens[i+1].text == "/" && (p.word(i+2) == "л" || p.word(i+2) == "l") {
+			return LabUnitMicromolL, i + 2, true
+		}
+		return LabUnitMicromolL, i, true
+	}
+	return "", 0, false
+}
+
+// guessLabUnit угадывает единицу по значению: HbA1c больше 20 бывает только в ммоль/моль,
+// холестерин больше 20 — в мг/дл, креатинин больше 22 — в мкмоль/л
+func guessLabUnit(kind string, value float64) string {
+	switch kind {
+	case LabHbA1c:
+		if value > 20 {
+			return LabUnitMmolMol
+		}
+		return LabUnitPercent
+	case LabCholesterol:
+		if value > 20 {
+			return LabUnitMgDL
+		}
+		return LabUnitMmolL
+	case LabCreatinine:
+		if value > 22 {
+			return LabUnitMicromolL
+		}
+		return LabUnitMgDL
+	}
+	return ""
+}
+
+// parseVital распознает показатели после названия: «вес 82.5 кг», «давление 130/85»,
+// «АД 140 на 90 мм рт. ст.», «кетоны 0.8». Без названия числа считаются глюкозой.
+func (p *parser) parseVital() {
+	for k := range p.tokens {
+		if p.tokens[k].used() {
+			continue
+		}
+		kind := vitalKind(p.word(k))
+		if kind == "" {
+			continue
+		}
+
+		for j := k + 1; j < len(p.tokens) && j <= k+3; j++ {
+			tok := &p.tokens[j]
+			if tok.used() || vitalFillers[tok.text] || tok.kind == tokenPunct {
+				continue
+			}
+			if !p.isNumber(j) {
+				break
+			}
+
+			vital := &Vital{Kind: kind, Value: tok.value}
+			confidence, end := 0.9, j
+			switch kind {
+			case VitalWeight:
+				vital.Unit = VitalUnitKg
+				if unit, last, ok := p.weightUnitAt(j + 1); ok {
+					vital.Unit, end, confidence = unit, last, 0.95
+				}
+			case VitalBloodPressure:
+				vital.Unit = VitalUnitMmHg
+				// Второе число через «/» или «на»: «130/85», «130 на 85»
+				if j+2 < len(p.tokens) && (p.tokens[j+1].text == "/" || p.word(j+1) == "на") && p.isNumber(j+2) {
+					vital.Diastolic, end = p.tokens[j+2].value, p.mmHgUnitEnd(j+2)
+				}
+				// Одно число или нижнее не меньше верхнего — давление не понять
+				if vital.Diastolic <= 0 || vital.Diastolic >= vital.Value {
+					confidence = 0.3
+				}
+			case VitalKetones:
+				vital.Unit = VitalUnitMmolL
+				if last, ok := p.mmolUnitAt(j + 1); ok {
+					end, confidence = last, 0.95
+				}
+			}
+			if vital.Value < 0 || (kind != VitalKetones && vital.Value == 0) {
+				confidence = 0.3
+			}
+			p.markRange(roleVital, k, end)
+			p.intents = append(p.intents, Intent{Kind: KindVital, Confidence: confidence, Vital: vital, pos: k})
+			break
+		}
+	}
+}
+
+// vitalKind возвращает вид показателя по его названию; пусто — не название
+func vitalKind(w string) string {
+	switch {
+	case w == "":
+		return ""
+	case weightWords[w]:
+		return VitalWeight
+	case pressureWords[w] || hasStem(w, pressureStems):
+		return VitalBloodPressure
+	case hasStem(w, ketoneStems):
+		return VitalKetones
+	}
+	return ""
+}
+
+// weightUnitAt распознает «кг» или «фунтов» с позиции i
+func (p *parser) weightUnitAt(i int) (string, int, bool) {
+	w := p.word(i)
+	switch {
+	case kgUnits[w]:
+		return VitalUnitKg, i, true
+	case lbUnits[w]:
+		return VitalUnitLb, i, true
+	}
+	return "", 0, false
+}
+
+// mmHgUnitEnd пропускает «мм рт. ст.» или «mmHg» после позиции last и возвращает последний токен единицы
+func (p *parser) mmHgUnitEnd(last int) int {
+	for i := last + 1; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if !mmHgWords[tok.text] && (tok.text != "." || i == last+1) {
+			break
+		}
+		last = i
+	}
+	return last
+}
+
+// parseInsulin распознает дозы: «6 ед хумалога», «уколол 4», «лантус 20»
+func (p *parser) parseInsulin() {
+	mentioned := false
+	for i := range p.tokens {
+		if w := p.word(i); w != "" && (hasStem(w, insulinKeywords) || hasStem(w, basalStems) || hasStem(w, bolusStems)) {
+			mentioned = true
+		}
+	}
+
+	// Число с единицами: «6 ед», «4ед.», «10 u»
+	for i := range p.tokens {
+		if !p.isNumber(i) || p.tokens[i].used() || !insulinUnits[p.word(i+1)] {
+			continue
+		}
+		p.mark(roleInsulin, i, i+1)
+		if i+2 < len(p.tokens) && p.tokens[i+2].text == "." && p.tokens[i+2].start == p.tokens[i+1].end {
+			p.mark(roleInsulin, i+2)
+		}
+		confidence := 0.9
+		if mentioned {
+			confidence = 0.95
+		}
+		p.addInsulin(i, confidence)
+	}
+
+	// Ключевое слово и число рядом: «уколол 4», «лантус 20», «инсулин короткий 5»
+	for k := range p.tokens {
+		w := p.word(k)
+		if w == "" || !(hasStem(w, insulinKeywords) || hasStem(w, basalStems) || hasStem(w, bolusStems)) {
+			continue
+		}
+		for j := k + 1; j <= k+3 && j < len(p.tokens); j++ {
+			if p.tokens[j].role == roleInsulin {
+				break
+			}
+			if p.tokens[j].used() {
+				continue
+			}
+			if p.isNumber(j) {
+				if quantityUnits[p.word(j+1)] || mmolUnits[p.word(j+1)] {
+					break
+				}
+				p.mark(roleInsulin, j)
+				p.addInsulin(j, 0.85)
+				break
+			}
+			if next := p.word(j); next == "" || !(hasStem(next, insulinKeywords) || hasStem(next, basalStems) || hasStem(next, bolusStems)) {
+				break
+			}
+		}
+	}
+
+	if len(p.insulinIntents()) == 0 {
+		return
+	}
+	for i := range p.tokens {
+		if w := p.word(i); w != "" && !p.tokens[i].used() &&
+			(hasStem(w, insulinKeywords) || hasStem(w, basalStems) || hasStem(w, bolusStems)) {
+			p.mark(roleInsulin, i)
+		}
+	}
+	// «сделал 4 ед»: глагол перед дозой не должен попасть в описание еды
+	for i := range p.tokens {
+		if insulinVerbs[p.word(i)] && !p.tokens[i].used() && i+1 < len(p.tokens) && p.tokens[i+1].role == roleInsulin {
+			p.mark(roleInsulin, i)
+		}
+	}
+}
+
+func (p *parser) insulinIntents() []Intent {
+	var intents []Intent
+	for _, intent := range p.intents {
+		if intent.Kind == KindInsulin {
+			intents = append(intents, intent)
+		}
+	}
+	return intents
+}
+
+// addInsulin добавляет дозу из числа в позиции i, определяя препарат по ближайшим словам
+func (p *parser) addInsulin(i int, confidence float64) {
+	insulin := &Insulin{Units: p.tokens[i].value}
+	if insulin.Units <= 0 || insulin.Units > MaxInsulin {
+		confidence = 0.3
+	}
+
+	// Ближайшее слово с названием или типом инсулина
+	for dist := 1; dist <= 4; dist++ {
+		for _, j := range []int{i + dist, i - dist} {
+			w := p.word(j)
+			if w == "" {
+				continue
+			}
+			if insulin.Name == "" {
+				for stem, name := range insulinNames {
+					if strings.HasPrefix(w, stem) {
+						insulin.Name = name
+					}
+				}
+			}
+			if insulin.Type == "" {
+				switch {
+				case hasStem(w, basalStems):
+					insulin.Type = InsulinBasal
+				case hasStem(w, bolusStems):
+					insulin.Type = InsulinBolus
+				}
+			}
+		}
+	}
+
+	p.intents = append(p.intents, Intent{Kind: KindInsulin, Confidence: confidence, Insulin: insulin, pos: i})
+}
+
+// parseGlucose распознает значение глюкозы: «сахар 7,8», «6.2 натощак», «140 мг/дл», «5.6»
+func (p *parser) parseGlucose() {
+	// Число с единицами измерения
+	for i := range p.tokens {
+		if !p.isNumber(i) || p.tokens[i].used() {
+			continue
+		}
+		if last, ok := p.mmolUnitAt(i + 1); ok {
+			p.markRange(roleGlucose, i, last)
+			p.markGlucoseKeywords()
+			p.addGlucose(i, p.tokens[i].value, false, 0.95)
+			return
+		}
+		if last, ok := p.mgdlUnitAt(i + 1); ok {
+			p.markRange(roleGlucose, i, last)
+			p.markGlucoseKeywords()
+			p.addGlucose(i, p.tokens[i].value/mgdlPerMmol, true, 0.95)
+			return
+		}
+	}
+
+	// Ключевое слово и число после него
+	for k := range p.tokens {
+		if !glucoseKeywords[p.word(k)] {
+			continue
+		}
+		for j := k + 1; j < len(p.tokens) && j <= k+4; j++ {
+			tok := &p.tokens[j]
+			if tok.used() || tok.kind == tokenPunct || glucoseFillers[tok.text] {
+				continue
+			}
+			if !p.isNumber(j) || p.followedByQuantity(j) {
+				break
+			}
+			p.mark(roleGlucose, k, j)
+			p.addKeywordGlucose(k, tok.value)
+			return
+		}
+	}
+
+	// Одно число без пояснений, например «5.6» или «6.2 натощак»
+	number := -1
+	for i := range p.tokens {
+		tok := &p.tokens[i]
+		switch {
+		case tok.used() || tok.kind == tokenPunct || fillerWords[tok.text]:
+			continue
+		case tok.kind == tokenNumber && number == -1 && !p.followedByQuantity(i):
+			number = i
+		default:
+			// В сообщении есть другие слова — число может означать что угодно
+			return
+		}
+	}
+	if number == -1 {
+		return
+	}
+
+	value := p.tokens[number].value
+	p.mark(roleGlucose, number)
+	switch {
+	case value >= MinGlucose && value <= MaxGlucose:
+		confidence := 0.9
+		if p.contextFound {
+			confidence = 0.95
+		} else if len(p.intents) > 0 {
+			confidence = 0.8
+		}
+		p.addGlucose(number, value, false, confidence)
+	case value > MaxGlucose && value <= 600 && !p.contextFound && len(p.intents) == 0:
+		// Похоже на мг/дл, но без единиц уверенности мало
+		p.addGlucose(number, value/mgdlPerMmol, true, 0.5)
+	case p.contextFound:
+		p.addGlucose(number, value, false, 0.3)
+	default:
+		p.intents = append(p.intents, Intent{Kind: KindUnknown})
+	}
+}
+
+func (p *parser) markGlucoseKeywords() {
+	for i := range p.tokens {
+		if !p.tokens[i].used() && glucoseKeywords[p.word(i)] {
+			p.mark(roleGlucose, i)
+		}
+	}
+}
+
+func (p *parser) addKeywordGlucose(at int, value float64) {
+	switch {
+	case value >= MinGlucose && value <= MaxGlucose:
+		p.addGlucose(at, value, false, 0.95)
+	case value > MaxGlucose && value <= 600:
+		p.addGlucose(at, value/mgdlPerMmol, true, 0.55)
+	default:
+		p.addGlucose(at, value, false, 0.3)
+	}
+}
+
+func (p *parser) addGlucose(at int, value float64, mgdl bool, confidence float64) {
+	value = math.Round(value*10) / 10
+	if value < MinGlucose || value > MaxGlucose {
+		confidence = math.Min(confidence, 0.3)
+	}
+	glucose := &Glucose{Value: value, MgDL: mgdl}
+	if p.contextFound {
+		glucose.Context = p.context
+	}
+	p.intents = append(p.intents, Intent{Kind: KindGlucose, Confidence: confidence, Glucose: glucose, pos: at})
+}
+
+// mmolUnitAt распознает «ммоль», «ммоль/л», «mmol/l» начиная с позиции i
+func (p *parser) mmolUnitAt(i int) (int, bool) {
+	if !mmolUnits[p.word(i)] {
+		return 0, false
+	}
+	if i+2 < len(p.tokens) && p.tokens[i+1].text == "/" && (p.word(i+2) == "л" || p.word(i+2) == "l") {
+		return i + 2, true
+	}
+	return i, true
+}
+
+// mgdlUnitAt распознает «мг/дл», «mg/dl» начиная с позиции i
+func (p *parser) mgdlUnitAt(i int) (int, bool) {
+	if !mgdlUnits[p.word(i)] || i+2 >= len(p.tokens) || p.tokens[i+1].text != "/" {
+		return 0, false
+	}
+	if w := p.word(i + 2); w == "дл" || w == "dl" {
+		return i + 2, true
+	}
+	return 0, false
+}
+
+func (p *parser) markRange(r role, first, last int) {
+	for j := first; j <= last; j++ {
+		p.mark(r, j)
+	}
+}
+
+// followedByQuantity сообщает, что число в позиции i — количество еды: «150 г», «2 яблока», «3 ХЕ»
+func (p *parser) followedByQuantity(i int) bool {
+	next := p.word(i + 1)
+	return quantityUnits[next] || breadUnits[next] || isFoodWord(next)
+}
+
+func isFoodWord(word string) bool {
+	return word != "" && (foodWords[word] || hasStem(word, foodStems))
+}
+
+func mealTypeOf(word string) string {
+	for prefix, mealType := range mealTypes {
+		if strings.HasPrefix(word, prefix) {
+			return mealType
+		}
+	}
+	return ""
+}
+
+// parseFood распознает прием пищи в оставшихся словах
+func (p *parser) parseFood() {
+	if p.isQuestion() {
+		// «Можно ли есть гречку?» — вопрос, а не запись
+		return
+	}
+
+	food := &Food{}
+	signal := 0.0 // уверенность по самому сильному признаку еды
+	var carbs float64
+	hasCarbs := false
+
+	for i := range p.tokens {
+		tok := &p.tokens[i]
+		if tok.used() {
+			continue
+		}
+
+		switch {
+		case tok.kind == tokenNumber && breadUnits[p.word(i+1)]:
+			carbs += tok.value * gramsPerBreadUnit
+			hasCarbs = true
+			p.mark(roleCarbs, i, i+1)
+			signal = math.Max(signal, 0.85)
+		case breadUnits[tok.text] && p.isNumber(i+1) && !p.tokens[i+1].used():
+			// «хе 3»
+			carbs += p.tokens[i+1].value * gramsPerBreadUnit
+			hasCarbs = true
+			p.mark(roleCarbs, i, i+1)
+			signal = math.Max(signal, 0.85)
+		case tok.kind == tokenNumber && gramUnits[p.word(i+1)] && carbWords[p.word(i+2)]:
+			carbs += tok.value
+			hasCarbs = true
+			p.mark(roleCarbs, i, i+1, i+2)
+			signal = math.Max(signal, 0.85)
+		case tok.kind != tokenWord:
+			continue
+		case foodVerbs[tok.text]:
+			p.mark(roleMeal, i)
+			if mealType, ok := mealVerbs[tok.text]; ok && food.MealType == "" {
+				food.MealType = mealType
+			}
+			signal = math.Max(signal, 0.9)
+		case mealTypeOf(tok.text) != "":
+			if food.MealType == "" {
+				food.MealType = mealTypeOf(tok.text)
+			}
+			p.mark(roleMeal, i)
+			if prev := p.word(i - 1); (prev == "на" || prev == "в" || prev == "за") && !p.tokens[i-1].used() {
+				p.mark(roleMeal, i-1)
+			}
+			signal = math.Max(signal, 0.9)
+		case isFoodWord(tok.text):
+			signal = math.Max(signal, 0.85)
+		}
+	}
+
+	// Описание из оставшихся токенов
+	var description []int
+	hasQuantity, hasWords := false, false
+	for i := range p.tokens {
+		tok := &p.tokens[i]
+		if tok.role == roleCarbs || !tok.used() {
+			description = append(description, i)
+			if tok.kind == tokenNumber && quantityUnits[p.word(i+1)] {
+				hasQuantity = true
+			}
+			if tok.kind == tokenWord && !fillerWords[tok.text] && !quantityUnits[tok.text] {
+				hasWords = true
+			}
+		}
+	}
+	if signal == 0 {
+		// Неизвестное блюдо с граммовкой: «плов 250 г»
+		if !hasQuantity || !hasWords {
+			return
+		}
+		signal = 0.65
+	}
+
+	description = p.trimSeparators(description)
+	for _, i := range description {
+		if p.tokens[i].role != roleCarbs {
+			p.mark(roleFood, i)
+		}
+	}
+	food.Description = p.spanText(description)
+	food.Items = p.foodItems(description)
+	if hasCarbs {
+		food.Carbs = &carbs
+	}
+
+	confidence := signal
+	if food.Description == "" {
+		// «обед» без описания — непонятно, что записывать
+		confidence = 0.4
+	}
+	pos := len(p.tokens)
+	for i := range p.tokens {
+		if r := p.tokens[i].role; r == roleFood || r == roleCarbs || r == roleMeal {
+			pos = i
+			break
+		}
+	}
+	p.intents = append(p.intents, Intent{Kind: KindFood, Confidence: confidence, Food: food, pos: pos})
+}
+
+// trimSeparators убирает союзы и знаки препинания по краям описания
+func (p *parser) trimSeparators(indexes []int) []int {
+	edge := func(i int) bool {
+		tok := p.tokens[i]
+		return tok.kind == tokenPunct || itemSeparators[tok.text] || fillerWords[tok.text]
+	}
+	for len(indexes) > 0 && edge(indexes[0]) {
+		indexes = indexes[1:]
+	}
+	for len(indexes) > 0 && edge(indexes[len(indexes)-1]) {
+		indexes = indexes[:len(indexes)-1]
+	}
+	return indexes
+}
+
+// spanText собирает текст токенов из исходного сообщения, сохраняя слитное написание «150г»
+func (p *parser) spanText(indexes []int) string {
+	var b strings.Builder
+	for n, i := range indexes {
+		tok := p.tokens[i]
+		attached := tok.kind == tokenPunct && strings.Contains(",.;:!?", tok.text)
+		if n > 0 && p.tokens[indexes[n-1]].end != tok.start && !attached {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.text[tok.start:tok.end])
+	}
+	return b.String()
+}
+
+// foodItems делит описание на продукты по союзам и запятым
+func (p *parser) foodItems(indexes []int) []FoodItem {
+	var items []FoodItem
+	var name, quantity []int
+
+	flush := func() {
+		name, quantity = p.trimSeparators(name), p.trimSeparators(quantity)
+		if len(name) > 0 {
+			items = append(items, FoodItem{Name: p.spanText(name), Quantity: p.quantityText(quantity)})
+		}
+		name, quantity = nil, nil
+	}
+
+	for n := 0; n < len(indexes); n++ {
+		i := indexes[n]
+		tok := p.tokens[i]
+		switch {
+		case tok.role == roleCarbs:
+			// Углеводы относятся ко всему приему пищи, а не к продукту
+		case itemSeparators[tok.text]:
+			flush()
+		case tok.kind == tokenNumber && n+1 < len(indexes) && quantityUnits[p.word(indexes[n+1])]:
+			quantity = append(quantity, i, indexes[n+1])
+			n++
+		case tok.kind == tokenNumber && n+1 < len(indexes) && isFoodWord(p.word(indexes[n+1])):
+			// «2 яблока»
+			quantity = append(quantity, i)
+		default:
+			name = append(name, i)
+		}
+	}
+	flush()
+
+	return items
+}
+
+// quantityText возвращает количество в виде «150 г»
+func (p *parser) quantityText(indexes []int) string {
+	var parts []string
+	for _, i := range indexes {
+		parts = append(parts, p.text[p.tokens[i].start:p.tokens[i].end])
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseQuestion превращает нераспознанный текст в вопрос к ИИ
+func (p *parser) parseQuestion() {
+	if len(p.intents) > 0 {
+		return
+	}
+
+	hasWords := false
+	for i := range p.tokens {
+		if p.tokens[i].kind == tokenWord && !p.tokens[i].used() {
+			hasWords = true
+		}
+	}
+	if !hasWords {
+		if len(p.tokens) > 0 {
+			p.intents = append(p.intents, Intent{Kind: KindUnknown})
+		}
+		return
+	}
+
+	confidence := 0.6
+	if p.isQuestion() {
+		confidence = 0.9
+	}
+	p.intents = append(p.intents, Intent{Kind: KindQuestion, Confidence: confidence})
+}
+
+// isQuestion сообщает, что сообщение выглядит как вопрос
+func (p *parser) isQuestion() bool {
+	for i := range p.tokens {
+		if p.tokens[i].text == "?" {
+			return true
+		}
+	}
+	return questionWords[p.firstWord()]
+}
+
+func (p *parser) firstWord() string {
+	for i := range p.tokens {
+		if p.tokens[i].kind == tokenWord {
+			return p.tokens[i].text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,347 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Воскресенье, 9 утра
+var testNow = time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+type parseCase struct {
+	input string
+	kinds []Kind
+
+	glucose float64
+	context string
+	mgdl    bool
+
+	units       float64
+	insulinType string
+	insulinName string
+
+	food  string
+	meal  string
+	carbs float64
+	items []FoodItem
+
+	lab      string
+	labValue float64
+	labUnit  string
+
+	vital          string
+	vitalValue     float64
+	vitalDiastolic float64
+	vitalUnit      string
+
+	time        string // пусто, если время не указано
+	invalidTime string
+	clarify     bool
+}
+
+func runParseCases(t *testing.T, cases []parseCase) {
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			result := Parse(tc.input, testNow)
+
+			var kinds []Kind
+			for _, intent := range result.Intents {
+				kinds = append(kinds, intent.Kind)
+			}
+			require.Equal(t, tc.kinds, kinds)
+			assert.Equal(t, tc.clarify, result.NeedsClarification(), "NeedsClarification")
+			assert.Equal(t, tc.invalidTime, result.InvalidTime)
+
+			if tc.time == "" {
+				assert.False(t, result.HasTime)
+				assert.Equal(t, testNow, result.Time)
+			} else {
+				assert.True(t, result.HasTime)
+				assert.Equal(t, tc.time, result.Time.Format("2006-01-02 15:04"))
+			}
+
+			if intent, ok := result.First(KindGlucose); ok {
+				assert.InDelta(t, tc.glucose, intent.Glucose.Value, 0.001)
+				assert.Equal(t, tc.context, intent.Glucose.Context)
+				assert.Equal(t, tc.mgdl, intent.Glucose.MgDL)
+			}
+			if intent, ok := result.First(KindInsulin); ok {
+				assert.Equal(t, tc.units, intent.Insulin.Units)
+				assert.Equal(t, tc.insulinType, intent.Insulin.Type)
+				assert.Equal(t, tc.insulinName, intent.Insulin.Name)
+			}
+			if intent, ok := result.First(KindFood); ok {
+				assert.Equal(t, tc.food, intent.Food.Description)
+				assert.Equal(t, tc.meal, intent.Food.MealType)
+				if tc.carbs == 0 {
+					assert.Nil(t, intent.Food.Carbs)
+				} else if assert.NotNil(t, intent.Food.Carbs) {
+					assert.InDelta(t, tc.carbs, *intent.Food.Carbs, 0.001)
+				}
+				if tc.items != nil {
+					assert.Equal(t, tc.items, intent.Food.Items)
+				}
+			}
+			if intent, ok := result.First(KindLab); ok {
+				assert.Equal(t, tc.lab, intent.Lab.Kind)
+				assert.Equal(t, tc.labValue, intent.Lab.Value)
+				assert.Equal(t, tc.labUnit, intent.Lab.Unit)
+			}
+			if intent, ok := result.First(KindVital); ok {
+				assert.Equal(t, tc.vital, intent.Vital.Kind)
+				assert.Equal(t, tc.vitalValue, intent.Vital.Value)
+				assert.Equal(t, tc.vitalDiastolic, intent.Vital.Diastolic)
+				assert.Equal(t, tc.vitalUnit, intent.Vital.Unit)
+			}
+		})
+	}
+}
+
+func TestParse_Glucose(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{input: "5.6", kinds: []Kind{KindGlucose}, glucose: 5.6},
+		{input: "5,6", kinds: []Kind{KindGlucose}, glucose: 5.6},
+		{input: "5", kinds: []Kind{KindGlucose}, glucose: 5},
+		{input: "10.25", kinds: []Kind{KindGlucose}, glucose: 10.3},
+		{input: " 7.1 ", kinds: []Kind{KindGlucose}, glucose: 7.1},
+		{input: "сахар 7,8", kinds: []Kind{KindGlucose}, glucose: 7.8},
+		{input: "Сахар 12.4", kinds: []Kind{KindGlucose}, glucose: 12.4},
+		{input: "сахар был 4.2", kinds: []Kind{KindGlucose}, glucose: 4.2},
+		{input: "глюкоза - 6.1", kinds: []Kind{KindGlucose}, glucose: 6.1},
+		{input: "ск 9", kinds: []Kind{KindGlucose}, glucose: 9},
+		{input: "sugar 5.5", kinds: []Kind{KindGlucose}, glucose: 5.5},
+		{input: "6.2 натощак", kinds: []Kind{KindGlucose}, glucose: 6.2, context: ContextFasting},
+		{input: "натощак 5.1", kinds: []Kind{KindGlucose}, glucose: 5.1, context: ContextFasting},
+		{input: "8.4 после еды", kinds: []Kind{KindGlucose}, glucose: 8.4, context: ContextAfterMeal},
+		{input: "до завтрака 5.9", kinds: []Kind{KindGlucose}, glucose: 5.9, context: ContextBeforeMeal},
+		{input: "перед обедом сахар 6", kinds: []Kind{KindGlucose}, glucose: 6, context: ContextBeforeMeal},
+		{input: "7 перед сном", kinds: []Kind{KindGlucose}, glucose: 7, context: ContextBedtime},
+		{input: "на ночь 6.6", kinds: []Kind{KindGlucose}, glucose: 6.6, context: ContextBedtime},
+		{input: "через 2 часа после еды 9.1", kinds: []Kind{KindGlucose}, glucose: 9.1, context: ContextAfterMeal},
+		{input: "сахар 8.8 через час после приема пищи", kinds: []Kind{KindGlucose}, glucose: 8.8, context: ContextAfterMeal},
+		{input: "7.8 ммоль", kinds: []Kind{KindGlucose}, glucose: 7.8},
+		{input: "7.8 ммоль/л", kinds: []Kind{KindGlucose}, glucose: 7.8},
+		{input: "6.5 mmol/l", kinds: []Kind{KindGlucose}, glucose: 6.5},
+		{input: "140 мг/дл", kinds: []Kind{KindGlucose}, glucose: 7.8, mgdl: true},
+		{input: "сахар 90 mg/dl", kinds: []Kind{KindGlucose}, glucose: 5, mgdl: true},
+		{input: "сахар 8 утром", kinds: []Kind{KindGlucose}, glucose: 8, time: "2026-10-18 08:00"},
+	})
+}
+
+func TestParse_GlucoseNeedsClarification(t *testing.T) {
+	runParseCases(t, []parseCase{
+		// Без единиц непонятно, мг/дл это или опечатка
+		{input: "150", kinds: []Kind{KindGlucose}, glucose: 8.3, mgdl: true, clarify: true},
+		{input: "сахар 45", kinds: []Kind{KindGlucose}, glucose: 2.5, mgdl: true, clarify: true},
+		{input: "сахар 0.3", kinds: []Kind{KindGlucose}, glucose: 0.3, clarify: true},
+		{input: "натощак 55.5", kinds: []Kind{KindGlucose}, glucose: 55.5, context: ContextFasting, clarify: true},
+		{input: "1000", kinds: []Kind{KindUnknown}, clarify: true},
+		{input: "-5.0", kinds: []Kind{KindUnknown}, clarify: true},
+		{input: "0", kinds: []Kind{KindUnknown}, clarify: true},
+		{input: "?", kinds: []Kind{KindUnknown}, clarify: true},
+	})
+}
+
+func TestParse_Time(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{input: "вчера в 22:30 сахар 7,8 после ужина", kinds: []Kind{KindGlucose}, glucose: 7.8, context: ContextAfterMeal, time: "2026-10-17 22:30"},
+		// Время позже текущего без указания дня — это вчера
+		{input: "в 22:30 сахар 7.8", kinds: []Kind{KindGlucose}, glucose: 7.8, time: "2026-10-17 22:30"},
+		{input: "в 7:15 5.4", kinds: []Kind{KindGlucose}, glucose: 5.4, time: "2026-10-18 07:15"},
+		{input: "сегодня в 8:05 сахар 6.0", kinds: []Kind{KindGlucose}, glucose: 6, time: "2026-10-18 08:05"},
+		{input: "в 8 утра 6.5", kinds: []Kind{KindGlucose}, glucose: 6.5, time: "2026-10-18 08:00"},
+		{input: "вчера в 10 вечера сахар 9.2", kinds: []Kind{KindGlucose}, glucose: 9.2, time: "2026-10-17 22:00"},
+		{input: "позавчера в 3 ночи 3.1", kinds: []Kind{KindGlucose}, glucose: 3.1, time: "2026-10-16 03:00"},
+		{input: "в 11 ночи сахар 8", kinds: []Kind{KindGlucose}, glucose: 8, time: "2026-10-17 23:00"},
+		{input: "в 2 дня сахар 7", kinds: []Kind{KindGlucose}, glucose: 7, time: "2026-10-17 14:00"},
+		{input: "полчаса назад сахар 5.5", kinds: []Kind{KindGlucose}, glucose: 5.5, time: "2026-10-18 08:30"},
+		{input: "2 часа назад 10.1", kinds: []Kind{KindGlucose}, glucose: 10.1, time: "2026-10-18 07:00"},
+		{input: "час назад 6", kinds: []Kind{KindGlucose}, glucose: 6, time: "2026-10-18 08:00"},
+		{input: "полтора часа назад 7.7", kinds: []Kind{KindGlucose}, glucose: 7.7, time: "2026-10-18 07:30"},
+		{input: "40 минут назад 4.9", kinds: []Kind{KindGlucose}, glucose: 4.9, time: "2026-10-18 08:20"},
+		{input: "сахар 6.3 15 мин назад", kinds: []Kind{KindGlucose}, glucose: 6.3, time: "2026-10-18 08:45"},
+		{input: "вчера сахар 5.5", kinds: []Kind{KindGlucose}, glucose: 5.5, time: "2026-10-17 09:00"},
+		{input: "сегодня 5.5", kinds: []Kind{KindGlucose}, glucose: 5.5},
+		// Часть суток без часа — типичный для нее час; вечер позже текущего времени — вчера
+		{input: "утром 5.5", kinds: []Kind{KindGlucose}, glucose: 5.5, time: "2026-10-18 08:00"},
+		{input: "вечером 7", kinds: []Kind{KindGlucose}, glucose: 7, time: "2026-10-17 19:00"},
+		{input: "вчера ночью 3.4", kinds: []Kind{KindGlucose}, glucose: 3.4, time: "2026-10-17 03:00"},
+		// Явно будущее время переспрашиваем
+		{input: "сегодня в 23:00 сахар 7", kinds: []Kind{KindGlucose}, glucose: 7, time: "2026-10-18 23:00", clarify: true},
+		{input: "завтра 5.5", kinds: []Kind{KindGlucose}, glucose: 5.5, time: "2026-10-19 09:00", clarify: true},
+		// Несуществующее время не подменяется текущим: переспрашиваем
+		{input: "вчера в 25:99 сахар 6", kinds: []Kind{KindGlucose}, glucose: 6, time: "2026-10-17 09:00", invalidTime: "25:99", clarify: true},
+		{input: "в 24:00 сахар 6", kinds: []Kind{KindGlucose}, glucose: 6, invalidTime: "24:00", clarify: true},
+		{input: "в 7:60 5.4", kinds: []Kind{KindGlucose}, glucose: 5.4, invalidTime: "7:60", clarify: true},
+		{input: "в 25 часов сахар 6", kinds: []Kind{KindGlucose}, glucose: 6, invalidTime: "25 часов", clarify: true},
+		{input: "в 30 утра 6.5", kinds: []Kind{KindGlucose}, glucose: 6.5, invalidTime: "30 утра", clarify: true},
+	})
+}
+
+func TestParse_Insulin(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{input: "уколол 6 ед новорапида", kinds: []Kind{KindInsulin}, units: 6, insulinType: InsulinBolus, insulinName: "НовоРапид"},
+		{input: "6 ед хумалога", kinds: []Kind{KindInsulin}, units: 6, insulinType: InsulinBolus, insulinName: "Хумалог"},
+		{input: "4ед.", kinds: []Kind{KindInsulin}, units: 4},
+		{input: "лантус 20", kinds: []Kind{KindInsulin}, units: 20, insulinType: InsulinBasal, insulinName: "Лантус"},
+		{input: "тресиба 18 ед", kinds: []Kind{KindInsulin}, units: 18, insulinType: InsulinBasal, insulinName: "Тресиба"},
+		{input: "уколол 4", kinds: []Kind{KindInsulin}, units: 4},
+		{input: "ввела 3.5 единицы фиаспа", kinds: []Kind{KindInsulin}, units: 3.5, insulinType: InsulinBolus, insulinName: "Фиасп"},
+		{input: "болюс 7", kinds: []Kind{KindInsulin}, units: 7, insulinType: InsulinBolus},
+		{input: "продленный 22 ед", kinds: []Kind{KindInsulin}, units: 22, insulinType: InsulinBasal},
+		{input: "инсулин 10 u", kinds: []Kind{KindInsulin}, units: 10},
+		{input: "в 22:00 туджео 16", kinds: []Kind{KindInsulin}, units: 16, insulinType: InsulinBasal, insulinName: "Туджео", time: "2026-10-17 22:00"},
+		{input: "уколол 500 ед", kinds: []Kind{KindInsulin}, units: 500, clarify: true},
+		{input: "сделал 4 ед", kinds: []Kind{KindInsulin}, units: 4},
+		{input: "поставила лантус 20", kinds: []Kind{KindInsulin}, units: 20, insulinType: InsulinBasal, insulinName: "Лантус"},
+	})
+}
+
+func TestParse_Food(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{
+			input: "гречка 150г и котлета", kinds: []Kind{KindFood}, food: "гречка 150г и котлета",
+			items: []FoodItem{{Name: "гречка", Quantity: "150 г"}, {Name: "котлета"}},
+		},
+		{input: "съел овсянку", kinds: []Kind{KindFood}, food: "овсянку"},
+		{input: "поел хлеб", kinds: []Kind{KindFood}, food: "хлеб"},
+		{input: "выпил кофе", kinds: []Kind{KindFood}, food: "кофе"},
+		{input: "ел мясо с овощами", kinds: []Kind{KindFood}, food: "мясо с овощами"},
+		{input: "чай с сахаром", kinds: []Kind{KindFood}, food: "чай с сахаром"},
+		{input: "кофе с молоком и сахаром 2 ложки", kinds: []Kind{KindFood}, food: "кофе с молоком и сахаром 2 ложки"},
+		{
+			input: "на обед борщ и хлеб 2 куска", kinds: []Kind{KindFood}, food: "борщ и хлеб 2 куска", meal: "обед",
+			items: []FoodItem{{Name: "борщ"}, {Name: "хлеб", Quantity: "2 куска"}},
+		},
+		{input: "на завтрак омлет", kinds: []Kind{KindFood}, food: "омлет", meal: "завтрак"},
+		{input: "Ужин: курица, рис", kinds: []Kind{KindFood}, food: "курица, рис", meal: "ужин",
+			items: []FoodItem{{Name: "курица"}, {Name: "рис"}}},
+		{input: "перекус яблоко", kinds: []Kind{KindFood}, food: "яблоко", meal: "перекус"},
+		{input: "пообедал супом", kinds: []Kind{KindFood}, food: "супом", meal: "обед"},
+		{input: "съел 2 яблока", kinds: []Kind{KindFood}, food: "2 яблока", items: []FoodItem{{Name: "яблока", Quantity: "2"}}},
+		{input: "банан + йогурт 150 мл", kinds: []Kind{KindFood}, food: "банан + йогурт 150 мл",
+			items: []FoodItem{{Name: "банан"}, {Name: "йогурт", Quantity: "150 мл"}}},
+		{input: "пицца 3 ХЕ", kinds: []Kind{KindFood}, food: "пицца 3 ХЕ", carbs: 36},
+		{input: "3 хе", kinds: []Kind{KindFood}, food: "3 хе", carbs: 36},
+		{input: "хе 3", kinds: []Kind{KindFood}, food: "хе 3", carbs: 36},
+		// Углеводы — про весь прием пищи, а не часть названия продукта
+		{input: "съел 2 булочки 30 г углеводов", kinds: []Kind{KindFood}, food: "2 булочки 30 г углеводов", carbs: 30,
+			items: []FoodItem{{Name: "булочки", Quantity: "2"}}},
+		{input: "паста 45 г углеводов", kinds: []Kind{KindFood}, food: "паста 45 г углеводов", carbs: 45},
+		// Незнакомое блюдо с граммовкой
+		{input: "фунчоза 200 г", kinds: []Kind{KindFood}, food: "фунчоза 200 г"},
+		{input: "вчера на ужин плов", kinds: []Kind{KindFood}, food: "плов", meal: "ужин", time: "2026-10-17 09:00"},
+		{input: "в 13:30 пообедал пельменями", kinds: []Kind{KindFood}, food: "пельменями", meal: "обед", time: "2026-10-17 13:30"},
+		{input: "съела творог в 8:40", kinds: []Kind{KindFood}, food: "творог", time: "2026-10-18 08:40"},
+		// Прием пищи без описания
+		{input: "обед", kinds: []Kind{KindFood}, meal: "обед", clarify: true},
+		{input: "завтрак был вкусный", kinds: []Kind{KindFood}, food: "вкусный", meal: "завтрак"},
+	})
+}
+
+func TestParse_Lab(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{input: "HbA1c 6.8%", kinds: []Kind{KindLab}, lab: LabHbA1c, labValue: 6.8, labUnit: LabUnitPercent},
+		{input: "hba1c: 7,2", kinds: []Kind{KindLab}, lab: LabHbA1c, labValue: 7.2, labUnit: LabUnitPercent},
+		{input: "Hb A1c 53 ммоль/моль", kinds: []Kind{KindLab}, lab: LabHbA1c, labValue: 53, labUnit: LabUnitMmolMol},
+		// В процентах HbA1c больше 20 не бывает
+		{input: "A1c 48", kinds: []Kind{KindLab}, lab: LabHbA1c, labValue: 48, labUnit: LabUnitMmolMol},
+		{input: "гликированный гемоглобин 6,5 %", kinds: []Kind{KindLab}, lab: LabHbA1c, labValue: 6.5, labUnit: LabUnitPercent},
+		{input: "холестерин 5.2", kinds: []Kind{KindLab}, lab: LabCholesterol, labValue: 5.2, labUnit: LabUnitMmolL},
+		{input: "общий холестерин 190 мг/дл", kinds: []Kind{KindLab}, lab: LabCholesterol, labValue: 190, labUnit: LabUnitMgDL},
+		{input: "креатинин 84 мкмоль/л", kinds: []Kind{KindLab}, lab: LabCreatinine, labValue: 84, labUnit: LabUnitMicromolL},
+		{input: "вчера креатинин 0.9", kinds: []Kind{KindLab}, lab: LabCreatinine, labValue: 0.9, labUnit: LabUnitMgDL, time: "2026-10-17 09:00"},
+		{input: "HbA1c 6.8%, сахар 7.1", kinds: []Kind{KindLab, KindGlucose}, lab: LabHbA1c, labValue: 6.8, labUnit: LabUnitPercent, glucose: 7.1},
+		{input: "HbA1c 0", kinds: []Kind{KindLab}, lab: LabHbA1c, labValue: 0, labUnit: LabUnitPercent, clarify: true},
+	})
+}
+
+func TestParse_Vital(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{input: "вес 82.5", kinds: []Kind{KindVital}, vital: VitalWeight, vitalValue: 82.5, vitalUnit: VitalUnitKg},
+		{input: "Вес 82,5 кг", kinds: []Kind{KindVital}, vital: VitalWeight, vitalValue: 82.5, vitalUnit: VitalUnitKg},
+		{input: "weight 180 lbs", kinds: []Kind{KindVital}, vital: VitalWeight, vitalValue: 180, vitalUnit: VitalUnitLb},
+		{input: "давление 130/85", kinds: []Kind{KindVital}, vital: VitalBloodPressure, vitalValue: 130, vitalDiastolic: 85, vitalUnit: VitalUnitMmHg},
+		{input: "АД 140 на 90 мм рт. ст.", kinds: []Kind{KindVital}, vital: VitalBloodPressure, vitalValue: 140, vitalDiastolic: 90, vitalUnit: VitalUnitMmHg},
+		{input: "артериальное давление 120/80", kinds: []Kind{KindVital}, vital: VitalBloodPressure, vitalValue: 120, vitalDiastolic: 80, vitalUnit: VitalUnitMmHg},
+		{input: "кетоны 0.8", kinds: []Kind{KindVital}, vital: VitalKetones, vitalValue: 0.8, vitalUnit: VitalUnitMmolL},
+		{input: "кетоны в крови 1,6 ммоль/л", kinds: []Kind{KindVital}, vital: VitalKetones, vitalValue: 1.6, vitalUnit: VitalUnitMmolL},
+		{input: "кетоны 0", kinds: []Kind{KindVital}, vital: VitalKetones, vitalValue: 0, vitalUnit: VitalUnitMmolL},
+		{input: "сахар 15.2, кетоны 1.1", kinds: []Kind{KindGlucose, KindVital}, glucose: 15.2, vital: VitalKetones, vitalValue: 1.1, vitalUnit: VitalUnitMmolL},
+		{input: "вчера в 8 утра вес 81", kinds: []Kind{KindVital}, vital: VitalWeight, vitalValue: 81, vitalUnit: VitalUnitKg, time: "2026-10-17 08:00"},
+		// Одного числа давления мало, нижнее больше верхнего — ошибка ввода
+		{input: "давление 130", kinds: []Kind{KindVital}, vital: VitalBloodPressure, vitalValue: 130, vitalUnit: VitalUnitMmHg, clarify: true},
+		{input: "давление 80/120", kinds: []Kind{KindVital}, vital: VitalBloodPressure, vitalValue: 80, vitalDiastolic: 120, vitalUnit: VitalUnitMmHg, clarify: true},
+	})
+}
+
+func TestParse_Mixed(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{input: "сахар 7.8, уколол 4 ед", kinds: []Kind{KindGlucose, KindInsulin}, glucose: 7.8, units: 4},
+		{input: "7.8 и 4 ед хумалога", kinds: []Kind{KindGlucose, KindInsulin}, glucose: 7.8, units: 4, insulinType: InsulinBolus, insulinName: "Хумалог"},
+		{input: "съел 2 яблока, сахар 9", kinds: []Kind{KindFood, KindGlucose}, food: "2 яблока", glucose: 9},
+		{
+			input: "сахар 6.1 до еды, гречка 150г, хумалог 5", kinds: []Kind{KindGlucose, KindFood, KindInsulin},
+			glucose: 6.1, context: ContextBeforeMeal, food: "гречка 150г", units: 5, insulinType: InsulinBolus, insulinName: "Хумалог",
+		},
+		{
+			input: "вчера в 19:00 ужин 4 хе, новорапид 5 ед", kinds: []Kind{KindFood, KindInsulin},
+			food: "4 хе", meal: "ужин", carbs: 48, units: 5, insulinType: InsulinBolus, insulinName: "НовоРапид", time: "2026-10-17 19:00",
+		},
+		{input: "сделал 4 ед и съел яблоко", kinds: []Kind{KindInsulin, KindFood}, units: 4, food: "яблоко", items: []FoodItem{{Name: "яблоко"}}},
+		{input: "натощак 5.8, лантус 18", kinds: []Kind{KindGlucose, KindInsulin}, glucose: 5.8, context: ContextFasting, units: 18, insulinType: InsulinBasal, insulinName: "Лантус"},
+	})
+}
+
+func TestParse_Questions(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{input: "как дела?", kinds: []Kind{KindQuestion}},
+		{input: "погода хорошая", kinds: []Kind{KindQuestion}},
+		// Раньше «ел» находилось внутри «хотел»
+		{input: "хотел спросить про диету", kinds: []Kind{KindQuestion}},
+		{input: "можно ли есть гречку?", kinds: []Kind{KindQuestion}},
+		{input: "что съесть на ужин", kinds: []Kind{KindQuestion}},
+		{input: "сколько углеводов в банане", kinds: []Kind{KindQuestion}},
+		{input: "почему утром высокий сахар", kinds: []Kind{KindQuestion}},
+		{input: "мне 7 лет", kinds: []Kind{KindQuestion}},
+		{input: "Привет", kinds: []Kind{KindQuestion}},
+		{input: "", kinds: nil},
+	})
+}
+
+func TestParse_Confidence(t *testing.T) {
+	assert.Equal(t, 0.0, Parse("", testNow).Confidence())
+	assert.Equal(t, 0.95, Parse("сахар 5.5", testNow).Confidence())
+	// Общая уверенность — минимальная среди намерений
+	assert.Equal(t, 0.8, Parse("7.8 и 4 ед хумалога", testNow).Confidence())
+
+	question := Parse("что такое хлебная единица?", testNow)
+	assert.Equal(t, 0.9, question.Confidence())
+	assert.False(t, question.NeedsClarification())
+	assert.Equal(t, "что такое хлебная единица?", question.Text)
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("Вчера в 22:30 сахар 7,8; 150г -5 ёж")
+
+	var texts []string
+	var kinds []tokenKind
+	for _, tok := range tokens {
+		texts = append(texts, tok.text)
+		kinds = append(kinds, tok.kind)
+	}
+	assert.Equal(t, []string{"вчера", "в", "22:30", "сахар", "7,8", ";", "150", "г", "-5", "еж"}, texts)
+	assert.Equal(t, []tokenKind{tokenWord, tokenWord, tokenClock, tokenWord, tokenNumber, tokenPunct, tokenNumber, tokenWord, tokenNumber, tokenWord}, kinds)
+	assert.Equal(t, 7.8, tokens[4].value)
+	assert.Equal(t, -5.0, tokens[8].value)
+	assert.Equal(t, 22, tokens[2].hour)
+	assert.Equal(t, 30, tokens[2].minute)
+
+	// Недопустимое время разбирается как числа
+	tokens = tokenize("25:99")
+	require.Len(t, tokens, 3)
+	assert.Equal(t, tokenNumber, tokens[0].kind)
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenNumber
+	tokenClock // время в формате 22:30
+	tokenPunct
+)
+
+// role отмечает, какой части записи принадлежит токен
+type role int
+
+const (
+	roleNone role = iota
+	roleTime
+	roleContext
+	roleInsulin
+	roleGlucose
+	roleLab
+	roleVital
+	roleMeal   // прием пищи и глаголы «съел», «выпил» — в описание еды не попадают
+	roleFood   // часть описания еды
+	roleCarbs  // углеводы «3 ХЕ», «30 г углеводов» — в описании еды, но не в списке продуктов
+	roleFiller // служебные слова, не влияющие на смысл
+)
+
+type token struct {
+	kind   tokenKind
+	text   string  // в нижнем регистре, ё заменена на е
+	value  float64 // для чисел
+	hour   int     // для времени
+	minute int
+	start  int // байтовые позиции в исходном тексте
+	end    int
+	role   role
+}
+
+func (t *token) used() bool {
+	return t.role != roleNone
+}
+
+// tokenize разбивает текст на слова, числа, время и знаки препинания.
+// Десятичная запятая («7,8») считается частью числа.
+func tokenize(text string) []token {
+	var tokens []token
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		switch {
+		case unicode.IsSpace(r) || r == utf8.RuneError:
+			i += size
+		case isDigit(r) || (r == '-' && startsNumber(text, i)):
+			tok := scanNumber(text, i)
+			tokens = append(tokens, tok)
+			i = tok.end
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsLetter(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: normalizeWord(text[start:i]), start: start, end: i})
+		default:
+			tokens = append(tokens, token{kind: tokenPunct, text: string(r), start: i, end: i + size})
+			i += size
+		}
+	}
+
+	return tokens
+}
+
+// startsNumber проверяет, что минус в позиции i — знак числа, а не тире
+func startsNumber(text string, i int) bool {
+	if i+1 >= len(text) || !isDigit(rune(text[i+1])) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsSpace(prev)
+}
+
+func scanNumber(text string, start int) token {
+	i := start
+	if text[i] == '-' {
+		i++
+	}
+	i = skipDigits(text, i)
+	intEnd := i
+
+	// Время: 1-2 цифры, двоеточие и ровно 2 цифры
+	if text[start] != '-' && intEnd-start <= 2 && i < len(text) && text[i] == ':' {
+		minEnd := skipDigits(text, i+1)
+		if minEnd-(i+1) == 2 {
+			hour, _ := strconv.Atoi(text[start:intEnd])
+			minute, _ := strconv.Atoi(text[i+1 : minEnd])
+			if hour < 24 && minute < 60 {
+				return token{kind: tokenClock, text: text[start:minEnd], hour: hour, minute: minute, start: start, end: minEnd}
+			}
+		}
+	}
+
+	// Дробная часть через точку или запятую
+	if i+1 < len(text) && (text[i] == '.' || text[i] == ',') && isDigit(rune(text[i+1])) {
+		i = skipDigits(text, i+1)
+	}
+
+	raw := text[start:i]
+	value, _ := strconv.ParseFloat(strings.Replace(raw, ",", ".", 1), 64)
+	return token{kind: tokenNumber, text: raw, value: value, start: start, end: i}
+}
+
+func skipDigits(text string, i int) int {
+	for i < len(text) && isDigit(rune(text[i])) {
+		i++
+	}
+	return i
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func normalizeWord(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+}
@@ -0,0 +1,644 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"diabetbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// NewGorm создает хранилища поверх подключения GORM
+func NewGorm(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:      NewGormUserRepository(db),
+		Glucose:    NewGormGlucoseRepository(db),
+		Food:       NewGormFoodRepository(db),
+		Insulin:    NewGormInsulinRepository(db),
+		AIUsage:    NewGormAIUsageRepository(db),
+		ImportJobs: NewGormImportJobRepository(db),
+		Shares:     NewGormShareRepository(db),
+		Alerts:     NewGormAlertRepository(db),
+		Links:      NewGormPublicLinkRepository(db),
+		Digests:    NewGormDigestRepository(db),
+		Labs:       NewGormLabRepository(db),
+		Vitals:     NewGormVitalRepository(db),
+	}
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func NewGormUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) GetByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) GetByTelegramID(telegramID int64) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("telegram_id = ?", telegramID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) GetByNightscoutSecretHash(hash string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("nightscout_secret_hash = ?", hash).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) Create(user *models.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *gormUserRepository) Update(id uint, updates map[string]interface{}) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *gormUserRepository) UpdateByTelegramID(telegramID int64, updates map[string]interface{}) error {
+	return r.db.Model(&models.User{}).Where("telegram_id = ?", telegramID).Updates(updates).Error
+}
+
+// gormRecords реализует общие операции над записями пользователя.
+// timeColumn — колонка времени события, по ней фильтруются и сортируются записи.
+type gormRecords[T any] struct {
+	db         *gorm.DB
+	timeColumn string
+}
+
+func (r gormRecords[T]) Create(record *T) error {
+	return r.db.Create(record).Error
+}
+
+// importBatchSize — число строк в одном INSERT при пакетном сохранении
+const importBatchSize = 500
+
+func (r gormRecords[T]) CreateBatch(records []T) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(records, importBatchSize).Error
+	})
+}
+
+func (r gormRecords[T]) GetByID(userID, id uint) (*T, error) {
+	var record T
+	if err := r.db.Where("user_id = ? AND id = ?", userID, id).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r gormRecords[T]) ListSince(userID uint, since time.Time) ([]T, error) {
+	var records []T
+	err := r.db.Where("user_id = ? AND "+r.timeColumn+" >= ?", userID, since).
+		Order(r.timeColumn + " DESC").
+		Find(&records).Error
+	return records, err
+}
+
+// list выбирает записи по ListQuery; filterColumn сравнивается с filterValue, если оно задано
+func (r gormRecords[T]) list(userID uint, query ListQuery, filterColumn, filterValue string) ([]T, error) {
+	tx := r.db.Where("user_id = ?", userID)
+	if !query.From.IsZero() {
+		tx = tx.Where(r.timeColumn+" >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where(r.timeColumn+" < ?", query.To)
+	}
+	if filterValue != "" {
+		tx = tx.Where(filterColumn+" = ?", filterValue)
+	}
+
+	op, direction := "<", " DESC"
+	if query.Ascending {
+		op, direction = ">", " ASC"
+	}
+	if query.After != nil {
+		tx = tx.Where("("+r.timeColumn+" "+op+" ? OR ("+r.timeColumn+" = ? AND id "+op+" ?))",
+			query.After.At, query.After.At, query.After.ID)
+	}
+	tx = tx.Order(r.timeColumn + direction).Order("id" + direction)
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+
+	var records []T
+	err := tx.Find(&records).Error
+	return records, err
+}
+
+func (r gormRecords[T]) Update(userID, id uint, updates map[string]interface{}) error {
+	var record T
+	return r.db.Model(&record).Where("user_id = ? AND id = ?", userID, id).Updates(updates).Error
+}
+
+func (r gormRecords[T]) Delete(userID, id uint) error {
+	var record T
+	return r.db.Where("user_id = ? AND id = ?", userID, id).Delete(&record).Error
+}
+
+func (r gormRecords[T]) Restore(userID, id uint, deletedAfter time.Time) error {
+	var record T
+	result := r.db.Unscoped().Model(&record).
+		Where("user_id = ? AND id = ? AND deleted_at >= ?", userID, id, deletedAfter).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormRecords[T]) DeleteAllByUser(userID uint) error {
+	var record T
+	return r.db.Where("user_id = ?", userID).Delete(&record).Error
+}
+
+type gormGlucoseRepository struct {
+	gormRecords[models.GlucoseRecord]
+}
+
+func NewGormGlucoseRepository(db *gorm.DB) GlucoseRepository {
+	return &gormGlucoseRepository{gormRecords[models.GlucoseRecord]{db: db, timeColumn: "measured_at"}}
+}
+
+func (r *gormGlucoseRepository) List(userID uint, query ListQuery, measurementContext string) ([]models.GlucoseRecord, error) {
+	return r.list(userID, query, "measurement_context", measurementContext)
+}
+
+func (r *gormGlucoseRepository) ListBySource(userID uint, query ListQuery, source string) ([]models.GlucoseRecord, error) {
+	return r.list(userID, query, "source", source)
+}
+
+func (r *gormGlucoseRepository) Latest(userID uint) (*models.GlucoseRecord, error) {
+	var record models.GlucoseRecord
+	if err := r.db.Where("user_id = ?", userID).Order("measured_at DESC").First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *gormGlucoseRepository) Stats(userID uint, since time.Time) (*GlucoseStats, error) {
+	var stats GlucoseStats
+	err := r.db.Model(&models.GlucoseRecord{}).
+		Where("user_id = ? AND measured_at >= ?", userID, since).
+		Select("AVG(value) as average, MIN(value) as min, MAX(value) as max, COUNT(*) as count").
+		Scan(&stats).Error
+	return &stats, err
+}
+
+func (r *gormGlucoseRepository) Buckets(userID uint, query BucketQuery) ([]GlucoseBucket, error) {
+	size := int64(query.Size / time.Second)
+	offset := int64(query.Offset / time.Second)
+	// Номер интервала — целая часть (Unix время + смещение) / размер
+	bucket := fmt.Sprintf("CAST(FLOOR((EXTRACT(EPOCH FROM measured_at) + %d) / %d) AS BIGINT)", offset, size)
+	if r.db.Dialector.Name() == "sqlite" {
+		bucket = fmt.Sprintf("((CAST(strftime('%%s', measured_at) AS INTEGER) + %d) / %d)", offset, size)
+	}
+
+	tx := r.db.Model(&models.GlucoseRecord{}).
+		Where("user_id = ? AND measured_at >= ? AND measured_at < ?", userID, query.From, query.To)
+	if query.Source != "" {
+		tx = tx.Where("source = ?", query.Source)
+	}
+
+	var rows []struct {
+		Bucket  int64
+		Count   int64
+		Average float64
+		Min     float64
+		Max     float64
+	}
+	err := tx.Select(bucket + " AS bucket, COUNT(*) AS count, AVG(value) AS average, MIN(value) AS min, MAX(value) AS max").
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]GlucoseBucket, len(rows))
+	for i, row := range rows {
+		buckets[i] = GlucoseBucket{
+			Start:   time.Unix(row.Bucket*size-offset, 0),
+			Count:   row.Count,
+			Average: row.Average,
+			Min:     row.Min,
+			Max:     row.Max,
+		}
+	}
+	return buckets, nil
+}
+
+func (r *gormGlucoseRepository) DeleteSourceBefore(source string, before time.Time) (int64, error) {
+	result := r.db.Unscoped().Where("source = ? AND measured_at < ?", source, before).Delete(&models.GlucoseRecord{})
+	return result.RowsAffected, result.Error
+}
+
+type gormFoodRepository struct {
+	gormRecords[models.FoodRecord]
+}
+
+func NewGormFoodRepository(db *gorm.DB) FoodRepository {
+	return &gormFoodRepository{gormRecords[models.FoodRecord]{db: db, timeColumn: "consumed_at"}}
+}
+
+func (r *gormFoodRepository) List(userID uint, query ListQuery, foodType string) ([]models.FoodRecord, error) {
+	return r.list(userID, query, "food_type", foodType)
+}
+
+func (r *gormFoodRepository) ListByTypeSince(userID uint, foodType string, since time.Time) ([]models.FoodRecord, error) {
+	var records []models.FoodRecord
+	err := r.db.Where("user_id = ? AND food_type = ? AND consumed_at >= ?", userID, foodType, since).
+		Order("consumed_at DESC").
+		Find(&records).Error
+	return records, err
+}
+
+func (r *gormFoodRepository) Totals(userID uint, from, to time.Time) (*FoodTotals, error) {
+	var totals FoodTotals
+	err := r.db.Model(&models.FoodRecord{}).
+		Where("user_id = ? AND consumed_at >= ? AND consumed_at < ?", userID, from, to).
+		Select("COUNT(*) as count, COALESCE(SUM(calories), 0) as calories, COALESCE(SUM(carbs), 0) as carbs").
+		Scan(&totals).Error
+	return &totals, err
+}
+
+type gormInsulinRepository struct {
+	gormRecords[models.InsulinRecord]
+}
+
+func NewGormInsulinRepository(db *gorm.DB) InsulinRepository {
+	return &gormInsulinRepository{gormRecords[models.InsulinRecord]{db: db, timeColumn: "injected_at"}}
+}
+
+func (r *gormInsulinRepository) List(userID uint, query ListQuery) ([]models.InsulinRecord, error) {
+	return r.list(userID, query, "", "")
+}
+
+type gormLabRepository struct {
+	gormRecords[models.LabResult]
+}
+
+func NewGormLabRepository(db *gorm.DB) LabRepository {
+	return &gormLabRepository{gormRecords[models.LabResult]{db: db, timeColumn: "taken_at"}}
+}
+
+func (r *gormLabRepository) List(userID uint, query ListQuery, kind string) ([]models.LabResult, error) {
+	return r.list(userID, query, "kind", kind)
+}
+
+type gormVitalRepository struct {
+	gormRecords[models.Vital]
+}
+
+func NewGormVitalRepository(db *gorm.DB) VitalRepository {
+	return &gormVitalRepository{gormRecords[models.Vital]{db: db, timeColumn: "measured_at"}}
+}
+
+func (r *gormVitalRepository) List(userID uint, query ListQuery, kind string) ([]models.Vital, error) {
+	return r.list(userID, query, "kind", kind)
+}
+
+type gormAIUsageRepository struct {
+	db *gorm.DB
+}
+
+func NewGormAIUsageRepository(db *gorm.DB) AIUsageRepository {
+	return &gormAIUsageRepository{db: db}
+}
+
+func (r *gormAIUsageRepository) Get(userID uint, date string) (*models.AIUsage, error) {
+	var usage models.AIUsage
+	if err := r.db.Where("user_id = ? AND date = ?", userID, date).First(&usage).Error; err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func (r *gormAIUsageRepository) Create(usage *models.AIUsage) error {
+	return r.db.Create(usage).Error
+}
+
+func (r *gormAIUsageRepository) Save(usage *models.AIUsage) error {
+	return r.db.Save(usage).Error
+}
+
+func (r *gormAIUsageRepository) DeleteBefore(date string) (int64, error) {
+	result := r.db.Where("date < ?", date).Delete(&models.AIUsage{})
+	return result.RowsAffected, result.Error
+}
+
+type gormImportJobRepository struct {
+	db *gorm.DB
+}
+
+func NewGormImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &gormImportJobRepository{db: db}
+}
+
+func (r *gormImportJobRepository) Create(job *models.ImportJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *gormImportJobRepository) GetByID(userID, id uint) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := r.db.Where("user_id = ? AND id = ?", userID, id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *gormImportJobRepository) Update(userID, id uint, updates map[string]interface{}) error {
+	return r.db.Model(&models.ImportJob{}).Where("user_id = ? AND id = ?", userID, id).Updates(updates).Error
+}
+
+func (r *gormImportJobRepository) Claim(userID, id uint, status string, updates map[string]interface{}) error {
+	// Условие на статус не дает двум подтверждениям сохранить измерения дважды
+	result := r.db.Model(&models.ImportJob{}).Where("user_id = ? AND id = ? AND status = ?", userID, id, status).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormShareRepository struct {
+	db *gorm.DB
+}
+
+func NewGormShareRepository(db *gorm.DB) ShareRepository {
+	return &gormShareRepository{db: db}
+}
+
+func (r *gormShareRepository) Create(share *models.Share) error {
+	return r.db.Create(share).Error
+}
+
+func (r *gormShareRepository) GetByID(id uint) (*models.Share, error) {
+	var share models.Share
+	if err := r.db.First(&share, id).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *gormShareRepository) GetByTokenHash(hash string) (*models.Share, error) {
+	var share models.Share
+	if err := r.db.Where("token_hash = ?", hash).First(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *gormShareRepository) Get(ownerID, granteeID uint) (*models.Share, error) {
+	var share models.Share
+	if err := r.db.Where("owner_id = ? AND grantee_id = ?", ownerID, granteeID).First(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *gormShareRepository) ListByOwner(ownerID uint) ([]models.Share, error) {
+	var shares []models.Share
+	err := r.db.Where("owner_id = ?", ownerID).Order("created_at DESC, id DESC").Find(&shares).Error
+	return shares, err
+}
+
+func (r *gormShareRepository) ListByGrantee(granteeID uint) ([]models.Share, error) {
+	var shares []models.Share
+	err := r.db.Where("grantee_id = ?", granteeID).Order("created_at DESC, id DESC").Find(&shares).Error
+	return shares, err
+}
+
+func (r *gormShareRepository) Accept(id, granteeID uint, at time.Time) error {
+	// Условие на grantee_id не дает двоим принять одно приглашение одновременно
+	result := r.db.Model(&models.Share{}).Where("id = ? AND grantee_id IS NULL", id).
+		Updates(map[string]interface{}{"grantee_id": granteeID, "accepted_at": at})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormShareRepository) Update(id uint, updates map[string]interface{}) error {
+	return r.db.Model(&models.Share{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *gormShareRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Share{}, id).Error
+}
+
+type gormAlertRepository struct {
+	db *gorm.DB
+}
+
+func NewGormAlertRepository(db *gorm.DB) AlertRepository {
+	return &gormAlertRepository{db: db}
+}
+
+func (r *gormAlertRepository) GetRule(userID uint) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	if err := r.db.Where("user_id = ?", userID).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *gormAlertRepository) SaveRule(rule *models.AlertRule) error {
+	return r.db.Save(rule).Error
+}
+
+func (r *gormAlertRepository) Create(alert *models.Alert) error {
+	err := r.db.Create(alert).Error
+	if translator, ok := r.db.Dialector.(gorm.ErrorTranslator); ok && err != nil {
+		// Вторая незакрытая тревога нарушает idx_alerts_user_active
+		err = translator.Translate(err)
+	}
+	return err
+}
+
+func (r *gormAlertRepository) GetByID(id uint) (*models.Alert, error) {
+	var alert models.Alert
+	if err := r.db.First(&alert, id).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *gormAlertRepository) Active(userID uint) (*models.Alert, error) {
+	var alert models.Alert
+	err := r.db.Where("user_id = ? AND status IN ?", userID,
+		[]string{models.AlertStatusOpen, models.AlertStatusAcknowledged, models.AlertStatusEscalated, models.AlertStatusMuted}).
+		Order("created_at DESC, id DESC").First(&alert).Error
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *gormAlertRepository) ListDue(at time.Time) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.Where("status IN ? AND recheck_by <= ?", []string{models.AlertStatusOpen, models.AlertStatusMuted}, at).
+		Order("recheck_by, id").Find(&alerts).Error
+	return alerts, err
+}
+
+func (r *gormAlertRepository) List(userID uint, limit int) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(limit).Find(&alerts).Error
+	return alerts, err
+}
+
+func (r *gormAlertRepository) Update(id uint, updates map[string]interface{}) error {
+	return r.db.Model(&models.Alert{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *gormAlertRepository) Claim(id uint, status string, updates map[string]interface{}) error {
+	// Условие на статус не дает двум репликам разослать одну тревогу дважды
+	result := r.db.Model(&models.Alert{}).Where("id = ? AND status = ?", id, status).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormPublicLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewGormPublicLinkRepository(db *gorm.DB) PublicLinkRepository {
+	return &gormPublicLinkRepository{db: db}
+}
+
+func (r *gormPublicLinkRepository) Create(link *models.PublicLink) error {
+	return r.db.Create(link).Error
+}
+
+func (r *gormPublicLinkRepository) GetByID(userID, id uint) (*models.PublicLink, error) {
+	var link models.PublicLink
+	if err := r.db.Where("user_id = ? AND id = ?", userID, id).First(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *gormPublicLinkRepository) GetByTokenHash(hash string) (*models.PublicLink, error) {
+	var link models.PublicLink
+	if err := r.db.Where("token_hash = ?", hash).First(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *gormPublicLinkRepository) ListByUser(userID uint) ([]models.PublicLink, error) {
+	var links []models.PublicLink
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&links).Error
+	return links, err
+}
+
+func (r *gormPublicLinkRepository) Delete(userID, id uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.PublicLink{}, id).Error
+}
+
+func (r *gormPublicLinkRepository) LogAccess(access *models.PublicLinkAccess) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(access).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PublicLink{}).Where("id = ?", access.LinkID).Updates(map[string]interface{}{
+			"access_count":     gorm.Expr("access_count + 1"),
+			"last_accessed_at": access.AccessedAt,
+		}).Error
+	})
+}
+
+func (r *gormPublicLinkRepository) ListAccesses(linkID uint, limit int) ([]models.PublicLinkAccess, error) {
+	var accesses []models.PublicLinkAccess
+	err := r.db.Where("link_id = ?", linkID).Order("accessed_at DESC, id DESC").Limit(limit).Find(&accesses).Error
+	return accesses, err
+}
+
+type gormDigestRepository struct {
+	db *gorm.DB
+}
+
+func NewGormDigestRepository(db *gorm.DB) DigestRepository {
+	return &gormDigestRepository{db: db}
+}
+
+func (r *gormDigestRepository) GetSubscription(userID uint) (*models.DigestSubscription, error) {
+	var sub models.DigestSubscription
+	if err := r.db.Where("user_id = ?", userID).First(&sub).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *gormDigestRepository) SaveSubscription(sub *models.DigestSubscription) error {
+	return r.db.Save(sub).Error
+}
+
+func (r *gormDigestRepository) ListSubscribed() ([]models.DigestSubscription, error) {
+	var subs []models.DigestSubscription
+	err := r.db.Where("enabled = ?", true).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+func (r *gormDigestRepository) GetByPeriod(userID uint, from time.Time) (*models.Digest, error) {
+	var digest models.Digest
+	if err := r.db.Where("user_id = ? AND period_from = ?", userID, from).First(&digest).Error; err != nil {
+		return nil, err
+	}
+	return &digest, nil
+}
+
+func (r *gormDigestRepository) Save(digest *models.Digest) error {
+	// Пересобранная сводка не должна затирать отметку об отправке, поставленную другой репликой
+	return r.db.Omit("sent_at").Save(digest).Error
+}
+
+func (r *gormDigestRepository) MarkSent(id uint, at time.Time) error {
+	result := r.db.Model(&models.Digest{}).Where("id = ? AND sent_at IS NULL", id).Update("sent_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormDigestRepository) List(userID uint, limit int) ([]models.Digest, error) {
+	var digests []models.Digest
+	err := r.db.Where("user_id = ?", userID).Order("period_from DESC, id DESC").Limit(limit).Find(&digests).Error
+	return digests, err
+}
+
+func (r *gormDigestRepository) DeleteAllByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.Digest{}).Error
+}
@@ -0,0 +1,1130 @@
+// Package memory — хранилища в памяти для тестов сервисов без базы данных.
+// Повторяют поведение GORM-реализации: мягкое удаление, сортировку и обновление по именам колонок.
+package memory
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// NewRepositories создает пустые хранилища всех агрегатов
+func NewRepositories() *repository.Repositories {
+	return &repository.Repositories{
+		Users:      NewUserRepository(),
+		Glucose:    NewGlucoseRepository(),
+		Food:       NewFoodRepository(),
+		Insulin:    NewInsulinRepository(),
+		AIUsage:    NewAIUsageRepository(),
+		ImportJobs: NewImportJobRepository(),
+		Shares:     NewShareRepository(),
+		Alerts:     NewAlertRepository(),
+		Links:      NewPublicLinkRepository(),
+		Digests:    NewDigestRepository(),
+		Labs:       NewLabRepository(),
+		Vitals:     NewVitalRepository(),
+	}
+}
+
+// store хранит копии моделей по ID. Поля ID, CreatedAt, UpdatedAt и DeletedAt
+// ищутся по имени, поэтому подходят и модели с gorm.Model.
+type store[T any] struct {
+	mu     sync.Mutex
+	nextID uint
+	items  map[uint]T
+}
+
+func newStore[T any]() *store[T] {
+	return &store[T]{items: make(map[uint]T)}
+}
+
+func (s *store[T]) create(item *T) {
+	v := reflect.ValueOf(item).Elem()
+	now := time.Now().UTC()
+
+	s.nextID++
+	v.FieldByName("ID").SetUint(uint64(s.nextID))
+	for _, name := range []string{"CreatedAt", "UpdatedAt"} {
+		if field := v.FieldByName(name); field.IsZero() {
+			field.Set(reflect.ValueOf(now))
+		}
+	}
+	s.items[s.nextID] = *item
+}
+
+// find возвращает неудаленные записи, подходящие под условие
+func (s *store[T]) find(match func(*T) bool) []T {
+	var result []T
+	for _, item := range s.items {
+		if !deleted(&item) && match(&item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (s *store[T]) update(id uint, updates map[string]interface{}) error {
+	item := s.items[id]
+	if err := applyUpdates(&item, updates); err != nil {
+		return err
+	}
+	reflect.ValueOf(&item).Elem().FieldByName("UpdatedAt").Set(reflect.ValueOf(time.Now().UTC()))
+	s.items[id] = item
+	return nil
+}
+
+func (s *store[T]) softDelete(id uint) {
+	item := s.items[id]
+	setDeletedAt(&item, gorm.DeletedAt{Time: time.Now().UTC(), Valid: true})
+	s.items[id] = item
+}
+
+func itemID[T any](item *T) uint {
+	return uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+}
+
+func userID[T any](item *T) uint {
+	return uint(reflect.ValueOf(item).Elem().FieldByName("UserID").Uint())
+}
+
+func deletedAt[T any](item *T) gorm.DeletedAt {
+	return reflect.ValueOf(item).Elem().FieldByName("DeletedAt").Interface().(gorm.DeletedAt)
+}
+
+func deleted[T any](item *T) bool {
+	return deletedAt(item).Valid
+}
+
+func setDeletedAt[T any](item *T, value gorm.DeletedAt) {
+	reflect.ValueOf(item).Elem().FieldByName("DeletedAt").Set(reflect.ValueOf(value))
+}
+
+var naming = schema.NamingStrategy{}
+
+// applyUpdates присваивает значения полям по именам колонок, как это делает GORM Updates
+func applyUpdates[T any](item *T, updates map[string]interface{}) error {
+	v := reflect.ValueOf(item).Elem()
+	for column, value := range updates {
+		field, ok := fieldByColumn(v, column)
+		if !ok {
+			return fmt.Errorf("memory: unknown column %q for %T", column, *item)
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("memory: column %q: %w", column, err)
+		}
+	}
+	return nil
+}
+
+func fieldByColumn(v reflect.Value, column string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if field, ok := fieldByColumn(v.Field(i), column); ok {
+				return field, true
+			}
+			continue
+		}
+		if naming.ColumnName("", sf.Name) == column {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setField поддерживает nil, значения того же типа, указатели и числовые преобразования
+func setField(field reflect.Value, value interface{}) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	val := reflect.ValueOf(value)
+	if val.Kind() == reflect.Ptr && field.Kind() != reflect.Ptr {
+		if val.IsNil() {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		val = val.Elem()
+	}
+
+	switch {
+	case val.Type().AssignableTo(field.Type()):
+		field.Set(val)
+	case val.Type().ConvertibleTo(field.Type()):
+		field.Set(val.Convert(field.Type()))
+	case field.Kind() == reflect.Ptr && val.Type().ConvertibleTo(field.Type().Elem()):
+		ptr := reflect.New(field.Type().Elem())
+		ptr.Elem().Set(val.Convert(field.Type().Elem()))
+		field.Set(ptr)
+	default:
+		return fmt.Errorf("cannot assign %T to %s", value, field.Type())
+	}
+	return nil
+}
+
+type userRepository struct {
+	*store[models.User]
+}
+
+func NewUserRepository() repository.UserRepository {
+	return &userRepository{newStore[models.User]()}
+}
+
+func (r *userRepository) GetByID(id uint) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := r.find(func(u *models.User) bool { return u.ID == id })
+	if len(users) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &users[0], nil
+}
+
+func (r *userRepository) GetByTelegramID(telegramID int64) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := r.find(func(u *models.User) bool { return u.TelegramID == telegramID })
+	if len(users) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &users[0], nil
+}
+
+func (r *userRepository) GetByNightscoutSecretHash(hash string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := r.find(func(u *models.User) bool {
+		return u.NightscoutSecretHash != nil && *u.NightscoutSecretHash == hash
+	})
+	if len(users) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &users[0], nil
+}
+
+func (r *userRepository) Create(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.items {
+		if existing.TelegramID == user.TelegramID {
+			return fmt.Errorf("memory: duplicate telegram_id %d", user.TelegramID)
+		}
+	}
+	r.create(user)
+	return nil
+}
+
+func (r *userRepository) Update(id uint, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.find(func(u *models.User) bool { return u.ID == id }) {
+		if err := r.update(user.ID, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateByTelegramID(telegramID int64, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.find(func(u *models.User) bool { return u.TelegramID == telegramID }) {
+		if err := r.update(user.ID, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// records реализует общие операции над записями пользователя;
+// at возвращает время события, по которому записи фильтруются и сортируются
+type records[T any] struct {
+	*store[T]
+	at    func(*T) time.Time
+	setAt func(*T, time.Time)
+}
+
+func newRecords[T any](at func(*T) time.Time, setAt func(*T, time.Time)) records[T] {
+	return records[T]{store: newStore[T](), at: at, setAt: setAt}
+}
+
+func (r records[T]) Create(record *T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.create(record)
+	return nil
+}
+
+func (r records[T]) CreateBatch(items []T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range items {
+		r.create(&items[i])
+	}
+	return nil
+}
+
+func (r records[T]) GetByID(uid, id uint) (*T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := r.find(func(item *T) bool { return userID(item) == uid && itemID(item) == id })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r records[T]) ListSince(uid uint, since time.Time) ([]T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.listWhere(func(item *T) bool {
+		return userID(item) == uid && !r.at(item).Before(since)
+	}), nil
+}
+
+// listWhere возвращает подходящие записи, сначала новые
+func (r records[T]) listWhere(match func(*T) bool) []T {
+	found := r.find(match)
+	sort.Slice(found, func(i, j int) bool {
+		return r.before(&found[j], &found[i])
+	})
+	return found
+}
+
+// before сравнивает записи по (время, ID)
+func (r records[T]) before(a, b *T) bool {
+	if !r.at(a).Equal(r.at(b)) {
+		return r.at(a).Before(r.at(b))
+	}
+	return itemID(a) < itemID(b)
+}
+
+func (r records[T]) list(uid uint, query repository.ListQuery, filterColumn, filterValue string) []T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var cursor *T
+	if query.After != nil {
+		var item T
+		v := reflect.ValueOf(&item).Elem()
+		v.FieldByName("ID").SetUint(uint64(query.After.ID))
+		r.setAt(&item, query.After.At)
+		cursor = &item
+	}
+
+	found := r.listWhere(func(item *T) bool {
+		at := r.at(item)
+		switch {
+		case userID(item) != uid,
+			!query.From.IsZero() && at.Before(query.From),
+			!query.To.IsZero() && !at.Before(query.To):
+			return false
+		case filterValue != "":
+			field, _ := fieldByColumn(reflect.ValueOf(item).Elem(), filterColumn)
+			if field.String() != filterValue {
+				return false
+			}
+		}
+		if cursor == nil {
+			return true
+		}
+		if query.Ascending {
+			return r.before(cursor, item)
+		}
+		return r.before(item, cursor)
+	})
+
+	if query.Ascending {
+		for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+			found[i], found[j] = found[j], found[i]
+		}
+	}
+	if query.Limit > 0 && len(found) > query.Limit {
+		found = found[:query.Limit]
+	}
+	return found
+}
+
+func (r records[T]) Update(uid, id uint, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || deleted(&item) || userID(&item) != uid {
+		return nil
+	}
+	return r.update(id, updates)
+}
+
+func (r records[T]) Delete(uid, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if ok && !deleted(&item) && userID(&item) == uid {
+		r.softDelete(id)
+	}
+	return nil
+}
+
+func (r records[T]) Restore(uid, id uint, deletedAfter time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || userID(&item) != uid || !deleted(&item) || deletedAt(&item).Time.Before(deletedAfter) {
+		return repository.ErrNotFound
+	}
+	setDeletedAt(&item, gorm.DeletedAt{})
+	r.items[id] = item
+	return nil
+}
+
+func (r records[T]) DeleteAllByUser(uid uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, item := range r.find(func(item *T) bool { return userID(item) == uid }) {
+		r.softDelete(itemID(&item))
+	}
+	return nil
+}
+
+type glucoseRepository struct {
+	records[models.GlucoseRecord]
+}
+
+func NewGlucoseRepository() repository.GlucoseRepository {
+	return &glucoseRepository{newRecords(
+		func(r *models.GlucoseRecord) time.Time { return r.MeasuredAt },
+		func(r *models.GlucoseRecord, at time.Time) { r.MeasuredAt = at },
+	)}
+}
+
+func (r *glucoseRepository) List(uid uint, query repository.ListQuery, measurementContext string) ([]models.GlucoseRecord, error) {
+	return r.list(uid, query, "measurement_context", measurementContext), nil
+}
+
+func (r *glucoseRepository) ListBySource(uid uint, query repository.ListQuery, source string) ([]models.GlucoseRecord, error) {
+	return r.list(uid, query, "source", source), nil
+}
+
+func (r *glucoseRepository) Latest(uid uint) (*models.GlucoseRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := r.listWhere(func(item *models.GlucoseRecord) bool { return item.UserID == uid })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *glucoseRepository) Stats(uid uint, since time.Time) (*repository.GlucoseStats, error) {
+	records, err := r.ListSince(uid, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats repository.GlucoseStats
+	var sum float64
+	for i, record := range records {
+		if i == 0 || record.Value < stats.Min {
+			stats.Min = record.Value
+		}
+		if i == 0 || record.Value > stats.Max {
+			stats.Max = record.Value
+		}
+		sum += record.Value
+	}
+	stats.Count = int64(len(records))
+	if stats.Count > 0 {
+		stats.Average = sum / float64(stats.Count)
+	}
+	return &stats, nil
+}
+
+func (r *glucoseRepository) Buckets(uid uint, query repository.BucketQuery) ([]repository.GlucoseBucket, error) {
+	records := r.list(uid, repository.ListQuery{From: query.From, To: query.To, Ascending: true}, "source", query.Source)
+
+	var buckets []repository.GlucoseBucket
+	sums := map[int64]float64{}
+	index := map[int64]int{}
+	for _, record := range records {
+		n := record.MeasuredAt.Add(query.Offset).Unix() / int64(query.Size/time.Second)
+		i, ok := index[n]
+		if !ok {
+			i = len(buckets)
+			index[n] = i
+			start := time.Unix(n*int64(query.Size/time.Second), 0).Add(-query.Offset)
+			buckets = append(buckets, repository.GlucoseBucket{Start: start, Min: record.Value, Max: record.Value})
+		}
+		b := &buckets[i]
+		b.Count++
+		b.Min = math.Min(b.Min, record.Value)
+		b.Max = math.Max(b.Max, record.Value)
+		sums[n] += record.Value
+	}
+	for n, i := range index {
+		buckets[i].Average = sums[n] / float64(buckets[i].Count)
+	}
+	return buckets, nil
+}
+
+func (r *glucoseRepository) DeleteSourceBefore(source string, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for id, item := range r.items {
+		if item.Source == source && item.MeasuredAt.Before(before) {
+			delete(r.items, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type foodRepository struct {
+	records[models.FoodRecord]
+}
+
+func NewFoodRepository() repository.FoodRepository {
+	return &foodRepository{newRecords(
+		func(r *models.FoodRecord) time.Time { return r.ConsumedAt },
+		func(r *models.FoodRecord, at time.Time) { r.ConsumedAt = at },
+	)}
+}
+
+func (r *foodRepository) List(uid uint, query repository.ListQuery, foodType string) ([]models.FoodRecord, error) {
+	return r.list(uid, query, "food_type", foodType), nil
+}
+
+func (r *foodRepository) ListByTypeSince(uid uint, foodType string, since time.Time) ([]models.FoodRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.listWhere(func(item *models.FoodRecord) bool {
+		return item.UserID == uid && item.FoodType == foodType && !item.ConsumedAt.Before(since)
+	}), nil
+}
+
+func (r *foodRepository) Totals(uid uint, from, to time.Time) (*repository.FoodTotals, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var totals repository.FoodTotals
+	for _, record := range r.find(func(item *models.FoodRecord) bool {
+		return item.UserID == uid && !item.ConsumedAt.Before(from) && item.ConsumedAt.Before(to)
+	}) {
+		totals.Count++
+		if record.Calories != nil {
+			totals.Calories += *record.Calories
+		}
+		if record.Carbs != nil {
+			totals.Carbs += *record.Carbs
+		}
+	}
+	return &totals, nil
+}
+
+type insulinRepository struct {
+	records[models.InsulinRecord]
+}
+
+func NewInsulinRepository() repository.InsulinRepository {
+	return &insulinRepository{newRecords(
+		func(r *models.InsulinRecord) time.Time { return r.InjectedAt },
+		func(r *models.InsulinRecord, at time.Time) { r.InjectedAt = at },
+	)}
+}
+
+func (r *insulinRepository) List(uid uint, query repository.ListQuery) ([]models.InsulinRecord, error) {
+	return r.list(uid, query, "", ""), nil
+}
+
+type labRepository struct {
+	records[models.LabResult]
+}
+
+func NewLabRepository() repository.LabRepository {
+	return &labRepository{newRecords(
+		func(r *models.LabResult) time.Time { return r.TakenAt },
+		func(r *models.LabResult, at time.Time) { r.TakenAt = at },
+	)}
+}
+
+func (r *labRepository) List(uid uint, query repository.ListQuery, kind string) ([]models.LabResult, error) {
+	return r.list(uid, query, "kind", kind), nil
+}
+
+type vitalRepository struct {
+	records[models.Vital]
+}
+
+func NewVitalRepository() repository.VitalRepository {
+	return &vitalRepository{newRecords(
+		func(r *models.Vital) time.Time { return r.MeasuredAt },
+		func(r *models.Vital, at time.Time) { r.MeasuredAt = at },
+	)}
+}
+
+func (r *vitalRepository) List(uid uint, query repository.ListQuery, kind string) ([]models.Vital, error) {
+	return r.list(uid, query, "kind", kind), nil
+}
+
+type aiUsageRepository struct {
+	*store[models.AIUsage]
+}
+
+func NewAIUsageRepository() repository.AIUsageRepository {
+	return &aiUsageRepository{newStore[models.AIUsage]()}
+}
+
+func (r *aiUsageRepository) Get(uid uint, date string) (*models.AIUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := r.find(func(u *models.AIUsage) bool { return u.UserID == uid && u.Date == date })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *aiUsageRepository) Create(usage *models.AIUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.find(func(u *models.AIUsage) bool { return u.UserID == usage.UserID && u.Date == usage.Date })) > 0 {
+		return fmt.Errorf("memory: duplicate AI usage for user %d on %s", usage.UserID, usage.Date)
+	}
+	r.create(usage)
+	return nil
+}
+
+func (r *aiUsageRepository) Save(usage *models.AIUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if usage.ID == 0 {
+		r.create(usage)
+		return nil
+	}
+	usage.UpdatedAt = time.Now().UTC()
+	r.items[usage.ID] = *usage
+	return nil
+}
+
+func (r *aiUsageRepository) DeleteBefore(date string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.find(func(u *models.AIUsage) bool { return u.Date < date })
+	for _, usage := range old {
+		r.softDelete(usage.ID)
+	}
+	return int64(len(old)), nil
+}
+
+type importJobRepository struct {
+	*store[models.ImportJob]
+}
+
+func NewImportJobRepository() repository.ImportJobRepository {
+	return &importJobRepository{newStore[models.ImportJob]()}
+}
+
+func (r *importJobRepository) Create(job *models.ImportJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.create(job)
+	return nil
+}
+
+func (r *importJobRepository) GetByID(uid, id uint) (*models.ImportJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := r.find(func(j *models.ImportJob) bool { return j.UserID == uid && j.ID == id })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *importJobRepository) Update(uid, id uint, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || deleted(&item) || item.UserID != uid {
+		return nil
+	}
+	return r.update(id, updates)
+}
+
+func (r *importJobRepository) Claim(uid, id uint, status string, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || deleted(&item) || item.UserID != uid || item.Status != status {
+		return repository.ErrNotFound
+	}
+	return r.update(id, updates)
+}
+
+type shareRepository struct {
+	*store[models.Share]
+}
+
+func NewShareRepository() repository.ShareRepository {
+	return &shareRepository{newStore[models.Share]()}
+}
+
+func (r *shareRepository) Create(share *models.Share) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.create(share)
+	return nil
+}
+
+func (r *shareRepository) first(match func(*models.Share) bool) (*models.Share, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := r.find(match)
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *shareRepository) GetByID(id uint) (*models.Share, error) {
+	return r.first(func(s *models.Share) bool { return s.ID == id })
+}
+
+func (r *shareRepository) GetByTokenHash(hash string) (*models.Share, error) {
+	return r.first(func(s *models.Share) bool { return s.TokenHash == hash })
+}
+
+func (r *shareRepository) Get(ownerID, granteeID uint) (*models.Share, error) {
+	return r.first(func(s *models.Share) bool {
+		return s.OwnerID == ownerID && s.GranteeID != nil && *s.GranteeID == granteeID
+	})
+}
+
+func (r *shareRepository) list(match func(*models.Share) bool) []models.Share {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	shares := r.find(match)
+	sort.Slice(shares, func(i, j int) bool {
+		if !shares[i].CreatedAt.Equal(shares[j].CreatedAt) {
+			return shares[i].CreatedAt.After(shares[j].CreatedAt)
+		}
+		return shares[i].ID > shares[j].ID
+	})
+	return shares
+}
+
+func (r *shareRepository) ListByOwner(ownerID uint) ([]models.Share, error) {
+	return r.list(func(s *models.Share) bool { return s.OwnerID == ownerID }), nil
+}
+
+func (r *shareRepository) ListByGrantee(granteeID uint) ([]models.Share, error) {
+	return r.list(func(s *models.Share) bool { return s.GranteeID != nil && *s.GranteeID == granteeID }), nil
+}
+
+func (r *shareRepository) Accept(id, granteeID uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || deleted(&item) || item.GranteeID != nil {
+		return repository.ErrNotFound
+	}
+	return r.update(id, map[string]interface{}{"grantee_id": granteeID, "accepted_at": at})
+}
+
+func (r *shareRepository) Update(id uint, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || deleted(&item) {
+		return nil
+	}
+	return r.update(id, updates)
+}
+
+func (r *shareRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if item, ok := r.items[id]; ok && !deleted(&item) {
+		r.softDelete(id)
+	}
+	return nil
+}
+
+type alertRepository struct {
+	*store[models.Alert]
+	rules *store[models.AlertRule]
+}
+
+func NewAlertRepository() repository.AlertRepository {
+	return &alertRepository{store: newStore[models.Alert](), rules: newStore[models.AlertRule]()}
+}
+
+func (r *alertRepository) GetRule(uid uint) (*models.AlertRule, error) {
+	r.rules.mu.Lock()
+	defer r.rules.mu.Unlock()
+
+	found := r.rules.find(func(rule *models.AlertRule) bool { return rule.UserID == uid })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *alertRepository) SaveRule(rule *models.AlertRule) error {
+	r.rules.mu.Lock()
+	defer r.rules.mu.Unlock()
+
+	if rule.ID == 0 {
+		if len(r.rules.find(func(existing *models.AlertRule) bool { return existing.UserID == rule.UserID })) > 0 {
+			return fmt.Errorf("memory: duplicate alert rule for user %d", rule.UserID)
+		}
+		r.rules.create(rule)
+		return nil
+	}
+	rule.UpdatedAt = time.Now().UTC()
+	r.rules.items[rule.ID] = *rule
+	return nil
+}
+
+func (r *alertRepository) Create(alert *models.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if activeAlert(alert) && len(r.find(func(a *models.Alert) bool { return a.UserID == alert.UserID && activeAlert(a) })) > 0 {
+		return repository.ErrDuplicate
+	}
+	r.create(alert)
+	return nil
+}
+
+// newest возвращает подходящие тревоги, сначала новые
+func (r *alertRepository) newest(match func(*models.Alert) bool) []models.Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	alerts := r.find(match)
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].CreatedAt.Equal(alerts[j].CreatedAt) {
+			return alerts[i].CreatedAt.After(alerts[j].CreatedAt)
+		}
+		return alerts[i].ID > alerts[j].ID
+	})
+	return alerts
+}
+
+func (r *alertRepository) GetByID(id uint) (*models.Alert, error) {
+	found := r.newest(func(a *models.Alert) bool { return a.ID == id })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+// activeAlert сообщает, что тревога еще не закрыта
+func activeAlert(a *models.Alert) bool {
+	switch a.Status {
+	case models.AlertStatusOpen, models.AlertStatusAcknowledged, models.AlertStatusEscalated, models.AlertStatusMuted:
+		return true
+	}
+	return false
+}
+
+func (r *alertRepository) Active(uid uint) (*models.Alert, error) {
+	found := r.newest(func(a *models.Alert) bool { return a.UserID == uid && activeAlert(a) })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *alertRepository) ListDue(at time.Time) ([]models.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	alerts := r.find(func(a *models.Alert) bool {
+		due := a.Status == models.AlertStatusOpen || a.Status == models.AlertStatusMuted
+		return due && !a.RecheckBy.After(at)
+	})
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].RecheckBy.Equal(alerts[j].RecheckBy) {
+			return alerts[i].RecheckBy.Before(alerts[j].RecheckBy)
+		}
+		return alerts[i].ID < alerts[j].ID
+	})
+	return alerts, nil
+}
+
+func (r *alertRepository) List(uid uint, limit int) ([]models.Alert, error) {
+	alerts := r.newest(func(a *models.Alert) bool { return a.UserID == uid })
+	if limit > 0 && len(alerts) > limit {
+		alerts = alerts[:limit]
+	}
+	return alerts, nil
+}
+
+func (r *alertRepository) Update(id uint, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || deleted(&item) {
+		return nil
+	}
+	return r.update(id, updates)
+}
+
+func (r *alertRepository) Claim(id uint, status string, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || deleted(&item) || item.Status != status {
+		return repository.ErrNotFound
+	}
+	return r.update(id, updates)
+}
+
+// publicLinkRepository хранит журнал открытий срезом: у записей журнала
+// нет полей мягкого удаления, которые нужны store
+type publicLinkRepository struct {
+	*store[models.PublicLink]
+	accesses []models.PublicLinkAccess
+}
+
+func NewPublicLinkRepository() repository.PublicLinkRepository {
+	return &publicLinkRepository{store: newStore[models.PublicLink]()}
+}
+
+func (r *publicLinkRepository) Create(link *models.PublicLink) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.create(link)
+	return nil
+}
+
+func (r *publicLinkRepository) first(match func(*models.PublicLink) bool) (*models.PublicLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := r.find(match)
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *publicLinkRepository) GetByID(uid, id uint) (*models.PublicLink, error) {
+	return r.first(func(l *models.PublicLink) bool { return l.UserID == uid && l.ID == id })
+}
+
+func (r *publicLinkRepository) GetByTokenHash(hash string) (*models.PublicLink, error) {
+	return r.first(func(l *models.PublicLink) bool { return l.TokenHash == hash })
+}
+
+func (r *publicLinkRepository) ListByUser(uid uint) ([]models.PublicLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	links := r.find(func(l *models.PublicLink) bool { return l.UserID == uid })
+	sort.Slice(links, func(i, j int) bool {
+		if !links[i].CreatedAt.Equal(links[j].CreatedAt) {
+			return links[i].CreatedAt.After(links[j].CreatedAt)
+		}
+		return links[i].ID > links[j].ID
+	})
+	return links, nil
+}
+
+func (r *publicLinkRepository) Delete(uid, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if item, ok := r.items[id]; ok && !deleted(&item) && item.UserID == uid {
+		r.softDelete(id)
+	}
+	return nil
+}
+
+func (r *publicLinkRepository) LogAccess(access *models.PublicLinkAccess) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	access.ID = uint(len(r.accesses) + 1)
+	r.accesses = append(r.accesses, *access)
+	if item, ok := r.items[access.LinkID]; ok {
+		return r.update(item.ID, map[string]interface{}{
+			"access_count":     item.AccessCount + 1,
+			"last_accessed_at": access.AccessedAt,
+		})
+	}
+	return nil
+}
+
+func (r *publicLinkRepository) ListAccesses(linkID uint, limit int) ([]models.PublicLinkAccess, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var accesses []models.PublicLinkAccess
+	for i := len(r.accesses) - 1; i >= 0; i-- {
+		if r.accesses[i].LinkID == linkID {
+			accesses = append(accesses, r.accesses[i])
+		}
+	}
+	sort.SliceStable(accesses, func(i, j int) bool { return accesses[i].AccessedAt.After(accesses[j].AccessedAt) })
+	if limit > 0 && len(accesses) > limit {
+		accesses = accesses[:limit]
+	}
+	return accesses, nil
+}
+
+type digestRepository struct {
+	*store[models.Digest]
+	subs *store[models.DigestSubscription]
+}
+
+func NewDigestRepository() repository.DigestRepository {
+	return &digestRepository{store: newStore[models.Digest](), subs: newStore[models.DigestSubscription]()}
+}
+
+func (r *digestRepository) GetSubscription(uid uint) (*models.DigestSubscription, error) {
+	r.subs.mu.Lock()
+	defer r.subs.mu.Unlock()
+
+	found := r.subs.find(func(sub *models.DigestSubscription) bool { return sub.UserID == uid })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *digestRepository) SaveSubscription(sub *models.DigestSubscription) error {
+	r.subs.mu.Lock()
+	defer r.subs.mu.Unlock()
+
+	if sub.ID == 0 {
+		if len(r.subs.find(func(existing *models.DigestSubscription) bool { return existing.UserID == sub.UserID })) > 0 {
+			return fmt.Errorf("memory: duplicate digest subscription for user %d", sub.UserID)
+		}
+		r.subs.create(sub)
+		return nil
+	}
+	sub.UpdatedAt = time.Now().UTC()
+	r.subs.items[sub.ID] = *sub
+	return nil
+}
+
+func (r *digestRepository) ListSubscribed() ([]models.DigestSubscription, error) {
+	r.subs.mu.Lock()
+	defer r.subs.mu.Unlock()
+
+	subs := r.subs.find(func(sub *models.DigestSubscription) bool { return sub.Enabled })
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs, nil
+}
+
+func (r *digestRepository) GetByPeriod(uid uint, from time.Time) (*models.Digest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := r.find(func(d *models.Digest) bool { return d.UserID == uid && d.PeriodFrom.Equal(from) })
+	if len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *digestRepository) Save(digest *models.Digest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if digest.ID == 0 {
+		digest.SentAt = nil
+		r.create(digest)
+		return nil
+	}
+	digest.SentAt = r.items[digest.ID].SentAt
+	digest.UpdatedAt = time.Now().UTC()
+	r.items[digest.ID] = *digest
+	return nil
+}
+
+func (r *digestRepository) MarkSent(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || deleted(&item) || item.SentAt != nil {
+		return repository.ErrNotFound
+	}
+	return r.update(id, map[string]interface{}{"sent_at": at})
+}
+
+func (r *digestRepository) List(uid uint, limit int) ([]models.Digest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	digests := r.find(func(d *models.Digest) bool { return d.UserID == uid })
+	sort.Slice(digests, func(i, j int) bool {
+		if !digests[i].PeriodFrom.Equal(digests[j].PeriodFrom) {
+			return digests[i].PeriodFrom.After(digests[j].PeriodFrom)
+		}
+		return digests[i].ID > digests[j].ID
+	})
+	if limit > 0 && len(digests) > limit {
+		digests = digests[:limit]
+	}
+	return digests, nil
+}
+
+func (r *digestRepository) DeleteAllByUser(uid uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, digest := range r.find(func(d *models.Digest) bool { return d.UserID == uid }) {
+		r.softDelete(digest.ID)
+	}
+	return nil
+}
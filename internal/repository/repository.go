@@ -0,0 +1,237 @@
+// Package repository описывает хранилища агрегатов, с которыми работают сервисы.
+// Основная реализация — на GORM, для тестов есть реализация в памяти (пакет memory).
+package repository
+
+import (
+	"time"
+
+	"diabetbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound возвращается, если запись не найдена.
+// Совпадает с gorm.ErrRecordNotFound, чтобы существующие проверки errors.Is продолжали работать.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrDuplicate возвращается, если запись нарушает уникальный индекс
+var ErrDuplicate = gorm.ErrDuplicatedKey
+
+// GlucoseStats — агрегированная статистика измерений глюкозы
+type GlucoseStats struct {
+	Average float64 `json:"average"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Count   int64   `json:"count"`
+}
+
+// FoodTotals — суммы калорий и углеводов за период
+type FoodTotals struct {
+	Count    int // число записей
+	Calories int
+	Carbs    float64
+}
+
+// BucketQuery задает агрегирование измерений за [From, To) по интервалам Size.
+// Интервалы отсчитываются от полуночи UTC со сдвигом Offset — смещением часового пояса,
+// чтобы суточные интервалы совпадали с сутками пользователя. Пустой Source не фильтрует.
+type BucketQuery struct {
+	From   time.Time
+	To     time.Time
+	Size   time.Duration
+	Offset time.Duration
+	Source string
+}
+
+// GlucoseBucket — измерения одного интервала, значения в ммоль/л
+type GlucoseBucket struct {
+	Start   time.Time `json:"start"`
+	Count   int64     `json:"count"`
+	Average float64   `json:"average"`
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+}
+
+// Cursor — позиция в списке: время события и ID последней выданной записи
+type Cursor struct {
+	At time.Time
+	ID uint
+}
+
+// ListQuery задает выборку записей пользователя. Нулевые From и To не ограничивают
+// период, To не включается. Записи упорядочены по (время, ID), After продолжает
+// список после курсора, Limit = 0 снимает ограничение.
+type ListQuery struct {
+	From      time.Time
+	To        time.Time
+	After     *Cursor
+	Ascending bool
+	Limit     int
+}
+
+type UserRepository interface {
+	GetByID(id uint) (*models.User, error)
+	GetByTelegramID(telegramID int64) (*models.User, error)
+	GetByNightscoutSecretHash(hash string) (*models.User, error)
+	Create(user *models.User) error
+	Update(id uint, updates map[string]interface{}) error
+	UpdateByTelegramID(telegramID int64, updates map[string]interface{}) error
+}
+
+// Общие операции над записями пользователя. CreateBatch сохраняет записи одной транзакцией,
+// Update принимает имена колонок, Delete — мягкое удаление, Restore восстанавливает запись,
+// удаленную не раньше deletedAfter.
+type recordRepository[T any] interface {
+	Create(record *T) error
+	CreateBatch(records []T) error
+	GetByID(userID, id uint) (*T, error)
+	ListSince(userID uint, since time.Time) ([]T, error) // сначала новые
+	Update(userID, id uint, updates map[string]interface{}) error
+	Delete(userID, id uint) error
+	Restore(userID, id uint, deletedAfter time.Time) error
+	DeleteAllByUser(userID uint) error
+}
+
+type GlucoseRepository interface {
+	recordRepository[models.GlucoseRecord]
+	// List возвращает страницу записей; пустой measurementContext не фильтрует
+	List(userID uint, query ListQuery, measurementContext string) ([]models.GlucoseRecord, error)
+	ListBySource(userID uint, query ListQuery, source string) ([]models.GlucoseRecord, error)
+	Latest(userID uint) (*models.GlucoseRecord, error)
+	Stats(userID uint, since time.Time) (*GlucoseStats, error)
+	// Buckets возвращает непустые интервалы по возрастанию времени
+	Buckets(userID uint, query BucketQuery) ([]GlucoseBucket, error)
+	// DeleteSourceBefore окончательно удаляет записи источника source, измеренные раньше before
+	DeleteSourceBefore(source string, before time.Time) (int64, error)
+}
+
+type FoodRepository interface {
+	recordRepository[models.FoodRecord]
+	// List возвращает страницу записей; пустой foodType не фильтрует
+	List(userID uint, query ListQuery, foodType string) ([]models.FoodRecord, error)
+	ListByTypeSince(userID uint, foodType string, since time.Time) ([]models.FoodRecord, error)
+	Totals(userID uint, from, to time.Time) (*FoodTotals, error)
+}
+
+type InsulinRepository interface {
+	recordRepository[models.InsulinRecord]
+	List(userID uint, query ListQuery) ([]models.InsulinRecord, error)
+}
+
+type LabRepository interface {
+	recordRepository[models.LabResult]
+	// List возвращает страницу результатов; пустой kind не фильтрует
+	List(userID uint, query ListQuery, kind string) ([]models.LabResult, error)
+}
+
+type VitalRepository interface {
+	recordRepository[models.Vital]
+	// List возвращает страницу измерений; пустой kind не фильтрует
+	List(userID uint, query ListQuery, kind string) ([]models.Vital, error)
+}
+
+// AIUsageRepository хранит дневные счетчики AI запросов; date — день в формате YYYY-MM-DD
+type AIUsageRepository interface {
+	Get(userID uint, date string) (*models.AIUsage, error)
+	Create(usage *models.AIUsage) error
+	Save(usage *models.AIUsage) error
+	DeleteBefore(date string) (int64, error)
+}
+
+// ImportJobRepository хранит загрузки файлов с измерениями
+type ImportJobRepository interface {
+	Create(job *models.ImportJob) error
+	GetByID(userID, id uint) (*models.ImportJob, error)
+	Update(userID, id uint, updates map[string]interface{}) error
+	// Claim обновляет импорт, только если он все еще в статусе status;
+	// ErrNotFound, если его уже подтвердил или отменил другой запрос
+	Claim(userID, id uint, status string, updates map[string]interface{}) error
+}
+
+// ShareRepository хранит доступы к дневникам и приглашения. Отозванные доступы
+// (мягко удаленные) не возвращаются; списки упорядочены от новых к старым.
+type ShareRepository interface {
+	Create(share *models.Share) error
+	GetByID(id uint) (*models.Share, error)
+	GetByTokenHash(hash string) (*models.Share, error)
+	// Get возвращает принятый доступ granteeID к дневнику ownerID
+	Get(ownerID, granteeID uint) (*models.Share, error)
+	// ListByOwner возвращает доступы и непринятые приглашения владельца
+	ListByOwner(ownerID uint) ([]models.Share, error)
+	// ListByGrantee возвращает принятые доступы получателя
+	ListByGrantee(granteeID uint) ([]models.Share, error)
+	// Accept отдает приглашение получателю; ErrNotFound, если его уже приняли или отозвали
+	Accept(id, granteeID uint, at time.Time) error
+	Update(id uint, updates map[string]interface{}) error
+	Delete(id uint) error
+}
+
+// AlertRepository хранит правила тревог и тревоги по критическим значениям.
+// Списки упорядочены от новых к старым.
+type AlertRepository interface {
+	// GetRule возвращает правило пользователя; ErrNotFound, если оно не сохранялось
+	GetRule(userID uint) (*models.AlertRule, error)
+	// SaveRule создает правило или перезаписывает все его поля
+	SaveRule(rule *models.AlertRule) error
+	// Create сохраняет новую тревогу; ErrDuplicate, если у пользователя уже есть незакрытая
+	Create(alert *models.Alert) error
+	GetByID(id uint) (*models.Alert, error)
+	// Active возвращает последнюю незакрытую тревогу пользователя: open, acknowledged, escalated или muted
+	Active(userID uint) (*models.Alert, error)
+	// ListDue возвращает открытые и отложенные в тихие часы (muted) тревоги,
+	// срок повторного измерения которых наступил к at
+	ListDue(at time.Time) ([]models.Alert, error)
+	List(userID uint, limit int) ([]models.Alert, error)
+	Update(id uint, updates map[string]interface{}) error
+	// Claim обновляет тревогу, только если она все еще в статусе status;
+	// ErrNotFound, если ее уже забрала другая реплика или закрыло новое измерение
+	Claim(id uint, status string, updates map[string]interface{}) error
+}
+
+// PublicLinkRepository хранит публичные ссылки на дневник и журнал их открытий.
+// Отозванные ссылки (мягко удаленные) не возвращаются; списки упорядочены от новых к старым.
+type PublicLinkRepository interface {
+	Create(link *models.PublicLink) error
+	GetByID(userID, id uint) (*models.PublicLink, error)
+	GetByTokenHash(hash string) (*models.PublicLink, error)
+	ListByUser(userID uint) ([]models.PublicLink, error)
+	Delete(userID, id uint) error
+	// LogAccess сохраняет открытие ссылки и обновляет ее счетчик и время последнего открытия
+	LogAccess(access *models.PublicLinkAccess) error
+	ListAccesses(linkID uint, limit int) ([]models.PublicLinkAccess, error)
+}
+
+// DigestRepository хранит подписки на еженедельные сводки и сами сводки.
+// Списки сводок упорядочены от новых к старым.
+type DigestRepository interface {
+	// GetSubscription возвращает подписку пользователя; ErrNotFound, если она не сохранялась
+	GetSubscription(userID uint) (*models.DigestSubscription, error)
+	// SaveSubscription создает подписку или перезаписывает все ее поля
+	SaveSubscription(sub *models.DigestSubscription) error
+	// ListSubscribed возвращает включенные подписки
+	ListSubscribed() ([]models.DigestSubscription, error)
+	// GetByPeriod возвращает сводку пользователя за неделю, начинающуюся в from
+	GetByPeriod(userID uint, from time.Time) (*models.Digest, error)
+	// Save создает сводку или перезаписывает все ее поля, кроме sent_at: его ставит только MarkSent
+	Save(digest *models.Digest) error
+	// MarkSent отмечает сводку отправленной в at; ErrNotFound, если ее уже отметили
+	MarkSent(id uint, at time.Time) error
+	List(userID uint, limit int) ([]models.Digest, error)
+	DeleteAllByUser(userID uint) error
+}
+
+// Repositories объединяет хранилища всех агрегатов
+type Repositories struct {
+	Users      UserRepository
+	Glucose    GlucoseRepository
+	Food       FoodRepository
+	Insulin    InsulinRepository
+	AIUsage    AIUsageRepository
+	ImportJobs ImportJobRepository
+	Shares     ShareRepository
+	Alerts     AlertRepository
+	Links      PublicLinkRepository
+	Digests    DigestRepository
+	Labs       LabRepository
+	Vitals     VitalRepository
+}
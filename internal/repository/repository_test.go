@@ -0,0 +1,740 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/repository/memory"
+	"diabetbot/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Один и тот же набор проверок для GORM и реализации в памяти,
+// чтобы фейки в тестах сервисов вели себя как настоящая база
+func forEachImplementation(t *testing.T, test func(t *testing.T, repos *repository.Repositories)) {
+	t.Run("gorm", func(t *testing.T) {
+		db := testutils.SetupTestDB(t)
+		defer testutils.CleanupTestDB(db)
+		test(t, repository.NewGorm(db))
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, memory.NewRepositories())
+	})
+}
+
+func createUser(t *testing.T, repos *repository.Repositories, telegramID int64) *models.User {
+	user := &models.User{TelegramID: telegramID, FirstName: "Test", IsActive: true}
+	require.NoError(t, repos.Users.Create(user))
+	require.NotZero(t, user.ID)
+	return user
+}
+
+func TestUserRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		_, err := repos.Users.GetByTelegramID(100)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		user := createUser(t, repos, 100)
+
+		target := 6.5
+		require.NoError(t, repos.Users.Update(user.ID, map[string]interface{}{
+			"first_name":     "Updated",
+			"diabetes_type":  1,
+			"target_glucose": &target,
+		}))
+		require.NoError(t, repos.Users.UpdateByTelegramID(100, map[string]interface{}{"is_active": false}))
+
+		found, err := repos.Users.GetByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), found.TelegramID)
+		assert.Equal(t, "Updated", found.FirstName)
+		assert.False(t, found.IsActive)
+		require.NotNil(t, found.DiabetesType)
+		assert.Equal(t, 1, *found.DiabetesType)
+		require.NotNil(t, found.TargetGlucose)
+		assert.Equal(t, 6.5, *found.TargetGlucose)
+
+		assert.Error(t, repos.Users.Create(&models.User{TelegramID: 100}))
+	})
+}
+
+func TestUserRepository_NightscoutSecret(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 110)
+		createUser(t, repos, 111)
+		hash := "b0a6a4b2cbbb8e4d7a9de1a1d4b0c7d3e5f6a7b8"
+
+		_, err := repos.Users.GetByNightscoutSecretHash(hash)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		require.NoError(t, repos.Users.Update(user.ID, map[string]interface{}{"nightscout_secret_hash": &hash}))
+		found, err := repos.Users.GetByNightscoutSecretHash(hash)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, found.ID)
+
+		require.NoError(t, repos.Users.Update(user.ID, map[string]interface{}{"nightscout_secret_hash": nil}))
+		_, err = repos.Users.GetByNightscoutSecretHash(hash)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+}
+
+func TestGlucoseRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 200)
+		other := createUser(t, repos, 201)
+		now := time.Now()
+
+		for i, value := range []float64{5.0, 7.0, 9.0} {
+			record := &models.GlucoseRecord{UserID: user.ID, Value: value, MeasuredAt: now.Add(-time.Duration(i) * time.Hour)}
+			require.NoError(t, repos.Glucose.Create(record))
+		}
+		old := &models.GlucoseRecord{UserID: user.ID, Value: 20.0, MeasuredAt: now.AddDate(0, 0, -10)}
+		require.NoError(t, repos.Glucose.Create(old))
+		require.NoError(t, repos.Glucose.Create(&models.GlucoseRecord{UserID: other.ID, Value: 3.0, MeasuredAt: now}))
+
+		records, err := repos.Glucose.ListSince(user.ID, now.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, []float64{5.0, 7.0, 9.0}, []float64{records[0].Value, records[1].Value, records[2].Value})
+
+		latest, err := repos.Glucose.Latest(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, latest.Value)
+
+		stats, err := repos.Glucose.Stats(user.ID, now.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stats.Count)
+		assert.InDelta(t, 7.0, stats.Average, 0.001)
+		assert.Equal(t, 5.0, stats.Min)
+		assert.Equal(t, 9.0, stats.Max)
+
+		require.NoError(t, repos.Glucose.Update(user.ID, latest.ID, map[string]interface{}{
+			"value":               6.0,
+			"measurement_context": models.GlucoseContextFasting,
+		}))
+		updated, err := repos.Glucose.GetByID(user.ID, latest.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 6.0, updated.Value)
+		assert.Equal(t, models.GlucoseContextFasting, updated.MeasurementContext)
+
+		// Чужую запись нельзя получить, изменить или удалить
+		_, err = repos.Glucose.GetByID(other.ID, latest.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		require.NoError(t, repos.Glucose.Update(other.ID, latest.ID, map[string]interface{}{"value": 1.0}))
+		require.NoError(t, repos.Glucose.Delete(other.ID, latest.ID))
+		updated, err = repos.Glucose.GetByID(user.ID, latest.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 6.0, updated.Value)
+
+		deletedAfter := time.Now().Add(-time.Minute)
+		require.NoError(t, repos.Glucose.Delete(user.ID, latest.ID))
+		_, err = repos.Glucose.GetByID(user.ID, latest.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		err = repos.Glucose.Restore(user.ID, latest.ID, time.Now().Add(time.Minute))
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		require.NoError(t, repos.Glucose.Restore(user.ID, latest.ID, deletedAfter))
+		_, err = repos.Glucose.GetByID(user.ID, latest.ID)
+		assert.NoError(t, err)
+
+		require.NoError(t, repos.Glucose.DeleteAllByUser(user.ID))
+		records, err = repos.Glucose.ListSince(user.ID, time.Time{})
+		require.NoError(t, err)
+		assert.Empty(t, records)
+		_, err = repos.Glucose.Latest(user.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		records, err = repos.Glucose.ListSince(other.ID, time.Time{})
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+	})
+}
+
+func TestFoodRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 300)
+		day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+		carbs := func(v float64) *float64 { return &v }
+		calories := func(v int) *int { return &v }
+
+		require.NoError(t, repos.Food.Create(&models.FoodRecord{UserID: user.ID, FoodName: "Каша", FoodType: "завтрак", Carbs: carbs(30), Calories: calories(200), ConsumedAt: day.Add(8 * time.Hour)}))
+		require.NoError(t, repos.Food.Create(&models.FoodRecord{UserID: user.ID, FoodName: "Суп", FoodType: "обед", Carbs: carbs(15.5), ConsumedAt: day.Add(13 * time.Hour)}))
+		require.NoError(t, repos.Food.Create(&models.FoodRecord{UserID: user.ID, FoodName: "Омлет", FoodType: "завтрак", Calories: calories(300), ConsumedAt: day.Add(32 * time.Hour)}))
+
+		breakfasts, err := repos.Food.ListByTypeSince(user.ID, "завтрак", day)
+		require.NoError(t, err)
+		require.Len(t, breakfasts, 2)
+		assert.Equal(t, "Омлет", breakfasts[0].FoodName)
+		assert.Equal(t, "Каша", breakfasts[1].FoodName)
+
+		totals, err := repos.Food.Totals(user.ID, day, day.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		assert.Equal(t, 2, totals.Count)
+		assert.Equal(t, 200, totals.Calories)
+		assert.InDelta(t, 45.5, totals.Carbs, 0.001)
+
+		totals, err = repos.Food.Totals(user.ID, day.AddDate(0, 0, 5), day.AddDate(0, 0, 6))
+		require.NoError(t, err)
+		assert.Equal(t, 0, totals.Count)
+		assert.Equal(t, 0, totals.Calories)
+		assert.Equal(t, 0.0, totals.Carbs)
+
+		porridge := breakfasts[1]
+		require.NoError(t, repos.Food.Update(user.ID, porridge.ID, map[string]interface{}{
+			"food_name": "Овсянка",
+			"carbs":     carbs(40),
+			"calories":  calories(250),
+		}))
+		updated, err := repos.Food.GetByID(user.ID, porridge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Овсянка", updated.FoodName)
+		assert.Equal(t, 40.0, *updated.Carbs)
+		assert.Equal(t, 250, *updated.Calories)
+	})
+}
+
+func TestInsulinRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 400)
+		now := time.Now()
+
+		record := &models.InsulinRecord{UserID: user.ID, Units: 4, InsulinType: models.InsulinTypeBolus, InjectedAt: now}
+		require.NoError(t, repos.Insulin.Create(record))
+		require.NoError(t, repos.Insulin.Create(&models.InsulinRecord{UserID: user.ID, Units: 12, InsulinType: models.InsulinTypeBasal, InjectedAt: now.Add(-2 * time.Hour)}))
+
+		records, err := repos.Insulin.ListSince(user.ID, now.Add(-time.Hour))
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, record.ID, records[0].ID)
+
+		require.NoError(t, repos.Insulin.Update(user.ID, record.ID, map[string]interface{}{"units": 5.5}))
+		updated, err := repos.Insulin.GetByID(user.ID, record.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5.5, updated.Units)
+	})
+}
+
+func TestLabRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 410)
+		other := createUser(t, repos, 411)
+		taken := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+
+		hba1c := &models.LabResult{UserID: user.ID, Kind: models.LabKindHbA1c, Value: 6.8, Unit: models.LabUnitPercent, TakenAt: taken}
+		require.NoError(t, repos.Labs.Create(hba1c))
+		require.NoError(t, repos.Labs.Create(&models.LabResult{UserID: user.ID, Kind: models.LabKindHbA1c, Value: 7.4, Unit: models.LabUnitPercent, TakenAt: taken.AddDate(0, -3, 0)}))
+		require.NoError(t, repos.Labs.Create(&models.LabResult{UserID: user.ID, Kind: models.LabKindCholesterol, Value: 5.2, Unit: models.LabUnitMmolL, TakenAt: taken}))
+		require.NoError(t, repos.Labs.Create(&models.LabResult{UserID: other.ID, Kind: models.LabKindHbA1c, Value: 5.5, Unit: models.LabUnitPercent, TakenAt: taken}))
+
+		results, err := repos.Labs.List(user.ID, repository.ListQuery{}, models.LabKindHbA1c)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, hba1c.ID, results[0].ID)
+		assert.Equal(t, 7.4, results[1].Value)
+
+		results, err = repos.Labs.List(user.ID, repository.ListQuery{To: taken}, "")
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		require.NoError(t, repos.Labs.Update(user.ID, hba1c.ID, map[string]interface{}{"value": 6.9, "notes": "повтор"}))
+		updated, err := repos.Labs.GetByID(user.ID, hba1c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 6.9, updated.Value)
+		assert.Equal(t, "повтор", updated.Notes)
+
+		_, err = repos.Labs.GetByID(other.ID, hba1c.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+}
+
+func TestVitalRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 420)
+		other := createUser(t, repos, 421)
+		at := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+
+		diastolic := 85.0
+		pressure := &models.Vital{UserID: user.ID, Kind: models.VitalKindBloodPressure, Value: 135, Diastolic: &diastolic, Unit: models.VitalUnitMmHg, MeasuredAt: at}
+		require.NoError(t, repos.Vitals.Create(pressure))
+		require.NoError(t, repos.Vitals.Create(&models.Vital{UserID: user.ID, Kind: models.VitalKindWeight, Value: 82.5, Unit: models.VitalUnitKg, MeasuredAt: at.Add(time.Hour)}))
+		require.NoError(t, repos.Vitals.Create(&models.Vital{UserID: user.ID, Kind: models.VitalKindBloodPressure, Value: 128, Unit: models.VitalUnitMmHg, MeasuredAt: at.AddDate(0, 0, -1)}))
+		require.NoError(t, repos.Vitals.Create(&models.Vital{UserID: other.ID, Kind: models.VitalKindKetones, Value: 0.4, Unit: models.VitalUnitMmolL, MeasuredAt: at}))
+
+		vitals, err := repos.Vitals.List(user.ID, repository.ListQuery{}, models.VitalKindBloodPressure)
+		require.NoError(t, err)
+		require.Len(t, vitals, 2)
+		assert.Equal(t, pressure.ID, vitals[0].ID)
+		require.NotNil(t, vitals[0].Diastolic)
+		assert.Equal(t, 85.0, *vitals[0].Diastolic)
+		assert.Nil(t, vitals[1].Diastolic)
+
+		vitals, err = repos.Vitals.List(user.ID, repository.ListQuery{From: at}, "")
+		require.NoError(t, err)
+		require.Len(t, vitals, 2)
+		assert.Equal(t, models.VitalKindWeight, vitals[0].Kind)
+
+		_, err = repos.Vitals.GetByID(other.ID, pressure.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+}
+
+func TestAIUsageRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 500)
+
+		_, err := repos.AIUsage.Get(user.ID, "2024-03-10")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		usage := &models.AIUsage{UserID: user.ID, Date: "2024-03-10", RequestCount: 1}
+		require.NoError(t, repos.AIUsage.Create(usage))
+		require.NoError(t, repos.AIUsage.Create(&models.AIUsage{UserID: user.ID, Date: "2024-03-08", RequestCount: 3}))
+		assert.Error(t, repos.AIUsage.Create(&models.AIUsage{UserID: user.ID, Date: "2024-03-10"}))
+
+		usage.RequestCount++
+		require.NoError(t, repos.AIUsage.Save(usage))
+		found, err := repos.AIUsage.Get(user.ID, "2024-03-10")
+		require.NoError(t, err)
+		assert.Equal(t, 2, found.RequestCount)
+
+		deleted, err := repos.AIUsage.DeleteBefore("2024-03-09")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+		_, err = repos.AIUsage.Get(user.ID, "2024-03-08")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+}
+
+func TestGlucoseRepository_CreateBatch(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 600)
+		base := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+
+		require.NoError(t, repos.Glucose.CreateBatch(nil))
+		records := []models.GlucoseRecord{
+			{UserID: user.ID, Value: 5.1, MeasuredAt: base},
+			{UserID: user.ID, Value: 6.2, MeasuredAt: base.Add(15 * time.Minute), Notes: "LibreView"},
+		}
+		require.NoError(t, repos.Glucose.CreateBatch(records))
+		assert.NotZero(t, records[0].ID)
+		assert.NotEqual(t, records[0].ID, records[1].ID)
+
+		found, err := repos.Glucose.List(user.ID, repository.ListQuery{Ascending: true}, "")
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		assert.Equal(t, 5.1, found[0].Value)
+		assert.Equal(t, "LibreView", found[1].Notes)
+	})
+}
+
+func TestGlucoseRepository_Buckets(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 220)
+		other := createUser(t, repos, 221)
+		msk := time.FixedZone("MSK", 3*3600)
+		base := time.Date(2024, 5, 1, 0, 0, 0, 0, msk)
+
+		records := []models.GlucoseRecord{
+			{UserID: user.ID, Value: 5.0, MeasuredAt: base.Add(10 * time.Minute), Source: models.GlucoseSourceCGM},
+			{UserID: user.ID, Value: 7.0, MeasuredAt: base.Add(50 * time.Minute), Source: models.GlucoseSourceCGM},
+			{UserID: user.ID, Value: 6.0, MeasuredAt: base.Add(40 * time.Minute), Source: models.GlucoseSourceFingerstick},
+			{UserID: user.ID, Value: 9.0, MeasuredAt: base.Add(23 * time.Hour), Source: models.GlucoseSourceCGM},
+			{UserID: user.ID, Value: 4.0, MeasuredAt: base.Add(25 * time.Hour), Source: models.GlucoseSourceCGM},
+			{UserID: other.ID, Value: 15.0, MeasuredAt: base.Add(20 * time.Minute), Source: models.GlucoseSourceCGM},
+		}
+		require.NoError(t, repos.Glucose.CreateBatch(records))
+
+		query := repository.BucketQuery{From: base, To: base.Add(48 * time.Hour), Size: time.Hour, Offset: 3 * time.Hour}
+		hourly, err := repos.Glucose.Buckets(user.ID, query)
+		require.NoError(t, err)
+		require.Len(t, hourly, 3)
+		assert.True(t, base.Equal(hourly[0].Start), hourly[0].Start)
+		assert.Equal(t, int64(3), hourly[0].Count)
+		assert.InDelta(t, 6.0, hourly[0].Average, 0.001)
+		assert.Equal(t, 5.0, hourly[0].Min)
+		assert.Equal(t, 7.0, hourly[0].Max)
+
+		// Сутки по московскому времени: 23:00 попадает в первые, 01:00 — во вторые
+		query.Size, query.Source = 24*time.Hour, models.GlucoseSourceCGM
+		daily, err := repos.Glucose.Buckets(user.ID, query)
+		require.NoError(t, err)
+		require.Len(t, daily, 2)
+		assert.True(t, base.Equal(daily[0].Start), daily[0].Start)
+		assert.Equal(t, int64(3), daily[0].Count)
+		assert.Equal(t, 9.0, daily[0].Max)
+		assert.True(t, base.Add(24*time.Hour).Equal(daily[1].Start))
+		assert.Equal(t, int64(1), daily[1].Count)
+	})
+}
+
+func TestGlucoseRepository_DeleteSourceBefore(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 230)
+		now := time.Now()
+		records := []models.GlucoseRecord{
+			{UserID: user.ID, Value: 5.0, MeasuredAt: now.AddDate(0, 0, -100), Source: models.GlucoseSourceCGM},
+			{UserID: user.ID, Value: 5.5, MeasuredAt: now.AddDate(0, 0, -100), Source: models.GlucoseSourceFingerstick},
+			{UserID: user.ID, Value: 6.0, MeasuredAt: now.AddDate(0, 0, -1), Source: models.GlucoseSourceCGM},
+		}
+		require.NoError(t, repos.Glucose.CreateBatch(records))
+
+		deleted, err := repos.Glucose.DeleteSourceBefore(models.GlucoseSourceCGM, now.AddDate(0, 0, -90))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		left, err := repos.Glucose.List(user.ID, repository.ListQuery{Ascending: true}, "")
+		require.NoError(t, err)
+		require.Len(t, left, 2)
+		assert.Equal(t, models.GlucoseSourceFingerstick, left[0].Source)
+
+		cgm, err := repos.Glucose.ListBySource(user.ID, repository.ListQuery{}, models.GlucoseSourceCGM)
+		require.NoError(t, err)
+		require.Len(t, cgm, 1)
+		assert.Equal(t, 6.0, cgm[0].Value)
+	})
+}
+
+func TestImportJobRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 700)
+		other := createUser(t, repos, 701)
+
+		job := &models.ImportJob{UserID: user.ID, Source: models.ImportSourceAPI, Format: "dexcom", Status: models.ImportStatusPreview, Total: 10}
+		require.NoError(t, repos.ImportJobs.Create(job))
+		require.NotZero(t, job.ID)
+
+		_, err := repos.ImportJobs.GetByID(other.ID, job.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		completedAt := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+		require.NoError(t, repos.ImportJobs.Update(user.ID, job.ID, map[string]interface{}{
+			"status":       models.ImportStatusCompleted,
+			"imported":     8,
+			"completed_at": completedAt,
+		}))
+		require.NoError(t, repos.ImportJobs.Update(other.ID, job.ID, map[string]interface{}{"status": models.ImportStatusCancelled}))
+
+		found, err := repos.ImportJobs.GetByID(user.ID, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportStatusCompleted, found.Status)
+		assert.Equal(t, 8, found.Imported)
+		assert.Equal(t, 10, found.Total)
+		require.NotNil(t, found.CompletedAt)
+		assert.True(t, completedAt.Equal(*found.CompletedAt))
+
+		// Claim срабатывает один раз: статус уже сменился
+		pending := &models.ImportJob{UserID: user.ID, Source: models.ImportSourceAPI, Format: "dexcom", Status: models.ImportStatusPreview}
+		require.NoError(t, repos.ImportJobs.Create(pending))
+		claim := map[string]interface{}{"status": models.ImportStatusCancelled}
+		assert.ErrorIs(t, repos.ImportJobs.Claim(other.ID, pending.ID, models.ImportStatusPreview, claim), repository.ErrNotFound)
+		require.NoError(t, repos.ImportJobs.Claim(user.ID, pending.ID, models.ImportStatusPreview, claim))
+		assert.ErrorIs(t, repos.ImportJobs.Claim(user.ID, pending.ID, models.ImportStatusPreview, claim), repository.ErrNotFound)
+	})
+}
+
+func TestShareRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		owner := createUser(t, repos, 150)
+		grantee := createUser(t, repos, 151)
+		other := createUser(t, repos, 152)
+
+		invite := &models.Share{OwnerID: owner.ID, Role: models.ShareRoleRead, TokenHash: "hash-1", ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, repos.Shares.Create(invite))
+		require.NotZero(t, invite.ID)
+		second := &models.Share{OwnerID: owner.ID, Role: models.ShareRoleWrite, TokenHash: "hash-2", ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, repos.Shares.Create(second))
+
+		found, err := repos.Shares.GetByTokenHash("hash-1")
+		require.NoError(t, err)
+		assert.Equal(t, invite.ID, found.ID)
+		assert.Nil(t, found.GranteeID)
+		_, err = repos.Shares.Get(owner.ID, grantee.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		// Приглашение принимается один раз
+		at := time.Now().UTC().Truncate(time.Second)
+		require.NoError(t, repos.Shares.Accept(invite.ID, grantee.ID, at))
+		assert.ErrorIs(t, repos.Shares.Accept(invite.ID, other.ID, at), repository.ErrNotFound)
+
+		found, err = repos.Shares.Get(owner.ID, grantee.ID)
+		require.NoError(t, err)
+		assert.Equal(t, invite.ID, found.ID)
+		require.NotNil(t, found.AcceptedAt)
+		assert.True(t, at.Equal(*found.AcceptedAt))
+
+		require.NoError(t, repos.Shares.Update(invite.ID, map[string]interface{}{"role": models.ShareRoleWrite}))
+		byGrantee, err := repos.Shares.ListByGrantee(grantee.ID)
+		require.NoError(t, err)
+		require.Len(t, byGrantee, 1)
+		assert.Equal(t, models.ShareRoleWrite, byGrantee[0].Role)
+
+		byOwner, err := repos.Shares.ListByOwner(owner.ID)
+		require.NoError(t, err)
+		require.Len(t, byOwner, 2)
+		assert.Equal(t, second.ID, byOwner[0].ID)
+
+		// Отозванный доступ больше не находится
+		require.NoError(t, repos.Shares.Delete(invite.ID))
+		_, err = repos.Shares.Get(owner.ID, grantee.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		_, err = repos.Shares.GetByID(invite.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		byGrantee, err = repos.Shares.ListByGrantee(grantee.ID)
+		require.NoError(t, err)
+		assert.Empty(t, byGrantee)
+		assert.ErrorIs(t, repos.Shares.Accept(invite.ID, other.ID, at), repository.ErrNotFound)
+	})
+}
+
+func TestAlertRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 160)
+		other := createUser(t, repos, 161)
+
+		_, err := repos.Alerts.GetRule(user.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		rule := &models.AlertRule{UserID: user.ID, Enabled: true, LowThreshold: 3, HighThreshold: 20, RecheckMinutes: 15}
+		require.NoError(t, repos.Alerts.SaveRule(rule))
+		require.NotZero(t, rule.ID)
+
+		// Повторное сохранение перезаписывает и нулевые значения
+		rule.Enabled = false
+		rule.QuietStart, rule.QuietEnd = "23:00", "07:00"
+		require.NoError(t, repos.Alerts.SaveRule(rule))
+		saved, err := repos.Alerts.GetRule(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, rule.ID, saved.ID)
+		assert.False(t, saved.Enabled)
+		assert.Equal(t, "07:00", saved.QuietEnd)
+
+		base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+		resolved := &models.Alert{UserID: user.ID, Kind: models.AlertKindHigh, Value: 22, Status: models.AlertStatusResolved, RecheckBy: base, CreatedAt: base}
+		open := &models.Alert{UserID: user.ID, Kind: models.AlertKindLow, Value: 2.6, Status: models.AlertStatusOpen, RecheckBy: base.Add(time.Hour), CreatedAt: base.Add(time.Minute)}
+		foreign := &models.Alert{UserID: other.ID, Kind: models.AlertKindLow, Value: 2.8, Status: models.AlertStatusOpen, RecheckBy: base.Add(30 * time.Minute), CreatedAt: base}
+		for _, alert := range []*models.Alert{resolved, open, foreign} {
+			require.NoError(t, repos.Alerts.Create(alert))
+		}
+
+		active, err := repos.Alerts.Active(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, open.ID, active.ID)
+		// Вторая незакрытая тревога того же пользователя не создается
+		second := &models.Alert{UserID: user.ID, Kind: models.AlertKindLow, Value: 2.5, Status: models.AlertStatusMuted, RecheckBy: base, CreatedAt: base}
+		assert.ErrorIs(t, repos.Alerts.Create(second), repository.ErrDuplicate)
+
+		due, err := repos.Alerts.ListDue(base.Add(45 * time.Minute))
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, foreign.ID, due[0].ID)
+		due, err = repos.Alerts.ListDue(base.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Len(t, due, 2)
+
+		// Отложенная в тихие часы тревога тоже ждет: ее разошлют после их окончания
+		require.NoError(t, repos.Alerts.Update(foreign.ID, map[string]interface{}{"status": models.AlertStatusMuted}))
+		due, err = repos.Alerts.ListDue(base.Add(45 * time.Minute))
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, models.AlertStatusMuted, due[0].Status)
+
+		// Забрать тревогу можно только из ожидаемого статуса, и только один раз
+		claim := map[string]interface{}{"status": models.AlertStatusOpen}
+		assert.ErrorIs(t, repos.Alerts.Claim(foreign.ID, models.AlertStatusOpen, claim), repository.ErrNotFound)
+		require.NoError(t, repos.Alerts.Claim(foreign.ID, models.AlertStatusMuted, claim))
+		assert.ErrorIs(t, repos.Alerts.Claim(foreign.ID, models.AlertStatusMuted, claim), repository.ErrNotFound)
+
+		// Подтвержденная тревога остается активной, но не ждет эскалации
+		require.NoError(t, repos.Alerts.Update(open.ID, map[string]interface{}{"status": models.AlertStatusAcknowledged, "acknowledged_by": other.ID}))
+		due, err = repos.Alerts.ListDue(base.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Len(t, due, 1)
+		found, err := repos.Alerts.GetByID(open.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.AlertStatusAcknowledged, found.Status)
+		require.NotNil(t, found.AcknowledgedBy)
+		assert.Equal(t, other.ID, *found.AcknowledgedBy)
+
+		list, err := repos.Alerts.List(user.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, open.ID, list[0].ID)
+		list, err = repos.Alerts.List(user.ID, 1)
+		require.NoError(t, err)
+		assert.Len(t, list, 1)
+
+		require.NoError(t, repos.Alerts.Update(open.ID, map[string]interface{}{"status": models.AlertStatusResolved}))
+		_, err = repos.Alerts.Active(user.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+}
+
+func TestPublicLinkRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 170)
+		other := createUser(t, repos, 171)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		link := &models.PublicLink{UserID: user.ID, TokenHash: "link-1", PeriodFrom: now.AddDate(0, 0, -14), PeriodTo: now, ExpiresAt: now.Add(time.Hour)}
+		require.NoError(t, repos.Links.Create(link))
+		require.NotZero(t, link.ID)
+		second := &models.PublicLink{UserID: user.ID, TokenHash: "link-2", PeriodFrom: now.AddDate(0, 0, -7), PeriodTo: now, ExpiresAt: now.Add(time.Hour)}
+		require.NoError(t, repos.Links.Create(second))
+
+		found, err := repos.Links.GetByTokenHash("link-1")
+		require.NoError(t, err)
+		assert.Equal(t, link.ID, found.ID)
+		_, err = repos.Links.GetByID(other.ID, link.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		for i := 0; i < 2; i++ {
+			access := &models.PublicLinkAccess{LinkID: link.ID, IP: "10.0.0.1", UserAgent: "test", AccessedAt: now.Add(time.Duration(i) * time.Minute)}
+			require.NoError(t, repos.Links.LogAccess(access))
+		}
+		found, err = repos.Links.GetByID(user.ID, link.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, found.AccessCount)
+		require.NotNil(t, found.LastAccessedAt)
+		assert.True(t, now.Add(time.Minute).Equal(*found.LastAccessedAt))
+
+		accesses, err := repos.Links.ListAccesses(link.ID, 1)
+		require.NoError(t, err)
+		require.Len(t, accesses, 1)
+		assert.True(t, now.Add(time.Minute).Equal(accesses[0].AccessedAt))
+
+		links, err := repos.Links.ListByUser(user.ID)
+		require.NoError(t, err)
+		require.Len(t, links, 2)
+		assert.Equal(t, second.ID, links[0].ID)
+
+		// Чужую ссылку отозвать нельзя, отозванная больше не открывается
+		require.NoError(t, repos.Links.Delete(other.ID, link.ID))
+		_, err = repos.Links.GetByTokenHash("link-1")
+		require.NoError(t, err)
+		require.NoError(t, repos.Links.Delete(user.ID, link.ID))
+		_, err = repos.Links.GetByTokenHash("link-1")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		links, err = repos.Links.ListByUser(user.ID)
+		require.NoError(t, err)
+		assert.Len(t, links, 1)
+	})
+}
+
+func TestDigestRepository(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 180)
+		other := createUser(t, repos, 181)
+
+		_, err := repos.Digests.GetSubscription(user.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		sub := &models.DigestSubscription{UserID: user.ID, Enabled: true, Timezone: "Europe/Moscow"}
+		require.NoError(t, repos.Digests.SaveSubscription(sub))
+		require.NoError(t, repos.Digests.SaveSubscription(&models.DigestSubscription{UserID: other.ID}))
+		subs, err := repos.Digests.ListSubscribed()
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+		assert.Equal(t, user.ID, subs[0].UserID)
+
+		sub.Enabled = false
+		require.NoError(t, repos.Digests.SaveSubscription(sub))
+		found, err := repos.Digests.GetSubscription(user.ID)
+		require.NoError(t, err)
+		assert.False(t, found.Enabled)
+		assert.Equal(t, "Europe/Moscow", found.Timezone)
+
+		week := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+		first := &models.Digest{UserID: user.ID, PeriodFrom: week, PeriodTo: week.AddDate(0, 0, 7), Summary: "неделя 1"}
+		require.NoError(t, repos.Digests.Save(first))
+		second := &models.Digest{UserID: user.ID, PeriodFrom: week.AddDate(0, 0, 7), PeriodTo: week.AddDate(0, 0, 14), Summary: "неделя 2"}
+		require.NoError(t, repos.Digests.Save(second))
+
+		// Перезапись сводки за ту же неделю
+		first.Commentary = "комментарий"
+		require.NoError(t, repos.Digests.Save(first))
+		digest, err := repos.Digests.GetByPeriod(user.ID, week)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, digest.ID)
+		assert.Equal(t, "комментарий", digest.Commentary)
+		_, err = repos.Digests.GetByPeriod(other.ID, week)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		// Отметку об отправке ставит только MarkSent и только один раз
+		sentAt := week.AddDate(0, 0, 6).Add(19 * time.Hour)
+		require.NoError(t, repos.Digests.MarkSent(first.ID, sentAt))
+		assert.ErrorIs(t, repos.Digests.MarkSent(first.ID, sentAt.Add(time.Hour)), repository.ErrNotFound)
+		first.Commentary = "пересобрана"
+		require.NoError(t, repos.Digests.Save(first))
+		digest, err = repos.Digests.GetByPeriod(user.ID, week)
+		require.NoError(t, err)
+		require.NotNil(t, digest.SentAt)
+		assert.True(t, sentAt.Equal(*digest.SentAt))
+
+		digests, err := repos.Digests.List(user.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, digests, 2)
+		assert.Equal(t, second.ID, digests[0].ID)
+
+		require.NoError(t, repos.Digests.DeleteAllByUser(user.ID))
+		digests, err = repos.Digests.List(user.ID, 10)
+		require.NoError(t, err)
+		assert.Empty(t, digests)
+		_, err = repos.Digests.GetByPeriod(user.ID, week)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+}
+
+func TestRecordRepository_List(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, repos *repository.Repositories) {
+		user := createUser(t, repos, 600)
+		base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+		// Две записи с одинаковым временем, чтобы проверить порядок по ID
+		contexts := []string{models.GlucoseContextFasting, "", models.GlucoseContextFasting, models.GlucoseContextAfterMeal, ""}
+		offsets := []time.Duration{0, time.Hour, time.Hour, 2 * time.Hour, 48 * time.Hour}
+		var ids []uint
+		for i := range contexts {
+			record := &models.GlucoseRecord{UserID: user.ID, Value: float64(5 + i), MeasuredAt: base.Add(offsets[i]), MeasurementContext: contexts[i]}
+			require.NoError(t, repos.Glucose.Create(record))
+			ids = append(ids, record.ID)
+		}
+
+		collect := func(query repository.ListQuery, context string) []uint {
+			var result []uint
+			for {
+				records, err := repos.Glucose.List(user.ID, query, context)
+				require.NoError(t, err)
+				for _, r := range records {
+					result = append(result, r.ID)
+				}
+				if len(records) < query.Limit || query.Limit == 0 {
+					return result
+				}
+				last := records[len(records)-1]
+				query.After = &repository.Cursor{At: last.MeasuredAt, ID: last.ID}
+			}
+		}
+
+		assert.Equal(t, []uint{ids[4], ids[3], ids[2], ids[1], ids[0]}, collect(repository.ListQuery{Limit: 2}, ""))
+		assert.Equal(t, []uint{ids[0], ids[1], ids[2], ids[3], ids[4]}, collect(repository.ListQuery{Limit: 2, Ascending: true}, ""))
+		assert.Equal(t, []uint{ids[3], ids[2], ids[1]}, collect(repository.ListQuery{From: base.Add(time.Hour), To: base.Add(24 * time.Hour)}, ""))
+		assert.Equal(t, []uint{ids[2], ids[0]}, collect(repository.ListQuery{Limit: 1}, models.GlucoseContextFasting))
+
+		breakfast := &models.FoodRecord{UserID: user.ID, FoodName: "Каша", FoodType: "завтрак", ConsumedAt: base}
+		require.NoError(t, repos.Food.Create(breakfast))
+		require.NoError(t, repos.Food.Create(&models.FoodRecord{UserID: user.ID, FoodName: "Суп", FoodType: "обед", ConsumedAt: base.Add(5 * time.Hour)}))
+		food, err := repos.Food.List(user.ID, repository.ListQuery{}, "завтрак")
+		require.NoError(t, err)
+		require.Len(t, food, 1)
+		assert.Equal(t, breakfast.ID, food[0].ID)
+
+		basal := &models.InsulinRecord{UserID: user.ID, Units: 12, InsulinType: models.InsulinTypeBasal, InjectedAt: base.Add(-time.Hour)}
+		require.NoError(t, repos.Insulin.Create(basal))
+		require.NoError(t, repos.Insulin.Create(&models.InsulinRecord{UserID: user.ID, Units: 4, InsulinType: models.InsulinTypeBolus, InjectedAt: base}))
+		insulin, err := repos.Insulin.List(user.ID, repository.ListQuery{Ascending: true, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, insulin, 1)
+		assert.Equal(t, basal.ID, insulin[0].ID)
+	})
+}
@@ -1,12 +1,50 @@
 package services
 
-import "diabetbot/internal/models"
+import (
+	"strings"
+
+	"diabetbot/internal/models"
+)
 
 // AIService интерфейс для различных AI провайдеров
 type AIService interface {
 	GetGlucoseRecommendation(user *models.User, record *models.GlucoseRecord) string
 	GetFoodRecommendation(user *models.User, foodDescription string) string
 	GetGeneralRecommendation(user *models.User, question string) string
+	// GetDigestCommentary комментирует показатели недели из еженедельной сводки.
+	// Пустая строка — ИИ недоступен, сводка отправляется без комментария.
+	GetDigestCommentary(user *models.User, summary string) string
+}
+
+// PatientContext дополняет запросы к ИИ сведениями из дневника пациента, например
+// найденными закономерностями. Пустая строка — дополнить нечем.
+type PatientContext interface {
+	AIContext(user *models.User) string
+}
+
+// PatientContexts объединяет несколько источников сведений из дневника: непустые
+// ответы идут по порядку через пустую строку
+type PatientContexts []PatientContext
+
+func (c PatientContexts) AIContext(user *models.User) string {
+	var parts []string
+	for _, ctx := range c {
+		if extra := ctx.AIContext(user); extra != "" {
+			parts = append(parts, extra)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// withPatientContext добавляет к запросу пациента сведения из дневника, если они есть
+func withPatientContext(ctx PatientContext, user *models.User, prompt string) string {
+	if ctx == nil {
+		return prompt
+	}
+	if extra := ctx.AIContext(user); extra != "" {
+		return prompt + "\n\n" + extra
+	}
+	return prompt
 }
 
 // Убеждаемся, что оба сервиса реализуют интерфейс
@@ -4,9 +4,9 @@ import (
 	"diabetbot/internal/models"
 	"fmt"
 	"log"
+	"diabetbot/internal/repository"
+	"errors"
 	"time"
-
-	"gorm.io/gorm"
 )
 
 const (
@@ -14,28 +14,27 @@ const (
 )
 
 type AIUsageService struct {
-	db *gorm.DB
+	repo repository.AIUsageRepository
 }
 
-func NewAIUsageService(db *gorm.DB) *AIUsageService {
-	return &AIUsageService{db: db}
+func NewAIUsageService(repo repository.AIUsageRepository) *AIUsageService {
+	return &AIUsageService{repo: repo}
 }
 
 // CheckAndIncrementUsage проверяет лимит и увеличивает счетчик использования
 func (s *AIUsageService) CheckAndIncrementUsage(userID uint) (bool, int, error) {
-	today := time.Now().UTC().Truncate(24 * time.Hour)
+	today := usageDate(time.Now())
 	
-	var usage models.AIUsage
-	err := s.db.Where("user_id = ? AND date = ?", userID, today).First(&usage).Error
+	usage, err := s.repo.Get(userID, today)
 	
-	if err == gorm.ErrRecordNotFound {
+	if errors.Is(err, repository.ErrNotFound) {
 		// Создаем новую запись для сегодня
-		usage = models.AIUsage{
+		usage = &models.AIUsage{
 			UserID:       userID,
 			Date:         today,
 			RequestCount: 1,
 		}
-		if err := s.db.Create(&usage).Error; err != nil {
+		if err := s.repo.Create(usage); err != nil {
 			return false, 0, fmt.Errorf("failed to create AI usage record: %w", err)
 		}
 		log.Printf("AI usage: user %d made 1/%d requests today", userID, DailyAIRequestLimit)
@@ -54,7 +53,7 @@ func (s *AIUsageService) CheckAndIncrementUsage(userID uint) (bool, int, error)
 	
 	// Увеличиваем счетчик
 	usage.RequestCount++
-	if err := s.db.Save(&usage).Error; err != nil {
+	if err := s.repo.Save(usage); err != nil {
 		return false, 0, fmt.Errorf("failed to update AI usage: %w", err)
 	}
 	
@@ -66,12 +65,11 @@ func (s *AIUsageService) CheckAndIncrementUsage(userID uint) (bool, int, error)
 
 // GetUsageToday возвращает количество использованных запросов за сегодня
 func (s *AIUsageService) GetUsageToday(userID uint) (int, error) {
-	today := time.Now().UTC().Truncate(24 * time.Hour)
+	today := usageDate(time.Now())
 	
-	var usage models.AIUsage
-	err := s.db.Where("user_id = ? AND date = ?", userID, today).First(&usage).Error
+	usage, err := s.repo.Get(userID, today)
 	
-	if err == gorm.ErrRecordNotFound {
+	if errors.Is(err, repository.ErrNotFound) {
 		return 0, nil
 	}
 	
@@ -99,16 +97,16 @@ func (s *AIUsageService) GetRemainingRequests(userID uint) (int, error) {
 
 // ResetDailyUsage сбрасывает счетчики для всех пользователей (для cron job)
 func (s *AIUsageService) ResetDailyUsage() error {
-	yesterday := time.Now().UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	yesterday := usageDate(time.Now().AddDate(0, 0, -1))
 	
 	// Удаляем записи старше вчерашнего дня
-	result := s.db.Where("date < ?", yesterday).Delete(&models.AIUsage{})
-	if result.Error != nil {
-		return fmt.Errorf("failed to clean old AI usage records: %w", result.Error)
+	deleted, err := s.repo.DeleteBefore(yesterday)
+	if err != nil {
+		return fmt.Errorf("failed to clean old AI usage records: %w", err)
 	}
 	
-	if result.RowsAffected > 0 {
-		log.Printf("Cleaned %d old AI usage records", result.RowsAffected)
+	if deleted > 0 {
+		log.Printf("Cleaned %d old AI usage records", deleted)
 	}
 	
 	return nil
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIUsageService_CheckAndIncrementUsage(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewAIUsageService(repository.NewGormAIUsageRepository(db))
+	user := testutils.CreateTestUser(db, 123)
+
+	for i := 1; i <= DailyAIRequestLimit; i++ {
+		allowed, remaining, err := service.CheckAndIncrementUsage(user.ID)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, DailyAIRequestLimit-i, remaining)
+	}
+
+	allowed, remaining, err := service.CheckAndIncrementUsage(user.ID)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 0, remaining)
+
+	used, err := service.GetUsageToday(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, DailyAIRequestLimit, used)
+
+	// Счетчик хранится одной строкой на день
+	var usages []models.AIUsage
+	require.NoError(t, db.Find(&usages).Error)
+	require.Len(t, usages, 1)
+	assert.Equal(t, time.Now().UTC().Format("2006-01-02"), usages[0].Date)
+}
+
+func TestAIUsageService_ResetDailyUsage(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewAIUsageService(repository.NewGormAIUsageRepository(db))
+	user := testutils.CreateTestUser(db, 123)
+
+	for _, daysAgo := range []int{0, 1, 2, 30} {
+		require.NoError(t, db.Create(&models.AIUsage{
+			UserID:       user.ID,
+			Date:         usageDate(time.Now().AddDate(0, 0, -daysAgo)),
+			RequestCount: 3,
+		}).Error)
+	}
+
+	require.NoError(t, service.ResetDailyUsage())
+
+	var usages []models.AIUsage
+	require.NoError(t, db.Order("date DESC").Find(&usages).Error)
+	require.Len(t, usages, 2)
+	assert.Equal(t, usageDate(time.Now()), usages[0].Date)
+	assert.Equal(t, usageDate(time.Now().AddDate(0, 0, -1)), usages[1].Date)
+}
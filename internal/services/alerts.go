@@ -0,0 +1,440 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// Правило тревог по умолчанию и допустимые границы настроек
+const (
+	DefaultAlertLow     = 3.0
+	DefaultAlertHigh    = 20.0
+	DefaultAlertRecheck = 15
+
+	minAlertLow, maxAlertLow         = 2.0, 4.5
+	minAlertHigh, maxAlertHigh       = 10.0, 30.0
+	minAlertRecheck, maxAlertRecheck = 5, 120
+)
+
+// alertFreshness — показания старше этого срока (загрузка истории с сенсора) тревог не вызывают
+const alertFreshness = 30 * time.Minute
+
+// alertClock — формат начала и конца тихих часов
+const alertClock = "15:04"
+
+// События тревоги для AlertNotifier
+const (
+	AlertEventRaised       = "raised"       // критическое значение, родственники и владелец узнают сразу
+	AlertEventEscalated    = "escalated"    // повторного измерения не было в срок
+	AlertEventResolved     = "resolved"     // повторное измерение в норме
+	AlertEventAcknowledged = "acknowledged" // родственник подтвердил тревогу
+)
+
+// AlertEvent — изменение тревоги, о котором нужно сообщить владельцу дневника и родственникам
+type AlertEvent struct {
+	Type       string
+	Alert      models.Alert
+	Owner      *models.User
+	Caregivers []models.User // получатели доступа к дневнику
+	By         *models.User  // кто подтвердил тревогу (acknowledged)
+}
+
+// AlertNotifier доставляет события тревог, например сообщениями в Telegram
+type AlertNotifier interface {
+	NotifyAlert(event AlertEvent)
+}
+
+// AlertService поднимает тревогу родственникам при тяжелой гипогликемии или очень
+// высоком сахаре и эскалирует ее, если пользователь не сделал повторное измерение.
+// Время берется из now, чтобы тесты могли подменить часы.
+type AlertService struct {
+	users    repository.UserRepository
+	shares   repository.ShareRepository
+	alerts   repository.AlertRepository
+	notifier AlertNotifier
+	now      func() time.Time
+}
+
+func NewAlertService(users repository.UserRepository, shares repository.ShareRepository, alerts repository.AlertRepository) *AlertService {
+	return &AlertService{users: users, shares: shares, alerts: alerts, now: time.Now}
+}
+
+// SetNotifier задает получателя событий; без него тревоги сохраняются, но не рассылаются
+func (s *AlertService) SetNotifier(notifier AlertNotifier) {
+	s.notifier = notifier
+}
+
+// Rules возвращает правило пользователя или правило по умолчанию, если оно не сохранялось
+func (s *AlertService) Rules(userID uint) (*models.AlertRule, error) {
+	rule, err := s.alerts.GetRule(userID)
+	if errors.Is(err, ErrNotFound) {
+		return &models.AlertRule{
+			UserID:         userID,
+			Enabled:        true,
+			LowThreshold:   DefaultAlertLow,
+			HighThreshold:  DefaultAlertHigh,
+			RecheckMinutes: DefaultAlertRecheck,
+		}, nil
+	}
+	return rule, err
+}
+
+// UpdateRules проверяет и сохраняет настройки тревог пользователя
+func (s *AlertService) UpdateRules(userID uint, update models.AlertRule) (*models.AlertRule, error) {
+	if err := validateAlertRule(&update); err != nil {
+		return nil, err
+	}
+	rule, err := s.Rules(userID)
+	if err != nil {
+		return nil, err
+	}
+	rule.Enabled = update.Enabled
+	rule.LowThreshold = update.LowThreshold
+	rule.HighThreshold = update.HighThreshold
+	rule.RecheckMinutes = update.RecheckMinutes
+	rule.QuietStart, rule.QuietEnd = update.QuietStart, update.QuietEnd
+	rule.Timezone = update.Timezone
+	if err := s.alerts.SaveRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func validateAlertRule(rule *models.AlertRule) error {
+	if rule.LowThreshold < minAlertLow || rule.LowThreshold > maxAlertLow {
+		return &ValidationError{Field: "low_threshold", Rule: "between", Param: fmt.Sprintf("%g %g", minAlertLow, maxAlertLow)}
+	}
+	if rule.HighThreshold < minAlertHigh || rule.HighThreshold > maxAlertHigh {
+		return &ValidationError{Field: "high_threshold", Rule: "between", Param: fmt.Sprintf("%g %g", minAlertHigh, maxAlertHigh)}
+	}
+	if rule.RecheckMinutes < minAlertRecheck || rule.RecheckMinutes > maxAlertRecheck {
+		return &ValidationError{Field: "recheck_minutes", Rule: "between", Param: fmt.Sprintf("%d %d", minAlertRecheck, maxAlertRecheck)}
+	}
+	for _, field := range []struct{ name, value string }{{"quiet_start", rule.QuietStart}, {"quiet_end", rule.QuietEnd}} {
+		if _, err := time.Parse(alertClock, field.value); field.value != "" && err != nil {
+			return &ValidationError{Field: field.name, Rule: "time_of_day"}
+		}
+	}
+	switch {
+	case rule.QuietStart != "" && rule.QuietEnd == "":
+		return &ValidationError{Field: "quiet_end", Rule: "required"}
+	case rule.QuietStart == "" && rule.QuietEnd != "":
+		return &ValidationError{Field: "quiet_start", Rule: "required"}
+	}
+	if _, err := time.LoadLocation(rule.Timezone); err != nil {
+		return &ValidationError{Field: "timezone", Rule: "invalid"}
+	}
+	return nil
+}
+
+// alertKind возвращает вид тревоги для значения или пустую строку, если значение не критическое
+func alertKind(rule *models.AlertRule, value float64) string {
+	switch {
+	case value < rule.LowThreshold:
+		return models.AlertKindLow
+	case value > rule.HighThreshold:
+		return models.AlertKindHigh
+	}
+	return ""
+}
+
+// quietAt сообщает, попадает ли at в тихие часы правила
+func quietAt(rule *models.AlertRule, at time.Time) bool {
+	start, err := time.Parse(alertClock, rule.QuietStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(alertClock, rule.QuietEnd)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	local := at.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// muted сообщает, что тревогу не нужно рассылать: высокий сахар в тихие часы
+// может подождать до утра, гипогликемия — нет
+func muted(rule *models.AlertRule, kind string, at time.Time) bool {
+	return kind == models.AlertKindHigh && quietAt(rule, at)
+}
+
+// observe проверяет только что сохраненные измерения. Ошибки не возвращаются:
+// запись уже сохранена, а тревога не должна ломать ввод данных.
+func (s *AlertService) observe(userID uint, records []models.GlucoseRecord) {
+	if s == nil || len(records) == 0 {
+		return
+	}
+	latest := &records[0]
+	for i := range records {
+		if records[i].MeasuredAt.After(latest.MeasuredAt) {
+			latest = &records[i]
+		}
+	}
+	if s.now().Sub(latest.MeasuredAt) > alertFreshness {
+		return
+	}
+	if err := s.check(userID, latest); err != nil {
+		log.Printf("Error checking glucose alert for user %d: %v", userID, err)
+	}
+}
+
+// check поднимает, продлевает или закрывает тревогу по новому измерению
+func (s *AlertService) check(userID uint, record *models.GlucoseRecord) error {
+	rule, err := s.Rules(userID)
+	if err != nil {
+		return err
+	}
+	active, err := s.alerts.Active(userID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		active = nil
+	case err != nil:
+		return err
+	}
+
+	now := s.now()
+	kind := alertKind(rule, record.Value)
+	if active != nil {
+		if !record.MeasuredAt.After(active.MeasuredAt) {
+			return nil
+		}
+		if kind == active.Kind {
+			return s.extend(rule, active, record, now)
+		}
+		if err := s.resolve(active, now); err != nil {
+			return err
+		}
+	}
+	if kind == "" || !rule.Enabled {
+		return nil
+	}
+
+	caregivers, err := s.caregivers(userID)
+	if err != nil || len(caregivers) == 0 {
+		return err
+	}
+	alert := &models.Alert{
+		UserID:     userID,
+		RecordID:   record.ID,
+		Kind:       kind,
+		Value:      record.Value,
+		MeasuredAt: record.MeasuredAt,
+		Status:     models.AlertStatusOpen,
+		RecheckBy:  now.Add(time.Duration(rule.RecheckMinutes) * time.Minute),
+	}
+	if muted(rule, kind, now) {
+		alert.Status = models.AlertStatusMuted
+	}
+	err = s.alerts.Create(alert)
+	if errors.Is(err, repository.ErrDuplicate) {
+		// Тревогу по параллельному измерению уже подняла и разослала другая реплика
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if alert.Status == models.AlertStatusMuted {
+		return nil
+	}
+	return s.notify(AlertEventRaised, alert, caregivers, nil)
+}
+
+// extend обновляет тревогу, если повторное измерение все еще критическое, и дает
+// новый срок на следующее. Отложенная в тихие часы тревога рассылается, когда они закончились.
+func (s *AlertService) extend(rule *models.AlertRule, alert *models.Alert, record *models.GlucoseRecord, now time.Time) error {
+	updates := map[string]interface{}{
+		"record_id":   record.ID,
+		"value":       record.Value,
+		"measured_at": record.MeasuredAt,
+		"recheck_by":  now.Add(time.Duration(rule.RecheckMinutes) * time.Minute),
+	}
+	raise := alert.Status == models.AlertStatusMuted && !muted(rule, alert.Kind, now) && rule.Enabled
+	if alert.Status == models.AlertStatusEscalated || raise {
+		updates["status"] = models.AlertStatusOpen
+	}
+	if err := s.alerts.Update(alert.ID, updates); err != nil {
+		return err
+	}
+	if !raise {
+		return nil
+	}
+	updated, err := s.alerts.GetByID(alert.ID)
+	if err != nil {
+		return err
+	}
+	caregivers, err := s.caregivers(alert.UserID)
+	if err != nil {
+		return err
+	}
+	return s.notify(AlertEventRaised, updated, caregivers, nil)
+}
+
+func (s *AlertService) resolve(alert *models.Alert, now time.Time) error {
+	wasMuted := alert.Status == models.AlertStatusMuted
+	alert.Status, alert.ResolvedAt = models.AlertStatusResolved, &now
+	if err := s.alerts.Update(alert.ID, map[string]interface{}{"status": alert.Status, "resolved_at": now}); err != nil {
+		return err
+	}
+	if wasMuted {
+		return nil
+	}
+	caregivers, err := s.caregivers(alert.UserID)
+	if err != nil {
+		return err
+	}
+	return s.notify(AlertEventResolved, alert, caregivers, nil)
+}
+
+// Escalate сообщает родственникам о тревогах, по которым не было повторного
+// измерения в срок, и возвращает их число. Высокий сахар ждет конца тихих часов:
+// отложенная тревога рассылается как новая, даже если измерений больше не было.
+// Подтвержденные родственником тревоги не эскалируются.
+func (s *AlertService) Escalate() (int, error) {
+	now := s.now()
+	due, err := s.alerts.ListDue(now)
+	if err != nil {
+		return 0, err
+	}
+	escalated := 0
+	for i := range due {
+		alert := &due[i]
+		rule, err := s.Rules(alert.UserID)
+		if err != nil {
+			return escalated, err
+		}
+		if muted(rule, alert.Kind, now) {
+			continue
+		}
+		if alert.Status == models.AlertStatusMuted {
+			if rule.Enabled {
+				if err := s.raiseMuted(rule, alert, now); err != nil && !errors.Is(err, ErrNotFound) {
+					return escalated, err
+				}
+			}
+			continue
+		}
+		// Эскалацию запускает каждая реплика: рассылает та, что первой сменила статус
+		err = s.alerts.Claim(alert.ID, models.AlertStatusOpen, map[string]interface{}{"status": models.AlertStatusEscalated, "escalated_at": now})
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return escalated, err
+		}
+		alert.Status, alert.EscalatedAt = models.AlertStatusEscalated, &now
+		escalated++
+		caregivers, err := s.caregivers(alert.UserID)
+		if err != nil {
+			return escalated, err
+		}
+		if err := s.notify(AlertEventEscalated, alert, caregivers, nil); err != nil {
+			return escalated, err
+		}
+	}
+	return escalated, nil
+}
+
+// raiseMuted рассылает тревогу, отложенную в тихие часы, и дает новый срок на повторное измерение
+func (s *AlertService) raiseMuted(rule *models.AlertRule, alert *models.Alert, now time.Time) error {
+	recheckBy := now.Add(time.Duration(rule.RecheckMinutes) * time.Minute)
+	updates := map[string]interface{}{"status": models.AlertStatusOpen, "recheck_by": recheckBy}
+	if err := s.alerts.Claim(alert.ID, models.AlertStatusMuted, updates); err != nil {
+		return err
+	}
+	alert.Status, alert.RecheckBy = models.AlertStatusOpen, recheckBy
+	caregivers, err := s.caregivers(alert.UserID)
+	if err != nil {
+		return err
+	}
+	return s.notify(AlertEventRaised, alert, caregivers, nil)
+}
+
+// Acknowledge отмечает, что родственник (или сам владелец) видит тревогу.
+// Для пользователя без доступа к дневнику тревога не найдена.
+func (s *AlertService) Acknowledge(actorID, alertID uint) (*models.Alert, error) {
+	alert, err := s.alerts.GetByID(alertID)
+	if err != nil {
+		return nil, err
+	}
+	if actorID != alert.UserID {
+		if _, err := s.shares.Get(alert.UserID, actorID); err != nil {
+			return nil, err
+		}
+	}
+	if alert.Status != models.AlertStatusOpen && alert.Status != models.AlertStatusEscalated {
+		return alert, nil
+	}
+
+	now := s.now()
+	alert.Status, alert.AcknowledgedAt, alert.AcknowledgedBy = models.AlertStatusAcknowledged, &now, &actorID
+	err = s.alerts.Update(alert.ID, map[string]interface{}{"status": alert.Status, "acknowledged_at": now, "acknowledged_by": actorID})
+	if err != nil {
+		return nil, err
+	}
+	actor, err := s.users.GetByID(actorID)
+	if err != nil {
+		return nil, err
+	}
+	caregivers, err := s.caregivers(alert.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.notify(AlertEventAcknowledged, alert, caregivers, actor); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// List возвращает последние тревоги пользователя, сначала новые
+func (s *AlertService) List(userID uint, limit int) ([]models.Alert, error) {
+	return s.alerts.List(userID, limit)
+}
+
+// caregivers возвращает пользователей, которым открыт дневник владельца
+func (s *AlertService) caregivers(ownerID uint) ([]models.User, error) {
+	shares, err := s.shares.ListByOwner(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	for _, share := range shares {
+		if share.GranteeID == nil {
+			continue
+		}
+		user, err := s.users.GetByID(*share.GranteeID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, nil
+}
+
+func (s *AlertService) notify(eventType string, alert *models.Alert, caregivers []models.User, by *models.User) error {
+	if s.notifier == nil {
+		return nil
+	}
+	owner, err := s.users.GetByID(alert.UserID)
+	if err != nil {
+		return err
+	}
+	s.notifier.NotifyAlert(AlertEvent{Type: eventType, Alert: *alert, Owner: owner, Caregivers: caregivers, By: by})
+	return nil
+}
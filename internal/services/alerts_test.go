@@ -0,0 +1,366 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	events []AlertEvent
+}
+
+func (n *recordingNotifier) NotifyAlert(event AlertEvent) {
+	n.events = append(n.events, event)
+}
+
+func (n *recordingNotifier) types() []string {
+	var types []string
+	for _, event := range n.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// alertFixture — владелец дневника с родственником и часы, которые двигает тест
+type alertFixture struct {
+	svc      *Services
+	notifier *recordingNotifier
+	owner    *models.User
+	parent   *models.User
+	now      time.Time
+}
+
+func newAlertFixture(t *testing.T) *alertFixture {
+	f := &alertFixture{svc: New(memory.NewRepositories()), notifier: &recordingNotifier{}}
+	f.now = time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	f.svc.Alerts.now = func() time.Time { return f.now }
+	f.svc.Alerts.SetNotifier(f.notifier)
+
+	var err error
+	f.owner, err = f.svc.Users.GetOrCreateUser(960, "", "Маша", "", "ru")
+	require.NoError(t, err)
+	f.parent, err = f.svc.Users.GetOrCreateUser(961, "", "Ольга", "", "ru")
+	require.NoError(t, err)
+	invite, err := f.svc.Sharing.CreateInvite(f.owner.ID, models.ShareRoleRead)
+	require.NoError(t, err)
+	_, err = f.svc.Sharing.AcceptInvite(f.parent.ID, invite.Token)
+	require.NoError(t, err)
+	return f
+}
+
+func (f *alertFixture) record(t *testing.T, value float64) {
+	_, err := f.svc.Glucose.CreateRecordAt(f.owner.ID, value, f.now, "", "")
+	require.NoError(t, err)
+}
+
+func (f *alertFixture) active(t *testing.T) *models.Alert {
+	alert, err := f.svc.Alerts.alerts.Active(f.owner.ID)
+	require.NoError(t, err)
+	return alert
+}
+
+func TestAlertService_EscalatesWithoutRecheck(t *testing.T) {
+	f := newAlertFixture(t)
+
+	f.record(t, 2.6)
+	require.Equal(t, []string{AlertEventRaised}, f.notifier.types())
+	event := f.notifier.events[0]
+	assert.Equal(t, f.owner.ID, event.Owner.ID)
+	require.Len(t, event.Caregivers, 1)
+	assert.Equal(t, f.parent.ID, event.Caregivers[0].ID)
+	assert.Equal(t, models.AlertKindLow, event.Alert.Kind)
+	assert.Equal(t, f.now.Add(DefaultAlertRecheck*time.Minute), event.Alert.RecheckBy)
+
+	// До срока повторного измерения эскалации нет
+	f.now = f.now.Add(10 * time.Minute)
+	n, err := f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	f.now = f.now.Add(5 * time.Minute)
+	n, err = f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{AlertEventRaised, AlertEventEscalated}, f.notifier.types())
+	assert.Equal(t, models.AlertStatusEscalated, f.active(t).Status)
+
+	// Эскалация одна
+	f.now = f.now.Add(time.Hour)
+	n, err = f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Повторное измерение в норме закрывает тревогу
+	f.record(t, 5.4)
+	assert.Equal(t, []string{AlertEventRaised, AlertEventEscalated, AlertEventResolved}, f.notifier.types())
+	_, err = f.svc.Alerts.alerts.Active(f.owner.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAlertService_RecheckStillCritical(t *testing.T) {
+	f := newAlertFixture(t)
+
+	f.record(t, 2.6)
+	f.now = f.now.Add(10 * time.Minute)
+	f.record(t, 2.8)
+
+	// Новое критическое значение продлевает срок, а не поднимает вторую тревогу
+	alert := f.active(t)
+	assert.Equal(t, 2.8, alert.Value)
+	assert.Equal(t, f.now.Add(DefaultAlertRecheck*time.Minute), alert.RecheckBy)
+	assert.Equal(t, []string{AlertEventRaised}, f.notifier.types())
+
+	f.now = f.now.Add(10 * time.Minute)
+	n, err := f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Резкий переход к высокому сахару закрывает старую тревогу и поднимает новую
+	f.record(t, 24)
+	assert.Equal(t, []string{AlertEventRaised, AlertEventResolved, AlertEventRaised}, f.notifier.types())
+	assert.Equal(t, models.AlertKindHigh, f.active(t).Kind)
+}
+
+func TestAlertService_Acknowledge(t *testing.T) {
+	f := newAlertFixture(t)
+	stranger, err := f.svc.Users.GetOrCreateUser(962, "", "Чужой", "", "ru")
+	require.NoError(t, err)
+
+	f.record(t, 2.4)
+	alert := f.active(t)
+
+	_, err = f.svc.Alerts.Acknowledge(stranger.ID, alert.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	acked, err := f.svc.Alerts.Acknowledge(f.parent.ID, alert.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusAcknowledged, acked.Status)
+	require.NotNil(t, acked.AcknowledgedBy)
+	assert.Equal(t, f.parent.ID, *acked.AcknowledgedBy)
+	last := f.notifier.events[len(f.notifier.events)-1]
+	assert.Equal(t, AlertEventAcknowledged, last.Type)
+	assert.Equal(t, f.parent.ID, last.By.ID)
+
+	// Повторное подтверждение ничего не рассылает, подтвержденная тревога не эскалируется
+	_, err = f.svc.Alerts.Acknowledge(f.parent.ID, alert.ID)
+	require.NoError(t, err)
+	f.now = f.now.Add(time.Hour)
+	n, err := f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, []string{AlertEventRaised, AlertEventAcknowledged}, f.notifier.types())
+}
+
+func TestAlertService_QuietHours(t *testing.T) {
+	f := newAlertFixture(t)
+	rule, err := f.svc.Alerts.Rules(f.owner.ID)
+	require.NoError(t, err)
+	rule.QuietStart, rule.QuietEnd, rule.Timezone = "23:00", "07:00", "Europe/Moscow"
+	_, err = f.svc.Alerts.UpdateRules(f.owner.ID, *rule)
+	require.NoError(t, err)
+
+	// 01:00 по Москве: высокий сахар ждет утра, гипогликемия — нет
+	f.now = time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	f.record(t, 23)
+	assert.Empty(t, f.notifier.events)
+	assert.Equal(t, models.AlertStatusMuted, f.active(t).Status)
+	f.now = f.now.Add(time.Hour)
+	n, err := f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// 07:30 по Москве: сахар все еще высокий — тревога рассылается
+	f.now = time.Date(2024, 5, 2, 4, 30, 0, 0, time.UTC)
+	f.record(t, 21.5)
+	assert.Equal(t, []string{AlertEventRaised}, f.notifier.types())
+	assert.Equal(t, models.AlertStatusOpen, f.active(t).Status)
+
+	f.now = time.Date(2024, 5, 2, 22, 0, 0, 0, time.UTC)
+	f.record(t, 2.5)
+	assert.Equal(t, []string{AlertEventRaised, AlertEventResolved, AlertEventRaised}, f.notifier.types())
+	assert.Equal(t, models.AlertKindLow, f.active(t).Kind)
+}
+
+func TestAlertService_QuietHoursEnd(t *testing.T) {
+	f := newAlertFixture(t)
+	rule, err := f.svc.Alerts.Rules(f.owner.ID)
+	require.NoError(t, err)
+	rule.QuietStart, rule.QuietEnd, rule.Timezone = "23:00", "07:00", "Europe/Moscow"
+	_, err = f.svc.Alerts.UpdateRules(f.owner.ID, *rule)
+	require.NoError(t, err)
+
+	f.now = time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	f.record(t, 23)
+	require.Equal(t, models.AlertStatusMuted, f.active(t).Status)
+
+	// 06:59 по Москве: тихие часы еще идут
+	f.now = time.Date(2024, 5, 2, 3, 59, 0, 0, time.UTC)
+	n, err := f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Empty(t, f.notifier.events)
+
+	// 07:05 по Москве: новых измерений нет, отложенная тревога все равно рассылается
+	f.now = time.Date(2024, 5, 2, 4, 5, 0, 0, time.UTC)
+	n, err = f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, []string{AlertEventRaised}, f.notifier.types())
+	alert := f.active(t)
+	assert.Equal(t, models.AlertStatusOpen, alert.Status)
+	assert.Equal(t, f.now.Add(DefaultAlertRecheck*time.Minute), alert.RecheckBy)
+
+	// Без повторного измерения тревога эскалируется как обычно
+	f.now = f.now.Add(DefaultAlertRecheck * time.Minute)
+	n, err = f.svc.Alerts.Escalate()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{AlertEventRaised, AlertEventEscalated}, f.notifier.types())
+}
+
+// staleAlerts отдает список тревог, прочитанный до того, как их забрала другая реплика
+type staleAlerts struct {
+	repository.AlertRepository
+	due []models.Alert
+}
+
+func (r staleAlerts) ListDue(time.Time) ([]models.Alert, error) {
+	return r.due, nil
+}
+
+func TestAlertService_EscalateOnceAcrossReplicas(t *testing.T) {
+	f := newAlertFixture(t)
+	rule, err := f.svc.Alerts.Rules(f.owner.ID)
+	require.NoError(t, err)
+	rule.QuietStart, rule.QuietEnd, rule.Timezone = "23:00", "07:00", "Europe/Moscow"
+	_, err = f.svc.Alerts.UpdateRules(f.owner.ID, *rule)
+	require.NoError(t, err)
+
+	// Обе реплики прочитали один и тот же список, но рассылает только первая
+	escalateBoth := func() (int, []string) {
+		due, err := f.svc.Alerts.alerts.ListDue(f.now)
+		require.NoError(t, err)
+		source := f.svc.Alerts
+		replica := NewAlertService(source.users, source.shares, staleAlerts{source.alerts, due})
+		replica.now = source.now
+		other := &recordingNotifier{}
+		replica.SetNotifier(other)
+
+		n, err := source.Escalate()
+		require.NoError(t, err)
+		m, err := replica.Escalate()
+		require.NoError(t, err)
+		return n + m, other.types()
+	}
+
+	f.now = time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	f.record(t, 23)
+	f.now = time.Date(2024, 5, 2, 4, 5, 0, 0, time.UTC)
+	_, replicaEvents := escalateBoth()
+	assert.Empty(t, replicaEvents)
+	assert.Equal(t, []string{AlertEventRaised}, f.notifier.types())
+
+	f.now = f.now.Add(DefaultAlertRecheck * time.Minute)
+	n, replicaEvents := escalateBoth()
+	assert.Equal(t, 1, n)
+	assert.Empty(t, replicaEvents)
+	assert.Equal(t, []string{AlertEventRaised, AlertEventEscalated}, f.notifier.types())
+}
+
+// unseenAlerts не видит незакрытую тревогу: реплика прочитала ее до того, как другая создала
+type unseenAlerts struct {
+	repository.AlertRepository
+}
+
+func (unseenAlerts) Active(uint) (*models.Alert, error) {
+	return nil, repository.ErrNotFound
+}
+
+func TestAlertService_RaiseOnceAcrossReplicas(t *testing.T) {
+	f := newAlertFixture(t)
+	f.record(t, 2.6)
+	require.Equal(t, []string{AlertEventRaised}, f.notifier.types())
+
+	// Параллельное измерение на другой реплике не поднимает вторую тревогу
+	source := f.svc.Alerts
+	replica := NewAlertService(source.users, source.shares, unseenAlerts{source.alerts})
+	replica.now = source.now
+	other := &recordingNotifier{}
+	replica.SetNotifier(other)
+
+	f.now = f.now.Add(time.Minute)
+	require.NoError(t, replica.check(f.owner.ID, &models.GlucoseRecord{Value: 2.5, MeasuredAt: f.now}))
+	assert.Empty(t, other.types())
+	alerts, err := source.alerts.List(f.owner.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1)
+}
+
+func TestAlertService_Skips(t *testing.T) {
+	f := newAlertFixture(t)
+
+	// Старые показания из загрузки истории тревог не вызывают
+	_, err := f.svc.Glucose.AddReadings(f.owner.ID, models.GlucoseSourceCGM, "", []GlucoseReading{
+		{Value: 2.5, MeasuredAt: f.now.Add(-2 * time.Hour)},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, f.notifier.events)
+
+	// Выключенные тревоги не поднимаются
+	rule, err := f.svc.Alerts.Rules(f.owner.ID)
+	require.NoError(t, err)
+	rule.Enabled = false
+	_, err = f.svc.Alerts.UpdateRules(f.owner.ID, *rule)
+	require.NoError(t, err)
+	f.record(t, 2.5)
+	assert.Empty(t, f.notifier.events)
+
+	// Без родственников тревоге некому уйти
+	loner, err := f.svc.Users.GetOrCreateUser(963, "", "Один", "", "ru")
+	require.NoError(t, err)
+	_, err = f.svc.Glucose.CreateRecordAt(loner.ID, 2.5, f.now, "", "")
+	require.NoError(t, err)
+	alerts, err := f.svc.Alerts.List(loner.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestAlertService_UpdateRulesValidation(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	rule, err := svc.Alerts.Rules(1)
+	require.NoError(t, err)
+	assert.True(t, rule.Enabled)
+	assert.Equal(t, DefaultAlertLow, rule.LowThreshold)
+
+	tests := []struct {
+		name   string
+		modify func(r *models.AlertRule)
+		field  string
+		rule   string
+	}{
+		{"Low", func(r *models.AlertRule) { r.LowThreshold = 5 }, "low_threshold", "between"},
+		{"High", func(r *models.AlertRule) { r.HighThreshold = 8 }, "high_threshold", "between"},
+		{"Recheck", func(r *models.AlertRule) { r.RecheckMinutes = 1 }, "recheck_minutes", "between"},
+		{"QuietFormat", func(r *models.AlertRule) { r.QuietStart, r.QuietEnd = "25:00", "07:00" }, "quiet_start", "time_of_day"},
+		{"QuietPair", func(r *models.AlertRule) { r.QuietStart = "23:00" }, "quiet_end", "required"},
+		{"Timezone", func(r *models.AlertRule) { r.Timezone = "Mars/Base" }, "timezone", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := *rule
+			tt.modify(&update)
+			_, err := svc.Alerts.UpdateRules(1, update)
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, tt.field, verr.Field)
+			assert.Equal(t, tt.rule, verr.Rule)
+		})
+	}
+}
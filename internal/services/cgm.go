@@ -0,0 +1,169 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// MaxBatchReadings — предел показаний в одной загрузке: сутки сенсора с опросом
+// раз в минуту с запасом на досылку пропущенного
+const MaxBatchReadings = 5000
+
+// MaxSeriesPoints — предел точек ряда: неделя пятиминутных интервалов
+const MaxSeriesPoints = 7 * 24 * 12
+
+// Допустимый диапазон показаний сенсора, ммоль/л; вне его — коды ошибок и калибровки
+const (
+	cgmMinValue = 1.0
+	cgmMaxValue = 40.0
+)
+
+// Шаги агрегации ряда глюкозы
+const (
+	SeriesBucket5m = "5m"
+	SeriesBucket1h = "1h"
+	SeriesBucket1d = "1d"
+)
+
+var seriesBuckets = map[string]time.Duration{
+	SeriesBucket5m: 5 * time.Minute,
+	SeriesBucket1h: time.Hour,
+	SeriesBucket1d: 24 * time.Hour,
+}
+
+type GlucoseBucket = repository.GlucoseBucket
+
+// GlucoseReading — одно показание загрузки, значение в ммоль/л
+type GlucoseReading struct {
+	Value      float64
+	MeasuredAt time.Time
+}
+
+// BatchResult — итог загрузки показаний
+type BatchResult struct {
+	Received   int `json:"received"`
+	Inserted   int `json:"inserted"`
+	Duplicates int `json:"duplicates"`
+	Skipped    int `json:"skipped"` // из будущего или вне диапазона сенсора
+}
+
+// SeriesOptions — параметры ряда: период [From, To), шаг Bucket и источник (пустой — все).
+// Интервалы выравниваются по часовому поясу Location на момент From, nil — time.Local.
+type SeriesOptions struct {
+	From     time.Time
+	To       time.Time
+	Bucket   string
+	Source   string
+	Location *time.Location
+}
+
+// GlucoseSeries — усредненный ряд глюкозы; пустые интервалы пропущены
+type GlucoseSeries struct {
+	Bucket string          `json:"bucket"`
+	Source string          `json:"source,omitempty"`
+	From   time.Time       `json:"from"`
+	To     time.Time       `json:"to"`
+	Points []GlucoseBucket `json:"points"`
+}
+
+// AddReadings сохраняет пачку показаний источника source. Показания из будущего
+// и вне диапазона сенсора пропускаются, повторы уже сохраненных не дублируются,
+// поэтому загрузку можно безопасно повторить после обрыва связи.
+func (s *GlucoseService) AddReadings(userID uint, source, deviceID string, readings []GlucoseReading) (*BatchResult, error) {
+	if !models.IsGlucoseSource(source) {
+		return nil, &ValidationError{Field: "source", Rule: "oneof", Param: models.GlucoseSourceFingerstick + " " + models.GlucoseSourceCGM}
+	}
+	if len(readings) > MaxBatchReadings {
+		return nil, &ValidationError{Field: "readings", Rule: "max", Param: strconv.Itoa(MaxBatchReadings)}
+	}
+
+	result := &BatchResult{Received: len(readings)}
+	limit := time.Now().Add(importFutureTolerance)
+	records := make([]models.GlucoseRecord, 0, len(readings))
+	for _, reading := range readings {
+		if reading.MeasuredAt.IsZero() || reading.MeasuredAt.After(limit) ||
+			reading.Value < cgmMinValue || reading.Value > cgmMaxValue {
+			result.Skipped++
+			continue
+		}
+		records = append(records, models.GlucoseRecord{
+			UserID:     userID,
+			Value:      reading.Value,
+			MeasuredAt: reading.MeasuredAt,
+			Source:     source,
+			DeviceID:   deviceID,
+		})
+	}
+
+	fresh, duplicates, err := freshGlucose(s.repo, userID, records)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateBatch(fresh); err != nil {
+		return nil, err
+	}
+	s.alerts.observe(userID, fresh)
+	result.Inserted, result.Duplicates = len(fresh), duplicates
+	return result, nil
+}
+
+// Series возвращает средние, минимум и максимум глюкозы по интервалам. Смещение
+// часового пояса берется на момент From, поэтому суточные интервалы сдвигаются
+// на час после перехода на летнее время внутри периода.
+func (s *GlucoseService) Series(userID uint, opts SeriesOptions) (*GlucoseSeries, error) {
+	size, ok := seriesBuckets[opts.Bucket]
+	if !ok {
+		return nil, &ValidationError{Field: "bucket", Rule: "oneof", Param: SeriesBucket5m + " " + SeriesBucket1h + " " + SeriesBucket1d}
+	}
+	if opts.Source != "" && !models.IsGlucoseSource(opts.Source) {
+		return nil, &ValidationError{Field: "source", Rule: "oneof", Param: models.GlucoseSourceFingerstick + " " + models.GlucoseSourceCGM}
+	}
+	if !opts.To.After(opts.From) {
+		return nil, &ValidationError{Field: "to", Rule: "after", Param: "from"}
+	}
+	if opts.To.Sub(opts.From)/size > MaxSeriesPoints {
+		return nil, &ValidationError{Field: "bucket", Rule: "max_points", Param: strconv.Itoa(MaxSeriesPoints)}
+	}
+
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	_, offset := opts.From.In(loc).Zone()
+	buckets, err := s.repo.Buckets(userID, repository.BucketQuery{
+		From:   opts.From,
+		To:     opts.To,
+		Size:   size,
+		Offset: time.Duration(offset) * time.Second,
+		Source: opts.Source,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate glucose: %w", err)
+	}
+
+	if buckets == nil {
+		buckets = []GlucoseBucket{}
+	}
+	for i := range buckets {
+		buckets[i].Start = buckets[i].Start.In(loc)
+		buckets[i].Average = math.Round(buckets[i].Average*10) / 10
+	}
+	return &GlucoseSeries{
+		Bucket: opts.Bucket,
+		Source: opts.Source,
+		From:   opts.From.In(loc),
+		To:     opts.To.In(loc),
+		Points: buckets,
+	}, nil
+}
+
+// PurgeCGM удаляет показания сенсора, измеренные раньше before. Измерения
+// глюкометром хранятся бессрочно: их мало, и по ним строятся отчеты для врача.
+func (s *GlucoseService) PurgeCGM(before time.Time) (int64, error) {
+	return s.repo.DeleteSourceBefore(models.GlucoseSourceCGM, before)
+}
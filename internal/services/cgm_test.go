@@ -0,0 +1,121 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlucoseService_AddReadings(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(940, "", "Ирина", "", "ru")
+	require.NoError(t, err)
+
+	base := time.Now().Add(-2 * time.Hour).Truncate(time.Minute)
+	readings := make([]GlucoseReading, 0, 26)
+	for i := 0; i < 24; i++ {
+		readings = append(readings, GlucoseReading{Value: 5 + float64(i)/10, MeasuredAt: base.Add(time.Duration(i) * 5 * time.Minute)})
+	}
+	readings = append(readings,
+		GlucoseReading{Value: 0.5, MeasuredAt: base.Add(time.Minute)},       // код ошибки сенсора
+		GlucoseReading{Value: 6, MeasuredAt: time.Now().Add(3 * time.Hour)}, // будущее
+	)
+
+	result, err := svc.Glucose.AddReadings(user.ID, models.GlucoseSourceCGM, "Libre 3", readings)
+	require.NoError(t, err)
+	assert.Equal(t, BatchResult{Received: 26, Inserted: 24, Skipped: 2}, *result)
+
+	records, err := svc.Glucose.ListRecords(user.ID, ListOptions{Limit: 1}, "")
+	require.NoError(t, err)
+	require.Len(t, records.Items, 1)
+	assert.Equal(t, models.GlucoseSourceCGM, records.Items[0].Source)
+	assert.Equal(t, "Libre 3", records.Items[0].DeviceID)
+
+	// Повтор загрузки после обрыва связи
+	result, err = svc.Glucose.AddReadings(user.ID, models.GlucoseSourceCGM, "Libre 3", readings[:10])
+	require.NoError(t, err)
+	assert.Equal(t, BatchResult{Received: 10, Duplicates: 10}, *result)
+
+	_, err = svc.Glucose.AddReadings(user.ID, "pump", "", readings)
+	assert.ErrorIs(t, err, ErrValidation)
+	_, err = svc.Glucose.AddReadings(user.ID, models.GlucoseSourceCGM, "", make([]GlucoseReading, MaxBatchReadings+1))
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestGlucoseService_Series(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	user, err := svc.Users.GetOrCreateUser(941, "", "Олег", "", "ru")
+	require.NoError(t, err)
+
+	vladivostok := time.FixedZone("VLAT", 10*3600)
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, vladivostok)
+	require.NoError(t, repos.Glucose.CreateBatch([]models.GlucoseRecord{
+		{UserID: user.ID, Value: 5.1, MeasuredAt: day.Add(time.Hour), Source: models.GlucoseSourceCGM},
+		{UserID: user.ID, Value: 5.2, MeasuredAt: day.Add(time.Hour + 5*time.Minute), Source: models.GlucoseSourceCGM},
+		{UserID: user.ID, Value: 5.3, MeasuredAt: day.Add(time.Hour + 10*time.Minute), Source: models.GlucoseSourceCGM},
+		{UserID: user.ID, Value: 8.0, MeasuredAt: day.Add(13 * time.Hour), Source: models.GlucoseSourceFingerstick},
+		{UserID: user.ID, Value: 9.0, MeasuredAt: day.Add(25 * time.Hour), Source: models.GlucoseSourceCGM},
+	}))
+
+	series, err := svc.Glucose.Series(user.ID, SeriesOptions{From: day, To: day.Add(24 * time.Hour), Bucket: SeriesBucket1h, Location: vladivostok})
+	require.NoError(t, err)
+	require.Len(t, series.Points, 2)
+	assert.True(t, day.Add(time.Hour).Equal(series.Points[0].Start))
+	assert.Equal(t, vladivostok, series.Points[0].Start.Location())
+	assert.Equal(t, int64(3), series.Points[0].Count)
+	assert.Equal(t, 5.2, series.Points[0].Average)
+	assert.Equal(t, 5.1, series.Points[0].Min)
+	assert.Equal(t, 5.3, series.Points[0].Max)
+
+	// Сутки считаются по времени пользователя, а не по UTC
+	series, err = svc.Glucose.Series(user.ID, SeriesOptions{From: day, To: day.Add(48 * time.Hour), Bucket: SeriesBucket1d, Source: models.GlucoseSourceCGM, Location: vladivostok})
+	require.NoError(t, err)
+	require.Len(t, series.Points, 2)
+	assert.True(t, day.Equal(series.Points[0].Start))
+	assert.Equal(t, int64(3), series.Points[0].Count)
+	assert.Equal(t, 9.0, series.Points[1].Average)
+
+	series, err = svc.Glucose.Series(user.ID, SeriesOptions{From: day.AddDate(0, 0, -7), To: day, Bucket: SeriesBucket5m})
+	require.NoError(t, err)
+	assert.NotNil(t, series.Points)
+	assert.Empty(t, series.Points)
+
+	for _, opts := range []SeriesOptions{
+		{From: day, To: day.Add(time.Hour), Bucket: "15m"},
+		{From: day, To: day.Add(time.Hour), Bucket: SeriesBucket1h, Source: "pump"},
+		{From: day, To: day, Bucket: SeriesBucket1h},
+		{From: day, To: day.AddDate(0, 0, 8), Bucket: SeriesBucket5m},
+	} {
+		_, err := svc.Glucose.Series(user.ID, opts)
+		assert.ErrorIs(t, err, ErrValidation, opts)
+	}
+}
+
+func TestGlucoseService_PurgeCGM(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	user, err := svc.Users.GetOrCreateUser(942, "", "Лена", "", "ru")
+	require.NoError(t, err)
+
+	old := time.Now().AddDate(0, 0, -120)
+	require.NoError(t, repos.Glucose.CreateBatch([]models.GlucoseRecord{
+		{UserID: user.ID, Value: 5.0, MeasuredAt: old, Source: models.GlucoseSourceCGM},
+		{UserID: user.ID, Value: 6.0, MeasuredAt: old, Source: models.GlucoseSourceFingerstick},
+		{UserID: user.ID, Value: 7.0, MeasuredAt: time.Now().Add(-time.Hour), Source: models.GlucoseSourceCGM},
+	}))
+
+	deleted, err := svc.Glucose.PurgeCGM(time.Now().AddDate(0, 0, -90))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	left, err := repos.Glucose.List(user.ID, repository.ListQuery{}, "")
+	require.NoError(t, err)
+	assert.Len(t, left, 2)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"math"
+	"time"
+)
+
+// recordKey — минута события и значение с точностью до 0.1. Записи с одинаковым ключом
+// считаются повтором: так совпадают показания, загруженные из разных источников.
+type recordKey struct {
+	minute int64
+	value  int64
+}
+
+func newRecordKey(at time.Time, value float64) recordKey {
+	return recordKey{minute: at.Truncate(time.Minute).Unix(), value: int64(math.Round(value * 10))}
+}
+
+// skipDuplicates возвращает записи, которых нет среди existing и которые не повторяются
+// внутри records, и число отброшенных повторов
+func skipDuplicates[T any](existing, records []T, key func(*T) recordKey) ([]T, int) {
+	seen := make(map[recordKey]bool, len(existing)+len(records))
+	for i := range existing {
+		seen[key(&existing[i])] = true
+	}
+
+	var fresh []T
+	duplicates := 0
+	for i := range records {
+		k := key(&records[i])
+		if seen[k] {
+			duplicates++
+			continue
+		}
+		seen[k] = true
+		fresh = append(fresh, records[i])
+	}
+	return fresh, duplicates
+}
+
+// minuteRange возвращает период [from, to), покрывающий минуты всех событий
+func minuteRange[T any](records []T, at func(*T) time.Time) (from, to time.Time) {
+	for i := range records {
+		t := at(&records[i])
+		if i == 0 || t.Before(from) {
+			from = t
+		}
+		if i == 0 || t.After(to) {
+			to = t
+		}
+	}
+	return from.Truncate(time.Minute), to.Truncate(time.Minute).Add(time.Minute)
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// Еженедельная сводка приходит в воскресенье, начиная с DigestHour по местному времени подписчика
+const (
+	DigestWeekday = time.Sunday
+	DigestHour    = 19
+)
+
+// digestRegenerateInterval — сводка моложе этого срока возвращается без повторного запроса к ИИ
+const digestRegenerateInterval = 10 * time.Minute
+
+// Заметная еда — подъем сахара от измерения до еды к пику в окне после нее
+const (
+	digestMealRise   = 3.0 // ммоль/л
+	digestMealBefore = time.Hour
+	digestMealAfter  = 3 * time.Hour
+	maxDigestMeals   = 3
+)
+
+// digestLowPenalty — вес выхода ниже диапазона при выборе лучшего и худшего дня:
+// низкий сахар опаснее высокого
+const digestLowPenalty = 2.0
+
+// digestSystemPrompt — инструкция ИИ для комментария к сводке, общая для всех провайдеров
+const digestSystemPrompt = `Ты медицинский консультант-диабетолог. Пациент прислал показатели своего дневника за неделю.
+Напиши короткий комментарий (до 120 слов): что получилось хорошо, на что обратить внимание на следующей неделе, один-два практических совета.
+Опирайся только на приведенные цифры. Не ставь диагнозы и не меняй лечение; при частых гипогликемиях или стойко высоком сахаре посоветуй обсудить это с врачом.
+Отвечай по-русски, дружелюбно и по делу.`
+
+// weekdayShort — короткие названия дней недели, индекс — time.Weekday
+var weekdayShort = [...]string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"}
+
+// DigestNotifier доставляет еженедельные сводки, например сообщением в Telegram
+type DigestNotifier interface {
+	NotifyDigest(user *models.User, digest *models.Digest)
+}
+
+// DigestService собирает еженедельную сводку: показатели глюкозы, лучший и худший
+// дни, заметную еду и комментарий ИИ. Комментарий запрашивается у ИИ напрямую, без
+// дневного лимита. Время берется из now, чтобы тесты могли подменить часы.
+type DigestService struct {
+	users    repository.UserRepository
+	digests  repository.DigestRepository
+	records  repository.GlucoseRepository
+	food     repository.FoodRepository
+	glucose  *GlucoseService
+	report   *ReportService
+	ai       AIService
+	notifier DigestNotifier
+	now      func() time.Time
+}
+
+func NewDigestService(users repository.UserRepository, digests repository.DigestRepository,
+	glucose repository.GlucoseRepository, food repository.FoodRepository) *DigestService {
+	return &DigestService{
+		users:   users,
+		digests: digests,
+		records: glucose,
+		food:    food,
+		glucose: NewGlucoseService(glucose),
+		report:  NewReportService(glucose, food),
+		now:     time.Now,
+	}
+}
+
+// SetAI задает ИИ для комментария; без него сводка собирается только из показателей
+func (s *DigestService) SetAI(ai AIService) {
+	s.ai = ai
+}
+
+// SetNotifier задает получателя сводок; без него SendDue ничего не отправляет
+func (s *DigestService) SetNotifier(notifier DigestNotifier) {
+	s.notifier = notifier
+}
+
+// Settings возвращает подписку пользователя; пока она не сохранялась, сводка выключена
+func (s *DigestService) Settings(userID uint) (*models.DigestSubscription, error) {
+	sub, err := s.digests.GetSubscription(userID)
+	if errors.Is(err, ErrNotFound) {
+		return &models.DigestSubscription{UserID: userID}, nil
+	}
+	return sub, err
+}
+
+// UpdateSettings включает или выключает сводку; пустой timezone — часовой пояс сервера
+func (s *DigestService) UpdateSettings(userID uint, enabled bool, timezone string) (*models.DigestSubscription, error) {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, &ValidationError{Field: "timezone", Rule: "invalid"}
+	}
+	sub, err := s.Settings(userID)
+	if err != nil {
+		return nil, err
+	}
+	sub.Enabled, sub.Timezone = enabled, timezone
+	if err := s.digests.SaveSubscription(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// List возвращает сохраненные сводки пользователя, сначала новые
+func (s *DigestService) List(userID uint, limit int) ([]models.Digest, error) {
+	digests, err := s.digests.List(userID, limit)
+	if digests == nil && err == nil {
+		digests = []models.Digest{}
+	}
+	return digests, err
+}
+
+// DeleteAll удаляет сохраненные сводки пользователя: они пересказывают его измерения
+func (s *DigestService) DeleteAll(userID uint) error {
+	return s.digests.DeleteAllByUser(userID)
+}
+
+// Generate собирает сводку за 7 дней, заканчивающихся сегодня по времени пользователя,
+// и сохраняет ее вместо прежней за ту же неделю
+func (s *DigestService) Generate(userID uint) (*models.Digest, error) {
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := s.Settings(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.generate(user, digestLocation(sub), s.now())
+}
+
+// MarkSent отмечает, что сводка отправлена пользователю. Уже отмеченная сводка
+// сохраняет время первой отправки.
+func (s *DigestService) MarkSent(digest *models.Digest) error {
+	now := s.now()
+	err := s.digests.MarkSent(digest.ID, now)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	digest.SentAt = &now
+	return nil
+}
+
+// SendDue отправляет сводки подписчикам, у которых наступил воскресный вечер, и
+// возвращает их число. Сводка за неделю отправляется один раз, в том числе если
+// пользователь уже получил ее командой в тот же день.
+func (s *DigestService) SendDue() (int, error) {
+	if s.notifier == nil {
+		return 0, nil
+	}
+	subs, err := s.digests.ListSubscribed()
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	sent := 0
+	for i := range subs {
+		loc := digestLocation(&subs[i])
+		local := now.In(loc)
+		if local.Weekday() != DigestWeekday || local.Hour() < DigestHour {
+			continue
+		}
+		from, _ := digestWeek(local)
+		existing, err := s.digests.GetByPeriod(subs[i].UserID, from.UTC())
+		switch {
+		case err == nil && existing.SentAt != nil:
+			continue
+		case err != nil && !errors.Is(err, ErrNotFound):
+			return sent, err
+		}
+
+		user, err := s.users.GetByID(subs[i].UserID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return sent, err
+		}
+		digest, err := s.generate(user, loc, now)
+		if err != nil {
+			// Ошибка одной сводки не должна задерживать остальные
+			log.Printf("Error generating digest for user %d: %v", user.ID, err)
+			continue
+		}
+		// Рассылку запускает каждая реплика: отправляет та, что первой отметила сводку
+		err = s.digests.MarkSent(digest.ID, now)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return sent, err
+		}
+		digest.SentAt = &now
+		s.notifier.NotifyDigest(user, digest)
+		sent++
+	}
+	return sent, nil
+}
+
+// digestLocation возвращает часовой пояс подписки, по умолчанию — сервера
+func digestLocation(sub *models.DigestSubscription) *time.Location {
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil || sub.Timezone == "" {
+		return time.Local
+	}
+	return loc
+}
+
+// digestWeek возвращает 7 дней, заканчивающихся днем at: в воскресенье это неделя с понедельника
+func digestWeek(at time.Time) (from, to time.Time) {
+	to = startOfDay(at).AddDate(0, 0, 1)
+	return to.AddDate(0, 0, -7), to
+}
+
+func (s *DigestService) generate(user *models.User, loc *time.Location, at time.Time) (*models.Digest, error) {
+	from, to := digestWeek(at.In(loc))
+	digest, err := s.digests.GetByPeriod(user.ID, from.UTC())
+	switch {
+	case err == nil && at.Sub(digest.GeneratedAt) < digestRegenerateInterval:
+		return digest, nil
+	case errors.Is(err, ErrNotFound):
+		digest = &models.Digest{UserID: user.ID, PeriodFrom: from.UTC(), PeriodTo: to.UTC()}
+	case err != nil:
+		return nil, err
+	}
+
+	summary, measured, err := s.summarize(user, from, to)
+	if err != nil {
+		return nil, err
+	}
+	digest.Summary, digest.Commentary, digest.GeneratedAt = summary, "", at
+	if measured && s.ai != nil {
+		digest.Commentary = strings.TrimSpace(s.ai.GetDigestCommentary(user, summary))
+	}
+	if err := s.digests.Save(digest); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// summarize описывает показатели недели [from, to) текстом для сообщения и запроса к ИИ.
+// measured сообщает, были ли за неделю измерения глюкозы.
+func (s *DigestService) summarize(user *models.User, from, to time.Time) (summary string, measured bool, err error) {
+	week, err := s.report.Build(user, ReportOptions{From: from, To: to})
+	if err != nil {
+		return "", false, err
+	}
+	period := fmt.Sprintf("Неделя %s–%s\n", from.Format("02.01"), to.AddDate(0, 0, -1).Format("02.01.2006"))
+	if week.Glucose.Count == 0 {
+		return period + "Измерений глюкозы за неделю нет.", false, nil
+	}
+	prev, err := s.report.Build(user, ReportOptions{From: from.AddDate(0, 0, -7), To: from})
+	if err != nil {
+		return "", false, err
+	}
+
+	var text strings.Builder
+	text.WriteString(period)
+	fmt.Fprintf(&text, "Измерений: %d (%.1f в день)\n", week.Glucose.Count, week.Glucose.PerDay)
+	fmt.Fprintf(&text, "Средний сахар: %.1f ммоль/л", week.Glucose.Average)
+	if prev.Glucose.Count > 0 {
+		fmt.Fprintf(&text, " (прошлая неделя %.1f, %+.1f)", prev.Glucose.Average, week.Glucose.Average-prev.Glucose.Average)
+	}
+	fmt.Fprintf(&text, "\nВ диапазоне %.1f–%.0f: %.0f%%", GlucoseLow, GlucoseHigh, week.Ranges.InRange*100)
+	if prev.Glucose.Count > 0 {
+		fmt.Fprintf(&text, " (прошлая неделя %.0f%%)", prev.Ranges.InRange*100)
+	}
+	fmt.Fprintf(&text, "\nНиже %.1f: %.0f%%, выше %.0f: %.0f%%\n", GlucoseLow, (week.Ranges.Low+week.Ranges.VeryLow)*100,
+		GlucoseHigh, (week.Ranges.High+week.Ranges.VeryHigh)*100)
+	if week.Glucose.Count > 1 {
+		fmt.Fprintf(&text, "Вариабельность (CV): %.0f%%\n", week.Glucose.CV)
+	}
+	fmt.Fprintf(&text, "Гипогликемии: %d\n", week.HypoCount)
+	if week.Carbs.Days > 0 {
+		fmt.Fprintf(&text, "Углеводы: в среднем %.0f г в день\n", week.Carbs.DailyAvg)
+	}
+
+	series, err := s.glucose.Series(user.ID, SeriesOptions{From: from, To: to, Bucket: SeriesBucket1d, Location: from.Location()})
+	if err != nil {
+		return "", false, err
+	}
+	if best, worst, ok := digestDays(series.Points); ok {
+		fmt.Fprintf(&text, "Лучший день: %s\n", digestDayText(best))
+		if dayExcursion(worst) > 0 {
+			fmt.Fprintf(&text, "Сложный день: %s\n", digestDayText(worst))
+		}
+	}
+
+	meals, err := s.notableMeals(user.ID, from, to)
+	if err != nil {
+		return "", false, err
+	}
+	if len(meals) > 0 {
+		text.WriteString("Еда с заметным подъемом сахара:\n")
+		for _, meal := range meals {
+			fmt.Fprintf(&text, "• %s (%s %s): %.1f → %.1f (+%.1f)\n", meal.Food.FoodName,
+				weekdayShort[meal.Food.ConsumedAt.In(from.Location()).Weekday()],
+				meal.Food.ConsumedAt.In(from.Location()).Format("02.01 15:04"), meal.Before, meal.Peak, meal.Peak-meal.Before)
+		}
+	}
+	return strings.TrimSuffix(text.String(), "\n"), true, nil
+}
+
+// dayExcursion — насколько сахар за день выходил из диапазона; низкий весит больше высокого
+func dayExcursion(day GlucoseBucket) float64 {
+	return math.Max(0, day.Max-GlucoseHigh) + digestLowPenalty*math.Max(0, GlucoseLow-day.Min)
+}
+
+// digestDays выбирает лучший и худший дни недели по выходу из диапазона, при равенстве —
+// по размаху за день. Нужно хотя бы два дня с измерениями.
+func digestDays(days []GlucoseBucket) (best, worst GlucoseBucket, ok bool) {
+	if len(days) < 2 {
+		return best, worst, false
+	}
+	ranked := append([]GlucoseBucket(nil), days...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		ei, ej := dayExcursion(ranked[i]), dayExcursion(ranked[j])
+		if ei != ej {
+			return ei < ej
+		}
+		return ranked[i].Max-ranked[i].Min < ranked[j].Max-ranked[j].Min
+	})
+	return ranked[0], ranked[len(ranked)-1], true
+}
+
+func digestDayText(day GlucoseBucket) string {
+	return fmt.Sprintf("%s %s — средний %.1f, от %.1f до %.1f", weekdayShort[day.Start.Weekday()],
+		day.Start.Format("02.01"), day.Average, day.Min, day.Max)
+}
+
+// mealRise — прием пищи и сахар до него и на пике после
+type mealRise struct {
+	Food   models.FoodRecord
+	Before float64
+	Peak   float64
+}
+
+// notableMeals находит еду, после которой сахар поднялся не меньше чем на digestMealRise:
+// от последнего измерения за час до еды к максимуму за три часа после. Самые большие подъемы — первыми.
+func (s *DigestService) notableMeals(userID uint, from, to time.Time) ([]mealRise, error) {
+	query := repository.ListQuery{From: from.Add(-digestMealBefore), To: to.Add(digestMealAfter), Ascending: true}
+	glucose, err := s.records.List(userID, query, "")
+	if err != nil {
+		return nil, err
+	}
+	food, err := s.food.List(userID, repository.ListQuery{From: from, To: to, Ascending: true}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var meals []mealRise
+	for _, meal := range food {
+		before, peak := math.NaN(), math.NaN()
+		for _, r := range glucose {
+			switch {
+			case r.MeasuredAt.Before(meal.ConsumedAt.Add(-digestMealBefore)):
+			case !r.MeasuredAt.After(meal.ConsumedAt):
+				before = r.Value
+			case !r.MeasuredAt.After(meal.ConsumedAt.Add(digestMealAfter)):
+				if math.IsNaN(peak) || r.Value > peak {
+					peak = r.Value
+				}
+			}
+		}
+		if !math.IsNaN(before) && !math.IsNaN(peak) && peak-before >= digestMealRise {
+			meals = append(meals, mealRise{Food: meal, Before: before, Peak: peak})
+		}
+	}
+	sort.SliceStable(meals, func(i, j int) bool { return meals[i].Peak-meals[i].Before > meals[j].Peak-meals[j].Before })
+	if len(meals) > maxDigestMeals {
+		meals = meals[:maxDigestMeals]
+	}
+	return meals, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAI отвечает заготовками и считает запросы комментария к сводке
+type stubAI struct {
+	digestCalls int
+}
+
+func (a *stubAI) GetGlucoseRecommendation(*models.User, *models.GlucoseRecord) string { return "" }
+func (a *stubAI) GetFoodRecommendation(*models.User, string) string                   { return "" }
+func (a *stubAI) GetGeneralRecommendation(*models.User, string) string                { return "" }
+
+func (a *stubAI) GetDigestCommentary(*models.User, string) string {
+	a.digestCalls++
+	return "Хорошая неделя."
+}
+
+type recordingDigestNotifier struct {
+	digests []models.Digest
+}
+
+func (n *recordingDigestNotifier) NotifyDigest(_ *models.User, digest *models.Digest) {
+	n.digests = append(n.digests, *digest)
+}
+
+func TestDigestService_SendDue(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	ai, notifier := &stubAI{}, &recordingDigestNotifier{}
+	svc.Digests.SetAI(ai)
+	svc.Digests.SetNotifier(notifier)
+	var now time.Time
+	svc.Digests.now = func() time.Time { return now }
+
+	user, err := svc.Users.GetOrCreateUser(970, "", "Анна", "", "ru")
+	require.NoError(t, err)
+	_, err = svc.Users.GetOrCreateUser(971, "", "Иван", "", "ru")
+	require.NoError(t, err)
+	_, err = svc.Digests.UpdateSettings(user.ID, true, "UTC")
+	require.NoError(t, err)
+
+	// Неделя с понедельника 6 мая по воскресенье 12 мая 2024 и одно измерение неделей раньше
+	monday := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	readings := []struct {
+		day, hour int
+		value     float64
+	}{
+		{-5, 8, 9.0},
+		{0, 8, 5.8}, {0, 20, 6.4},
+		{1, 8, 3.2}, {1, 20, 14.0},
+		{2, 12, 6.0}, {2, 14, 12.5},
+		{6, 8, 6.1},
+	}
+	for _, r := range readings {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, r.value, monday.AddDate(0, 0, r.day).Add(time.Duration(r.hour)*time.Hour+30*time.Minute), "", "")
+		require.NoError(t, err)
+	}
+	carbs := 80.0
+	_, err = svc.Food.CreateRecordAt(user.ID, "Плов", "обед", &carbs, nil, "", "", monday.AddDate(0, 0, 2).Add(13*time.Hour))
+	require.NoError(t, err)
+
+	// Суббота и воскресенье до 19:00 — рано
+	for _, at := range []time.Time{monday.AddDate(0, 0, 5).Add(20 * time.Hour), monday.AddDate(0, 0, 6).Add(18 * time.Hour)} {
+		now = at
+		sent, err := svc.Digests.SendDue()
+		require.NoError(t, err)
+		assert.Zero(t, sent)
+	}
+
+	now = monday.AddDate(0, 0, 6).Add(19*time.Hour + 30*time.Minute)
+	sent, err := svc.Digests.SendDue()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	require.Len(t, notifier.digests, 1)
+	digest := notifier.digests[0]
+	assert.True(t, monday.Equal(digest.PeriodFrom))
+	assert.Contains(t, digest.Summary, "Неделя 06.05–12.05.2024")
+	assert.Contains(t, digest.Summary, "Измерений: 7")
+	assert.Contains(t, digest.Summary, "прошлая неделя 9.0")
+	assert.Contains(t, digest.Summary, "Сложный день: вт 07.05")
+	assert.Contains(t, digest.Summary, "Плов (ср 08.05 13:00): 6.0 → 12.5 (+6.5)")
+	assert.Equal(t, "Хорошая неделя.", digest.Commentary)
+	assert.Equal(t, 1, ai.digestCalls)
+
+	// Сводка за неделю отправляется один раз
+	now = now.Add(15 * time.Minute)
+	sent, err = svc.Digests.SendDue()
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	// Повторная сводка перезаписывает прежнюю, но не чаще раза в 10 минут
+	regenerated, err := svc.Digests.Generate(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, digest.ID, regenerated.ID)
+	assert.NotNil(t, regenerated.SentAt)
+	assert.Equal(t, 2, ai.digestCalls)
+	_, err = svc.Digests.Generate(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, ai.digestCalls)
+
+	digests, err := svc.Digests.List(user.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, digests, 1)
+}
+
+// staleDigests отдает сводку такой, какой ее прочитали до отметки об отправке другой репликой
+type staleDigests struct {
+	repository.DigestRepository
+	digest models.Digest
+}
+
+func (r staleDigests) GetByPeriod(uint, time.Time) (*models.Digest, error) {
+	digest := r.digest
+	return &digest, nil
+}
+
+func TestDigestService_SendDueOnceAcrossReplicas(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	now := time.Date(2024, 5, 12, 19, 30, 0, 0, time.UTC)
+	svc.Digests.now = func() time.Time { return now }
+	user, err := svc.Users.GetOrCreateUser(973, "", "Анна", "", "ru")
+	require.NoError(t, err)
+	_, err = svc.Digests.UpdateSettings(user.ID, true, "UTC")
+	require.NoError(t, err)
+
+	// Вторая реплика успела прочитать сводку до того, как первая ее отметила
+	digest, err := svc.Digests.Generate(user.ID)
+	require.NoError(t, err)
+	replica := NewDigestService(repos.Users, staleDigests{repos.Digests, *digest}, repos.Glucose, repos.Food)
+	replica.now = svc.Digests.now
+
+	notifier, other := &recordingDigestNotifier{}, &recordingDigestNotifier{}
+	svc.Digests.SetNotifier(notifier)
+	replica.SetNotifier(other)
+	sent, err := svc.Digests.SendDue()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	sent, err = replica.SendDue()
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	assert.Len(t, notifier.digests, 1)
+	assert.Empty(t, other.digests)
+
+	// Пересборка сводки не снимает отметку об отправке
+	saved, err := repos.Digests.GetByPeriod(user.ID, digest.PeriodFrom)
+	require.NoError(t, err)
+	require.NotNil(t, saved.SentAt)
+	assert.True(t, now.Equal(*saved.SentAt))
+}
+
+func TestDigestService_EmptyWeekAndSettings(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	ai := &stubAI{}
+	svc.Digests.SetAI(ai)
+	user, err := svc.Users.GetOrCreateUser(972, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	sub, err := svc.Digests.Settings(user.ID)
+	require.NoError(t, err)
+	assert.False(t, sub.Enabled)
+	_, err = svc.Digests.UpdateSettings(user.ID, true, "Mars/Olympus")
+	assert.ErrorIs(t, err, ErrValidation)
+
+	digest, err := svc.Digests.Generate(user.ID)
+	require.NoError(t, err)
+	assert.Contains(t, digest.Summary, "Измерений глюкозы за неделю нет.")
+	assert.Empty(t, digest.Commentary)
+	assert.Zero(t, ai.digestCalls)
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"errors"
+
+	"diabetbot/internal/repository"
+)
+
+// Ошибки сервисов, по которым API выбирает HTTP статус
+var (
+	ErrNotFound   = repository.ErrNotFound
+	ErrForbidden  = errors.New("forbidden")
+	ErrValidation = errors.New("validation failed")
+)
+
+// ValidationError — некорректное значение поля. Rule — код нарушенного правила
+// (invalid, min, max, range), Param — граница правила, если она есть.
+// errors.Is(err, ErrValidation) для нее истинно.
+type ValidationError struct {
+	Field string
+	Rule  string
+	Param string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Param != "" {
+		return e.Field + ": " + e.Rule + " " + e.Param
+	}
+	return e.Field + ": " + e.Rule
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// ExportFormat — формат выгрузки дневника
+type ExportFormat string
+
+const (
+	ExportCSV  ExportFormat = "csv"
+	ExportJSON ExportFormat = "json"
+	ExportFHIR ExportFormat = "fhir" // Bundle ресурсов FHIR R4 для медицинских систем
+)
+
+// exportBatchSize — сколько записей каждого типа читается из хранилища за раз
+const exportBatchSize = 500
+
+// Типы записей в выгрузке
+const (
+	EntryGlucose = "glucose"
+	EntryFood    = "food"
+	EntryInsulin = "insulin"
+	EntryVital   = "vital"
+)
+
+// ContentType возвращает MIME-тип файла выгрузки
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportJSON:
+		return "application/json; charset=utf-8"
+	case ExportFHIR:
+		return "application/fhir+json; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Extension возвращает расширение файла выгрузки
+func (f ExportFormat) Extension() string {
+	if f == ExportFHIR {
+		return "fhir.json"
+	}
+	return string(f)
+}
+
+// ExportFileName возвращает имя файла выгрузки, например diabetbot-2024-05-01.csv
+func ExportFileName(format ExportFormat, now time.Time) string {
+	return fmt.Sprintf("diabetbot-%s.%s", now.Format("2006-01-02"), format.Extension())
+}
+
+// ExportOptions — параметры выгрузки. Нулевые From и To не ограничивают период, To не включается.
+type ExportOptions struct {
+	Format ExportFormat
+	From   time.Time
+	To     time.Time
+}
+
+// ExportEntry — запись дневника в выгрузке. Заполнены только поля ее типа.
+type ExportEntry struct {
+	Type               string    `json:"type"`
+	ID                 uint      `json:"id"`
+	At                 time.Time `json:"at"`
+	Glucose            *float64  `json:"glucose,omitempty"` // ммоль/л
+	MeasurementContext string    `json:"measurement_context,omitempty"`
+	FoodName           string    `json:"food_name,omitempty"`
+	FoodType           string    `json:"food_type,omitempty"`
+	Quantity           string    `json:"quantity,omitempty"`
+	Carbs              *float64  `json:"carbs,omitempty"` // граммы
+	Calories           *int      `json:"calories,omitempty"`
+	InsulinUnits       *float64  `json:"insulin_units,omitempty"`
+	InsulinType        string    `json:"insulin_type,omitempty"`
+	InsulinName        string    `json:"insulin_name,omitempty"`
+	VitalKind          string    `json:"vital_kind,omitempty"` // models.VitalKind*
+	VitalValue         *float64  `json:"vital_value,omitempty"`
+	VitalDiastolic     *float64  `json:"vital_diastolic,omitempty"`
+	VitalUnit          string    `json:"vital_unit,omitempty"`
+	Notes              string    `json:"notes,omitempty"`
+}
+
+// ExportService выгружает дневник пользователя: глюкозу, питание, инсулин, вес, давление и кетоны
+type ExportService struct {
+	users   repository.UserRepository
+	glucose repository.GlucoseRepository
+	food    repository.FoodRepository
+	insulin repository.InsulinRepository
+	vitals  repository.VitalRepository
+}
+
+func NewExportService(users repository.UserRepository, glucose repository.GlucoseRepository, food repository.FoodRepository, insulin repository.InsulinRepository, vitals repository.VitalRepository) *ExportService {
+	return &ExportService{users: users, glucose: glucose, food: food, insulin: insulin, vitals: vitals}
+}
+
+// Validate проверяет параметры выгрузки до того, как начнется запись ответа
+func (o ExportOptions) Validate() error {
+	if o.Format != ExportCSV && o.Format != ExportJSON && o.Format != ExportFHIR {
+		return &ValidationError{Field: "format", Rule: "oneof", Param: "csv json fhir"}
+	}
+	if !o.From.IsZero() && !o.To.IsZero() && !o.From.Before(o.To) {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// Export пишет записи пользователя в w в хронологическом порядке.
+// Записи читаются из хранилища порциями по exportBatchSize и сразу пишутся,
+// поэтому память не зависит от размера дневника.
+func (s *ExportService) Export(w io.Writer, userID uint, opts ExportOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if opts.Format == ExportFHIR {
+		return s.exportFHIR(w, userID, opts)
+	}
+
+	query := repository.ListQuery{From: opts.From, To: opts.To, Ascending: true, Limit: exportBatchSize}
+	sources := []*entrySource{
+		newEntrySource(query, func(q repository.ListQuery) ([]ExportEntry, error) {
+			records, err := s.glucose.List(userID, q, "")
+			return convertEntries(records, glucoseEntry), err
+		}),
+		newEntrySource(query, func(q repository.ListQuery) ([]ExportEntry, error) {
+			records, err := s.food.List(userID, q, "")
+			return convertEntries(records, foodEntry), err
+		}),
+		newEntrySource(query, func(q repository.ListQuery) ([]ExportEntry, error) {
+			records, err := s.insulin.List(userID, q)
+			return convertEntries(records, insulinEntry), err
+		}),
+		newEntrySource(query, func(q repository.ListQuery) ([]ExportEntry, error) {
+			records, err := s.vitals.List(userID, q, "")
+			return convertEntries(records, vitalEntry), err
+		}),
+	}
+	// Первые порции загружаются до записи файла: если хранилище недоступно,
+	// клиент получит ошибку, а не оборванный файл
+	for _, source := range sources {
+		if _, err := source.peek(); err != nil {
+			return err
+		}
+	}
+	entries := mergeEntries(sources...)
+
+	if opts.Format == ExportJSON {
+		return writeJSONExport(w, opts, entries)
+	}
+	return writeCSVExport(w, entries)
+}
+
+func convertEntries[T any](records []T, convert func(*T) ExportEntry) []ExportEntry {
+	entries := make([]ExportEntry, len(records))
+	for i := range records {
+		entries[i] = convert(&records[i])
+	}
+	return entries
+}
+
+func glucoseEntry(r *models.GlucoseRecord) ExportEntry {
+	value := r.Value
+	return ExportEntry{
+		Type: EntryGlucose, ID: r.ID, At: r.MeasuredAt,
+		Glucose: &value, MeasurementContext: r.MeasurementContext, Notes: r.Notes,
+	}
+}
+
+func foodEntry(r *models.FoodRecord) ExportEntry {
+	return ExportEntry{
+		Type: EntryFood, ID: r.ID, At: r.ConsumedAt,
+		FoodName: r.FoodName, FoodType: r.FoodType, Quantity: r.Quantity,
+		Carbs: r.Carbs, Calories: r.Calories, Notes: r.Notes,
+	}
+}
+
+func insulinEntry(r *models.InsulinRecord) ExportEntry {
+	units := r.Units
+	return ExportEntry{
+		Type: EntryInsulin, ID: r.ID, At: r.InjectedAt,
+		InsulinUnits: &units, InsulinType: r.InsulinType, InsulinName: r.InsulinName, Notes: r.Notes,
+	}
+}
+
+func vitalEntry(r *models.Vital) ExportEntry {
+	value := r.Value
+	return ExportEntry{
+		Type: EntryVital, ID: r.ID, At: r.MeasuredAt,
+		VitalKind: r.Kind, VitalValue: &value, VitalDiastolic: r.Diastolic, VitalUnit: r.Unit, Notes: r.Notes,
+	}
+}
+
+// entrySource листает записи одного типа курсором (время, id)
+type entrySource struct {
+	fetch func(repository.ListQuery) ([]ExportEntry, error)
+	query repository.ListQuery
+	batch []ExportEntry
+	done  bool
+}
+
+func newEntrySource(query repository.ListQuery, fetch func(repository.ListQuery) ([]ExportEntry, error)) *entrySource {
+	return &entrySource{fetch: fetch, query: query}
+}
+
+// peek возвращает следующую запись, при необходимости загружая порцию; nil — записи кончились
+func (s *entrySource) peek() (*ExportEntry, error) {
+	if len(s.batch) == 0 && !s.done {
+		batch, err := s.fetch(s.query)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) < s.query.Limit {
+			s.done = true
+		}
+		if len(batch) > 0 {
+			last := batch[len(batch)-1]
+			s.query.After = &repository.Cursor{At: last.At, ID: last.ID}
+		}
+		s.batch = batch
+	}
+	if len(s.batch) == 0 {
+		return nil, nil
+	}
+	return &s.batch[0], nil
+}
+
+// mergeEntries объединяет отсортированные по времени источники.
+// Возвращаемая функция отдает записи по одной; nil без ошибки — конец выгрузки.
+func mergeEntries(sources ...*entrySource) func() (*ExportEntry, error) {
+	return func() (*ExportEntry, error) {
+		var next *entrySource
+		var earliest *ExportEntry
+		for _, source := range sources {
+			entry, err := source.peek()
+			if err != nil {
+				return nil, err
+			}
+			if entry != nil && (earliest == nil || entry.At.Before(earliest.At)) {
+				next, earliest = source, entry
+			}
+		}
+		if next == nil {
+			return nil, nil
+		}
+		entry := *earliest
+		next.batch = next.batch[1:]
+		return &entry, nil
+	}
+}
+
+var csvHeader = []string{
+	"type", "id", "at", "glucose_mmol_l", "measurement_context",
+	"food_name", "food_type", "quantity", "carbs_g", "calories",
+	"insulin_units", "insulin_type", "insulin_name", "notes",
+	// Столбцы показателей добавлены в конец, чтобы не сдвигать прежние
+	"vital_kind", "vital_value", "vital_diastolic", "vital_unit",
+}
+
+func writeCSVExport(w io.Writer, next func() (*ExportEntry, error)) error {
+	// BOM нужен, чтобы Excel открыл кириллицу в UTF-8
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for {
+		entry, err := next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			break
+		}
+		row := []string{
+			entry.Type,
+			strconv.FormatUint(uint64(entry.ID), 10),
+			entry.At.Format(time.RFC3339),
+			formatFloat(entry.Glucose),
+			entry.MeasurementContext,
+			entry.FoodName,
+			entry.FoodType,
+			entry.Quantity,
+			formatFloat(entry.Carbs),
+			formatInt(entry.Calories),
+			formatFloat(entry.InsulinUnits),
+			entry.InsulinType,
+			entry.InsulinName,
+			entry.Notes,
+			entry.VitalKind,
+			formatFloat(entry.VitalValue),
+			formatFloat(entry.VitalDiastolic),
+			entry.VitalUnit,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+// writeJSONExport пишет {"exported_at", "from", "to", "entries": [...]}, по одной записи за раз
+func writeJSONExport(w io.Writer, opts ExportOptions, next func() (*ExportEntry, error)) error {
+	bw := bufio.NewWriter(w)
+
+	header := struct {
+		ExportedAt time.Time  `json:"exported_at"`
+		From       *time.Time `json:"from,omitempty"`
+		To         *time.Time `json:"to,omitempty"`
+	}{ExportedAt: time.Now().UTC()}
+	if !opts.From.IsZero() {
+		header.From = &opts.From
+	}
+	if !opts.To.IsZero() {
+		header.To = &opts.To
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// Дописываем массив entries в объект заголовка
+	bw.Write(data[:len(data)-1])
+	bw.WriteString(`,"entries":[`)
+
+	for i := 0; ; i++ {
+		entry, err := next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			break
+		}
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	bw.WriteString("]}\n")
+	return bw.Flush()
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportService_CSV(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(900, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	carbs, calories := 45.0, 320
+	_, err = svc.Food.CreateRecordAt(user.ID, "Каша, с ягодами", "завтрак", &carbs, &calories, "200 г", "", base.Add(30*time.Minute))
+	require.NoError(t, err)
+	_, err = svc.Insulin.CreateRecord(user.ID, 4, models.InsulinTypeBolus, "Новорапид", base.Add(20*time.Minute), "")
+	require.NoError(t, err)
+	_, err = svc.Glucose.CreateRecordAt(user.ID, 5.6, base, "", "натощак")
+	require.NoError(t, err)
+	_, err = svc.Glucose.CreateRecordAt(user.ID, 9.1, base.AddDate(0, 0, 2), "", "")
+	require.NoError(t, err)
+	diastolic := 85.0
+	_, err = svc.Vitals.Create(user.ID, VitalInput{Kind: models.VitalKindBloodPressure, Value: 135, Diastolic: &diastolic, MeasuredAt: base.Add(time.Hour)})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, svc.Export.Export(&buf, user.ID, ExportOptions{Format: ExportCSV, To: base.AddDate(0, 0, 1)}))
+
+	require.True(t, strings.HasPrefix(buf.String(), "\ufeff"))
+	rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\ufeff"))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	assert.Equal(t, csvHeader, rows[0])
+
+	assert.Equal(t, []string{"glucose", "insulin", "food", "vital"}, []string{rows[1][0], rows[2][0], rows[3][0], rows[4][0]})
+	assert.Equal(t, "2024-05-01T08:00:00Z", rows[1][2])
+	assert.Equal(t, "5.6", rows[1][3])
+	assert.Equal(t, "натощак", rows[1][13])
+	assert.Equal(t, "4", rows[2][10])
+	assert.Equal(t, "Новорапид", rows[2][12])
+	assert.Equal(t, "Каша, с ягодами", rows[3][5])
+	assert.Equal(t, "45", rows[3][8])
+	assert.Equal(t, "320", rows[3][9])
+	assert.Equal(t, []string{"blood_pressure", "135", "85", "mmHg"}, rows[4][14:])
+}
+
+func TestExportService_JSONInBatches(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(901, "", "Иван", "", "ru")
+	require.NoError(t, err)
+	other, err := svc.Users.GetOrCreateUser(902, "", "Петр", "", "ru")
+	require.NoError(t, err)
+
+	// Больше двух порций, часть записей с одинаковым временем
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	total := 2*exportBatchSize + 7
+	for i := 0; i < total; i++ {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, 5+float64(i%10)/10, base.Add(time.Duration(i/2)*time.Minute), "", "")
+		require.NoError(t, err)
+	}
+	_, err = svc.Glucose.CreateRecordAt(other.ID, 7, base, "", "")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, svc.Export.Export(&buf, user.ID, ExportOptions{Format: ExportJSON, From: base}))
+
+	var export struct {
+		ExportedAt time.Time     `json:"exported_at"`
+		From       *time.Time    `json:"from"`
+		Entries    []ExportEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+	require.NotNil(t, export.From)
+	require.Len(t, export.Entries, total)
+
+	seen := make(map[uint]bool)
+	for i, entry := range export.Entries {
+		assert.Equal(t, EntryGlucose, entry.Type)
+		assert.False(t, seen[entry.ID], "запись %d выгружена дважды", entry.ID)
+		seen[entry.ID] = true
+		if i > 0 {
+			assert.False(t, entry.At.Before(export.Entries[i-1].At))
+		}
+	}
+}
+
+func TestExportService_Validation(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	var buf bytes.Buffer
+
+	err := svc.Export.Export(&buf, 1, ExportOptions{Format: "xml"})
+	assert.ErrorIs(t, err, ErrValidation)
+
+	now := time.Now()
+	err = svc.Export.Export(&buf, 1, ExportOptions{Format: ExportCSV, From: now, To: now.Add(-time.Hour)})
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	assert.Zero(t, buf.Len())
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"diabetbot/internal/fhir"
+	"diabetbot/internal/importer"
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// ImportFormatFHIR — формат импорта Bundle FHIR в models.ImportJob
+const ImportFormatFHIR = "fhir"
+
+// exportFHIR пишет Bundle: Patient, затем Observation глюкозы и углеводов.
+// Инсулин, вес, давление и кетоны в выгрузку FHIR не входят.
+func (s *ExportService) exportFHIR(w io.Writer, userID uint, opts ExportOptions) error {
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := fhir.NewBundleWriter(w, time.Now())
+	if err != nil {
+		return err
+	}
+	patient := fhir.NewPatient(user)
+	subject := fhir.URN(patient.ID)
+	if err := bundle.Add(patient.ID, patient); err != nil {
+		return err
+	}
+
+	query := repository.ListQuery{From: opts.From, To: opts.To, Ascending: true, Limit: exportBatchSize}
+	err = eachRecord(query,
+		func(q repository.ListQuery) ([]models.GlucoseRecord, error) { return s.glucose.List(userID, q, "") },
+		func(r *models.GlucoseRecord) repository.Cursor { return repository.Cursor{At: r.MeasuredAt, ID: r.ID} },
+		func(r *models.GlucoseRecord) error {
+			obs := fhir.GlucoseObservation(r, subject)
+			return bundle.Add(obs.ID, obs)
+		})
+	if err != nil {
+		return err
+	}
+	err = eachRecord(query,
+		func(q repository.ListQuery) ([]models.FoodRecord, error) { return s.food.List(userID, q, "") },
+		func(r *models.FoodRecord) repository.Cursor { return repository.Cursor{At: r.ConsumedAt, ID: r.ID} },
+		func(r *models.FoodRecord) error {
+			obs := fhir.CarbsObservation(r, subject)
+			return bundle.Add(obs.ID, obs)
+		})
+	if err != nil {
+		return err
+	}
+	return bundle.Close()
+}
+
+// eachRecord листает записи курсором порциями по query.Limit и передает их fn по одной
+func eachRecord[T any](query repository.ListQuery, list func(repository.ListQuery) ([]T, error), cursor func(*T) repository.Cursor, fn func(*T) error) error {
+	for {
+		records, err := list(query)
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			if err := fn(&records[i]); err != nil {
+				return err
+			}
+		}
+		if len(records) < query.Limit {
+			return nil
+		}
+		last := cursor(&records[len(records)-1])
+		query.After = &last
+	}
+}
+
+// ImportFHIR сохраняет измерения глюкозы и углеводы из Bundle FHIR. Записи из будущего
+// и уже сохраненные пропускаются, как при импорте CSV; пациент в Bundle не сверяется.
+func (s *ImportService) ImportFHIR(userID uint, r io.Reader, opts ImportOptions) (*models.ImportJob, error) {
+	records, err := fhir.Decode(r)
+	if err != nil {
+		if errors.Is(err, fhir.ErrNotBundle) {
+			return nil, &ValidationError{Field: "file", Rule: "fhir_bundle"}
+		}
+		return nil, err
+	}
+
+	job := &models.ImportJob{
+		UserID:   userID,
+		Source:   opts.Source,
+		Format:   ImportFormatFHIR,
+		FileName: opts.FileName,
+		Total:    records.Total,
+		Skipped:  records.Skipped,
+	}
+
+	limit := time.Now().Add(importFutureTolerance)
+	var first, last time.Time
+	keep := func(at time.Time) bool {
+		if at.After(limit) {
+			job.Total--
+			job.Skipped++
+			return false
+		}
+		if first.IsZero() || at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+		return true
+	}
+	glucose := make([]models.GlucoseRecord, 0, len(records.Glucose))
+	for _, record := range records.Glucose {
+		if keep(record.MeasuredAt) {
+			record.UserID = userID
+			glucose = append(glucose, record)
+		}
+	}
+	food := make([]models.FoodRecord, 0, len(records.Food))
+	for _, record := range records.Food {
+		if keep(record.ConsumedAt) {
+			record.UserID = userID
+			food = append(food, record)
+		}
+	}
+	if len(glucose)+len(food) == 0 {
+		return nil, importError(importer.ErrNoReadings)
+	}
+	job.FirstAt, job.LastAt = &first, &last
+
+	freshGlucoseRecords, duplicates, err := freshGlucose(s.glucose, userID, glucose)
+	if err != nil {
+		return nil, err
+	}
+	freshFood, err := freshRecords(food, func(from, to time.Time) ([]models.FoodRecord, error) {
+		return s.food.List(userID, repository.ListQuery{From: from, To: to, Ascending: true}, "")
+	}, func(r *models.FoodRecord) time.Time { return r.ConsumedAt }, foodCarbs)
+	if err != nil {
+		return nil, err
+	}
+	job.Duplicates = duplicates + len(food) - len(freshFood)
+
+	if err := s.glucose.CreateBatch(freshGlucoseRecords); err != nil {
+		return nil, err
+	}
+	if err := s.food.CreateBatch(freshFood); err != nil {
+		return nil, err
+	}
+	completeJob(job, len(freshGlucoseRecords)+len(freshFood))
+	if err := s.jobs.Create(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// foodCarbs — углеводы записи для сравнения дубликатов; без углеводов 0
+func foodCarbs(r *models.FoodRecord) float64 {
+	if r.Carbs == nil {
+		return 0
+	}
+	return *r.Carbs
+}
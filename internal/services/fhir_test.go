@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportService_FHIR(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(930, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	// Больше порции чтения, чтобы выгрузка прошла курсором
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	total := exportBatchSize + 3
+	for i := 0; i < total; i++ {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, 5+float64(i%10)/10, base.Add(time.Duration(i)*time.Minute), "", "")
+		require.NoError(t, err)
+	}
+	carbs := 45.0
+	_, err = svc.Food.CreateRecordAt(user.ID, "Гречка", "обед", &carbs, nil, "", "", base.Add(4*time.Hour))
+	require.NoError(t, err)
+	_, err = svc.Insulin.CreateRecord(user.ID, 4, models.InsulinTypeBolus, "", base, "")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, svc.Export.Export(&buf, user.ID, ExportOptions{Format: ExportFHIR}))
+
+	var bundle struct {
+		ResourceType string `json:"resourceType"`
+		Entry        []struct {
+			Resource struct {
+				ResourceType string `json:"resourceType"`
+				ID           string `json:"id"`
+			} `json:"resource"`
+		} `json:"entry"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &bundle))
+	assert.Equal(t, "Bundle", bundle.ResourceType)
+	// Patient, глюкоза и углеводы; инсулин не выгружается
+	require.Len(t, bundle.Entry, total+2)
+	assert.Equal(t, fmt.Sprintf("patient-%d", user.ID), bundle.Entry[0].Resource.ID)
+	seen := make(map[string]bool)
+	for _, entry := range bundle.Entry[1:] {
+		assert.Equal(t, "Observation", entry.Resource.ResourceType)
+		assert.False(t, seen[entry.Resource.ID], "%s выгружен дважды", entry.Resource.ID)
+		seen[entry.Resource.ID] = true
+	}
+	assert.True(t, seen["food-1"])
+
+	assert.Equal(t, "application/fhir+json; charset=utf-8", ExportFHIR.ContentType())
+	assert.True(t, strings.HasSuffix(ExportFileName(ExportFHIR, time.Now()), ".fhir.json"))
+}
+
+func TestImportService_FHIR(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	source, err := svc.Users.GetOrCreateUser(931, "", "Анна", "", "ru")
+	require.NoError(t, err)
+	target, err := svc.Users.GetOrCreateUser(932, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	_, err = svc.Glucose.CreateRecordAt(source.ID, 6.2, base, "", "натощак")
+	require.NoError(t, err)
+	_, err = svc.Glucose.CreateRecordAt(source.ID, 9.4, base.Add(2*time.Hour), "", "")
+	require.NoError(t, err)
+	carbs := 60.0
+	_, err = svc.Food.CreateRecordAt(source.ID, "Паста", "обед", &carbs, nil, "", "", base.Add(5*time.Hour))
+	require.NoError(t, err)
+	// Уже есть у получателя
+	_, err = svc.Glucose.CreateRecordAt(target.ID, 9.4, base.Add(2*time.Hour), "", "")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, svc.Export.Export(&buf, source.ID, ExportOptions{Format: ExportFHIR}))
+	bundle := buf.String()
+
+	job, err := svc.Import.ImportFHIR(target.ID, strings.NewReader(bundle), ImportOptions{Source: models.ImportSourceAPI, FileName: "diary.fhir.json"})
+	require.NoError(t, err)
+	assert.NotZero(t, job.ID)
+	assert.Equal(t, ImportFormatFHIR, job.Format)
+	assert.Equal(t, models.ImportStatusCompleted, job.Status)
+	assert.Equal(t, 3, job.Total)
+	assert.Equal(t, 2, job.Imported)
+	assert.Equal(t, 1, job.Duplicates)
+	require.NotNil(t, job.FirstAt)
+	require.NotNil(t, job.LastAt)
+	assert.True(t, base.Equal(*job.FirstAt))
+	assert.True(t, base.Add(5*time.Hour).Equal(*job.LastAt))
+
+	glucose, err := svc.Glucose.ListRecords(target.ID, ListOptions{}, "")
+	require.NoError(t, err)
+	require.Len(t, glucose.Items, 2)
+	food, err := svc.Food.ListRecords(target.ID, ListOptions{}, "")
+	require.NoError(t, err)
+	require.Len(t, food.Items, 1)
+	assert.Equal(t, "Паста", food.Items[0].FoodName)
+	assert.Equal(t, 60.0, *food.Items[0].Carbs)
+
+	// Повторная загрузка ничего не добавляет
+	job, err = svc.Import.ImportFHIR(target.ID, strings.NewReader(bundle), ImportOptions{Source: models.ImportSourceAPI})
+	require.NoError(t, err)
+	assert.Zero(t, job.Imported)
+	assert.Equal(t, 3, job.Duplicates)
+}
+
+func TestImportService_FHIRErrors(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(933, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	future := time.Now().Add(3 * time.Hour).Format(time.RFC3339)
+	for name, doc := range map[string]string{
+		"NotBundle": `{"resourceType": "Patient"}`,
+		"CSV":       "date,glucose\n",
+		"Empty":     `{"resourceType": "Bundle", "type": "collection"}`,
+		"Future": `{"resourceType": "Bundle", "entry": [{"resource": {"resourceType": "Observation", "status": "final",
+			"code": {"coding": [{"system": "http://loinc.org", "code": "15074-8"}]},
+			"effectiveDateTime": "` + future + `", "valueQuantity": {"value": 6.0}}}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.Import.ImportFHIR(user.ID, strings.NewReader(doc), ImportOptions{})
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, "file", verr.Field)
+		})
+	}
+}
@@ -2,20 +2,24 @@ package services
 
 import (
 	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
 	"time"
-
-	"gorm.io/gorm"
 )
 
 type FoodService struct {
-	db *gorm.DB
+	repo repository.FoodRepository
 }
 
-func NewFoodService(db *gorm.DB) *FoodService {
-	return &FoodService{db: db}
+func NewFoodService(repo repository.FoodRepository) *FoodService {
+	return &FoodService{repo: repo}
 }
 
 func (s *FoodService) CreateRecord(userID uint, foodName, foodType string, carbs *float64, calories *int, quantity, notes string) (*models.FoodRecord, error) {
+	return s.CreateRecordAt(userID, foodName, foodType, carbs, calories, quantity, notes, time.Now())
+}
+
+// CreateRecordAt создает запись с указанным временем приема пищи
+func (s *FoodService) CreateRecordAt(userID uint, foodName, foodType string, carbs *float64, calories *int, quantity, notes string, consumedAt time.Time) (*models.FoodRecord, error) {
 	record := models.FoodRecord{
 		UserID:     userID,
 		FoodName:   foodName,
@@ -23,11 +27,11 @@ func (s *FoodService) CreateRecord(userID uint, foodName, foodType string, carbs
 		Carbs:      carbs,
 		Calories:   calories,
 		Quantity:   quantity,
-		ConsumedAt: time.Now(),
+		ConsumedAt: consumedAt,
 		Notes:      notes,
 	}
 
-	if err := s.db.Create(&record).Error; err != nil {
+	if err := s.repo.Create(&record); err != nil {
 		return nil, err
 	}
 
@@ -35,68 +39,69 @@ func (s *FoodService) CreateRecord(userID uint, foodName, foodType string, carbs
 }
 
 func (s *FoodService) GetUserRecords(userID uint, days int) ([]models.FoodRecord, error) {
-	var records []models.FoodRecord
-	
-	startDate := time.Now().AddDate(0, 0, -days)
-	
-	err := s.db.Where("user_id = ? AND consumed_at >= ?", userID, startDate).
-		Order("consumed_at DESC").
-		Find(&records).Error
-	
-	return records, err
+	return s.repo.ListSince(userID, time.Now().AddDate(0, 0, -days))
+}
+
+// ListRecords возвращает страницу записей; пустой foodType не фильтрует
+func (s *FoodService) ListRecords(userID uint, opts ListOptions, foodType string) (*Page[models.FoodRecord], error) {
+	query, err := opts.query()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.repo.List(userID, query, foodType)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPage(records, query, func(r *models.FoodRecord) repository.Cursor {
+		return repository.Cursor{At: r.ConsumedAt, ID: r.ID}
+	}), nil
 }
 
 func (s *FoodService) GetRecordsByType(userID uint, foodType string, days int) ([]models.FoodRecord, error) {
-	var records []models.FoodRecord
-	
-	startDate := time.Now().AddDate(0, 0, -days)
-	
-	err := s.db.Where("user_id = ? AND food_type = ? AND consumed_at >= ?", userID, foodType, startDate).
-		Order("consumed_at DESC").
-		Find(&records).Error
-	
-	return records, err
+	return s.repo.ListByTypeSince(userID, foodType, time.Now().AddDate(0, 0, -days))
+}
+
+func (s *FoodService) GetRecord(userID, recordID uint) (*models.FoodRecord, error) {
+	return s.repo.GetByID(userID, recordID)
 }
 
 func (s *FoodService) DeleteRecord(userID, recordID uint) error {
-	return s.db.Where("user_id = ? AND id = ?", userID, recordID).
-		Delete(&models.FoodRecord{}).Error
+	return s.repo.Delete(userID, recordID)
 }
 
 func (s *FoodService) UpdateRecord(userID, recordID uint, updates map[string]interface{}) error {
-	return s.db.Model(&models.FoodRecord{}).
-		Where("user_id = ? AND id = ?", userID, recordID).
-		Updates(updates).Error
+	return s.repo.Update(userID, recordID, updates)
+}
+
+// RestoreRecord восстанавливает запись, удаленную не раньше deletedAfter
+func (s *FoodService) RestoreRecord(userID, recordID uint, deletedAfter time.Time) error {
+	return s.repo.Restore(userID, recordID, deletedAfter)
+}
+
+// todayTotals возвращает суммы за текущие сутки
+func (s *FoodService) todayTotals(userID uint) (*repository.FoodTotals, error) {
+	today := startOfDay(time.Now())
+	return s.repo.Totals(userID, today, today.AddDate(0, 0, 1))
 }
 
 func (s *FoodService) GetTodayCalories(userID uint) (int, error) {
-	var totalCalories int
-	
-	today := time.Now().Truncate(24 * time.Hour)
-	tomorrow := today.Add(24 * time.Hour)
-	
-	err := s.db.Model(&models.FoodRecord{}).
-		Where("user_id = ? AND consumed_at >= ? AND consumed_at < ?", userID, today, tomorrow).
-		Select("COALESCE(SUM(calories), 0)").
-		Scan(&totalCalories).Error
-	
-	return totalCalories, err
+	totals, err := s.todayTotals(userID)
+	if err != nil {
+		return 0, err
+	}
+	return totals.Calories, nil
 }
 
 func (s *FoodService) GetTodayCarbs(userID uint) (float64, error) {
-	var totalCarbs float64
-	
-	today := time.Now().Truncate(24 * time.Hour)
-	tomorrow := today.Add(24 * time.Hour)
-	
-	err := s.db.Model(&models.FoodRecord{}).
-		Where("user_id = ? AND consumed_at >= ? AND consumed_at < ?", userID, today, tomorrow).
-		Select("COALESCE(SUM(carbs), 0)").
-		Scan(&totalCarbs).Error
-	
-	return totalCarbs, err
+	totals, err := s.todayTotals(userID)
+	if err != nil {
+		return 0, err
+	}
+	return totals.Carbs, nil
 }
 
 func (s *FoodService) DeleteAllUserRecords(userID uint) error {
-	return s.db.Where("user_id = ?", userID).Delete(&models.FoodRecord{}).Error
-}
\ No newline at end of file
+	return s.repo.DeleteAllByUser(userID)
+}
@@ -5,17 +5,19 @@ import (
 	"time"
 
 	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
 	"diabetbot/internal/testutils"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestFoodService_CreateRecord(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewFoodService(db)
+	service := NewFoodService(repository.NewGormFoodRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 
 	t.Run("CreateFullRecord", func(t *testing.T) {
@@ -63,7 +65,7 @@ func TestFoodService_GetUserRecords(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewFoodService(db)
+	service := NewFoodService(repository.NewGormFoodRepository(db))
 	user1 := testutils.CreateTestUser(db, 123)
 	user2 := testutils.CreateTestUser(db, 456)
 
@@ -127,7 +129,7 @@ func TestFoodService_GetRecordsByType(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewFoodService(db)
+	service := NewFoodService(repository.NewGormFoodRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 
 	// Создаем записи разных типов с явным временем
@@ -196,7 +198,7 @@ func TestFoodService_UpdateRecord(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewFoodService(db)
+	service := NewFoodService(repository.NewGormFoodRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 	record := testutils.CreateTestFoodRecord(db, user.ID, "Старое название", "завтрак")
 
@@ -246,7 +248,7 @@ func TestFoodService_DeleteRecord(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewFoodService(db)
+	service := NewFoodService(repository.NewGormFoodRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 	record := testutils.CreateTestFoodRecord(db, user.ID, "Удаляемая еда", "завтрак")
 
@@ -266,11 +268,11 @@ func TestFoodService_GetTodayCalories(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewFoodService(db)
+	service := NewFoodService(repository.NewGormFoodRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 
 	// Создаем записи на сегодня
-	today := time.Now().Truncate(24 * time.Hour)
+	today := startOfDay(time.Now())
 	record1 := &models.FoodRecord{
 		UserID:     user.ID,
 		FoodName:   "Завтрак",
@@ -311,11 +313,11 @@ func TestFoodService_GetTodayCarbs(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewFoodService(db)
+	service := NewFoodService(repository.NewGormFoodRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 
 	// Создаем записи на сегодня
-	today := time.Now().Truncate(24 * time.Hour)
+	today := startOfDay(time.Now())
 	record1 := &models.FoodRecord{
 		UserID:     user.ID,
 		FoodName:   "Каша",
@@ -348,4 +350,25 @@ func TestFoodService_GetTodayCarbs(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, 0.0, totalCarbs)
 	})
-}
\ No newline at end of file
+}
+
+func TestFoodService_RestoreRecord(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewFoodService(repository.NewGormFoodRepository(db))
+	user := testutils.CreateTestUser(db, 123)
+	record := testutils.CreateTestFoodRecord(db, user.ID, "Овсянка", "завтрак")
+	require.NoError(t, service.DeleteRecord(user.ID, record.ID))
+
+	// Запись удалена раньше окна отмены
+	err := service.RestoreRecord(user.ID, record.ID, time.Now().Add(time.Minute))
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	err = service.RestoreRecord(user.ID, record.ID, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	restored, err := service.GetRecord(user.ID, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Овсянка", restored.FoodName)
+}
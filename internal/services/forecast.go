@@ -0,0 +1,370 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// Направление прогноза за ближайший час
+const (
+	ForecastRisingFast  = "rising_fast"
+	ForecastRising      = "rising"
+	ForecastFlat        = "flat"
+	ForecastFalling     = "falling"
+	ForecastFallingFast = "falling_fast"
+)
+
+// Прогноз строится на два часа вперед с шагом 30 минут и только от свежего измерения
+const (
+	ForecastHorizon = 2 * time.Hour
+	ForecastMaxAge  = 30 * time.Minute
+	forecastStep    = 30 * time.Minute
+)
+
+// Тренд — прямая по измерениям за forecastTrendWindow, продолженная с затуханием:
+// через время t она добавляет rate·τ·(1−e^(−t/τ)), то есть не больше rate·τ
+const (
+	forecastTrendWindow  = 45 * time.Minute
+	forecastTrendMinSpan = 10 * time.Minute // измерения ближе друг к другу дают шум, а не тренд
+	forecastTrendDamping = 20.0             // τ, минут
+	forecastMaxTrendRate = 0.2              // ммоль/л в минуту, быстрее сахар не меняется
+)
+
+// Углеводы всасываются равномерно за carbAbsorptionTime, болюс действует
+// insulinActionTime с пиком через insulinPeak. Чувствительность общая для всех:
+// настроек пользователя для нее пока нет.
+const (
+	carbAbsorptionTime = 3 * time.Hour
+	insulinActionTime  = 4 * time.Hour
+	insulinPeak        = 75 * time.Minute
+
+	forecastInsulinSensitivity = 2.0  // ммоль/л на 1 ед болюса
+	forecastCarbRatio          = 10.0 // г углеводов на 1 ед болюса
+)
+
+// Полоса неопределенности: ± forecastBandBase + forecastBandPerHour за каждый час и
+// доля forecastBandEffectShare от вклада еды и инсулина — их всасывание предсказуемо хуже всего
+const (
+	forecastBandBase        = 0.5
+	forecastBandPerHour     = 1.0
+	forecastBandEffectShare = 0.3
+)
+
+// Пределы прогноза, как у вводимых значений глюкозы
+const (
+	forecastMinValue = 1.0
+	forecastMaxValue = 30.0
+)
+
+// backtestTolerance — насколько фактическое измерение может отстоять от момента прогноза
+const backtestTolerance = 10 * time.Minute
+
+// ForecastPoint — прогноз через Minutes минут после последнего измерения и вклад каждой составляющей
+type ForecastPoint struct {
+	At            time.Time `json:"at"`
+	Minutes       int       `json:"minutes"`
+	Value         float64   `json:"value"`
+	Low           float64   `json:"low"`
+	High          float64   `json:"high"`
+	TrendEffect   float64   `json:"trend_effect"`
+	CarbEffect    float64   `json:"carb_effect"`
+	InsulinEffect float64   `json:"insulin_effect"`
+}
+
+// Forecast — прогноз глюкозы от последнего измерения. TrendRate — скорость по последним
+// измерениям в ммоль/л в час, 0 — измерений для тренда мало.
+type Forecast struct {
+	At             time.Time       `json:"at"`
+	Current        float64         `json:"current"`
+	TrendRate      float64         `json:"trend_rate"`
+	Direction      string          `json:"direction"`
+	CarbsOnBoard   float64         `json:"carbs_on_board"`   // г, еще не всосались
+	InsulinOnBoard float64         `json:"insulin_on_board"` // ед болюса, еще действуют
+	Points         []ForecastPoint `json:"points"`
+}
+
+// Point возвращает прогноз через minutes минут
+func (f *Forecast) Point(minutes int) (ForecastPoint, bool) {
+	for _, p := range f.Points {
+		if p.Minutes == minutes {
+			return p, true
+		}
+	}
+	return ForecastPoint{}, false
+}
+
+// BacktestHorizon — точность прогнозов на один горизонт. NaiveMAE — ошибка прогноза
+// «сахар не изменится» на тех же измерениях, для сравнения. Coverage — доля фактических
+// значений внутри полосы неопределенности.
+type BacktestHorizon struct {
+	Minutes  int     `json:"minutes"`
+	Count    int     `json:"count"`
+	MAE      float64 `json:"mae"`
+	NaiveMAE float64 `json:"naive_mae"`
+	Coverage float64 `json:"coverage"`
+}
+
+// BacktestResult — точность прогнозов, построенных задним числом по каждому измерению периода
+type BacktestResult struct {
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Forecasts int               `json:"forecasts"`
+	Horizons  []BacktestHorizon `json:"horizons"`
+}
+
+// ForecastService прогнозирует глюкозу на ближайшие два часа по тренду последних
+// измерений, еще не всосавшимся углеводам и действующему болюсу
+type ForecastService struct {
+	glucose repository.GlucoseRepository
+	food    repository.FoodRepository
+	insulin repository.InsulinRepository
+}
+
+func NewForecastService(glucose repository.GlucoseRepository, food repository.FoodRepository, insulin repository.InsulinRepository) *ForecastService {
+	return &ForecastService{glucose: glucose, food: food, insulin: insulin}
+}
+
+// Forecast строит прогноз по данным на момент at от последнего измерения не старше
+// ForecastMaxAge. ErrNotFound — свежего измерения нет.
+func (s *ForecastService) Forecast(userID uint, at time.Time) (*Forecast, error) {
+	in, err := s.history(userID, at.Add(-ForecastMaxAge), at.Add(time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	forecast, ok := in.forecast(at)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return forecast, nil
+}
+
+// Backtest повторяет историю [from, to): по каждому измерению строит прогноз только по
+// данным, известным на тот момент, и сравнивает его с измерениями через час и два
+func (s *ForecastService) Backtest(userID uint, from, to time.Time) (*BacktestResult, error) {
+	if !from.Before(to) {
+		return nil, &ValidationError{Field: "from", Rule: "ltfield", Param: "to"}
+	}
+	in, err := s.history(userID, from, to.Add(ForecastHorizon+backtestTolerance))
+	if err != nil {
+		return nil, err
+	}
+
+	type horizonErrors struct {
+		count            int
+		abs, naive, band float64
+	}
+	horizons := []int{60, 120}
+	errs := make([]horizonErrors, len(horizons))
+	result := &BacktestResult{From: from, To: to}
+	for _, anchor := range recordsBetween(in.readings, from, to) {
+		forecast, ok := in.forecast(anchor.MeasuredAt)
+		if !ok {
+			continue
+		}
+		result.Forecasts++
+		for i, minutes := range horizons {
+			point, _ := forecast.Point(minutes)
+			actual, ok := nearestRecord(in.readings, point.At, backtestTolerance)
+			if !ok {
+				continue
+			}
+			errs[i].count++
+			errs[i].abs += math.Abs(point.Value - actual.Value)
+			errs[i].naive += math.Abs(forecast.Current - actual.Value)
+			if actual.Value >= point.Low && actual.Value <= point.High {
+				errs[i].band++
+			}
+		}
+	}
+	for i, minutes := range horizons {
+		h := BacktestHorizon{Minutes: minutes, Count: errs[i].count}
+		if h.Count > 0 {
+			n := float64(h.Count)
+			h.MAE, h.NaiveMAE, h.Coverage = round2(errs[i].abs/n), round2(errs[i].naive/n), round2(errs[i].band/n)
+		}
+		result.Horizons = append(result.Horizons, h)
+	}
+	return result, nil
+}
+
+// history загружает измерения [from, to) и еду с болюсами, которые еще действуют в этом периоде
+func (s *ForecastService) history(userID uint, from, to time.Time) (*forecastInput, error) {
+	readings, err := s.glucose.List(userID, repository.ListQuery{From: from.Add(-forecastTrendWindow), To: to, Ascending: true}, "")
+	if err != nil {
+		return nil, err
+	}
+	food, err := s.food.List(userID, repository.ListQuery{From: from.Add(-carbAbsorptionTime), To: to, Ascending: true}, "")
+	if err != nil {
+		return nil, err
+	}
+	insulin, err := s.insulin.List(userID, repository.ListQuery{From: from.Add(-insulinActionTime), To: to, Ascending: true})
+	if err != nil {
+		return nil, err
+	}
+	return &forecastInput{readings: readings, food: food, insulin: insulin}, nil
+}
+
+// forecastInput — история для прогноза по возрастанию времени
+type forecastInput struct {
+	readings []models.GlucoseRecord
+	food     []models.FoodRecord
+	insulin  []models.InsulinRecord
+}
+
+// forecast строит прогноз от последнего измерения не позже at, учитывая только записи
+// не позже at — так прогнозы задним числом не подсматривают в будущее
+func (in *forecastInput) forecast(at time.Time) (*Forecast, bool) {
+	known := recordsBetween(in.readings, time.Time{}, at.Add(time.Nanosecond))
+	if len(known) == 0 {
+		return nil, false
+	}
+	anchor := known[len(known)-1]
+	if at.Sub(anchor.MeasuredAt) > ForecastMaxAge {
+		return nil, false
+	}
+	t0 := anchor.MeasuredAt
+
+	rate := trendRate(recordsBetween(known, t0.Add(-forecastTrendWindow), t0.Add(time.Nanosecond)))
+	forecast := &Forecast{At: t0, Current: anchor.Value, TrendRate: round2(rate * 60)}
+
+	for _, meal := range in.food {
+		if meal.Carbs == nil || meal.ConsumedAt.After(at) {
+			continue
+		}
+		forecast.CarbsOnBoard += *meal.Carbs * (1 - carbAbsorbed(t0.Sub(meal.ConsumedAt)))
+	}
+	for _, dose := range in.insulin {
+		if dose.InsulinType != models.InsulinTypeBolus || dose.InjectedAt.After(at) {
+			continue
+		}
+		forecast.InsulinOnBoard += dose.Units * (1 - insulinAbsorbed(t0.Sub(dose.InjectedAt)))
+	}
+	forecast.CarbsOnBoard = math.Round(forecast.CarbsOnBoard)
+	forecast.InsulinOnBoard = math.Round(forecast.InsulinOnBoard*10) / 10
+
+	for step := forecastStep; step <= ForecastHorizon; step += forecastStep {
+		minutes := step.Minutes()
+		point := ForecastPoint{At: t0.Add(step), Minutes: int(minutes)}
+		point.TrendEffect = rate * forecastTrendDamping * (1 - math.Exp(-minutes/forecastTrendDamping))
+		for _, meal := range in.food {
+			if meal.Carbs == nil || meal.ConsumedAt.After(at) {
+				continue
+			}
+			since := t0.Sub(meal.ConsumedAt)
+			point.CarbEffect += *meal.Carbs * forecastInsulinSensitivity / forecastCarbRatio *
+				(carbAbsorbed(since+step) - carbAbsorbed(since))
+		}
+		for _, dose := range in.insulin {
+			if dose.InsulinType != models.InsulinTypeBolus || dose.InjectedAt.After(at) {
+				continue
+			}
+			since := t0.Sub(dose.InjectedAt)
+			point.InsulinEffect -= dose.Units * forecastInsulinSensitivity *
+				(insulinAbsorbed(since+step) - insulinAbsorbed(since))
+		}
+
+		value := anchor.Value + point.TrendEffect + point.CarbEffect + point.InsulinEffect
+		band := forecastBandBase + forecastBandPerHour*minutes/60 +
+			forecastBandEffectShare*(math.Abs(point.CarbEffect)+math.Abs(point.InsulinEffect))
+		point.Value = round1(clampForecast(value))
+		point.Low = round1(clampForecast(value - band))
+		point.High = round1(clampForecast(value + band))
+		point.TrendEffect, point.CarbEffect, point.InsulinEffect = round1(point.TrendEffect), round1(point.CarbEffect), round1(point.InsulinEffect)
+		forecast.Points = append(forecast.Points, point)
+	}
+
+	hour, _ := forecast.Point(60)
+	forecast.Direction = forecastDirection(hour.Value - anchor.Value)
+	return forecast, true
+}
+
+// trendRate — скорость изменения сахара в ммоль/л в минуту по прямой через измерения;
+// 0, если они охватывают меньше forecastTrendMinSpan
+func trendRate(records []models.GlucoseRecord) float64 {
+	if len(records) < 2 || records[len(records)-1].MeasuredAt.Sub(records[0].MeasuredAt) < forecastTrendMinSpan {
+		return 0
+	}
+	xs, ys := make([]float64, len(records)), make([]float64, len(records))
+	for i, r := range records {
+		xs[i] = r.MeasuredAt.Sub(records[0].MeasuredAt).Minutes()
+		ys[i] = r.Value
+	}
+	slope, _, _ := linearFit(xs, ys)
+	return math.Max(-forecastMaxTrendRate, math.Min(forecastMaxTrendRate, slope))
+}
+
+// carbAbsorbed — доля углеводов, всосавшихся через since после еды
+func carbAbsorbed(since time.Duration) float64 {
+	return math.Max(0, math.Min(1, since.Minutes()/carbAbsorptionTime.Minutes()))
+}
+
+// insulinAbsorbed — доля болюса, подействовавшая через since после укола. Действие
+// нарастает линейно до пика и линейно спадает к концу insulinActionTime.
+func insulinAbsorbed(since time.Duration) float64 {
+	t, peak, end := since.Minutes(), insulinPeak.Minutes(), insulinActionTime.Minutes()
+	switch {
+	case t <= 0:
+		return 0
+	case t <= peak:
+		return t * t / (peak * end)
+	case t < end:
+		return 1 - (end-t)*(end-t)/(end*(end-peak))
+	default:
+		return 1
+	}
+}
+
+// forecastDirection — направление по изменению за час в ммоль/л
+func forecastDirection(change float64) string {
+	switch {
+	case change >= 2:
+		return ForecastRisingFast
+	case change >= 0.5:
+		return ForecastRising
+	case change > -0.5:
+		return ForecastFlat
+	case change > -2:
+		return ForecastFalling
+	default:
+		return ForecastFallingFast
+	}
+}
+
+// nearestRecord — измерение, ближайшее к at, не дальше tolerance
+func nearestRecord(records []models.GlucoseRecord, at time.Time, tolerance time.Duration) (models.GlucoseRecord, bool) {
+	i := sort.Search(len(records), func(i int) bool { return !records[i].MeasuredAt.Before(at) })
+	best, found := models.GlucoseRecord{}, false
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(records) {
+			continue
+		}
+		diff := absDuration(records[j].MeasuredAt.Sub(at))
+		if diff <= tolerance && (!found || diff < absDuration(best.MeasuredAt.Sub(at))) {
+			best, found = records[j], true
+		}
+	}
+	return best, found
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func clampForecast(value float64) float64 {
+	return math.Max(forecastMinValue, math.Min(forecastMaxValue, value))
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
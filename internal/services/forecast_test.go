@@ -0,0 +1,118 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForecastService_Forecast(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(990, "", "Анна", "", "ru")
+	require.NoError(t, err)
+	t0 := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+
+	_, err = svc.Forecast.Forecast(user.ID, t0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, m := range []int{30, 15, 0} {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, 6.0, t0.Add(-time.Duration(m)*time.Minute), "", "")
+		require.NoError(t, err)
+	}
+
+	// Ровный сахар без еды и инсулина остается на месте, полоса растет со временем
+	forecast, err := svc.Forecast.Forecast(user.ID, t0)
+	require.NoError(t, err)
+	assert.Equal(t, ForecastFlat, forecast.Direction)
+	require.Len(t, forecast.Points, 4)
+	hour, ok := forecast.Point(60)
+	require.True(t, ok)
+	assert.Equal(t, 6.0, hour.Value)
+	assert.Equal(t, 4.5, hour.Low)
+	assert.Equal(t, 7.5, hour.High)
+
+	// 60 г углеводов и 6 ед болюса сразу после измерения: за час всасывается треть
+	// углеводов (+4.0) и пятая часть болюса (−2.4)
+	carbs := 60.0
+	_, err = svc.Food.CreateRecordAt(user.ID, "Плов", "обед", &carbs, nil, "", "", t0)
+	require.NoError(t, err)
+	_, err = svc.Insulin.CreateRecord(user.ID, 6, models.InsulinTypeBolus, "", t0, "")
+	require.NoError(t, err)
+	_, err = svc.Insulin.CreateRecord(user.ID, 20, models.InsulinTypeBasal, "", t0, "")
+	require.NoError(t, err)
+
+	forecast, err = svc.Forecast.Forecast(user.ID, t0.Add(5*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 60.0, forecast.CarbsOnBoard)
+	assert.Equal(t, 6.0, forecast.InsulinOnBoard)
+	hour, _ = forecast.Point(60)
+	assert.Equal(t, 4.0, hour.CarbEffect)
+	assert.Equal(t, -2.4, hour.InsulinEffect)
+	assert.Equal(t, 7.6, hour.Value)
+	assert.Equal(t, 4.2, hour.Low)
+	assert.Equal(t, 11.0, hour.High)
+	assert.Equal(t, ForecastRising, forecast.Direction)
+	twoHours, _ := forecast.Point(120)
+	assert.Equal(t, 6.4, twoHours.Value)
+
+	// Прогноз задним числом не видит записей после своего момента
+	forecast, err = svc.Forecast.Forecast(user.ID, t0.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, forecast.CarbsOnBoard)
+
+	_, err = svc.Forecast.Forecast(user.ID, t0.Add(ForecastMaxAge+time.Minute))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestForecastService_Trend(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(991, "", "Анна", "", "ru")
+	require.NoError(t, err)
+	t0 := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	for i, value := range []float64{6.0, 7.0, 8.0} {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, value, t0.Add(time.Duration(i-2)*15*time.Minute), "", "")
+		require.NoError(t, err)
+	}
+
+	forecast, err := svc.Forecast.Forecast(user.ID, t0)
+	require.NoError(t, err)
+	assert.Equal(t, 4.0, forecast.TrendRate)
+	hour, _ := forecast.Point(60)
+	// Тренд затухает: за час добавляет 1.3, а не 4.0
+	assert.Equal(t, 1.3, hour.TrendEffect)
+	assert.Equal(t, 9.3, hour.Value)
+	assert.Equal(t, ForecastRising, forecast.Direction)
+}
+
+func TestForecastService_Backtest(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(992, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	// Показания сенсора каждые 15 минут: сахар ровно растет на 1.2 ммоль/л в час
+	from := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 24; i++ {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, 5.0+0.3*float64(i), from.Add(time.Duration(i)*15*time.Minute), "", "")
+		require.NoError(t, err)
+	}
+
+	result, err := svc.Forecast.Backtest(user.ID, from, from.Add(6*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 24, result.Forecasts)
+	require.Len(t, result.Horizons, 2)
+	hour := result.Horizons[0]
+	assert.Equal(t, 60, hour.Minutes)
+	assert.Equal(t, 20, hour.Count)
+	assert.Equal(t, 1.2, hour.NaiveMAE)
+	assert.Less(t, hour.MAE, hour.NaiveMAE)
+	assert.Equal(t, 1.0, hour.Coverage)
+	assert.Equal(t, 16, result.Horizons[1].Count)
+
+	_, err = svc.Forecast.Backtest(user.ID, from, from)
+	assert.ErrorIs(t, err, ErrValidation)
+}
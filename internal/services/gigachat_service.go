@@ -18,9 +18,11 @@ import (
 type GigaChatService struct {
 	apiKey    string
 	baseURL   string
+	authURL   string
 	client    *http.Client
 	authToken string
 	tokenExp  time.Time
+	patient   PatientContext // сведения из дневника для запросов, nil — без них
 }
 
 type AuthRequest struct {
@@ -73,6 +75,7 @@ func NewGigaChatService(cfg *config.GigaChatConfig) *GigaChatService {
 	return &GigaChatService{
 		apiKey:  cfg.APIKey,
 		baseURL: cfg.BaseURL,
+		authURL: cfg.AuthURL,
 		client: &http.Client{
 			Transport: &http.Transport{
 				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
@@ -82,18 +85,24 @@ func NewGigaChatService(cfg *config.GigaChatConfig) *GigaChatService {
 	}
 }
 
+// SetPatientContext задает источник сведений из дневника, которые добавляются к запросам
+func (s *GigaChatService) SetPatientContext(ctx PatientContext) {
+	s.patient = ctx
+}
+
 func (s *GigaChatService) authenticate() error {
 	if time.Now().Before(s.tokenExp) && s.authToken != "" {
 		return nil // токен еще действителен
 	}
 
-	// Используем локальный прокси для обхода Docker ограничений  
-	authURL := "http://172.17.0.1:8888/oauth"
+	authURL := s.authURL
+	if authURL == "" {
+		authURL = s.baseURL + "/api/v1/oauth"
+	}
 	formData := "scope=GIGACHAT_API_PERS"
-	log.Printf("GigaChat auth URL (via proxy): %s", authURL)
-	log.Printf("GigaChat auth data: %s", formData)
-	log.Printf("GigaChat API key (first 20 chars): %s", s.apiKey[:20])
-	
+	// Ключ и заголовки не пишем в лог: в Authorization передается сам ключ
+	log.Printf("GigaChat auth URL: %s", authURL)
+
 	req, err := http.NewRequest("POST", authURL, strings.NewReader(formData))
 	if err != nil {
 		return fmt.Errorf("failed to create auth request: %w", err)
@@ -104,8 +113,6 @@ func (s *GigaChatService) authenticate() error {
 	req.Header.Set("Authorization", "Basic "+s.apiKey)
 	req.Header.Set("RqUID", fmt.Sprintf("%d", time.Now().UnixNano()))
 	req.Header.Set("User-Agent", "DiabetBot/1.0")
-	
-	log.Printf("Request headers: %+v", req.Header)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -120,8 +127,6 @@ func (s *GigaChatService) authenticate() error {
 	}
 
 	log.Printf("Auth response status: %d", resp.StatusCode)
-	log.Printf("Auth response headers: %+v", resp.Header)
-	log.Printf("Auth response body: %s", string(body))
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("auth failed with status %d: %s", resp.StatusCode, string(body))
@@ -214,7 +219,7 @@ func (s *GigaChatService) GetGlucoseRecommendation(user *models.User, record *mo
 Не ставь диагнозы, рекомендуй обращение к врачу при критических значениях.
 Отвечай по-русски, дружелюбно и профессионально.`
 
-	userPrompt := fmt.Sprintf(`Пациент:
+	userPrompt := withPatientContext(s.patient, user, fmt.Sprintf(`Пациент:
 - Диабет: %s
 - Целевая глюкоза: %s
 - Текущий показатель: %.1f ммоль/л
@@ -224,7 +229,7 @@ func (s *GigaChatService) GetGlucoseRecommendation(user *models.User, record *mo
 		diabetesTypeText, 
 		targetText, 
 		record.Value, 
-		record.MeasuredAt.Format("15:04 02.01.2006"))
+		record.MeasuredAt.Format("15:04 02.01.2006")))
 
 	messages := []Message{
 		{Role: "system", Content: systemPrompt},
@@ -254,10 +259,10 @@ func (s *GigaChatService) GetFoodRecommendation(user *models.User, foodDescripti
 Оцени углеводность продуктов, влияние на сахар крови, дай советы по порциям или сочетанию с другими продуктами.
 Отвечай по-русски, дружелюбно и практично.`
 
-	userPrompt := fmt.Sprintf(`Пациент с диабетом %s описал прием пищи:
+	userPrompt := withPatientContext(s.patient, user, fmt.Sprintf(`Пациент с диабетом %s описал прием пищи:
 "%s"
 
-Дай рекомендацию по этой еде для контроля сахара в крови.`, diabetesTypeText, foodDescription)
+Дай рекомендацию по этой еде для контроля сахара в крови.`, diabetesTypeText, foodDescription))
 
 	messages := []Message{
 		{Role: "system", Content: systemPrompt},
@@ -287,10 +292,10 @@ func (s *GigaChatService) GetGeneralRecommendation(user *models.User, question s
 Давай практические советы (до 200 слов). Не ставь диагнозы, при серьезных симптомах рекомендуй врача.
 Отвечай по-русски, понятно и дружелюбно.`
 
-	userPrompt := fmt.Sprintf(`Пациент с диабетом %s спрашивает:
+	userPrompt := withPatientContext(s.patient, user, fmt.Sprintf(`Пациент с диабетом %s спрашивает:
 "%s"
 
-Дай полезный ответ по этому вопросу.`, diabetesTypeText, question)
+Дай полезный ответ по этому вопросу.`, diabetesTypeText, question))
 
 	messages := []Message{
 		{Role: "system", Content: systemPrompt},
@@ -304,4 +309,33 @@ func (s *GigaChatService) GetGeneralRecommendation(user *models.User, question s
 	}
 
 	return response
-}
\ No newline at end of file
+}
+
+func (s *GigaChatService) GetDigestCommentary(user *models.User, summary string) string {
+	if s.apiKey == "" || s.apiKey == "your_gigachat_api_key_here" {
+		return ""
+	}
+
+	diabetesTypeText := "не указан"
+	if user.DiabetesType != nil {
+		diabetesTypeText = fmt.Sprintf("%d типа", *user.DiabetesType)
+	}
+
+	userPrompt := withPatientContext(s.patient, user, fmt.Sprintf(`Пациент с диабетом %s. Показатели за неделю:
+%s
+
+Прокомментируй неделю.`, diabetesTypeText, summary))
+
+	messages := []Message{
+		{Role: "system", Content: digestSystemPrompt},
+		{Role: "user", Content: userPrompt},
+	}
+
+	response, err := s.sendChatRequest(messages)
+	if err != nil {
+		log.Printf("GigaChat digest commentary error: %v", err)
+		return ""
+	}
+
+	return response
+}
@@ -2,94 +2,99 @@ package services
 
 import (
 	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
 	"time"
-
-	"gorm.io/gorm"
 )
 
 type GlucoseService struct {
-	db *gorm.DB
+	repo   repository.GlucoseRepository
+	alerts *AlertService // nil — без тревог родственникам
 }
 
-type GlucoseStats struct {
-	Average float64 `json:"average"`
-	Min     float64 `json:"min"`
-	Max     float64 `json:"max"`
-	Count   int64   `json:"count"`
-}
+type GlucoseStats = repository.GlucoseStats
 
-func NewGlucoseService(db *gorm.DB) *GlucoseService {
-	return &GlucoseService{db: db}
+func NewGlucoseService(repo repository.GlucoseRepository) *GlucoseService {
+	return &GlucoseService{repo: repo}
 }
 
 func (s *GlucoseService) CreateRecord(userID uint, value float64, notes string) (*models.GlucoseRecord, error) {
+	return s.CreateRecordAt(userID, value, time.Now(), "", notes)
+}
+
+// CreateRecordAt создает запись с указанным временем измерения и контекстом
+func (s *GlucoseService) CreateRecordAt(userID uint, value float64, measuredAt time.Time, measurementContext, notes string) (*models.GlucoseRecord, error) {
 	record := models.GlucoseRecord{
-		UserID:     userID,
-		Value:      value,
-		MeasuredAt: time.Now(),
-		Notes:      notes,
+		UserID:             userID,
+		Value:              value,
+		MeasuredAt:         measuredAt,
+		MeasurementContext: measurementContext,
+		Notes:              notes,
+		Source:             models.GlucoseSourceFingerstick,
 	}
 
-	if err := s.db.Create(&record).Error; err != nil {
+	if err := s.repo.Create(&record); err != nil {
 		return nil, err
 	}
+	s.alerts.observe(userID, []models.GlucoseRecord{record})
 
 	return &record, nil
 }
 
 func (s *GlucoseService) GetUserRecords(userID uint, days int) ([]models.GlucoseRecord, error) {
-	var records []models.GlucoseRecord
-	
-	startDate := time.Now().AddDate(0, 0, -days)
-	
-	err := s.db.Where("user_id = ? AND measured_at >= ?", userID, startDate).
-		Order("measured_at DESC").
-		Find(&records).Error
-	
-	return records, err
+	return s.repo.ListSince(userID, time.Now().AddDate(0, 0, -days))
 }
 
-func (s *GlucoseService) GetUserStats(userID uint, days int) (*GlucoseStats, error) {
-	var stats GlucoseStats
-	
-	startDate := time.Now().AddDate(0, 0, -days)
-	
-	err := s.db.Model(&models.GlucoseRecord{}).
-		Where("user_id = ? AND measured_at >= ?", userID, startDate).
-		Select("AVG(value) as average, MIN(value) as min, MAX(value) as max, COUNT(*) as count").
-		Scan(&stats).Error
-	
-	return &stats, err
-}
+// ListRecords возвращает страницу записей; пустой measurementContext не фильтрует
+func (s *GlucoseService) ListRecords(userID uint, opts ListOptions, measurementContext string) (*Page[models.GlucoseRecord], error) {
+	query, err := opts.query()
+	if err != nil {
+		return nil, err
+	}
 
-func (s *GlucoseService) GetRecentRecord(userID uint) (*models.GlucoseRecord, error) {
-	var record models.GlucoseRecord
-	
-	err := s.db.Where("user_id = ?", userID).
-		Order("measured_at DESC").
-		First(&record).Error
-	
+	records, err := s.repo.List(userID, query, measurementContext)
 	if err != nil {
 		return nil, err
 	}
-	
-	return &record, nil
+
+	return newPage(records, query, func(r *models.GlucoseRecord) repository.Cursor {
+		return repository.Cursor{At: r.MeasuredAt, ID: r.ID}
+	}), nil
+}
+
+func (s *GlucoseService) GetUserStats(userID uint, days int) (*GlucoseStats, error) {
+	return s.repo.Stats(userID, time.Now().AddDate(0, 0, -days))
+}
+
+func (s *GlucoseService) GetRecentRecord(userID uint) (*models.GlucoseRecord, error) {
+	return s.repo.Latest(userID)
+}
+
+func (s *GlucoseService) GetRecord(userID, recordID uint) (*models.GlucoseRecord, error) {
+	return s.repo.GetByID(userID, recordID)
 }
 
 func (s *GlucoseService) DeleteRecord(userID, recordID uint) error {
-	return s.db.Where("user_id = ? AND id = ?", userID, recordID).
-		Delete(&models.GlucoseRecord{}).Error
+	return s.repo.Delete(userID, recordID)
 }
 
 func (s *GlucoseService) UpdateRecord(userID, recordID uint, value float64, notes string) error {
-	return s.db.Model(&models.GlucoseRecord{}).
-		Where("user_id = ? AND id = ?", userID, recordID).
-		Updates(map[string]interface{}{
-			"value": value,
-			"notes": notes,
-		}).Error
+	return s.repo.Update(userID, recordID, map[string]interface{}{
+		"value": value,
+		"notes": notes,
+	})
+}
+
+// RestoreRecord восстанавливает запись, удаленную не раньше deletedAfter
+func (s *GlucoseService) RestoreRecord(userID, recordID uint, deletedAfter time.Time) error {
+	return s.repo.Restore(userID, recordID, deletedAfter)
+}
+
+func (s *GlucoseService) UpdateContext(userID, recordID uint, measurementContext string) error {
+	return s.repo.Update(userID, recordID, map[string]interface{}{
+		"measurement_context": measurementContext,
+	})
 }
 
 func (s *GlucoseService) DeleteAllUserRecords(userID uint) error {
-	return s.db.Where("user_id = ?", userID).Delete(&models.GlucoseRecord{}).Error
-}
\ No newline at end of file
+	return s.repo.DeleteAllByUser(userID)
+}
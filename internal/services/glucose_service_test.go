@@ -5,17 +5,19 @@ import (
 	"time"
 
 	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
 	"diabetbot/internal/testutils"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestGlucoseService_CreateRecord(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewGlucoseService(db)
+	service := NewGlucoseService(repository.NewGormGlucoseRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 
 	t.Run("CreateValidRecord", func(t *testing.T) {
@@ -47,7 +49,7 @@ func TestGlucoseService_GetUserRecords(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewGlucoseService(db)
+	service := NewGlucoseService(repository.NewGormGlucoseRepository(db))
 	user1 := testutils.CreateTestUser(db, 123)
 	user2 := testutils.CreateTestUser(db, 456)
 
@@ -116,7 +118,7 @@ func TestGlucoseService_GetUserStats(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewGlucoseService(db)
+	service := NewGlucoseService(repository.NewGormGlucoseRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 
 	// Создаем записи с разными значениями и явным временем (в пределах последних 7 дней)
@@ -172,7 +174,7 @@ func TestGlucoseService_GetRecentRecord(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewGlucoseService(db)
+	service := NewGlucoseService(repository.NewGormGlucoseRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 
 	t.Run("GetMostRecentRecord", func(t *testing.T) {
@@ -211,7 +213,7 @@ func TestGlucoseService_UpdateRecord(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewGlucoseService(db)
+	service := NewGlucoseService(repository.NewGormGlucoseRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 	record := testutils.CreateTestGlucoseRecord(db, user.ID, 5.0)
 
@@ -234,7 +236,7 @@ func TestGlucoseService_DeleteRecord(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewGlucoseService(db)
+	service := NewGlucoseService(repository.NewGormGlucoseRepository(db))
 	user := testutils.CreateTestUser(db, 123)
 	record := testutils.CreateTestGlucoseRecord(db, user.ID, 5.0)
 
@@ -248,4 +250,61 @@ func TestGlucoseService_DeleteRecord(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotNil(t, deleted.DeletedAt)
 	})
-}
\ No newline at end of file
+}
+
+func TestGlucoseService_RestoreRecord(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewGlucoseService(repository.NewGormGlucoseRepository(db))
+	user := testutils.CreateTestUser(db, 123)
+
+	t.Run("RestoreRecentlyDeleted", func(t *testing.T) {
+		record := testutils.CreateTestGlucoseRecord(db, user.ID, 6.5)
+		require.NoError(t, service.DeleteRecord(user.ID, record.ID))
+
+		err := service.RestoreRecord(user.ID, record.ID, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+
+		restored, err := service.GetRecord(user.ID, record.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 6.5, restored.Value)
+	})
+
+	t.Run("RestoreAfterWindowFails", func(t *testing.T) {
+		record := testutils.CreateTestGlucoseRecord(db, user.ID, 7.0)
+		require.NoError(t, service.DeleteRecord(user.ID, record.ID))
+
+		err := service.RestoreRecord(user.ID, record.ID, time.Now().Add(time.Minute))
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		_, err = service.GetRecord(user.ID, record.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("RestoreOtherUserRecordFails", func(t *testing.T) {
+		otherUser := testutils.CreateTestUser(db, 456)
+		record := testutils.CreateTestGlucoseRecord(db, otherUser.ID, 5.0)
+		require.NoError(t, service.DeleteRecord(otherUser.ID, record.ID))
+
+		err := service.RestoreRecord(user.ID, record.ID, time.Now().Add(-time.Minute))
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+}
+
+func TestGlucoseService_UpdateContext(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewGlucoseService(repository.NewGormGlucoseRepository(db))
+	user := testutils.CreateTestUser(db, 123)
+	record := testutils.CreateTestGlucoseRecord(db, user.ID, 5.4)
+
+	err := service.UpdateContext(user.ID, record.ID, models.GlucoseContextFasting)
+	require.NoError(t, err)
+
+	updated, err := service.GetRecord(user.ID, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.GlucoseContextFasting, updated.MeasurementContext)
+	assert.Equal(t, 5.4, updated.Value)
+}
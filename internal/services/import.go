@@ -0,0 +1,232 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"diabetbot/internal/importer"
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// importFutureTolerance — насколько измерение может быть в будущем: часы глюкометра
+// и телефона расходятся, но дальше это уже ошибка часового пояса
+const importFutureTolerance = time.Hour
+
+// ErrImportNotPending — импорт уже подтвержден или отменен
+var ErrImportNotPending = &ValidationError{Field: "status", Rule: "oneof", Param: models.ImportStatusPreview}
+
+// ImportOptions — параметры импорта файла
+type ImportOptions struct {
+	Format   string            // пустой — определить по заголовку
+	Mapping  *importer.Mapping // колонки произвольного CSV
+	Location *time.Location    // часовой пояс времени в файле, nil — time.Local
+	Source   string            // models.ImportSource*
+	FileName string
+	FileID   string // file_id Telegram, чтобы скачать файл еще раз при подтверждении
+	DryRun   bool   // только разобрать файл и посчитать дубликаты
+}
+
+// ImportService загружает измерения глюкозы из выгрузок LibreView, Dexcom Clarity и CSV глюкометров
+type ImportService struct {
+	glucose repository.GlucoseRepository
+	food    repository.FoodRepository
+	jobs    repository.ImportJobRepository
+}
+
+func NewImportService(glucose repository.GlucoseRepository, food repository.FoodRepository, jobs repository.ImportJobRepository) *ImportService {
+	return &ImportService{glucose: glucose, food: food, jobs: jobs}
+}
+
+// Run разбирает файл и сохраняет новые измерения. При DryRun записи не сохраняются,
+// а импорт остается в статусе preview до Confirm или Cancel.
+func (s *ImportService) Run(userID uint, r io.Reader, opts ImportOptions) (*models.ImportJob, error) {
+	result, err := importer.Parse(r, importer.Options{Format: opts.Format, Mapping: opts.Mapping, Location: opts.Location})
+	if err != nil {
+		return nil, importError(err)
+	}
+
+	fresh, job, err := s.prepare(userID, result)
+	if err != nil {
+		return nil, err
+	}
+	job.Source = opts.Source
+	job.FileName = opts.FileName
+	job.FileID = opts.FileID
+	job.Status = models.ImportStatusPreview
+
+	if !opts.DryRun {
+		if err := s.glucose.CreateBatch(fresh); err != nil {
+			return nil, err
+		}
+		completeJob(job, len(fresh))
+	}
+	if err := s.jobs.Create(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Confirm сохраняет измерения импорта, созданного с DryRun. Файл разбирается заново,
+// поэтому дубликаты учитывают записи, добавленные после предпросмотра.
+func (s *ImportService) Confirm(userID, jobID uint, r io.Reader, opts ImportOptions) (*models.ImportJob, error) {
+	job, err := s.pendingJob(userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := importer.Parse(r, importer.Options{Format: job.Format, Mapping: opts.Mapping, Location: opts.Location})
+	if err != nil {
+		return nil, importError(err)
+	}
+	fresh, parsed, err := s.prepare(userID, result)
+	if err != nil {
+		return nil, err
+	}
+	parsed.ID, parsed.UserID, parsed.CreatedAt = job.ID, job.UserID, job.CreatedAt
+	parsed.Source, parsed.FileName, parsed.FileID = job.Source, job.FileName, job.FileID
+	completeJob(parsed, len(fresh))
+
+	// Импорт забирается до сохранения: из двух одновременных подтверждений
+	// измерения сохранит только то, что первым сменило статус
+	if err := s.claim(userID, jobID, map[string]interface{}{
+		"status":       parsed.Status,
+		"total":        parsed.Total,
+		"imported":     parsed.Imported,
+		"duplicates":   parsed.Duplicates,
+		"skipped":      parsed.Skipped,
+		"first_at":     parsed.FirstAt,
+		"last_at":      parsed.LastAt,
+		"completed_at": parsed.CompletedAt,
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.glucose.CreateBatch(fresh); err != nil {
+		// Возвращаем импорт в предпросмотр, чтобы подтверждение можно было повторить
+		_ = s.jobs.Update(userID, jobID, map[string]interface{}{"status": models.ImportStatusPreview, "imported": 0, "completed_at": nil})
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// Cancel отменяет импорт, ожидающий подтверждения
+func (s *ImportService) Cancel(userID, jobID uint) error {
+	if _, err := s.pendingJob(userID, jobID); err != nil {
+		return err
+	}
+	return s.claim(userID, jobID, map[string]interface{}{"status": models.ImportStatusCancelled})
+}
+
+func (s *ImportService) GetJob(userID, jobID uint) (*models.ImportJob, error) {
+	return s.jobs.GetByID(userID, jobID)
+}
+
+func (s *ImportService) pendingJob(userID, jobID uint) (*models.ImportJob, error) {
+	job, err := s.jobs.GetByID(userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.ImportStatusPreview {
+		return nil, ErrImportNotPending
+	}
+	return job, nil
+}
+
+// claim переводит импорт из preview; ErrImportNotPending, если его уже подтвердил
+// или отменил другой запрос после проверки в pendingJob
+func (s *ImportService) claim(userID, jobID uint, updates map[string]interface{}) error {
+	err := s.jobs.Claim(userID, jobID, models.ImportStatusPreview, updates)
+	if errors.Is(err, ErrNotFound) {
+		return ErrImportNotPending
+	}
+	return err
+}
+
+// prepare отбрасывает измерения из будущего и дубликаты: те, что уже есть в дневнике,
+// и повторы внутри файла. Совпадением считается та же минута и то же значение с точностью до 0.1.
+func (s *ImportService) prepare(userID uint, result *importer.Result) ([]models.GlucoseRecord, *models.ImportJob, error) {
+	job := &models.ImportJob{
+		UserID:  userID,
+		Format:  result.Format,
+		Unit:    string(result.Unit),
+		Total:   len(result.Readings),
+		Skipped: result.Skipped,
+	}
+
+	limit := time.Now().Add(importFutureTolerance)
+	readings := make([]importer.Reading, 0, len(result.Readings))
+	for _, reading := range result.Readings {
+		if reading.At.After(limit) {
+			job.Total--
+			job.Skipped++
+			continue
+		}
+		readings = append(readings, reading)
+	}
+	if len(readings) == 0 {
+		return nil, nil, importError(importer.ErrNoReadings)
+	}
+
+	first, last := readings[0].At, readings[len(readings)-1].At
+	job.FirstAt, job.LastAt = &first, &last
+
+	records := make([]models.GlucoseRecord, len(readings))
+	for i, reading := range readings {
+		source := models.GlucoseSourceFingerstick
+		if reading.CGM {
+			source = models.GlucoseSourceCGM
+		}
+		records[i] = models.GlucoseRecord{
+			UserID:     userID,
+			Value:      reading.Value,
+			MeasuredAt: reading.At,
+			Notes:      reading.Notes,
+			Source:     source,
+		}
+	}
+	fresh, duplicates, err := freshGlucose(s.glucose, userID, records)
+	if err != nil {
+		return nil, nil, err
+	}
+	job.Duplicates = duplicates
+	return fresh, job, nil
+}
+
+// freshGlucose отбрасывает измерения, которые уже есть в дневнике или повторяются в records
+func freshGlucose(repo repository.GlucoseRepository, userID uint, records []models.GlucoseRecord) ([]models.GlucoseRecord, int, error) {
+	if len(records) == 0 {
+		return nil, 0, nil
+	}
+	at := func(r *models.GlucoseRecord) time.Time { return r.MeasuredAt }
+	from, to := minuteRange(records, at)
+	existing, err := repo.List(userID, repository.ListQuery{From: from, To: to, Ascending: true}, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	fresh, duplicates := skipDuplicates(existing, records, func(r *models.GlucoseRecord) recordKey {
+		return newRecordKey(r.MeasuredAt, r.Value)
+	})
+	return fresh, duplicates, nil
+}
+
+func completeJob(job *models.ImportJob, imported int) {
+	now := time.Now()
+	job.Status = models.ImportStatusCompleted
+	job.Imported = imported
+	job.CompletedAt = &now
+}
+
+// importError переводит ошибки разбора файла в ошибки проверки поля file
+func importError(err error) error {
+	switch {
+	case errors.Is(err, importer.ErrUnknownFormat):
+		return &ValidationError{Field: "file", Rule: "file_format"}
+	case errors.Is(err, importer.ErrNoReadings):
+		return &ValidationError{Field: "file", Rule: "no_readings"}
+	case errors.Is(err, importer.ErrFileTooLarge):
+		return &ValidationError{Field: "file", Rule: "file_size", Param: fmt.Sprintf("%dMB", importer.MaxFileSize>>20)}
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const importCSV = "Дата;Время;Глюкоза\n" +
+	"14.02.2024;07:30;5,6\n" +
+	"14.02.2024;07:30;5,6\n" + // повтор внутри файла
+	"14.02.2024;13:00;8,9\n" +
+	"14.02.2024;19:00;7,2\n" +
+	"14.02.2024;ночь;6,1\n"
+
+func TestImportService_Run(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(920, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	// Уже есть в дневнике: та же минута и значение
+	_, err = svc.Glucose.CreateRecordAt(user.ID, 8.9, time.Date(2024, 2, 14, 13, 0, 40, 0, time.UTC), "", "")
+	require.NoError(t, err)
+
+	job, err := svc.Import.Run(user.ID, strings.NewReader(importCSV), ImportOptions{Location: time.UTC, Source: models.ImportSourceAPI, FileName: "meter.csv"})
+	require.NoError(t, err)
+
+	assert.NotZero(t, job.ID)
+	assert.Equal(t, models.ImportStatusCompleted, job.Status)
+	assert.Equal(t, "generic", job.Format)
+	assert.Equal(t, "mmol/L", job.Unit)
+	assert.Equal(t, 4, job.Total)
+	assert.Equal(t, 2, job.Imported)
+	assert.Equal(t, 2, job.Duplicates)
+	assert.Equal(t, 1, job.Skipped)
+	require.NotNil(t, job.FirstAt)
+	assert.Equal(t, time.Date(2024, 2, 14, 7, 30, 0, 0, time.UTC), *job.FirstAt)
+	assert.NotNil(t, job.CompletedAt)
+
+	records, err := svc.Glucose.ListRecords(user.ID, ListOptions{}, "")
+	require.NoError(t, err)
+	assert.Len(t, records.Items, 3)
+
+	// Повторная загрузка того же файла ничего не добавляет
+	job, err = svc.Import.Run(user.ID, strings.NewReader(importCSV), ImportOptions{Location: time.UTC})
+	require.NoError(t, err)
+	assert.Zero(t, job.Imported)
+	assert.Equal(t, 4, job.Duplicates)
+}
+
+func TestImportService_DryRunConfirm(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	user, err := svc.Users.GetOrCreateUser(921, "", "Петр", "", "ru")
+	require.NoError(t, err)
+
+	opts := ImportOptions{Location: time.UTC, Source: models.ImportSourceTelegram, FileID: "file-1", DryRun: true}
+	job, err := svc.Import.Run(user.ID, strings.NewReader(importCSV), opts)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportStatusPreview, job.Status)
+	assert.Equal(t, 3, job.Total-job.Duplicates)
+	assert.Zero(t, job.Imported)
+	assert.Nil(t, job.CompletedAt)
+
+	records, err := repos.Glucose.List(user.ID, repository.ListQuery{}, "")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	// Чужой импорт не подтвердить
+	_, err = svc.Import.Confirm(user.ID+1, job.ID, strings.NewReader(importCSV), opts)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	confirmed, err := svc.Import.Confirm(user.ID, job.ID, strings.NewReader(importCSV), opts)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportStatusCompleted, confirmed.Status)
+	assert.Equal(t, 3, confirmed.Imported)
+	assert.Equal(t, "file-1", confirmed.FileID)
+
+	stored, err := svc.Import.GetJob(user.ID, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportStatusCompleted, stored.Status)
+	assert.Equal(t, 3, stored.Imported)
+
+	_, err = svc.Import.Confirm(user.ID, job.ID, strings.NewReader(importCSV), opts)
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.ErrorIs(t, svc.Import.Cancel(user.ID, job.ID), ErrValidation)
+}
+
+// stalePendingJobs видит импорт в предпросмотре: реплика прочитала его до того,
+// как другая подтвердила
+type stalePendingJobs struct {
+	repository.ImportJobRepository
+}
+
+func (r stalePendingJobs) GetByID(userID, id uint) (*models.ImportJob, error) {
+	job, err := r.ImportJobRepository.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	job.Status = models.ImportStatusPreview
+	return job, nil
+}
+
+func TestImportService_ConfirmOnceAcrossReplicas(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	user, err := svc.Users.GetOrCreateUser(923, "", "Ирина", "", "ru")
+	require.NoError(t, err)
+
+	opts := ImportOptions{Location: time.UTC, DryRun: true}
+	job, err := svc.Import.Run(user.ID, strings.NewReader(importCSV), opts)
+	require.NoError(t, err)
+	_, err = svc.Import.Confirm(user.ID, job.ID, strings.NewReader(importCSV), opts)
+	require.NoError(t, err)
+
+	// Новые измерения в файле не спасают: импорт уже подтвержден
+	replica := NewImportService(repos.Glucose, repos.Food, stalePendingJobs{repos.ImportJobs})
+	file := importCSV + "15.02.2024;08:00;6,4\n"
+	_, err = replica.Confirm(user.ID, job.ID, strings.NewReader(file), opts)
+	assert.ErrorIs(t, err, ErrImportNotPending)
+	assert.ErrorIs(t, replica.Cancel(user.ID, job.ID), ErrImportNotPending)
+
+	records, err := repos.Glucose.List(user.ID, repository.ListQuery{}, "")
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+	stored, err := svc.Import.GetJob(user.ID, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportStatusCompleted, stored.Status)
+}
+
+func TestImportService_Cancel(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(922, "", "Ольга", "", "ru")
+	require.NoError(t, err)
+
+	job, err := svc.Import.Run(user.ID, strings.NewReader(importCSV), ImportOptions{Location: time.UTC, DryRun: true})
+	require.NoError(t, err)
+	require.NoError(t, svc.Import.Cancel(user.ID, job.ID))
+
+	stored, err := svc.Import.GetJob(user.ID, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportStatusCancelled, stored.Status)
+}
+
+func TestImportService_Errors(t *testing.T) {
+	svc := New(memory.NewRepositories())
+
+	_, err := svc.Import.Run(1, strings.NewReader("a,b\n1,2\n"), ImportOptions{})
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, "file", verr.Field)
+	assert.Equal(t, "file_format", verr.Rule)
+
+	// Все измерения в будущем — импортировать нечего
+	future := time.Now().AddDate(0, 0, 2).Format("2006-01-02 15:04")
+	_, err = svc.Import.Run(1, strings.NewReader("Date,Glucose\n"+future+",5.5\n"), ImportOptions{})
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, "no_readings", verr.Rule)
+}
@@ -0,0 +1,521 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// Закономерности, которые ищет InsightService
+const (
+	InsightDawnPhenomenon    = "dawn_phenomenon"    // сахар поднимается к утру без ночных гипогликемий
+	InsightNightLows         = "night_lows"         // повторяющиеся гипогликемии ночью
+	InsightBreakfastSpikes   = "breakfast_spikes"   // высокий сахар после завтрака
+	InsightWeekendDifference = "weekend_difference" // в выходные сахар заметно другой, чем в будни
+	InsightFastingTrend      = "fasting_trend"      // сахар натощак растет
+)
+
+// DefaultInsightDays — период поиска закономерностей по умолчанию, в том числе для запросов к ИИ
+const DefaultInsightDays = 30
+
+// Пороги правил. Часы — местное время пользователя.
+const (
+	dawnNightFrom     = -2  // ночь для утренней зари — с 22:00 предыдущего дня
+	dawnNightTo       = 4   // до 04:00
+	morningFrom       = 5   // утро и натощак — с 05:00
+	morningTo         = 9   // до 09:00
+	nightTo           = 6   // ночь для гипогликемий — с полуночи до 06:00
+	breakfastFrom     = 6   // измерения «после еды» без записи о завтраке считаются
+	breakfastTo       = 11  // после завтрака с 06:00 до 11:00
+	dawnRise          = 1.5 // ммоль/л от ночи к утру
+	weekendDifference = 1.0 // ммоль/л между средними выходных и будней
+	fastingTrendRise  = 1.0 // ммоль/л за период по линии тренда
+
+	dawnMinDays         = 3
+	breakfastMinDays    = 3
+	nightLowMinNights   = 2
+	nightLowFullNights  = 4 // с этого числа ночей уверенность полная
+	weekendMinDays      = 2
+	weekdayMinDays      = 4
+	fastingTrendMinDays = 7
+	insightFullDays     = 7  // с этого числа подходящих дней выборка считается достаточной
+	fastingFullDays     = 14 // то же для тренда натощак
+	insightMinShare     = 0.5
+	maxInsightEvidence  = 10
+)
+
+// breakfastWindow — сколько после завтрака ищется пик сахара
+const breakfastWindow = 3 * time.Hour
+
+// InsightEvidence — измерение, на котором основана закономерность
+type InsightEvidence struct {
+	RecordID   uint      `json:"record_id"`
+	Value      float64   `json:"value"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// Insight — найденная закономерность. Confidence от 0 до 1 учитывает, в какой доле
+// подходящих дней признак проявился и хватает ли этих дней для вывода.
+type Insight struct {
+	Kind        string            `json:"kind"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Confidence  float64           `json:"confidence"`
+	Evidence    []InsightEvidence `json:"evidence"`
+}
+
+// InsightReport — закономерности за период [From, To), самые уверенные первыми
+type InsightReport struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Readings int       `json:"readings"`
+	Insights []Insight `json:"insights"`
+}
+
+// InsightOptions задает период анализа. Нулевой To — сейчас, нулевой From —
+// DefaultInsightDays дней до To. Часы суток считаются в Location, nil — часовой
+// пояс пользователя из настроек сводки, а без него — time.Local.
+type InsightOptions struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+}
+
+// InsightService ищет в истории глюкозы повторяющиеся закономерности по простым
+// правилам, чтобы каждую можно было проверить по приведенным измерениям
+type InsightService struct {
+	glucose repository.GlucoseRepository
+	food    repository.FoodRepository
+	digests repository.DigestRepository
+	now     func() time.Time
+}
+
+func NewInsightService(glucose repository.GlucoseRepository, food repository.FoodRepository, digests repository.DigestRepository) *InsightService {
+	return &InsightService{glucose: glucose, food: food, digests: digests, now: time.Now}
+}
+
+// Analyze ищет закономерности за период
+func (s *InsightService) Analyze(userID uint, opts InsightOptions) (*InsightReport, error) {
+	if opts.To.IsZero() {
+		opts.To = s.now()
+	}
+	if opts.From.IsZero() {
+		opts.From = opts.To.AddDate(0, 0, -DefaultInsightDays)
+	}
+	if !opts.From.Before(opts.To) {
+		return nil, &ValidationError{Field: "from", Rule: "ltfield", Param: "to"}
+	}
+	loc := opts.Location
+	if loc == nil {
+		// «Ночь» и «утро» — по часам пользователя, а не сервера
+		sub, err := s.digests.GetSubscription(userID)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			loc = time.Local
+		case err != nil:
+			return nil, err
+		default:
+			loc = digestLocation(sub)
+		}
+	}
+
+	// Ночь перед первым днем нужна для утренней зари
+	records, err := s.glucose.List(userID, repository.ListQuery{
+		From: opts.From.Add(dawnNightFrom * time.Hour), To: opts.To, Ascending: true,
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+	breakfasts, err := s.food.List(userID, repository.ListQuery{From: opts.From, To: opts.To, Ascending: true}, "завтрак")
+	if err != nil {
+		return nil, err
+	}
+
+	a := insightAnalysis{records: records, breakfasts: breakfasts, days: insightDays(opts.From, opts.To, loc)}
+	report := &InsightReport{From: opts.From, To: opts.To, Readings: len(recordsBetween(records, opts.From, opts.To)), Insights: []Insight{}}
+	for _, rule := range []func() *Insight{a.dawnPhenomenon, a.nightLows, a.breakfastSpikes, a.weekendDifference, a.fastingTrend} {
+		if insight := rule(); insight != nil {
+			insight.Confidence = math.Round(insight.Confidence*100) / 100
+			insight.Evidence = thinEvidence(insight.Evidence)
+			report.Insights = append(report.Insights, *insight)
+		}
+	}
+	sort.SliceStable(report.Insights, func(i, j int) bool {
+		return report.Insights[i].Confidence > report.Insights[j].Confidence
+	})
+	return report, nil
+}
+
+// AIContext описывает закономерности за последние DefaultInsightDays дней для запроса к ИИ;
+// пустая строка — закономерностей нет
+func (s *InsightService) AIContext(user *models.User) string {
+	report, err := s.Analyze(user.ID, InsightOptions{})
+	if err != nil {
+		log.Printf("Error analyzing insights for user %d: %v", user.ID, err)
+		return ""
+	}
+	if len(report.Insights) == 0 {
+		return ""
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "Закономерности в дневнике за %d дней (найдены автоматически, учитывай их в ответе):", DefaultInsightDays)
+	for _, insight := range report.Insights {
+		fmt.Fprintf(&text, "\n- %s: %s (уверенность %.0f%%)", insight.Title, insight.Description, insight.Confidence*100)
+	}
+	return text.String()
+}
+
+// insightAnalysis — измерения по возрастанию времени, завтраки и местные полуночи дней периода
+type insightAnalysis struct {
+	records    []models.GlucoseRecord
+	breakfasts []models.FoodRecord
+	days       []time.Time
+}
+
+// dawnPhenomenon — утром сахар выше, чем ночью, хотя ночью гипогликемии не было.
+// Ночь с гипогликемией не учитывается: подъем после нее — откат, а не утренняя заря.
+func (a *insightAnalysis) dawnPhenomenon() *Insight {
+	var eligible, hits int
+	var rise float64
+	var evidence []InsightEvidence
+	for _, day := range a.days {
+		night := recordsBetween(a.records, atHour(day, dawnNightFrom), atHour(day, dawnNightTo))
+		morning := recordsBetween(a.records, atHour(day, morningFrom), atHour(day, morningTo))
+		if len(night) == 0 || len(morning) == 0 {
+			continue
+		}
+		if low, ok := lowestRecord(recordsBetween(a.records, atHour(day, dawnNightFrom), atHour(day, nightTo))); ok && low.Value < GlucoseLow {
+			continue
+		}
+		eligible++
+		before, after := night[len(night)-1], morning[0]
+		if after.Value-before.Value >= dawnRise {
+			hits++
+			rise += after.Value - before.Value
+			evidence = append(evidence, insightEvidence(before), insightEvidence(after))
+		}
+	}
+	if hits < dawnMinDays || share(hits, eligible) < insightMinShare {
+		return nil
+	}
+	return &Insight{
+		Kind:  InsightDawnPhenomenon,
+		Title: "Утренний подъем сахара",
+		Description: fmt.Sprintf("В %d из %d дней сахар от ночи к утру поднимался в среднем на %.1f ммоль/л без ночных гипогликемий. Похоже на феномен утренней зари — стоит обсудить с врачом.",
+			hits, eligible, rise/float64(hits)),
+		Confidence: share(hits, eligible) * sampleFactor(eligible, insightFullDays),
+		Evidence:   evidence,
+	}
+}
+
+// nightLows — гипогликемии с полуночи до 06:00 хотя бы в двух разных ночах
+func (a *insightAnalysis) nightLows() *Insight {
+	var observed, hits int
+	var evidence []InsightEvidence
+	for _, day := range a.days {
+		low, ok := lowestRecord(recordsBetween(a.records, day, atHour(day, nightTo)))
+		if !ok {
+			continue
+		}
+		observed++
+		if low.Value < GlucoseLow {
+			hits++
+			evidence = append(evidence, insightEvidence(low))
+		}
+	}
+	if hits < nightLowMinNights {
+		return nil
+	}
+	return &Insight{
+		Kind:  InsightNightLows,
+		Title: "Ночные гипогликемии",
+		Description: fmt.Sprintf("Сахар ниже %.1f ммоль/л ночью в %d из %d ночей с измерениями. Ночные гипогликемии опасны — обсудите их с врачом.",
+			GlucoseLow, hits, observed),
+		Confidence: sampleFactor(hits, nightLowFullNights),
+		Evidence:   evidence,
+	}
+}
+
+// breakfastSpikes — пик сахара после завтрака выше диапазона. После еды смотрятся три
+// часа от записи о завтраке, а в дни без нее — измерения «после еды» с 06:00 до 11:00.
+func (a *insightAnalysis) breakfastSpikes() *Insight {
+	var eligible, hits int
+	var peaks float64
+	var evidence []InsightEvidence
+	for i, day := range a.days {
+		next := atHour(day, 24)
+		if i+1 < len(a.days) {
+			next = a.days[i+1]
+		}
+		var after []models.GlucoseRecord
+		for _, meal := range a.breakfasts {
+			if !meal.ConsumedAt.Before(day) && meal.ConsumedAt.Before(next) {
+				after = append(after, recordsBetween(a.records, meal.ConsumedAt.Add(time.Nanosecond), meal.ConsumedAt.Add(breakfastWindow))...)
+			}
+		}
+		if after == nil {
+			for _, r := range recordsBetween(a.records, atHour(day, breakfastFrom), atHour(day, breakfastTo)) {
+				if r.MeasurementContext == models.GlucoseContextAfterMeal {
+					after = append(after, r)
+				}
+			}
+		}
+		peak, ok := highestRecord(after)
+		if !ok {
+			continue
+		}
+		eligible++
+		if peak.Value > GlucoseHigh {
+			hits++
+			peaks += peak.Value
+			evidence = append(evidence, insightEvidence(peak))
+		}
+	}
+	if hits < breakfastMinDays || share(hits, eligible) < insightMinShare {
+		return nil
+	}
+	return &Insight{
+		Kind:  InsightBreakfastSpikes,
+		Title: "Высокий сахар после завтрака",
+		Description: fmt.Sprintf("В %d из %d дней сахар после завтрака поднимался выше %.0f ммоль/л, в среднем до %.1f. Стоит пересмотреть углеводы на завтрак или время болюса.",
+			hits, eligible, GlucoseHigh, peaks/float64(hits)),
+		Confidence: share(hits, eligible) * sampleFactor(eligible, insightFullDays),
+		Evidence:   evidence,
+	}
+}
+
+// weekendDifference сравнивает средние по дням: так плотные показания сенсора
+// в отдельные дни не перевешивают остальные
+func (a *insightAnalysis) weekendDifference() *Insight {
+	type group struct {
+		days    int
+		sum     float64
+		records []models.GlucoseRecord
+	}
+	var weekend, weekday group
+	for i, day := range a.days {
+		next := atHour(day, 24)
+		if i+1 < len(a.days) {
+			next = a.days[i+1]
+		}
+		records := recordsBetween(a.records, day, next)
+		if len(records) == 0 {
+			continue
+		}
+		g := &weekday
+		if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			g = &weekend
+		}
+		g.days++
+		g.sum += averageValue(records)
+		g.records = append(g.records, records...)
+	}
+	if weekend.days < weekendMinDays || weekday.days < weekdayMinDays {
+		return nil
+	}
+	weekendAvg, weekdayAvg := weekend.sum/float64(weekend.days), weekday.sum/float64(weekday.days)
+	diff := weekendAvg - weekdayAvg
+	if math.Abs(diff) < weekendDifference {
+		return nil
+	}
+
+	// Доказательства — самые высокие измерения той группы, где сахар выше
+	higher, word := weekend.records, "выше"
+	if diff < 0 {
+		higher, word = weekday.records, "ниже"
+	}
+	top := append([]models.GlucoseRecord(nil), higher...)
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Value > top[j].Value })
+	if len(top) > maxInsightEvidence {
+		top = top[:maxInsightEvidence]
+	}
+	sort.SliceStable(top, func(i, j int) bool { return top[i].MeasuredAt.Before(top[j].MeasuredAt) })
+	evidence := make([]InsightEvidence, 0, len(top))
+	for _, r := range top {
+		evidence = append(evidence, insightEvidence(r))
+	}
+
+	return &Insight{
+		Kind:  InsightWeekendDifference,
+		Title: "Выходные отличаются от будней",
+		Description: fmt.Sprintf("В выходные средний сахар %.1f ммоль/л, в будни %.1f — на %.1f %s (%d выходных и %d будних дней). Обратите внимание на режим питания и активности в выходные.",
+			weekendAvg, weekdayAvg, math.Abs(diff), word, weekend.days, weekday.days),
+		Confidence: math.Min(1, math.Abs(diff)/(2*weekendDifference)) * sampleFactor(weekend.days, 2*weekendMinDays),
+		Evidence:   evidence,
+	}
+}
+
+// fastingTrend — линия тренда сахара натощак поднялась за период не меньше чем на
+// fastingTrendRise. Натощак — первое измерение дня с таким контекстом, а без него —
+// первое измерение с 05:00 до 09:00.
+func (a *insightAnalysis) fastingTrend() *Insight {
+	var points []models.GlucoseRecord
+	var xs []float64
+	for _, day := range a.days {
+		fasting, ok := models.GlucoseRecord{}, false
+		for _, r := range recordsBetween(a.records, day, atHour(day, 24)) {
+			if r.MeasurementContext == models.GlucoseContextFasting {
+				fasting, ok = r, true
+				break
+			}
+		}
+		if !ok {
+			if window := recordsBetween(a.records, atHour(day, morningFrom), atHour(day, morningTo)); len(window) > 0 {
+				fasting, ok = window[0], true
+			}
+		}
+		if !ok {
+			continue
+		}
+		points = append(points, fasting)
+		xs = append(xs, fasting.MeasuredAt.Sub(a.days[0]).Hours()/24)
+	}
+	if len(points) < fastingTrendMinDays || xs[len(xs)-1]-xs[0] < fastingTrendMinDays-1 {
+		return nil
+	}
+
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		ys[i] = p.Value
+	}
+	slope, intercept, r2 := linearFit(xs, ys)
+	start, end := intercept+slope*xs[0], intercept+slope*xs[len(xs)-1]
+	if end-start < fastingTrendRise {
+		return nil
+	}
+	evidence := make([]InsightEvidence, 0, len(points))
+	for _, p := range points {
+		evidence = append(evidence, insightEvidence(p))
+	}
+	return &Insight{
+		Kind:  InsightFastingTrend,
+		Title: "Растет сахар натощак",
+		Description: fmt.Sprintf("По %d утренним измерениям натощак сахар по линии тренда вырос с %.1f до %.1f ммоль/л за %.0f дн. Покажите динамику врачу.",
+			len(points), start, end, math.Round(xs[len(xs)-1]-xs[0])),
+		Confidence: r2 * sampleFactor(len(points), fastingFullDays),
+		Evidence:   evidence,
+	}
+}
+
+// insightDays возвращает местные полуночи дней, пересекающихся с [from, to)
+func insightDays(from, to time.Time, loc *time.Location) []time.Time {
+	var days []time.Time
+	for day := startOfDay(from.In(loc)); day.Before(to); day = atHour(day, 24) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// atHour возвращает hour часов дня day по его часовому поясу; отрицательные часы —
+// вечер предыдущего дня, 24 — полночь следующего
+func atHour(day time.Time, hour int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, day.Location())
+}
+
+// recordsBetween возвращает измерения из [from, to); records упорядочены по времени
+func recordsBetween(records []models.GlucoseRecord, from, to time.Time) []models.GlucoseRecord {
+	i := sort.Search(len(records), func(i int) bool { return !records[i].MeasuredAt.Before(from) })
+	j := sort.Search(len(records), func(i int) bool { return !records[i].MeasuredAt.Before(to) })
+	if j < i {
+		j = i
+	}
+	return records[i:j]
+}
+
+func lowestRecord(records []models.GlucoseRecord) (models.GlucoseRecord, bool) {
+	if len(records) == 0 {
+		return models.GlucoseRecord{}, false
+	}
+	low := records[0]
+	for _, r := range records[1:] {
+		if r.Value < low.Value {
+			low = r
+		}
+	}
+	return low, true
+}
+
+func highestRecord(records []models.GlucoseRecord) (models.GlucoseRecord, bool) {
+	if len(records) == 0 {
+		return models.GlucoseRecord{}, false
+	}
+	high := records[0]
+	for _, r := range records[1:] {
+		if r.Value > high.Value {
+			high = r
+		}
+	}
+	return high, true
+}
+
+func averageValue(records []models.GlucoseRecord) float64 {
+	var sum float64
+	for _, r := range records {
+		sum += r.Value
+	}
+	return sum / float64(len(records))
+}
+
+func insightEvidence(r models.GlucoseRecord) InsightEvidence {
+	return InsightEvidence{RecordID: r.ID, Value: r.Value, MeasuredAt: r.MeasuredAt}
+}
+
+// share — доля hits из total
+func share(hits, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// sampleFactor снижает уверенность, пока подходящих дней меньше full
+func sampleFactor(n, full int) float64 {
+	return math.Min(1, float64(n)/float64(full))
+}
+
+// thinEvidence оставляет не больше maxInsightEvidence измерений, равномерно по
+// периоду, с первым и последним
+func thinEvidence(evidence []InsightEvidence) []InsightEvidence {
+	if len(evidence) <= maxInsightEvidence {
+		return evidence
+	}
+	thinned := make([]InsightEvidence, maxInsightEvidence)
+	for i := range thinned {
+		thinned[i] = evidence[i*(len(evidence)-1)/(maxInsightEvidence-1)]
+	}
+	return thinned
+}
+
+// linearFit — прямая y = intercept + slope*x по методу наименьших квадратов и
+// коэффициент детерминации r2
+func linearFit(xs, ys []float64) (slope, intercept, r2 float64) {
+	n := float64(len(xs))
+	var sx, sy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+	}
+	mx, my := sx/n, sy/n
+	var sxx, sxy, syy float64
+	for i := range xs {
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		syy += (ys[i] - my) * (ys[i] - my)
+	}
+	if sxx == 0 {
+		return 0, my, 0
+	}
+	slope = sxy / sxx
+	intercept = my - slope*mx
+	if syy > 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+	return slope, intercept, r2
+}
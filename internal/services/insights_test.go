@@ -0,0 +1,146 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsightService_Analyze(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(980, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	// Две недели с 1 мая 2024 (среда): вечером 6.0, утром натощак все выше,
+	// после завтрака выше 10, в выходные днем еще и 15.0
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 14)
+	for d := 0; d < 14; d++ {
+		day := from.AddDate(0, 0, d)
+		_, err := svc.Glucose.CreateRecordAt(user.ID, 6.0, day.Add(-time.Hour), "bedtime", "")
+		require.NoError(t, err)
+		_, err = svc.Glucose.CreateRecordAt(user.ID, 8.0+0.15*float64(d), day.Add(7*time.Hour), "fasting", "")
+		require.NoError(t, err)
+		_, err = svc.Food.CreateRecordAt(user.ID, "Каша", "завтрак", nil, nil, "", "", day.Add(7*time.Hour+30*time.Minute))
+		require.NoError(t, err)
+		_, err = svc.Glucose.CreateRecordAt(user.ID, 11.5, day.Add(9*time.Hour+30*time.Minute), "after_meal", "")
+		require.NoError(t, err)
+		if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			_, err = svc.Glucose.CreateRecordAt(user.ID, 15.0, day.Add(14*time.Hour), "", "")
+			require.NoError(t, err)
+		}
+	}
+
+	report, err := svc.Insights.Analyze(user.ID, InsightOptions{From: from, To: to, Location: time.UTC})
+	require.NoError(t, err)
+	// Вечернее измерение перед первым днем учитывается только для утренней зари
+	assert.Equal(t, 45, report.Readings)
+
+	found := map[string]Insight{}
+	for _, insight := range report.Insights {
+		found[insight.Kind] = insight
+		assert.NotEmpty(t, insight.Evidence, insight.Kind)
+		assert.LessOrEqual(t, len(insight.Evidence), maxInsightEvidence, insight.Kind)
+	}
+	require.Len(t, found, 4)
+	assert.NotContains(t, found, InsightNightLows)
+
+	dawn := found[InsightDawnPhenomenon]
+	assert.Equal(t, 1.0, dawn.Confidence)
+	assert.Contains(t, dawn.Description, "В 14 из 14 дней")
+	assert.Len(t, dawn.Evidence, maxInsightEvidence)
+	assert.Equal(t, 6.0, dawn.Evidence[0].Value)
+	assert.Equal(t, 9.95, dawn.Evidence[len(dawn.Evidence)-1].Value)
+
+	assert.Equal(t, 1.0, found[InsightBreakfastSpikes].Confidence)
+	assert.Equal(t, 11.5, found[InsightBreakfastSpikes].Evidence[0].Value)
+
+	trend := found[InsightFastingTrend]
+	assert.Equal(t, 1.0, trend.Confidence)
+	assert.Contains(t, trend.Description, "с 8.0 до 9.9 ммоль/л за 13 дн.")
+	assert.Len(t, trend.Evidence, maxInsightEvidence)
+
+	weekend := found[InsightWeekendDifference]
+	assert.Contains(t, weekend.Description, "4 выходных и 10 будних дней")
+	assert.Equal(t, 0.71, weekend.Confidence)
+	weekendHighs := 0
+	for _, e := range weekend.Evidence {
+		if e.Value == 15.0 {
+			weekendHighs++
+		}
+	}
+	assert.Equal(t, 4, weekendHighs)
+
+	// Самые уверенные находки — первыми
+	assert.Equal(t, InsightWeekendDifference, report.Insights[len(report.Insights)-1].Kind)
+
+	_, err = svc.Insights.Analyze(user.ID, InsightOptions{From: to, To: from})
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestInsightService_NightLowsAndAIContext(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.Local)
+	svc.Insights.now = func() time.Time { return now }
+	user, err := svc.Users.GetOrCreateUser(981, "", "Иван", "", "ru")
+	require.NoError(t, err)
+
+	assert.Empty(t, svc.Insights.AIContext(user))
+
+	// Три ночи с измерениями в 03:00, две из них с гипогликемией, и подъемом утром после них
+	for d, value := range []float64{3.2, 5.5, 3.5} {
+		night := time.Date(2024, 5, 5+d, 3, 0, 0, 0, time.Local)
+		_, err := svc.Glucose.CreateRecordAt(user.ID, value, night, "", "")
+		require.NoError(t, err)
+		_, err = svc.Glucose.CreateRecordAt(user.ID, value+4, night.Add(4*time.Hour), "", "")
+		require.NoError(t, err)
+	}
+
+	report, err := svc.Insights.Analyze(user.ID, InsightOptions{})
+	require.NoError(t, err)
+	require.Len(t, report.Insights, 1)
+	lows := report.Insights[0]
+	assert.Equal(t, InsightNightLows, lows.Kind)
+	assert.Equal(t, 0.5, lows.Confidence)
+	assert.Contains(t, lows.Description, "в 2 из 3 ночей")
+	require.Len(t, lows.Evidence, 2)
+	assert.Equal(t, 3.2, lows.Evidence[0].Value)
+	assert.Equal(t, 3.5, lows.Evidence[1].Value)
+
+	context := svc.Insights.AIContext(user)
+	assert.Contains(t, context, "Закономерности в дневнике за 30 дней")
+	assert.Contains(t, context, "- Ночные гипогликемии: ")
+	assert.Contains(t, withPatientContext(svc.Insights, user, "Вопрос"), "Вопрос\n\nЗакономерности")
+}
+
+func TestInsightService_UserTimezone(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	vladivostok, err := time.LoadLocation("Asia/Vladivostok")
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, vladivostok)
+	svc.Insights.now = func() time.Time { return now }
+	user, err := svc.Users.GetOrCreateUser(982, "", "Иван", "", "ru")
+	require.NoError(t, err)
+
+	// Гипогликемии в 03:00 по Владивостоку — это 17:00 UTC, днем по часам сервера
+	for d := 0; d < 3; d++ {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, 3.2, time.Date(2024, 5, 5+d, 3, 0, 0, 0, vladivostok).UTC(), "", "")
+		require.NoError(t, err)
+	}
+	report, err := svc.Insights.Analyze(user.ID, InsightOptions{Location: time.UTC})
+	require.NoError(t, err)
+	assert.Empty(t, report.Insights)
+
+	// Часовой пояс из настроек сводки учитывается и в отчете, и в контексте для ИИ
+	_, err = svc.Digests.UpdateSettings(user.ID, false, "Asia/Vladivostok")
+	require.NoError(t, err)
+	report, err = svc.Insights.Analyze(user.ID, InsightOptions{})
+	require.NoError(t, err)
+	require.Len(t, report.Insights, 1)
+	assert.Equal(t, InsightNightLows, report.Insights[0].Kind)
+	assert.Contains(t, svc.Insights.AIContext(user), "- Ночные гипогликемии: ")
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"time"
+)
+
+type InsulinService struct {
+	repo repository.InsulinRepository
+}
+
+func NewInsulinService(repo repository.InsulinRepository) *InsulinService {
+	return &InsulinService{repo: repo}
+}
+
+func (s *InsulinService) CreateRecord(userID uint, units float64, insulinType, insulinName string, injectedAt time.Time, notes string) (*models.InsulinRecord, error) {
+	record := models.InsulinRecord{
+		UserID:      userID,
+		Units:       units,
+		InsulinType: insulinType,
+		InsulinName: insulinName,
+		InjectedAt:  injectedAt,
+		Notes:       notes,
+	}
+
+	if err := s.repo.Create(&record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (s *InsulinService) GetUserRecords(userID uint, days int) ([]models.InsulinRecord, error) {
+	return s.repo.ListSince(userID, time.Now().AddDate(0, 0, -days))
+}
+
+func (s *InsulinService) GetRecord(userID, recordID uint) (*models.InsulinRecord, error) {
+	return s.repo.GetByID(userID, recordID)
+}
+
+func (s *InsulinService) DeleteRecord(userID, recordID uint) error {
+	return s.repo.Delete(userID, recordID)
+}
+
+func (s *InsulinService) UpdateRecord(userID, recordID uint, updates map[string]interface{}) error {
+	return s.repo.Update(userID, recordID, updates)
+}
+
+// RestoreRecord восстанавливает запись, удаленную не раньше deletedAfter
+func (s *InsulinService) RestoreRecord(userID, recordID uint, deletedAfter time.Time) error {
+	return s.repo.Restore(userID, recordID, deletedAfter)
+}
+
+func (s *InsulinService) DeleteAllUserRecords(userID uint) error {
+	return s.repo.DeleteAllByUser(userID)
+}
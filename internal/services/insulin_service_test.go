@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestInsulinService_CreateRecord(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewInsulinService(repository.NewGormInsulinRepository(db))
+	user := testutils.CreateTestUser(db, 123)
+	injectedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	record, err := service.CreateRecord(user.ID, 6, models.InsulinTypeBolus, "Хумалог", injectedAt, "на обед")
+	require.NoError(t, err)
+	assert.NotZero(t, record.ID)
+
+	stored, err := service.GetRecord(user.ID, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, stored.Units)
+	assert.Equal(t, models.InsulinTypeBolus, stored.InsulinType)
+	assert.Equal(t, "Хумалог", stored.InsulinName)
+	assert.True(t, injectedAt.Equal(stored.InjectedAt))
+	assert.Equal(t, "на обед", stored.Notes)
+}
+
+func TestInsulinService_GetUserRecords(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewInsulinService(repository.NewGormInsulinRepository(db))
+	user := testutils.CreateTestUser(db, 123)
+	otherUser := testutils.CreateTestUser(db, 456)
+
+	_, err := service.CreateRecord(user.ID, 20, models.InsulinTypeBasal, "Лантус", time.Now().AddDate(0, 0, -10), "")
+	require.NoError(t, err)
+	_, err = service.CreateRecord(user.ID, 4, models.InsulinTypeBolus, "", time.Now().Add(-2*time.Hour), "")
+	require.NoError(t, err)
+	_, err = service.CreateRecord(user.ID, 18, models.InsulinTypeBasal, "Лантус", time.Now().Add(-time.Hour), "")
+	require.NoError(t, err)
+	_, err = service.CreateRecord(otherUser.ID, 5, models.InsulinTypeBolus, "", time.Now(), "")
+	require.NoError(t, err)
+
+	records, err := service.GetUserRecords(user.ID, 7)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	// Сначала новые записи
+	assert.Equal(t, 18.0, records[0].Units)
+	assert.Equal(t, 4.0, records[1].Units)
+}
+
+func TestInsulinService_DeleteAndRestoreRecord(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewInsulinService(repository.NewGormInsulinRepository(db))
+	user := testutils.CreateTestUser(db, 123)
+	record, err := service.CreateRecord(user.ID, 6, models.InsulinTypeBolus, "", time.Now(), "")
+	require.NoError(t, err)
+
+	require.NoError(t, service.DeleteRecord(user.ID, record.ID))
+	_, err = service.GetRecord(user.ID, record.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	err = service.RestoreRecord(user.ID, record.ID, time.Now().Add(time.Minute))
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, service.RestoreRecord(user.ID, record.ID, time.Now().Add(-time.Minute)))
+	restored, err := service.GetRecord(user.ID, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, restored.Units)
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+const (
+	// HbA1cGlucoseDays — за сколько дней до анализа глюкоза из дневника сравнивается с HbA1c
+	HbA1cGlucoseDays = 90
+	// minGMIDays — меньше дней с измерениями GMI не считается: по нескольким дням он случаен
+	minGMIDays = 14
+	// hba1cDiscordance — расхождение HbA1c и GMI, процентных пунктов, при котором о нем стоит сказать
+	hba1cDiscordance = 0.5
+	// hba1cReportAge — в отчет попадает HbA1c, сданный не раньше чем за полгода до конца периода
+	hba1cReportAge = 183 * 24 * time.Hour
+)
+
+// unitRange — допустимая единица показателя и диапазон значений в ней
+type unitRange struct {
+	unit     string
+	min, max float64
+}
+
+// checkUnitRange находит единицу без учета регистра и проверяет значение по ее диапазону.
+// Пустая единица — первая из ranges. Возвращает каноническое написание единицы.
+func checkUnitRange(ranges []unitRange, unit string, value float64) (string, error) {
+	if unit == "" {
+		unit = ranges[0].unit
+	}
+	allowed := make([]string, len(ranges))
+	for i, r := range ranges {
+		allowed[i] = r.unit
+		if !strings.EqualFold(unit, r.unit) {
+			continue
+		}
+		if value < r.min || value > r.max {
+			return "", &ValidationError{Field: "value", Rule: "between", Param: fmt.Sprintf("%g %g", r.min, r.max)}
+		}
+		return r.unit, nil
+	}
+	return "", &ValidationError{Field: "unit", Rule: "oneof", Param: strings.Join(allowed, " ")}
+}
+
+// labUnits — единицы и диапазоны по видам анализа; первая единица — основная.
+// У custom единица свободная, диапазон не проверяется.
+var labUnits = map[string][]unitRange{
+	models.LabKindHbA1c: {
+		{models.LabUnitPercent, 3, 20},
+		{models.LabUnitMmolMol, 9, 195},
+	},
+	models.LabKindCholesterol: {
+		{models.LabUnitMmolL, 1, 20},
+		{models.LabUnitMgDL, 40, 770},
+	},
+	models.LabKindCreatinine: {
+		{models.LabUnitMicromolL, 10, 2000},
+		{models.LabUnitMgDL, 0.1, 22},
+	},
+}
+
+// LabInput — результат анализа. Пустой Unit — основная единица вида анализа,
+// нулевой TakenAt — текущий момент. Name обязателен только для custom.
+type LabInput struct {
+	Kind    string
+	Name    string
+	Value   float64
+	Unit    string
+	TakenAt time.Time
+	Notes   string
+}
+
+// HbA1cPercent переводит HbA1c в проценты NGSP; mmol/mol (IFCC) пересчитывается
+// по формуле NGSP = IFCC / 10.929 + 2.15
+func HbA1cPercent(value float64, unit string) float64 {
+	if unit == models.LabUnitMmolMol {
+		return round1(value/10.929 + 2.15)
+	}
+	return value
+}
+
+// HbA1cComparison — измеренный HbA1c рядом с GMI по глюкозе за HbA1cGlucoseDays дней до анализа
+type HbA1cComparison struct {
+	Result     models.LabResult `json:"result"`
+	HbA1c      float64          `json:"hba1c"`      // %, пересчитан из mmol/mol при необходимости
+	GMI        *float64         `json:"gmi"`        // %, nil — дней с измерениями меньше minGMIDays
+	Difference *float64         `json:"difference"` // HbA1c − GMI, процентных пунктов
+	Readings   int              `json:"readings"`   // измерений глюкозы в окне
+	Days       int              `json:"days"`       // дней с измерениями в окне
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+}
+
+// GMIText — GMI до анализа и разница с HbA1c для строки таблицы отчета
+func (c *HbA1cComparison) GMIText() string {
+	if c.GMI == nil {
+		return fmt.Sprintf("— (измерения за %d дн.)", c.Days)
+	}
+	return fmt.Sprintf("%.1f%% (разница %+.1f)", *c.GMI, *c.Difference)
+}
+
+// Summary описывает сравнение одной-двумя строками для бота и отчетов
+func (c *HbA1cComparison) Summary() string {
+	text := fmt.Sprintf("HbA1c %.1f%% от %s", c.HbA1c, c.Result.TakenAt.Format("02.01.2006"))
+	if c.GMI == nil {
+		return text + fmt.Sprintf(", GMI не рассчитан: измерения есть только за %d дн. из %d до анализа",
+			c.Days, HbA1cGlucoseDays)
+	}
+	text += fmt.Sprintf(", GMI по дневнику за %d дн. до анализа %.1f%% (разница %+.1f)",
+		HbA1cGlucoseDays, *c.GMI, *c.Difference)
+	if math.Abs(*c.Difference) >= hba1cDiscordance {
+		text += "\nРасхождение заметное: измерений могло быть мало или они приходятся на одно время суток; " +
+			"на HbA1c влияют также анемия и другие состояния крови. Обсудите с врачом."
+	}
+	return text
+}
+
+// LabService хранит результаты лабораторных анализов и сравнивает HbA1c с глюкозой из дневника
+type LabService struct {
+	repo    repository.LabRepository
+	glucose repository.GlucoseRepository
+	now     func() time.Time
+}
+
+func NewLabService(repo repository.LabRepository, glucose repository.GlucoseRepository) *LabService {
+	return &LabService{repo: repo, glucose: glucose, now: time.Now}
+}
+
+// Create проверяет и сохраняет результат анализа
+func (s *LabService) Create(userID uint, input LabInput) (*models.LabResult, error) {
+	result, err := s.validate(input)
+	if err != nil {
+		return nil, err
+	}
+	result.UserID = userID
+	if err := s.repo.Create(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// List возвращает страницу результатов; пустой kind не фильтрует
+func (s *LabService) List(userID uint, opts ListOptions, kind string) (*Page[models.LabResult], error) {
+	if kind != "" && !models.IsLabKind(kind) {
+		return nil, labKindError()
+	}
+	query, err := opts.query()
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.repo.List(userID, query, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPage(results, query, func(r *models.LabResult) repository.Cursor {
+		return repository.Cursor{At: r.TakenAt, ID: r.ID}
+	}), nil
+}
+
+func (s *LabService) Get(userID, id uint) (*models.LabResult, error) {
+	return s.repo.GetByID(userID, id)
+}
+
+// Update проверяет и целиком перезаписывает результат анализа
+func (s *LabService) Update(userID, id uint, input LabInput) (*models.LabResult, error) {
+	if _, err := s.repo.GetByID(userID, id); err != nil {
+		return nil, err
+	}
+	result, err := s.validate(input)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.Update(userID, id, map[string]interface{}{
+		"kind":     result.Kind,
+		"name":     result.Name,
+		"value":    result.Value,
+		"unit":     result.Unit,
+		"taken_at": result.TakenAt,
+		"notes":    result.Notes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(userID, id)
+}
+
+func (s *LabService) Delete(userID, id uint) error {
+	if _, err := s.repo.GetByID(userID, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(userID, id)
+}
+
+func (s *LabService) DeleteAllUserResults(userID uint) error {
+	return s.repo.DeleteAllByUser(userID)
+}
+
+// CompareHbA1c сравнивает последний HbA1c, сданный раньше before, с GMI по глюкозе
+// за HbA1cGlucoseDays дней до анализа. Нулевой before — последний вообще.
+// ErrNotFound — HbA1c еще не сдавался.
+func (s *LabService) CompareHbA1c(userID uint, before time.Time) (*HbA1cComparison, error) {
+	results, err := s.repo.List(userID, repository.ListQuery{To: before, Limit: 1}, models.LabKindHbA1c)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return s.CompareResult(userID, results[0])
+}
+
+// CompareResult сравнивает сохраненный HbA1c с GMI по глюкозе за HbA1cGlucoseDays дней до него
+func (s *LabService) CompareResult(userID uint, result models.LabResult) (*HbA1cComparison, error) {
+	comparison := &HbA1cComparison{
+		Result: result,
+		HbA1c:  HbA1cPercent(result.Value, result.Unit),
+		From:   result.TakenAt.AddDate(0, 0, -HbA1cGlucoseDays),
+		To:     result.TakenAt,
+	}
+	glucose, err := s.glucose.List(userID, repository.ListQuery{From: comparison.From, To: comparison.To, Ascending: true}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	days := map[string]bool{}
+	var sum float64
+	for _, r := range glucose {
+		sum += r.Value
+		days[r.MeasuredAt.In(result.TakenAt.Location()).Format("2006-01-02")] = true
+	}
+	comparison.Readings = len(glucose)
+	comparison.Days = len(days)
+	if comparison.Days >= minGMIDays {
+		gmi := round1(GMI(sum / float64(len(glucose))))
+		difference := round1(comparison.HbA1c - gmi)
+		comparison.GMI, comparison.Difference = &gmi, &difference
+	}
+	return comparison, nil
+}
+
+// Latest возвращает сравнение для последнего сданного HbA1c или nil, если его нет
+func (s *LabService) Latest(userID uint) (*HbA1cComparison, error) {
+	comparison, err := s.CompareHbA1c(userID, time.Time{})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return comparison, err
+}
+
+// validate проверяет вид, единицу и значение анализа и приводит единицу к каноническому написанию
+func (s *LabService) validate(input LabInput) (*models.LabResult, error) {
+	result := &models.LabResult{
+		Kind:    input.Kind,
+		Name:    strings.TrimSpace(input.Name),
+		Value:   input.Value,
+		Unit:    strings.TrimSpace(input.Unit),
+		TakenAt: input.TakenAt,
+		Notes:   input.Notes,
+	}
+	if !models.IsLabKind(result.Kind) {
+		return nil, labKindError()
+	}
+	if result.TakenAt.IsZero() {
+		result.TakenAt = s.now()
+	}
+	if result.TakenAt.After(s.now().Add(time.Hour)) {
+		return nil, &ValidationError{Field: "taken_at", Rule: "not_future"}
+	}
+	if utf8.RuneCountInString(result.Name) > 100 {
+		return nil, &ValidationError{Field: "name", Rule: "max", Param: "100"}
+	}
+	if utf8.RuneCountInString(result.Notes) > 500 {
+		return nil, &ValidationError{Field: "notes", Rule: "max", Param: "500"}
+	}
+
+	if result.Kind == models.LabKindCustom {
+		switch {
+		case result.Name == "":
+			return nil, &ValidationError{Field: "name", Rule: "required"}
+		case result.Unit == "":
+			return nil, &ValidationError{Field: "unit", Rule: "required"}
+		case utf8.RuneCountInString(result.Unit) > 20:
+			return nil, &ValidationError{Field: "unit", Rule: "max", Param: "20"}
+		case result.Value < 0:
+			return nil, &ValidationError{Field: "value", Rule: "min", Param: "0"}
+		}
+		return result, nil
+	}
+
+	// У стандартных анализов название задает вид
+	result.Name = ""
+	unit, err := checkUnitRange(labUnits[result.Kind], result.Unit, result.Value)
+	if err != nil {
+		return nil, err
+	}
+	result.Unit = unit
+	return result, nil
+}
+
+func labKindError() error {
+	return &ValidationError{Field: "kind", Rule: "oneof", Param: strings.Join([]string{
+		models.LabKindHbA1c, models.LabKindCholesterol, models.LabKindCreatinine, models.LabKindCustom,
+	}, " ")}
+}
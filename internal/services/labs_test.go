@@ -0,0 +1,131 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabService_Validation(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	svc.Labs.now = func() time.Time { return now }
+
+	result, err := svc.Labs.Create(1, LabInput{Kind: models.LabKindHbA1c, Value: 6.8})
+	require.NoError(t, err)
+	assert.Equal(t, models.LabUnitPercent, result.Unit)
+	assert.Equal(t, now, result.TakenAt)
+
+	result, err = svc.Labs.Create(1, LabInput{Kind: models.LabKindCreatinine, Name: "лишнее", Value: 1.1, Unit: "MG/DL"})
+	require.NoError(t, err)
+	assert.Equal(t, models.LabUnitMgDL, result.Unit)
+	assert.Empty(t, result.Name)
+
+	tests := map[string]struct {
+		input LabInput
+		field string
+		rule  string
+	}{
+		"UnknownKind":    {LabInput{Kind: "glucose", Value: 5}, "kind", "oneof"},
+		"OutOfRange":     {LabInput{Kind: models.LabKindHbA1c, Value: 68}, "value", "between"},
+		"WrongUnit":      {LabInput{Kind: models.LabKindCholesterol, Value: 5, Unit: "%"}, "unit", "oneof"},
+		"CustomNoName":   {LabInput{Kind: models.LabKindCustom, Value: 5, Unit: "ммоль/л"}, "name", "required"},
+		"CustomNoUnit":   {LabInput{Kind: models.LabKindCustom, Name: "Калий", Value: 5}, "unit", "required"},
+		"CustomNegative": {LabInput{Kind: models.LabKindCustom, Name: "Калий", Value: -1, Unit: "ммоль/л"}, "value", "min"},
+		"FutureDate":     {LabInput{Kind: models.LabKindHbA1c, Value: 6.8, TakenAt: now.AddDate(0, 0, 2)}, "taken_at", "not_future"},
+		"IFCCOutOfRange": {LabInput{Kind: models.LabKindHbA1c, Value: 6.8, Unit: models.LabUnitMmolMol}, "value", "between"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.Labs.Create(1, tt.input)
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, tt.field, verr.Field)
+			assert.Equal(t, tt.rule, verr.Rule)
+		})
+	}
+
+	_, err = svc.Labs.Update(2, result.ID, LabInput{Kind: models.LabKindCreatinine, Value: 90})
+	assert.ErrorIs(t, err, ErrNotFound)
+	updated, err := svc.Labs.Update(1, result.ID, LabInput{Kind: models.LabKindCreatinine, Value: 90, TakenAt: now.AddDate(0, 0, -1)})
+	require.NoError(t, err)
+	assert.Equal(t, models.LabUnitMicromolL, updated.Unit)
+	assert.Equal(t, 90.0, updated.Value)
+
+	page, err := svc.Labs.List(1, ListOptions{}, models.LabKindCreatinine)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	_, err = svc.Labs.List(1, ListOptions{}, "glucose")
+	assert.ErrorIs(t, err, ErrValidation)
+
+	require.NoError(t, svc.Labs.Delete(1, result.ID))
+	assert.ErrorIs(t, svc.Labs.Delete(1, result.ID), ErrNotFound)
+}
+
+func TestLabService_CompareHbA1c(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(990, "", "Анна", "", "ru")
+	require.NoError(t, err)
+	taken := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	comparison, err := svc.Labs.Latest(user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, comparison)
+
+	// 53 mmol/mol = 7.0%; глюкоза 8.6 ммоль/л дает GMI 7.0% — без расхождения
+	_, err = svc.Labs.Create(user.ID, LabInput{Kind: models.LabKindHbA1c, Value: 53, Unit: models.LabUnitMmolMol, TakenAt: taken})
+	require.NoError(t, err)
+	for d := 1; d <= 10; d++ {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, 8.6, taken.AddDate(0, 0, -d).Add(8*time.Hour), "", "")
+		require.NoError(t, err)
+	}
+	// Измерение после анализа в сравнение не попадает
+	_, err = svc.Glucose.CreateRecordAt(user.ID, 20, taken.Add(time.Hour), "", "")
+	require.NoError(t, err)
+
+	comparison, err = svc.Labs.Latest(user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, comparison)
+	assert.Equal(t, 7.0, comparison.HbA1c)
+	assert.Equal(t, 10, comparison.Readings)
+	assert.Equal(t, 10, comparison.Days)
+	assert.Nil(t, comparison.GMI)
+	assert.Contains(t, comparison.Summary(), "GMI не рассчитан: измерения есть только за 10 дн. из 90")
+
+	for d := 11; d <= 20; d++ {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, 8.6, taken.AddDate(0, 0, -d).Add(8*time.Hour), "", "")
+		require.NoError(t, err)
+	}
+	comparison, err = svc.Labs.CompareHbA1c(user.ID, taken.Add(time.Second))
+	require.NoError(t, err)
+	require.NotNil(t, comparison.GMI)
+	assert.Equal(t, 7.0, *comparison.GMI)
+	assert.Equal(t, 0.0, *comparison.Difference)
+	assert.NotContains(t, comparison.Summary(), "Расхождение")
+
+	_, err = svc.Labs.CompareHbA1c(user.ID, taken)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Окно нового анализа включает и измерение 20.0: GMI 7.3, разница с 8.0 заметная
+	_, err = svc.Labs.Create(user.ID, LabInput{Kind: models.LabKindHbA1c, Value: 8.0, TakenAt: taken.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	comparison, err = svc.Labs.Latest(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, comparison.HbA1c)
+	assert.Contains(t, comparison.Summary(), "7.3% (разница +0.7)")
+	assert.Contains(t, comparison.Summary(), "Расхождение заметное")
+
+	// Отчет показывает последний HbA1c на конец периода
+	report, err := svc.Report.Build(user, ReportOptions{From: taken.AddDate(0, 0, -30), To: taken.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+	require.NotNil(t, report.HbA1c)
+	assert.Equal(t, 8.0, report.HbA1c.HbA1c)
+	report, err = svc.Report.Build(user, ReportOptions{From: taken.AddDate(1, 0, 0), To: taken.AddDate(1, 1, 0)})
+	require.NoError(t, err)
+	assert.Nil(t, report.HbA1c)
+}
@@ -3,7 +3,6 @@ package services
 import (
 	"diabetbot/internal/models"
 	"fmt"
-	"gorm.io/gorm"
 )
 
 // LimitedAIService оборачивает AI сервис и добавляет проверку лимитов
@@ -12,10 +11,10 @@ type LimitedAIService struct {
 	aiUsageService *AIUsageService
 }
 
-func NewLimitedAIService(aiService AIService, db *gorm.DB) *LimitedAIService {
+func NewLimitedAIService(aiService AIService, aiUsageService *AIUsageService) *LimitedAIService {
 	return &LimitedAIService{
 		aiService:      aiService,
-		aiUsageService: NewAIUsageService(db),
+		aiUsageService: aiUsageService,
 	}
 }
 
@@ -123,5 +122,10 @@ func (s *LimitedAIService) GetGeneralRecommendation(user *models.User, question
 	return recommendation
 }
 
+// GetDigestCommentary не расходует дневной лимит: еженедельную сводку пользователь не запрашивает сам
+func (s *LimitedAIService) GetDigestCommentary(user *models.User, summary string) string {
+	return s.aiService.GetDigestCommentary(user, summary)
+}
+
 // Убеждаемся, что LimitedAIService реализует интерфейс AIService
 var _ AIService = (*LimitedAIService)(nil)
\ No newline at end of file
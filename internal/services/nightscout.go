@@ -0,0 +1,512 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"diabetbot/internal/importer"
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// Типы записей и событий Nightscout
+const (
+	NightscoutSGV = "sgv" // показание сенсора
+	NightscoutMBG = "mbg" // измерение глюкометром
+
+	NightscoutBGCheck        = "BG Check"
+	NightscoutCarbCorrection = "Carb Correction"
+	NightscoutCorrection     = "Correction Bolus"
+	NightscoutNote           = "Note"
+)
+
+// Ограничения выборки entries и treatments: Nightscout по умолчанию отдает 10 записей
+const (
+	NightscoutDefaultCount = 10
+	NightscoutMaxCount     = 1000
+)
+
+// nightscoutSecretBytes — длина секрета; в hex он вдвое длиннее и проходит
+// проверку длины API_SECRET в xDrip+ и AAPS (не меньше 12 символов)
+const nightscoutSecretBytes = 12
+
+// Префиксы _id: Nightscout ждет 24 hex-символа ObjectId, записи разных таблиц не должны совпасть
+const (
+	nightscoutGlucoseID = 1
+	nightscoutFoodID    = 2
+	nightscoutInsulinID = 3
+)
+
+// NightscoutEntry — запись коллекции entries. Глюкоза в мг/дл, date — Unix время в миллисекундах.
+type NightscoutEntry struct {
+	ID         string  `json:"_id,omitempty"`
+	Type       string  `json:"type"`
+	SGV        float64 `json:"sgv,omitempty"`
+	MBG        float64 `json:"mbg,omitempty"`
+	Date       int64   `json:"date"`
+	DateString string  `json:"dateString,omitempty"`
+	Direction  string  `json:"direction,omitempty"`
+	Device     string  `json:"device,omitempty"`
+}
+
+// NightscoutTreatment — событие коллекции treatments: инсулин, углеводы или измерение из пальца.
+// Glucose задается в Units (mg/dl по умолчанию или mmol).
+type NightscoutTreatment struct {
+	ID          string   `json:"_id,omitempty"`
+	EventType   string   `json:"eventType"`
+	CreatedAt   string   `json:"created_at"`
+	Mills       int64    `json:"mills,omitempty"`
+	Insulin     *float64 `json:"insulin,omitempty"`
+	Carbs       *float64 `json:"carbs,omitempty"`
+	Glucose     *float64 `json:"glucose,omitempty"`
+	GlucoseType string   `json:"glucoseType,omitempty"`
+	Units       string   `json:"units,omitempty"`
+	FoodType    string   `json:"foodType,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+	EnteredBy   string   `json:"enteredBy,omitempty"`
+}
+
+// NightscoutQuery — выборка entries или treatments: последние Count записей за период [From, To)
+type NightscoutQuery struct {
+	From  time.Time
+	To    time.Time
+	Count int
+}
+
+// NightscoutService отдает дневник в формате Nightscout REST API v1 и принимает
+// загрузки xDrip+ и AAPS. Доступ по API secret, который пользователь получает в боте.
+type NightscoutService struct {
+	users   repository.UserRepository
+	glucose repository.GlucoseRepository
+	food    repository.FoodRepository
+	insulin repository.InsulinRepository
+	alerts  *AlertService // nil — без тревог родственникам
+}
+
+func NewNightscoutService(users repository.UserRepository, glucose repository.GlucoseRepository, food repository.FoodRepository, insulin repository.InsulinRepository) *NightscoutService {
+	return &NightscoutService{users: users, glucose: glucose, food: food, insulin: insulin}
+}
+
+// NightscoutSecretHash возвращает SHA1 секрета в hex — в таком виде клиенты
+// Nightscout передают его в заголовке api-secret
+func NightscoutSecretHash(secret string) string {
+	sum := sha1.Sum([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// EnableAPI создает новый API secret; прежний перестает действовать.
+// Секрет возвращается один раз, хранится только его SHA1.
+func (s *NightscoutService) EnableAPI(userID uint) (string, error) {
+	b := make([]byte, nightscoutSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+	hash := NightscoutSecretHash(secret)
+	if err := s.users.Update(userID, map[string]interface{}{"nightscout_secret_hash": &hash}); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// DisableAPI отзывает API secret
+func (s *NightscoutService) DisableAPI(userID uint) error {
+	return s.users.Update(userID, map[string]interface{}{"nightscout_secret_hash": nil})
+}
+
+// Authenticate находит пользователя по API secret. Принимается SHA1 секрета, как его
+// отправляют xDrip+ и AAPS, или сам секрет, например из параметра token.
+func (s *NightscoutService) Authenticate(secret string) (*models.User, error) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return nil, ErrForbidden
+	}
+	hash := strings.ToLower(secret)
+	if !isSHA1Hex(hash) {
+		hash = NightscoutSecretHash(secret)
+	}
+
+	user, err := s.users.GetByNightscoutSecretHash(hash)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrForbidden
+	}
+	return user, err
+}
+
+// Entries возвращает последние измерения глюкозы, сначала новые. Направление
+// изменения считается по предыдущему измерению, если оно было не раньше 15 минут назад.
+func (s *NightscoutService) Entries(userID uint, q NightscoutQuery) ([]NightscoutEntry, error) {
+	count := nightscoutCount(q.Count)
+	// Лишняя запись нужна для направления самого старого измерения
+	records, err := s.glucose.List(userID, repository.ListQuery{From: q.From, To: q.To, Limit: count + 1}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]NightscoutEntry, 0, min(count, len(records)))
+	for i := 0; i < len(records) && i < count; i++ {
+		entry := nightscoutEntry(&records[i])
+		if i+1 < len(records) {
+			entry.Direction = nightscoutDirection(&records[i+1], &records[i])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// AddEntries сохраняет загруженные измерения. Записи с неизвестным типом, без времени,
+// из будущего или вне диапазона глюкозы пропускаются, как и уже сохраненные:
+// клиенты повторяют загрузку, пока не получат успешный ответ.
+func (s *NightscoutService) AddEntries(userID uint, entries []NightscoutEntry) ([]NightscoutEntry, error) {
+	limit := time.Now().Add(importFutureTolerance)
+	records := make([]models.GlucoseRecord, 0, len(entries))
+	for _, entry := range entries {
+		at, ok := entryTime(entry)
+		if !ok || at.After(limit) {
+			continue
+		}
+		var mgdl float64
+		source, deviceID := models.GlucoseSourceCGM, truncate(entry.Device, 100)
+		switch entry.Type {
+		case NightscoutSGV, "":
+			mgdl = entry.SGV
+		case NightscoutMBG:
+			mgdl = entry.MBG
+			source, deviceID = models.GlucoseSourceFingerstick, ""
+		default:
+			continue
+		}
+		value, ok := nightscoutMmol(mgdl)
+		if !ok {
+			continue
+		}
+		records = append(records, models.GlucoseRecord{
+			UserID:     userID,
+			Value:      value,
+			MeasuredAt: at,
+			Notes:      nightscoutNotes(entry.Device),
+			Source:     source,
+			DeviceID:   deviceID,
+		})
+	}
+
+	fresh, _, err := freshGlucose(s.glucose, userID, records)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.glucose.CreateBatch(fresh); err != nil {
+		return nil, err
+	}
+	s.alerts.observe(userID, fresh)
+
+	saved := make([]NightscoutEntry, len(fresh))
+	for i := range fresh {
+		saved[i] = nightscoutEntry(&fresh[i])
+	}
+	return saved, nil
+}
+
+// Treatments возвращает последние записи о еде и инсулине, сначала новые
+func (s *NightscoutService) Treatments(userID uint, q NightscoutQuery) ([]NightscoutTreatment, error) {
+	count := nightscoutCount(q.Count)
+	query := repository.ListQuery{From: q.From, To: q.To, Limit: count}
+	foods, err := s.food.List(userID, query, "")
+	if err != nil {
+		return nil, err
+	}
+	injections, err := s.insulin.List(userID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	treatments := make([]NightscoutTreatment, 0, len(foods)+len(injections))
+	for i := range foods {
+		treatments = append(treatments, foodTreatment(&foods[i]))
+	}
+	for i := range injections {
+		treatments = append(treatments, insulinTreatment(&injections[i]))
+	}
+	sort.SliceStable(treatments, func(i, j int) bool { return treatments[i].Mills > treatments[j].Mills })
+	if len(treatments) > count {
+		treatments = treatments[:count]
+	}
+	return treatments, nil
+}
+
+// AddTreatments сохраняет события: insulin — болюс, carbs — запись о еде, glucose из пальца —
+// измерение. Из одного события может получиться несколько записей. События без этих полей
+// (смена сенсора, временный базал) и повторы пропускаются.
+func (s *NightscoutService) AddTreatments(userID uint, treatments []NightscoutTreatment) ([]NightscoutTreatment, error) {
+	limit := time.Now().Add(importFutureTolerance)
+	var glucose []models.GlucoseRecord
+	var foods []models.FoodRecord
+	var injections []models.InsulinRecord
+
+	for _, t := range treatments {
+		at, ok := treatmentTime(t)
+		if !ok || at.After(limit) {
+			continue
+		}
+		if t.Insulin != nil && *t.Insulin > 0 {
+			injections = append(injections, models.InsulinRecord{
+				UserID:      userID,
+				Units:       math.Round(*t.Insulin*100) / 100,
+				InsulinType: models.InsulinTypeBolus,
+				InjectedAt:  at,
+				Notes:       truncate(t.Notes, 500),
+			})
+		}
+		if t.Carbs != nil && *t.Carbs > 0 {
+			carbs := math.Round(*t.Carbs*10) / 10
+			foods = append(foods, models.FoodRecord{
+				UserID:     userID,
+				FoodName:   truncate(firstNonEmpty(t.FoodType, t.Notes, "Углеводы"), 255),
+				Carbs:      &carbs,
+				ConsumedAt: at,
+				Notes:      nightscoutNotes(t.EnteredBy),
+			})
+		}
+		if t.Glucose != nil && !strings.EqualFold(t.GlucoseType, "Sensor") {
+			if value, ok := treatmentGlucose(*t.Glucose, t.Units); ok {
+				glucose = append(glucose, models.GlucoseRecord{
+					UserID:     userID,
+					Value:      value,
+					MeasuredAt: at,
+					Notes:      nightscoutNotes(t.EnteredBy),
+					Source:     models.GlucoseSourceFingerstick,
+				})
+			}
+		}
+	}
+
+	freshGlucoseRecords, _, err := freshGlucose(s.glucose, userID, glucose)
+	if err != nil {
+		return nil, err
+	}
+	freshFoods, err := freshRecords(foods, func(from, to time.Time) ([]models.FoodRecord, error) {
+		return s.food.List(userID, repository.ListQuery{From: from, To: to, Ascending: true}, "")
+	}, func(r *models.FoodRecord) time.Time { return r.ConsumedAt }, func(r *models.FoodRecord) float64 { return *r.Carbs })
+	if err != nil {
+		return nil, err
+	}
+	freshInjections, err := freshRecords(injections, func(from, to time.Time) ([]models.InsulinRecord, error) {
+		return s.insulin.List(userID, repository.ListQuery{From: from, To: to, Ascending: true})
+	}, func(r *models.InsulinRecord) time.Time { return r.InjectedAt }, func(r *models.InsulinRecord) float64 { return r.Units })
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.glucose.CreateBatch(freshGlucoseRecords); err != nil {
+		return nil, err
+	}
+	s.alerts.observe(userID, freshGlucoseRecords)
+	if err := s.food.CreateBatch(freshFoods); err != nil {
+		return nil, err
+	}
+	if err := s.insulin.CreateBatch(freshInjections); err != nil {
+		return nil, err
+	}
+
+	saved := make([]NightscoutTreatment, 0, len(freshGlucoseRecords)+len(freshFoods)+len(freshInjections))
+	for i := range freshGlucoseRecords {
+		saved = append(saved, bgCheckTreatment(&freshGlucoseRecords[i]))
+	}
+	for i := range freshFoods {
+		saved = append(saved, foodTreatment(&freshFoods[i]))
+	}
+	for i := range freshInjections {
+		saved = append(saved, insulinTreatment(&freshInjections[i]))
+	}
+	return saved, nil
+}
+
+// freshRecords отбрасывает записи, которые уже есть в дневнике: list загружает
+// сохраненные записи за период, at и value задают ключ совпадения
+func freshRecords[T any](records []T, list func(from, to time.Time) ([]T, error), at func(*T) time.Time, value func(*T) float64) ([]T, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	existing, err := list(minuteRange(records, at))
+	if err != nil {
+		return nil, err
+	}
+	fresh, _ := skipDuplicates(existing, records, func(r *T) recordKey { return newRecordKey(at(r), value(r)) })
+	return fresh, nil
+}
+
+func nightscoutCount(count int) int {
+	if count <= 0 {
+		return NightscoutDefaultCount
+	}
+	return min(count, NightscoutMaxCount)
+}
+
+func nightscoutID(kind int, id uint) string {
+	return fmt.Sprintf("%x%023x", kind, id)
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != sha1.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// nightscoutMmol переводит мг/дл в ммоль/л; значения вне диапазона глюкозы,
+// например коды ошибок сенсора, не принимаются
+func nightscoutMmol(mgdl float64) (float64, bool) {
+	value := math.Round(mgdl/importer.MgDLPerMmol*10) / 10
+	return value, value >= 1 && value <= 40
+}
+
+// treatmentGlucose переводит глюкозу события в ммоль/л; units — mg/dl или mmol
+func treatmentGlucose(value float64, units string) (float64, bool) {
+	if !strings.EqualFold(units, "mmol") {
+		return nightscoutMmol(value)
+	}
+	value = math.Round(value*10) / 10
+	return value, value >= 1 && value <= 40
+}
+
+func nightscoutMgDL(mmol float64) float64 {
+	return math.Round(mmol * importer.MgDLPerMmol)
+}
+
+// nightscoutDirection — стрелка тренда по скорости изменения в мг/дл в минуту, как в xDrip+
+func nightscoutDirection(prev, cur *models.GlucoseRecord) string {
+	minutes := cur.MeasuredAt.Sub(prev.MeasuredAt).Minutes()
+	if minutes <= 0 || minutes > 15 {
+		return "NONE"
+	}
+	slope := (nightscoutMgDL(cur.Value) - nightscoutMgDL(prev.Value)) / minutes
+	switch {
+	case slope > 3.5:
+		return "DoubleUp"
+	case slope > 2:
+		return "SingleUp"
+	case slope > 1:
+		return "FortyFiveUp"
+	case slope >= -1:
+		return "Flat"
+	case slope >= -2:
+		return "FortyFiveDown"
+	case slope >= -3.5:
+		return "SingleDown"
+	default:
+		return "DoubleDown"
+	}
+}
+
+func nightscoutNotes(source string) string {
+	if source == "" {
+		return "Nightscout"
+	}
+	return truncate("Nightscout: "+source, 500)
+}
+
+func nightscoutEntry(r *models.GlucoseRecord) NightscoutEntry {
+	return NightscoutEntry{
+		ID:         nightscoutID(nightscoutGlucoseID, r.ID),
+		Type:       NightscoutSGV,
+		SGV:        nightscoutMgDL(r.Value),
+		Date:       r.MeasuredAt.UnixMilli(),
+		DateString: r.MeasuredAt.UTC().Format(time.RFC3339),
+		Direction:  "NONE",
+	}
+}
+
+func bgCheckTreatment(r *models.GlucoseRecord) NightscoutTreatment {
+	value := nightscoutMgDL(r.Value)
+	return NightscoutTreatment{
+		ID:          nightscoutID(nightscoutGlucoseID, r.ID),
+		EventType:   NightscoutBGCheck,
+		CreatedAt:   r.MeasuredAt.UTC().Format(time.RFC3339),
+		Mills:       r.MeasuredAt.UnixMilli(),
+		Glucose:     &value,
+		GlucoseType: "Finger",
+		Units:       "mg/dl",
+		Notes:       r.Notes,
+	}
+}
+
+func foodTreatment(r *models.FoodRecord) NightscoutTreatment {
+	return NightscoutTreatment{
+		ID:        nightscoutID(nightscoutFoodID, r.ID),
+		EventType: NightscoutCarbCorrection,
+		CreatedAt: r.ConsumedAt.UTC().Format(time.RFC3339),
+		Mills:     r.ConsumedAt.UnixMilli(),
+		Carbs:     r.Carbs,
+		FoodType:  r.FoodType,
+		Notes:     r.FoodName,
+	}
+}
+
+// insulinTreatment отдает болюс с полем insulin. Продленный инсулин Nightscout считал бы
+// активным инсулином болюса, поэтому он передается заметкой.
+func insulinTreatment(r *models.InsulinRecord) NightscoutTreatment {
+	t := NightscoutTreatment{
+		ID:        nightscoutID(nightscoutInsulinID, r.ID),
+		EventType: NightscoutCorrection,
+		CreatedAt: r.InjectedAt.UTC().Format(time.RFC3339),
+		Mills:     r.InjectedAt.UnixMilli(),
+		Notes:     strings.TrimSpace(r.InsulinName + " " + r.Notes),
+	}
+	if r.InsulinType == models.InsulinTypeBasal {
+		t.EventType = NightscoutNote
+		t.Notes = strings.TrimSpace(fmt.Sprintf("%s %g ЕД %s", firstNonEmpty(r.InsulinName, "Базальный инсулин"), r.Units, r.Notes))
+		return t
+	}
+	units := r.Units
+	t.Insulin = &units
+	return t
+}
+
+func entryTime(e NightscoutEntry) (time.Time, bool) {
+	if e.Date > 0 {
+		return time.UnixMilli(e.Date), true
+	}
+	return parseNightscoutTime(e.DateString)
+}
+
+func treatmentTime(t NightscoutTreatment) (time.Time, bool) {
+	if at, ok := parseNightscoutTime(t.CreatedAt); ok {
+		return at, true
+	}
+	if t.Mills > 0 {
+		return time.UnixMilli(t.Mills), true
+	}
+	return time.Time{}, false
+}
+
+func parseNightscoutTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	return at, err == nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNightscoutService_Secret(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(930, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	_, err = svc.Nightscout.Authenticate("")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	secret, err := svc.Nightscout.EnableAPI(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, secret, 24)
+
+	// xDrip+ и AAPS отправляют SHA1 секрета, браузер — сам секрет в token
+	for _, credential := range []string{secret, NightscoutSecretHash(secret)} {
+		found, err := svc.Nightscout.Authenticate(credential)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, found.ID)
+	}
+	_, err = svc.Nightscout.Authenticate("wrong-secret")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// Новый секрет отменяет прежний
+	rotated, err := svc.Nightscout.EnableAPI(user.ID)
+	require.NoError(t, err)
+	_, err = svc.Nightscout.Authenticate(secret)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	require.NoError(t, svc.Nightscout.DisableAPI(user.ID))
+	_, err = svc.Nightscout.Authenticate(rotated)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestNightscoutService_Entries(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(931, "", "Петр", "", "ru")
+	require.NoError(t, err)
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	upload := []NightscoutEntry{
+		{Type: NightscoutSGV, SGV: 108, Date: base.UnixMilli(), Device: "xDrip-DexcomG6"},
+		{Type: NightscoutSGV, SGV: 117, Date: base.Add(5 * time.Minute).UnixMilli(), Device: "xDrip-DexcomG6"},
+		{Type: NightscoutSGV, SGV: 135, Date: base.Add(10 * time.Minute).UnixMilli(), Device: "xDrip-DexcomG6"},
+		{Type: NightscoutMBG, MBG: 126, DateString: base.Add(20 * time.Minute).UTC().Format(time.RFC3339)},
+		{Type: NightscoutSGV, SGV: 5, Date: base.Add(25 * time.Minute).UnixMilli()},      // код ошибки сенсора
+		{Type: "cal", Date: base.Add(25 * time.Minute).UnixMilli()},                      // калибровка
+		{Type: NightscoutSGV, SGV: 120, Date: time.Now().Add(3 * time.Hour).UnixMilli()}, // будущее
+		{Type: NightscoutSGV, SGV: 108, Date: base.Add(20 * time.Second).UnixMilli()},    // повтор
+	}
+
+	saved, err := svc.Nightscout.AddEntries(user.ID, upload)
+	require.NoError(t, err)
+	require.Len(t, saved, 4)
+	assert.Regexp(t, `^1[0-9a-f]{23}$`, saved[0].ID)
+
+	records, err := svc.Glucose.ListRecords(user.ID, ListOptions{}, "")
+	require.NoError(t, err)
+	require.Len(t, records.Items, 4)
+	assert.Equal(t, 7.0, records.Items[0].Value) // 126 мг/дл
+	assert.Equal(t, 6.0, records.Items[3].Value) // 108 мг/дл
+	assert.Equal(t, "Nightscout: xDrip-DexcomG6", records.Items[3].Notes)
+	assert.Equal(t, models.GlucoseSourceCGM, records.Items[3].Source)
+	assert.Equal(t, "xDrip-DexcomG6", records.Items[3].DeviceID)
+	assert.Equal(t, models.GlucoseSourceFingerstick, records.Items[0].Source)
+
+	// Повторная загрузка ничего не добавляет
+	saved, err = svc.Nightscout.AddEntries(user.ID, upload)
+	require.NoError(t, err)
+	assert.Empty(t, saved)
+
+	entries, err := svc.Nightscout.Entries(user.ID, NightscoutQuery{Count: 3})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, NightscoutSGV, entries[0].Type)
+	assert.Equal(t, 126.0, entries[0].SGV)
+	assert.Equal(t, base.Add(20*time.Minute).UnixMilli(), entries[0].Date)
+	assert.Equal(t, "Flat", entries[0].Direction)        // -0.9 мг/дл в минуту
+	assert.Equal(t, "DoubleUp", entries[1].Direction)    // +3.6 мг/дл в минуту
+	assert.Equal(t, "FortyFiveUp", entries[2].Direction) // +1.8 мг/дл в минуту
+
+	entries, err = svc.Nightscout.Entries(user.ID, NightscoutQuery{From: base.Add(time.Minute), To: base.Add(15 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, base.Add(10*time.Minute).UnixMilli(), entries[0].Date)
+}
+
+func TestNightscoutService_Treatments(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	user, err := svc.Users.GetOrCreateUser(932, "", "Ольга", "", "ru")
+	require.NoError(t, err)
+
+	at := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	insulin, carbs, glucose := 4.5, 45.0, 7.2
+	upload := []NightscoutTreatment{
+		{EventType: "Meal Bolus", CreatedAt: at.Format(time.RFC3339), Insulin: &insulin, Carbs: &carbs, Notes: "Гречка", EnteredBy: "AAPS"},
+		{EventType: NightscoutBGCheck, Mills: at.Add(-10 * time.Minute).UnixMilli(), Glucose: &glucose, GlucoseType: "Finger", Units: "mmol"},
+		{EventType: "Site Change", CreatedAt: at.Format(time.RFC3339)},
+		{EventType: "Correction Bolus"}, // без времени
+	}
+
+	saved, err := svc.Nightscout.AddTreatments(user.ID, upload)
+	require.NoError(t, err)
+	assert.Len(t, saved, 3)
+
+	food, err := repos.Food.List(user.ID, repository.ListQuery{}, "")
+	require.NoError(t, err)
+	require.Len(t, food, 1)
+	assert.Equal(t, "Гречка", food[0].FoodName)
+	assert.Equal(t, 45.0, *food[0].Carbs)
+	assert.True(t, at.Equal(food[0].ConsumedAt))
+
+	injections, err := repos.Insulin.List(user.ID, repository.ListQuery{})
+	require.NoError(t, err)
+	require.Len(t, injections, 1)
+	assert.Equal(t, 4.5, injections[0].Units)
+	assert.Equal(t, models.InsulinTypeBolus, injections[0].InsulinType)
+
+	readings, err := repos.Glucose.List(user.ID, repository.ListQuery{}, "")
+	require.NoError(t, err)
+	require.Len(t, readings, 1)
+	assert.Equal(t, 7.2, readings[0].Value)
+	assert.Equal(t, "Nightscout", readings[0].Notes)
+
+	// AAPS повторяет загрузку при обрыве связи
+	saved, err = svc.Nightscout.AddTreatments(user.ID, upload)
+	require.NoError(t, err)
+	assert.Empty(t, saved)
+
+	_, err = svc.Insulin.CreateRecord(user.ID, 18, models.InsulinTypeBasal, "Тресиба", at.Add(time.Hour), "")
+	require.NoError(t, err)
+
+	treatments, err := svc.Nightscout.Treatments(user.ID, NightscoutQuery{})
+	require.NoError(t, err)
+	require.Len(t, treatments, 3)
+	assert.Equal(t, NightscoutNote, treatments[0].EventType)
+	assert.Nil(t, treatments[0].Insulin)
+	assert.Equal(t, "Тресиба 18 ЕД", treatments[0].Notes)
+	assert.Equal(t, at.UnixMilli(), treatments[1].Mills)
+	assert.Equal(t, at.Format(time.RFC3339), treatments[1].CreatedAt)
+
+	treatments, err = svc.Nightscout.Treatments(user.ID, NightscoutQuery{Count: 1, To: at.Add(time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, treatments, 1)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"diabetbot/internal/repository"
+)
+
+const (
+	DefaultPageLimit = 50  // Размер страницы списка по умолчанию
+	MaxPageLimit     = 500 // Максимальный размер страницы
+)
+
+var (
+	ErrInvalidCursor = &ValidationError{Field: "cursor", Rule: "invalid"}
+	ErrInvalidRange  = &ValidationError{Field: "to", Rule: "after", Param: "from"}
+	ErrInvalidLimit  = &ValidationError{Field: "limit", Rule: "between", Param: fmt.Sprintf("1 %d", MaxPageLimit)}
+)
+
+// ListOptions — параметры постраничного списка записей.
+// Нулевые From и To не ограничивают период, To не включается.
+// С курсором период берется из курсора: «последние 30 дней» не сдвигаются между страницами.
+type ListOptions struct {
+	From      time.Time
+	To        time.Time
+	Limit     int    // 0 — DefaultPageLimit
+	Cursor    string // next_cursor предыдущей страницы
+	Ascending bool   // по умолчанию сначала новые
+}
+
+// Page — страница списка. NextCursor пуст, если записей больше нет.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// query проверяет параметры и переводит их в запрос к хранилищу.
+// Хранилище запрашивается на одну запись больше, чтобы понять, есть ли следующая страница.
+func (o ListOptions) query() (repository.ListQuery, error) {
+	limit := o.Limit
+	if limit == 0 {
+		limit = DefaultPageLimit
+	}
+	if limit < 0 || limit > MaxPageLimit {
+		return repository.ListQuery{}, ErrInvalidLimit
+	}
+	if !o.From.IsZero() && !o.To.IsZero() && !o.From.Before(o.To) {
+		return repository.ListQuery{}, ErrInvalidRange
+	}
+
+	query := repository.ListQuery{
+		From:      o.From,
+		To:        o.To,
+		Ascending: o.Ascending,
+		Limit:     limit + 1,
+	}
+	if o.Cursor != "" {
+		cursor, err := decodeCursor(o.Cursor, o.Ascending)
+		if err != nil {
+			return repository.ListQuery{}, err
+		}
+		query.After, query.From, query.To = &cursor.After, cursor.From, cursor.To
+	}
+	return query, nil
+}
+
+// newPage обрезает лишнюю запись и формирует курсор на следующую страницу
+func newPage[T any](records []T, query repository.ListQuery, position func(*T) repository.Cursor) *Page[T] {
+	page := &Page[T]{Items: records}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if limit := query.Limit - 1; len(records) > limit {
+		page.Items = records[:limit]
+		cursor := encodeCursor(pageCursor{After: position(&page.Items[limit-1]), From: query.From, To: query.To}, query.Ascending)
+		page.NextCursor = &cursor
+	}
+	return page
+}
+
+// pageCursor — последняя запись страницы и период, с которым запрошена первая страница
+type pageCursor struct {
+	After    repository.Cursor
+	From, To time.Time
+}
+
+// Курсор непрозрачен для клиента: base64 от направления, времени в наносекундах, ID
+// и границ периода (пусто — без границы). Направление проверяется, чтобы курсор
+// не применили к списку с другой сортировкой.
+func encodeCursor(c pageCursor, ascending bool) string {
+	direction := "d"
+	if ascending {
+		direction = "a"
+	}
+	raw := fmt.Sprintf("%s:%d:%d:%s:%s", direction, c.After.At.UnixNano(), c.After.ID, cursorBound(c.From), cursorBound(c.To))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func cursorBound(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func decodeCursor(s string, ascending bool) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 5 {
+		return nil, ErrInvalidCursor
+	}
+	if (parts[0] == "a") != ascending || (parts[0] != "a" && parts[0] != "d") {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &pageCursor{After: repository.Cursor{At: time.Unix(0, nanos), ID: uint(id)}}
+	for i, bound := range []*time.Time{&cursor.From, &cursor.To} {
+		if parts[3+i] == "" {
+			continue
+		}
+		nanos, err := strconv.ParseInt(parts[3+i], 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		*bound = time.Unix(0, nanos)
+	}
+	return cursor, nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/repository"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlucoseService_ListRecords(t *testing.T) {
+	service := NewGlucoseService(memory.NewGlucoseRepository())
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		_, err := service.CreateRecordAt(1, float64(5+i), base.Add(time.Duration(i)*time.Hour), "", "")
+		require.NoError(t, err)
+	}
+
+	t.Run("PagesUntilEnd", func(t *testing.T) {
+		var values []float64
+		opts := ListOptions{Limit: 2}
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 5)
+			page, err := service.ListRecords(1, opts, "")
+			require.NoError(t, err)
+			for _, r := range page.Items {
+				values = append(values, r.Value)
+			}
+			if page.NextCursor == nil {
+				break
+			}
+			opts.Cursor = *page.NextCursor
+		}
+		assert.Equal(t, []float64{9, 8, 7, 6, 5}, values)
+	})
+
+	// Период «за последние N дней» вычисляется заново на каждый запрос,
+	// но следующие страницы берут его из курсора первой
+	t.Run("CursorKeepsPeriod", func(t *testing.T) {
+		page, err := service.ListRecords(1, ListOptions{From: base.Add(2 * time.Hour), Limit: 1}, "")
+		require.NoError(t, err)
+		require.NotNil(t, page.NextCursor)
+
+		page, err = service.ListRecords(1, ListOptions{From: base.Add(4 * time.Hour), Cursor: *page.NextCursor}, "")
+		require.NoError(t, err)
+		var values []float64
+		for _, r := range page.Items {
+			values = append(values, r.Value)
+		}
+		assert.Equal(t, []float64{8, 7}, values)
+	})
+
+	t.Run("ExactLastPageHasNoCursor", func(t *testing.T) {
+		page, err := service.ListRecords(1, ListOptions{Limit: 5}, "")
+		require.NoError(t, err)
+		assert.Len(t, page.Items, 5)
+		assert.Nil(t, page.NextCursor)
+	})
+
+	t.Run("EmptyListIsNotNil", func(t *testing.T) {
+		page, err := service.ListRecords(2, ListOptions{}, "")
+		require.NoError(t, err)
+		assert.NotNil(t, page.Items)
+		assert.Empty(t, page.Items)
+	})
+
+	t.Run("CursorBoundToSortDirection", func(t *testing.T) {
+		page, err := service.ListRecords(1, ListOptions{Limit: 1}, "")
+		require.NoError(t, err)
+		require.NotNil(t, page.NextCursor)
+
+		_, err = service.ListRecords(1, ListOptions{Limit: 1, Cursor: *page.NextCursor, Ascending: true}, "")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		_, err := service.ListRecords(1, ListOptions{Cursor: "not-a-cursor"}, "")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		_, err = service.ListRecords(1, ListOptions{Limit: MaxPageLimit + 1}, "")
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+
+		_, err = service.ListRecords(1, ListOptions{From: base, To: base}, "")
+		assert.ErrorIs(t, err, ErrInvalidRange)
+	})
+}
+
+func TestFoodService_ListRecords(t *testing.T) {
+	service := NewFoodService(memory.NewFoodRepository())
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	_, err := service.CreateRecordAt(1, "Каша", "завтрак", nil, nil, "", "", base)
+	require.NoError(t, err)
+	_, err = service.CreateRecordAt(1, "Суп", "обед", nil, nil, "", "", base.Add(5*time.Hour))
+	require.NoError(t, err)
+	_, err = service.CreateRecordAt(1, "Омлет", "завтрак", nil, nil, "", "", base.AddDate(0, 0, 1))
+	require.NoError(t, err)
+
+	page, err := service.ListRecords(1, ListOptions{Ascending: true}, "завтрак")
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "Каша", page.Items[0].FoodName)
+	assert.Equal(t, "Омлет", page.Items[1].FoodName)
+
+	page, err = service.ListRecords(1, ListOptions{From: base.Add(time.Hour), To: base.AddDate(0, 0, 1)}, "")
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "Суп", page.Items[0].FoodName)
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 30, 15, 123456000, time.UTC)
+	from := at.AddDate(0, 0, -30)
+	for _, ascending := range []bool{false, true} {
+		encoded := encodeCursor(pageCursor{After: repository.Cursor{At: at, ID: 42}, From: from}, ascending)
+		decoded, err := decodeCursor(encoded, ascending)
+		require.NoError(t, err)
+		assert.True(t, decoded.After.At.Equal(at))
+		assert.Equal(t, uint(42), decoded.After.ID)
+		assert.True(t, decoded.From.Equal(from))
+		assert.True(t, decoded.To.IsZero())
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// Ограничения публичных ссылок
+const (
+	DefaultPublicLinkDays = 14                  // период сводки по умолчанию
+	MaxPublicLinkDays     = 90                  // самый длинный период сводки
+	DefaultPublicLinkTTL  = 7 * 24 * time.Hour  // срок действия по умолчанию
+	MaxPublicLinkTTL      = 30 * 24 * time.Hour // самый долгий срок действия
+	MaxPublicLinks        = 20                  // действующих ссылок у одного пользователя
+)
+
+// PublicLinkPathPrefix — путь страницы сводки: /share/<токен>
+const PublicLinkPathPrefix = "/share/"
+
+// publicLinkTokenBytes — длина токена ссылки, в base64url это 32 символа
+const publicLinkTokenBytes = 24
+
+// maxPublicFoodRecords — сколько записей питания показывает сводка, остальные только считаются
+const maxPublicFoodRecords = 300
+
+// maxUserAgentLength — предел User-Agent в журнале открытий, символов
+const maxUserAgentLength = 255
+
+// ErrPublicLinkExpired — срок действия ссылки истек
+var ErrPublicLinkExpired = errors.New("public link expired")
+
+// PublicLinkPath возвращает путь страницы сводки для токена
+func PublicLinkPath(token string) string {
+	return PublicLinkPathPrefix + token
+}
+
+// PublicLinkOptions — параметры новой ссылки: период сводки [From, To) и срок действия TTL.
+// Нулевой To — текущий момент, нулевой From — DefaultPublicLinkDays дней до To,
+// нулевой TTL — DefaultPublicLinkTTL.
+type PublicLinkOptions struct {
+	Label string
+	From  time.Time
+	To    time.Time
+	TTL   time.Duration
+}
+
+// PublicLinkCreated — новая ссылка. Токен показывается один раз, хранится только его хеш.
+type PublicLinkCreated struct {
+	models.PublicLink
+	Token string `json:"token"`
+	Path  string `json:"path"`
+}
+
+// PublicSummary — данные страницы сводки для врача
+type PublicSummary struct {
+	Report    *Report
+	Food      []models.FoodRecord // первые maxPublicFoodRecords записей по времени
+	FoodCount int                 // всего записей питания за период
+	ExpiresAt time.Time
+}
+
+// PublicLinkService выдает врачу без Telegram ссылку на сводку дневника за период:
+// ссылка действует ограниченное время, ее можно отозвать, каждое открытие записывается.
+type PublicLinkService struct {
+	users  repository.UserRepository
+	links  repository.PublicLinkRepository
+	food   repository.FoodRepository
+	report *ReportService
+}
+
+func NewPublicLinkService(users repository.UserRepository, links repository.PublicLinkRepository,
+	glucose repository.GlucoseRepository, food repository.FoodRepository) *PublicLinkService {
+	return &PublicLinkService{users: users, links: links, food: food, report: NewReportService(glucose, food)}
+}
+
+// Create создает ссылку на сводку дневника пользователя
+func (s *PublicLinkService) Create(userID uint, opts PublicLinkOptions) (*PublicLinkCreated, error) {
+	now := time.Now()
+	if opts.To.IsZero() {
+		opts.To = now
+	}
+	if opts.From.IsZero() {
+		opts.From = opts.To.AddDate(0, 0, -DefaultPublicLinkDays)
+	}
+	if opts.TTL == 0 {
+		opts.TTL = DefaultPublicLinkTTL
+	}
+	if !opts.From.Before(opts.To) {
+		return nil, ErrInvalidRange
+	}
+	if opts.To.Sub(opts.From) > MaxPublicLinkDays*24*time.Hour {
+		return nil, &ValidationError{Field: "days", Rule: "between", Param: fmt.Sprintf("1 %d", MaxPublicLinkDays)}
+	}
+	if opts.TTL < time.Hour || opts.TTL > MaxPublicLinkTTL {
+		return nil, &ValidationError{Field: "expires_in_days", Rule: "between", Param: fmt.Sprintf("1 %d", MaxPublicLinkTTL/(24*time.Hour))}
+	}
+	if utf8.RuneCountInString(opts.Label) > 100 {
+		return nil, &ValidationError{Field: "label", Rule: "max", Param: "100"}
+	}
+
+	links, err := s.links.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	active := 0
+	for _, link := range links {
+		if now.Before(link.ExpiresAt) {
+			active++
+		}
+	}
+	if active >= MaxPublicLinks {
+		return nil, &ValidationError{Field: "label", Rule: "max_links", Param: strconv.Itoa(MaxPublicLinks)}
+	}
+
+	b := make([]byte, publicLinkTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	link := models.PublicLink{
+		UserID:     userID,
+		TokenHash:  shareTokenHash(token),
+		Label:      opts.Label,
+		PeriodFrom: opts.From,
+		PeriodTo:   opts.To,
+		ExpiresAt:  now.Add(opts.TTL),
+	}
+	if err := s.links.Create(&link); err != nil {
+		return nil, err
+	}
+	return &PublicLinkCreated{PublicLink: link, Token: token, Path: PublicLinkPath(token)}, nil
+}
+
+// List возвращает неотозванные ссылки пользователя, в том числе истекшие, сначала новые
+func (s *PublicLinkService) List(userID uint) ([]models.PublicLink, error) {
+	links, err := s.links.ListByUser(userID)
+	if links == nil && err == nil {
+		links = []models.PublicLink{}
+	}
+	return links, err
+}
+
+// Revoke отзывает ссылку пользователя; чужая ссылка не найдена
+func (s *PublicLinkService) Revoke(userID, linkID uint) (*models.PublicLink, error) {
+	link, err := s.links.GetByID(userID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.links.Delete(userID, link.ID); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// RevokeAll отзывает все ссылки пользователя
+func (s *PublicLinkService) RevokeAll(userID uint) error {
+	links, err := s.links.ListByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if err := s.links.Delete(userID, link.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Accesses возвращает последние limit открытий ссылки пользователя, сначала новые
+func (s *PublicLinkService) Accesses(userID, linkID uint, limit int) ([]models.PublicLinkAccess, error) {
+	link, err := s.links.GetByID(userID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	accesses, err := s.links.ListAccesses(link.ID, limit)
+	if accesses == nil && err == nil {
+		accesses = []models.PublicLinkAccess{}
+	}
+	return accesses, err
+}
+
+// Open проверяет токен, записывает открытие и собирает сводку за период ссылки.
+// Неизвестная или отозванная ссылка — ErrNotFound, просроченная — ErrPublicLinkExpired.
+func (s *PublicLinkService) Open(token, ip, userAgent string) (*PublicSummary, error) {
+	link, err := s.links.GetByTokenHash(shareTokenHash(token))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !now.Before(link.ExpiresAt) {
+		return nil, ErrPublicLinkExpired
+	}
+	user, err := s.users.GetByID(link.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &models.PublicLinkAccess{LinkID: link.ID, IP: ip, UserAgent: truncate(userAgent, maxUserAgentLength), AccessedAt: now}
+	if err := s.links.LogAccess(access); err != nil {
+		return nil, err
+	}
+
+	// Часы профиля и дни питания считаются в часовом поясе сервера, как в отчете бота
+	opts := ReportOptions{From: link.PeriodFrom.In(time.Local), To: link.PeriodTo.In(time.Local)}
+	report, err := s.report.Build(user, opts)
+	if err != nil {
+		return nil, err
+	}
+	food, err := s.food.List(user.ID, repository.ListQuery{From: opts.From, To: opts.To, Ascending: true, Limit: maxPublicFoodRecords}, "")
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.food.Totals(user.ID, opts.From, opts.To)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicSummary{Report: report, Food: food, FoodCount: totals.Count, ExpiresAt: link.ExpiresAt.In(time.Local)}, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicLinkService_OpenAndRevoke(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	user, err := svc.Users.GetOrCreateUser(960, "", "Анна", "<b>Смирнова</b>", "ru")
+	require.NoError(t, err)
+	other, err := svc.Users.GetOrCreateUser(961, "", "Иван", "", "ru")
+	require.NoError(t, err)
+
+	now := time.Now()
+	_, err = svc.Glucose.CreateRecordAt(user.ID, 5.8, now.Add(-2*time.Hour), "", "")
+	require.NoError(t, err)
+	_, err = svc.Glucose.CreateRecordAt(user.ID, 3.2, now.Add(-26*time.Hour), "", "")
+	require.NoError(t, err)
+	// Вне периода ссылки
+	_, err = svc.Glucose.CreateRecordAt(user.ID, 15, now.AddDate(0, 0, -20), "", "")
+	require.NoError(t, err)
+	carbs := 45.0
+	_, err = svc.Food.CreateRecordAt(user.ID, "Гречка <script>", "обед", &carbs, nil, "150 г", "", now.Add(-3*time.Hour))
+	require.NoError(t, err)
+
+	link, err := svc.Links.Create(user.ID, PublicLinkOptions{Label: "Эндокринолог"})
+	require.NoError(t, err)
+	assert.Equal(t, PublicLinkPath(link.Token), link.Path)
+	assert.WithinDuration(t, now.Add(DefaultPublicLinkTTL), link.ExpiresAt, time.Minute)
+	assert.WithinDuration(t, now.AddDate(0, 0, -DefaultPublicLinkDays), link.PeriodFrom, time.Minute)
+
+	summary, err := svc.Links.Open(link.Token, "10.0.0.1", "Mozilla/5.0")
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Report.Glucose.Count)
+	assert.Equal(t, 1, summary.Report.HypoCount)
+	require.Len(t, summary.Food, 1)
+	assert.Equal(t, 1, summary.FoodCount)
+
+	var page bytes.Buffer
+	require.NoError(t, WritePublicSummaryHTML(&page, summary))
+	html := page.String()
+	assert.Contains(t, html, "<svg")
+	assert.Contains(t, html, "Гречка &lt;script&gt;")
+	assert.Contains(t, html, "&lt;b&gt;Смирнова&lt;/b&gt;")
+	assert.NotContains(t, html, "<script>")
+
+	// Каждое открытие записывается в журнал
+	_, err = svc.Links.Open(link.Token, "10.0.0.2", strings.Repeat("a", 400))
+	require.NoError(t, err)
+	accesses, err := svc.Links.Accesses(user.ID, link.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, accesses, 2)
+	assert.Len(t, accesses[0].UserAgent, maxUserAgentLength)
+	links, err := svc.Links.List(user.ID)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, 2, links[0].AccessCount)
+
+	_, err = svc.Links.Accesses(other.ID, link.ID, 10)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = svc.Links.Revoke(other.ID, link.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = svc.Links.Revoke(user.ID, link.ID)
+	require.NoError(t, err)
+	_, err = svc.Links.Open(link.Token, "10.0.0.1", "")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = svc.Links.Open("unknown", "10.0.0.1", "")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPublicLinkService_FoodLimit(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(963, "", "Анна", "", "ru")
+	require.NoError(t, err)
+	now := time.Now()
+	for i := 0; i < maxPublicFoodRecords+5; i++ {
+		_, err := svc.Food.CreateRecordAt(user.ID, "Яблоко", "перекус", nil, nil, "", "", now.Add(-time.Duration(i+1)*time.Minute))
+		require.NoError(t, err)
+	}
+
+	link, err := svc.Links.Create(user.ID, PublicLinkOptions{})
+	require.NoError(t, err)
+	summary, err := svc.Links.Open(link.Token, "10.0.0.1", "")
+	require.NoError(t, err)
+	assert.Len(t, summary.Food, maxPublicFoodRecords)
+	assert.Equal(t, maxPublicFoodRecords+5, summary.FoodCount)
+	// Показываются первые записи периода
+	assert.True(t, summary.Food[0].ConsumedAt.Before(summary.Food[1].ConsumedAt))
+}
+
+func TestPublicLinkService_Expiry(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	user, err := svc.Users.GetOrCreateUser(962, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	now := time.Now()
+	link := &models.PublicLink{UserID: user.ID, TokenHash: shareTokenHash("expired"), PeriodFrom: now.AddDate(0, 0, -7), PeriodTo: now, ExpiresAt: now.Add(-time.Minute)}
+	require.NoError(t, repos.Links.Create(link))
+
+	_, err = svc.Links.Open("expired", "10.0.0.1", "")
+	assert.ErrorIs(t, err, ErrPublicLinkExpired)
+	accesses, err := svc.Links.Accesses(user.ID, link.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, accesses)
+}
+
+func TestPublicLinkService_Validation(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(963, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	now := time.Now()
+	for _, opts := range []PublicLinkOptions{
+		{From: now, To: now.Add(-time.Hour)},
+		{From: now.AddDate(0, 0, -MaxPublicLinkDays-1), To: now},
+		{TTL: MaxPublicLinkTTL + time.Hour},
+		{Label: strings.Repeat("я", 101)},
+	} {
+		_, err := svc.Links.Create(user.ID, opts)
+		assert.ErrorIs(t, err, ErrValidation, "%+v", opts)
+	}
+
+	for i := 0; i < MaxPublicLinks; i++ {
+		_, err := svc.Links.Create(user.ID, PublicLinkOptions{})
+		require.NoError(t, err)
+	}
+	_, err = svc.Links.Create(user.ID, PublicLinkOptions{})
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, "max_links", verr.Rule)
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+)
+
+// Разметка графика суточного профиля в SVG, px
+const (
+	svgWidth      = 720.0
+	svgHeight     = 260.0
+	svgAxisWidth  = 32.0
+	svgAxisHeight = 20.0
+)
+
+func (c rgb) css() string { return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b) }
+
+// WritePublicSummaryHTML рисует сводку дневника для врача страницей HTML без внешних
+// ресурсов: стили встроены, график суточного профиля — встроенный SVG.
+func WritePublicSummaryHTML(w io.Writer, s *PublicSummary) error {
+	return publicSummaryTemplate.Execute(w, publicSummaryView(s))
+}
+
+type summaryRange struct {
+	Label string
+	Share float64
+	Color string
+}
+
+type summaryFoodDay struct {
+	Day     string
+	Records []models.FoodRecord
+}
+
+type summaryView struct {
+	*PublicSummary
+	Name      string
+	Period    string
+	Days      int
+	Ranges    []summaryRange
+	Chart     template.HTML
+	FoodDays  []summaryFoodDay
+	Contexts  map[string]string
+	Location  *time.Location
+	ExpiresAt string
+}
+
+func publicSummaryView(s *PublicSummary) summaryView {
+	r := s.Report
+	name := r.User.FirstName
+	if r.User.LastName != "" {
+		name += " " + r.User.LastName
+	}
+	opts := ReportOptions{From: r.From, To: r.To}
+	view := summaryView{
+		PublicSummary: s,
+		Name:          name,
+		Period:        fmt.Sprintf("%s – %s", r.From.Format("02.01.2006"), r.To.Add(-time.Nanosecond).Format("02.01.2006")),
+		Days:          opts.Days(),
+		Ranges: []summaryRange{
+			{fmt.Sprintf("Очень низкий (< %.1f)", GlucoseVeryLow), r.Ranges.VeryLow, colorVeryLow.css()},
+			{fmt.Sprintf("Низкий (%.1f–%.1f)", GlucoseVeryLow, GlucoseLow-0.1), r.Ranges.Low, colorLow.css()},
+			{fmt.Sprintf("Целевой (%.1f–%.1f)", GlucoseLow, GlucoseHigh), r.Ranges.InRange, colorInRange.css()},
+			{fmt.Sprintf("Высокий (%.1f–%.1f)", GlucoseHigh+0.1, GlucoseVeryHigh), r.Ranges.High, colorHigh.css()},
+			{fmt.Sprintf("Очень высокий (> %.1f)", GlucoseVeryHigh), r.Ranges.VeryHigh, colorVeryHigh.css()},
+		},
+		Chart:     profileSVG(r.Profile),
+		Contexts:  reportContexts,
+		Location:  r.From.Location(),
+		ExpiresAt: s.ExpiresAt.Format("02.01.2006 15:04"),
+	}
+
+	for _, record := range s.Food {
+		day := record.ConsumedAt.In(view.Location).Format("02.01.2006")
+		if len(view.FoodDays) == 0 || view.FoodDays[len(view.FoodDays)-1].Day != day {
+			view.FoodDays = append(view.FoodDays, summaryFoodDay{Day: day})
+		}
+		last := &view.FoodDays[len(view.FoodDays)-1]
+		last.Records = append(last.Records, record)
+	}
+	return view
+}
+
+// profileSVG рисует суточный профиль так же, как PDF-отчет: целевой диапазон,
+// полосы перцентилей 5–95% и 25–75% и медиану по участкам часов с измерениями
+func profileSVG(profile []ProfileHour) template.HTML {
+	left, top := svgAxisWidth, 8.0
+	width, height := svgWidth-svgAxisWidth-8, svgHeight-svgAxisHeight-top
+
+	yMax := 14.0
+	for _, h := range profile {
+		if h.Count > 0 {
+			yMax = math.Max(yMax, math.Ceil(h.P95))
+		}
+	}
+	yMax = math.Min(yMax+1, 25)
+	const yMin = 2.0
+	xAt := func(hour float64) float64 { return left + width*hour/24 }
+	yAt := func(value float64) float64 {
+		value = math.Max(yMin, math.Min(yMax, value))
+		return top + height - height*(value-yMin)/(yMax-yMin)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %.0f %.0f" role="img" aria-label="Суточный профиль глюкозы">`, svgWidth, svgHeight)
+	fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`,
+		left, yAt(GlucoseHigh), width, yAt(GlucoseLow)-yAt(GlucoseHigh), colorTarget.css())
+	for v := 4.0; v <= yMax; v += 2 {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`, left, yAt(v), left+width, yAt(v), colorGrid.css())
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="end" font-size="11">%.0f</text>`, left-4, yAt(v)+4, v)
+	}
+	for hour := 0; hour <= 24; hour += 3 {
+		x := xAt(float64(hour))
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`, x, top, x, top+height, colorGrid.css())
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle" font-size="11">%02d:00</text>`, x, svgHeight-4, hour%24)
+	}
+
+	for _, run := range profileRuns(profile) {
+		xs := make([]float64, 0, len(run)+2)
+		xs = append(xs, xAt(float64(run[0].Hour)))
+		for _, h := range run {
+			xs = append(xs, xAt(float64(h.Hour)+0.5))
+		}
+		xs = append(xs, xAt(float64(run[len(run)-1].Hour+1)))
+		at := func(i int) ProfileHour { return run[max(0, min(len(run)-1, i-1))] }
+
+		band := func(lower, upper func(ProfileHour) float64, color rgb) {
+			points := make([]string, 0, 2*len(xs))
+			for i, x := range xs {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", x, yAt(upper(at(i)))))
+			}
+			for i := len(xs) - 1; i >= 0; i-- {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", xs[i], yAt(lower(at(i)))))
+			}
+			fmt.Fprintf(&b, `<polygon points="%s" fill="%s"/>`, strings.Join(points, " "), color.css())
+		}
+		band(func(h ProfileHour) float64 { return h.P5 }, func(h ProfileHour) float64 { return h.P95 }, colorOuterBand)
+		band(func(h ProfileHour) float64 { return h.P25 }, func(h ProfileHour) float64 { return h.P75 }, colorInnerBand)
+
+		median := make([]string, len(xs))
+		for i, x := range xs {
+			median[i] = fmt.Sprintf("%.1f,%.1f", x, yAt(at(i).P50))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(median, " "), colorMedian.css())
+	}
+
+	fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="none" stroke="#000"/></svg>`, left, top, width, height)
+	// Разметка собрана из чисел и констант, пользовательских данных в ней нет
+	return template.HTML(b.String())
+}
+
+var publicSummaryTemplate = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"percent": func(share float64) string { return fmt.Sprintf("%.0f%%", share*100) },
+	"width":   func(share float64) template.CSS { return template.CSS(fmt.Sprintf("width:%.2f%%", share*100)) },
+	"at": func(t time.Time, loc *time.Location, layout string) string {
+		return t.In(loc).Format(layout)
+	},
+	"optional": func(v *float64) string {
+		if v == nil {
+			return "—"
+		}
+		return fmt.Sprintf("%.0f", *v)
+	},
+	"calories": func(v *int) string {
+		if v == nil {
+			return "—"
+		}
+		return fmt.Sprintf("%d", *v)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>Дневник гликемии: {{.Name}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",Roboto,Arial,sans-serif;max-width:800px;margin:0 auto;padding:16px;color:#222;line-height:1.4}
+h1{font-size:24px;margin:0 0 4px}h2{font-size:18px;margin:28px 0 8px;border-bottom:1px solid #ddd;padding-bottom:4px}
+.muted{color:#6e6e6e;font-size:13px}
+table{border-collapse:collapse;width:100%;font-size:14px}th,td{text-align:left;padding:4px 8px;border-bottom:1px solid #eee}th{background:#eee}
+.stats td:first-child{color:#6e6e6e;width:45%}
+.bar{display:flex;height:20px;border-radius:3px;overflow:hidden;margin-bottom:8px}
+.swatch{display:inline-block;width:12px;height:12px;margin-right:6px;vertical-align:middle}
+svg{width:100%;height:auto;font-family:inherit}
+@media print{body{max-width:none}h2{break-after:avoid}}
+</style>
+</head>
+<body>
+<h1>Дневник гликемии</h1>
+<div>{{.Name}}</div>
+<div>Период: {{.Period}} ({{.Days}} дн.)</div>
+<div class="muted">Ссылка действует до {{.ExpiresAt}}. Данные из дневника DiabetBot, глюкоза в ммоль/л.</div>
+{{with .Report}}{{if eq .Glucose.Count 0}}
+<p>Нет измерений глюкозы за период.</p>
+{{else}}
+<h2>Сводка</h2>
+<table class="stats">
+<tr><td>Измерений</td><td>{{.Glucose.Count}} ({{printf "%.1f" .Glucose.PerDay}} в день)</td></tr>
+<tr><td>Средняя глюкоза</td><td>{{printf "%.1f" .Glucose.Average}} ммоль/л</td></tr>
+<tr><td>SD / CV</td><td>{{printf "%.1f" .Glucose.SD}} ммоль/л / {{printf "%.0f" .Glucose.CV}}%</td></tr>
+<tr><td>GMI</td><td>{{printf "%.1f" .Glucose.GMI}}%</td></tr>
+<tr><td>Минимум / максимум</td><td>{{printf "%.1f" .Glucose.Min}} / {{printf "%.1f" .Glucose.Max}} ммоль/л</td></tr>
+<tr><td>Гипогликемий</td><td>{{.HypoCount}}</td></tr>
+{{with .HbA1c}}<tr><td>HbA1c</td><td>{{printf "%.1f" .HbA1c}}% ({{at .Result.TakenAt $.Location "02.01.2006"}})</td></tr>
+<tr><td>GMI за 90 дней до анализа</td><td>{{.GMIText}}</td></tr>
+{{end}}</table>
+<p class="muted">GMI — оценка HbA1c по средней глюкозе. CV выше 36% говорит о высокой вариабельности.</p>
+{{end}}{{end}}
+{{if gt .Report.Glucose.Count 0}}
+<h2>Время в диапазоне</h2>
+<div class="bar">{{range .Ranges}}{{if gt .Share 0.0}}<div style="background:{{.Color}};{{width .Share}}"></div>{{end}}{{end}}</div>
+<table>{{range .Ranges}}
+<tr><td><span class="swatch" style="background:{{.Color}}"></span>{{.Label}}</td><td>{{percent .Share}}</td></tr>{{end}}
+</table>
+
+<h2>Суточный профиль</h2>
+{{.Chart}}
+<p class="muted">Измерения всех дней, наложенные на одни сутки: линия — медиана, темная полоса — 25–75%, светлая — 5–95%. Зеленым выделен целевой диапазон 3.9–10.0 ммоль/л.</p>
+
+<h2>Средние по времени суток</h2>
+<table>
+<tr><th>Время суток</th><th>Часы</th><th>Измерений</th><th>Средняя</th><th>Мин.</th><th>Макс.</th></tr>
+{{range .Report.TimeOfDay}}<tr><td>{{.Name}}</td><td>{{printf "%02d:00–%02d:00" .FromHour .ToHour}}</td><td>{{.Count}}</td>{{if gt .Count 0}}<td>{{printf "%.1f" .Average}}</td><td>{{printf "%.1f" .Min}}</td><td>{{printf "%.1f" .Max}}</td>{{else}}<td>—</td><td>—</td><td>—</td>{{end}}</tr>
+{{end}}</table>
+
+<h2>Гипогликемии (ниже 3.9 ммоль/л)</h2>
+{{if eq .Report.HypoCount 0}}<p>Гипогликемий за период не было.</p>{{else}}
+<table>
+<tr><th>Начало</th><th>Минимум</th><th>Измерений</th><th>Контекст</th></tr>
+{{range .Report.Hypos}}<tr><td>{{at .Start $.Location "02.01.2006 15:04"}}</td><td>{{printf "%.1f" .Nadir}}</td><td>{{.Readings}}</td><td>{{or (index $.Contexts .Context) "—"}}</td></tr>
+{{end}}</table>
+{{if gt .Report.HypoCount (len .Report.Hypos)}}<p class="muted">Показаны первые {{len .Report.Hypos}} из {{.Report.HypoCount}}.</p>{{end}}
+{{end}}{{end}}
+
+<h2>Дневник питания</h2>
+{{if eq .FoodCount 0}}<p>Нет записей питания за период.</p>{{else}}
+{{with .Report.Carbs}}<p>Углеводы в день: в среднем {{printf "%.0f" .DailyAvg}} г, от {{printf "%.0f" .DailyMin}} до {{printf "%.0f" .DailyMax}} г (дней с записями: {{.Days}}).</p>{{end}}
+<table>
+<tr><th>Время</th><th>Прием пищи</th><th>Что съедено</th><th>Углеводы, г</th><th>Ккал</th></tr>
+{{range .FoodDays}}<tr><th colspan="5">{{.Day}}</th></tr>
+{{range .Records}}<tr><td>{{at .ConsumedAt $.Location "15:04"}}</td><td>{{.FoodType}}</td><td>{{.FoodName}}{{if .Quantity}}, {{.Quantity}}{{end}}</td><td>{{optional .Carbs}}</td><td>{{calories .Calories}}</td></tr>
+{{end}}{{end}}</table>
+{{if gt .FoodCount (len .Food)}}<p class="muted">Показаны первые {{len .Food}} из {{.FoodCount}} записей.</p>{{end}}
+{{end}}
+
+<p class="muted">Сформировано {{.Report.GeneratedAt.Format "02.01.2006 15:04"}}. Сводка составлена по данным дневника DiabetBot и не заменяет консультацию врача.</p>
+</body>
+</html>
+`))
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// Границы диапазонов глюкозы, ммоль/л (международный консенсус по времени в диапазоне)
+const (
+	GlucoseVeryLow  = 3.0
+	GlucoseLow      = 3.9
+	GlucoseHigh     = 10.0
+	GlucoseVeryHigh = 13.9
+)
+
+// MaxReportDays — максимальный период отчета
+const MaxReportDays = 365
+
+// profileWindow — полуширина окна суточного профиля, часов
+const profileWindow = 1
+
+// maxReportHypos — сколько гипогликемий перечисляется в отчете, остальные только считаются
+const maxReportHypos = 30
+
+// ReportFileName возвращает имя файла отчета, например diabetbot-report-2024-05-01.pdf
+func ReportFileName(now time.Time) string {
+	return fmt.Sprintf("diabetbot-report-%s.pdf", now.Format("2006-01-02"))
+}
+
+// ReportOptions — период отчета, To не включается. Часы суточного профиля
+// и времени суток считаются в часовом поясе From.
+type ReportOptions struct {
+	From time.Time
+	To   time.Time
+}
+
+// Validate проверяет период отчета
+func (o ReportOptions) Validate() error {
+	if o.From.IsZero() || o.To.IsZero() || !o.From.Before(o.To) {
+		return ErrInvalidRange
+	}
+	if o.To.Sub(o.From) > MaxReportDays*24*time.Hour {
+		return &ValidationError{Field: "days", Rule: "between", Param: fmt.Sprintf("1 %d", MaxReportDays)}
+	}
+	return nil
+}
+
+// Days возвращает длительность периода в днях, округленную вверх
+func (o ReportOptions) Days() int {
+	return int(math.Ceil(o.To.Sub(o.From).Hours() / 24))
+}
+
+// Report — данные отчета для врача за период
+type Report struct {
+	User        *models.User
+	From        time.Time
+	To          time.Time
+	GeneratedAt time.Time
+
+	Glucose   GlucoseSummary
+	Ranges    RangeDistribution
+	Profile   []ProfileHour // 24 часа, часы без измерений имеют Count = 0
+	TimeOfDay []TimeOfDayStats
+	Hypos     []HypoEvent // первые maxReportHypos событий
+	HypoCount int         // всего событий за период
+	Meals     []MealSummary
+	Carbs     CarbsSummary
+	HbA1c     *HbA1cComparison // последний HbA1c не старше полугода на конец периода, nil — нет
+}
+
+// GlucoseSummary — сводка измерений глюкозы за период
+type GlucoseSummary struct {
+	Count   int
+	PerDay  float64
+	Average float64
+	SD      float64 // стандартное отклонение
+	CV      float64 // коэффициент вариации, %
+	GMI     float64 // индикатор управления гликемией, %
+	Min     float64
+	Max     float64
+}
+
+// RangeDistribution — доли измерений по диапазонам, в сумме 1
+type RangeDistribution struct {
+	VeryLow  float64 // < 3.0
+	Low      float64 // 3.0–3.8
+	InRange  float64 // 3.9–10.0
+	High     float64 // 10.1–13.9
+	VeryHigh float64 // > 13.9
+}
+
+// ProfileHour — перцентили глюкозы около часа суток по всем дням периода
+type ProfileHour struct {
+	Hour  int
+	Count int
+	P5    float64
+	P25   float64
+	P50   float64
+	P75   float64
+	P95   float64
+}
+
+// TimeOfDayStats — глюкоза в части суток [FromHour, ToHour)
+type TimeOfDayStats struct {
+	Name     string
+	FromHour int
+	ToHour   int
+	Count    int
+	Average  float64
+	Min      float64
+	Max      float64
+}
+
+// HypoEvent — подряд идущие измерения ниже GlucoseLow
+type HypoEvent struct {
+	Start    time.Time
+	Nadir    float64
+	Readings int
+	Context  string // контекст первого измерения
+}
+
+// MealSummary — приемы пищи одного типа
+type MealSummary struct {
+	FoodType    string
+	Count       int
+	AvgCarbs    *float64 // по записям с углеводами
+	AvgCalories *float64 // по записям с калориями
+}
+
+// CarbsSummary — углеводы в днях, где есть записи питания
+type CarbsSummary struct {
+	Days     int
+	DailyAvg float64
+	DailyMin float64
+	DailyMax float64
+}
+
+// timesOfDay — части суток для средних по времени суток
+var timesOfDay = []struct {
+	name     string
+	fromHour int
+	toHour   int
+}{
+	{"Ночь", 0, 6},
+	{"Утро", 6, 12},
+	{"День", 12, 18},
+	{"Вечер", 18, 24},
+}
+
+// mealOrder — порядок типов приема пищи в отчете, остальные идут следом по алфавиту
+var mealOrder = map[string]int{"завтрак": 0, "обед": 1, "ужин": 2, "перекус": 3}
+
+// ReportService собирает отчет для врача
+type ReportService struct {
+	glucose repository.GlucoseRepository
+	food    repository.FoodRepository
+	labs    *LabService // nil — отчет без HbA1c
+}
+
+func NewReportService(glucose repository.GlucoseRepository, food repository.FoodRepository) *ReportService {
+	return &ReportService{glucose: glucose, food: food}
+}
+
+// Build собирает отчет пользователя за период
+func (s *ReportService) Build(user *models.User, opts ReportOptions) (*Report, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := repository.ListQuery{From: opts.From, To: opts.To, Ascending: true}
+	glucose, err := s.glucose.List(user.ID, query, "")
+	if err != nil {
+		return nil, err
+	}
+	food, err := s.food.List(user.ID, query, "")
+	if err != nil {
+		return nil, err
+	}
+
+	loc := opts.From.Location()
+	report := &Report{
+		User:        user,
+		From:        opts.From,
+		To:          opts.To,
+		GeneratedAt: time.Now().In(loc),
+		Glucose:     summarizeGlucose(glucose, opts.Days()),
+		Ranges:      rangeDistribution(glucose),
+		Profile:     glucoseProfile(glucose, loc),
+		TimeOfDay:   timeOfDayStats(glucose, loc),
+		Meals:       mealSummaries(food),
+		Carbs:       carbsSummary(food, loc),
+	}
+	hypos := hypoEvents(glucose)
+	report.HypoCount = len(hypos)
+	if len(hypos) > maxReportHypos {
+		hypos = hypos[:maxReportHypos]
+	}
+	report.Hypos = hypos
+
+	if s.labs != nil {
+		comparison, err := s.labs.CompareHbA1c(user.ID, opts.To)
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			return nil, err
+		case opts.To.Sub(comparison.Result.TakenAt) <= hba1cReportAge:
+			report.HbA1c = comparison
+		}
+	}
+	return report, nil
+}
+
+// GMI оценивает HbA1c по средней глюкозе: GMI(%) = 3.31 + 0.02392 × средняя (мг/дл)
+func GMI(averageMmol float64) float64 {
+	return 3.31 + 0.02392*averageMmol*18.0182
+}
+
+func summarizeGlucose(records []models.GlucoseRecord, days int) GlucoseSummary {
+	summary := GlucoseSummary{Count: len(records)}
+	if len(records) == 0 {
+		return summary
+	}
+
+	summary.Min, summary.Max = records[0].Value, records[0].Value
+	var sum float64
+	for _, r := range records {
+		sum += r.Value
+		summary.Min = math.Min(summary.Min, r.Value)
+		summary.Max = math.Max(summary.Max, r.Value)
+	}
+	summary.Average = sum / float64(len(records))
+
+	if len(records) > 1 {
+		var squares float64
+		for _, r := range records {
+			squares += (r.Value - summary.Average) * (r.Value - summary.Average)
+		}
+		summary.SD = math.Sqrt(squares / float64(len(records)-1))
+	}
+	summary.CV = summary.SD / summary.Average * 100
+	summary.GMI = GMI(summary.Average)
+	if days > 0 {
+		summary.PerDay = float64(len(records)) / float64(days)
+	}
+	return summary
+}
+
+func rangeDistribution(records []models.GlucoseRecord) RangeDistribution {
+	var dist RangeDistribution
+	if len(records) == 0 {
+		return dist
+	}
+
+	share := 1 / float64(len(records))
+	for _, r := range records {
+		switch {
+		case r.Value < GlucoseVeryLow:
+			dist.VeryLow += share
+		case r.Value < GlucoseLow:
+			dist.Low += share
+		case r.Value <= GlucoseHigh:
+			dist.InRange += share
+		case r.Value <= GlucoseVeryHigh:
+			dist.High += share
+		default:
+			dist.VeryHigh += share
+		}
+	}
+	return dist
+}
+
+// glucoseProfile считает перцентили для каждого часа суток по измерениям в окне
+// ±profileWindow часов: у глюкометра измерений мало, и без сглаживания кривые рвутся.
+func glucoseProfile(records []models.GlucoseRecord, loc *time.Location) []ProfileHour {
+	byHour := make([][]float64, 24)
+	for _, r := range records {
+		hour := r.MeasuredAt.In(loc).Hour()
+		byHour[hour] = append(byHour[hour], r.Value)
+	}
+
+	profile := make([]ProfileHour, 24)
+	for hour := range profile {
+		var values []float64
+		for offset := -profileWindow; offset <= profileWindow; offset++ {
+			values = append(values, byHour[(hour+offset+24)%24]...)
+		}
+		profile[hour] = ProfileHour{Hour: hour, Count: len(values)}
+		if len(values) == 0 {
+			continue
+		}
+		sort.Float64s(values)
+		profile[hour].P5 = percentile(values, 5)
+		profile[hour].P25 = percentile(values, 25)
+		profile[hour].P50 = percentile(values, 50)
+		profile[hour].P75 = percentile(values, 75)
+		profile[hour].P95 = percentile(values, 95)
+	}
+	return profile
+}
+
+// percentile возвращает перцентиль p отсортированных значений с линейной интерполяцией
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+func timeOfDayStats(records []models.GlucoseRecord, loc *time.Location) []TimeOfDayStats {
+	stats := make([]TimeOfDayStats, len(timesOfDay))
+	sums := make([]float64, len(timesOfDay))
+	for i, part := range timesOfDay {
+		stats[i] = TimeOfDayStats{Name: part.name, FromHour: part.fromHour, ToHour: part.toHour}
+	}
+
+	for _, r := range records {
+		hour := r.MeasuredAt.In(loc).Hour()
+		for i := range stats {
+			if hour < stats[i].FromHour || hour >= stats[i].ToHour {
+				continue
+			}
+			if stats[i].Count == 0 || r.Value < stats[i].Min {
+				stats[i].Min = r.Value
+			}
+			if stats[i].Count == 0 || r.Value > stats[i].Max {
+				stats[i].Max = r.Value
+			}
+			stats[i].Count++
+			sums[i] += r.Value
+		}
+	}
+
+	for i := range stats {
+		if stats[i].Count > 0 {
+			stats[i].Average = sums[i] / float64(stats[i].Count)
+		}
+	}
+	return stats
+}
+
+// hypoEvents объединяет подряд идущие измерения ниже GlucoseLow в события.
+// records должны быть упорядочены по времени.
+func hypoEvents(records []models.GlucoseRecord) []HypoEvent {
+	var events []HypoEvent
+	inEvent := false
+	for _, r := range records {
+		if r.Value >= GlucoseLow {
+			inEvent = false
+			continue
+		}
+		if !inEvent {
+			events = append(events, HypoEvent{Start: r.MeasuredAt, Nadir: r.Value, Context: r.MeasurementContext})
+			inEvent = true
+		}
+		event := &events[len(events)-1]
+		event.Readings++
+		event.Nadir = math.Min(event.Nadir, r.Value)
+	}
+	return events
+}
+
+func mealSummaries(records []models.FoodRecord) []MealSummary {
+	type totals struct {
+		count                   int
+		carbs, calories         float64
+		withCarbs, withCalories int
+	}
+	byType := make(map[string]*totals)
+	for _, r := range records {
+		t := byType[r.FoodType]
+		if t == nil {
+			t = &totals{}
+			byType[r.FoodType] = t
+		}
+		t.count++
+		if r.Carbs != nil {
+			t.carbs += *r.Carbs
+			t.withCarbs++
+		}
+		if r.Calories != nil {
+			t.calories += float64(*r.Calories)
+			t.withCalories++
+		}
+	}
+
+	meals := make([]MealSummary, 0, len(byType))
+	for foodType, t := range byType {
+		meal := MealSummary{FoodType: foodType, Count: t.count}
+		if t.withCarbs > 0 {
+			avg := t.carbs / float64(t.withCarbs)
+			meal.AvgCarbs = &avg
+		}
+		if t.withCalories > 0 {
+			avg := t.calories / float64(t.withCalories)
+			meal.AvgCalories = &avg
+		}
+		meals = append(meals, meal)
+	}
+
+	sort.Slice(meals, func(i, j int) bool {
+		oi, iKnown := mealOrder[meals[i].FoodType]
+		oj, jKnown := mealOrder[meals[j].FoodType]
+		if iKnown != jKnown {
+			return iKnown
+		}
+		if iKnown {
+			return oi < oj
+		}
+		return meals[i].FoodType < meals[j].FoodType
+	})
+	return meals
+}
+
+func carbsSummary(records []models.FoodRecord, loc *time.Location) CarbsSummary {
+	daily := make(map[time.Time]float64)
+	for _, r := range records {
+		day := startOfDay(r.ConsumedAt.In(loc))
+		carbs := daily[day]
+		if r.Carbs != nil {
+			carbs += *r.Carbs
+		}
+		daily[day] = carbs
+	}
+
+	summary := CarbsSummary{Days: len(daily)}
+	if len(daily) == 0 {
+		return summary
+	}
+	first := true
+	var sum float64
+	for _, carbs := range daily {
+		sum += carbs
+		if first || carbs < summary.DailyMin {
+			summary.DailyMin = carbs
+		}
+		if first || carbs > summary.DailyMax {
+			summary.DailyMax = carbs
+		}
+		first = false
+	}
+	summary.DailyAvg = sum / float64(len(daily))
+	return summary
+}
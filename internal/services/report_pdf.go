@@ -0,0 +1,419 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"diabetbot/internal/models"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Разметка страницы A4, мм
+const (
+	pdfMargin      = 15.0
+	pdfWidth       = 210.0 - 2*pdfMargin
+	pdfLineHeight  = 6.0
+	pdfChartHeight = 70.0
+	pdfAxisWidth   = 10.0
+)
+
+type rgb struct{ r, g, b int }
+
+// Цвета диапазонов и суточного профиля
+var (
+	colorVeryLow   = rgb{183, 28, 28}
+	colorLow       = rgb{229, 57, 53}
+	colorInRange   = rgb{67, 160, 71}
+	colorHigh      = rgb{251, 192, 45}
+	colorVeryHigh  = rgb{245, 124, 0}
+	colorTarget    = rgb{232, 245, 233}
+	colorOuterBand = rgb{198, 219, 239}
+	colorInnerBand = rgb{107, 174, 214}
+	colorMedian    = rgb{8, 81, 156}
+	colorGrid      = rgb{220, 220, 220}
+	colorHeader    = rgb{238, 238, 238}
+	colorMuted     = rgb{110, 110, 110}
+)
+
+// reportContexts — контекст измерения в таблице гипогликемий
+var reportContexts = map[string]string{
+	models.GlucoseContextFasting:    "натощак",
+	models.GlucoseContextBeforeMeal: "до еды",
+	models.GlucoseContextAfterMeal:  "после еды",
+	models.GlucoseContextBedtime:    "перед сном",
+}
+
+// WriteReportPDF рисует отчет в PDF. Шрифты Go встроены в бинарник и содержат кириллицу.
+func WriteReportPDF(w io.Writer, r *Report) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetCreationDate(r.GeneratedAt)
+	pdf.SetCatalogSort(true)
+	pdf.SetTitle("DiabetBot: отчет о гликемии", true)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin+5)
+	pdf.AddUTF8FontFromBytes("go", "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes("go", "B", gobold.TTF)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pdfMargin)
+		pdf.SetFont("go", "", 8)
+		setTextColor(pdf, colorMuted)
+		pdf.CellFormat(pdfWidth-20, 5, "Отчет составлен по данным дневника DiabetBot и не заменяет консультацию врача", "", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 5, fmt.Sprintf("Стр. %d", pdf.PageNo()), "", 0, "R", false, 0, "")
+		setTextColor(pdf, rgb{})
+	})
+	pdf.AddPage()
+
+	doc := reportPDF{pdf: pdf, r: r}
+	doc.header()
+	if r.Glucose.Count == 0 {
+		doc.paragraph("Нет измерений глюкозы за выбранный период.")
+	} else {
+		doc.summary()
+		doc.ranges()
+		doc.profile()
+		doc.timeOfDay()
+		doc.hypos()
+	}
+	doc.meals()
+
+	return pdf.Output(w)
+}
+
+// reportPDF рисует разделы отчета по очереди сверху вниз
+type reportPDF struct {
+	pdf *fpdf.Fpdf
+	r   *Report
+}
+
+func (d reportPDF) header() {
+	pdf := d.pdf
+	pdf.SetFont("go", "B", 18)
+	pdf.CellFormat(pdfWidth, 10, "Отчет о гликемии", "", 1, "L", false, 0, "")
+
+	pdf.SetFont("go", "", 11)
+	name := d.r.User.FirstName
+	if d.r.User.LastName != "" {
+		name += " " + d.r.User.LastName
+	}
+	lastDay := d.r.To.Add(-time.Nanosecond)
+	days := ReportOptions{From: d.r.From, To: d.r.To}.Days()
+	pdf.CellFormat(pdfWidth, pdfLineHeight, name, "", 1, "L", false, 0, "")
+	pdf.CellFormat(pdfWidth, pdfLineHeight, fmt.Sprintf("Период: %s – %s (%d дн.)",
+		d.r.From.Format("02.01.2006"), lastDay.Format("02.01.2006"), days), "", 1, "L", false, 0, "")
+
+	setTextColor(pdf, colorMuted)
+	pdf.SetFontSize(9)
+	pdf.CellFormat(pdfWidth, 5, "Сформирован "+d.r.GeneratedAt.Format("02.01.2006 15:04"), "", 1, "L", false, 0, "")
+	setTextColor(pdf, rgb{})
+	pdf.Ln(3)
+}
+
+func (d reportPDF) summary() {
+	g := d.r.Glucose
+	d.heading("Сводка")
+	items := [][2]string{
+		{"Измерений", fmt.Sprintf("%d (%.1f в день)", g.Count, g.PerDay)},
+		{"Средняя глюкоза", fmt.Sprintf("%.1f ммоль/л", g.Average)},
+		{"SD / CV", fmt.Sprintf("%.1f ммоль/л / %.0f%%", g.SD, g.CV)},
+		{"GMI", fmt.Sprintf("%.1f%%", g.GMI)},
+		{"Минимум / максимум", fmt.Sprintf("%.1f / %.1f ммоль/л", g.Min, g.Max)},
+		{"Гипогликемий", fmt.Sprintf("%d", d.r.HypoCount)},
+	}
+	if c := d.r.HbA1c; c != nil {
+		items = append(items,
+			[2]string{"HbA1c", fmt.Sprintf("%.1f%% (%s)", c.HbA1c, c.Result.TakenAt.In(d.r.From.Location()).Format("02.01.2006"))},
+			[2]string{"GMI до анализа", c.GMIText()},
+		)
+	}
+
+	pdf := d.pdf
+	column := pdfWidth / 2
+	for i, item := range items {
+		pdf.SetFont("go", "", 10)
+		setTextColor(pdf, colorMuted)
+		pdf.CellFormat(column*0.45, pdfLineHeight, item[0], "", 0, "L", false, 0, "")
+		setTextColor(pdf, rgb{})
+		pdf.SetFont("go", "B", 10)
+		ln := 0
+		if i%2 == 1 {
+			ln = 1
+		}
+		pdf.CellFormat(column*0.55, pdfLineHeight, item[1], "", ln, "L", false, 0, "")
+	}
+	d.note("GMI — оценка HbA1c по средней глюкозе. CV выше 36% говорит о высокой вариабельности.")
+	if d.r.HbA1c != nil {
+		d.note(fmt.Sprintf("Измеренный HbA1c сравнивается с GMI за %d дней до анализа. "+
+			"Расхождение больше %.1f бывает при редких измерениях и при состояниях, влияющих на эритроциты.",
+			HbA1cGlucoseDays, hba1cDiscordance))
+	}
+}
+
+func (d reportPDF) ranges() {
+	dist := d.r.Ranges
+	segments := []struct {
+		label string
+		share float64
+		color rgb
+	}{
+		{fmt.Sprintf("Очень низкий (< %.1f)", GlucoseVeryLow), dist.VeryLow, colorVeryLow},
+		{fmt.Sprintf("Низкий (%.1f–%.1f)", GlucoseVeryLow, GlucoseLow-0.1), dist.Low, colorLow},
+		{fmt.Sprintf("Целевой (%.1f–%.1f)", GlucoseLow, GlucoseHigh), dist.InRange, colorInRange},
+		{fmt.Sprintf("Высокий (%.1f–%.1f)", GlucoseHigh+0.1, GlucoseVeryHigh), dist.High, colorHigh},
+		{fmt.Sprintf("Очень высокий (> %.1f)", GlucoseVeryHigh), dist.VeryHigh, colorVeryHigh},
+	}
+
+	d.ensureSpace(40 + float64(len(segments))*5)
+	d.heading("Время в диапазоне")
+	pdf := d.pdf
+	x, y := pdf.GetX(), pdf.GetY()
+	for _, s := range segments {
+		if s.share <= 0 {
+			continue
+		}
+		setFillColor(pdf, s.color)
+		pdf.Rect(x, y, pdfWidth*s.share, 8, "F")
+		x += pdfWidth * s.share
+	}
+	pdf.SetY(y + 10)
+
+	pdf.SetFont("go", "", 10)
+	for _, s := range segments {
+		y := pdf.GetY()
+		setFillColor(pdf, s.color)
+		pdf.Rect(pdfMargin, y+1, 3, 3, "F")
+		pdf.SetX(pdfMargin + 5)
+		pdf.CellFormat(60, 5, s.label, "", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 5, fmt.Sprintf("%.0f%%", s.share*100), "", 1, "R", false, 0, "")
+	}
+	d.note("Доли измерений по диапазонам, ммоль/л. Цель для большинства взрослых — более 70% в целевом диапазоне и менее 4% ниже 3.9.")
+}
+
+func (d reportPDF) profile() {
+	d.ensureSpace(pdfChartHeight + 40)
+	d.heading("Суточный профиль")
+
+	pdf := d.pdf
+	left := pdfMargin + pdfAxisWidth
+	width := pdfWidth - pdfAxisWidth
+	top := pdf.GetY() + 2
+	bottom := top + pdfChartHeight
+
+	yMax := 14.0
+	for _, h := range d.r.Profile {
+		if h.Count > 0 {
+			yMax = math.Max(yMax, math.Ceil(h.P95))
+		}
+	}
+	yMax = math.Min(yMax+1, 25)
+	const yMin = 2.0
+	xAt := func(hour float64) float64 { return left + width*hour/24 }
+	yAt := func(value float64) float64 {
+		value = math.Max(yMin, math.Min(yMax, value))
+		return bottom - pdfChartHeight*(value-yMin)/(yMax-yMin)
+	}
+
+	// Целевой диапазон и сетка
+	setFillColor(pdf, colorTarget)
+	pdf.Rect(left, yAt(GlucoseHigh), width, yAt(GlucoseLow)-yAt(GlucoseHigh), "F")
+	pdf.SetFont("go", "", 8)
+	pdf.SetLineWidth(0.1)
+	setDrawColor(pdf, colorGrid)
+	for v := 4.0; v <= yMax; v += 2 {
+		pdf.Line(left, yAt(v), left+width, yAt(v))
+		pdf.SetXY(pdfMargin, yAt(v)-2)
+		pdf.CellFormat(pdfAxisWidth-1, 4, fmt.Sprintf("%.0f", v), "", 0, "R", false, 0, "")
+	}
+	for hour := 0; hour <= 24; hour += 3 {
+		pdf.Line(xAt(float64(hour)), top, xAt(float64(hour)), bottom)
+		pdf.SetXY(xAt(float64(hour))-6, bottom+1)
+		pdf.CellFormat(12, 4, fmt.Sprintf("%02d:00", hour%24), "", 0, "C", false, 0, "")
+	}
+
+	// Полосы перцентилей рисуются по непрерывным участкам часов с измерениями
+	for _, run := range profileRuns(d.r.Profile) {
+		xs := make([]float64, 0, len(run)+2)
+		xs = append(xs, xAt(float64(run[0].Hour)))
+		for _, h := range run {
+			xs = append(xs, xAt(float64(h.Hour)+0.5))
+		}
+		xs = append(xs, xAt(float64(run[len(run)-1].Hour+1)))
+		// Крайние точки повторяют значения первого и последнего часа
+		at := func(i int) ProfileHour { return run[max(0, min(len(run)-1, i-1))] }
+
+		band := func(lower, upper func(ProfileHour) float64, color rgb) {
+			points := make([]fpdf.PointType, 0, 2*len(xs))
+			for i, x := range xs {
+				points = append(points, fpdf.PointType{X: x, Y: yAt(upper(at(i)))})
+			}
+			for i := len(xs) - 1; i >= 0; i-- {
+				points = append(points, fpdf.PointType{X: xs[i], Y: yAt(lower(at(i)))})
+			}
+			setFillColor(pdf, color)
+			pdf.Polygon(points, "F")
+		}
+		band(func(h ProfileHour) float64 { return h.P5 }, func(h ProfileHour) float64 { return h.P95 }, colorOuterBand)
+		band(func(h ProfileHour) float64 { return h.P25 }, func(h ProfileHour) float64 { return h.P75 }, colorInnerBand)
+
+		setDrawColor(pdf, colorMedian)
+		pdf.SetLineWidth(0.6)
+		for i := 1; i < len(xs); i++ {
+			pdf.Line(xs[i-1], yAt(at(i-1).P50), xs[i], yAt(at(i).P50))
+		}
+		pdf.SetLineWidth(0.1)
+	}
+
+	setDrawColor(pdf, rgb{})
+	pdf.Rect(left, top, width, pdfChartHeight, "D")
+	pdf.SetXY(pdfMargin, bottom+6)
+	d.note("Измерения всех дней, наложенные на одни сутки: линия — медиана, темная полоса — 25–75%, светлая — 5–95%. Зеленым выделен целевой диапазон 3.9–10.0 ммоль/л.")
+}
+
+// profileRuns делит часы суточного профиля на непрерывные участки с измерениями
+func profileRuns(profile []ProfileHour) [][]ProfileHour {
+	var runs [][]ProfileHour
+	var run []ProfileHour
+	for _, h := range profile {
+		if h.Count == 0 {
+			if len(run) > 0 {
+				runs = append(runs, run)
+			}
+			run = nil
+			continue
+		}
+		run = append(run, h)
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func (d reportPDF) timeOfDay() {
+	d.heading("Средние по времени суток")
+	rows := make([][]string, 0, len(d.r.TimeOfDay))
+	for _, s := range d.r.TimeOfDay {
+		row := []string{s.Name, fmt.Sprintf("%02d:00–%02d:00", s.FromHour, s.ToHour), fmt.Sprintf("%d", s.Count), "—", "—", "—"}
+		if s.Count > 0 {
+			row[3] = fmt.Sprintf("%.1f", s.Average)
+			row[4] = fmt.Sprintf("%.1f", s.Min)
+			row[5] = fmt.Sprintf("%.1f", s.Max)
+		}
+		rows = append(rows, row)
+	}
+	d.table([]string{"Время суток", "Часы", "Измерений", "Средняя", "Мин.", "Макс."},
+		[]float64{40, 36, 26, 26, 26, 26}, rows)
+	d.note("Глюкоза в ммоль/л.")
+}
+
+func (d reportPDF) hypos() {
+	d.heading(fmt.Sprintf("Гипогликемии (ниже %.1f ммоль/л)", GlucoseLow))
+	if d.r.HypoCount == 0 {
+		d.paragraph("Гипогликемий за период не было.")
+		return
+	}
+
+	rows := make([][]string, 0, len(d.r.Hypos))
+	for _, h := range d.r.Hypos {
+		context := reportContexts[h.Context]
+		if context == "" {
+			context = "—"
+		}
+		rows = append(rows, []string{
+			h.Start.In(d.r.From.Location()).Format("02.01.2006 15:04"),
+			fmt.Sprintf("%.1f", h.Nadir),
+			fmt.Sprintf("%d", h.Readings),
+			context,
+		})
+	}
+	d.table([]string{"Начало", "Минимум", "Измерений", "Контекст"}, []float64{50, 35, 35, 60}, rows)
+	if d.r.HypoCount > len(d.r.Hypos) {
+		d.note(fmt.Sprintf("Показаны первые %d из %d.", len(d.r.Hypos), d.r.HypoCount))
+	}
+	d.note("Подряд идущие измерения ниже 3.9 считаются одним эпизодом.")
+}
+
+func (d reportPDF) meals() {
+	d.heading("Питание")
+	if len(d.r.Meals) == 0 {
+		d.paragraph("Нет записей питания за выбранный период.")
+		return
+	}
+
+	optional := func(v *float64) string {
+		if v == nil {
+			return "—"
+		}
+		return fmt.Sprintf("%.0f", *v)
+	}
+	rows := make([][]string, 0, len(d.r.Meals))
+	for _, m := range d.r.Meals {
+		foodType := m.FoodType
+		if foodType == "" {
+			foodType = "без типа"
+		}
+		rows = append(rows, []string{foodType, fmt.Sprintf("%d", m.Count), optional(m.AvgCarbs), optional(m.AvgCalories)})
+	}
+	d.table([]string{"Прием пищи", "Записей", "Углеводы, г", "Калории, ккал"}, []float64{60, 30, 45, 45}, rows)
+	d.note("Углеводы и калории — в среднем на прием пищи, по записям, где они указаны.")
+
+	c := d.r.Carbs
+	d.paragraph(fmt.Sprintf("Углеводы в день: в среднем %.0f г, от %.0f до %.0f г (дней с записями: %d).",
+		c.DailyAvg, c.DailyMin, c.DailyMax, c.Days))
+}
+
+func (d reportPDF) heading(text string) {
+	d.ensureSpace(20)
+	d.pdf.Ln(3)
+	d.pdf.SetFont("go", "B", 13)
+	d.pdf.CellFormat(pdfWidth, 8, text, "", 1, "L", false, 0, "")
+}
+
+func (d reportPDF) paragraph(text string) {
+	d.pdf.SetFont("go", "", 10)
+	d.pdf.MultiCell(pdfWidth, 5, text, "", "L", false)
+}
+
+func (d reportPDF) note(text string) {
+	d.pdf.SetFont("go", "", 8)
+	setTextColor(d.pdf, colorMuted)
+	d.pdf.MultiCell(pdfWidth, 4, text, "", "L", false)
+	setTextColor(d.pdf, rgb{})
+}
+
+// table рисует таблицу с заголовком; строки переносятся на новую страницу автоматически
+func (d reportPDF) table(headers []string, widths []float64, rows [][]string) {
+	pdf := d.pdf
+	d.ensureSpace(pdfLineHeight * 3)
+	pdf.SetFont("go", "B", 9)
+	setFillColor(pdf, colorHeader)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], pdfLineHeight, header, "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("go", "", 9)
+	for _, row := range rows {
+		for i, cell := range row {
+			pdf.CellFormat(widths[i], pdfLineHeight, cell, "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+// ensureSpace начинает новую страницу, если до нижнего поля меньше height мм
+func (d reportPDF) ensureSpace(height float64) {
+	_, pageHeight := d.pdf.GetPageSize()
+	_, _, _, bottom := d.pdf.GetMargins()
+	if d.pdf.GetY()+height > pageHeight-bottom-5 {
+		d.pdf.AddPage()
+	}
+}
+
+func setFillColor(pdf *fpdf.Fpdf, c rgb) { pdf.SetFillColor(c.r, c.g, c.b) }
+func setDrawColor(pdf *fpdf.Fpdf, c rgb) { pdf.SetDrawColor(c.r, c.g, c.b) }
+func setTextColor(pdf *fpdf.Fpdf, c rgb) { pdf.SetTextColor(c.r, c.g, c.b) }
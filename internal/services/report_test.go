@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportService_Build(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(910, "", "Мария", "Иванова", "ru")
+	require.NoError(t, err)
+
+	loc := time.FixedZone("MSK", 3*60*60)
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, loc)
+	at := func(day, hour, minute int) time.Time {
+		return from.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	for _, g := range []struct {
+		at      time.Time
+		value   float64
+		context string
+	}{
+		{at(0, 7, 0), 5.5, models.GlucoseContextFasting},
+		{at(0, 13, 0), 11.0, ""},
+		{at(0, 23, 0), 3.5, models.GlucoseContextBedtime}, // гипогликемия из двух измерений
+		{at(0, 23, 20), 2.8, ""},
+		{at(1, 7, 10), 6.5, models.GlucoseContextFasting},
+		{at(1, 19, 0), 15.0, ""},
+		{at(2, 7, 30), 3.7, ""}, // отдельная гипогликемия
+		{at(2, 12, 0), 8.0, ""},
+	} {
+		_, err := svc.Glucose.CreateRecordAt(user.ID, g.value, g.at, g.context, "")
+		require.NoError(t, err)
+	}
+	// Вне периода
+	_, err = svc.Glucose.CreateRecordAt(user.ID, 20, from.AddDate(0, 0, -1), "", "")
+	require.NoError(t, err)
+
+	carbs := func(v float64) *float64 { return &v }
+	calories := 400
+	for _, f := range []struct {
+		at       time.Time
+		foodType string
+		carbs    *float64
+		calories *int
+	}{
+		{at(0, 8, 0), "завтрак", carbs(40), &calories},
+		{at(1, 8, 0), "завтрак", carbs(60), nil},
+		{at(0, 13, 0), "обед", nil, nil},
+		{at(1, 16, 0), "полдник", carbs(20), nil},
+		{at(1, 10, 0), "перекус", carbs(15), nil},
+	} {
+		_, err := svc.Food.CreateRecordAt(user.ID, "еда", f.foodType, f.carbs, f.calories, "", "", f.at)
+		require.NoError(t, err)
+	}
+
+	report, err := svc.Report.Build(user, ReportOptions{From: from, To: from.AddDate(0, 0, 4)})
+	require.NoError(t, err)
+
+	g := report.Glucose
+	assert.Equal(t, 8, g.Count)
+	assert.InDelta(t, 2.0, g.PerDay, 0.001)
+	assert.InDelta(t, 7.0, g.Average, 0.001)
+	assert.Equal(t, 2.8, g.Min)
+	assert.Equal(t, 15.0, g.Max)
+	assert.InDelta(t, 4.214, g.SD, 0.001)
+	assert.InDelta(t, g.SD/g.Average*100, g.CV, 0.001)
+	assert.InDelta(t, 6.33, g.GMI, 0.01)
+
+	assert.InDelta(t, 1.0/8, report.Ranges.VeryLow, 0.001)
+	assert.InDelta(t, 2.0/8, report.Ranges.Low, 0.001)
+	assert.InDelta(t, 3.0/8, report.Ranges.InRange, 0.001)
+	assert.InDelta(t, 1.0/8, report.Ranges.High, 0.001)
+	assert.InDelta(t, 1.0/8, report.Ranges.VeryHigh, 0.001)
+
+	// Часы считаются в часовом поясе периода, окно профиля ±1 час
+	require.Len(t, report.Profile, 24)
+	assert.Equal(t, 3, report.Profile[7].Count)
+	assert.Equal(t, 3, report.Profile[8].Count)
+	assert.InDelta(t, 5.5, report.Profile[7].P50, 0.001)
+	assert.InDelta(t, 4.6, report.Profile[7].P25, 0.001)
+	assert.InDelta(t, 6.4, report.Profile[7].P95, 0.001)
+	assert.Equal(t, 2, report.Profile[0].Count)
+	assert.Zero(t, report.Profile[3].Count)
+
+	require.Len(t, report.TimeOfDay, 4)
+	assert.Equal(t, "Утро", report.TimeOfDay[1].Name)
+	assert.Equal(t, 3, report.TimeOfDay[1].Count)
+	assert.InDelta(t, 5.233, report.TimeOfDay[1].Average, 0.001)
+	assert.Zero(t, report.TimeOfDay[0].Count)
+	assert.Equal(t, 3, report.TimeOfDay[3].Count)
+	assert.Equal(t, 2.8, report.TimeOfDay[3].Min)
+
+	require.Len(t, report.Hypos, 2)
+	assert.Equal(t, 2, report.HypoCount)
+	assert.True(t, at(0, 23, 0).Equal(report.Hypos[0].Start))
+	assert.Equal(t, 2.8, report.Hypos[0].Nadir)
+	assert.Equal(t, 2, report.Hypos[0].Readings)
+	assert.Equal(t, models.GlucoseContextBedtime, report.Hypos[0].Context)
+	assert.Equal(t, 1, report.Hypos[1].Readings)
+
+	require.Len(t, report.Meals, 4)
+	assert.Equal(t, []string{"завтрак", "обед", "перекус", "полдник"},
+		[]string{report.Meals[0].FoodType, report.Meals[1].FoodType, report.Meals[2].FoodType, report.Meals[3].FoodType})
+	assert.Equal(t, 2, report.Meals[0].Count)
+	require.NotNil(t, report.Meals[0].AvgCarbs)
+	assert.InDelta(t, 50, *report.Meals[0].AvgCarbs, 0.001)
+	require.NotNil(t, report.Meals[0].AvgCalories)
+	assert.InDelta(t, 400, *report.Meals[0].AvgCalories, 0.001)
+	assert.Nil(t, report.Meals[1].AvgCarbs)
+
+	assert.Equal(t, 2, report.Carbs.Days)
+	assert.InDelta(t, 67.5, report.Carbs.DailyAvg, 0.001)
+	assert.InDelta(t, 40, report.Carbs.DailyMin, 0.001)
+	assert.InDelta(t, 95, report.Carbs.DailyMax, 0.001)
+}
+
+func TestReportService_Validation(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user := &models.User{ID: 1}
+	now := time.Now()
+
+	_, err := svc.Report.Build(user, ReportOptions{From: now, To: now.Add(-time.Hour)})
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	_, err = svc.Report.Build(user, ReportOptions{From: now.AddDate(0, 0, -MaxReportDays-1), To: now})
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5}
+	assert.Equal(t, 1.0, percentile(values, 0))
+	assert.Equal(t, 3.0, percentile(values, 50))
+	assert.InDelta(t, 4.8, percentile(values, 95), 0.001)
+	assert.Equal(t, 5.0, percentile(values, 100))
+	assert.Equal(t, 7.0, percentile([]float64{7}, 25))
+}
+
+func TestWriteReportPDF(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	user, err := svc.Users.GetOrCreateUser(911, "", "Олег", "", "ru")
+	require.NoError(t, err)
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for day := 0; day < 14; day++ {
+		for _, hour := range []int{3, 7, 10, 13, 16, 19, 22} {
+			value := 4 + float64((day*hour)%11)
+			_, err := svc.Glucose.CreateRecordAt(user.ID, value, from.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour), "", "")
+			require.NoError(t, err)
+		}
+		carbs := 50.0
+		_, err := svc.Food.CreateRecordAt(user.ID, "Гречка", "обед", &carbs, nil, "", "", from.AddDate(0, 0, day).Add(13*time.Hour))
+		require.NoError(t, err)
+	}
+
+	report, err := svc.Report.Build(user, ReportOptions{From: from, To: from.AddDate(0, 0, 14)})
+	require.NoError(t, err)
+	report.GeneratedAt = from.AddDate(0, 0, 14)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteReportPDF(&buf, report))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	assert.True(t, bytes.HasSuffix(bytes.TrimSpace(buf.Bytes()), []byte("%%EOF")))
+
+	if path := os.Getenv("REPORT_PDF_OUT"); path != "" {
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	}
+}
+
+func TestWriteReportPDF_Empty(t *testing.T) {
+	report, err := New(memory.NewRepositories()).Report.Build(&models.User{ID: 1, FirstName: "Нет данных"},
+		ReportOptions{From: time.Now().AddDate(0, 0, -7), To: time.Now()})
+	require.NoError(t, err)
+	assert.Zero(t, report.Glucose.Count)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteReportPDF(&buf, report))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+}
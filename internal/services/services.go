@@ -0,0 +1,54 @@
+package services
+
+import "diabetbot/internal/repository"
+
+// Services объединяет сервисы предметной области, которые используют бот и API
+type Services struct {
+	Users      *UserService
+	Glucose    *GlucoseService
+	Food       *FoodService
+	Insulin    *InsulinService
+	AIUsage    *AIUsageService
+	Export     *ExportService
+	Report     *ReportService
+	Import     *ImportService
+	Nightscout *NightscoutService
+	Sharing    *SharingService
+	Alerts     *AlertService
+	Links      *PublicLinkService
+	Digests    *DigestService
+	Insights   *InsightService
+	Forecast   *ForecastService
+	Labs       *LabService
+	Vitals     *VitalService
+}
+
+// New создает сервисы поверх переданных хранилищ
+func New(repos *repository.Repositories) *Services {
+	s := &Services{
+		Users:      NewUserService(repos.Users),
+		Glucose:    NewGlucoseService(repos.Glucose),
+		Food:       NewFoodService(repos.Food),
+		Insulin:    NewInsulinService(repos.Insulin),
+		AIUsage:    NewAIUsageService(repos.AIUsage),
+		Export:     NewExportService(repos.Users, repos.Glucose, repos.Food, repos.Insulin, repos.Vitals),
+		Report:     NewReportService(repos.Glucose, repos.Food),
+		Import:     NewImportService(repos.Glucose, repos.Food, repos.ImportJobs),
+		Nightscout: NewNightscoutService(repos.Users, repos.Glucose, repos.Food, repos.Insulin),
+		Sharing:    NewSharingService(repos.Users, repos.Shares),
+		Alerts:     NewAlertService(repos.Users, repos.Shares, repos.Alerts),
+		Links:      NewPublicLinkService(repos.Users, repos.Links, repos.Glucose, repos.Food),
+		Digests:    NewDigestService(repos.Users, repos.Digests, repos.Glucose, repos.Food),
+		Insights:   NewInsightService(repos.Glucose, repos.Food, repos.Digests),
+		Forecast:   NewForecastService(repos.Glucose, repos.Food, repos.Insulin),
+		Labs:       NewLabService(repos.Labs, repos.Glucose),
+		Vitals:     NewVitalService(repos.Vitals),
+	}
+	// Записи глюкозы из бота, API и Nightscout проверяются на критические значения
+	s.Glucose.alerts = s.Alerts
+	s.Nightscout.alerts = s.Alerts
+	// Отчет для врача и публичная сводка показывают последний HbA1c рядом с GMI
+	s.Report.labs = s.Labs
+	s.Links.report.labs = s.Labs
+	return s
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Сервисы работают через интерфейсы хранилищ, поэтому тестируются без базы данных
+func TestServices_WithMemoryRepositories(t *testing.T) {
+	svc := New(memory.NewRepositories())
+
+	user, err := svc.Users.GetOrCreateUser(777, "old", "Иван", "", "ru")
+	require.NoError(t, err)
+
+	updated, err := svc.Users.GetOrCreateUser(777, "new", "Иван", "Петров", "en")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, updated.ID)
+	assert.Equal(t, "new", updated.Username)
+	assert.Equal(t, "Петров", updated.LastName)
+
+	carbs := 50.0
+	calories := 400
+	_, err = svc.Food.CreateRecord(user.ID, "Плов", "обед", &carbs, &calories, "", "")
+	require.NoError(t, err)
+	_, err = svc.Food.CreateRecordAt(user.ID, "Торт", "перекус", &carbs, &calories, "", "", time.Now().AddDate(0, 0, -2))
+	require.NoError(t, err)
+
+	todayCalories, err := svc.Food.GetTodayCalories(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 400, todayCalories)
+
+	record, err := svc.Glucose.CreateRecord(user.ID, 6.1, "")
+	require.NoError(t, err)
+	require.NoError(t, svc.Glucose.UpdateContext(user.ID, record.ID, "fasting"))
+	recent, err := svc.Glucose.GetRecentRecord(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "fasting", recent.MeasurementContext)
+
+	for i := 0; i < DailyAIRequestLimit; i++ {
+		allowed, _, err := svc.AIUsage.CheckAndIncrementUsage(user.ID)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	allowed, _, err := svc.AIUsage.CheckAndIncrementUsage(user.ID)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
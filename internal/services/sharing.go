@@ -0,0 +1,255 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+// ShareInviteTTL — срок действия ссылки-приглашения
+const ShareInviteTTL = 7 * 24 * time.Hour
+
+// MaxShares — предел доступов и действующих приглашений к одному дневнику
+const MaxShares = 20
+
+// ShareStartPrefix — префикс параметра /start в ссылке-приглашении t.me/<бот>?start=share_<токен>
+const ShareStartPrefix = "share_"
+
+// shareTokenBytes — длина токена приглашения: в base64url это 22 символа, вместе
+// с префиксом они укладываются в 64 символа, которые Telegram допускает в start
+const shareTokenBytes = 16
+
+// ShareRoleOwner — роль пользователя в собственном дневнике в списке SharedAccount
+const ShareRoleOwner = "owner"
+
+// ShareUser — участник доступа без служебных полей пользователя
+type ShareUser struct {
+	TelegramID int64  `json:"telegram_id"`
+	Username   string `json:"username"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+}
+
+// NewShareUser оставляет от пользователя поля, которые видит другая сторона доступа
+func NewShareUser(u *models.User) ShareUser {
+	return ShareUser{TelegramID: u.TelegramID, Username: u.Username, FirstName: u.FirstName, LastName: u.LastName}
+}
+
+// DisplayName — имя для сообщений бота
+func (u ShareUser) DisplayName() string {
+	switch {
+	case u.FirstName != "" && u.LastName != "":
+		return u.FirstName + " " + u.LastName
+	case u.FirstName != "":
+		return u.FirstName
+	case u.Username != "":
+		return "@" + u.Username
+	}
+	return strconv.FormatInt(u.TelegramID, 10)
+}
+
+// ShareInvite — новое приглашение. Токен показывается один раз, хранится только его хеш.
+type ShareInvite struct {
+	ID        uint      `json:"id"`
+	Role      string    `json:"role"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StartParam — параметр start ссылки-приглашения на бота
+func (i *ShareInvite) StartParam() string {
+	return ShareStartPrefix + i.Token
+}
+
+// ShareGrant — доступ к дневнику владельца; без Grantee приглашение еще не принято
+type ShareGrant struct {
+	ID         uint       `json:"id"`
+	Role       string     `json:"role"`
+	Grantee    *ShareUser `json:"grantee"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // только у непринятого приглашения
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// SharedAccount — дневник, который может открыть пользователь: свой (роль owner) или чужой по доступу
+type SharedAccount struct {
+	ShareUser
+	Role    string `json:"role"` // owner, read, write
+	ShareID uint   `json:"share_id,omitempty"`
+}
+
+// SharingService выдает родственникам и врачам доступ к дневнику по ссылке-приглашению
+// и проверяет, может ли пользователь читать или изменять чужой дневник.
+type SharingService struct {
+	users  repository.UserRepository
+	shares repository.ShareRepository
+}
+
+func NewSharingService(users repository.UserRepository, shares repository.ShareRepository) *SharingService {
+	return &SharingService{users: users, shares: shares}
+}
+
+func shareTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateInvite создает приглашение с ролью role (read или write), действующее ShareInviteTTL
+func (s *SharingService) CreateInvite(ownerID uint, role string) (*ShareInvite, error) {
+	if !models.IsShareRole(role) {
+		return nil, &ValidationError{Field: "role", Rule: "oneof", Param: models.ShareRoleRead + " " + models.ShareRoleWrite}
+	}
+	grants, err := s.ListGrants(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(grants) >= MaxShares {
+		return nil, &ValidationError{Field: "role", Rule: "max_shares", Param: strconv.Itoa(MaxShares)}
+	}
+
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	share := &models.Share{
+		OwnerID:   ownerID,
+		Role:      role,
+		TokenHash: shareTokenHash(token),
+		ExpiresAt: time.Now().Add(ShareInviteTTL),
+	}
+	if err := s.shares.Create(share); err != nil {
+		return nil, err
+	}
+	return &ShareInvite{ID: share.ID, Role: share.Role, Token: token, ExpiresAt: share.ExpiresAt}, nil
+}
+
+// AcceptInvite открывает получателю дневник по токену приглашения. Просроченное,
+// принятое или отозванное приглашение — ErrNotFound. Если доступ к этому дневнику
+// уже есть, ему назначается роль из приглашения.
+func (s *SharingService) AcceptInvite(granteeID uint, token string) (*models.Share, error) {
+	invite, err := s.shares.GetByTokenHash(shareTokenHash(token))
+	if err != nil {
+		return nil, err
+	}
+	if invite.GranteeID != nil || time.Now().After(invite.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	if invite.OwnerID == granteeID {
+		return nil, &ValidationError{Field: "token", Rule: "own_share"}
+	}
+
+	existing, err := s.shares.Get(invite.OwnerID, granteeID)
+	switch {
+	case err == nil:
+		if err := s.shares.Update(existing.ID, map[string]interface{}{"role": invite.Role}); err != nil {
+			return nil, err
+		}
+		if err := s.shares.Delete(invite.ID); err != nil {
+			return nil, err
+		}
+		existing.Role = invite.Role
+		return existing, nil
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+
+	if err := s.shares.Accept(invite.ID, granteeID, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.shares.GetByID(invite.ID)
+}
+
+// Revoke отзывает доступ или приглашение. Отозвать может владелец дневника,
+// а получатель — отказаться от доступа; для остальных доступ не найден.
+func (s *SharingService) Revoke(userID, shareID uint) (*models.Share, error) {
+	share, err := s.shares.GetByID(shareID)
+	if err != nil {
+		return nil, err
+	}
+	if share.OwnerID != userID && (share.GranteeID == nil || *share.GranteeID != userID) {
+		return nil, ErrNotFound
+	}
+	if err := s.shares.Delete(share.ID); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// ListGrants возвращает доступы к дневнику владельца и действующие приглашения
+func (s *SharingService) ListGrants(ownerID uint) ([]ShareGrant, error) {
+	shares, err := s.shares.ListByOwner(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	grants := make([]ShareGrant, 0, len(shares))
+	for _, share := range shares {
+		grant := ShareGrant{ID: share.ID, Role: share.Role, AcceptedAt: share.AcceptedAt, CreatedAt: share.CreatedAt}
+		if share.GranteeID == nil {
+			if now.After(share.ExpiresAt) {
+				continue
+			}
+			grant.ExpiresAt = &share.ExpiresAt
+		} else {
+			grantee, err := s.users.GetByID(*share.GranteeID)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			user := NewShareUser(grantee)
+			grant.Grantee = &user
+		}
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+// Accounts возвращает дневники, которые может открыть пользователь: первым — собственный
+func (s *SharingService) Accounts(user *models.User) ([]SharedAccount, error) {
+	shares, err := s.shares.ListByGrantee(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]SharedAccount, 0, len(shares)+1)
+	accounts = append(accounts, SharedAccount{ShareUser: NewShareUser(user), Role: ShareRoleOwner})
+	for _, share := range shares {
+		owner, err := s.users.GetByID(share.OwnerID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, SharedAccount{ShareUser: NewShareUser(owner), Role: share.Role, ShareID: share.ID})
+	}
+	return accounts, nil
+}
+
+// Authorize проверяет, что actorID может работать с дневником ownerID: владельцу можно все,
+// получателю с ролью read — только чтение (role read). Иначе ErrForbidden.
+func (s *SharingService) Authorize(actorID, ownerID uint, role string) error {
+	if actorID == ownerID {
+		return nil
+	}
+	share, err := s.shares.Get(ownerID, actorID)
+	if errors.Is(err, ErrNotFound) {
+		return ErrForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if role == models.ShareRoleWrite && share.Role != models.ShareRoleWrite {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharingService_InviteAndAuthorize(t *testing.T) {
+	repos := memory.NewRepositories()
+	svc := New(repos)
+	owner, err := svc.Users.GetOrCreateUser(940, "kid", "Маша", "", "ru")
+	require.NoError(t, err)
+	parent, err := svc.Users.GetOrCreateUser(941, "", "Ольга", "Петрова", "ru")
+	require.NoError(t, err)
+	doctor, err := svc.Users.GetOrCreateUser(942, "", "Врач", "", "ru")
+	require.NoError(t, err)
+
+	// Без доступа чужой дневник закрыт
+	assert.ErrorIs(t, svc.Sharing.Authorize(parent.ID, owner.ID, models.ShareRoleRead), ErrForbidden)
+	assert.NoError(t, svc.Sharing.Authorize(owner.ID, owner.ID, models.ShareRoleWrite))
+
+	invite, err := svc.Sharing.CreateInvite(owner.ID, models.ShareRoleWrite)
+	require.NoError(t, err)
+	// Параметр start Telegram: до 64 символов A-Z, a-z, 0-9, _ и -
+	assert.Regexp(t, regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`), invite.StartParam())
+	assert.WithinDuration(t, time.Now().Add(ShareInviteTTL), invite.ExpiresAt, time.Minute)
+
+	share, err := svc.Sharing.AcceptInvite(parent.ID, invite.Token)
+	require.NoError(t, err)
+	assert.Equal(t, owner.ID, share.OwnerID)
+	assert.NoError(t, svc.Sharing.Authorize(parent.ID, owner.ID, models.ShareRoleWrite))
+
+	// Приглашение одноразовое
+	_, err = svc.Sharing.AcceptInvite(doctor.ID, invite.Token)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	readInvite, err := svc.Sharing.CreateInvite(owner.ID, models.ShareRoleRead)
+	require.NoError(t, err)
+	_, err = svc.Sharing.AcceptInvite(doctor.ID, readInvite.Token)
+	require.NoError(t, err)
+	assert.NoError(t, svc.Sharing.Authorize(doctor.ID, owner.ID, models.ShareRoleRead))
+	assert.ErrorIs(t, svc.Sharing.Authorize(doctor.ID, owner.ID, models.ShareRoleWrite), ErrForbidden)
+	// Доступ действует в одну сторону
+	assert.ErrorIs(t, svc.Sharing.Authorize(owner.ID, doctor.ID, models.ShareRoleRead), ErrForbidden)
+
+	accounts, err := svc.Sharing.Accounts(doctor)
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, ShareRoleOwner, accounts[0].Role)
+	assert.Equal(t, int64(942), accounts[0].TelegramID)
+	assert.Equal(t, int64(940), accounts[1].TelegramID)
+	assert.Equal(t, models.ShareRoleRead, accounts[1].Role)
+
+	pending, err := svc.Sharing.CreateInvite(owner.ID, models.ShareRoleRead)
+	require.NoError(t, err)
+	grants, err := svc.Sharing.ListGrants(owner.ID)
+	require.NoError(t, err)
+	require.Len(t, grants, 3)
+	assert.Equal(t, pending.ID, grants[0].ID)
+	assert.Nil(t, grants[0].Grantee)
+	assert.NotNil(t, grants[0].ExpiresAt)
+	require.NotNil(t, grants[2].Grantee)
+	assert.Equal(t, "Ольга Петрова", grants[2].Grantee.DisplayName())
+
+	// Чужой доступ отозвать нельзя, владелец и получатель могут
+	_, err = svc.Sharing.Revoke(doctor.ID, share.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = svc.Sharing.Revoke(owner.ID, share.ID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, svc.Sharing.Authorize(parent.ID, owner.ID, models.ShareRoleRead), ErrForbidden)
+	_, err = svc.Sharing.Revoke(doctor.ID, accounts[1].ShareID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, svc.Sharing.Authorize(doctor.ID, owner.ID, models.ShareRoleRead), ErrForbidden)
+
+	// Отозванное приглашение не принимается
+	_, err = svc.Sharing.Revoke(owner.ID, pending.ID)
+	require.NoError(t, err)
+	_, err = svc.Sharing.AcceptInvite(doctor.ID, pending.Token)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSharingService_AcceptUpdatesRole(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	owner, err := svc.Users.GetOrCreateUser(943, "", "Анна", "", "ru")
+	require.NoError(t, err)
+	grantee, err := svc.Users.GetOrCreateUser(944, "", "Иван", "", "ru")
+	require.NoError(t, err)
+
+	first, err := svc.Sharing.CreateInvite(owner.ID, models.ShareRoleRead)
+	require.NoError(t, err)
+	share, err := svc.Sharing.AcceptInvite(grantee.ID, first.Token)
+	require.NoError(t, err)
+
+	// Повторное приглашение меняет роль, а не создает второй доступ
+	second, err := svc.Sharing.CreateInvite(owner.ID, models.ShareRoleWrite)
+	require.NoError(t, err)
+	updated, err := svc.Sharing.AcceptInvite(grantee.ID, second.Token)
+	require.NoError(t, err)
+	assert.Equal(t, share.ID, updated.ID)
+	assert.Equal(t, models.ShareRoleWrite, updated.Role)
+	grants, err := svc.Sharing.ListGrants(owner.ID)
+	require.NoError(t, err)
+	assert.Len(t, grants, 1)
+
+	own, err := svc.Sharing.CreateInvite(owner.ID, models.ShareRoleRead)
+	require.NoError(t, err)
+	_, err = svc.Sharing.AcceptInvite(owner.ID, own.Token)
+	assert.ErrorIs(t, err, ErrValidation)
+	_, err = svc.Sharing.AcceptInvite(grantee.ID, "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSharingService_Validation(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	owner, err := svc.Users.GetOrCreateUser(945, "", "Анна", "", "ru")
+	require.NoError(t, err)
+
+	_, err = svc.Sharing.CreateInvite(owner.ID, "admin")
+	assert.ErrorIs(t, err, ErrValidation)
+
+	for i := 0; i < MaxShares; i++ {
+		_, err := svc.Sharing.CreateInvite(owner.ID, models.ShareRoleRead)
+		require.NoError(t, err)
+	}
+	_, err = svc.Sharing.CreateInvite(owner.ID, models.ShareRoleRead)
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, "max_shares", verr.Rule)
+}
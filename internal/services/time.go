@@ -0,0 +1,14 @@
+package services
+
+import "time"
+
+// startOfDay возвращает полночь дня t в его часовом поясе.
+// time.Truncate(24*time.Hour) для этого не подходит: он округляет до полуночи UTC.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// usageDate возвращает день учета AI запросов в формате YYYY-MM-DD по UTC
+func usageDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
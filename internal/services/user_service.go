@@ -2,32 +2,25 @@ package services
 
 import (
 	"diabetbot/internal/models"
-
-	"gorm.io/gorm"
+	"diabetbot/internal/repository"
+	"errors"
 )
 
 type UserService struct {
-	db *gorm.DB
-}
-
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db}
+	repo repository.UserRepository
 }
 
-// GetDB возвращает экземпляр базы данных
-func (s *UserService) GetDB() *gorm.DB {
-	return s.db
+func NewUserService(repo repository.UserRepository) *UserService {
+	return &UserService{repo: repo}
 }
 
 func (s *UserService) GetOrCreateUser(telegramID int64, username, firstName, lastName, languageCode string) (*models.User, error) {
-	var user models.User
-	
 	// Пытаемся найти существующего пользователя
-	err := s.db.Where("telegram_id = ?", telegramID).First(&user).Error
-	
-	if err == gorm.ErrRecordNotFound {
+	user, err := s.repo.GetByTelegramID(telegramID)
+
+	if errors.Is(err, repository.ErrNotFound) {
 		// Создаем нового пользователя
-		user = models.User{
+		user = &models.User{
 			TelegramID:   telegramID,
 			Username:     username,
 			FirstName:    firstName,
@@ -35,16 +28,16 @@ func (s *UserService) GetOrCreateUser(telegramID int64, username, firstName, las
 			LanguageCode: languageCode,
 			IsActive:     true,
 		}
-		
-		if err := s.db.Create(&user).Error; err != nil {
+
+		if err := s.repo.Create(user); err != nil {
 			return nil, err
 		}
-		
-		return &user, nil
+
+		return user, nil
 	} else if err != nil {
 		return nil, err
 	}
-	
+
 	// Обновляем информацию существующего пользователя
 	updates := map[string]interface{}{
 		"username":      username,
@@ -53,30 +46,40 @@ func (s *UserService) GetOrCreateUser(telegramID int64, username, firstName, las
 		"language_code": languageCode,
 		"is_active":     true,
 	}
-	
-	if err := s.db.Model(&user).Updates(updates).Error; err != nil {
+
+	if err := s.repo.Update(user.ID, updates); err != nil {
 		return nil, err
 	}
-	
-	return &user, nil
+
+	user.Username = username
+	user.FirstName = firstName
+	user.LastName = lastName
+	user.LanguageCode = languageCode
+	user.IsActive = true
+
+	return user, nil
 }
 
 func (s *UserService) GetByTelegramID(telegramID int64) (*models.User, error) {
-	var user models.User
-	err := s.db.Where("telegram_id = ?", telegramID).First(&user).Error
-	if err != nil {
-		return nil, err
-	}
-	return &user, nil
+	return s.repo.GetByTelegramID(telegramID)
+}
+
+func (s *UserService) GetByID(id uint) (*models.User, error) {
+	return s.repo.GetByID(id)
 }
 
 func (s *UserService) UpdateDiabetesInfo(userID uint, diabetesType int, targetGlucose float64) error {
-	return s.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-		"diabetes_type":   diabetesType,
-		"target_glucose":  targetGlucose,
-	}).Error
+	return s.repo.Update(userID, map[string]interface{}{
+		"diabetes_type":  diabetesType,
+		"target_glucose": targetGlucose,
+	})
+}
+
+// DeactivateByTelegramID помечает пользователя неактивным (например, если он заблокировал бота)
+func (s *UserService) DeactivateByTelegramID(telegramID int64) error {
+	return s.repo.UpdateByTelegramID(telegramID, map[string]interface{}{"is_active": false})
 }
 
 func (s *UserService) UpdateUser(userID uint, updates map[string]interface{}) error {
-	return s.db.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
-}
\ No newline at end of file
+	return s.repo.Update(userID, updates)
+}
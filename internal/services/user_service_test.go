@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
 	"diabetbot/internal/testutils"
 
 	"github.com/stretchr/testify/assert"
@@ -14,7 +15,7 @@ func TestUserService_GetOrCreateUser(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewUserService(db)
+	service := NewUserService(repository.NewGormUserRepository(db))
 
 	t.Run("CreateNewUser", func(t *testing.T) {
 		telegramID := int64(123456789)
@@ -71,7 +72,7 @@ func TestUserService_GetByTelegramID(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewUserService(db)
+	service := NewUserService(repository.NewGormUserRepository(db))
 
 	t.Run("UserExists", func(t *testing.T) {
 		// Создаем пользователя
@@ -101,7 +102,7 @@ func TestUserService_UpdateDiabetesInfo(t *testing.T) {
 	db := testutils.SetupTestDB(t)
 	defer testutils.CleanupTestDB(db)
 	
-	service := NewUserService(db)
+	service := NewUserService(repository.NewGormUserRepository(db))
 
 	t.Run("UpdateSuccessful", func(t *testing.T) {
 		// Создаем пользователя
@@ -130,4 +131,28 @@ func TestUserService_UpdateDiabetesInfo(t *testing.T) {
 		// но можно добавить проверку в сервис
 		assert.NoError(t, err)
 	})
-}
\ No newline at end of file
+}
+func TestUserService_DeactivateByTelegramID(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+
+	service := NewUserService(repository.NewGormUserRepository(db))
+	user := testutils.CreateTestUser(db, 444555666)
+	other := testutils.CreateTestUser(db, 777888999)
+
+	require.NoError(t, service.DeactivateByTelegramID(user.TelegramID))
+
+	var updated models.User
+	require.NoError(t, db.First(&updated, user.ID).Error)
+	assert.False(t, updated.IsActive)
+
+	// Другие пользователи не затрагиваются
+	var untouched models.User
+	require.NoError(t, db.First(&untouched, other.ID).Error)
+	assert.True(t, untouched.IsActive)
+
+	// Повторное сообщение от пользователя снова делает его активным
+	reactivated, err := service.GetOrCreateUser(user.TelegramID, "", user.FirstName, "", "ru")
+	require.NoError(t, err)
+	assert.True(t, reactivated.IsActive)
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+)
+
+const (
+	// KetoneCheckGlucose — сахар, ммоль/л, начиная с которого стоит проверить кетоны
+	KetoneCheckGlucose = 13.9
+	// KetoneRecentWindow — сколько измерение кетонов учитывается в советах при высоком сахаре
+	KetoneRecentWindow = 4 * time.Hour
+	// vitalContextDays — за сколько дней показатели попадают в запросы к ИИ
+	vitalContextDays = 30
+	// ketoneContextAge — кетоны старше суток в запросы к ИИ не попадают
+	ketoneContextAge = 24 * time.Hour
+
+	kgPerLb = 0.45359237
+)
+
+// Уровень кетонов в крови
+const (
+	KetonesNormal   = "normal"   // меньше 0.6 ммоль/л
+	KetonesElevated = "elevated" // 0.6–1.4: повышены
+	KetonesHigh     = "high"     // 1.5–2.9: риск кетоацидоза
+	KetonesCritical = "critical" // от 3.0: вероятен кетоацидоз, нужна неотложная помощь
+)
+
+// KetoneLevel оценивает уровень кетонов в крови, ммоль/л
+func KetoneLevel(value float64) string {
+	switch {
+	case value >= 3:
+		return KetonesCritical
+	case value >= 1.5:
+		return KetonesHigh
+	case value >= 0.6:
+		return KetonesElevated
+	}
+	return KetonesNormal
+}
+
+var ketoneLevelTexts = map[string]string{
+	KetonesNormal:   "в норме",
+	KetonesElevated: "повышены",
+	KetonesHigh:     "высокие, риск кетоацидоза",
+	KetonesCritical: "очень высокие, вероятен кетоацидоз",
+}
+
+// KetoneLevelText — уровень кетонов словами: «повышены», «высокие, риск кетоацидоза»
+func KetoneLevelText(level string) string {
+	return ketoneLevelTexts[level]
+}
+
+// vitalUnits — единицы и диапазоны по видам показателя; первая единица — основная.
+// У давления диапазон относится к систолическому значению.
+var vitalUnits = map[string][]unitRange{
+	models.VitalKindWeight: {
+		{models.VitalUnitKg, 20, 350},
+		{models.VitalUnitLb, 44, 770},
+	},
+	models.VitalKindBloodPressure: {
+		{models.VitalUnitMmHg, 60, 260},
+	},
+	models.VitalKindKetones: {
+		{models.VitalUnitMmolL, 0, 10},
+	},
+}
+
+// Диастолическое давление, мм рт. ст.
+const (
+	minDiastolic = 30
+	maxDiastolic = 160
+)
+
+// VitalInput — измерение показателя. Пустой Unit — основная единица вида,
+// нулевой MeasuredAt — текущий момент. Diastolic обязателен только для давления.
+type VitalInput struct {
+	Kind       string
+	Value      float64
+	Diastolic  *float64
+	Unit       string
+	MeasuredAt time.Time
+	Notes      string
+}
+
+// VitalService хранит вес, давление и кетоны и описывает их для запросов к ИИ
+type VitalService struct {
+	repo repository.VitalRepository
+	now  func() time.Time
+}
+
+func NewVitalService(repo repository.VitalRepository) *VitalService {
+	return &VitalService{repo: repo, now: time.Now}
+}
+
+// Create проверяет и сохраняет измерение
+func (s *VitalService) Create(userID uint, input VitalInput) (*models.Vital, error) {
+	vital, err := s.validate(input)
+	if err != nil {
+		return nil, err
+	}
+	vital.UserID = userID
+	if err := s.repo.Create(vital); err != nil {
+		return nil, err
+	}
+	return vital, nil
+}
+
+// List возвращает страницу измерений; пустой kind не фильтрует
+func (s *VitalService) List(userID uint, opts ListOptions, kind string) (*Page[models.Vital], error) {
+	if kind != "" && !models.IsVitalKind(kind) {
+		return nil, vitalKindError()
+	}
+	query, err := opts.query()
+	if err != nil {
+		return nil, err
+	}
+
+	vitals, err := s.repo.List(userID, query, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPage(vitals, query, func(v *models.Vital) repository.Cursor {
+		return repository.Cursor{At: v.MeasuredAt, ID: v.ID}
+	}), nil
+}
+
+func (s *VitalService) Get(userID, id uint) (*models.Vital, error) {
+	return s.repo.GetByID(userID, id)
+}
+
+// Update проверяет и целиком перезаписывает измерение
+func (s *VitalService) Update(userID, id uint, input VitalInput) (*models.Vital, error) {
+	if _, err := s.repo.GetByID(userID, id); err != nil {
+		return nil, err
+	}
+	vital, err := s.validate(input)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.Update(userID, id, map[string]interface{}{
+		"kind":        vital.Kind,
+		"value":       vital.Value,
+		"diastolic":   vital.Diastolic,
+		"unit":        vital.Unit,
+		"measured_at": vital.MeasuredAt,
+		"notes":       vital.Notes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(userID, id)
+}
+
+func (s *VitalService) Delete(userID, id uint) error {
+	if _, err := s.repo.GetByID(userID, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(userID, id)
+}
+
+func (s *VitalService) DeleteAllUserVitals(userID uint) error {
+	return s.repo.DeleteAllByUser(userID)
+}
+
+// RecentKetones возвращает последнее измерение кетонов не раньше KetoneRecentWindow до at; nil — его нет
+func (s *VitalService) RecentKetones(userID uint, at time.Time) (*models.Vital, error) {
+	vitals, err := s.repo.List(userID, repository.ListQuery{From: at.Add(-KetoneRecentWindow), Limit: 1}, models.VitalKindKetones)
+	if err != nil || len(vitals) == 0 {
+		return nil, err
+	}
+	return &vitals[0], nil
+}
+
+// AIContext описывает для ИИ последние вес, давление и свежие кетоны.
+// Реализует PatientContext; пустая строка — показателей за vitalContextDays дней нет.
+func (s *VitalService) AIContext(user *models.User) string {
+	now := s.now()
+	vitals, err := s.repo.List(user.ID, repository.ListQuery{From: now.AddDate(0, 0, -vitalContextDays), Ascending: true}, "")
+	if err != nil {
+		log.Printf("Error listing vitals for user %d: %v", user.ID, err)
+		return ""
+	}
+
+	var weights, pressures []models.Vital
+	var ketones *models.Vital
+	for i := range vitals {
+		switch vitals[i].Kind {
+		case models.VitalKindWeight:
+			weights = append(weights, vitals[i])
+		case models.VitalKindBloodPressure:
+			pressures = append(pressures, vitals[i])
+		case models.VitalKindKetones:
+			ketones = &vitals[i]
+		}
+	}
+
+	var lines []string
+	if len(weights) > 0 {
+		first, last := weights[0], weights[len(weights)-1]
+		line := fmt.Sprintf("- вес %.1f кг (%s)", weightKg(last), last.MeasuredAt.Format("02.01.2006"))
+		if len(weights) > 1 {
+			line += fmt.Sprintf(", изменение за %d дн. %+.1f кг", vitalContextDays, weightKg(last)-weightKg(first))
+		}
+		lines = append(lines, line)
+	}
+	if len(pressures) > 0 {
+		last := pressures[len(pressures)-1]
+		line := "- давление " + BloodPressureText(&last) + " мм рт. ст. (" + last.MeasuredAt.Format("02.01.2006") + ")"
+		if len(pressures) > 1 {
+			var systolic, diastolic float64
+			for _, p := range pressures {
+				systolic += p.Value
+				if p.Diastolic != nil {
+					diastolic += *p.Diastolic
+				}
+			}
+			n := float64(len(pressures))
+			line += fmt.Sprintf(", в среднем %.0f/%.0f по %d измерениям", systolic/n, diastolic/n, len(pressures))
+		}
+		lines = append(lines, line)
+	}
+	if ketones != nil && now.Sub(ketones.MeasuredAt) <= ketoneContextAge {
+		lines = append(lines, fmt.Sprintf("- кетоны в крови %.1f ммоль/л (%s) — %s",
+			ketones.Value, ketones.MeasuredAt.Format("02.01.2006 15:04"), KetoneLevelText(KetoneLevel(ketones.Value))))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Показатели из дневника за %d дней:\n", vitalContextDays) + strings.Join(lines, "\n")
+}
+
+// BloodPressureText — давление в виде «135/85»
+func BloodPressureText(v *models.Vital) string {
+	if v.Diastolic == nil {
+		return fmt.Sprintf("%.0f", v.Value)
+	}
+	return fmt.Sprintf("%.0f/%.0f", v.Value, *v.Diastolic)
+}
+
+// weightKg — вес в килограммах независимо от единицы записи
+func weightKg(v models.Vital) float64 {
+	if v.Unit == models.VitalUnitLb {
+		return v.Value * kgPerLb
+	}
+	return v.Value
+}
+
+// validate проверяет вид, единицу и значения измерения и приводит единицу к каноническому написанию
+func (s *VitalService) validate(input VitalInput) (*models.Vital, error) {
+	vital := &models.Vital{
+		Kind:       input.Kind,
+		Value:      input.Value,
+		Unit:       strings.TrimSpace(input.Unit),
+		MeasuredAt: input.MeasuredAt,
+		Notes:      input.Notes,
+	}
+	if !models.IsVitalKind(vital.Kind) {
+		return nil, vitalKindError()
+	}
+	if vital.MeasuredAt.IsZero() {
+		vital.MeasuredAt = s.now()
+	}
+	if vital.MeasuredAt.After(s.now().Add(time.Hour)) {
+		return nil, &ValidationError{Field: "measured_at", Rule: "not_future"}
+	}
+	if utf8.RuneCountInString(vital.Notes) > 500 {
+		return nil, &ValidationError{Field: "notes", Rule: "max", Param: "500"}
+	}
+
+	unit, err := checkUnitRange(vitalUnits[vital.Kind], vital.Unit, vital.Value)
+	if err != nil {
+		return nil, err
+	}
+	vital.Unit = unit
+
+	if vital.Kind != models.VitalKindBloodPressure {
+		return vital, nil
+	}
+	switch {
+	case input.Diastolic == nil:
+		return nil, &ValidationError{Field: "diastolic", Rule: "required"}
+	case *input.Diastolic < minDiastolic || *input.Diastolic > maxDiastolic:
+		return nil, &ValidationError{Field: "diastolic", Rule: "between", Param: fmt.Sprintf("%d %d", minDiastolic, maxDiastolic)}
+	case *input.Diastolic >= vital.Value:
+		return nil, &ValidationError{Field: "diastolic", Rule: "ltfield", Param: "value"}
+	}
+	diastolic := math.Round(*input.Diastolic)
+	vital.Diastolic = &diastolic
+	vital.Value = math.Round(vital.Value)
+	return vital, nil
+}
+
+func vitalKindError() error {
+	return &ValidationError{Field: "kind", Rule: "oneof", Param: strings.Join([]string{
+		models.VitalKindWeight, models.VitalKindBloodPressure, models.VitalKindKetones,
+	}, " ")}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVitalService_Validation(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	svc.Vitals.now = func() time.Time { return now }
+
+	vital, err := svc.Vitals.Create(1, VitalInput{Kind: models.VitalKindWeight, Value: 180, Unit: "LB"})
+	require.NoError(t, err)
+	assert.Equal(t, models.VitalUnitLb, vital.Unit)
+	assert.Equal(t, now, vital.MeasuredAt)
+
+	diastolic, high, low := 84.6, 150.0, 20.0
+	pressure, err := svc.Vitals.Create(1, VitalInput{Kind: models.VitalKindBloodPressure, Value: 135.2, Diastolic: &diastolic})
+	require.NoError(t, err)
+	assert.Equal(t, models.VitalUnitMmHg, pressure.Unit)
+	assert.Equal(t, "135/85", BloodPressureText(pressure))
+
+	ketones, err := svc.Vitals.Create(1, VitalInput{Kind: models.VitalKindKetones, Value: 0, Diastolic: &diastolic})
+	require.NoError(t, err)
+	assert.Nil(t, ketones.Diastolic)
+
+	tests := map[string]struct {
+		input VitalInput
+		field string
+		rule  string
+	}{
+		"UnknownKind":       {VitalInput{Kind: "pulse", Value: 70}, "kind", "oneof"},
+		"WeightOutOfRange":  {VitalInput{Kind: models.VitalKindWeight, Value: 8}, "value", "between"},
+		"WrongUnit":         {VitalInput{Kind: models.VitalKindKetones, Value: 1, Unit: "mg/dL"}, "unit", "oneof"},
+		"NoDiastolic":       {VitalInput{Kind: models.VitalKindBloodPressure, Value: 130}, "diastolic", "required"},
+		"DiastolicRange":    {VitalInput{Kind: models.VitalKindBloodPressure, Value: 130, Diastolic: &low}, "diastolic", "between"},
+		"DiastolicAbove":    {VitalInput{Kind: models.VitalKindBloodPressure, Value: 130, Diastolic: &high}, "diastolic", "ltfield"},
+		"NegativeKetones":   {VitalInput{Kind: models.VitalKindKetones, Value: -0.1}, "value", "between"},
+		"FutureMeasurement": {VitalInput{Kind: models.VitalKindWeight, Value: 80, MeasuredAt: now.AddDate(0, 0, 1)}, "measured_at", "not_future"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.Vitals.Create(1, tt.input)
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, tt.field, verr.Field)
+			assert.Equal(t, tt.rule, verr.Rule)
+		})
+	}
+
+	_, err = svc.Vitals.Update(2, vital.ID, VitalInput{Kind: models.VitalKindWeight, Value: 80})
+	assert.ErrorIs(t, err, ErrNotFound)
+	updated, err := svc.Vitals.Update(1, vital.ID, VitalInput{Kind: models.VitalKindWeight, Value: 81.5, MeasuredAt: now.Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, models.VitalUnitKg, updated.Unit)
+	assert.Equal(t, 81.5, updated.Value)
+
+	page, err := svc.Vitals.List(1, ListOptions{}, models.VitalKindBloodPressure)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	_, err = svc.Vitals.List(1, ListOptions{}, "pulse")
+	assert.ErrorIs(t, err, ErrValidation)
+
+	require.NoError(t, svc.Vitals.Delete(1, vital.ID))
+	assert.ErrorIs(t, svc.Vitals.Delete(1, vital.ID), ErrNotFound)
+}
+
+func TestKetoneLevel(t *testing.T) {
+	assert.Equal(t, KetonesNormal, KetoneLevel(0.4))
+	assert.Equal(t, KetonesElevated, KetoneLevel(0.6))
+	assert.Equal(t, KetonesHigh, KetoneLevel(1.5))
+	assert.Equal(t, KetonesCritical, KetoneLevel(3.2))
+	assert.Equal(t, "высокие, риск кетоацидоза", KetoneLevelText(KetonesHigh))
+}
+
+func TestVitalService_KetonesAndAIContext(t *testing.T) {
+	svc := New(memory.NewRepositories())
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	svc.Vitals.now = func() time.Time { return now }
+	user := &models.User{ID: 1}
+
+	assert.Empty(t, svc.Vitals.AIContext(user))
+
+	_, err := svc.Vitals.Create(user.ID, VitalInput{Kind: models.VitalKindKetones, Value: 0.4, MeasuredAt: now.Add(-5 * time.Hour)})
+	require.NoError(t, err)
+	ketones, err := svc.Vitals.RecentKetones(user.ID, now)
+	require.NoError(t, err)
+	assert.Nil(t, ketones)
+
+	_, err = svc.Vitals.Create(user.ID, VitalInput{Kind: models.VitalKindKetones, Value: 1.8, MeasuredAt: now.Add(-time.Hour)})
+	require.NoError(t, err)
+	ketones, err = svc.Vitals.RecentKetones(user.ID, now)
+	require.NoError(t, err)
+	require.NotNil(t, ketones)
+	assert.Equal(t, 1.8, ketones.Value)
+
+	for i, weight := range []float64{84, 83.1, 82.5} {
+		_, err := svc.Vitals.Create(user.ID, VitalInput{Kind: models.VitalKindWeight, Value: weight, MeasuredAt: now.AddDate(0, 0, -10+i*5)})
+		require.NoError(t, err)
+	}
+	for _, bp := range [][2]float64{{140, 90}, {130, 80}} {
+		diastolic := bp[1]
+		_, err := svc.Vitals.Create(user.ID, VitalInput{Kind: models.VitalKindBloodPressure, Value: bp[0], Diastolic: &diastolic, MeasuredAt: now.AddDate(0, 0, -1)})
+		require.NoError(t, err)
+	}
+	// Вес за пределами окна не учитывается
+	_, err = svc.Vitals.Create(user.ID, VitalInput{Kind: models.VitalKindWeight, Value: 95, MeasuredAt: now.AddDate(0, -2, 0)})
+	require.NoError(t, err)
+
+	text := svc.Vitals.AIContext(user)
+	assert.Contains(t, text, "- вес 82.5 кг (10.05.2024), изменение за 30 дн. -1.5 кг")
+	assert.Contains(t, text, "- давление 130/80 мм рт. ст. (09.05.2024), в среднем 135/85 по 2 измерениям")
+	assert.Contains(t, text, "- кетоны в крови 1.8 ммоль/л (10.05.2024 11:00) — высокие, риск кетоацидоза")
+
+	// Источники сведений объединяются по порядку, пустые пропускаются
+	combined := PatientContexts{svc.Insights, svc.Vitals}.AIContext(user)
+	assert.Equal(t, text, combined)
+}
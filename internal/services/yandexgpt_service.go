@@ -17,6 +17,7 @@ type YandexGPTService struct {
 	apiKey    string
 	folderId  string
 	client    *http.Client
+	patient   PatientContext // сведения из дневника для запросов, nil — без них
 }
 
 type YandexGPTRequest struct {
@@ -66,6 +67,11 @@ func NewYandexGPTService(cfg *config.YandexGPTConfig) *YandexGPTService {
 	}
 }
 
+// SetPatientContext задает источник сведений из дневника, которые добавляются к запросам
+func (s *YandexGPTService) SetPatientContext(ctx PatientContext) {
+	s.patient = ctx
+}
+
 func (s *YandexGPTService) sendRequest(messages []YandexMessage) (string, error) {
 	if s.apiKey == "" || s.apiKey == "your_yandex_api_key_here" {
 		return "", fmt.Errorf("YandexGPT API key not configured")
@@ -162,7 +168,7 @@ func (s *YandexGPTService) GetGlucoseRecommendation(user *models.User, record *m
 
 	userMessage := YandexMessage{
 		Role: "user",
-		Text: fmt.Sprintf(`Пациент:
+		Text: withPatientContext(s.patient, user, fmt.Sprintf(`Пациент:
 - Диабет: %s
 - Целевая глюкоза: %s
 - Текущий показатель: %.1f ммоль/л
@@ -172,7 +178,7 @@ func (s *YandexGPTService) GetGlucoseRecommendation(user *models.User, record *m
 			diabetesTypeText, 
 			targetText, 
 			record.Value, 
-			record.MeasuredAt.Format("15:04 02.01.2006")),
+			record.MeasuredAt.Format("15:04 02.01.2006"))),
 	}
 
 	messages := []YandexMessage{systemMessage, userMessage}
@@ -206,10 +212,10 @@ func (s *YandexGPTService) GetFoodRecommendation(user *models.User, foodDescript
 
 	userMessage := YandexMessage{
 		Role: "user",
-		Text: fmt.Sprintf(`Пациент с диабетом %s описал прием пищи:
+		Text: withPatientContext(s.patient, user, fmt.Sprintf(`Пациент с диабетом %s описал прием пищи:
 "%s"
 
-Дай рекомендацию по этой еде для контроля сахара в крови.`, diabetesTypeText, foodDescription),
+Дай рекомендацию по этой еде для контроля сахара в крови.`, diabetesTypeText, foodDescription)),
 	}
 
 	messages := []YandexMessage{systemMessage, userMessage}
@@ -243,10 +249,10 @@ func (s *YandexGPTService) GetGeneralRecommendation(user *models.User, question
 
 	userMessage := YandexMessage{
 		Role: "user",
-		Text: fmt.Sprintf(`Пациент с диабетом %s спрашивает:
+		Text: withPatientContext(s.patient, user, fmt.Sprintf(`Пациент с диабетом %s спрашивает:
 "%s"
 
-Дай полезный ответ по этому вопросу.`, diabetesTypeText, question),
+Дай полезный ответ по этому вопросу.`, diabetesTypeText, question)),
 	}
 
 	messages := []YandexMessage{systemMessage, userMessage}
@@ -258,4 +264,36 @@ func (s *YandexGPTService) GetGeneralRecommendation(user *models.User, question
 	}
 
 	return response
-}
\ No newline at end of file
+}
+
+func (s *YandexGPTService) GetDigestCommentary(user *models.User, summary string) string {
+	if s.apiKey == "" || s.apiKey == "your_yandex_api_key_here" {
+		return ""
+	}
+
+	diabetesTypeText := "не указан"
+	if user.DiabetesType != nil {
+		diabetesTypeText = fmt.Sprintf("%d типа", *user.DiabetesType)
+	}
+
+	systemMessage := YandexMessage{
+		Role: "system",
+		Text: digestSystemPrompt + "\nНе используй поиск.",
+	}
+
+	userMessage := YandexMessage{
+		Role: "user",
+		Text: withPatientContext(s.patient, user, fmt.Sprintf(`Пациент с диабетом %s. Показатели за неделю:
+%s
+
+Прокомментируй неделю.`, diabetesTypeText, summary)),
+	}
+
+	response, err := s.sendRequest([]YandexMessage{systemMessage, userMessage})
+	if err != nil {
+		log.Printf("YandexGPT digest commentary error: %v", err)
+		return ""
+	}
+
+	return response
+}
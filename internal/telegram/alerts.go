@@ -0,0 +1,237 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const alertsUsage = `Настройка:
+/alerts on или /alerts off — включить или выключить
+/alerts low 3.5 — порог низкого сахара (2–4.5)
+/alerts high 18 — порог высокого сахара (10–30)
+/alerts recheck 20 — минут до напоминания родственникам (5–120)
+/alerts quiet 23:00-07:00 — тихие часы, /alerts quiet off — без них
+/alerts tz Europe/Moscow — часовой пояс тихих часов`
+
+// alertKindText — вид тревоги для сообщений
+func alertKindText(kind string) string {
+	if kind == models.AlertKindLow {
+		return "гипогликемия"
+	}
+	return "очень высокий сахар"
+}
+
+// alertAckKeyboard — кнопка подтверждения тревоги для родственника
+func alertAckKeyboard(alertID uint) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Вижу, на связи", fmt.Sprintf("alert_ack_%d", alertID)),
+	))
+}
+
+// NotifyAlert рассылает события тревог владельцу дневника и родственникам
+func (b *Bot) NotifyAlert(event services.AlertEvent) {
+	alert := event.Alert
+	name := services.NewShareUser(event.Owner).DisplayName()
+	reading := fmt.Sprintf("%.1f ммоль/л в %s", alert.Value, alert.MeasuredAt.Local().Format("15:04"))
+	recheckBy := alert.RecheckBy.Local().Format("15:04")
+
+	switch event.Type {
+	case services.AlertEventRaised:
+		for _, caregiver := range event.Caregivers {
+			msg := tgbotapi.NewMessage(caregiver.TelegramID, fmt.Sprintf("🚨 %s: %s — %s.\n\nЕсли повторного измерения не будет до %s, я напомню.",
+				name, alertKindText(alert.Kind), reading, recheckBy))
+			msg.ReplyMarkup = alertAckKeyboard(alert.ID)
+			b.send(caregiver.TelegramID, msg)
+		}
+		advice, ketones := "Съешьте 15–20 г быстрых углеводов", ""
+		if alert.Kind == models.AlertKindHigh {
+			advice = "Проверьте кетоны и следуйте плану коррекции"
+			// Свежие кетоны или просьба их измерить вместо общего совета
+			if hint := b.ketoneHint(event.Owner, alert.Value, alert.MeasuredAt); hint != "" {
+				advice, ketones = "Следуйте плану коррекции", "\n\n"+hint
+			}
+		}
+		b.sendMessage(event.Owner.TelegramID, fmt.Sprintf("⚠️ Сахар %.1f ммоль/л — %s. %s и перемерьте сахар до %s.\nРодственники получили уведомление.%s",
+			alert.Value, alertKindText(alert.Kind), advice, recheckBy, ketones))
+	case services.AlertEventEscalated:
+		for _, caregiver := range event.Caregivers {
+			msg := tgbotapi.NewMessage(caregiver.TelegramID, fmt.Sprintf("❗️ %s: нет повторного измерения после тревоги (%s, %s). Свяжитесь, пожалуйста, напрямую.",
+				name, alertKindText(alert.Kind), reading))
+			msg.ReplyMarkup = alertAckKeyboard(alert.ID)
+			b.send(caregiver.TelegramID, msg)
+		}
+		b.sendMessage(event.Owner.TelegramID, "⏰ Нет повторного измерения сахара — родственники получили напоминание. Перемерьте сахар и запишите результат.")
+	case services.AlertEventResolved:
+		for _, caregiver := range event.Caregivers {
+			b.sendMessage(caregiver.TelegramID, fmt.Sprintf("✅ %s: получено повторное измерение, тревога снята.", name))
+		}
+	case services.AlertEventAcknowledged:
+		if event.By == nil {
+			return
+		}
+		by := services.NewShareUser(event.By).DisplayName()
+		if event.By.ID != event.Owner.ID {
+			b.sendMessage(event.Owner.TelegramID, fmt.Sprintf("👀 %s видит тревогу и на связи.", by))
+		}
+		for _, caregiver := range event.Caregivers {
+			if caregiver.ID != event.By.ID {
+				b.sendMessage(caregiver.TelegramID, fmt.Sprintf("👀 %s видит тревогу по дневнику %s.", by, name))
+			}
+		}
+	}
+}
+
+// handleAlertSelection подтверждает тревогу по кнопке; action — ack_<id>
+func (b *Bot) handleAlertSelection(chatID int64, action string, user *models.User) {
+	id, ok := strings.CutPrefix(action, "ack_")
+	alertID, err := strconv.ParseUint(id, 10, 64)
+	if !ok || err != nil {
+		b.sendMessage(chatID, "Ошибка обработки тревоги")
+		return
+	}
+
+	alert, err := b.alertService.Acknowledge(user.ID, uint(alertID))
+	if errors.Is(err, services.ErrNotFound) {
+		b.sendMessage(chatID, "Тревога не найдена: возможно, доступ к дневнику закрыт.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error acknowledging alert %d for user %d: %v", alertID, user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось подтвердить тревогу, попробуйте позже.")
+		return
+	}
+
+	switch {
+	case alert.Status == models.AlertStatusResolved:
+		b.sendMessage(chatID, "Тревога уже снята: было повторное измерение.")
+	case alert.AcknowledgedBy != nil && *alert.AcknowledgedBy != user.ID:
+		b.sendMessage(chatID, "Тревогу уже подтвердил другой родственник.")
+	default:
+		b.sendMessage(chatID, "👌 Спасибо! Остальные знают, что вы на связи.")
+	}
+}
+
+// handleAlertsCommand показывает и меняет настройки тревог родственникам
+func (b *Bot) handleAlertsCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	rule, err := b.alertService.Rules(user.ID)
+	if err != nil {
+		log.Printf("Error getting alert rules for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось получить настройки тревог, попробуйте позже.")
+		return
+	}
+
+	args := strings.Fields(strings.ToLower(message.CommandArguments()))
+	if len(args) == 0 {
+		b.sendAlertRules(chatID, user, rule)
+		return
+	}
+
+	update := *rule
+	parsed := true
+	switch {
+	case len(args) == 1 && args[0] == "on":
+		update.Enabled = true
+	case len(args) == 1 && args[0] == "off":
+		update.Enabled = false
+	case len(args) == 2 && (args[0] == "low" || args[0] == "high"):
+		value, err := strconv.ParseFloat(strings.ReplaceAll(args[1], ",", "."), 64)
+		parsed = err == nil
+		if args[0] == "low" {
+			update.LowThreshold = value
+		} else {
+			update.HighThreshold = value
+		}
+	case len(args) == 2 && args[0] == "recheck":
+		update.RecheckMinutes, err = strconv.Atoi(args[1])
+		parsed = err == nil
+	case len(args) == 2 && args[0] == "quiet" && args[1] == "off":
+		update.QuietStart, update.QuietEnd = "", ""
+	case len(args) == 2 && args[0] == "quiet":
+		update.QuietStart, update.QuietEnd, parsed = strings.Cut(args[1], "-")
+	case len(args) == 2 && args[0] == "tz":
+		// Имена часовых поясов чувствительны к регистру
+		update.Timezone = strings.Fields(message.CommandArguments())[1]
+	default:
+		parsed = false
+	}
+	if !parsed {
+		b.sendMessage(chatID, alertsUsage)
+		return
+	}
+
+	saved, err := b.alertService.UpdateRules(user.ID, update)
+	var verr *services.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		b.sendMessage(chatID, alertRuleErrorText(verr)+"\n\n"+alertsUsage)
+		return
+	case err != nil:
+		log.Printf("Error updating alert rules for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось сохранить настройки тревог, попробуйте позже.")
+		return
+	}
+	b.sendAlertRules(chatID, user, saved)
+}
+
+// alertRuleErrorText объясняет ошибку настройки тревог
+func alertRuleErrorText(verr *services.ValidationError) string {
+	switch verr.Rule {
+	case "between":
+		bounds := strings.Fields(verr.Param)
+		if len(bounds) == 2 {
+			return fmt.Sprintf("❌ Значение должно быть от %s до %s.", bounds[0], bounds[1])
+		}
+	case "time_of_day", "required":
+		return "❌ Укажите тихие часы в формате ЧЧ:ММ-ЧЧ:ММ, например 23:00-07:00."
+	}
+	if verr.Field == "timezone" {
+		return "❌ Неизвестный часовой пояс. Пример: Europe/Moscow."
+	}
+	return "❌ Некорректное значение."
+}
+
+// sendAlertRules показывает текущие настройки тревог
+func (b *Bot) sendAlertRules(chatID int64, user *models.User, rule *models.AlertRule) {
+	var text strings.Builder
+	if rule.Enabled {
+		text.WriteString("🚨 Тревоги родственникам включены\n\n")
+	} else {
+		text.WriteString("🔕 Тревоги родственникам выключены\n\n")
+	}
+	fmt.Fprintf(&text, "Сахар ниже %.1f или выше %.1f ммоль/л — родственники узнают сразу.\n", rule.LowThreshold, rule.HighThreshold)
+	fmt.Fprintf(&text, "Нет повторного измерения %d мин — напоминаю им еще раз.\n", rule.RecheckMinutes)
+	if rule.QuietStart != "" {
+		fmt.Fprintf(&text, "Тихие часы: %s–%s", rule.QuietStart, rule.QuietEnd)
+		if rule.Timezone != "" {
+			fmt.Fprintf(&text, " (%s)", rule.Timezone)
+		}
+		text.WriteString(" — высокий сахар ждет их окончания, низкий отправляется всегда.\n")
+	}
+
+	grants, err := b.sharingService.ListGrants(user.ID)
+	if err != nil {
+		log.Printf("Error listing shares for user %d: %v", user.ID, err)
+	}
+	caregivers := 0
+	for _, grant := range grants {
+		if grant.Grantee != nil {
+			caregivers++
+		}
+	}
+	if caregivers > 0 {
+		fmt.Fprintf(&text, "Получатели: %d (список в /share)\n", caregivers)
+	} else {
+		text.WriteString("Получателей пока нет: откройте дневник родственнику командой /share\n")
+	}
+	text.WriteString("\n" + alertsUsage)
+	b.sendMessage(chatID, text.String())
+}
@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBot_AlertsCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 8180)
+
+	bot.handleCommand(commandMessage(8180, "/alerts", "/alerts"), user)
+	text := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "включены")
+	assert.Contains(t, text, "ниже 3.0 или выше 20.0")
+	assert.Contains(t, text, "/share")
+
+	bot.handleCommand(commandMessage(8180, "/alerts", "/alerts low 3,5"), user)
+	bot.handleCommand(commandMessage(8180, "/alerts", "/alerts quiet 23:00-07:00"), user)
+	bot.handleCommand(commandMessage(8180, "/alerts", "/alerts tz Europe/Moscow"), user)
+	text = mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "ниже 3.5")
+	assert.Contains(t, text, "Тихие часы: 23:00–07:00 (Europe/Moscow)")
+
+	rule, err := bot.alertService.Rules(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3.5, rule.LowThreshold)
+	assert.Equal(t, "Europe/Moscow", rule.Timezone)
+
+	bot.handleCommand(commandMessage(8180, "/alerts", "/alerts recheck 500"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "от 5 до 120")
+	bot.handleCommand(commandMessage(8180, "/alerts", "/alerts off"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "выключены")
+	bot.handleCommand(commandMessage(8180, "/alerts", "/alerts loud"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "/alerts on")
+}
+
+func TestBot_NotifyAlertAndAcknowledge(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	owner := testutils.CreateTestUser(testDB.DB, 8190)
+	parent := testutils.CreateTestUser(testDB.DB, 8191)
+	invite, err := bot.sharingService.CreateInvite(owner.ID, models.ShareRoleRead)
+	require.NoError(t, err)
+	_, err = bot.sharingService.AcceptInvite(parent.ID, invite.Token)
+	require.NoError(t, err)
+
+	alert := &models.Alert{
+		UserID:     owner.ID,
+		Kind:       models.AlertKindLow,
+		Value:      2.6,
+		MeasuredAt: time.Now(),
+		Status:     models.AlertStatusOpen,
+		RecheckBy:  time.Now().Add(15 * time.Minute),
+	}
+	require.NoError(t, repository.NewGormAlertRepository(testDB.DB).Create(alert))
+
+	bot.NotifyAlert(services.AlertEvent{Type: services.AlertEventRaised, Alert: *alert, Owner: owner, Caregivers: []models.User{*parent}})
+	messages := mockAPI.GetAllSentMessages()
+	require.Len(t, messages, 2)
+	caregiverMsg := messages[0].(tgbotapi.MessageConfig)
+	assert.Equal(t, int64(8191), caregiverMsg.ChatID)
+	assert.Contains(t, caregiverMsg.Text, "Test User 8190: гипогликемия — 2.6 ммоль/л")
+	keyboard, ok := caregiverMsg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	require.True(t, ok)
+	data := *keyboard.InlineKeyboard[0][0].CallbackData
+	assert.Equal(t, fmt.Sprintf("alert_ack_%d", alert.ID), data)
+	ownerMsg := messages[1].(tgbotapi.MessageConfig)
+	assert.Equal(t, int64(8190), ownerMsg.ChatID)
+	assert.Contains(t, ownerMsg.Text, "быстрых углеводов")
+
+	// Подтверждение по кнопке: без уведомителя сервис только сохраняет статус
+	mockAPI.ClearMessages()
+	bot.handleAlertSelection(8191, data[6:], parent)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "Спасибо")
+	acked, err := repository.NewGormAlertRepository(testDB.DB).GetByID(alert.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusAcknowledged, acked.Status)
+
+	stranger := testutils.CreateTestUser(testDB.DB, 8192)
+	bot.handleAlertSelection(8192, data[6:], stranger)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "не найдена")
+
+	mockAPI.ClearMessages()
+	bot.NotifyAlert(services.AlertEvent{Type: services.AlertEventAcknowledged, Alert: *acked, Owner: owner, Caregivers: []models.User{*parent}, By: parent})
+	messages = mockAPI.GetAllSentMessages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, int64(8190), messages[0].(tgbotapi.MessageConfig).ChatID)
+	assert.Contains(t, messages[0].(tgbotapi.MessageConfig).Text, "Test User 8191 видит тревогу")
+}
@@ -1,29 +1,65 @@
 package telegram
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 
 	"diabetbot/internal/config"
 	"diabetbot/internal/models"
 	"diabetbot/internal/services"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"gorm.io/gorm"
 )
 
+// botAPI описывает используемую часть tgbotapi.BotAPI, чтобы клиент можно было подменить в тестах
+type botAPI interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
 type Bot struct {
-	api         *tgbotapi.BotAPI
+	api         botAPI
 	userService *services.UserService
 	glucoseService *services.GlucoseService
 	foodService *services.FoodService
+	insulinService *services.InsulinService
+	aiUsageService *services.AIUsageService
+	exportService  *services.ExportService
+	reportService  *services.ReportService
+	importService  *services.ImportService
+	nightscoutService *services.NightscoutService
+	sharingService *services.SharingService
+	alertService   *services.AlertService
+	linkService    *services.PublicLinkService
+	digestService  *services.DigestService
+	insightService *services.InsightService
+	forecastService *services.ForecastService
+	labService     *services.LabService
+	vitalService   *services.VitalService
 	aiService   services.AIService
 	config      *config.TelegramConfig
+	username    string // имя бота для ссылок t.me
+	dispatcher  *Dispatcher
+	outbox      *Outbox
+	pending     pendingInputs
 }
 
-func NewBot(cfg *config.TelegramConfig, db *gorm.DB, aiService services.AIService) (*Bot, error) {
-	bot, err := tgbotapi.NewBotAPI(cfg.BotToken)
+// BotStats содержит метрики входящей и исходящей очередей бота
+type BotStats struct {
+	Updates QueueStats  `json:"updates"`
+	Outbox  OutboxStats `json:"outbox"`
+}
+
+func NewBot(cfg *config.TelegramConfig, svc *services.Services, aiService services.AIService) (*Bot, error) {
+	endpoint := cfg.APIEndpoint
+	if endpoint == "" {
+		endpoint = tgbotapi.APIEndpoint
+	}
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.BotToken, endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
@@ -33,12 +69,29 @@ func NewBot(cfg *config.TelegramConfig, db *gorm.DB, aiService services.AIServic
 
 	telegramBot := &Bot{
 		api:            bot,
-		userService:    services.NewUserService(db),
-		glucoseService: services.NewGlucoseService(db),
-		foodService:    services.NewFoodService(db),
+		userService:    svc.Users,
+		glucoseService: svc.Glucose,
+		foodService:    svc.Food,
+		insulinService: svc.Insulin,
+		aiUsageService: svc.AIUsage,
+		exportService:  svc.Export,
+		reportService:  svc.Report,
+		importService:  svc.Import,
+		nightscoutService: svc.Nightscout,
+		sharingService: svc.Sharing,
+		alertService:   svc.Alerts,
+		linkService:    svc.Links,
+		digestService:  svc.Digests,
+		insightService: svc.Insights,
+		forecastService: svc.Forecast,
+		labService:     svc.Labs,
+		vitalService:   svc.Vitals,
 		aiService:      aiService,
 		config:         cfg,
+		username:       bot.Self.UserName,
 	}
+	telegramBot.dispatcher = NewDispatcher(cfg.Workers, cfg.QueueSize, telegramBot.handleUpdate)
+	telegramBot.outbox = NewOutbox(bot, DefaultOutboxConfig(), telegramBot.handleBlocked)
 	
 	// WebApp будет работать через обычные кнопки и команды
 	
@@ -52,11 +105,34 @@ func (b *Bot) Start() error {
 	return nil
 }
 
-func (b *Bot) HandleWebhook(update tgbotapi.Update) {
+// HandleWebhook ставит обновление в очередь обработки.
+// Ошибка означает, что очередь переполнена или бот останавливается.
+func (b *Bot) HandleWebhook(update tgbotapi.Update) error {
+	return b.dispatcher.Dispatch(update)
+}
+
+// Shutdown дожидается обработки уже принятых обновлений и отправки ответов
+func (b *Bot) Shutdown(ctx context.Context) error {
+	if err := b.dispatcher.Shutdown(ctx); err != nil {
+		b.outbox.Shutdown(ctx)
+		return err
+	}
+	return b.outbox.Shutdown(ctx)
+}
+
+// Stats возвращает метрики очередей бота
+func (b *Bot) Stats() BotStats {
+	return BotStats{
+		Updates: b.dispatcher.Stats(),
+		Outbox:  b.outbox.Stats(),
+	}
+}
+
+func (b *Bot) handleUpdate(update tgbotapi.Update) {
 	if update.Message != nil {
-		go b.handleMessage(update.Message)
+		b.handleMessage(update.Message)
 	} else if update.CallbackQuery != nil {
-		go b.handleCallbackQuery(update.CallbackQuery)
+		b.handleCallbackQuery(update.CallbackQuery)
 	}
 }
 
@@ -68,11 +144,18 @@ func (b *Bot) handleMessage(message *tgbotapi.Message) {
 		return
 	}
 
+	// Команда или файл отменяют ожидание ввода после нажатия кнопки
+	if message.IsCommand() || message.Document != nil {
+		b.pending.clear(message.Chat.ID)
+	} else if b.handlePendingInput(message, user) {
+		return
+	}
+
 	switch {
+	case message.Document != nil:
+		b.handleDocument(message, user)
 	case message.IsCommand():
 		b.handleCommand(message, user)
-	case isNumeric(message.Text):
-		b.handleGlucoseInput(message, user)
 	case b.isKeyboardButton(message.Text):
 		b.handleKeyboardButton(message, user)
 	default:
@@ -96,12 +179,41 @@ func (b *Bot) handleCommand(message *tgbotapi.Message, user *models.User) {
 		b.handleWebAppCommand(message, user)
 	case "limits":
 		b.handleLimitsCommand(message, user)
+	case "export":
+		b.handleExportCommand(message, user)
+	case "report":
+		b.handleReportCommand(message)
+	case "import":
+		b.sendMessage(message.Chat.ID, importUsage)
+	case "nightscout":
+		b.handleNightscoutCommand(message, user)
+	case "share":
+		b.handleShareCommand(message, user)
+	case "alerts":
+		b.handleAlertsCommand(message, user)
+	case "link":
+		b.handleLinkCommand(message, user)
+	case "links":
+		b.handleLinksCommand(message, user)
+	case "digest":
+		b.handleDigestCommand(message, user)
+	case "insights":
+		b.handleInsightsCommand(message, user)
+	case "labs":
+		b.handleLabsCommand(message, user)
+	case "vitals":
+		b.handleVitalsCommand(message, user)
 	default:
 		b.sendMessage(message.Chat.ID, "Неизвестная команда. Используйте /help для списка команд.")
 	}
 }
 
 func (b *Bot) handleStartCommand(message *tgbotapi.Message, user *models.User) {
+	if token, ok := strings.CutPrefix(message.CommandArguments(), services.ShareStartPrefix); ok {
+		b.handleShareStart(message, user, token)
+		return
+	}
+
 	text := fmt.Sprintf(`Привет, %s! 👋
 
 Я помогу вам контролировать уровень сахара в крови и питание.
@@ -116,7 +228,7 @@ func (b *Bot) handleStartCommand(message *tgbotapi.Message, user *models.User) {
 	keyboard := b.getMainKeyboard()
 	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(message.Chat.ID, msg)
 }
 
 func (b *Bot) handleHelpCommand(message *tgbotapi.Message) {
@@ -126,8 +238,14 @@ func (b *Bot) handleHelpCommand(message *tgbotapi.Message) {
 🔘 Или просто напишите мне:
   • Число (например, 5.6) - записать уровень сахара
   • Описание еды - записать в дневник питания
+  • Дозу инсулина (например, уколол 6 ед) - записать инсулин
+  • Анализ (например, HbA1c 6.8%) - записать результат анализа
+  • Вес, давление или кетоны (например, давление 130/85, кетоны 0.8)
   • Вопрос о диабете - получить рекомендацию от ИИ
 
+Можно писать одним сообщением и указывать время:
+  «вчера в 22:30 сахар 7,8 после ужина, уколол 4 ед»
+
 📱 Веб-приложение:
 Для подробной статистики, графиков и управления данными используйте веб-приложение - нажмите кнопку "📱 Веб-приложение"
 
@@ -135,12 +253,29 @@ func (b *Bot) handleHelpCommand(message *tgbotapi.Message) {
 Бот анализирует ваши данные и дает персональные рекомендации на основе уровня сахара и питания.
 
 📊 Лимиты AI:
-/limits - проверить количество оставшихся AI запросов на сегодня`
+/limits - проверить количество оставшихся AI запросов на сегодня
+/digest - сводка за неделю с комментарием ИИ (/digest on - присылать по воскресеньям)
+/insights - закономерности в дневнике: утренние подъемы, ночные гипогликемии и другие
+/labs - последние анализы и HbA1c в сравнении с дневником
+/vitals - последние вес, давление и кетоны
+
+📤 Экспорт:
+/export - выгрузить дневник в CSV (/export json 30 - JSON за 30 дней, /export fhir - FHIR для врача)
+/report - отчет для врача в PDF
+
+📥 Импорт:
+/import - загрузить измерения из LibreView, Dexcom Clarity или CSV глюкометра
+/nightscout - подключить xDrip+, AAPS и приложения Nightscout
+
+👪 Доступ:
+/share - открыть дневник родственнику или врачу
+/alerts - тревоги родственникам при очень низком или высоком сахаре
+/link - ссылка на сводку для врача без Telegram (/links - ваши ссылки)`
 
 	keyboard := b.getMainKeyboard()
 	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(message.Chat.ID, msg)
 }
 
 func (b *Bot) handleGlucoseCommand(message *tgbotapi.Message) {
@@ -157,7 +292,7 @@ func (b *Bot) handleGlucoseCommand(message *tgbotapi.Message) {
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, "🩸 Выберите время измерения или просто отправьте число (например: 5.6):")
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(message.Chat.ID, msg)
 }
 
 func (b *Bot) handleFoodCommand(message *tgbotapi.Message) {
@@ -174,7 +309,7 @@ func (b *Bot) handleFoodCommand(message *tgbotapi.Message) {
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, "🍽 Выберите тип приема пищи:")
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(message.Chat.ID, msg)
 }
 
 func (b *Bot) handleStatsCommand(message *tgbotapi.Message, user *models.User) {
@@ -191,7 +326,7 @@ func (b *Bot) handleStatsCommand(message *tgbotapi.Message, user *models.User) {
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, "📊 Выберите период для статистики:")
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(message.Chat.ID, msg)
 }
 
 func (b *Bot) handleWebAppCommand(message *tgbotapi.Message, user *models.User) {
@@ -221,57 +356,7 @@ func (b *Bot) handleWebAppCommand(message *tgbotapi.Message, user *models.User)
 
 Нажмите кнопку ниже для открытия:`)
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
-}
-
-func (b *Bot) handleGlucoseInput(message *tgbotapi.Message, user *models.User) {
-	value, err := strconv.ParseFloat(message.Text, 64)
-	if err != nil || value < 1.0 || value > 30.0 {
-		b.sendMessage(message.Chat.ID, "Пожалуйста, введите корректное значение глюкозы (1.0-30.0 ммоль/л)")
-		return
-	}
-
-	record, err := b.glucoseService.CreateRecord(user.ID, value, "")
-	if err != nil {
-		b.sendMessage(message.Chat.ID, "Ошибка сохранения данных")
-		return
-	}
-
-	// Получаем рекомендации от ИИ
-	recommendation := b.aiService.GetGlucoseRecommendation(user, record)
-	
-	response := fmt.Sprintf("✅ Записал: %.1f ммоль/л\n\n🤖 %s", value, recommendation)
-	b.sendMessage(message.Chat.ID, response)
-}
-
-func (b *Bot) handleTextMessage(message *tgbotapi.Message, user *models.User) {
-	// Обработка текстового сообщения как возможного описания еды или вопроса
-	if len(message.Text) < 3 {
-		b.sendMessage(message.Chat.ID, "Не понял вас. Используйте /help для получения помощи.")
-		return
-	}
-
-	// Пытаемся определить, это еда или вопрос
-	if isFoodDescription(message.Text) {
-		b.handleFoodDescription(message, user)
-	} else {
-		b.handleQuestion(message, user)
-	}
-}
-
-func (b *Bot) handleFoodDescription(message *tgbotapi.Message, user *models.User) {
-	// Сохраняем запись о еде
-	_, err := b.foodService.CreateRecord(user.ID, message.Text, "неопределено", nil, nil, "", "")
-	if err != nil {
-		b.sendMessage(message.Chat.ID, "Ошибка сохранения записи о питании")
-		return
-	}
-
-	// Получаем рекомендации от ИИ
-	recommendation := b.aiService.GetFoodRecommendation(user, message.Text)
-	
-	response := fmt.Sprintf("✅ Записал в дневник питания: %s\n\n🤖 %s", message.Text, recommendation)
-	b.sendMessage(message.Chat.ID, response)
+	b.send(message.Chat.ID, msg)
 }
 
 func (b *Bot) handleQuestion(message *tgbotapi.Message, user *models.User) {
@@ -280,6 +365,12 @@ func (b *Bot) handleQuestion(message *tgbotapi.Message, user *models.User) {
 }
 
 func (b *Bot) handleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery) {
+	// Кнопки записей подписаны и отвечают на callback сами
+	if strings.HasPrefix(callbackQuery.Data, recordCallbackPrefix) {
+		b.handleRecordCallback(callbackQuery)
+		return
+	}
+
 	// Обработка inline кнопок
 	callback := tgbotapi.NewCallback(callbackQuery.ID, "")
 	b.api.Request(callback)
@@ -301,6 +392,16 @@ func (b *Bot) handleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery) {
 		b.handleGlucosePeriodSelection(chatID, data[8:], user)
 	case len(data) >= 5 && data[:5] == "stats":
 		b.handleStatsSelection(chatID, data[6:], user)
+	case len(data) >= 6 && data[:6] == "report":
+		b.handleReportSelection(chatID, data[7:], user)
+	case len(data) >= 6 && data[:6] == "import":
+		b.handleImportSelection(chatID, data[7:], user)
+	case len(data) >= 5 && data[:5] == "share":
+		b.handleShareSelection(chatID, data[6:], user)
+	case len(data) >= 5 && data[:5] == "alert":
+		b.handleAlertSelection(chatID, data[6:], user)
+	case len(data) >= 4 && data[:4] == "link":
+		b.handleLinkSelection(chatID, data[5:], user)
 	}
 }
 
@@ -322,45 +423,27 @@ func (b *Bot) handleFoodTypeSelection(chatID int64, foodType string) {
 }
 
 func (b *Bot) sendMessage(chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	if _, err := b.api.Send(msg); err != nil {
-		log.Printf("Error sending message: %v", err)
-	}
+	b.send(chatID, tgbotapi.NewMessage(chatID, text))
 }
 
-func isNumeric(s string) bool {
-	_, err := strconv.ParseFloat(s, 64)
-	return err == nil
-}
-
-func isFoodDescription(text string) bool {
-	foodKeywords := []string{"съел", "поел", "ел", "завтрак", "обед", "ужин", "перекус", 
-		"каша", "хлеб", "мясо", "рыба", "овощи", "фрукты", "молоко", "кофе", "чай"}
-	
-	for _, keyword := range foodKeywords {
-		if contains(text, keyword) {
-			return true
-		}
+// send отправляет сообщение через очередь с учетом лимитов Telegram.
+// Без очереди (в тестах) сообщение отправляется напрямую.
+func (b *Bot) send(chatID int64, c tgbotapi.Chattable) {
+	if b.outbox != nil {
+		b.outbox.Send(chatID, c)
+		return
+	}
+	defer release(c)
+	if _, err := b.api.Send(c); err != nil {
+		log.Printf("Error sending message: %v", err)
 	}
-	return false
-}
-
-func contains(text, substr string) bool {
-	return len(text) >= len(substr) && 
-		(text == substr || 
-		 (len(text) > len(substr) && 
-		  (text[:len(substr)] == substr || 
-		   text[len(text)-len(substr):] == substr ||
-		   containsMiddle(text, substr))))
 }
 
-func containsMiddle(text, substr string) bool {
-	for i := 1; i <= len(text)-len(substr); i++ {
-		if text[i:i+len(substr)] == substr {
-			return true
-		}
+// handleBlocked помечает пользователя неактивным, когда он заблокировал бота
+func (b *Bot) handleBlocked(chatID int64) {
+	if err := b.userService.DeactivateByTelegramID(chatID); err != nil {
+		log.Printf("Error deactivating user %d: %v", chatID, err)
 	}
-	return false
 }
 
 // getMainKeyboard возвращает основную клавиатуру
@@ -488,6 +571,14 @@ func (b *Bot) handleStatsSelection(chatID int64, period string, user *models.Use
 		statusText = "Требуется внимание"
 	}
 
+	// Последний HbA1c рядом с GMI до него, независимо от выбранного периода
+	var labText string
+	if comparison, err := b.labService.Latest(user.ID); err != nil {
+		log.Printf("Error comparing HbA1c for user %d: %v", user.ID, err)
+	} else if comparison != nil {
+		labText = "\n\n🧪 " + comparison.Summary()
+	}
+
 	text := fmt.Sprintf(`📊 Статистика %s:
 
 📈 Средний уровень: %.1f ммоль/л %s
@@ -495,29 +586,26 @@ func (b *Bot) handleStatsSelection(chatID int64, period string, user *models.Use
 📊 Максимум: %.1f ммоль/л
 🔢 Всего измерений: %d
 
-%s %s
+%s %s%s
 
 💡 Для подробных графиков и трендов используйте веб-приложение`, 
 		periodText, stats.Average, statusEmoji, stats.Min, stats.Max, stats.Count,
-		statusEmoji, statusText)
+		statusEmoji, statusText, labText)
 
 	b.sendMessage(chatID, text)
 }
 
 // handleLimitsCommand обрабатывает команду /limits
 func (b *Bot) handleLimitsCommand(message *tgbotapi.Message, user *models.User) {
-	// Создаем сервис для проверки лимитов
-	aiUsageService := services.NewAIUsageService(b.userService.GetDB())
-	
 	// Получаем количество использованных запросов
-	used, err := aiUsageService.GetUsageToday(user.ID)
+	used, err := b.aiUsageService.GetUsageToday(user.ID)
 	if err != nil {
 		b.sendMessage(message.Chat.ID, "❌ Ошибка при проверке лимитов. Попробуйте позже.")
 		return
 	}
 	
 	// Получаем количество оставшихся запросов
-	remaining, err := aiUsageService.GetRemainingRequests(user.ID)
+	remaining, err := b.aiUsageService.GetRemainingRequests(user.ID)
 	if err != nil {
 		b.sendMessage(message.Chat.ID, "❌ Ошибка при проверке лимитов. Попробуйте позже.")
 		return
@@ -1,10 +1,15 @@
 package telegram
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"diabetbot/internal/config"
 	"diabetbot/internal/models"
+	"diabetbot/internal/repository"
 	"diabetbot/internal/services"
 	"diabetbot/internal/testutils"
 
@@ -62,10 +67,25 @@ func createTestBot() (*Bot, *MockBotAPI, *testutils.TestDB) {
 	
 	bot := &Bot{
 		api:             mockAPI,
-		userService:     services.NewUserService(db),
-		glucoseService:  services.NewGlucoseService(db),
-		foodService:     services.NewFoodService(db),
-		gigachatService: gigachatService,
+		userService:     services.NewUserService(repository.NewGormUserRepository(db)),
+		glucoseService:  services.NewGlucoseService(repository.NewGormGlucoseRepository(db)),
+		foodService:     services.NewFoodService(repository.NewGormFoodRepository(db)),
+		insulinService:  services.NewInsulinService(repository.NewGormInsulinRepository(db)),
+		aiUsageService:  services.NewAIUsageService(repository.NewGormAIUsageRepository(db)),
+		exportService:   services.NewExportService(repository.NewGormUserRepository(db), repository.NewGormGlucoseRepository(db), repository.NewGormFoodRepository(db), repository.NewGormInsulinRepository(db), repository.NewGormVitalRepository(db)),
+		reportService:   services.NewReportService(repository.NewGormGlucoseRepository(db), repository.NewGormFoodRepository(db)),
+		importService:   services.NewImportService(repository.NewGormGlucoseRepository(db), repository.NewGormFoodRepository(db), repository.NewGormImportJobRepository(db)),
+		nightscoutService: services.NewNightscoutService(repository.NewGormUserRepository(db), repository.NewGormGlucoseRepository(db), repository.NewGormFoodRepository(db), repository.NewGormInsulinRepository(db)),
+		sharingService:  services.NewSharingService(repository.NewGormUserRepository(db), repository.NewGormShareRepository(db)),
+		alertService:    services.NewAlertService(repository.NewGormUserRepository(db), repository.NewGormShareRepository(db), repository.NewGormAlertRepository(db)),
+		linkService:     services.NewPublicLinkService(repository.NewGormUserRepository(db), repository.NewGormPublicLinkRepository(db), repository.NewGormGlucoseRepository(db), repository.NewGormFoodRepository(db)),
+		digestService:   services.NewDigestService(repository.NewGormUserRepository(db), repository.NewGormDigestRepository(db), repository.NewGormGlucoseRepository(db), repository.NewGormFoodRepository(db)),
+		insightService:  services.NewInsightService(repository.NewGormGlucoseRepository(db), repository.NewGormFoodRepository(db), repository.NewGormDigestRepository(db)),
+		forecastService: services.NewForecastService(repository.NewGormGlucoseRepository(db), repository.NewGormFoodRepository(db), repository.NewGormInsulinRepository(db)),
+		labService:      services.NewLabService(repository.NewGormLabRepository(db), repository.NewGormGlucoseRepository(db)),
+		vitalService:    services.NewVitalService(repository.NewGormVitalRepository(db)),
+		aiService:       gigachatService,
+		config:          &config.TelegramConfig{},
 	}
 	
 	return bot, mockAPI, &testutils.TestDB{DB: db}
@@ -102,12 +122,10 @@ func TestBot_HandleStartCommand(t *testing.T) {
 	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
 	require.True(t, ok)
 	
-	assert.Contains(t, sentMsg.Text, "Привет, Test!")
-	assert.Contains(t, sentMsg.Text, "/glucose")
-	assert.Contains(t, sentMsg.Text, "/food")
-	assert.Contains(t, sentMsg.Text, "/stats")
-	assert.Contains(t, sentMsg.Text, "/webapp")
-	assert.Contains(t, sentMsg.Text, "/help")
+	assert.Contains(t, sentMsg.Text, "Привет, Test User 123456789!")
+	assert.Contains(t, sentMsg.Text, "5.6")
+	assert.Contains(t, sentMsg.Text, "веб-приложение")
+	assert.NotNil(t, sentMsg.ReplyMarkup)
 }
 
 func TestBot_HandleHelpCommand(t *testing.T) {
@@ -127,14 +145,12 @@ func TestBot_HandleHelpCommand(t *testing.T) {
 	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
 	require.True(t, ok)
 	
-	assert.Contains(t, sentMsg.Text, "Список команд")
-	assert.Contains(t, sentMsg.Text, "/glucose")
-	assert.Contains(t, sentMsg.Text, "/food")
-	assert.Contains(t, sentMsg.Text, "/stats")
-	assert.Contains(t, sentMsg.Text, "/webapp")
+	assert.Contains(t, sentMsg.Text, "Как пользоваться ботом")
+	assert.Contains(t, sentMsg.Text, "/limits")
+	assert.NotNil(t, sentMsg.ReplyMarkup)
 }
 
-func TestBot_HandleGlucoseInput(t *testing.T) {
+func TestBot_HandleTextMessage_Glucose(t *testing.T) {
 	bot, mockAPI, testDB := createTestBot()
 	defer testutils.CleanupTestDB(testDB.DB)
 	
@@ -149,7 +165,7 @@ func TestBot_HandleGlucoseInput(t *testing.T) {
 			Text: "6.5",
 		}
 
-		bot.handleGlucoseInput(message, user)
+		bot.handleTextMessage(message, user)
 
 		// Проверяем, что запись создана
 		records, err := bot.glucoseService.GetUserRecords(user.ID, 1)
@@ -175,7 +191,7 @@ func TestBot_HandleGlucoseInput(t *testing.T) {
 			Text: "50.0", // слишком высокое значение
 		}
 
-		bot.handleGlucoseInput(message, user)
+		bot.handleTextMessage(message, user)
 
 		// Проверяем отправку сообщения об ошибке
 		require.Len(t, mockAPI.GetAllSentMessages(), 1)
@@ -184,6 +200,32 @@ func TestBot_HandleGlucoseInput(t *testing.T) {
 		
 		assert.Contains(t, sentMsg.Text, "корректное значение")
 		assert.Contains(t, sentMsg.Text, "1.0-30.0")
+
+		// При уточнении запись не создается
+		records, err := bot.glucoseService.GetUserRecords(user.ID, 1)
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+	})
+
+	t.Run("InvalidTime", func(t *testing.T) {
+		mockAPI.ClearMessages()
+
+		message := &tgbotapi.Message{
+			MessageID: 3,
+			Chat:      &tgbotapi.Chat{ID: 123456789},
+			Text:      "вчера в 25:99 сахар 6",
+		}
+
+		bot.handleTextMessage(message, user)
+
+		require.Len(t, mockAPI.GetAllSentMessages(), 1)
+		sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+		require.True(t, ok)
+		assert.Contains(t, sentMsg.Text, "Не понял время «25:99»")
+
+		records, err := bot.glucoseService.GetUserRecords(user.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
 	})
 
 	t.Run("NonNumericValue", func(t *testing.T) {
@@ -195,17 +237,18 @@ func TestBot_HandleGlucoseInput(t *testing.T) {
 			Text: "abc",
 		}
 
-		bot.handleGlucoseInput(message, user)
+		bot.handleTextMessage(message, user)
 
+		// Текст без чисел уходит вопросом к ИИ
 		require.Len(t, mockAPI.GetAllSentMessages(), 1)
 		sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
 		require.True(t, ok)
 		
-		assert.Contains(t, sentMsg.Text, "корректное значение")
+		assert.Contains(t, sentMsg.Text, "🤖")
 	})
 }
 
-func TestBot_HandleFoodDescription(t *testing.T) {
+func TestBot_HandleTextMessage_Food(t *testing.T) {
 	bot, mockAPI, testDB := createTestBot()
 	defer testutils.CleanupTestDB(testDB.DB)
 	
@@ -217,13 +260,13 @@ func TestBot_HandleFoodDescription(t *testing.T) {
 		Text: "съел овсянку с ягодами",
 	}
 
-	bot.handleFoodDescription(message, user)
+	bot.handleTextMessage(message, user)
 
 	// Проверяем создание записи о еде
 	records, err := bot.foodService.GetUserRecords(user.ID, 1)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
-	assert.Equal(t, "съел овсянку с ягодами", records[0].FoodName)
+	assert.Equal(t, "овсянку с ягодами", records[0].FoodName)
 	assert.Equal(t, "неопределено", records[0].FoodType)
 
 	// Проверяем отправку сообщения
@@ -232,7 +275,7 @@ func TestBot_HandleFoodDescription(t *testing.T) {
 	require.True(t, ok)
 	
 	assert.Contains(t, sentMsg.Text, "Записал в дневник питания")
-	assert.Contains(t, sentMsg.Text, "съел овсянку с ягодами")
+	assert.Contains(t, sentMsg.Text, "овсянку с ягодами")
 	assert.Contains(t, sentMsg.Text, "🤖")
 }
 
@@ -260,12 +303,23 @@ func TestBot_HandleStatsCommand(t *testing.T) {
 	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
 	require.True(t, ok)
 	
-	assert.Contains(t, sentMsg.Text, "Статистика за 7 дней")
+	assert.Contains(t, sentMsg.Text, "Выберите период")
+	assert.NotNil(t, sentMsg.ReplyMarkup)
+
+	// Выбор периода в inline клавиатуре
+	mockAPI.ClearMessages()
+	bot.handleStatsSelection(123456789, "7", user)
+
+	require.Len(t, mockAPI.GetAllSentMessages(), 1)
+	sentMsg, ok = mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+
+	assert.Contains(t, sentMsg.Text, "Статистика за неделю")
 	assert.Contains(t, sentMsg.Text, "Средний уровень: 6.2") // (5.5+6.0+6.5+7.0)/4
 	assert.Contains(t, sentMsg.Text, "Минимум: 5.5")
 	assert.Contains(t, sentMsg.Text, "Максимум: 7.0")
 	assert.Contains(t, sentMsg.Text, "Всего измерений: 4")
-	assert.Contains(t, sentMsg.Text, "/webapp")
+	assert.Contains(t, sentMsg.Text, "веб-приложение")
 }
 
 func TestBot_HandleFoodCommand(t *testing.T) {
@@ -293,6 +347,7 @@ func TestBot_HandleFoodCommand(t *testing.T) {
 func TestBot_HandleWebAppCommand(t *testing.T) {
 	bot, mockAPI, testDB := createTestBot()
 	defer testutils.CleanupTestDB(testDB.DB)
+	bot.config.WebAppURL = "https://example.com"
 	
 	user := testutils.CreateTestUser(testDB.DB, 123456789)
 	
@@ -309,63 +364,90 @@ func TestBot_HandleWebAppCommand(t *testing.T) {
 	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
 	require.True(t, ok)
 	
-	assert.Contains(t, sentMsg.Text, "веб-приложение")
+	assert.Contains(t, sentMsg.Text, "Веб-приложение")
 	assert.NotNil(t, sentMsg.ReplyMarkup)
 }
 
-func TestIsNumeric(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"6.5", true},
-		{"5", true},
-		{"10.25", true},
-		{"0.5", true},
-		{"abc", false},
-		{"6.5abc", false},
-		{"", false},
-		{".", false},
-		{"-5.0", false}, // отрицательные не допускаются
-	}
+func TestBot_HandleTextMessage_MixedEntry(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
 
-	for _, test := range tests {
-		t.Run(test.input, func(t *testing.T) {
-			result := isNumeric(test.input)
-			assert.Equal(t, test.expected, result)
-		})
+	user := testutils.CreateTestUser(testDB.DB, 123456789)
+
+	message := &tgbotapi.Message{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: 123456789},
+		Text:      "вчера в 22:30 сахар 7,8 после ужина, уколол 4 ед новорапида",
 	}
+
+	bot.handleTextMessage(message, user)
+
+	glucoseRecords, err := bot.glucoseService.GetUserRecords(user.ID, 3)
+	require.NoError(t, err)
+	require.Len(t, glucoseRecords, 1)
+	assert.Equal(t, 7.8, glucoseRecords[0].Value)
+	assert.Equal(t, models.GlucoseContextAfterMeal, glucoseRecords[0].MeasurementContext)
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+	measuredAt := glucoseRecords[0].MeasuredAt.In(time.Local)
+	assert.Equal(t, yesterday.Day(), measuredAt.Day())
+	assert.Equal(t, 22, measuredAt.Hour())
+	assert.Equal(t, 30, measuredAt.Minute())
+
+	insulinRecords, err := bot.insulinService.GetUserRecords(user.ID, 3)
+	require.NoError(t, err)
+	require.Len(t, insulinRecords, 1)
+	assert.Equal(t, 4.0, insulinRecords[0].Units)
+	assert.Equal(t, models.InsulinTypeBolus, insulinRecords[0].InsulinType)
+	assert.True(t, insulinRecords[0].InjectedAt.Equal(glucoseRecords[0].MeasuredAt))
+
+	// Отдельное подтверждение с кнопками на каждую запись
+	messages := mockAPI.GetAllSentMessages()
+	require.Len(t, messages, 2)
+	glucoseMsg := messages[0].(tgbotapi.MessageConfig)
+	assert.Contains(t, glucoseMsg.Text, "Записал: 7.8 ммоль/л, после еды")
+	assert.Contains(t, glucoseMsg.Text, "вчера в 22:30")
+	insulinMsg := messages[1].(tgbotapi.MessageConfig)
+	assert.Contains(t, insulinMsg.Text, "Записал инсулин: 4 ед")
+	assert.NotNil(t, insulinMsg.ReplyMarkup)
 }
 
-func TestIsFoodDescription(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"съел овсянку", true},
-		{"поел хлеб", true},
-		{"завтрак был вкусный", true},
-		{"выпил кофе", true},
-		{"ел мясо с овощами", true},
-		{"как дела?", false},
-		{"погода хорошая", false},
-		{"123", false},
-		{"", false},
-	}
+func TestBot_HandleTextMessage_Clarification(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 123456789)
 
-	for _, test := range tests {
-		t.Run(test.input, func(t *testing.T) {
-			result := isFoodDescription(test.input)
-			assert.Equal(t, test.expected, result)
-		})
+	message := &tgbotapi.Message{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: 123456789},
+		Text:      "обед",
 	}
+
+	bot.handleTextMessage(message, user)
+
+	records, err := bot.foodService.GetUserRecords(user.ID, 1)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	require.Len(t, mockAPI.GetAllSentMessages(), 1)
+	sentMsg := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	assert.Contains(t, sentMsg.Text, "Что вы съели?")
+}
+
+func TestFormatRecordTime(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "сегодня в 07:30", formatRecordTime(now.Add(-90*time.Minute), now))
+	assert.Equal(t, "вчера в 22:30", formatRecordTime(time.Date(2026, 10, 17, 22, 30, 0, 0, time.UTC), now))
+	assert.Equal(t, "15.10 в 08:05", formatRecordTime(time.Date(2026, 10, 15, 8, 5, 0, 0, time.UTC), now))
 }
 
 func TestBot_HandleMessage_Classification(t *testing.T) {
 	bot, mockAPI, testDB := createTestBot()
 	defer testutils.CleanupTestDB(testDB.DB)
 	
-	user := testutils.CreateTestUser(testDB.DB, 123456789)
+	testutils.CreateTestUser(testDB.DB, 123456789)
 	
 	t.Run("CommandMessage", func(t *testing.T) {
 		mockAPI.ClearMessages()
@@ -375,6 +457,9 @@ func TestBot_HandleMessage_Classification(t *testing.T) {
 			From: &tgbotapi.User{ID: 123456789, FirstName: "Test"},
 			Chat: &tgbotapi.Chat{ID: 123456789},
 			Text: "/help",
+			Entities: []tgbotapi.MessageEntity{
+				{Type: "bot_command", Offset: 0, Length: 5},
+			},
 		}
 
 		bot.handleMessage(message)
@@ -383,7 +468,7 @@ func TestBot_HandleMessage_Classification(t *testing.T) {
 		require.Len(t, mockAPI.GetAllSentMessages(), 1)
 		sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
 		require.True(t, ok)
-		assert.Contains(t, sentMsg.Text, "Список команд")
+		assert.Contains(t, sentMsg.Text, "Как пользоваться ботом")
 	})
 
 	t.Run("NumericMessage", func(t *testing.T) {
@@ -442,4 +527,124 @@ func TestBot_HandleMessage_Classification(t *testing.T) {
 		require.True(t, ok)
 		assert.Contains(t, sentMsg.Text, "🤖")
 	})
-}
\ No newline at end of file
+}
+// createWebhookBot создает бот через NewBot, направленный на фейковый Bot API
+func createWebhookBot(t *testing.T) (*Bot, *testutils.FakeBotAPI, *testutils.TestDB) {
+	db := testutils.SetupTestDB(t)
+	t.Cleanup(func() { testutils.CleanupTestDB(db) })
+
+	fakeAPI := testutils.NewFakeBotAPI(t)
+	aiService := services.NewGigaChatService(&config.GigaChatConfig{APIKey: ""})
+
+	bot, err := NewBot(fakeAPI.TelegramConfig(), services.New(repository.NewGorm(db)), aiService)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		bot.Shutdown(ctx)
+	})
+
+	return bot, fakeAPI, &testutils.TestDB{DB: db}
+}
+
+// handleWebhookJSON разбирает JSON обновления так же, как webhook обработчик
+func handleWebhookJSON(t *testing.T, bot *Bot, payload string) {
+	var update tgbotapi.Update
+	require.NoError(t, json.Unmarshal([]byte(payload), &update))
+	require.NoError(t, bot.HandleWebhook(update))
+}
+
+func TestBot_Webhook_StartCommand(t *testing.T) {
+	bot, fakeAPI, _ := createWebhookBot(t)
+
+	handleWebhookJSON(t, bot, `{
+		"update_id": 1,
+		"message": {
+			"message_id": 10,
+			"date": 1700000000,
+			"from": {"id": 123456789, "is_bot": false, "first_name": "Anna", "language_code": "ru"},
+			"chat": {"id": 123456789, "type": "private"},
+			"text": "/start",
+			"entities": [{"type": "bot_command", "offset": 0, "length": 6}]
+		}
+	}`)
+
+	calls := fakeAPI.WaitForCalls(t, "sendMessage", 1)
+	assert.Equal(t, int64(123456789), calls[0].ChatID())
+	assert.Contains(t, calls[0].Text(), "Привет, Anna!")
+	assert.Contains(t, calls[0].Params["reply_markup"], "Записать глюкозу")
+}
+
+func TestBot_Webhook_GlucoseReading(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+
+	handleWebhookJSON(t, bot, `{
+		"update_id": 2,
+		"message": {
+			"message_id": 11,
+			"date": 1700000000,
+			"from": {"id": 555, "is_bot": false, "first_name": "Ivan"},
+			"chat": {"id": 555, "type": "private"},
+			"text": "6.5"
+		}
+	}`)
+
+	calls := fakeAPI.WaitForCalls(t, "sendMessage", 1)
+	assert.Contains(t, calls[0].Text(), "Записал: 6.5 ммоль/л")
+
+	var user models.User
+	require.NoError(t, testDB.DB.Where("telegram_id = ?", 555).First(&user).Error)
+	var records []models.GlucoseRecord
+	require.NoError(t, testDB.DB.Where("user_id = ?", user.ID).Find(&records).Error)
+	require.Len(t, records, 1)
+	assert.Equal(t, 6.5, records[0].Value)
+}
+
+func TestBot_Webhook_StatsCallback(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+
+	user := testutils.CreateTestUser(testDB.DB, 777)
+	testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 5.0)
+	testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 7.0)
+
+	handleWebhookJSON(t, bot, `{
+		"update_id": 3,
+		"callback_query": {
+			"id": "cb-1",
+			"from": {"id": 777, "is_bot": false, "first_name": "Test"},
+			"message": {"message_id": 12, "date": 1700000000, "chat": {"id": 777, "type": "private"}, "text": "📊 Выберите период для статистики:"},
+			"data": "stats_7"
+		}
+	}`)
+
+	answers := fakeAPI.WaitForCalls(t, "answerCallbackQuery", 1)
+	assert.Equal(t, "cb-1", answers[0].Params["callback_query_id"])
+
+	calls := fakeAPI.WaitForCalls(t, "sendMessage", 1)
+	assert.Equal(t, int64(777), calls[0].ChatID())
+	assert.Contains(t, calls[0].Text(), "Статистика за неделю")
+	assert.Contains(t, calls[0].Text(), "Средний уровень: 6.0")
+	assert.Contains(t, calls[0].Text(), "Всего измерений: 2")
+}
+
+func TestBot_Webhook_RepliesInOrder(t *testing.T) {
+	bot, fakeAPI, _ := createWebhookBot(t)
+
+	for i, value := range []string{"5.1", "6.2", "7.3"} {
+		handleWebhookJSON(t, bot, fmt.Sprintf(`{
+			"update_id": %d,
+			"message": {
+				"message_id": %d,
+				"date": 1700000000,
+				"from": {"id": 999, "is_bot": false, "first_name": "Test"},
+				"chat": {"id": 999, "type": "private"},
+				"text": "%s"
+			}
+		}`, 100+i, 100+i, value))
+	}
+
+	calls := fakeAPI.WaitForCalls(t, "sendMessage", 3)
+	assert.Contains(t, calls[0].Text(), "Записал: 5.1")
+	assert.Contains(t, calls[1].Text(), "Записал: 6.2")
+	assert.Contains(t, calls[2].Text(), "Записал: 7.3")
+}
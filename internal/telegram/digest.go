@@ -0,0 +1,100 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const digestUsage = `Еженедельная сводка:
+/digest — сводка за последние 7 дней сейчас
+/digest on или /digest off — присылать ли сводку по воскресеньям в 19:00
+/digest tz Europe/Moscow — часовой пояс для воскресного вечера`
+
+// digestText — сообщение со сводкой и комментарием ИИ
+func digestText(digest *models.Digest) string {
+	text := "🗓 " + digest.Summary
+	if digest.Commentary != "" {
+		text += "\n\n🤖 " + digest.Commentary
+	}
+	return text
+}
+
+// NotifyDigest отправляет еженедельную сводку пользователю
+func (b *Bot) NotifyDigest(user *models.User, digest *models.Digest) {
+	b.sendMessage(user.TelegramID, digestText(digest)+"\n\n/digest off — не присылать сводку")
+}
+
+// handleDigestCommand собирает сводку заново или меняет подписку на нее
+func (b *Bot) handleDigestCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	sub, err := b.digestService.Settings(user.ID)
+	if err != nil {
+		log.Printf("Error getting digest settings for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось получить настройки сводки, попробуйте позже.")
+		return
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	switch {
+	case len(args) == 0:
+		b.sendDigest(chatID, user, sub)
+		return
+	case len(args) == 1 && strings.EqualFold(args[0], "on"):
+		sub.Enabled = true
+	case len(args) == 1 && strings.EqualFold(args[0], "off"):
+		sub.Enabled = false
+	case len(args) == 2 && strings.EqualFold(args[0], "tz"):
+		sub.Timezone = args[1]
+	default:
+		b.sendMessage(chatID, digestUsage)
+		return
+	}
+
+	saved, err := b.digestService.UpdateSettings(user.ID, sub.Enabled, sub.Timezone)
+	var verr *services.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		b.sendMessage(chatID, "❌ Неизвестный часовой пояс. Пример: Europe/Moscow.\n\n"+digestUsage)
+		return
+	case err != nil:
+		log.Printf("Error updating digest settings for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось сохранить настройки сводки, попробуйте позже.")
+		return
+	}
+
+	if !saved.Enabled {
+		b.sendMessage(chatID, "🔕 Еженедельная сводка выключена. Получить ее можно в любой момент командой /digest.")
+		return
+	}
+	text := fmt.Sprintf("🗓 Еженедельная сводка включена: пришлю ее в воскресенье в %d:00", services.DigestHour)
+	if saved.Timezone != "" {
+		text += " (" + saved.Timezone + ")"
+	}
+	b.sendMessage(chatID, text+".\nСводка не расходует дневной лимит AI запросов.")
+}
+
+// sendDigest собирает сводку за последние 7 дней и отправляет ее
+func (b *Bot) sendDigest(chatID int64, user *models.User, sub *models.DigestSubscription) {
+	digest, err := b.digestService.Generate(user.ID)
+	if err != nil {
+		log.Printf("Error generating digest for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось собрать сводку, попробуйте позже.")
+		return
+	}
+
+	text := digestText(digest)
+	if !sub.Enabled {
+		text += "\n\n/digest on — присылать сводку каждое воскресенье вечером"
+	}
+	b.sendMessage(chatID, text)
+	if err := b.digestService.MarkSent(digest); err != nil {
+		log.Printf("Error marking digest %d as sent: %v", digest.ID, err)
+	}
+}
@@ -0,0 +1,43 @@
+package telegram
+
+import (
+	"testing"
+
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBot_DigestCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 8200)
+	testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 6.4)
+
+	bot.handleCommand(commandMessage(8200, "/digest", "/digest"), user)
+	text := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "Средний сахар: 6.4")
+	assert.Contains(t, text, "/digest on")
+
+	digests, err := bot.digestService.List(user.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	assert.NotNil(t, digests[0].SentAt)
+
+	bot.handleCommand(commandMessage(8200, "/digest", "/digest tz Europe/Moscow"), user)
+	bot.handleCommand(commandMessage(8200, "/digest", "/digest on"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "включена: пришлю ее в воскресенье в 19:00 (Europe/Moscow)")
+	sub, err := bot.digestService.Settings(user.ID)
+	require.NoError(t, err)
+	assert.True(t, sub.Enabled)
+
+	bot.handleCommand(commandMessage(8200, "/digest", "/digest tz Mars/Olympus"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "Неизвестный часовой пояс")
+	bot.handleCommand(commandMessage(8200, "/digest", "/digest off"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "выключена")
+	bot.handleCommand(commandMessage(8200, "/digest", "/digest weekly"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "/digest tz")
+}
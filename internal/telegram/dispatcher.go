@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"context"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	DefaultDispatcherWorkers   = 8   // Количество воркеров по умолчанию
+	DefaultDispatcherQueueSize = 256 // Максимум необработанных обновлений по умолчанию
+)
+
+// Dispatcher обрабатывает входящие обновления фиксированным пулом воркеров.
+// Обновления одного чата обрабатываются строго последовательно и в порядке
+// поступления, разные чаты обрабатываются параллельно.
+type Dispatcher struct {
+	pool *chatPool[tgbotapi.Update]
+}
+
+// NewDispatcher создает диспетчер и запускает воркеры
+func NewDispatcher(workers, queueSize int, handle func(tgbotapi.Update)) *Dispatcher {
+	if workers <= 0 {
+		workers = DefaultDispatcherWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultDispatcherQueueSize
+	}
+
+	return &Dispatcher{
+		pool: newChatPool("update", workers, queueSize, func(_ int64, update tgbotapi.Update) {
+			handle(update)
+		}),
+	}
+}
+
+// Dispatch ставит обновление в очередь его чата.
+// Возвращает ErrQueueFull, если очередь переполнена, чтобы вызывающий
+// мог попросить Telegram повторить доставку позже.
+func (d *Dispatcher) Dispatch(update tgbotapi.Update) error {
+	err := d.pool.push(updateChatID(update), update)
+	if err == ErrQueueFull {
+		log.Printf("Update queue is full, rejecting update %d", update.UpdateID)
+	}
+	return err
+}
+
+// Shutdown прекращает прием обновлений и ждет обработки уже принятых
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	return d.pool.shutdown(ctx)
+}
+
+// Stats возвращает текущие метрики диспетчера
+func (d *Dispatcher) Stats() QueueStats {
+	return d.pool.stats()
+}
+
+// updateChatID определяет чат, к которому относится обновление
+func updateChatID(update tgbotapi.Update) int64 {
+	if chat := update.FromChat(); chat != nil {
+		return chat.ID
+	}
+	if user := update.SentFrom(); user != nil {
+		return user.ID
+	}
+	return 0
+}
@@ -0,0 +1,164 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func messageUpdate(updateID int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		Message: &tgbotapi.Message{
+			MessageID: updateID,
+			Chat:      &tgbotapi.Chat{ID: chatID},
+			From:      &tgbotapi.User{ID: chatID},
+		},
+	}
+}
+
+func TestDispatcher_PerChatOrdering(t *testing.T) {
+	var mu sync.Mutex
+	processed := make(map[int64][]int)
+
+	d := NewDispatcher(4, 100, func(update tgbotapi.Update) {
+		// Небольшая задержка увеличивает шанс перестановки при отсутствии упорядочивания
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		processed[update.Message.Chat.ID] = append(processed[update.Message.Chat.ID], update.UpdateID)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, d.Dispatch(messageUpdate(i, 1)))
+		require.NoError(t, d.Dispatch(messageUpdate(100+i, 2)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, d.Shutdown(ctx))
+
+	require.Len(t, processed[1], 20)
+	require.Len(t, processed[2], 20)
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, i, processed[1][i])
+		assert.Equal(t, 100+i, processed[2][i])
+	}
+
+	stats := d.Stats()
+	assert.Equal(t, uint64(40), stats.Accepted)
+	assert.Equal(t, uint64(40), stats.Processed)
+	assert.Equal(t, 0, stats.Pending)
+	assert.Equal(t, 0, stats.ActiveChats)
+}
+
+func TestDispatcher_BoundedConcurrency(t *testing.T) {
+	var running, maxRunning atomic.Int32
+
+	d := NewDispatcher(3, 100, func(update tgbotapi.Update) {
+		current := running.Add(1)
+		for {
+			prev := maxRunning.Load()
+			if current <= prev || maxRunning.CompareAndSwap(prev, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+	})
+
+	for i := 0; i < 30; i++ {
+		require.NoError(t, d.Dispatch(messageUpdate(i, int64(i))))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, d.Shutdown(ctx))
+
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+	assert.Equal(t, uint64(30), d.Stats().Processed)
+}
+
+func TestDispatcher_PanicRecovery(t *testing.T) {
+	var handled atomic.Int32
+
+	d := NewDispatcher(1, 10, func(update tgbotapi.Update) {
+		if update.UpdateID == 1 {
+			panic("boom")
+		}
+		handled.Add(1)
+	})
+
+	require.NoError(t, d.Dispatch(messageUpdate(1, 42)))
+	require.NoError(t, d.Dispatch(messageUpdate(2, 42)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, d.Shutdown(ctx))
+
+	// Следующее обновление того же чата обрабатывается после паники
+	assert.Equal(t, int32(1), handled.Load())
+	assert.Equal(t, uint64(1), d.Stats().Panics)
+	assert.Equal(t, uint64(2), d.Stats().Processed)
+}
+
+func TestDispatcher_Backpressure(t *testing.T) {
+	release := make(chan struct{})
+
+	d := NewDispatcher(1, 2, func(update tgbotapi.Update) {
+		<-release
+	})
+
+	require.NoError(t, d.Dispatch(messageUpdate(1, 1)))
+	require.NoError(t, d.Dispatch(messageUpdate(2, 2)))
+	assert.ErrorIs(t, d.Dispatch(messageUpdate(3, 3)), ErrQueueFull)
+
+	stats := d.Stats()
+	assert.Equal(t, 2, stats.Pending)
+	assert.Equal(t, uint64(1), stats.Rejected)
+
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, d.Shutdown(ctx))
+
+	assert.ErrorIs(t, d.Dispatch(messageUpdate(4, 4)), ErrQueueClosed)
+}
+
+func TestDispatcher_ShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	d := NewDispatcher(1, 10, func(update tgbotapi.Update) {
+		<-release
+	})
+	require.NoError(t, d.Dispatch(messageUpdate(1, 1)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, d.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestUpdateChatID(t *testing.T) {
+	assert.Equal(t, int64(5), updateChatID(messageUpdate(1, 5)))
+
+	callback := tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			From:    &tgbotapi.User{ID: 7},
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 8}},
+		},
+	}
+	assert.Equal(t, int64(8), updateChatID(callback))
+
+	inline := tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{From: &tgbotapi.User{ID: 9}}}
+	assert.Equal(t, int64(9), updateChatID(inline))
+
+	assert.Equal(t, int64(0), updateChatID(tgbotapi.Update{}))
+}
@@ -0,0 +1,120 @@
+package telegram
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const exportUsage = `📤 Выгрузка дневника: /export [csv|json|fhir] [дней]
+
+Например:
+/export — все записи в CSV
+/export json 30 — записи за 30 дней в JSON
+/export fhir 90 — глюкоза и углеводы за 90 дней в FHIR для врача`
+
+// tempFile — файл на диске, отправляемый под именем name.
+// Файл открывается заново при каждой попытке отправки, поэтому повторы безопасны.
+type tempFile struct {
+	name string
+	path string
+}
+
+func (f tempFile) NeedsUpload() bool { return true }
+
+func (f tempFile) UploadData() (string, io.Reader, error) {
+	file, err := os.Open(f.path)
+	return f.name, file, err
+}
+
+func (f tempFile) SendData() string {
+	panic("tempFile must be uploaded")
+}
+
+// tempDocument удаляет временный файл, когда очередь закончила с ним работу
+type tempDocument struct {
+	tgbotapi.DocumentConfig
+	path string
+}
+
+func (d tempDocument) release() {
+	if err := os.Remove(d.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing temp file %s: %v", d.path, err)
+	}
+}
+
+// sendTempDocument отправляет временный файл под именем name и удаляет его после отправки
+func (b *Bot) sendTempDocument(chatID int64, path, name, caption string) {
+	document := tgbotapi.NewDocument(chatID, tempFile{name: name, path: path})
+	document.Caption = caption
+	b.send(chatID, tempDocument{DocumentConfig: document, path: path})
+}
+
+// handleExportCommand отправляет дневник файлом. Выгрузка пишется во временный
+// файл потоком, поэтому размер дневника не влияет на память.
+func (b *Bot) handleExportCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	opts := services.ExportOptions{Format: services.ExportCSV}
+	days := 0
+	for _, arg := range strings.Fields(message.CommandArguments()) {
+		switch format := services.ExportFormat(strings.ToLower(arg)); format {
+		case services.ExportCSV, services.ExportJSON, services.ExportFHIR:
+			opts.Format = format
+		default:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				b.sendMessage(chatID, exportUsage)
+				return
+			}
+			days = n
+		}
+	}
+	now := time.Now()
+	if days > 0 {
+		opts.From = now.AddDate(0, 0, -days)
+	}
+
+	path, err := writeTempFile("diabetbot-export-*."+opts.Format.Extension(), func(w io.Writer) error {
+		return b.exportService.Export(w, user.ID, opts)
+	})
+	if err != nil {
+		log.Printf("Error exporting diary for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось подготовить выгрузку, попробуйте позже.")
+		return
+	}
+
+	period := "за все время"
+	if days > 0 {
+		period = fmt.Sprintf("за %d дн.", days)
+	}
+	b.sendTempDocument(chatID, path, services.ExportFileName(opts.Format, now),
+		fmt.Sprintf("📤 Дневник %s: глюкоза, питание и инсулин", period))
+}
+
+// writeTempFile создает временный файл по шаблону os.CreateTemp, заполняет его write
+// и возвращает путь. При ошибке файл удаляется.
+func writeTempFile(pattern string, write func(w io.Writer) error) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportCommandJSON(telegramID int64, text string) string {
+	return fmt.Sprintf(`{
+		"update_id": 1,
+		"message": {
+			"message_id": 20,
+			"date": 1700000000,
+			"from": {"id": %d, "is_bot": false, "first_name": "Test"},
+			"chat": {"id": %d, "type": "private"},
+			"text": %q,
+			"entities": [{"type": "bot_command", "offset": 0, "length": 7}]
+		}
+	}`, telegramID, telegramID, text)
+}
+
+// exportMessage создает сообщение с командой /export и аргументами
+func exportMessage(telegramID int64, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: telegramID},
+		Chat:      &tgbotapi.Chat{ID: telegramID},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/export")}},
+	}
+}
+
+func TestBot_Webhook_ExportCommand(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+
+	user := testutils.CreateTestUser(testDB.DB, 4040)
+	testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 6.6)
+	testutils.CreateTestFoodRecord(testDB.DB, user.ID, "Сырники", "завтрак")
+
+	handleWebhookJSON(t, bot, exportCommandJSON(4040, "/export json 7"))
+
+	calls := fakeAPI.WaitForCalls(t, "sendDocument", 1)
+	assert.Equal(t, int64(4040), calls[0].ChatID())
+	assert.Contains(t, calls[0].Text(), "за 7 дн.")
+
+	file := calls[0].Files["document"]
+	assert.Equal(t, fmt.Sprintf("diabetbot-%s.json", time.Now().Format("2006-01-02")), file.Name)
+	var export struct {
+		Entries []struct {
+			Type string `json:"type"`
+		} `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(file.Data, &export))
+	require.Len(t, export.Entries, 2)
+	assert.ElementsMatch(t, []string{"glucose", "food"}, []string{export.Entries[0].Type, export.Entries[1].Type})
+}
+
+func TestBot_ExportCommand_FHIR(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+
+	user := testutils.CreateTestUser(testDB.DB, 4043)
+	testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 7.1)
+
+	handleWebhookJSON(t, bot, exportCommandJSON(4043, "/export fhir"))
+
+	calls := fakeAPI.WaitForCalls(t, "sendDocument", 1)
+	file := calls[0].Files["document"]
+	assert.Equal(t, fmt.Sprintf("diabetbot-%s.fhir.json", time.Now().Format("2006-01-02")), file.Name)
+	var bundle struct {
+		ResourceType string `json:"resourceType"`
+		Entry        []struct {
+			Resource struct {
+				ResourceType string `json:"resourceType"`
+			} `json:"resource"`
+		} `json:"entry"`
+	}
+	require.NoError(t, json.Unmarshal(file.Data, &bundle))
+	assert.Equal(t, "Bundle", bundle.ResourceType)
+	require.Len(t, bundle.Entry, 2)
+	assert.Equal(t, "Patient", bundle.Entry[0].Resource.ResourceType)
+	assert.Equal(t, "Observation", bundle.Entry[1].Resource.ResourceType)
+}
+
+func TestBot_ExportCommand_RemovesTempFile(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 4041)
+	testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 5.2)
+
+	before, _ := filepath.Glob(filepath.Join(os.TempDir(), "diabetbot-export-*"))
+	bot.handleExportCommand(exportMessage(4041, "/export"), user)
+
+	sent, ok := mockAPI.GetLastSentMessage().(tempDocument)
+	require.True(t, ok)
+	assert.True(t, strings.HasSuffix(sent.File.(tempFile).name, ".csv"))
+	_, err := os.Stat(sent.path)
+	assert.True(t, os.IsNotExist(err), "временный файл должен быть удален после отправки")
+
+	after, _ := filepath.Glob(filepath.Join(os.TempDir(), "diabetbot-export-*"))
+	assert.Len(t, after, len(before))
+}
+
+func TestBot_ExportCommand_Usage(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 4042)
+	bot.handleExportCommand(exportMessage(4042, "/export xml"), user)
+
+	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Contains(t, sentMsg.Text, "/export [csv|json|fhir] [дней]")
+}
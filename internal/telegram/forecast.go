@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+)
+
+// forecastArrows — стрелка тренда по направлению прогноза
+var forecastArrows = map[string]string{
+	services.ForecastRisingFast:  "⬆",
+	services.ForecastRising:      "↗",
+	services.ForecastFlat:        "→",
+	services.ForecastFalling:     "↘",
+	services.ForecastFallingFast: "⬇",
+}
+
+// forecastText описывает прогноз одной-двумя строками: «📈 Тренд ↗, через час ~9.5 (8.0–11.0), через 2 часа ~10.1 (7.6–12.6)»
+func forecastText(forecast *services.Forecast) string {
+	hour, _ := forecast.Point(60)
+	twoHours, _ := forecast.Point(120)
+	text := fmt.Sprintf("📈 Тренд %s, через час ~%.1f (%.1f–%.1f), через 2 часа ~%.1f (%.1f–%.1f)",
+		forecastArrows[forecast.Direction], hour.Value, hour.Low, hour.High, twoHours.Value, twoHours.Low, twoHours.High)
+
+	var onBoard []string
+	if forecast.CarbsOnBoard > 0 {
+		onBoard = append(onBoard, fmt.Sprintf("%.0f г углеводов", forecast.CarbsOnBoard))
+	}
+	if forecast.InsulinOnBoard > 0 {
+		onBoard = append(onBoard, fmt.Sprintf("%g ед инсулина", forecast.InsulinOnBoard))
+	}
+	if len(onBoard) > 0 {
+		text += "\nУчтено в действии: " + strings.Join(onBoard, " и ")
+	}
+	if hour.Value < services.GlucoseLow || twoHours.Value < services.GlucoseLow {
+		text += fmt.Sprintf("\n⚠️ По прогнозу сахар может опуститься ниже %.1f — перепроверьте его через 15–30 минут.", services.GlucoseLow)
+	}
+	return text
+}
+
+// recordForecast возвращает прогноз после свежего измерения; для записи задним числом
+// или при ошибке — пустую строку
+func (b *Bot) recordForecast(user *models.User, record *models.GlucoseRecord) string {
+	now := time.Now()
+	if now.Sub(record.MeasuredAt) > services.ForecastMaxAge {
+		return ""
+	}
+	forecast, err := b.forecastService.Forecast(user.ID, now)
+	if errors.Is(err, services.ErrNotFound) {
+		return ""
+	}
+	if err != nil {
+		log.Printf("Error forecasting glucose for user %d: %v", user.ID, err)
+		return ""
+	}
+	return forecastText(forecast)
+}
@@ -0,0 +1,34 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBot_GlucoseForecast(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 8400)
+	carbs := 15.0
+	require.NoError(t, testDB.DB.Create(&models.FoodRecord{UserID: user.ID, FoodName: "Каша", Carbs: &carbs, ConsumedAt: time.Now().Add(-time.Minute)}).Error)
+
+	bot.handleTextMessage(&tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 8400}, Text: "7.0"}, user)
+	text := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "Записал: 7.0 ммоль/л")
+	assert.Contains(t, text, "📈 Тренд ↗, через час ~8.0")
+	assert.Contains(t, text, "Учтено в действии: 15 г углеводов")
+
+	// Измерение задним числом прогноз не сопровождает
+	bot.handleTextMessage(&tgbotapi.Message{MessageID: 2, Chat: &tgbotapi.Chat{ID: 8400}, Text: "вчера в 10:00 сахар 6.1"}, user)
+	text = mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "Записал: 6.1 ммоль/л")
+	assert.NotContains(t, text, "Тренд")
+}
@@ -0,0 +1,193 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diabetbot/internal/importer"
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const importUsage = `📥 Импорт измерений глюкозы
+
+Отправьте боту CSV файл выгрузки:
+  • LibreView (FreeStyle Libre)
+  • Dexcom Clarity
+  • CSV глюкометра или дневника с колонками даты, времени и глюкозы
+
+Перед импортом бот покажет, сколько измерений новых, а сколько уже есть в дневнике.`
+
+// fileClient скачивает файлы, отправленные боту
+var fileClient = &http.Client{Timeout: 30 * time.Second}
+
+// importFormatNames — названия форматов для сообщений
+var importFormatNames = map[string]string{
+	importer.FormatLibreView: "LibreView",
+	importer.FormatDexcom:    "Dexcom Clarity",
+	importer.FormatGeneric:   "CSV",
+}
+
+// handleDocument разбирает присланный файл и показывает предпросмотр импорта.
+// Записи сохраняются только после подтверждения кнопкой.
+func (b *Bot) handleDocument(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	document := message.Document
+	if document.FileSize > importer.MaxFileSize {
+		b.sendMessage(chatID, fmt.Sprintf("❌ Файл слишком большой: можно загрузить до %d МБ.", importer.MaxFileSize>>20))
+		return
+	}
+
+	data, err := b.downloadFile(document.FileID)
+	if err != nil {
+		log.Printf("Error downloading file %s for user %d: %v", document.FileID, user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось скачать файл, попробуйте еще раз.")
+		return
+	}
+
+	job, err := b.importService.Run(user.ID, bytes.NewReader(data), services.ImportOptions{
+		Source:   models.ImportSourceTelegram,
+		FileName: document.FileName,
+		FileID:   document.FileID,
+		DryRun:   true,
+	})
+	if err != nil {
+		b.sendImportError(chatID, user, err)
+		return
+	}
+
+	fresh := job.Total - job.Duplicates
+	if fresh == 0 {
+		if err := b.importService.Cancel(user.ID, job.ID); err != nil {
+			log.Printf("Error cancelling import %d: %v", job.ID, err)
+		}
+		b.sendMessage(chatID, importSummary(job)+"\n\nВсе измерения из файла уже есть в дневнике.")
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, importSummary(job)+fmt.Sprintf("\n\nИмпортировать %d измерений?", fresh))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Импортировать", fmt.Sprintf("import_ok_%d", job.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("import_no_%d", job.ID)),
+		),
+	)
+	b.send(chatID, msg)
+}
+
+// handleImportSelection подтверждает или отменяет импорт; action — ok_<id> или no_<id>
+func (b *Bot) handleImportSelection(chatID int64, action string, user *models.User) {
+	decision, id, _ := strings.Cut(action, "_")
+	jobID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || (decision != "ok" && decision != "no") {
+		b.sendMessage(chatID, "Ошибка обработки импорта")
+		return
+	}
+
+	if decision == "no" {
+		if err := b.importService.Cancel(user.ID, uint(jobID)); err != nil {
+			b.sendImportError(chatID, user, err)
+			return
+		}
+		b.sendMessage(chatID, "Импорт отменен.")
+		return
+	}
+
+	job, err := b.importService.GetJob(user.ID, uint(jobID))
+	if err != nil {
+		b.sendImportError(chatID, user, err)
+		return
+	}
+	data, err := b.downloadFile(job.FileID)
+	if err != nil {
+		log.Printf("Error downloading file %s for user %d: %v", job.FileID, user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось скачать файл, отправьте его еще раз.")
+		return
+	}
+	job, err = b.importService.Confirm(user.ID, job.ID, bytes.NewReader(data), services.ImportOptions{})
+	if err != nil {
+		b.sendImportError(chatID, user, err)
+		return
+	}
+
+	text := fmt.Sprintf("✅ Импортировано измерений: %d", job.Imported)
+	if job.Duplicates > 0 {
+		text += fmt.Sprintf("\nПропущено дубликатов: %d", job.Duplicates)
+	}
+	b.sendMessage(chatID, text+"\n\nПосмотреть их можно в /stats и в веб-приложении.")
+}
+
+func (b *Bot) sendImportError(chatID int64, user *models.User, err error) {
+	var verr *services.ValidationError
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		b.sendMessage(chatID, "Импорт не найден, отправьте файл еще раз.")
+	case errors.Is(err, services.ErrImportNotPending):
+		b.sendMessage(chatID, "Этот импорт уже завершен или отменен.")
+	case errors.As(err, &verr) && verr.Rule == "no_readings":
+		b.sendMessage(chatID, "❌ В файле не нашлось измерений глюкозы.\n\n"+importUsage)
+	case errors.As(err, &verr) && verr.Rule == "file_size":
+		b.sendMessage(chatID, fmt.Sprintf("❌ Файл слишком большой: можно загрузить до %d МБ.", importer.MaxFileSize>>20))
+	case errors.As(err, &verr):
+		b.sendMessage(chatID, "❌ Не удалось распознать файл.\n\n"+importUsage)
+	default:
+		log.Printf("Error importing file for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось импортировать файл, попробуйте позже.")
+	}
+}
+
+// importSummary описывает разобранный файл
+func importSummary(job *models.ImportJob) string {
+	unit := "ммоль/л"
+	if job.Unit == string(importer.UnitMgDL) {
+		unit = "мг/дл"
+	}
+	text := fmt.Sprintf("📥 Файл %s: %s, %s\n\nИзмерений: %d", job.FileName, importFormatNames[job.Format], unit, job.Total)
+	if job.FirstAt != nil && job.LastAt != nil {
+		text += fmt.Sprintf(" (%s — %s)", job.FirstAt.Local().Format("02.01.2006"), job.LastAt.Local().Format("02.01.2006"))
+	}
+	text += fmt.Sprintf("\nНовых: %d\nУже есть в дневнике: %d", job.Total-job.Duplicates, job.Duplicates)
+	if job.Skipped > 0 {
+		text += fmt.Sprintf("\nНе удалось разобрать строк: %d", job.Skipped)
+	}
+	return text
+}
+
+// downloadFile скачивает файл, отправленный боту. Адрес файлов строится из адреса
+// Bot API, поэтому работает и с локальным сервером Bot API.
+func (b *Bot) downloadFile(fileID string) ([]byte, error) {
+	resp, err := b.api.Request(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+	var file tgbotapi.File
+	if err := json.Unmarshal(resp.Result, &file); err != nil {
+		return nil, err
+	}
+
+	endpoint := b.config.APIEndpoint
+	if endpoint == "" {
+		endpoint = tgbotapi.APIEndpoint
+	}
+	url := fmt.Sprintf(strings.Replace(endpoint, "/bot%s/%s", "/file/bot%s/%s", 1), b.config.BotToken, file.FilePath)
+
+	httpResp, err := fileClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file: unexpected status %s", httpResp.Status)
+	}
+	return io.ReadAll(io.LimitReader(httpResp.Body, importer.MaxFileSize+1))
+}
@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"fmt"
+	"testing"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const importTestCSV = "Date Time,Glucose mg/dL\n" +
+	"2024-02-14 07:30,101\n" +
+	"2024-02-14 13:00,160\n" +
+	"2024-02-14 19:00,130\n"
+
+func documentJSON(telegramID int64, fileID, fileName string) string {
+	return fmt.Sprintf(`{
+		"update_id": 7,
+		"message": {
+			"message_id": 40,
+			"date": 1700000000,
+			"from": {"id": %d, "is_bot": false, "first_name": "Test"},
+			"chat": {"id": %d, "type": "private"},
+			"document": {"file_id": %q, "file_unique_id": "u-%s", "file_name": %q, "mime_type": "text/csv", "file_size": 100}
+		}
+	}`, telegramID, telegramID, fileID, fileID, fileName)
+}
+
+func importCallbackJSON(telegramID int64, data string) string {
+	return fmt.Sprintf(`{
+		"update_id": 8,
+		"callback_query": {
+			"id": "cb-import",
+			"from": {"id": %d, "is_bot": false, "first_name": "Test"},
+			"message": {"message_id": 41, "date": 1700000000, "chat": {"id": %d, "type": "private"}, "text": "📥 Файл"},
+			"data": %q
+		}
+	}`, telegramID, telegramID, data)
+}
+
+func TestBot_Webhook_ImportDocument(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+	user := testutils.CreateTestUser(testDB.DB, 6060)
+	fakeAPI.AddFile("csv-1", "meter.csv", []byte(importTestCSV))
+
+	handleWebhookJSON(t, bot, documentJSON(6060, "csv-1", "meter.csv"))
+
+	preview := fakeAPI.WaitForCalls(t, "sendMessage", 1)[0]
+	assert.Contains(t, preview.Text(), "📥 Файл meter.csv: CSV, мг/дл")
+	assert.Contains(t, preview.Text(), "Новых: 3")
+	markup := preview.ReplyMarkup()
+	require.NotNil(t, markup)
+	confirm := *markup.InlineKeyboard[0][0].CallbackData
+	assert.Regexp(t, `^import_ok_\d+$`, confirm)
+
+	// До подтверждения записи не сохраняются
+	var count int64
+	testDB.DB.Model(&models.GlucoseRecord{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Zero(t, count)
+
+	handleWebhookJSON(t, bot, importCallbackJSON(6060, confirm))
+
+	done := fakeAPI.WaitForCalls(t, "sendMessage", 2)[1]
+	assert.Contains(t, done.Text(), "Импортировано измерений: 3")
+	testDB.DB.Model(&models.GlucoseRecord{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	var job models.ImportJob
+	require.NoError(t, testDB.DB.Where("user_id = ?", user.ID).First(&job).Error)
+	assert.Equal(t, models.ImportStatusCompleted, job.Status)
+	assert.Equal(t, models.ImportSourceTelegram, job.Source)
+	assert.Equal(t, 3, job.Imported)
+
+	// Повторное нажатие ничего не импортирует
+	handleWebhookJSON(t, bot, importCallbackJSON(6060, confirm))
+	again := fakeAPI.WaitForCalls(t, "sendMessage", 3)[2]
+	assert.Contains(t, again.Text(), "уже завершен")
+}
+
+func TestBot_Webhook_ImportCancel(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+	user := testutils.CreateTestUser(testDB.DB, 6061)
+	fakeAPI.AddFile("csv-2", "meter.csv", []byte(importTestCSV))
+
+	handleWebhookJSON(t, bot, documentJSON(6061, "csv-2", "meter.csv"))
+	markup := fakeAPI.WaitForCalls(t, "sendMessage", 1)[0].ReplyMarkup()
+	require.NotNil(t, markup)
+
+	handleWebhookJSON(t, bot, importCallbackJSON(6061, *markup.InlineKeyboard[0][1].CallbackData))
+
+	assert.Contains(t, fakeAPI.WaitForCalls(t, "sendMessage", 2)[1].Text(), "Импорт отменен")
+	var count int64
+	testDB.DB.Model(&models.GlucoseRecord{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestBot_Webhook_ImportUnknownFile(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+	testutils.CreateTestUser(testDB.DB, 6062)
+	fakeAPI.AddFile("txt-1", "notes.txt", []byte("просто заметки\nбез таблицы\n"))
+
+	handleWebhookJSON(t, bot, documentJSON(6062, "txt-1", "notes.txt"))
+
+	text := fakeAPI.WaitForCalls(t, "sendMessage", 1)[0].Text()
+	assert.Contains(t, text, "Не удалось распознать файл")
+	assert.Contains(t, text, "Dexcom Clarity")
+	assert.Len(t, fakeAPI.CallsTo("getFile"), 1)
+}
@@ -0,0 +1,71 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Период /insights в днях
+const maxInsightCommandDays = 90
+
+// insightEvidenceShown — сколько измерений показывать под каждой закономерностью
+const insightEvidenceShown = 4
+
+const insightsUsage = `🔎 Поиск закономерностей в дневнике
+/insights — за последние 30 дней
+/insights 60 — за 60 дней (до 90)`
+
+// handleInsightsCommand ищет закономерности в истории глюкозы: /insights [дней]
+func (b *Bot) handleInsightsCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	days := services.DefaultInsightDays
+	if arg := strings.TrimSpace(message.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxInsightCommandDays {
+			b.sendMessage(chatID, insightsUsage)
+			return
+		}
+		days = n
+	}
+
+	now := time.Now()
+	report, err := b.insightService.Analyze(user.ID, services.InsightOptions{From: now.AddDate(0, 0, -days), To: now})
+	if err != nil {
+		log.Printf("Error analyzing insights for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось проанализировать дневник, попробуйте позже.")
+		return
+	}
+	b.sendMessage(chatID, insightsText(report, days))
+}
+
+func insightsText(report *services.InsightReport, days int) string {
+	if len(report.Insights) == 0 {
+		return fmt.Sprintf("🔎 За %d дн. закономерностей не нашлось (измерений: %d).\n\nЧем регулярнее измерения — натощак, ночью и после еды, — тем точнее поиск.",
+			days, report.Readings)
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "🔎 Закономерности за %d дн. (измерений: %d)\n", days, report.Readings)
+	for i, insight := range report.Insights {
+		fmt.Fprintf(&text, "\n%d. %s — уверенность %.0f%%\n%s\n", i+1, insight.Title, insight.Confidence*100, insight.Description)
+		evidence := insight.Evidence
+		if len(evidence) > insightEvidenceShown {
+			evidence = evidence[len(evidence)-insightEvidenceShown:]
+		}
+		values := make([]string, 0, len(evidence))
+		for _, e := range evidence {
+			values = append(values, fmt.Sprintf("%s — %.1f", e.MeasuredAt.Local().Format("02.01 15:04"), e.Value))
+		}
+		text.WriteString("Например: " + strings.Join(values, "; ") + "\n")
+	}
+	text.WriteString("\nЭто подсказки по правилам, а не диагноз. Изменения лечения обсуждайте с врачом. Закономерности учитываются в ответах ИИ.")
+	return text.String()
+}
@@ -0,0 +1,37 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBot_InsightsCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 8300)
+	bot.handleCommand(commandMessage(8300, "/insights", "/insights"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "закономерностей не нашлось")
+
+	// Гипогликемии в 03:00 две ночи подряд
+	for d := 1; d <= 2; d++ {
+		night := time.Now().AddDate(0, 0, -d)
+		night = time.Date(night.Year(), night.Month(), night.Day(), 3, 0, 0, 0, time.Local)
+		require.NoError(t, testDB.DB.Create(&models.GlucoseRecord{UserID: user.ID, Value: 3.3, MeasuredAt: night}).Error)
+	}
+	bot.handleCommand(commandMessage(8300, "/insights", "/insights 7"), user)
+	text := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "Закономерности за 7 дн. (измерений: 2)")
+	assert.Contains(t, text, "1. Ночные гипогликемии — уверенность 50%")
+	assert.Contains(t, text, "03:00 — 3.3")
+
+	bot.handleCommand(commandMessage(8300, "/insights", "/insights 400"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "/insights 60")
+}
@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/parser"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// labsShown — сколько последних анализов показывает /labs
+const labsShown = 10
+
+const labsUsage = `🧪 Анализы записываются обычным сообщением:
+«HbA1c 6.8%», «гликированный 53 ммоль/моль»
+«холестерин 5.2», «креатинин 84 мкмоль/л»
+Можно указать дату: «вчера HbA1c 7.1%»`
+
+var labKindNames = map[string]string{
+	models.LabKindHbA1c:       "HbA1c",
+	models.LabKindCholesterol: "Холестерин",
+	models.LabKindCreatinine:  "Креатинин",
+}
+
+var labUnitNames = map[string]string{
+	models.LabUnitPercent:   "%",
+	models.LabUnitMmolMol:   " ммоль/моль",
+	models.LabUnitMmolL:     " ммоль/л",
+	models.LabUnitMgDL:      " мг/дл",
+	models.LabUnitMicromolL: " мкмоль/л",
+}
+
+// labResultText — «HbA1c 6.8%», «Калий 4.2 ммоль/л»
+func labResultText(result *models.LabResult) string {
+	name := labKindNames[result.Kind]
+	if name == "" {
+		name = result.Name
+	}
+	unit, ok := labUnitNames[result.Unit]
+	if !ok {
+		unit = " " + result.Unit
+	}
+	return fmt.Sprintf("%s %g%s", name, result.Value, unit)
+}
+
+func (b *Bot) recordLab(chatID int64, user *models.User, lab *parser.Lab, result parser.Result) {
+	record, err := b.labService.Create(user.ID, services.LabInput{
+		Kind:    lab.Kind,
+		Value:   lab.Value,
+		Unit:    lab.Unit,
+		TakenAt: result.Time,
+	})
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		b.sendMessage(chatID, "⚠️ Значение анализа вне допустимого диапазона. Проверьте число и единицы.\n\n"+labsUsage)
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving lab result: %v", err)
+		b.sendMessage(chatID, "Ошибка сохранения данных")
+		return
+	}
+
+	response := "🧪 Записал анализ: " + labResultText(record) + recordTimeLine(result)
+	if record.Kind == models.LabKindHbA1c {
+		comparison, err := b.labService.CompareResult(user.ID, *record)
+		if err != nil {
+			log.Printf("Error comparing HbA1c for user %d: %v", user.ID, err)
+		} else {
+			response += "\n\n" + comparison.Summary()
+		}
+	}
+	b.sendMessage(chatID, response)
+}
+
+// handleLabsCommand показывает последние анализы и сравнение HbA1c с дневником
+func (b *Bot) handleLabsCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	page, err := b.labService.List(user.ID, services.ListOptions{Limit: labsShown}, "")
+	if err != nil {
+		log.Printf("Error listing lab results for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось загрузить анализы, попробуйте позже.")
+		return
+	}
+	if len(page.Items) == 0 {
+		b.sendMessage(chatID, "🧪 Анализов пока нет.\n\n"+labsUsage)
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString("🧪 Последние анализы:\n")
+	for i := range page.Items {
+		result := &page.Items[i]
+		fmt.Fprintf(&text, "%s — %s\n", result.TakenAt.Local().Format("02.01.2006"), labResultText(result))
+	}
+
+	comparison, err := b.labService.Latest(user.ID)
+	if err != nil {
+		log.Printf("Error comparing HbA1c for user %d: %v", user.ID, err)
+	} else if comparison != nil {
+		text.WriteString("\n" + comparison.Summary() + "\n")
+	}
+	text.WriteString("\n" + labsUsage)
+	b.sendMessage(chatID, text.String())
+}
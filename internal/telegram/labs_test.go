@@ -0,0 +1,41 @@
+package telegram
+
+import (
+	"testing"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBot_LabEntryAndCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 8400)
+	bot.handleCommand(commandMessage(8400, "/labs", "/labs"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "Анализов пока нет")
+
+	bot.handleTextMessage(&tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 8400}, Text: "HbA1c 6.8%"}, user)
+	text := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "Записал анализ: HbA1c 6.8%")
+	assert.Contains(t, text, "GMI не рассчитан")
+
+	page, err := bot.labService.List(user.ID, services.ListOptions{}, models.LabKindHbA1c)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, models.LabUnitPercent, page.Items[0].Unit)
+
+	bot.handleTextMessage(&tgbotapi.Message{MessageID: 2, Chat: &tgbotapi.Chat{ID: 8400}, Text: "холестерин 5.2"}, user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "Холестерин 5.2 ммоль/л")
+
+	bot.handleCommand(commandMessage(8400, "/labs", "/labs"), user)
+	text = mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "Последние анализы")
+	assert.Contains(t, text, "Холестерин 5.2 ммоль/л")
+	assert.Contains(t, text, "HbA1c 6.8%")
+}
@@ -0,0 +1,50 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"diabetbot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleNightscoutCommand выдает новый API secret для xDrip+, AAPS и приложений,
+// читающих Nightscout; /nightscout off отключает доступ
+func (b *Bot) handleNightscoutCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	if strings.EqualFold(strings.TrimSpace(message.CommandArguments()), "off") {
+		if err := b.nightscoutService.DisableAPI(user.ID); err != nil {
+			log.Printf("Error disabling Nightscout API for user %d: %v", user.ID, err)
+			b.sendMessage(chatID, "❌ Не удалось отключить доступ, попробуйте позже.")
+			return
+		}
+		b.sendMessage(chatID, "🔒 Доступ по Nightscout API отключен.")
+		return
+	}
+
+	secret, err := b.nightscoutService.EnableAPI(user.ID)
+	if err != nil {
+		log.Printf("Error enabling Nightscout API for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось создать API secret, попробуйте позже.")
+		return
+	}
+	b.sendMessage(chatID, nightscoutInstructions(b.config.WebAppURL, secret))
+}
+
+// nightscoutInstructions описывает подключение xDrip+ и AAPS; baseURL — адрес сервера
+func nightscoutInstructions(baseURL, secret string) string {
+	text := "🔗 Nightscout API включен\n\n"
+	if u, err := url.Parse(strings.TrimSuffix(baseURL, "/")); err == nil && u.Host != "" {
+		site := u.String()
+		u.User = url.User(secret)
+		u.Path += "/api/v1/"
+		text += fmt.Sprintf("xDrip+: Настройки → Загрузка в облако → Nightscout Sync (REST-API), Base URL:\n%s\n\n", u.String())
+		text += fmt.Sprintf("AAPS и другие приложения:\nАдрес: %s\nAPI secret: %s", site, secret)
+	} else {
+		text += fmt.Sprintf("API secret: %s\nАдрес сервера уточните у администратора бота.", secret)
+	}
+	return text + "\n\nПрежний секрет больше не действует. Не пересылайте это сообщение: по секрету можно читать и изменять ваш дневник.\n/nightscout off — отключить доступ"
+}
@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"regexp"
+	"testing"
+
+	"diabetbot/internal/repository"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nightscoutMessage(telegramID int64, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: telegramID},
+		Chat:      &tgbotapi.Chat{ID: telegramID},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/nightscout")}},
+	}
+}
+
+func TestBot_NightscoutCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	bot.config.WebAppURL = "https://diabet.example.com"
+
+	user := testutils.CreateTestUser(testDB.DB, 7070)
+	bot.handleNightscoutCommand(nightscoutMessage(7070, "/nightscout"), user)
+
+	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Regexp(t, `https://[0-9a-f]{24}@diabet\.example\.com/api/v1/`, sentMsg.Text)
+	assert.Contains(t, sentMsg.Text, "Адрес: https://diabet.example.com\n")
+
+	match := regexp.MustCompile(`API secret: ([0-9a-f]{24})`).FindStringSubmatch(sentMsg.Text)
+	require.Len(t, match, 2)
+	secret := match[1]
+	nightscout := services.New(repository.NewGorm(testDB.DB)).Nightscout
+	found, err := nightscout.Authenticate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	bot.handleNightscoutCommand(nightscoutMessage(7070, "/nightscout off"), user)
+	sentMsg, ok = mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Contains(t, sentMsg.Text, "отключен")
+	_, err = nightscout.Authenticate(secret)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+}
+
+func TestNightscoutInstructions_WithoutURL(t *testing.T) {
+	text := nightscoutInstructions("", "0123456789abcdef01234567")
+	assert.Contains(t, text, "API secret: 0123456789abcdef01234567")
+	assert.NotContains(t, text, "Base URL")
+}
@@ -0,0 +1,262 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	TelegramGlobalRate = 30 // Лимит Telegram: сообщений в секунду на бота
+	TelegramChatRate   = 1  // Лимит Telegram: сообщений в секунду в один чат
+
+	DefaultOutboxWorkers    = 4
+	DefaultOutboxQueueSize  = 1000
+	DefaultOutboxMaxRetries = 3
+)
+
+// OutboxConfig задает параметры очереди исходящих сообщений
+type OutboxConfig struct {
+	GlobalRate float64       // сообщений в секунду на бота
+	ChatRate   float64       // сообщений в секунду в один чат
+	Workers    int           // количество отправляющих воркеров
+	QueueSize  int           // максимум сообщений в очереди
+	MaxRetries int           // повторы при сетевых ошибках и 5xx
+	RetryDelay time.Duration // начальная задержка между повторами
+}
+
+// DefaultOutboxConfig возвращает настройки, соответствующие лимитам Telegram
+func DefaultOutboxConfig() OutboxConfig {
+	return OutboxConfig{
+		GlobalRate: TelegramGlobalRate,
+		ChatRate:   TelegramChatRate,
+		Workers:    DefaultOutboxWorkers,
+		QueueSize:  DefaultOutboxQueueSize,
+		MaxRetries: DefaultOutboxMaxRetries,
+		RetryDelay: time.Second,
+	}
+}
+
+// OutboxStats содержит метрики очереди исходящих сообщений
+type OutboxStats struct {
+	QueueStats
+	Sent      uint64 `json:"sent"`
+	Failed    uint64 `json:"failed"`
+	Retried   uint64 `json:"retried"`
+	Throttled uint64 `json:"throttled"`
+	Blocked   uint64 `json:"blocked"`
+}
+
+// Outbox отправляет сообщения с соблюдением лимитов Telegram.
+// Сообщения одного чата уходят в порядке постановки в очередь.
+type Outbox struct {
+	api       botAPI
+	cfg       OutboxConfig
+	onBlocked func(chatID int64)
+	pool      *chatPool[tgbotapi.Chattable]
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	global    *tokenBucket
+	mu        sync.Mutex
+	chats     map[int64]*tokenBucket
+	lastPrune time.Time
+
+	sent      atomic.Uint64
+	failed    atomic.Uint64
+	retried   atomic.Uint64
+	throttled atomic.Uint64
+	blocked   atomic.Uint64
+}
+
+// NewOutbox создает очередь исходящих сообщений.
+// onBlocked вызывается, когда пользователь заблокировал бота.
+func NewOutbox(api botAPI, cfg OutboxConfig, onBlocked func(chatID int64)) *Outbox {
+	defaults := DefaultOutboxConfig()
+	if cfg.GlobalRate <= 0 {
+		cfg.GlobalRate = defaults.GlobalRate
+	}
+	if cfg.ChatRate <= 0 {
+		cfg.ChatRate = defaults.ChatRate
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaults.Workers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaults.QueueSize
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaults.RetryDelay
+	}
+
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	o := &Outbox{
+		api:       api,
+		cfg:       cfg,
+		onBlocked: onBlocked,
+		ctx:       ctx,
+		cancel:    cancel,
+		global:    newTokenBucket(cfg.GlobalRate, max(1, int(cfg.GlobalRate)), now),
+		chats:     make(map[int64]*tokenBucket),
+		lastPrune: now,
+	}
+	o.pool = newChatPool("outbox", cfg.Workers, cfg.QueueSize, o.deliver)
+
+	return o
+}
+
+// releaser — сообщение с ресурсами (например, временным файлом),
+// которые нужно освободить после отправки или отказа от нее
+type releaser interface {
+	release()
+}
+
+func release(c tgbotapi.Chattable) {
+	if r, ok := c.(releaser); ok {
+		r.release()
+	}
+}
+
+// Send ставит сообщение в очередь отправки
+func (o *Outbox) Send(chatID int64, c tgbotapi.Chattable) error {
+	err := o.pool.push(chatID, c)
+	if err != nil {
+		release(c)
+		log.Printf("Outbox rejected message for chat %d: %v", chatID, err)
+	}
+	return err
+}
+
+// Shutdown ждет отправки сообщений из очереди.
+// Если контекст истекает раньше, ожидающие отправки сообщения отбрасываются.
+func (o *Outbox) Shutdown(ctx context.Context) error {
+	err := o.pool.shutdown(ctx)
+	o.cancel()
+	return err
+}
+
+// Stats возвращает текущие метрики очереди
+func (o *Outbox) Stats() OutboxStats {
+	return OutboxStats{
+		QueueStats: o.pool.stats(),
+		Sent:       o.sent.Load(),
+		Failed:     o.failed.Load(),
+		Retried:    o.retried.Load(),
+		Throttled:  o.throttled.Load(),
+		Blocked:    o.blocked.Load(),
+	}
+}
+
+// deliver отправляет одно сообщение, повторяя попытки при временных ошибках
+func (o *Outbox) deliver(chatID int64, c tgbotapi.Chattable) {
+	defer release(c)
+
+	retries := 0
+	for {
+		if err := o.waitForSlot(chatID); err != nil {
+			o.failed.Add(1)
+			log.Printf("Dropping message for chat %d: %v", chatID, err)
+			return
+		}
+
+		_, err := o.api.Send(c)
+		if err == nil {
+			o.sent.Add(1)
+			return
+		}
+
+		var delay time.Duration
+		var apiErr *tgbotapi.Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.RetryAfter > 0:
+			// 429: Telegram сообщает, сколько секунд нужно подождать
+			o.throttled.Add(1)
+			delay = time.Duration(apiErr.RetryAfter) * time.Second
+			log.Printf("Telegram rate limit hit for chat %d, retrying after %s", chatID, delay)
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden:
+			// Бот заблокирован пользователем или удален из чата
+			o.blocked.Add(1)
+			log.Printf("Bot is blocked in chat %d: %s", chatID, apiErr.Message)
+			if o.onBlocked != nil {
+				o.onBlocked(chatID)
+			}
+			return
+		case errors.As(err, &apiErr) && apiErr.Code >= 400 && apiErr.Code < 500:
+			// Остальные ошибки клиента повтор не исправит
+			o.failed.Add(1)
+			log.Printf("Error sending message to chat %d: %v", chatID, err)
+			return
+		default:
+			if retries >= o.cfg.MaxRetries {
+				o.failed.Add(1)
+				log.Printf("Error sending message to chat %d after %d retries: %v", chatID, retries, err)
+				return
+			}
+			delay = o.cfg.RetryDelay << retries
+			retries++
+			log.Printf("Error sending message to chat %d, retry %d in %s: %v", chatID, retries, delay, err)
+		}
+
+		o.retried.Add(1)
+		if err := o.sleep(delay); err != nil {
+			o.failed.Add(1)
+			log.Printf("Dropping message for chat %d: %v", chatID, err)
+			return
+		}
+	}
+}
+
+// waitForSlot ждет, пока отправка не нарушит лимиты чата и бота
+func (o *Outbox) waitForSlot(chatID int64) error {
+	if err := o.sleep(o.chatBucket(chatID).reserve(time.Now())); err != nil {
+		return err
+	}
+	return o.sleep(o.global.reserve(time.Now()))
+}
+
+// chatBucket возвращает ограничитель чата, периодически удаляя неиспользуемые
+func (o *Outbox) chatBucket(chatID int64) *tokenBucket {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(o.lastPrune) > time.Minute {
+		for id, bucket := range o.chats {
+			if bucket.idle(now) {
+				delete(o.chats, id)
+			}
+		}
+		o.lastPrune = now
+	}
+
+	bucket, ok := o.chats[chatID]
+	if !ok {
+		bucket = newTokenBucket(o.cfg.ChatRate, 1, now)
+		o.chats[chatID] = bucket
+	}
+	return bucket
+}
+
+func (o *Outbox) sleep(d time.Duration) error {
+	if d <= 0 {
+		return o.ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-o.ctx.Done():
+		return o.ctx.Err()
+	}
+}
@@ -0,0 +1,163 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"diabetbot/internal/repository"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shutdownOutbox(t *testing.T, o *Outbox) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, o.Shutdown(ctx))
+}
+
+func TestOutbox_PerChatRateLimit(t *testing.T) {
+	server := testutils.NewFakeBotAPI(t)
+	outbox := NewOutbox(server.NewBotAPI(t), OutboxConfig{GlobalRate: 1000, ChatRate: 10}, nil)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, outbox.Send(1, tgbotapi.NewMessage(1, fmt.Sprintf("msg %d", i))))
+	}
+	shutdownOutbox(t, outbox)
+
+	calls := server.CallsTo("sendMessage")
+	require.Len(t, calls, 3)
+	for i, call := range calls {
+		assert.Equal(t, fmt.Sprintf("msg %d", i), call.Text())
+	}
+	// При 10 сообщениях в секунду между отправками в один чат не меньше ~100 мс
+	assert.GreaterOrEqual(t, calls[2].At.Sub(calls[0].At), 180*time.Millisecond)
+	assert.Equal(t, uint64(3), outbox.Stats().Sent)
+}
+
+func TestOutbox_GlobalRateLimit(t *testing.T) {
+	server := testutils.NewFakeBotAPI(t)
+	outbox := NewOutbox(server.NewBotAPI(t), OutboxConfig{GlobalRate: 10, ChatRate: 1000, Workers: 4}, nil)
+
+	// Burst глобального лимита равен 10 сообщениям, остальные 5 ждут ~0.5 с
+	for i := 0; i < 15; i++ {
+		require.NoError(t, outbox.Send(int64(i), tgbotapi.NewMessage(int64(i), "broadcast")))
+	}
+	start := time.Now()
+	shutdownOutbox(t, outbox)
+
+	assert.Len(t, server.CallsTo("sendMessage"), 15)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestOutbox_RetryAfter(t *testing.T) {
+	server := testutils.NewFakeBotAPI(t)
+	server.QueueResponse("sendMessage", `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`)
+	outbox := NewOutbox(server.NewBotAPI(t), OutboxConfig{GlobalRate: 1000, ChatRate: 1000}, nil)
+
+	require.NoError(t, outbox.Send(1, tgbotapi.NewMessage(1, "hello")))
+	shutdownOutbox(t, outbox)
+
+	calls := server.CallsTo("sendMessage")
+	require.Len(t, calls, 2)
+	assert.GreaterOrEqual(t, calls[1].At.Sub(calls[0].At), time.Second)
+
+	stats := outbox.Stats()
+	assert.Equal(t, uint64(1), stats.Sent)
+	assert.Equal(t, uint64(1), stats.Throttled)
+	assert.Equal(t, uint64(1), stats.Retried)
+}
+
+func TestOutbox_ServerErrorRetries(t *testing.T) {
+	failure := `{"ok":false,"error_code":502,"description":"Bad Gateway"}`
+	server := testutils.NewFakeBotAPI(t)
+	server.QueueResponse("sendMessage", failure)
+	server.QueueResponse("sendMessage", failure)
+	server.QueueResponse("sendMessage", failure)
+	outbox := NewOutbox(server.NewBotAPI(t), OutboxConfig{
+		GlobalRate: 1000,
+		ChatRate:   1000,
+		MaxRetries: 2,
+		RetryDelay: 10 * time.Millisecond,
+	}, nil)
+
+	require.NoError(t, outbox.Send(1, tgbotapi.NewMessage(1, "hello")))
+	shutdownOutbox(t, outbox)
+
+	// Первая попытка и два повтора, после чего сообщение отбрасывается
+	assert.Len(t, server.CallsTo("sendMessage"), 3)
+	stats := outbox.Stats()
+	assert.Equal(t, uint64(0), stats.Sent)
+	assert.Equal(t, uint64(1), stats.Failed)
+	assert.Equal(t, uint64(2), stats.Retried)
+}
+
+func TestOutbox_BadRequestIsNotRetried(t *testing.T) {
+	server := testutils.NewFakeBotAPI(t)
+	server.QueueResponse("sendMessage", `{"ok":false,"error_code":400,"description":"Bad Request: message text is empty"}`)
+	outbox := NewOutbox(server.NewBotAPI(t), OutboxConfig{GlobalRate: 1000, ChatRate: 1000}, nil)
+
+	require.NoError(t, outbox.Send(1, tgbotapi.NewMessage(1, "")))
+	shutdownOutbox(t, outbox)
+
+	assert.Len(t, server.CallsTo("sendMessage"), 1)
+	assert.Equal(t, uint64(1), outbox.Stats().Failed)
+}
+
+func TestOutbox_BlockedUserIsDeactivated(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(db)
+	user := testutils.CreateTestUser(db, 555)
+	userService := services.NewUserService(repository.NewGormUserRepository(db))
+
+	server := testutils.NewFakeBotAPI(t)
+	server.QueueResponse("sendMessage", `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`)
+	bot := &Bot{userService: userService}
+	outbox := NewOutbox(server.NewBotAPI(t), OutboxConfig{GlobalRate: 1000, ChatRate: 1000}, bot.handleBlocked)
+
+	require.NoError(t, outbox.Send(555, tgbotapi.NewMessage(555, "reminder")))
+	shutdownOutbox(t, outbox)
+
+	assert.Len(t, server.CallsTo("sendMessage"), 1)
+	assert.Equal(t, uint64(1), outbox.Stats().Blocked)
+
+	updated, err := userService.GetByTelegramID(555)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, updated.ID)
+	assert.False(t, updated.IsActive)
+}
+
+func TestOutbox_QueueFull(t *testing.T) {
+	server := testutils.NewFakeBotAPI(t)
+	outbox := NewOutbox(server.NewBotAPI(t), OutboxConfig{GlobalRate: 1000, ChatRate: 1, Workers: 1, QueueSize: 2}, nil)
+
+	require.NoError(t, outbox.Send(1, tgbotapi.NewMessage(1, "first")))
+	require.NoError(t, outbox.Send(1, tgbotapi.NewMessage(1, "second")))
+	assert.ErrorIs(t, outbox.Send(1, tgbotapi.NewMessage(1, "third")), ErrQueueFull)
+
+	shutdownOutbox(t, outbox)
+	assert.Len(t, server.CallsTo("sendMessage"), 2)
+}
+
+func TestOutbox_SendDocument(t *testing.T) {
+	server := testutils.NewFakeBotAPI(t)
+	outbox := NewOutbox(server.NewBotAPI(t), OutboxConfig{GlobalRate: 1000, ChatRate: 1000}, nil)
+
+	document := tgbotapi.NewDocument(1, tgbotapi.FileBytes{Name: "diary.csv", Bytes: []byte("date,value\n")})
+	document.Caption = "Экспорт"
+	require.NoError(t, outbox.Send(1, document))
+	shutdownOutbox(t, outbox)
+
+	calls := server.CallsTo("sendDocument")
+	require.Len(t, calls, 1)
+	assert.Equal(t, int64(1), calls[0].ChatID())
+	assert.Equal(t, "Экспорт", calls[0].Text())
+	assert.Equal(t, "diary.csv", calls[0].Files["document"].Name)
+	assert.Equal(t, "date,value\n", string(calls[0].Files["document"].Data))
+	assert.Equal(t, uint64(1), outbox.Stats().Sent)
+}
@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const linkUsage = `🌐 Ссылка на сводку дневника для врача без Telegram
+
+/link — ссылка на сводку за 14 дней
+/link 30 — за 30 дней (до 90)
+/link 30 Эндокринолог — с пометкой, для кого ссылка
+/links — ваши ссылки, число открытий и отзыв
+
+По ссылке открывается страница со статистикой, графиком и дневником питания. Ссылка действует 7 дней, каждое открытие записывается.`
+
+// handleLinkCommand создает публичную ссылку на сводку: /link [дней] [для кого]
+func (b *Bot) handleLinkCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	args := strings.Fields(message.CommandArguments())
+	days := services.DefaultPublicLinkDays
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > services.MaxPublicLinkDays {
+			b.sendMessage(chatID, linkUsage)
+			return
+		}
+		days = n
+		args = args[1:]
+	}
+
+	now := time.Now()
+	link, err := b.linkService.Create(user.ID, services.PublicLinkOptions{
+		Label: strings.Join(args, " "),
+		From:  now.AddDate(0, 0, -days),
+		To:    now,
+	})
+	var verr *services.ValidationError
+	switch {
+	case errors.As(err, &verr) && verr.Rule == "max_links":
+		b.sendMessage(chatID, fmt.Sprintf("❌ У вас уже %d действующих ссылок. Отзовите ненужные в /links.", services.MaxPublicLinks))
+		return
+	case errors.As(err, &verr):
+		b.sendMessage(chatID, linkUsage)
+		return
+	case err != nil:
+		log.Printf("Error creating public link for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось создать ссылку, попробуйте позже.")
+		return
+	}
+
+	text := fmt.Sprintf("🌐 Сводка дневника за %d дн.\n\n", days)
+	if base := strings.TrimSuffix(b.config.WebAppURL, "/"); base != "" {
+		text += "Отправьте ссылку врачу:\n" + base + link.Path
+	} else {
+		text += fmt.Sprintf("Путь на сервере бота: %s\nАдрес сервера уточните у администратора бота.", link.Path)
+	}
+	text += fmt.Sprintf("\n\nСсылка действует до %s. Любой, у кого она есть, увидит сводку — не публикуйте ее. Отозвать: /links",
+		link.ExpiresAt.Local().Format("02.01.2006 15:04"))
+	b.sendMessage(chatID, text)
+}
+
+// handleLinksCommand показывает ссылки пользователя с числом открытий и кнопками отзыва
+func (b *Bot) handleLinksCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	links, err := b.linkService.List(user.ID)
+	if err != nil {
+		log.Printf("Error listing public links for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось получить список ссылок, попробуйте позже.")
+		return
+	}
+	if len(links) == 0 {
+		b.sendMessage(chatID, linkUsage)
+		return
+	}
+
+	now := time.Now()
+	var text strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	text.WriteString("🌐 Ваши ссылки на сводку:\n")
+	for i, link := range links {
+		name := link.Label
+		if name == "" {
+			name = fmt.Sprintf("Ссылка %d", i+1)
+		}
+		status := "до " + link.ExpiresAt.Local().Format("02.01.2006")
+		if !now.Before(link.ExpiresAt) {
+			status = "истекла"
+		}
+		fmt.Fprintf(&text, "• %s: %s – %s, %s, открыта %d раз", name,
+			link.PeriodFrom.Local().Format("02.01"), link.PeriodTo.Local().Format("02.01.2006"), status, link.AccessCount)
+		if link.LastAccessedAt != nil {
+			fmt.Fprintf(&text, " (последний — %s)", link.LastAccessedAt.Local().Format("02.01 15:04"))
+		}
+		text.WriteString("\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отозвать: "+name, fmt.Sprintf("link_rm_%d", link.ID)),
+		))
+	}
+	text.WriteString("\n/link — новая ссылка")
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.send(chatID, msg)
+}
+
+// handleLinkSelection отзывает ссылку по кнопке; action — rm_<id>
+func (b *Bot) handleLinkSelection(chatID int64, action string, user *models.User) {
+	id, ok := strings.CutPrefix(action, "rm_")
+	linkID, err := strconv.ParseUint(id, 10, 64)
+	if !ok || err != nil {
+		b.sendMessage(chatID, "Ошибка обработки ссылки")
+		return
+	}
+
+	_, err = b.linkService.Revoke(user.ID, uint(linkID))
+	if errors.Is(err, services.ErrNotFound) {
+		b.sendMessage(chatID, "Ссылка уже отозвана.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking public link %d for user %d: %v", linkID, user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось отозвать ссылку, попробуйте позже.")
+		return
+	}
+	b.sendMessage(chatID, "🔒 Ссылка отозвана: сводка по ней больше не открывается.")
+}
@@ -0,0 +1,53 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBot_LinkCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	bot.config.WebAppURL = "https://diary.example.com/"
+
+	user := testutils.CreateTestUser(testDB.DB, 8090)
+
+	bot.handleCommand(commandMessage(8090, "/link", "/link 30 Эндокринолог"), user)
+	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Contains(t, sentMsg.Text, "за 30 дн.")
+	assert.Regexp(t, regexp.MustCompile(`https://diary\.example\.com/share/[A-Za-z0-9_-]+`), sentMsg.Text)
+
+	mockAPI.ClearMessages()
+	bot.handleCommand(commandMessage(8090, "/link", "/link 500"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "/link 30")
+
+	mockAPI.ClearMessages()
+	bot.handleCommand(commandMessage(8090, "/links", "/links"), user)
+	sentMsg, ok = mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Contains(t, sentMsg.Text, "Эндокринолог")
+	keyboard, ok := sentMsg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	require.True(t, ok)
+	require.Len(t, keyboard.InlineKeyboard, 1)
+	data := *keyboard.InlineKeyboard[0][0].CallbackData
+
+	links, err := bot.linkService.List(user.ID)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, fmt.Sprintf("link_rm_%d", links[0].ID), data)
+
+	mockAPI.ClearMessages()
+	bot.handleLinkSelection(8090, data[5:], user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "Ссылка отозвана")
+	links, err = bot.linkService.List(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, links)
+}
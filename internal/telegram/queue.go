@@ -0,0 +1,179 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrQueueFull возвращается, когда очередь переполнена
+	ErrQueueFull = errors.New("queue is full")
+	// ErrQueueClosed возвращается после начала остановки очереди
+	ErrQueueClosed = errors.New("queue is shut down")
+)
+
+// QueueStats содержит метрики очереди
+type QueueStats struct {
+	Workers     int    `json:"workers"`
+	QueueSize   int    `json:"queue_size"`
+	Pending     int    `json:"pending"`
+	ActiveChats int    `json:"active_chats"`
+	Accepted    uint64 `json:"accepted"`
+	Processed   uint64 `json:"processed"`
+	Rejected    uint64 `json:"rejected"`
+	Panics      uint64 `json:"panics"`
+}
+
+// chatPool обрабатывает элементы фиксированным пулом воркеров.
+// Элементы одного чата обрабатываются строго последовательно и в порядке
+// поступления, разные чаты обрабатываются параллельно.
+type chatPool[T any] struct {
+	name      string
+	handle    func(chatID int64, item T)
+	workers   int
+	queueSize int
+
+	mu      sync.Mutex
+	chats   map[int64][]T
+	ready   chan int64
+	pending int
+	closed  bool
+	wg      sync.WaitGroup
+
+	accepted  atomic.Uint64
+	processed atomic.Uint64
+	rejected  atomic.Uint64
+	panics    atomic.Uint64
+}
+
+func newChatPool[T any](name string, workers, queueSize int, handle func(chatID int64, item T)) *chatPool[T] {
+	p := &chatPool[T]{
+		name:      name,
+		handle:    handle,
+		workers:   workers,
+		queueSize: queueSize,
+		chats:     make(map[int64][]T),
+		// В ready одновременно находится не больше чатов, чем элементов в очереди
+		ready: make(chan int64, queueSize),
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+
+	return p
+}
+
+// push ставит элемент в очередь чата
+func (p *chatPool[T]) push(chatID int64, item T) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		p.rejected.Add(1)
+		return ErrQueueClosed
+	}
+	if p.pending >= p.queueSize {
+		p.rejected.Add(1)
+		return ErrQueueFull
+	}
+
+	queue, scheduled := p.chats[chatID]
+	p.chats[chatID] = append(queue, item)
+	p.pending++
+	p.accepted.Add(1)
+
+	// Чат, уже находящийся в работе, будет перепланирован воркером
+	if !scheduled {
+		p.ready <- chatID
+	}
+
+	return nil
+}
+
+// shutdown прекращает прием элементов и ждет обработки уже принятых
+func (p *chatPool[T]) shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		if p.pending == 0 {
+			close(p.ready)
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *chatPool[T]) stats() QueueStats {
+	p.mu.Lock()
+	pending := p.pending
+	activeChats := len(p.chats)
+	p.mu.Unlock()
+
+	return QueueStats{
+		Workers:     p.workers,
+		QueueSize:   p.queueSize,
+		Pending:     pending,
+		ActiveChats: activeChats,
+		Accepted:    p.accepted.Load(),
+		Processed:   p.processed.Load(),
+		Rejected:    p.rejected.Load(),
+		Panics:      p.panics.Load(),
+	}
+}
+
+func (p *chatPool[T]) worker() {
+	defer p.wg.Done()
+
+	for chatID := range p.ready {
+		p.mu.Lock()
+		item := p.chats[chatID][0]
+		p.mu.Unlock()
+
+		p.safeHandle(chatID, item)
+		p.processed.Add(1)
+
+		p.mu.Lock()
+		p.pending--
+		if queue := p.chats[chatID][1:]; len(queue) > 0 {
+			p.chats[chatID] = queue
+			// Возвращаем чат в конец очереди, чтобы не блокировать остальные чаты
+			p.ready <- chatID
+		} else {
+			delete(p.chats, chatID)
+		}
+		if p.closed && p.pending == 0 {
+			close(p.ready)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// safeHandle вызывает обработчик и перехватывает панику, чтобы она не уронила процесс
+func (p *chatPool[T]) safeHandle(chatID int64, item T) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.panics.Add(1)
+			log.Printf("Panic in %s worker (chat %d): %v\n%s", p.name, chatID, r, debug.Stack())
+		}
+	}()
+
+	p.handle(chatID, item)
+}
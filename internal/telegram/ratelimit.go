@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket ограничивает частоту операций алгоритмом token bucket
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // токенов в секунду
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// reserve забирает токен и возвращает, сколько нужно подождать до его появления.
+// Токены резервируются в долг, поэтому конкурирующие вызовы выстраиваются в очередь.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// idle сообщает, что корзина полна и ее можно безопасно удалить
+func (b *tokenBucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
@@ -0,0 +1,38 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	bucket := newTokenBucket(2, 2, start)
+
+	// Первые два токена доступны сразу
+	assert.Equal(t, time.Duration(0), bucket.reserve(start))
+	assert.Equal(t, time.Duration(0), bucket.reserve(start))
+
+	// Дальше токены выдаются в долг с нарастающим ожиданием
+	assert.Equal(t, 500*time.Millisecond, bucket.reserve(start))
+	assert.Equal(t, time.Second, bucket.reserve(start))
+
+	// Через секунду долг погашен, корзина снова пуста
+	assert.Equal(t, 500*time.Millisecond, bucket.reserve(start.Add(time.Second)))
+}
+
+func TestTokenBucket_RefillIsCapped(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	bucket := newTokenBucket(1, 1, start)
+
+	assert.Equal(t, time.Duration(0), bucket.reserve(start))
+	assert.False(t, bucket.idle(start))
+
+	// Долгий простой не накапливает токены сверх burst
+	later := start.Add(time.Hour)
+	assert.True(t, bucket.idle(later))
+	assert.Equal(t, time.Duration(0), bucket.reserve(later))
+	assert.Equal(t, time.Second, bucket.reserve(later))
+}
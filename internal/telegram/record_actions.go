@@ -0,0 +1,506 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/parser"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	RecordUndoWindow    = 5 * time.Minute  // Сколько времени можно отменить удаление записи
+	PendingInputTimeout = 10 * time.Minute // Сколько бот ждет ввода после нажатия кнопки
+
+	recordCallbackPrefix = "rec:"
+)
+
+// Действия с записями из inline кнопок
+const (
+	recordActionEdit    = "edit"
+	recordActionDelete  = "del"
+	recordActionNote    = "note"
+	recordActionContext = "ctx"
+	recordActionSetCtx  = "setctx"
+	recordActionUndo    = "undo"
+)
+
+// Типы записей в callback data
+const (
+	recordKindGlucose = "g"
+	recordKindFood    = "f"
+	recordKindInsulin = "i"
+)
+
+// recordContextOption описывает вариант кнопки выбора контекста
+type recordContextOption struct {
+	code  string // значение в callback data
+	label string
+	value string // значение, сохраняемое в записи
+}
+
+var glucoseContextOptions = []recordContextOption{
+	{"fast", "🌅 Натощак", models.GlucoseContextFasting},
+	{"before", "🍽 До еды", models.GlucoseContextBeforeMeal},
+	{"after", "🍴 После еды", models.GlucoseContextAfterMeal},
+	{"bed", "🌙 Перед сном", models.GlucoseContextBedtime},
+}
+
+var foodContextOptions = []recordContextOption{
+	{"breakfast", "🌅 Завтрак", "завтрак"},
+	{"lunch", "🌞 Обед", "обед"},
+	{"dinner", "🌙 Ужин", "ужин"},
+	{"snack", "🍎 Перекус", "перекус"},
+}
+
+var insulinContextOptions = []recordContextOption{
+	{"bolus", "⚡ Болюс", models.InsulinTypeBolus},
+	{"basal", "🌙 Базальный", models.InsulinTypeBasal},
+}
+
+// contextOptions возвращает варианты контекста для типа записи
+func contextOptions(kind string) []recordContextOption {
+	switch kind {
+	case recordKindFood:
+		return foodContextOptions
+	case recordKindInsulin:
+		return insulinContextOptions
+	}
+	return glucoseContextOptions
+}
+
+// insulinTypeText возвращает тип инсулина для отображения пользователю
+func insulinTypeText(insulinType string) string {
+	switch insulinType {
+	case models.InsulinTypeBolus:
+		return "болюс"
+	case models.InsulinTypeBasal:
+		return "базальный"
+	}
+	return ""
+}
+
+// glucoseContextText возвращает контекст измерения для отображения пользователю
+func glucoseContextText(measurementContext string) string {
+	switch measurementContext {
+	case models.GlucoseContextFasting:
+		return "натощак"
+	case models.GlucoseContextBeforeMeal:
+		return "до еды"
+	case models.GlucoseContextAfterMeal:
+		return "после еды"
+	case models.GlucoseContextBedtime:
+		return "перед сном"
+	}
+	return ""
+}
+
+// recordCallback — разобранные данные inline кнопки записи
+type recordCallback struct {
+	action   string
+	kind     string
+	recordID uint
+	arg      string
+}
+
+// encodeRecordCallback формирует callback data, подписанные для владельца записи.
+// Подпись не дает использовать кнопку из пересланного сообщения в другом чате.
+func (b *Bot) encodeRecordCallback(ownerID int64, cb recordCallback) string {
+	payload := fmt.Sprintf("%s:%s:%d", cb.action, cb.kind, cb.recordID)
+	if cb.arg != "" {
+		payload += ":" + cb.arg
+	}
+	return recordCallbackPrefix + b.signCallback(ownerID, payload) + ":" + payload
+}
+
+// decodeRecordCallback проверяет подпись и разбирает callback data
+func (b *Bot) decodeRecordCallback(ownerID int64, data string) (recordCallback, bool) {
+	rest := strings.TrimPrefix(data, recordCallbackPrefix)
+	signature, payload, ok := strings.Cut(rest, ":")
+	if !ok || !hmac.Equal([]byte(signature), []byte(b.signCallback(ownerID, payload))) {
+		return recordCallback{}, false
+	}
+
+	parts := strings.Split(payload, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return recordCallback{}, false
+	}
+	recordID, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return recordCallback{}, false
+	}
+
+	cb := recordCallback{action: parts[0], kind: parts[1], recordID: uint(recordID)}
+	if len(parts) == 4 {
+		cb.arg = parts[3]
+	}
+	return cb, true
+}
+
+// signCallback подписывает данные кнопки ключом, производным от токена бота
+func (b *Bot) signCallback(ownerID int64, payload string) string {
+	key := sha256.Sum256([]byte("diabetbot-callback:" + b.config.BotToken))
+	mac := hmac.New(sha256.New, key[:])
+	fmt.Fprintf(mac, "%d:%s", ownerID, payload)
+	// 8 байт подписи достаточно и укладываются в лимит callback data в 64 байта
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// recordActionsKeyboard возвращает кнопки управления записью
+func (b *Bot) recordActionsKeyboard(ownerID int64, kind string, recordID uint) tgbotapi.InlineKeyboardMarkup {
+	button := func(text, action string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(text, b.encodeRecordCallback(ownerID, recordCallback{action: action, kind: kind, recordID: recordID}))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button("✏️ Изменить", recordActionEdit),
+			button("🗑 Удалить", recordActionDelete),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button("📝 Добавить заметку", recordActionNote),
+			button("🏷 Контекст", recordActionContext),
+		),
+	)
+}
+
+// handleRecordCallback обрабатывает нажатия кнопок под подтверждением записи
+func (b *Bot) handleRecordCallback(callbackQuery *tgbotapi.CallbackQuery) {
+	cb, ok := b.decodeRecordCallback(callbackQuery.From.ID, callbackQuery.Data)
+	if !ok {
+		log.Printf("Rejected record callback with invalid signature from user %d", callbackQuery.From.ID)
+		b.answerCallback(callbackQuery.ID, "⛔ Эта кнопка недоступна")
+		return
+	}
+
+	user, err := b.userService.GetByTelegramID(callbackQuery.From.ID)
+	if err != nil {
+		log.Printf("Error getting user for callback: %v", err)
+		b.answerCallback(callbackQuery.ID, "Ошибка обработки запроса")
+		return
+	}
+
+	chatID := callbackQuery.Message.Chat.ID
+	messageID := callbackQuery.Message.MessageID
+
+	switch cb.action {
+	case recordActionEdit:
+		b.answerCallback(callbackQuery.ID, "")
+		b.pending.set(chatID, pendingInput{action: recordActionEdit, kind: cb.kind, recordID: cb.recordID})
+		switch cb.kind {
+		case recordKindGlucose:
+			b.sendMessage(chatID, "✏️ Отправьте новое значение глюкозы (например: 5.6)")
+		case recordKindInsulin:
+			b.sendMessage(chatID, "✏️ Отправьте новую дозу инсулина в единицах (например: 6)")
+		default:
+			b.sendMessage(chatID, "✏️ Отправьте новое описание приема пищи")
+		}
+	case recordActionNote:
+		b.answerCallback(callbackQuery.ID, "")
+		b.pending.set(chatID, pendingInput{action: recordActionNote, kind: cb.kind, recordID: cb.recordID})
+		b.sendMessage(chatID, "📝 Отправьте текст заметки")
+	case recordActionContext:
+		b.answerCallback(callbackQuery.ID, "")
+		b.sendContextPicker(chatID, callbackQuery.From.ID, cb)
+	case recordActionSetCtx:
+		b.setRecordContext(callbackQuery, user, cb)
+	case recordActionDelete:
+		b.deleteRecord(callbackQuery, user, cb, messageID)
+	case recordActionUndo:
+		b.undoDeleteRecord(callbackQuery, user, cb, messageID)
+	default:
+		b.answerCallback(callbackQuery.ID, "")
+	}
+}
+
+func (b *Bot) sendContextPicker(chatID, ownerID int64, cb recordCallback) {
+	options, text := contextOptions(cb.kind), "🏷 Выберите контекст измерения:"
+	switch cb.kind {
+	case recordKindFood:
+		text = "🏷 Выберите прием пищи:"
+	case recordKindInsulin:
+		text = "🏷 Выберите тип инсулина:"
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(options); i += 2 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, option := range options[i:min(i+2, len(options))] {
+			data := b.encodeRecordCallback(ownerID, recordCallback{action: recordActionSetCtx, kind: cb.kind, recordID: cb.recordID, arg: option.code})
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(option.label, data))
+		}
+		rows = append(rows, row)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.send(chatID, msg)
+}
+
+func (b *Bot) setRecordContext(callbackQuery *tgbotapi.CallbackQuery, user *models.User, cb recordCallback) {
+	options := contextOptions(cb.kind)
+
+	var option *recordContextOption
+	for i := range options {
+		if options[i].code == cb.arg {
+			option = &options[i]
+		}
+	}
+	if option == nil {
+		b.answerCallback(callbackQuery.ID, "Неизвестный контекст")
+		return
+	}
+
+	var err error
+	switch cb.kind {
+	case recordKindGlucose:
+		err = b.glucoseService.UpdateContext(user.ID, cb.recordID, option.value)
+	case recordKindInsulin:
+		err = b.insulinService.UpdateRecord(user.ID, cb.recordID, map[string]interface{}{"insulin_type": option.value})
+	default:
+		err = b.foodService.UpdateRecord(user.ID, cb.recordID, map[string]interface{}{"food_type": option.value})
+	}
+	if err != nil {
+		log.Printf("Error updating record context: %v", err)
+		b.answerCallback(callbackQuery.ID, "Ошибка сохранения")
+		return
+	}
+
+	b.answerCallback(callbackQuery.ID, "Сохранено")
+	chatID := callbackQuery.Message.Chat.ID
+	contextText := option.value
+	switch cb.kind {
+	case recordKindGlucose:
+		contextText = glucoseContextText(option.value)
+	case recordKindInsulin:
+		contextText = insulinTypeText(option.value)
+	}
+	b.send(chatID, tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID,
+		fmt.Sprintf("🏷 Контекст сохранен: %s", contextText)))
+}
+
+func (b *Bot) deleteRecord(callbackQuery *tgbotapi.CallbackQuery, user *models.User, cb recordCallback, messageID int) {
+	description, ok := b.describeRecord(user, cb)
+	if !ok {
+		b.answerCallback(callbackQuery.ID, "Запись уже удалена")
+		return
+	}
+
+	var err error
+	switch cb.kind {
+	case recordKindGlucose:
+		err = b.glucoseService.DeleteRecord(user.ID, cb.recordID)
+	case recordKindInsulin:
+		err = b.insulinService.DeleteRecord(user.ID, cb.recordID)
+	default:
+		err = b.foodService.DeleteRecord(user.ID, cb.recordID)
+	}
+	if err != nil {
+		log.Printf("Error deleting record: %v", err)
+		b.answerCallback(callbackQuery.ID, "Ошибка удаления")
+		return
+	}
+
+	b.answerCallback(callbackQuery.ID, "Удалено")
+
+	chatID := callbackQuery.Message.Chat.ID
+	undo := b.encodeRecordCallback(callbackQuery.From.ID, recordCallback{action: recordActionUndo, kind: cb.kind, recordID: cb.recordID})
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID,
+		fmt.Sprintf("🗑 Запись удалена: %s\n\nОтменить удаление можно в течение %d минут.", description, int(RecordUndoWindow.Minutes())),
+		tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Отменить удаление", undo),
+		)),
+	)
+	b.send(chatID, edit)
+}
+
+func (b *Bot) undoDeleteRecord(callbackQuery *tgbotapi.CallbackQuery, user *models.User, cb recordCallback, messageID int) {
+	deletedAfter := time.Now().Add(-RecordUndoWindow)
+
+	var err error
+	switch cb.kind {
+	case recordKindGlucose:
+		err = b.glucoseService.RestoreRecord(user.ID, cb.recordID, deletedAfter)
+	case recordKindInsulin:
+		err = b.insulinService.RestoreRecord(user.ID, cb.recordID, deletedAfter)
+	default:
+		err = b.foodService.RestoreRecord(user.ID, cb.recordID, deletedAfter)
+	}
+	if err != nil {
+		b.answerCallback(callbackQuery.ID, "⌛ Время для отмены удаления истекло")
+		return
+	}
+
+	b.answerCallback(callbackQuery.ID, "Восстановлено")
+
+	description, _ := b.describeRecord(user, cb)
+	chatID := callbackQuery.Message.Chat.ID
+	b.send(chatID, tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID,
+		fmt.Sprintf("↩️ Запись восстановлена: %s", description),
+		b.recordActionsKeyboard(callbackQuery.From.ID, cb.kind, cb.recordID),
+	))
+}
+
+// describeRecord возвращает краткое описание записи для сообщений
+func (b *Bot) describeRecord(user *models.User, cb recordCallback) (string, bool) {
+	switch cb.kind {
+	case recordKindGlucose:
+		record, err := b.glucoseService.GetRecord(user.ID, cb.recordID)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("%.1f ммоль/л", record.Value), true
+	case recordKindInsulin:
+		record, err := b.insulinService.GetRecord(user.ID, cb.recordID)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("%g ед инсулина", record.Units), true
+	}
+
+	record, err := b.foodService.GetRecord(user.ID, cb.recordID)
+	if err != nil {
+		return "", false
+	}
+	return record.FoodName, true
+}
+
+// handlePendingInput обрабатывает сообщение, которого бот ждет после нажатия кнопки.
+// Возвращает false, если ожидаемого ввода нет.
+func (b *Bot) handlePendingInput(message *tgbotapi.Message, user *models.User) bool {
+	input, ok := b.pending.take(message.Chat.ID)
+	if !ok {
+		return false
+	}
+
+	chatID := message.Chat.ID
+	text := strings.TrimSpace(message.Text)
+
+	switch {
+	case input.action == recordActionEdit && input.kind == recordKindGlucose:
+		value, err := strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 64)
+		if err != nil || value < parser.MinGlucose || value > parser.MaxGlucose {
+			// Даем пользователю еще попытку
+			b.pending.set(chatID, input)
+			b.sendMessage(chatID, "Пожалуйста, введите корректное значение глюкозы (1.0-30.0 ммоль/л)")
+			return true
+		}
+		record, err := b.glucoseService.GetRecord(user.ID, input.recordID)
+		if err == nil {
+			err = b.glucoseService.UpdateRecord(user.ID, input.recordID, value, record.Notes)
+		}
+		if err != nil {
+			b.sendMessage(chatID, "Ошибка сохранения данных")
+			return true
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Исправил: %.1f ммоль/л", value))
+		msg.ReplyMarkup = b.recordActionsKeyboard(user.TelegramID, recordKindGlucose, input.recordID)
+		b.send(chatID, msg)
+	case input.action == recordActionEdit && input.kind == recordKindFood:
+		if err := b.foodService.UpdateRecord(user.ID, input.recordID, map[string]interface{}{"food_name": text}); err != nil {
+			b.sendMessage(chatID, "Ошибка сохранения записи о питании")
+			return true
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Исправил: %s", text))
+		msg.ReplyMarkup = b.recordActionsKeyboard(user.TelegramID, recordKindFood, input.recordID)
+		b.send(chatID, msg)
+	case input.action == recordActionEdit && input.kind == recordKindInsulin:
+		units, err := strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 64)
+		if err != nil || units <= 0 || units > parser.MaxInsulin {
+			b.pending.set(chatID, input)
+			b.sendMessage(chatID, fmt.Sprintf("Пожалуйста, введите дозу инсулина от 0 до %.0f ед", parser.MaxInsulin))
+			return true
+		}
+		if err := b.insulinService.UpdateRecord(user.ID, input.recordID, map[string]interface{}{"units": units}); err != nil {
+			b.sendMessage(chatID, "Ошибка сохранения данных")
+			return true
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Исправил: %g ед инсулина", units))
+		msg.ReplyMarkup = b.recordActionsKeyboard(user.TelegramID, recordKindInsulin, input.recordID)
+		b.send(chatID, msg)
+	case input.action == recordActionNote:
+		var err error
+		switch input.kind {
+		case recordKindGlucose:
+			var record *models.GlucoseRecord
+			record, err = b.glucoseService.GetRecord(user.ID, input.recordID)
+			if err == nil {
+				err = b.glucoseService.UpdateRecord(user.ID, input.recordID, record.Value, text)
+			}
+		case recordKindInsulin:
+			err = b.insulinService.UpdateRecord(user.ID, input.recordID, map[string]interface{}{"notes": text})
+		default:
+			err = b.foodService.UpdateRecord(user.ID, input.recordID, map[string]interface{}{"notes": text})
+		}
+		if err != nil {
+			b.sendMessage(chatID, "Ошибка сохранения заметки")
+			return true
+		}
+		b.sendMessage(chatID, "📝 Заметка сохранена")
+	}
+
+	return true
+}
+
+// answerCallback отвечает на нажатие inline кнопки, text показывается всплывающей подсказкой
+func (b *Bot) answerCallback(callbackID, text string) {
+	if _, err := b.api.Request(tgbotapi.NewCallback(callbackID, text)); err != nil {
+		log.Printf("Error answering callback: %v", err)
+	}
+}
+
+// pendingInput описывает ввод, которого бот ждет от пользователя
+type pendingInput struct {
+	action   string
+	kind     string
+	recordID uint
+	expires  time.Time
+}
+
+// pendingInputs хранит ожидаемый ввод по чатам. Нулевое значение готово к использованию.
+type pendingInputs struct {
+	mu     sync.Mutex
+	inputs map[int64]pendingInput
+}
+
+func (p *pendingInputs) set(chatID int64, input pendingInput) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.inputs == nil {
+		p.inputs = make(map[int64]pendingInput)
+	}
+	input.expires = time.Now().Add(PendingInputTimeout)
+	p.inputs[chatID] = input
+}
+
+// take возвращает и удаляет ожидаемый ввод чата, если он не устарел
+func (p *pendingInputs) take(chatID int64) (pendingInput, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	input, ok := p.inputs[chatID]
+	if !ok {
+		return pendingInput{}, false
+	}
+	delete(p.inputs, chatID)
+	if time.Now().After(input.expires) {
+		return pendingInput{}, false
+	}
+	return input, true
+}
+
+func (p *pendingInputs) clear(chatID int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.inputs, chatID)
+}
@@ -0,0 +1,225 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordCallbackQuery(bot *Bot, from int64, cb recordCallback) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: from},
+		Message: &tgbotapi.Message{MessageID: 42, Chat: &tgbotapi.Chat{ID: from}},
+		Data:    bot.encodeRecordCallback(from, cb),
+	}
+}
+
+func TestRecordCallback_Signature(t *testing.T) {
+	bot := &Bot{config: testutils.NewFakeBotAPI(t).TelegramConfig()}
+	cb := recordCallback{action: recordActionSetCtx, kind: recordKindFood, recordID: 4294967295, arg: "breakfast"}
+
+	data := bot.encodeRecordCallback(100, cb)
+	assert.LessOrEqual(t, len(data), 64, "callback data не должны превышать лимит Telegram")
+
+	decoded, ok := bot.decodeRecordCallback(100, data)
+	require.True(t, ok)
+	assert.Equal(t, cb, decoded)
+
+	// Кнопка, подписанная для другого пользователя, не принимается
+	_, ok = bot.decodeRecordCallback(200, data)
+	assert.False(t, ok)
+
+	// Подмена идентификатора записи ломает подпись
+	tampered := data[:len(data)-len(":4294967295:breakfast")] + ":1:breakfast"
+	_, ok = bot.decodeRecordCallback(100, tampered)
+	assert.False(t, ok)
+}
+
+func TestBot_HandleTextMessage_AttachesRecordActions(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	user := testutils.CreateTestUser(testDB.DB, 123456789)
+
+	bot.handleTextMessage(&tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 123456789}, Text: "6.5"}, user)
+
+	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	markup, ok := sentMsg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	require.True(t, ok)
+	require.Len(t, markup.InlineKeyboard, 2)
+	assert.Equal(t, "✏️ Изменить", markup.InlineKeyboard[0][0].Text)
+	assert.Equal(t, "🗑 Удалить", markup.InlineKeyboard[0][1].Text)
+
+	cb, ok := bot.decodeRecordCallback(123456789, *markup.InlineKeyboard[0][1].CallbackData)
+	require.True(t, ok)
+	assert.Equal(t, recordActionDelete, cb.action)
+	assert.Equal(t, recordKindGlucose, cb.kind)
+}
+
+func TestBot_RecordDeleteAndUndo(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	user := testutils.CreateTestUser(testDB.DB, 123456789)
+	record := testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 6.5)
+
+	bot.handleCallbackQuery(recordCallbackQuery(bot, 123456789, recordCallback{action: recordActionDelete, kind: recordKindGlucose, recordID: record.ID}))
+
+	_, err := bot.glucoseService.GetRecord(user.ID, record.ID)
+	assert.Error(t, err, "запись должна быть удалена")
+
+	edit, ok := mockAPI.GetLastSentMessage().(tgbotapi.EditMessageTextConfig)
+	require.True(t, ok)
+	assert.Equal(t, 42, edit.MessageID)
+	assert.Contains(t, edit.Text, "Запись удалена: 6.5 ммоль/л")
+	require.NotNil(t, edit.ReplyMarkup)
+	assert.Equal(t, "↩️ Отменить удаление", edit.ReplyMarkup.InlineKeyboard[0][0].Text)
+
+	undo := &tgbotapi.CallbackQuery{
+		ID:      "cb-undo",
+		From:    &tgbotapi.User{ID: 123456789},
+		Message: &tgbotapi.Message{MessageID: 42, Chat: &tgbotapi.Chat{ID: 123456789}},
+		Data:    *edit.ReplyMarkup.InlineKeyboard[0][0].CallbackData,
+	}
+	bot.handleCallbackQuery(undo)
+
+	restored, err := bot.glucoseService.GetRecord(user.ID, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6.5, restored.Value)
+
+	edit, ok = mockAPI.GetLastSentMessage().(tgbotapi.EditMessageTextConfig)
+	require.True(t, ok)
+	assert.Contains(t, edit.Text, "Запись восстановлена")
+}
+
+func TestBot_RecordUndoExpired(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	user := testutils.CreateTestUser(testDB.DB, 123456789)
+	record := testutils.CreateTestFoodRecord(testDB.DB, user.ID, "Овсянка", "завтрак")
+
+	require.NoError(t, bot.foodService.DeleteRecord(user.ID, record.ID))
+	require.NoError(t, testDB.DB.Unscoped().Model(&models.FoodRecord{}).Where("id = ?", record.ID).
+		Update("deleted_at", time.Now().Add(-RecordUndoWindow-time.Minute)).Error)
+	mockAPI.ClearMessages()
+
+	bot.handleCallbackQuery(recordCallbackQuery(bot, 123456789, recordCallback{action: recordActionUndo, kind: recordKindFood, recordID: record.ID}))
+
+	_, err := bot.foodService.GetRecord(user.ID, record.ID)
+	assert.Error(t, err, "запись не должна восстанавливаться после окончания окна отмены")
+	assert.Empty(t, mockAPI.GetAllSentMessages())
+}
+
+func TestBot_RecordCallbackFromAnotherUser(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	owner := testutils.CreateTestUser(testDB.DB, 111)
+	testutils.CreateTestUser(testDB.DB, 222)
+	record := testutils.CreateTestGlucoseRecord(testDB.DB, owner.ID, 5.5)
+
+	// Кнопку из сообщения владельца нажимает другой пользователь
+	query := recordCallbackQuery(bot, 111, recordCallback{action: recordActionDelete, kind: recordKindGlucose, recordID: record.ID})
+	query.From = &tgbotapi.User{ID: 222}
+	bot.handleCallbackQuery(query)
+
+	_, err := bot.glucoseService.GetRecord(owner.ID, record.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, mockAPI.GetAllSentMessages())
+}
+
+func TestBot_RecordEditViaPendingInput(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	user := testutils.CreateTestUser(testDB.DB, 123456789)
+	record, err := bot.glucoseService.CreateRecord(user.ID, 6.5, "после прогулки")
+	require.NoError(t, err)
+
+	bot.handleCallbackQuery(recordCallbackQuery(bot, 123456789, recordCallback{action: recordActionEdit, kind: recordKindGlucose, recordID: record.ID}))
+	prompt, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Contains(t, prompt.Text, "новое значение глюкозы")
+
+	from := &tgbotapi.User{ID: 123456789, FirstName: "Test"}
+	chat := &tgbotapi.Chat{ID: 123456789}
+
+	t.Run("InvalidValueKeepsWaiting", func(t *testing.T) {
+		bot.handleMessage(&tgbotapi.Message{From: from, Chat: chat, Text: "50"})
+		sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+		require.True(t, ok)
+		assert.Contains(t, sentMsg.Text, "корректное значение")
+	})
+
+	t.Run("ValueIsUpdated", func(t *testing.T) {
+		bot.handleMessage(&tgbotapi.Message{From: from, Chat: chat, Text: "7,2"})
+
+		updated, err := bot.glucoseService.GetRecord(user.ID, record.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7.2, updated.Value)
+		assert.Equal(t, "после прогулки", updated.Notes)
+
+		sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+		require.True(t, ok)
+		assert.Contains(t, sentMsg.Text, "Исправил: 7.2 ммоль/л")
+
+		// Ожидание ввода сброшено, новое значение создает новую запись
+		records, err := bot.glucoseService.GetUserRecords(user.ID, 1)
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+	})
+}
+
+func TestBot_RecordNote(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	user := testutils.CreateTestUser(testDB.DB, 123456789)
+	record := testutils.CreateTestFoodRecord(testDB.DB, user.ID, "Гречка", "обед")
+
+	bot.handleCallbackQuery(recordCallbackQuery(bot, 123456789, recordCallback{action: recordActionNote, kind: recordKindFood, recordID: record.ID}))
+	bot.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 123456789}, Chat: &tgbotapi.Chat{ID: 123456789}, Text: "с котлетой"})
+
+	updated, err := bot.foodService.GetRecord(user.ID, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "с котлетой", updated.Notes)
+
+	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Contains(t, sentMsg.Text, "Заметка сохранена")
+}
+
+func TestBot_RecordContext(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	user := testutils.CreateTestUser(testDB.DB, 123456789)
+	record := testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 5.2)
+
+	bot.handleCallbackQuery(recordCallbackQuery(bot, 123456789, recordCallback{action: recordActionContext, kind: recordKindGlucose, recordID: record.ID}))
+
+	picker, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	markup, ok := picker.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	require.True(t, ok)
+	fasting := markup.InlineKeyboard[0][0]
+	assert.Equal(t, "🌅 Натощак", fasting.Text)
+
+	bot.handleCallbackQuery(&tgbotapi.CallbackQuery{
+		ID:      "cb-ctx",
+		From:    &tgbotapi.User{ID: 123456789},
+		Message: &tgbotapi.Message{MessageID: 43, Chat: &tgbotapi.Chat{ID: 123456789}},
+		Data:    *fasting.CallbackData,
+	})
+
+	updated, err := bot.glucoseService.GetRecord(user.ID, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.GlucoseContextFasting, updated.MeasurementContext)
+
+	edit, ok := mockAPI.GetLastSentMessage().(tgbotapi.EditMessageTextConfig)
+	require.True(t, ok)
+	assert.Equal(t, 43, edit.MessageID)
+	assert.Contains(t, edit.Text, "Контекст сохранен: натощак")
+}
@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleReportCommand предлагает выбрать период отчета для врача
+func (b *Bot) handleReportCommand(message *tgbotapi.Message) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📅 7 дней", "report_7"),
+			tgbotapi.NewInlineKeyboardButtonData("📅 14 дней", "report_14"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📅 30 дней", "report_30"),
+			tgbotapi.NewInlineKeyboardButtonData("📅 90 дней", "report_90"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, "📄 Отчет для врача в PDF. Выберите период:")
+	msg.ReplyMarkup = keyboard
+	b.send(message.Chat.ID, msg)
+}
+
+// handleReportSelection строит отчет за последние days дней, включая сегодня, и отправляет его файлом
+func (b *Bot) handleReportSelection(chatID int64, period string, user *models.User) {
+	days, err := strconv.Atoi(period)
+	if err != nil || days < 1 || days > services.MaxReportDays {
+		b.sendMessage(chatID, "Ошибка обработки периода")
+		return
+	}
+
+	now := time.Now()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	report, err := b.reportService.Build(user, services.ReportOptions{From: tomorrow.AddDate(0, 0, -days), To: tomorrow})
+	if err != nil {
+		log.Printf("Error building report for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось подготовить отчет, попробуйте позже.")
+		return
+	}
+	if report.Glucose.Count == 0 && len(report.Meals) == 0 {
+		b.sendMessage(chatID, fmt.Sprintf("📄 За %d дн. нет записей для отчета.\n\nНачните записывать показания глюкозы!", days))
+		return
+	}
+
+	path, err := writeTempFile("diabetbot-report-*.pdf", func(w io.Writer) error {
+		return services.WriteReportPDF(w, report)
+	})
+	if err != nil {
+		log.Printf("Error rendering report for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось подготовить отчет, попробуйте позже.")
+		return
+	}
+
+	b.sendTempDocument(chatID, path, services.ReportFileName(now),
+		fmt.Sprintf("📄 Отчет для врача за %d дн.: время в диапазоне, суточный профиль, гипогликемии и питание", days))
+}
@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reportCallbackJSON(telegramID int64, data string) string {
+	return fmt.Sprintf(`{
+		"update_id": 5,
+		"callback_query": {
+			"id": "cb-report",
+			"from": {"id": %d, "is_bot": false, "first_name": "Test"},
+			"message": {"message_id": 30, "date": 1700000000, "chat": {"id": %d, "type": "private"}, "text": "📄 Отчет для врача в PDF. Выберите период:"},
+			"data": %q
+		}
+	}`, telegramID, telegramID, data)
+}
+
+func TestBot_HandleReportCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	bot.handleReportCommand(exportMessage(5050, "/report"))
+
+	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	keyboard, ok := sentMsg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	require.True(t, ok)
+	var data []string
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			data = append(data, *button.CallbackData)
+		}
+	}
+	assert.Equal(t, []string{"report_7", "report_14", "report_30", "report_90"}, data)
+}
+
+func TestBot_Webhook_ReportCallback(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+
+	user := testutils.CreateTestUser(testDB.DB, 5051)
+	testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 3.2)
+	testutils.CreateTestGlucoseRecord(testDB.DB, user.ID, 8.4)
+
+	handleWebhookJSON(t, bot, reportCallbackJSON(5051, "report_14"))
+
+	calls := fakeAPI.WaitForCalls(t, "sendDocument", 1)
+	assert.Equal(t, int64(5051), calls[0].ChatID())
+	assert.Contains(t, calls[0].Text(), "Отчет для врача за 14 дн.")
+
+	file := calls[0].Files["document"]
+	assert.Equal(t, fmt.Sprintf("diabetbot-report-%s.pdf", time.Now().Format("2006-01-02")), file.Name)
+	assert.True(t, bytes.HasPrefix(file.Data, []byte("%PDF-")))
+}
+
+func TestBot_Webhook_ReportCallback_NoData(t *testing.T) {
+	bot, fakeAPI, testDB := createWebhookBot(t)
+	testutils.CreateTestUser(testDB.DB, 5052)
+
+	handleWebhookJSON(t, bot, reportCallbackJSON(5052, "report_7"))
+
+	calls := fakeAPI.WaitForCalls(t, "sendMessage", 1)
+	assert.Contains(t, calls[0].Text(), "За 7 дн. нет записей для отчета")
+	assert.Empty(t, fakeAPI.CallsTo("sendDocument"))
+}
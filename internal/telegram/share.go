@@ -0,0 +1,185 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const shareUsage = `👪 Доступ к дневнику для родственников и врача
+
+/share read — ссылка с доступом только на чтение
+/share write — ссылка с доступом на чтение и запись
+/share — кому открыт дневник и отзыв доступа
+
+Ссылка одноразовая и действует 7 дней.`
+
+// shareRoleText — роль доступа для сообщений
+func shareRoleText(role string) string {
+	if role == models.ShareRoleWrite {
+		return "чтение и запись"
+	}
+	return "только чтение"
+}
+
+// handleShareCommand создает приглашение (/share read или /share write),
+// без аргументов показывает доступы с кнопками отзыва
+func (b *Bot) handleShareCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	role := strings.ToLower(strings.TrimSpace(message.CommandArguments()))
+	if role == "" {
+		b.sendShareList(chatID, user)
+		return
+	}
+	if !models.IsShareRole(role) {
+		b.sendMessage(chatID, shareUsage)
+		return
+	}
+
+	invite, err := b.sharingService.CreateInvite(user.ID, role)
+	var verr *services.ValidationError
+	switch {
+	case errors.As(err, &verr) && verr.Rule == "max_shares":
+		b.sendMessage(chatID, fmt.Sprintf("❌ Дневник открыт уже %d раз. Отзовите ненужные доступы в /share.", services.MaxShares))
+		return
+	case err != nil:
+		log.Printf("Error creating share invite for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось создать приглашение, попробуйте позже.")
+		return
+	}
+
+	text := fmt.Sprintf("🔗 Приглашение к дневнику (%s)\n\n", shareRoleText(invite.Role))
+	if b.username != "" {
+		text += fmt.Sprintf("Отправьте ссылку родственнику или врачу:\nhttps://t.me/%s?start=%s", b.username, invite.StartParam())
+	} else {
+		text += fmt.Sprintf("Попросите родственника или врача отправить боту команду:\n/start %s", invite.StartParam())
+	}
+	text += fmt.Sprintf("\n\nСсылка одноразовая и действует до %s. Отозвать доступ можно в /share.", invite.ExpiresAt.Local().Format("02.01.2006 15:04"))
+	b.sendMessage(chatID, text)
+}
+
+// sendShareList показывает, кому открыт дневник пользователя и чьи дневники открыты ему
+func (b *Bot) sendShareList(chatID int64, user *models.User) {
+	grants, err := b.sharingService.ListGrants(user.ID)
+	if err != nil {
+		log.Printf("Error listing shares for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось получить список доступов, попробуйте позже.")
+		return
+	}
+	accounts, err := b.sharingService.Accounts(user)
+	if err != nil {
+		log.Printf("Error listing shared accounts for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось получить список доступов, попробуйте позже.")
+		return
+	}
+	if len(grants) == 0 && len(accounts) == 1 {
+		b.sendMessage(chatID, shareUsage)
+		return
+	}
+
+	var text strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(grants) > 0 {
+		text.WriteString("👪 Ваш дневник открыт:\n")
+		for _, grant := range grants {
+			name := "Приглашение"
+			if grant.Grantee != nil {
+				name = grant.Grantee.DisplayName()
+				fmt.Fprintf(&text, "• %s — %s\n", name, shareRoleText(grant.Role))
+			} else {
+				fmt.Fprintf(&text, "• Приглашение (%s) до %s\n", shareRoleText(grant.Role), grant.ExpiresAt.Local().Format("02.01.2006"))
+			}
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("❌ Отозвать: "+name, fmt.Sprintf("share_rm_%d", grant.ID)),
+			))
+		}
+	}
+	if len(accounts) > 1 {
+		if text.Len() > 0 {
+			text.WriteString("\n")
+		}
+		text.WriteString("📖 Вам открыты дневники:\n")
+		for _, account := range accounts[1:] {
+			fmt.Fprintf(&text, "• %s — %s\n", account.DisplayName(), shareRoleText(account.Role))
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🚪 Отказаться: "+account.DisplayName(), fmt.Sprintf("share_rm_%d", account.ShareID)),
+			))
+		}
+		text.WriteString("Открыть их можно в веб-приложении.\n")
+	}
+	text.WriteString("\n/share read или /share write — новое приглашение")
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.send(chatID, msg)
+}
+
+// handleShareStart принимает приглашение из ссылки t.me/<бот>?start=share_<токен>
+// и сообщает об этом владельцу дневника
+func (b *Bot) handleShareStart(message *tgbotapi.Message, user *models.User, token string) {
+	chatID := message.Chat.ID
+	share, err := b.sharingService.AcceptInvite(user.ID, token)
+	var verr *services.ValidationError
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		b.sendMessage(chatID, "❌ Приглашение недействительно: срок истек, оно уже использовано или отозвано. Попросите новую ссылку.")
+		return
+	case errors.As(err, &verr) && verr.Rule == "own_share":
+		b.sendMessage(chatID, "Это приглашение к вашему собственному дневнику. Отправьте ссылку тому, кому хотите его открыть.")
+		return
+	case err != nil:
+		log.Printf("Error accepting share invite for user %d: %v", user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось принять приглашение, попробуйте позже.")
+		return
+	}
+
+	owner, err := b.userService.GetByID(share.OwnerID)
+	if err != nil {
+		log.Printf("Error getting share owner %d: %v", share.OwnerID, err)
+		b.sendMessage(chatID, "✅ Приглашение принято. Дневник доступен в веб-приложении.")
+		return
+	}
+	b.sendMessage(chatID, fmt.Sprintf("✅ Вам открыт дневник: %s (%s).\n\nОн доступен в веб-приложении. Отказаться от доступа можно в /share.",
+		services.NewShareUser(owner).DisplayName(), shareRoleText(share.Role)))
+	b.sendMessage(owner.TelegramID, fmt.Sprintf("🔗 %s получил доступ к вашему дневнику (%s).\nОтозвать доступ: /share",
+		services.NewShareUser(user).DisplayName(), shareRoleText(share.Role)))
+}
+
+// handleShareSelection отзывает доступ по кнопке; action — rm_<id>
+func (b *Bot) handleShareSelection(chatID int64, action string, user *models.User) {
+	id, ok := strings.CutPrefix(action, "rm_")
+	shareID, err := strconv.ParseUint(id, 10, 64)
+	if !ok || err != nil {
+		b.sendMessage(chatID, "Ошибка обработки доступа")
+		return
+	}
+
+	share, err := b.sharingService.Revoke(user.ID, uint(shareID))
+	if errors.Is(err, services.ErrNotFound) {
+		b.sendMessage(chatID, "Доступ уже отозван.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking share %d for user %d: %v", shareID, user.ID, err)
+		b.sendMessage(chatID, "❌ Не удалось отозвать доступ, попробуйте позже.")
+		return
+	}
+	b.sendMessage(chatID, "🔒 Доступ отозван.")
+
+	// Владелец закрыл дневник — получатель должен узнать, что доступа больше нет
+	if share.OwnerID == user.ID && share.GranteeID != nil {
+		grantee, err := b.userService.GetByID(*share.GranteeID)
+		if err != nil {
+			log.Printf("Error getting share grantee %d: %v", *share.GranteeID, err)
+			return
+		}
+		b.sendMessage(grantee.TelegramID, fmt.Sprintf("🔒 %s закрыл доступ к своему дневнику.", services.NewShareUser(user).DisplayName()))
+	}
+}
@@ -0,0 +1,94 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commandMessage(telegramID int64, command, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: telegramID},
+		Chat:      &tgbotapi.Chat{ID: telegramID},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}
+}
+
+func TestBot_ShareCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+	bot.username = "testbot"
+
+	owner := testutils.CreateTestUser(testDB.DB, 8080)
+	parent := testutils.CreateTestUser(testDB.DB, 8081)
+
+	bot.handleCommand(commandMessage(8080, "/share", "/share write"), owner)
+	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Contains(t, sentMsg.Text, "чтение и запись")
+	match := regexp.MustCompile(`https://t\.me/testbot\?start=(share_[A-Za-z0-9_-]+)`).FindStringSubmatch(sentMsg.Text)
+	require.Len(t, match, 2)
+
+	// Приглашенный открывает ссылку: бот получает /start share_<токен>
+	mockAPI.ClearMessages()
+	bot.handleCommand(commandMessage(8081, "/start", "/start "+match[1]), parent)
+	messages := mockAPI.GetAllSentMessages()
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[0].(tgbotapi.MessageConfig).Text, "Вам открыт дневник")
+	ownerMsg := messages[1].(tgbotapi.MessageConfig)
+	assert.Equal(t, int64(8080), ownerMsg.ChatID)
+	assert.Contains(t, ownerMsg.Text, "получил доступ")
+	require.NoError(t, bot.sharingService.Authorize(parent.ID, owner.ID, models.ShareRoleWrite))
+
+	// Ссылка одноразовая
+	mockAPI.ClearMessages()
+	bot.handleCommand(commandMessage(8081, "/start", "/start "+match[1]), parent)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "недействительно")
+
+	mockAPI.ClearMessages()
+	bot.handleCommand(commandMessage(8080, "/share", "/share"), owner)
+	sentMsg, ok = mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Contains(t, sentMsg.Text, "Test User 8081 — чтение и запись")
+	keyboard, ok := sentMsg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	require.True(t, ok)
+	require.Len(t, keyboard.InlineKeyboard, 1)
+	data := *keyboard.InlineKeyboard[0][0].CallbackData
+
+	grants, err := bot.sharingService.ListGrants(owner.ID)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, fmt.Sprintf("share_rm_%d", grants[0].ID), data)
+
+	mockAPI.ClearMessages()
+	bot.handleShareSelection(8080, data[6:], owner)
+	messages = mockAPI.GetAllSentMessages()
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[0].(tgbotapi.MessageConfig).Text, "Доступ отозван")
+	assert.Equal(t, int64(8081), messages[1].(tgbotapi.MessageConfig).ChatID)
+	assert.Error(t, bot.sharingService.Authorize(parent.ID, owner.ID, models.ShareRoleRead))
+}
+
+func TestBot_ShareCommand_WithoutUsername(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	owner := testutils.CreateTestUser(testDB.DB, 8090)
+	bot.handleShareCommand(commandMessage(8090, "/share", "/share read"), owner)
+	sentMsg, ok := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	assert.Regexp(t, `/start share_[A-Za-z0-9_-]{22}`, sentMsg.Text)
+	assert.Contains(t, sentMsg.Text, "только чтение")
+
+	bot.handleShareCommand(commandMessage(8090, "/share", "/share admin"), owner)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "/share read")
+}
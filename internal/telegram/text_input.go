@@ -0,0 +1,194 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/parser"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleTextMessage разбирает свободный текст и сохраняет найденные записи.
+// Если сообщение понято неуверенно, бот переспрашивает и ничего не сохраняет.
+func (b *Bot) handleTextMessage(message *tgbotapi.Message, user *models.User) {
+	result := parser.Parse(message.Text, time.Now())
+
+	if len(result.Intents) == 0 {
+		b.sendMessage(message.Chat.ID, "Не понял вас. Используйте /help для получения помощи.")
+		return
+	}
+	if result.NeedsClarification() {
+		b.sendMessage(message.Chat.ID, clarificationText(result))
+		return
+	}
+
+	for _, intent := range result.Intents {
+		switch intent.Kind {
+		case parser.KindGlucose:
+			b.recordGlucose(message.Chat.ID, user, intent.Glucose, result)
+		case parser.KindFood:
+			b.recordFood(message.Chat.ID, user, intent.Food, result)
+		case parser.KindInsulin:
+			b.recordInsulin(message.Chat.ID, user, intent.Insulin, result)
+		case parser.KindLab:
+			b.recordLab(message.Chat.ID, user, intent.Lab, result)
+		case parser.KindVital:
+			b.recordVital(message.Chat.ID, user, intent.Vital, result)
+		case parser.KindQuestion:
+			if len(result.Text) < 3 {
+				b.sendMessage(message.Chat.ID, "Не понял вас. Используйте /help для получения помощи.")
+				continue
+			}
+			b.handleQuestion(message, user)
+		}
+	}
+}
+
+func (b *Bot) recordGlucose(chatID int64, user *models.User, glucose *parser.Glucose, result parser.Result) {
+	record, err := b.glucoseService.CreateRecordAt(user.ID, glucose.Value, result.Time, glucose.Context, "")
+	if err != nil {
+		b.sendMessage(chatID, "Ошибка сохранения данных")
+		return
+	}
+
+	// Получаем рекомендации от ИИ
+	recommendation := b.aiService.GetGlucoseRecommendation(user, record)
+
+	response := fmt.Sprintf("✅ Записал: %.1f ммоль/л", glucose.Value)
+	if glucose.Context != "" {
+		response += ", " + glucoseContextText(glucose.Context)
+	}
+	response += recordTimeLine(result)
+	if forecast := b.recordForecast(user, record); forecast != "" {
+		response += "\n" + forecast
+	}
+	if hint := b.ketoneHint(user, record.Value, record.MeasuredAt); hint != "" {
+		response += "\n\n" + hint
+	}
+	response += "\n\n🤖 " + recommendation
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	msg.ReplyMarkup = b.recordActionsKeyboard(user.TelegramID, recordKindGlucose, record.ID)
+	b.send(chatID, msg)
+}
+
+func (b *Bot) recordFood(chatID int64, user *models.User, food *parser.Food, result parser.Result) {
+	foodType := food.MealType
+	if foodType == "" {
+		foodType = "неопределено"
+	}
+
+	record, err := b.foodService.CreateRecordAt(user.ID, food.Description, foodType, food.Carbs, nil, "", "", result.Time)
+	if err != nil {
+		b.sendMessage(chatID, "Ошибка сохранения записи о питании")
+		return
+	}
+
+	// Получаем рекомендации от ИИ
+	recommendation := b.aiService.GetFoodRecommendation(user, food.Description)
+
+	response := fmt.Sprintf("✅ Записал в дневник питания: %s", food.Description)
+	if food.Carbs != nil {
+		response += fmt.Sprintf(" (%.0f г углеводов)", *food.Carbs)
+	}
+	response += recordTimeLine(result) + "\n\n🤖 " + recommendation
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	msg.ReplyMarkup = b.recordActionsKeyboard(user.TelegramID, recordKindFood, record.ID)
+	b.send(chatID, msg)
+}
+
+func (b *Bot) recordInsulin(chatID int64, user *models.User, insulin *parser.Insulin, result parser.Result) {
+	record, err := b.insulinService.CreateRecord(user.ID, insulin.Units, insulin.Type, insulin.Name, result.Time, "")
+	if err != nil {
+		log.Printf("Error saving insulin record: %v", err)
+		b.sendMessage(chatID, "Ошибка сохранения данных")
+		return
+	}
+
+	response := fmt.Sprintf("💉 Записал инсулин: %g ед", insulin.Units)
+	var details []string
+	if insulin.Name != "" {
+		details = append(details, insulin.Name)
+	}
+	if typeText := insulinTypeText(insulin.Type); typeText != "" {
+		details = append(details, typeText)
+	}
+	if len(details) > 0 {
+		response += " (" + strings.Join(details, ", ") + ")"
+	}
+	response += recordTimeLine(result)
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	msg.ReplyMarkup = b.recordActionsKeyboard(user.TelegramID, recordKindInsulin, record.ID)
+	b.send(chatID, msg)
+}
+
+// clarificationText формирует вопрос по первому неуверенно понятому намерению
+func clarificationText(result parser.Result) string {
+	if result.InvalidTime != "" {
+		return fmt.Sprintf("🕐 Не понял время «%s». Уточните, пожалуйста, когда это было (например: «вчера в 22:30»)", result.InvalidTime)
+	}
+	now := time.Now()
+	if result.HasTime && result.Time.After(now) {
+		return "🕐 Указанное время еще не наступило. Уточните, пожалуйста, когда это было (например: «вчера в 22:30»)"
+	}
+
+	for _, intent := range result.Intents {
+		if intent.Kind == parser.KindQuestion || intent.Confidence >= parser.ClarifyThreshold {
+			continue
+		}
+
+		switch intent.Kind {
+		case parser.KindGlucose:
+			text := "Пожалуйста, введите корректное значение глюкозы (1.0-30.0 ммоль/л), например: «сахар 6.5» или «140 мг/дл»"
+			if intent.Glucose.MgDL {
+				text += fmt.Sprintf("\nЕсли вы указали мг/дл, напишите «%.0f мг/дл» — это %.1f ммоль/л", intent.Glucose.Value*18, intent.Glucose.Value)
+			}
+			return text
+		case parser.KindFood:
+			if intent.Food.Description == "" {
+				return "🍽 Что вы съели? Опишите блюдо, например: «на обед гречка 150г и котлета»"
+			}
+			return fmt.Sprintf("🍽 Не уверен, что правильно понял: «%s». Уточните, пожалуйста, что вы съели и сколько", intent.Food.Description)
+		case parser.KindInsulin:
+			return fmt.Sprintf("💉 Проверьте дозу инсулина: допустимо от 0 до %.0f ед, например: «уколол 6 ед новорапида»", parser.MaxInsulin)
+		case parser.KindLab:
+			return "🧪 Проверьте значение анализа, например: «HbA1c 6.8%» или «холестерин 5.2 ммоль/л»"
+		case parser.KindVital:
+			if intent.Vital.Kind == parser.VitalBloodPressure {
+				return "🩺 Укажите верхнее и нижнее давление, например: «давление 130/85»"
+			}
+			return "📏 Проверьте значение, например: «вес 82.5 кг» или «кетоны 0.8»"
+		}
+	}
+
+	return "Не понял вас. Напишите, например: «сахар 6.5 натощак», «съел гречку 150г» или «уколол 6 ед». Используйте /help для получения помощи."
+}
+
+// recordTimeLine возвращает строку со временем записи, если оно было указано явно
+func recordTimeLine(result parser.Result) string {
+	if !result.HasTime {
+		return ""
+	}
+	return "\n🕐 " + formatRecordTime(result.Time, time.Now())
+}
+
+// formatRecordTime форматирует время записи относительно текущего дня
+func formatRecordTime(t, now time.Time) string {
+	t = t.In(now.Location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch {
+	case day.Equal(today):
+		return "сегодня в " + t.Format("15:04")
+	case day.Equal(today.AddDate(0, 0, -1)):
+		return "вчера в " + t.Format("15:04")
+	}
+	return t.Format("02.01 в 15:04")
+}
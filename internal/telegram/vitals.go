@@ -0,0 +1,147 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/parser"
+	"diabetbot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const vitalsUsage = `Показатели записываются обычным сообщением:
+«вес 82.5», «давление 130/85», «кетоны 0.8»
+Можно указать время: «вчера в 8 утра вес 81»`
+
+// ketoneRequest — просьба измерить кетоны при высоком сахаре
+var ketoneRequest = fmt.Sprintf("🧪 Сахар выше %.1f — проверьте кетоны в крови и пришлите результат, например: «кетоны 0.8»",
+	services.KetoneCheckGlucose)
+
+var ketoneAdvice = map[string]string{
+	services.KetonesNormal:   "Продолжайте следить за сахаром.",
+	services.KetonesElevated: "Пейте воду без сахара, сделайте коррекцию по плану лечения и перемерьте сахар и кетоны через 2 часа.",
+	services.KetonesHigh:     "Свяжитесь с врачом сейчас. Пейте воду, следуйте плану коррекции и перемерьте сахар и кетоны через 1–2 часа.",
+	services.KetonesCritical: "🚑 Немедленно обратитесь за медицинской помощью (103 или 112), особенно если есть тошнота, рвота, боль в животе или частое дыхание.",
+}
+
+var ketoneEmoji = map[string]string{
+	services.KetonesNormal:   "✅",
+	services.KetonesElevated: "⚠️",
+	services.KetonesHigh:     "❗️",
+	services.KetonesCritical: "🆘",
+}
+
+// vitalText — «Вес 82.5 кг», «Давление 130/85 мм рт. ст.», «Кетоны 0.8 ммоль/л»
+func vitalText(vital *models.Vital) string {
+	switch vital.Kind {
+	case models.VitalKindWeight:
+		unit := "кг"
+		if vital.Unit == models.VitalUnitLb {
+			unit = "фунт."
+		}
+		return fmt.Sprintf("Вес %g %s", vital.Value, unit)
+	case models.VitalKindBloodPressure:
+		return "Давление " + services.BloodPressureText(vital) + " мм рт. ст."
+	}
+	return fmt.Sprintf("Кетоны %g ммоль/л", vital.Value)
+}
+
+// ketoneText оценивает кетоны и советует, что делать
+func ketoneText(value float64) string {
+	level := services.KetoneLevel(value)
+	return fmt.Sprintf("%s Кетоны %s. %s", ketoneEmoji[level], services.KetoneLevelText(level), ketoneAdvice[level])
+}
+
+// ketoneHint дополняет сообщение о высоком сахаре: оценка кетонов, измеренных за последние
+// часы, или просьба их измерить. Пусто при сахаре ниже services.KetoneCheckGlucose,
+// для измерений задним числом и при диабете 2 типа.
+func (b *Bot) ketoneHint(user *models.User, glucose float64, measuredAt time.Time) string {
+	if glucose < services.KetoneCheckGlucose || time.Since(measuredAt) > services.KetoneRecentWindow {
+		return ""
+	}
+	if user.DiabetesType != nil && *user.DiabetesType == 2 {
+		return ""
+	}
+	ketones, err := b.vitalService.RecentKetones(user.ID, measuredAt)
+	if err != nil {
+		log.Printf("Error loading recent ketones for user %d: %v", user.ID, err)
+	}
+	if ketones == nil {
+		return ketoneRequest
+	}
+	return fmt.Sprintf("🧪 %s в %s. %s", vitalText(ketones), ketones.MeasuredAt.Local().Format("15:04"), ketoneText(ketones.Value))
+}
+
+func (b *Bot) recordVital(chatID int64, user *models.User, vital *parser.Vital, result parser.Result) {
+	input := services.VitalInput{
+		Kind:       vital.Kind,
+		Value:      vital.Value,
+		Unit:       vital.Unit,
+		MeasuredAt: result.Time,
+	}
+	if vital.Kind == parser.VitalBloodPressure {
+		input.Diastolic = &vital.Diastolic
+	}
+	record, err := b.vitalService.Create(user.ID, input)
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		b.sendMessage(chatID, "⚠️ Значение вне допустимого диапазона. Проверьте число и единицы.\n\n"+vitalsUsage)
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving vital: %v", err)
+		b.sendMessage(chatID, "Ошибка сохранения данных")
+		return
+	}
+
+	response := "✅ Записал: " + vitalText(record) + recordTimeLine(result)
+	if record.Kind == models.VitalKindKetones {
+		response += "\n\n" + ketoneText(record.Value)
+		response += b.ketoneGlucoseNote(user, record)
+	}
+	b.sendMessage(chatID, response)
+}
+
+// ketoneGlucoseNote предупреждает, если кетоны повышены вместе с высоким сахаром
+func (b *Bot) ketoneGlucoseNote(user *models.User, ketones *models.Vital) string {
+	if services.KetoneLevel(ketones.Value) == services.KetonesNormal {
+		return ""
+	}
+	glucose, err := b.glucoseService.GetRecentRecord(user.ID)
+	if err != nil || ketones.MeasuredAt.Sub(glucose.MeasuredAt).Abs() > services.KetoneRecentWindow {
+		return ""
+	}
+	if glucose.Value < services.KetoneCheckGlucose {
+		return ""
+	}
+	return fmt.Sprintf("\nВместе с сахаром %.1f ммоль/л это признак нехватки инсулина: не откладывайте коррекцию и не занимайтесь спортом, пока кетоны не снизятся.",
+		glucose.Value)
+}
+
+// handleVitalsCommand показывает последние вес, давление и кетоны
+func (b *Bot) handleVitalsCommand(message *tgbotapi.Message, user *models.User) {
+	chatID := message.Chat.ID
+	var lines []string
+	for _, kind := range []string{models.VitalKindWeight, models.VitalKindBloodPressure, models.VitalKindKetones} {
+		page, err := b.vitalService.List(user.ID, services.ListOptions{Limit: 1}, kind)
+		if err != nil {
+			log.Printf("Error listing vitals for user %d: %v", user.ID, err)
+			b.sendMessage(chatID, "❌ Не удалось загрузить показатели, попробуйте позже.")
+			return
+		}
+		if len(page.Items) > 0 {
+			vital := &page.Items[0]
+			lines = append(lines, fmt.Sprintf("%s — %s", vitalText(vital), vital.MeasuredAt.Local().Format("02.01.2006 15:04")))
+		}
+	}
+	if len(lines) == 0 {
+		b.sendMessage(chatID, "📏 Показателей пока нет.\n\n"+vitalsUsage)
+		return
+	}
+	b.sendMessage(chatID, "📏 Последние показатели:\n"+strings.Join(lines, "\n")+"\n\n"+vitalsUsage)
+}
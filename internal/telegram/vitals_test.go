@@ -0,0 +1,83 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"diabetbot/internal/models"
+	"diabetbot/internal/services"
+	"diabetbot/internal/testutils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBot_VitalEntryAndCommand(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 8500)
+	bot.handleCommand(commandMessage(8500, "/vitals", "/vitals"), user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "Показателей пока нет")
+
+	bot.handleTextMessage(&tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 8500}, Text: "давление 130/85"}, user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "Записал: Давление 130/85 мм рт. ст.")
+	bot.handleTextMessage(&tgbotapi.Message{MessageID: 2, Chat: &tgbotapi.Chat{ID: 8500}, Text: "вес 82,5 кг"}, user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "Записал: Вес 82.5 кг")
+
+	page, err := bot.vitalService.List(user.ID, services.ListOptions{}, models.VitalKindBloodPressure)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.NotNil(t, page.Items[0].Diastolic)
+	assert.Equal(t, 85.0, *page.Items[0].Diastolic)
+
+	// Одно число давления бот переспрашивает и не сохраняет
+	bot.handleTextMessage(&tgbotapi.Message{MessageID: 3, Chat: &tgbotapi.Chat{ID: 8500}, Text: "давление 130"}, user)
+	assert.Contains(t, mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text, "верхнее и нижнее давление")
+
+	bot.handleCommand(commandMessage(8500, "/vitals", "/vitals"), user)
+	text := mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "Вес 82.5 кг")
+	assert.Contains(t, text, "Давление 130/85 мм рт. ст.")
+	assert.NotContains(t, text, "Кетоны")
+}
+
+func TestBot_KetonesWithHighGlucose(t *testing.T) {
+	bot, mockAPI, testDB := createTestBot()
+	defer testutils.CleanupTestDB(testDB.DB)
+
+	user := testutils.CreateTestUser(testDB.DB, 8510)
+	send := func(text string) string {
+		bot.handleTextMessage(&tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 8510}, Text: text}, user)
+		return mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	}
+
+	assert.NotContains(t, send("сахар 9.2"), "кетоны")
+	assert.Contains(t, send("сахар 15.4"), "проверьте кетоны в крови и пришлите результат")
+
+	text := send("кетоны 1.8")
+	assert.Contains(t, text, "Записал: Кетоны 1.8 ммоль/л")
+	assert.Contains(t, text, "высокие, риск кетоацидоза")
+	assert.Contains(t, text, "Вместе с сахаром 15.4 ммоль/л")
+
+	// Следующий высокий сахар показывает свежие кетоны вместо просьбы измерить
+	text = send("сахар 16")
+	assert.Contains(t, text, "Кетоны 1.8 ммоль/л в")
+	assert.NotContains(t, text, "пришлите результат")
+
+	assert.Contains(t, send("кетоны 0.3"), "Кетоны в норме")
+
+	// Тревога владельцу о высоком сахаре тоже опирается на свежие кетоны
+	mockAPI.ClearMessages()
+	alert := models.Alert{UserID: user.ID, Kind: models.AlertKindHigh, Value: 21, MeasuredAt: time.Now(), RecheckBy: time.Now().Add(15 * time.Minute)}
+	bot.NotifyAlert(services.AlertEvent{Type: services.AlertEventRaised, Alert: alert, Owner: user})
+	text = mockAPI.GetLastSentMessage().(tgbotapi.MessageConfig).Text
+	assert.Contains(t, text, "Следуйте плану коррекции")
+	assert.Contains(t, text, "Кетоны 0.3 ммоль/л")
+
+	// При диабете 2 типа кетоны не запрашиваются
+	diabetesType := 2
+	user.DiabetesType = &diabetesType
+	assert.NotContains(t, send("сахар 15"), "кетоны")
+}
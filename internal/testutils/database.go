@@ -2,32 +2,43 @@ package testutils
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"diabetbot/internal/database"
 	"diabetbot/internal/models"
 
-	"gorm.io/driver/sqlite"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// SetupTestDB создает временную SQLite базу данных для тестов
+// SetupTestDB создает тестовую базу данных со схемой из миграций.
+// По умолчанию это SQLite в памяти; TEST_DB_DRIVER=postgres запускает тесты
+// на PostgreSQL из TEST_DB_DSN, каждый раз в отдельной схеме.
 func SetupTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
-	})
+	var db *gorm.DB
+	var err error
+
+	switch driver := os.Getenv("TEST_DB_DRIVER"); driver {
+	case "", database.DriverSQLite:
+		db, err = openTestSQLite()
+	case database.DriverPostgres:
+		db, err = openTestPostgres()
+	default:
+		err = fmt.Errorf("unsupported TEST_DB_DRIVER %q", driver)
+	}
 	if err != nil {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
-	// Автомиграция моделей
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.GlucoseRecord{},
-		&models.FoodRecord{},
-		&models.AIRecommendation{},
-	)
+	migrator, err := database.NewMigrator(db)
+	if err == nil {
+		_, err = migrator.Up()
+	}
 	if err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}
@@ -35,8 +46,56 @@ func SetupTestDB(t *testing.T) *gorm.DB {
 	return db
 }
 
-// CleanupTestDB очищает тестовую базу данных
+func openTestSQLite() (*gorm.DB, error) {
+	db, err := database.Open(database.SQLiteDialector(":memory:"), logger.Silent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Каждое подключение к :memory: открывает свою пустую базу
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+var testSchemaCounter int64
+
+func openTestPostgres() (*gorm.DB, error) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		return nil, fmt.Errorf("TEST_DB_DSN is required for TEST_DB_DRIVER=postgres")
+	}
+
+	admin, err := database.Open(postgres.Open(dsn), logger.Silent)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDB(admin)
+
+	schema := fmt.Sprintf("test_%d_%d", os.Getpid(), atomic.AddInt64(&testSchemaCounter, 1))
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		return nil, err
+	}
+
+	return database.Open(postgres.Open(dsn+" search_path="+schema), logger.Silent)
+}
+
+// CleanupTestDB закрывает тестовую базу данных и удаляет схему PostgreSQL
 func CleanupTestDB(db *gorm.DB) {
+	if db.Dialector.Name() == database.DriverPostgres {
+		var schema string
+		if err := db.Raw("SELECT current_schema()").Scan(&schema).Error; err == nil && strings.HasPrefix(schema, "test_") {
+			db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		}
+	}
+	closeDB(db)
+}
+
+func closeDB(db *gorm.DB) {
 	sqlDB, _ := db.DB()
 	if sqlDB != nil {
 		sqlDB.Close()
@@ -0,0 +1,300 @@
+package testutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"diabetbot/internal/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const FakeBotToken = "123456:TEST-TOKEN"
+
+// FakeFile описывает файл, загруженный через multipart запрос
+type FakeFile struct {
+	Name string
+	Data []byte
+}
+
+// BotAPICall описывает запрос, полученный фейковым Bot API
+type BotAPICall struct {
+	Method string
+	Params map[string]string
+	Files  map[string]FakeFile
+	At     time.Time
+}
+
+// ChatID возвращает chat_id запроса или 0
+func (c BotAPICall) ChatID() int64 {
+	id, _ := strconv.ParseInt(c.Params["chat_id"], 10, 64)
+	return id
+}
+
+// Text возвращает текст сообщения (text или caption)
+func (c BotAPICall) Text() string {
+	if text, ok := c.Params["text"]; ok {
+		return text
+	}
+	return c.Params["caption"]
+}
+
+// ReplyMarkup разбирает reply_markup запроса в inline клавиатуру
+func (c BotAPICall) ReplyMarkup() *tgbotapi.InlineKeyboardMarkup {
+	raw, ok := c.Params["reply_markup"]
+	if !ok {
+		return nil
+	}
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(raw), &markup); err != nil {
+		return nil
+	}
+	return &markup
+}
+
+// FakeBotAPI эмулирует Telegram Bot API для тестов.
+// Поддерживает getMe, sendMessage, answerCallbackQuery, editMessageText,
+// sendDocument и getFile, отдает файлы, добавленные через AddFile,
+// и записывает все полученные запросы.
+type FakeBotAPI struct {
+	Server *httptest.Server
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	calls     []BotAPICall
+	responses map[string][]string
+	files     map[string]FakeFile // по file_id
+	messageID int
+}
+
+// NewFakeBotAPI запускает фейковый Bot API, который останавливается по завершении теста
+func NewFakeBotAPI(t *testing.T) *FakeBotAPI {
+	f := &FakeBotAPI{responses: make(map[string][]string), files: make(map[string]FakeFile)}
+	f.cond = sync.NewCond(&f.mu)
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// Endpoint возвращает шаблон адреса API в формате tgbotapi.APIEndpoint
+func (f *FakeBotAPI) Endpoint() string {
+	return f.Server.URL + "/bot%s/%s"
+}
+
+// TelegramConfig возвращает конфигурацию бота, направленную на фейковый API
+func (f *FakeBotAPI) TelegramConfig() *config.TelegramConfig {
+	return &config.TelegramConfig{
+		BotToken:    FakeBotToken,
+		APIEndpoint: f.Endpoint(),
+		Workers:     2,
+		QueueSize:   16,
+	}
+}
+
+// NewBotAPI создает клиент tgbotapi, работающий с фейковым API
+func (f *FakeBotAPI) NewBotAPI(t *testing.T) *tgbotapi.BotAPI {
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint(FakeBotToken, f.Endpoint())
+	if err != nil {
+		t.Fatalf("Failed to create bot API client: %v", err)
+	}
+	return api
+}
+
+// QueueResponse задает ответ на следующий вызов метода, например ошибку Telegram.
+// Без заданных ответов метод отвечает успехом.
+func (f *FakeBotAPI) QueueResponse(method, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[method] = append(f.responses[method], body)
+}
+
+// AddFile добавляет файл, который бот сможет скачать по fileID через getFile
+func (f *FakeBotAPI) AddFile(fileID, name string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[fileID] = FakeFile{Name: name, Data: data}
+}
+
+// Calls возвращает все полученные запросы, кроме getMe
+func (f *FakeBotAPI) Calls() []BotAPICall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.filter("")
+}
+
+// CallsTo возвращает запросы к указанному методу
+func (f *FakeBotAPI) CallsTo(method string) []BotAPICall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.filter(method)
+}
+
+// WaitForCalls ждет, пока метод будет вызван не менее n раз, и возвращает вызовы.
+// Нужен, потому что бот отправляет сообщения асинхронно.
+func (f *FakeBotAPI) WaitForCalls(t *testing.T, method string, n int) []BotAPICall {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	timer := time.AfterFunc(5*time.Second, func() {
+		f.mu.Lock()
+		f.cond.Broadcast()
+		f.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for {
+		calls := f.filter(method)
+		if len(calls) >= n {
+			return calls
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d calls to %s, got %d", n, method, len(calls))
+		}
+		f.cond.Wait()
+	}
+}
+
+// Reset очищает записанные запросы и заданные ответы
+func (f *FakeBotAPI) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+	f.responses = make(map[string][]string)
+}
+
+func (f *FakeBotAPI) filter(method string) []BotAPICall {
+	var calls []BotAPICall
+	for _, call := range f.calls {
+		if call.Method == "getMe" {
+			continue
+		}
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (f *FakeBotAPI) serve(w http.ResponseWriter, r *http.Request) {
+	if fileID, ok := strings.CutPrefix(r.URL.Path, "/file/bot"+FakeBotToken+"/"); ok {
+		f.serveFile(w, fileID)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] != "bot"+FakeBotToken {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"ok":false,"error_code":404,"description":"Not Found"}`)
+		return
+	}
+	method := parts[1]
+
+	call := BotAPICall{
+		Method: method,
+		Params: make(map[string]string),
+		Files:  make(map[string]FakeFile),
+		At:     time.Now(),
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err == nil {
+			for key, values := range r.MultipartForm.Value {
+				call.Params[key] = values[0]
+			}
+			for key, headers := range r.MultipartForm.File {
+				file, err := headers[0].Open()
+				if err != nil {
+					continue
+				}
+				data, _ := io.ReadAll(file)
+				file.Close()
+				call.Files[key] = FakeFile{Name: headers[0].Filename, Data: data}
+			}
+		}
+	} else if err := r.ParseForm(); err == nil {
+		for key, values := range r.PostForm {
+			call.Params[key] = values[0]
+		}
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	var response string
+	if queued := f.responses[method]; len(queued) > 0 {
+		response, f.responses[method] = queued[0], queued[1:]
+	} else {
+		response = f.defaultResponse(call)
+	}
+	f.cond.Broadcast()
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, response)
+}
+
+// serveFile отдает содержимое файла; file_path в ответе getFile совпадает с file_id
+func (f *FakeBotAPI) serveFile(w http.ResponseWriter, fileID string) {
+	f.mu.Lock()
+	file, ok := f.files[fileID]
+	f.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(file.Data)
+}
+
+// defaultResponse формирует успешный ответ; вызывается под мьютексом
+func (f *FakeBotAPI) defaultResponse(call BotAPICall) string {
+	switch call.Method {
+	case "getMe":
+		return `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"DiabetBot","username":"diabetbot_test"}}`
+	case "sendMessage", "editMessageText", "sendDocument":
+		f.messageID++
+		messageID := f.messageID
+		if id, err := strconv.Atoi(call.Params["message_id"]); err == nil && call.Method == "editMessageText" {
+			messageID = id
+		}
+		message := map[string]interface{}{
+			"message_id": messageID,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]interface{}{"id": call.ChatID(), "type": "private"},
+			"text":       call.Params["text"],
+		}
+		if file, ok := call.Files["document"]; ok {
+			message["document"] = map[string]interface{}{
+				"file_id":        fmt.Sprintf("file-%d", messageID),
+				"file_unique_id": fmt.Sprintf("unique-%d", messageID),
+				"file_name":      file.Name,
+				"file_size":      len(file.Data),
+			}
+		}
+		result, _ := json.Marshal(map[string]interface{}{"ok": true, "result": message})
+		return string(result)
+	case "answerCallbackQuery":
+		return `{"ok":true,"result":true}`
+	case "getFile":
+		file, ok := f.files[call.Params["file_id"]]
+		if !ok {
+			return `{"ok":false,"error_code":400,"description":"Bad Request: invalid file_id"}`
+		}
+		result, _ := json.Marshal(map[string]interface{}{"ok": true, "result": map[string]interface{}{
+			"file_id":        call.Params["file_id"],
+			"file_unique_id": "unique-" + call.Params["file_id"],
+			"file_size":      len(file.Data),
+			"file_path":      call.Params["file_id"],
+		}})
+		return string(result)
+	default:
+		return fmt.Sprintf(`{"ok":false,"error_code":404,"description":"Not Found: method %s is not supported by fake API"}`, call.Method)
+	}
+}
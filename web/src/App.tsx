@@ -138,7 +138,7 @@ function App() {
           <Route path="/" element={<Dashboard user={user} />} />
           <Route path="/glucose" element={<GlucoseRecords user={user} />} />
           <Route path="/food" element={<FoodRecords user={user} />} />
-          <Route path="/add/:type" element={<AddRecord user={user} />} />
+          <Route path="/add/:type" element={<AddRecord />} />
           <Route path="/settings" element={<Settings user={user} setUser={setUser} />} />
           <Route path="*" element={<Navigate to="/" replace />} />
         </Routes>
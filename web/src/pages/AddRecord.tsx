@@ -1,13 +1,8 @@
 import { useState } from 'react'
 import { useParams, useNavigate } from 'react-router-dom'
 import { ApiService } from '../services/api'
-import { User } from '../types'
 
-interface Props {
-  user: User
-}
-
-function AddRecord({ user }: Props) {
+function AddRecord() {
   const { type } = useParams<{ type: string }>()
   const navigate = useNavigate()
   const [loading, setLoading] = useState(false)
@@ -30,11 +25,10 @@ function AddRecord({ user }: Props) {
 
     try {
       if (type === 'glucose') {
-        await ApiService.createGlucoseRecord(user.telegram_id, parseFloat(glucoseValue), '')
+        await ApiService.createGlucoseRecord(parseFloat(glucoseValue), '')
         navigate('/glucose')
       } else if (type === 'food') {
         await ApiService.createFoodRecord(
-          user.telegram_id,
           foodDescription,
           mealType,
           parseFloat(foodCarbs),
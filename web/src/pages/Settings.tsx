@@ -57,6 +57,34 @@ function Settings({ user, setUser }: Props) {
     }
   }
 
+  // Скачивает файл, полученный от API
+  const download = async (load: () => Promise<Blob>, filename: string, errorText: string) => {
+    setLoading(true)
+    setError(null)
+
+    try {
+      const blob = await load()
+      const url = URL.createObjectURL(blob)
+      const link = document.createElement('a')
+      link.href = url
+      link.download = filename
+      link.click()
+      URL.revokeObjectURL(url)
+    } catch (err) {
+      setError(err instanceof Error ? err.message : errorText)
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const today = () => new Date().toISOString().slice(0, 10)
+
+  const handleExport = (format: 'csv' | 'json') =>
+    download(() => ApiService.exportDiary(format), `diabetbot-${today()}.${format}`, 'Ошибка выгрузки')
+
+  const handleReport = (days: number) =>
+    download(() => ApiService.getReport(days), `diabetbot-report-${today()}.pdf`, 'Ошибка формирования отчета')
+
   return (
     <div className="page">
       <h2>Настройки</h2>
@@ -102,6 +130,27 @@ function Settings({ user, setUser }: Props) {
         </div>
       </div>
 
+      <div className="section">
+        <h3>Выгрузка дневника</h3>
+        <button type="button" className="button" onClick={() => handleExport('csv')} disabled={loading}>
+          Скачать CSV
+        </button>
+        <button type="button" className="button" onClick={() => handleExport('json')} disabled={loading}>
+          Скачать JSON
+        </button>
+        <small>Глюкоза, питание и инсулин за все время</small>
+      </div>
+
+      <div className="section">
+        <h3>Отчет для врача</h3>
+        {[14, 30, 90].map((days) => (
+          <button key={days} type="button" className="button" onClick={() => handleReport(days)} disabled={loading}>
+            PDF за {days} дней
+          </button>
+        ))}
+        <small>Время в диапазоне, суточный профиль, гипогликемии и питание</small>
+      </div>
+
       <div className="section danger-zone">
         <h3>Опасная зона</h3>
         <button 
@@ -1,4 +1,4 @@
-import { ApiService } from '../api'
+import { ApiService, ApiRequestError, toApiError } from '../api'
 import axios, { AxiosInstance } from 'axios'
 
 // Mock axios
@@ -73,27 +73,59 @@ describe('ApiService', () => {
     })
   })
 
+  describe('Export', () => {
+    test('exportDiary requests file as blob', async () => {
+      const blob = new Blob(['type,id'])
+      mockAxiosInstance.get.mockResolvedValue({ data: blob })
+
+      const result = await ApiService.exportDiary('json')
+
+      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/export?format=json', { responseType: 'blob' })
+      expect(result).toBe(blob)
+    })
+
+    test('getReport requests PDF for period', async () => {
+      const blob = new Blob(['%PDF-'])
+      mockAxiosInstance.get.mockResolvedValue({ data: blob })
+
+      const result = await ApiService.getReport(14)
+
+      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/report?days=14', { responseType: 'blob' })
+      expect(result).toBe(blob)
+    })
+  })
+
   describe('Glucose methods', () => {
     test('getGlucoseRecords makes correct API call with default days', async () => {
       const mockRecords = [
         { id: 1, user_id: 1, value: 6.5, measured_at: '2024-01-01T08:00:00Z' }
       ]
 
-      mockAxiosInstance.get.mockResolvedValue({ data: mockRecords })
+      mockAxiosInstance.get.mockResolvedValue({ data: { items: mockRecords, next_cursor: null } })
 
       const result = await ApiService.getGlucoseRecords(1)
 
-      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/glucose/1?days=30')
+      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/glucose/1?days=30&limit=500')
       expect(result).toEqual(mockRecords)
     })
 
     test('getGlucoseRecords makes correct API call with custom days', async () => {
-      const mockRecords: any[] = []
-      mockAxiosInstance.get.mockResolvedValue({ data: mockRecords })
+      mockAxiosInstance.get.mockResolvedValue({ data: { items: [], next_cursor: null } })
 
       await ApiService.getGlucoseRecords(1, 7)
 
-      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/glucose/1?days=7')
+      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/glucose/1?days=7&limit=500')
+    })
+
+    test('getGlucoseRecords follows next_cursor', async () => {
+      mockAxiosInstance.get
+        .mockResolvedValueOnce({ data: { items: [{ id: 2 }], next_cursor: 'abc' } })
+        .mockResolvedValueOnce({ data: { items: [{ id: 1 }], next_cursor: null } })
+
+      const result = await ApiService.getGlucoseRecords(1)
+
+      expect(mockAxiosInstance.get).toHaveBeenNthCalledWith(2, '/glucose/1?days=30&limit=500&cursor=abc')
+      expect(result).toEqual([{ id: 2 }, { id: 1 }])
     })
 
     test('createGlucoseRecord makes correct API call', async () => {
@@ -107,10 +139,9 @@ describe('ApiService', () => {
 
       mockAxiosInstance.post.mockResolvedValue({ data: mockRecord })
 
-      const result = await ApiService.createGlucoseRecord(1, 6.5, 'Test note')
+      const result = await ApiService.createGlucoseRecord(6.5, 'Test note')
 
       expect(mockAxiosInstance.post).toHaveBeenCalledWith('/glucose', {
-        user_id: 1,
         value: 6.5,
         notes: 'Test note',
       })
@@ -121,10 +152,9 @@ describe('ApiService', () => {
       const mockRecord = { id: 1, user_id: 1, value: 6.5, notes: '' }
       mockAxiosInstance.post.mockResolvedValue({ data: mockRecord })
 
-      await ApiService.createGlucoseRecord(1, 6.5)
+      await ApiService.createGlucoseRecord(6.5)
 
       expect(mockAxiosInstance.post).toHaveBeenCalledWith('/glucose', {
-        user_id: 1,
         value: 6.5,
         notes: '',
       })
@@ -133,10 +163,9 @@ describe('ApiService', () => {
     test('updateGlucoseRecord makes correct API call', async () => {
       mockAxiosInstance.put.mockResolvedValue({ data: {} })
 
-      await ApiService.updateGlucoseRecord(1, 1, 7.0, 'Updated note')
+      await ApiService.updateGlucoseRecord(1, 7.0, 'Updated note')
 
       expect(mockAxiosInstance.put).toHaveBeenCalledWith('/glucose/1', {
-        user_id: 1,
         value: 7.0,
         notes: 'Updated note',
       })
@@ -145,9 +174,9 @@ describe('ApiService', () => {
     test('deleteGlucoseRecord makes correct API call', async () => {
       mockAxiosInstance.delete.mockResolvedValue({ data: {} })
 
-      await ApiService.deleteGlucoseRecord(1, 1)
+      await ApiService.deleteGlucoseRecord(1)
 
-      expect(mockAxiosInstance.delete).toHaveBeenCalledWith('/glucose/1?user_id=1')
+      expect(mockAxiosInstance.delete).toHaveBeenCalledWith('/glucose/1')
     })
 
     test('getGlucoseStats makes correct API call', async () => {
@@ -175,21 +204,20 @@ describe('ApiService', () => {
       const mockRecords = [
         { id: 1, user_id: 1, food_name: 'Овсянка', food_type: 'завтрак' }
       ]
-      mockAxiosInstance.get.mockResolvedValue({ data: mockRecords })
+      mockAxiosInstance.get.mockResolvedValue({ data: { items: mockRecords, next_cursor: null } })
 
       const result = await ApiService.getFoodRecords(1, 7)
 
-      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/food/1?days=7')
+      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/food/1?days=7&limit=500')
       expect(result).toEqual(mockRecords)
     })
 
     test('getFoodRecords makes correct API call with type filter', async () => {
-      const mockRecords: any[] = []
-      mockAxiosInstance.get.mockResolvedValue({ data: mockRecords })
+      mockAxiosInstance.get.mockResolvedValue({ data: { items: [], next_cursor: null } })
 
       await ApiService.getFoodRecords(1, 7, 'завтрак')
 
-      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/food/1?days=7&type=завтрак')
+      expect(mockAxiosInstance.get).toHaveBeenCalledWith('/food/1?days=7&limit=500&type=завтрак')
     })
 
     test('createFoodRecord makes correct API call with all parameters', async () => {
@@ -207,7 +235,6 @@ describe('ApiService', () => {
       mockAxiosInstance.post.mockResolvedValue({ data: mockRecord })
 
       const result = await ApiService.createFoodRecord(
-        1,
         'Овсянка',
         'завтрак',
         45.0,
@@ -217,7 +244,6 @@ describe('ApiService', () => {
       )
 
       expect(mockAxiosInstance.post).toHaveBeenCalledWith('/food', {
-        user_id: 1,
         food_name: 'Овсянка',
         food_type: 'завтрак',
         carbs: 45.0,
@@ -238,10 +264,9 @@ describe('ApiService', () => {
 
       mockAxiosInstance.post.mockResolvedValue({ data: mockRecord })
 
-      await ApiService.createFoodRecord(1, 'Яблоко', 'перекус')
+      await ApiService.createFoodRecord('Яблоко', 'перекус')
 
       expect(mockAxiosInstance.post).toHaveBeenCalledWith('/food', {
-        user_id: 1,
         food_name: 'Яблоко',
         food_type: 'перекус',
         carbs: null,
@@ -259,20 +284,17 @@ describe('ApiService', () => {
         carbs: 30.0,
       }
 
-      await ApiService.updateFoodRecord(1, 1, updates)
+      await ApiService.updateFoodRecord(1, updates)
 
-      expect(mockAxiosInstance.put).toHaveBeenCalledWith('/food/1', {
-        user_id: 1,
-        ...updates,
-      })
+      expect(mockAxiosInstance.put).toHaveBeenCalledWith('/food/1', updates)
     })
 
     test('deleteFoodRecord makes correct API call', async () => {
       mockAxiosInstance.delete.mockResolvedValue({ data: {} })
 
-      await ApiService.deleteFoodRecord(1, 1)
+      await ApiService.deleteFoodRecord(1)
 
-      expect(mockAxiosInstance.delete).toHaveBeenCalledWith('/food/1?user_id=1')
+      expect(mockAxiosInstance.delete).toHaveBeenCalledWith('/food/1')
     })
   })
 
@@ -295,5 +317,33 @@ describe('ApiService', () => {
 
       await expect(ApiService.getUser(123)).rejects.toEqual(errorResponse)
     })
+
+    test('toApiError converts error envelope', () => {
+      const error = toApiError({
+        response: {
+          status: 400,
+          data: {
+            error: {
+              code: 'validation_error',
+              message: 'Проверьте введенные данные',
+              details: [{ field: 'value', rule: 'max', message: 'Значение должно быть не больше 30' }],
+              request_id: 'abc123',
+            },
+          },
+        },
+      })
+
+      expect(error).toBeInstanceOf(ApiRequestError)
+      expect(error.message).toBe('Проверьте введенные данные')
+      expect(error.code).toBe('validation_error')
+      expect(error.status).toBe(400)
+      expect(error.details).toHaveLength(1)
+      expect(error.requestId).toBe('abc123')
+    })
+
+    test('toApiError keeps network errors', () => {
+      const error = new Error('Network Error')
+      expect(toApiError(error)).toBe(error)
+    })
   })
 })
\ No newline at end of file
@@ -1,8 +1,9 @@
 import axios from 'axios'
-import { User, GlucoseRecord, FoodRecord, GlucoseStats } from '../types'
+import { User, GlucoseRecord, FoodRecord, GlucoseStats, Page, ApiErrorBody, ApiFieldError } from '../types'
 import { initTelegramWebApp, getTelegramUser } from '../utils/telegram'
 
 const API_BASE_URL = '/api/v1'
+const PAGE_LIMIT = 500
 
 const api = axios.create({
   baseURL: API_BASE_URL,
@@ -27,6 +28,11 @@ api.interceptors.request.use((config) => {
     }
   }
   
+  // Подписанные данные Telegram, по ним сервер проверяет пользователя
+  if (webApp?.initData) {
+    config.headers['X-Telegram-Init-Data'] = webApp.initData
+  }
+
   if (telegramUser) {
     config.headers['X-Telegram-Username'] = telegramUser.username || ''
     config.headers['X-Telegram-First-Name'] = telegramUser.first_name || ''
@@ -37,6 +43,52 @@ api.interceptors.request.use((config) => {
   return config
 })
 
+// Ошибка API с кодом, сообщением для пользователя и ошибками по полям
+export class ApiRequestError extends Error {
+  code: string
+  status?: number
+  details: ApiFieldError[]
+  requestId?: string
+
+  constructor(body: ApiErrorBody, status?: number) {
+    super(body.message)
+    this.name = 'ApiRequestError'
+    this.code = body.code
+    this.status = status
+    this.details = body.details || []
+    this.requestId = body.request_id
+  }
+}
+
+// toApiError превращает ответ с телом {"error": {...}} в ApiRequestError,
+// остальные ошибки (сеть, таймаут) возвращаются как есть
+export function toApiError(error: any): any {
+  const body = error?.response?.data?.error
+  if (body && typeof body === 'object' && typeof body.message === 'string') {
+    return new ApiRequestError(body, error.response.status)
+  }
+  return error
+}
+
+api.interceptors.response.use(
+  (response) => response,
+  (error) => Promise.reject(toApiError(error))
+)
+
+// Списки отдаются страницами, собираем все записи за запрошенный период
+async function fetchAllPages<T>(url: string): Promise<T[]> {
+  const items: T[] = []
+  let cursor: string | null = null
+  do {
+    const pageUrl: string = cursor ? `${url}&cursor=${encodeURIComponent(cursor)}` : url
+    const response = await api.get(pageUrl)
+    const page: Page<T> = response.data
+    items.push(...page.items)
+    cursor = page.next_cursor
+  } while (cursor)
+  return items
+}
+
 export class ApiService {
   // User methods
   static async getUser(telegramId: number): Promise<User> {
@@ -63,31 +115,41 @@ export class ApiService {
     await api.delete(`/user/${telegramId}/data`)
   }
 
+  // Выгрузка дневника текущего пользователя (определяется по initData)
+  static async exportDiary(format: 'csv' | 'json' = 'csv'): Promise<Blob> {
+    const response = await api.get(`/export?format=${format}`, { responseType: 'blob' })
+    return response.data
+  }
+
+  // PDF-отчет для врача за последние days дней
+  static async getReport(days = 30): Promise<Blob> {
+    const response = await api.get(`/report?days=${days}`, { responseType: 'blob' })
+    return response.data
+  }
+
   // Glucose methods
   static async getGlucoseRecords(userId: number, days = 30): Promise<GlucoseRecord[]> {
-    const response = await api.get(`/glucose/${userId}?days=${days}`)
-    return response.data
+    return fetchAllPages<GlucoseRecord>(`/glucose/${userId}?days=${days}&limit=${PAGE_LIMIT}`)
   }
 
-  static async createGlucoseRecord(userId: number, value: number, notes = ''): Promise<GlucoseRecord> {
+  // Записи создаются, меняются и удаляются в дневнике пользователя из initData
+  static async createGlucoseRecord(value: number, notes = ''): Promise<GlucoseRecord> {
     const response = await api.post('/glucose', {
-      user_id: userId,
       value,
       notes,
     })
     return response.data
   }
 
-  static async updateGlucoseRecord(recordId: number, userId: number, value: number, notes = ''): Promise<void> {
+  static async updateGlucoseRecord(recordId: number, value: number, notes = ''): Promise<void> {
     await api.put(`/glucose/${recordId}`, {
-      user_id: userId,
       value,
       notes,
     })
   }
 
-  static async deleteGlucoseRecord(recordId: number, userId: number): Promise<void> {
-    await api.delete(`/glucose/${recordId}?user_id=${userId}`)
+  static async deleteGlucoseRecord(recordId: number): Promise<void> {
+    await api.delete(`/glucose/${recordId}`)
   }
 
   static async getGlucoseStats(userId: number, days = 30): Promise<GlucoseStats> {
@@ -97,16 +159,14 @@ export class ApiService {
 
   // Food methods
   static async getFoodRecords(userId: number, days = 30, type?: string): Promise<FoodRecord[]> {
-    let url = `/food/${userId}?days=${days}`
+    let url = `/food/${userId}?days=${days}&limit=${PAGE_LIMIT}`
     if (type) {
       url += `&type=${type}`
     }
-    const response = await api.get(url)
-    return response.data
+    return fetchAllPages<FoodRecord>(url)
   }
 
   static async createFoodRecord(
-    userId: number,
     foodName: string,
     foodType: string,
     carbs?: number,
@@ -115,7 +175,6 @@ export class ApiService {
     notes?: string
   ): Promise<FoodRecord> {
     const response = await api.post('/food', {
-      user_id: userId,
       food_name: foodName,
       food_type: foodType,
       carbs: carbs || null,
@@ -128,7 +187,6 @@ export class ApiService {
 
   static async updateFoodRecord(
     recordId: number,
-    userId: number,
     updates: {
       food_name?: string
       food_type?: string
@@ -138,13 +196,10 @@ export class ApiService {
       notes?: string
     }
   ): Promise<void> {
-    await api.put(`/food/${recordId}`, {
-      user_id: userId,
-      ...updates,
-    })
+    await api.put(`/food/${recordId}`, updates)
   }
 
-  static async deleteFoodRecord(recordId: number, userId: number): Promise<void> {
-    await api.delete(`/food/${recordId}?user_id=${userId}`)
+  static async deleteFoodRecord(recordId: number): Promise<void> {
+    await api.delete(`/food/${recordId}`)
   }
 }
\ No newline at end of file
@@ -37,6 +37,25 @@ export interface FoodRecord {
   updated_at: string
 }
 
+// Страница списка: next_cursor передается в параметре cursor для следующей страницы
+export interface Page<T> {
+  items: T[]
+  next_cursor: string | null
+}
+
+export interface ApiFieldError {
+  field: string
+  rule: string
+  message: string
+}
+
+export interface ApiErrorBody {
+  code: string
+  message: string
+  details?: ApiFieldError[]
+  request_id?: string
+}
+
 export interface GlucoseStats {
   average: number
   min: number
@@ -45,6 +64,7 @@ export interface GlucoseStats {
 }
 
 export interface TelegramWebApp {
+  initData?: string
   initDataUnsafe: {
     user?: {
       id: number